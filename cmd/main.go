@@ -31,8 +31,14 @@ func main() {
 	)
 	log.Debug("debug messages are enabled")
 
-	application := app.NewApp(log, cfg.StoragePath, cfg.TokenTTL, cfg.GRPC.Port)
+	application := app.NewApp(log, cfg)
 	go application.GRPCApp.MustRun()
+	if application.HTTPApp != nil {
+		go application.HTTPApp.MustRun()
+	}
+	if application.GatewayApp != nil {
+		go application.GatewayApp.MustRun()
+	}
 
 	// Channel for graceful shutdown
 	stop := make(chan os.Signal, 1)
@@ -40,10 +46,20 @@ func main() {
 
 	<-stop
 
-	_, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 	defer cancel()
+	if application.GatewayApp != nil {
+		if err := application.GatewayApp.Stop(ctx); err != nil {
+			log.Error("failed to stop gateway server", "error", err)
+		}
+	}
+	if application.HTTPApp != nil {
+		if err := application.HTTPApp.Stop(ctx); err != nil {
+			log.Error("failed to stop http server", "error", err)
+		}
+	}
 	if err := application.GRPCApp.Stop(); err != nil {
-		log.Error("failed to stop grpc server", err)
+		log.Error("failed to stop grpc server", "error", err)
 		os.Exit(1)
 	}
 
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/lib/userfile"
+	servicestorage "github.com/Len4i/auth-service/internal/services/storage"
+	"github.com/Len4i/auth-service/internal/storage/sqlite"
+)
+
+const usage = `usage:
+  usertool import --storage-path=<path> [--format=csv|jsonl] [--file=<path>] [--batch-size=500] [--dry-run] [--report=<path>]
+  usertool export --storage-path=<path> [--format=csv|jsonl] [--file=<path>] [--batch-size=500]`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "import":
+		runImport(os.Args[2:])
+	case "export":
+		runExport(os.Args[2:])
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// row is a parsed user along with its line in the input file
+type row struct {
+	line int
+	user models.User
+}
+
+func runImport(args []string) {
+	var storagePath, format, filePath, reportPath string
+	var batchSize int
+	var dryRun bool
+
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	fs.StringVar(&storagePath, "storage-path", "", "path to storage")
+	fs.StringVar(&format, "format", userfile.FormatCSV, "input format: csv or jsonl")
+	fs.StringVar(&filePath, "file", "-", "input file, - for stdin")
+	fs.IntVar(&batchSize, "batch-size", 500, "users per transaction")
+	fs.BoolVar(&dryRun, "dry-run", false, "validate and insert without committing")
+	fs.StringVar(&reportPath, "report", "-", "per-row error report file, - for stderr")
+	_ = fs.Parse(args)
+
+	if storagePath == "" {
+		usageError("storage path is required")
+	}
+	if batchSize <= 0 {
+		usageError("batch size must be positive")
+	}
+
+	in := os.Stdin
+	if filePath != "-" {
+		f, err := os.Open(filePath)
+		if err != nil {
+			fail(err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	report := os.Stderr
+	if reportPath != "-" {
+		f, err := os.Create(reportPath)
+		if err != nil {
+			fail(err)
+		}
+		defer f.Close()
+		report = f
+	}
+
+	storage, err := sqlite.New(storagePath)
+	if err != nil {
+		fail(err)
+	}
+
+	reader, err := userfile.NewReader(in, format)
+	if err != nil {
+		fail(err)
+	}
+
+	ctx := context.Background()
+	var imported, failed int
+	// emails of earlier batches are committed in a real import, but rolled
+	// back in a dry run, duplicates across batches are detected here instead
+	seen := make(map[string]bool)
+
+	flush := func(batch []row) {
+		users := make([]models.User, len(batch))
+		for i, r := range batch {
+			users[i] = r.user
+		}
+
+		rowErrs, err := storage.ImportUsers(ctx, users, dryRun)
+		if err != nil {
+			fail(err)
+		}
+		for i, rowErr := range rowErrs {
+			if rowErr != nil {
+				failed++
+				fmt.Fprintf(report, "line %d: %s: %v\n", batch[i].line, batch[i].user.Email, rowErr)
+				continue
+			}
+			imported++
+		}
+	}
+
+	batch := make([]row, 0, batchSize)
+	for {
+		rec, line, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var rowErr *userfile.RowError
+			if !errors.As(err, &rowErr) {
+				fail(err)
+			}
+			failed++
+			fmt.Fprintln(report, rowErr.Error())
+			continue
+		}
+
+		user, err := rec.User()
+		if err != nil {
+			failed++
+			fmt.Fprintf(report, "line %d: %s: %v\n", line, rec.Email, err)
+			continue
+		}
+
+		if seen[user.Email] {
+			failed++
+			fmt.Fprintf(report, "line %d: %s: %v\n", line, rec.Email, servicestorage.ErrorUserExists)
+			continue
+		}
+		seen[user.Email] = true
+
+		batch = append(batch, row{line: line, user: user})
+		if len(batch) == batchSize {
+			flush(batch)
+			batch = batch[:0]
+		}
+	}
+	if len(batch) > 0 {
+		flush(batch)
+	}
+
+	if dryRun {
+		fmt.Printf("dry run: %d users would be imported, %d failed\n", imported, failed)
+	} else {
+		fmt.Printf("%d users imported, %d failed\n", imported, failed)
+	}
+
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func runExport(args []string) {
+	var storagePath, format, filePath string
+	var batchSize int
+
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	fs.StringVar(&storagePath, "storage-path", "", "path to storage")
+	fs.StringVar(&format, "format", userfile.FormatCSV, "output format: csv or jsonl")
+	fs.StringVar(&filePath, "file", "-", "output file, - for stdout")
+	fs.IntVar(&batchSize, "batch-size", 500, "users per query")
+	_ = fs.Parse(args)
+
+	if storagePath == "" {
+		usageError("storage path is required")
+	}
+	if batchSize <= 0 {
+		usageError("batch size must be positive")
+	}
+
+	out := os.Stdout
+	if filePath != "-" {
+		f, err := os.Create(filePath)
+		if err != nil {
+			fail(err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	storage, err := sqlite.New(storagePath)
+	if err != nil {
+		fail(err)
+	}
+
+	writer, err := userfile.NewWriter(out, format)
+	if err != nil {
+		fail(err)
+	}
+
+	ctx := context.Background()
+	var afterID int64
+	var exported int
+	for {
+		users, err := storage.Users(ctx, afterID, batchSize)
+		if err != nil {
+			fail(err)
+		}
+		if len(users) == 0 {
+			break
+		}
+
+		for _, user := range users {
+			if err := writer.Write(userfile.FromUser(user)); err != nil {
+				fail(err)
+			}
+		}
+		exported += len(users)
+		afterID = users[len(users)-1].ID
+	}
+
+	if err := writer.Flush(); err != nil {
+		fail(err)
+	}
+
+	fmt.Fprintf(os.Stderr, "%d users exported\n", exported)
+}
+
+// usageError reports invalid arguments and exits
+func usageError(msg string) {
+	fmt.Fprintln(os.Stderr, msg)
+	fmt.Fprintln(os.Stderr, usage)
+	os.Exit(2)
+}
+
+// fail reports error that stopped the command and exits
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "usertool:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,684 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: authsvc/orgs.proto
+
+package authsvcv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Orgs_CreateOrg_FullMethodName       = "/authsvc.Orgs/CreateOrg"
+	Orgs_GetOrg_FullMethodName          = "/authsvc.Orgs/GetOrg"
+	Orgs_ListOrgs_FullMethodName        = "/authsvc.Orgs/ListOrgs"
+	Orgs_UpdateOrg_FullMethodName       = "/authsvc.Orgs/UpdateOrg"
+	Orgs_DeleteOrg_FullMethodName       = "/authsvc.Orgs/DeleteOrg"
+	Orgs_ListMembers_FullMethodName     = "/authsvc.Orgs/ListMembers"
+	Orgs_AddMember_FullMethodName       = "/authsvc.Orgs/AddMember"
+	Orgs_RemoveMember_FullMethodName    = "/authsvc.Orgs/RemoveMember"
+	Orgs_CreateOrgInvite_FullMethodName = "/authsvc.Orgs/CreateOrgInvite"
+	Orgs_ListOrgInvites_FullMethodName  = "/authsvc.Orgs/ListOrgInvites"
+	Orgs_RevokeOrgInvite_FullMethodName = "/authsvc.Orgs/RevokeOrgInvite"
+	Orgs_AcceptOrgInvite_FullMethodName = "/authsvc.Orgs/AcceptOrgInvite"
+	Orgs_SwitchOrg_FullMethodName       = "/authsvc.Orgs/SwitchOrg"
+	Orgs_CreateSCIMToken_FullMethodName = "/authsvc.Orgs/CreateSCIMToken"
+	Orgs_ListSCIMTokens_FullMethodName  = "/authsvc.Orgs/ListSCIMTokens"
+	Orgs_RevokeSCIMToken_FullMethodName = "/authsvc.Orgs/RevokeSCIMToken"
+)
+
+// OrgsClient is the client API for Orgs service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type OrgsClient interface {
+	CreateOrg(ctx context.Context, in *CreateOrgRequest, opts ...grpc.CallOption) (*CreateOrgResponse, error)
+	GetOrg(ctx context.Context, in *GetOrgRequest, opts ...grpc.CallOption) (*GetOrgResponse, error)
+	// ListOrgs lists orgs of the caller, or all orgs for admins if all is set
+	ListOrgs(ctx context.Context, in *ListOrgsRequest, opts ...grpc.CallOption) (*ListOrgsResponse, error)
+	// UpdateOrg replaces name, allowed apps and login policy of the org
+	UpdateOrg(ctx context.Context, in *UpdateOrgRequest, opts ...grpc.CallOption) (*UpdateOrgResponse, error)
+	// DeleteOrg removes org with its members, invites and SCIM data
+	DeleteOrg(ctx context.Context, in *DeleteOrgRequest, opts ...grpc.CallOption) (*DeleteOrgResponse, error)
+	ListMembers(ctx context.Context, in *ListMembersRequest, opts ...grpc.CallOption) (*ListMembersResponse, error)
+	// AddMember adds user to the org or changes role of a member
+	AddMember(ctx context.Context, in *AddMemberRequest, opts ...grpc.CallOption) (*AddMemberResponse, error)
+	// RemoveMember removes user from the org, the last owner can't be removed
+	RemoveMember(ctx context.Context, in *RemoveMemberRequest, opts ...grpc.CallOption) (*RemoveMemberResponse, error)
+	CreateOrgInvite(ctx context.Context, in *CreateOrgInviteRequest, opts ...grpc.CallOption) (*CreateOrgInviteResponse, error)
+	ListOrgInvites(ctx context.Context, in *ListOrgInvitesRequest, opts ...grpc.CallOption) (*ListOrgInvitesResponse, error)
+	RevokeOrgInvite(ctx context.Context, in *RevokeOrgInviteRequest, opts ...grpc.CallOption) (*RevokeOrgInviteResponse, error)
+	// AcceptOrgInvite adds the caller to the org of the invite
+	AcceptOrgInvite(ctx context.Context, in *AcceptOrgInviteRequest, opts ...grpc.CallOption) (*AcceptOrgInviteResponse, error)
+	// SwitchOrg reissues the caller token scoped to another org, org_id 0
+	// drops the org scope. The new token expires with the old one.
+	SwitchOrg(ctx context.Context, in *SwitchOrgRequest, opts ...grpc.CallOption) (*SwitchOrgResponse, error)
+	// CreateSCIMToken creates a bearer token the identity provider of the org
+	// provisions users and groups with over SCIM 2.0 at /scim/v2
+	CreateSCIMToken(ctx context.Context, in *CreateSCIMTokenRequest, opts ...grpc.CallOption) (*CreateSCIMTokenResponse, error)
+	ListSCIMTokens(ctx context.Context, in *ListSCIMTokensRequest, opts ...grpc.CallOption) (*ListSCIMTokensResponse, error)
+	RevokeSCIMToken(ctx context.Context, in *RevokeSCIMTokenRequest, opts ...grpc.CallOption) (*RevokeSCIMTokenResponse, error)
+}
+
+type orgsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewOrgsClient(cc grpc.ClientConnInterface) OrgsClient {
+	return &orgsClient{cc}
+}
+
+func (c *orgsClient) CreateOrg(ctx context.Context, in *CreateOrgRequest, opts ...grpc.CallOption) (*CreateOrgResponse, error) {
+	out := new(CreateOrgResponse)
+	err := c.cc.Invoke(ctx, Orgs_CreateOrg_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orgsClient) GetOrg(ctx context.Context, in *GetOrgRequest, opts ...grpc.CallOption) (*GetOrgResponse, error) {
+	out := new(GetOrgResponse)
+	err := c.cc.Invoke(ctx, Orgs_GetOrg_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orgsClient) ListOrgs(ctx context.Context, in *ListOrgsRequest, opts ...grpc.CallOption) (*ListOrgsResponse, error) {
+	out := new(ListOrgsResponse)
+	err := c.cc.Invoke(ctx, Orgs_ListOrgs_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orgsClient) UpdateOrg(ctx context.Context, in *UpdateOrgRequest, opts ...grpc.CallOption) (*UpdateOrgResponse, error) {
+	out := new(UpdateOrgResponse)
+	err := c.cc.Invoke(ctx, Orgs_UpdateOrg_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orgsClient) DeleteOrg(ctx context.Context, in *DeleteOrgRequest, opts ...grpc.CallOption) (*DeleteOrgResponse, error) {
+	out := new(DeleteOrgResponse)
+	err := c.cc.Invoke(ctx, Orgs_DeleteOrg_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orgsClient) ListMembers(ctx context.Context, in *ListMembersRequest, opts ...grpc.CallOption) (*ListMembersResponse, error) {
+	out := new(ListMembersResponse)
+	err := c.cc.Invoke(ctx, Orgs_ListMembers_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orgsClient) AddMember(ctx context.Context, in *AddMemberRequest, opts ...grpc.CallOption) (*AddMemberResponse, error) {
+	out := new(AddMemberResponse)
+	err := c.cc.Invoke(ctx, Orgs_AddMember_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orgsClient) RemoveMember(ctx context.Context, in *RemoveMemberRequest, opts ...grpc.CallOption) (*RemoveMemberResponse, error) {
+	out := new(RemoveMemberResponse)
+	err := c.cc.Invoke(ctx, Orgs_RemoveMember_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orgsClient) CreateOrgInvite(ctx context.Context, in *CreateOrgInviteRequest, opts ...grpc.CallOption) (*CreateOrgInviteResponse, error) {
+	out := new(CreateOrgInviteResponse)
+	err := c.cc.Invoke(ctx, Orgs_CreateOrgInvite_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orgsClient) ListOrgInvites(ctx context.Context, in *ListOrgInvitesRequest, opts ...grpc.CallOption) (*ListOrgInvitesResponse, error) {
+	out := new(ListOrgInvitesResponse)
+	err := c.cc.Invoke(ctx, Orgs_ListOrgInvites_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orgsClient) RevokeOrgInvite(ctx context.Context, in *RevokeOrgInviteRequest, opts ...grpc.CallOption) (*RevokeOrgInviteResponse, error) {
+	out := new(RevokeOrgInviteResponse)
+	err := c.cc.Invoke(ctx, Orgs_RevokeOrgInvite_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orgsClient) AcceptOrgInvite(ctx context.Context, in *AcceptOrgInviteRequest, opts ...grpc.CallOption) (*AcceptOrgInviteResponse, error) {
+	out := new(AcceptOrgInviteResponse)
+	err := c.cc.Invoke(ctx, Orgs_AcceptOrgInvite_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orgsClient) SwitchOrg(ctx context.Context, in *SwitchOrgRequest, opts ...grpc.CallOption) (*SwitchOrgResponse, error) {
+	out := new(SwitchOrgResponse)
+	err := c.cc.Invoke(ctx, Orgs_SwitchOrg_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orgsClient) CreateSCIMToken(ctx context.Context, in *CreateSCIMTokenRequest, opts ...grpc.CallOption) (*CreateSCIMTokenResponse, error) {
+	out := new(CreateSCIMTokenResponse)
+	err := c.cc.Invoke(ctx, Orgs_CreateSCIMToken_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orgsClient) ListSCIMTokens(ctx context.Context, in *ListSCIMTokensRequest, opts ...grpc.CallOption) (*ListSCIMTokensResponse, error) {
+	out := new(ListSCIMTokensResponse)
+	err := c.cc.Invoke(ctx, Orgs_ListSCIMTokens_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *orgsClient) RevokeSCIMToken(ctx context.Context, in *RevokeSCIMTokenRequest, opts ...grpc.CallOption) (*RevokeSCIMTokenResponse, error) {
+	out := new(RevokeSCIMTokenResponse)
+	err := c.cc.Invoke(ctx, Orgs_RevokeSCIMToken_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// OrgsServer is the server API for Orgs service.
+// All implementations must embed UnimplementedOrgsServer
+// for forward compatibility
+type OrgsServer interface {
+	CreateOrg(context.Context, *CreateOrgRequest) (*CreateOrgResponse, error)
+	GetOrg(context.Context, *GetOrgRequest) (*GetOrgResponse, error)
+	// ListOrgs lists orgs of the caller, or all orgs for admins if all is set
+	ListOrgs(context.Context, *ListOrgsRequest) (*ListOrgsResponse, error)
+	// UpdateOrg replaces name, allowed apps and login policy of the org
+	UpdateOrg(context.Context, *UpdateOrgRequest) (*UpdateOrgResponse, error)
+	// DeleteOrg removes org with its members, invites and SCIM data
+	DeleteOrg(context.Context, *DeleteOrgRequest) (*DeleteOrgResponse, error)
+	ListMembers(context.Context, *ListMembersRequest) (*ListMembersResponse, error)
+	// AddMember adds user to the org or changes role of a member
+	AddMember(context.Context, *AddMemberRequest) (*AddMemberResponse, error)
+	// RemoveMember removes user from the org, the last owner can't be removed
+	RemoveMember(context.Context, *RemoveMemberRequest) (*RemoveMemberResponse, error)
+	CreateOrgInvite(context.Context, *CreateOrgInviteRequest) (*CreateOrgInviteResponse, error)
+	ListOrgInvites(context.Context, *ListOrgInvitesRequest) (*ListOrgInvitesResponse, error)
+	RevokeOrgInvite(context.Context, *RevokeOrgInviteRequest) (*RevokeOrgInviteResponse, error)
+	// AcceptOrgInvite adds the caller to the org of the invite
+	AcceptOrgInvite(context.Context, *AcceptOrgInviteRequest) (*AcceptOrgInviteResponse, error)
+	// SwitchOrg reissues the caller token scoped to another org, org_id 0
+	// drops the org scope. The new token expires with the old one.
+	SwitchOrg(context.Context, *SwitchOrgRequest) (*SwitchOrgResponse, error)
+	// CreateSCIMToken creates a bearer token the identity provider of the org
+	// provisions users and groups with over SCIM 2.0 at /scim/v2
+	CreateSCIMToken(context.Context, *CreateSCIMTokenRequest) (*CreateSCIMTokenResponse, error)
+	ListSCIMTokens(context.Context, *ListSCIMTokensRequest) (*ListSCIMTokensResponse, error)
+	RevokeSCIMToken(context.Context, *RevokeSCIMTokenRequest) (*RevokeSCIMTokenResponse, error)
+	mustEmbedUnimplementedOrgsServer()
+}
+
+// UnimplementedOrgsServer must be embedded to have forward compatible implementations.
+type UnimplementedOrgsServer struct {
+}
+
+func (UnimplementedOrgsServer) CreateOrg(context.Context, *CreateOrgRequest) (*CreateOrgResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateOrg not implemented")
+}
+func (UnimplementedOrgsServer) GetOrg(context.Context, *GetOrgRequest) (*GetOrgResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetOrg not implemented")
+}
+func (UnimplementedOrgsServer) ListOrgs(context.Context, *ListOrgsRequest) (*ListOrgsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListOrgs not implemented")
+}
+func (UnimplementedOrgsServer) UpdateOrg(context.Context, *UpdateOrgRequest) (*UpdateOrgResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateOrg not implemented")
+}
+func (UnimplementedOrgsServer) DeleteOrg(context.Context, *DeleteOrgRequest) (*DeleteOrgResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteOrg not implemented")
+}
+func (UnimplementedOrgsServer) ListMembers(context.Context, *ListMembersRequest) (*ListMembersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListMembers not implemented")
+}
+func (UnimplementedOrgsServer) AddMember(context.Context, *AddMemberRequest) (*AddMemberResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddMember not implemented")
+}
+func (UnimplementedOrgsServer) RemoveMember(context.Context, *RemoveMemberRequest) (*RemoveMemberResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveMember not implemented")
+}
+func (UnimplementedOrgsServer) CreateOrgInvite(context.Context, *CreateOrgInviteRequest) (*CreateOrgInviteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateOrgInvite not implemented")
+}
+func (UnimplementedOrgsServer) ListOrgInvites(context.Context, *ListOrgInvitesRequest) (*ListOrgInvitesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListOrgInvites not implemented")
+}
+func (UnimplementedOrgsServer) RevokeOrgInvite(context.Context, *RevokeOrgInviteRequest) (*RevokeOrgInviteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeOrgInvite not implemented")
+}
+func (UnimplementedOrgsServer) AcceptOrgInvite(context.Context, *AcceptOrgInviteRequest) (*AcceptOrgInviteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AcceptOrgInvite not implemented")
+}
+func (UnimplementedOrgsServer) SwitchOrg(context.Context, *SwitchOrgRequest) (*SwitchOrgResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SwitchOrg not implemented")
+}
+func (UnimplementedOrgsServer) CreateSCIMToken(context.Context, *CreateSCIMTokenRequest) (*CreateSCIMTokenResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateSCIMToken not implemented")
+}
+func (UnimplementedOrgsServer) ListSCIMTokens(context.Context, *ListSCIMTokensRequest) (*ListSCIMTokensResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSCIMTokens not implemented")
+}
+func (UnimplementedOrgsServer) RevokeSCIMToken(context.Context, *RevokeSCIMTokenRequest) (*RevokeSCIMTokenResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeSCIMToken not implemented")
+}
+func (UnimplementedOrgsServer) mustEmbedUnimplementedOrgsServer() {}
+
+// UnsafeOrgsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to OrgsServer will
+// result in compilation errors.
+type UnsafeOrgsServer interface {
+	mustEmbedUnimplementedOrgsServer()
+}
+
+func RegisterOrgsServer(s grpc.ServiceRegistrar, srv OrgsServer) {
+	s.RegisterService(&Orgs_ServiceDesc, srv)
+}
+
+func _Orgs_CreateOrg_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateOrgRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrgsServer).CreateOrg(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Orgs_CreateOrg_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrgsServer).CreateOrg(ctx, req.(*CreateOrgRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Orgs_GetOrg_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetOrgRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrgsServer).GetOrg(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Orgs_GetOrg_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrgsServer).GetOrg(ctx, req.(*GetOrgRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Orgs_ListOrgs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListOrgsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrgsServer).ListOrgs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Orgs_ListOrgs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrgsServer).ListOrgs(ctx, req.(*ListOrgsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Orgs_UpdateOrg_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateOrgRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrgsServer).UpdateOrg(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Orgs_UpdateOrg_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrgsServer).UpdateOrg(ctx, req.(*UpdateOrgRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Orgs_DeleteOrg_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteOrgRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrgsServer).DeleteOrg(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Orgs_DeleteOrg_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrgsServer).DeleteOrg(ctx, req.(*DeleteOrgRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Orgs_ListMembers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListMembersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrgsServer).ListMembers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Orgs_ListMembers_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrgsServer).ListMembers(ctx, req.(*ListMembersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Orgs_AddMember_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddMemberRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrgsServer).AddMember(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Orgs_AddMember_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrgsServer).AddMember(ctx, req.(*AddMemberRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Orgs_RemoveMember_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveMemberRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrgsServer).RemoveMember(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Orgs_RemoveMember_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrgsServer).RemoveMember(ctx, req.(*RemoveMemberRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Orgs_CreateOrgInvite_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateOrgInviteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrgsServer).CreateOrgInvite(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Orgs_CreateOrgInvite_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrgsServer).CreateOrgInvite(ctx, req.(*CreateOrgInviteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Orgs_ListOrgInvites_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListOrgInvitesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrgsServer).ListOrgInvites(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Orgs_ListOrgInvites_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrgsServer).ListOrgInvites(ctx, req.(*ListOrgInvitesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Orgs_RevokeOrgInvite_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeOrgInviteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrgsServer).RevokeOrgInvite(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Orgs_RevokeOrgInvite_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrgsServer).RevokeOrgInvite(ctx, req.(*RevokeOrgInviteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Orgs_AcceptOrgInvite_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AcceptOrgInviteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrgsServer).AcceptOrgInvite(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Orgs_AcceptOrgInvite_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrgsServer).AcceptOrgInvite(ctx, req.(*AcceptOrgInviteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Orgs_SwitchOrg_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SwitchOrgRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrgsServer).SwitchOrg(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Orgs_SwitchOrg_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrgsServer).SwitchOrg(ctx, req.(*SwitchOrgRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Orgs_CreateSCIMToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateSCIMTokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrgsServer).CreateSCIMToken(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Orgs_CreateSCIMToken_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrgsServer).CreateSCIMToken(ctx, req.(*CreateSCIMTokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Orgs_ListSCIMTokens_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSCIMTokensRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrgsServer).ListSCIMTokens(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Orgs_ListSCIMTokens_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrgsServer).ListSCIMTokens(ctx, req.(*ListSCIMTokensRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Orgs_RevokeSCIMToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeSCIMTokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OrgsServer).RevokeSCIMToken(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Orgs_RevokeSCIMToken_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OrgsServer).RevokeSCIMToken(ctx, req.(*RevokeSCIMTokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Orgs_ServiceDesc is the grpc.ServiceDesc for Orgs service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Orgs_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "authsvc.Orgs",
+	HandlerType: (*OrgsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateOrg",
+			Handler:    _Orgs_CreateOrg_Handler,
+		},
+		{
+			MethodName: "GetOrg",
+			Handler:    _Orgs_GetOrg_Handler,
+		},
+		{
+			MethodName: "ListOrgs",
+			Handler:    _Orgs_ListOrgs_Handler,
+		},
+		{
+			MethodName: "UpdateOrg",
+			Handler:    _Orgs_UpdateOrg_Handler,
+		},
+		{
+			MethodName: "DeleteOrg",
+			Handler:    _Orgs_DeleteOrg_Handler,
+		},
+		{
+			MethodName: "ListMembers",
+			Handler:    _Orgs_ListMembers_Handler,
+		},
+		{
+			MethodName: "AddMember",
+			Handler:    _Orgs_AddMember_Handler,
+		},
+		{
+			MethodName: "RemoveMember",
+			Handler:    _Orgs_RemoveMember_Handler,
+		},
+		{
+			MethodName: "CreateOrgInvite",
+			Handler:    _Orgs_CreateOrgInvite_Handler,
+		},
+		{
+			MethodName: "ListOrgInvites",
+			Handler:    _Orgs_ListOrgInvites_Handler,
+		},
+		{
+			MethodName: "RevokeOrgInvite",
+			Handler:    _Orgs_RevokeOrgInvite_Handler,
+		},
+		{
+			MethodName: "AcceptOrgInvite",
+			Handler:    _Orgs_AcceptOrgInvite_Handler,
+		},
+		{
+			MethodName: "SwitchOrg",
+			Handler:    _Orgs_SwitchOrg_Handler,
+		},
+		{
+			MethodName: "CreateSCIMToken",
+			Handler:    _Orgs_CreateSCIMToken_Handler,
+		},
+		{
+			MethodName: "ListSCIMTokens",
+			Handler:    _Orgs_ListSCIMTokens_Handler,
+		},
+		{
+			MethodName: "RevokeSCIMToken",
+			Handler:    _Orgs_RevokeSCIMToken_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "authsvc/orgs.proto",
+}
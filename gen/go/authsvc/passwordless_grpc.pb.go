@@ -0,0 +1,152 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: authsvc/passwordless.proto
+
+package authsvcv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Passwordless_RequestLoginCode_FullMethodName = "/authsvc.Passwordless/RequestLoginCode"
+	Passwordless_RedeemLoginCode_FullMethodName  = "/authsvc.Passwordless/RedeemLoginCode"
+)
+
+// PasswordlessClient is the client API for Passwordless service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type PasswordlessClient interface {
+	// RequestLoginCode succeeds for unknown emails too, not to reveal registered users
+	RequestLoginCode(ctx context.Context, in *RequestLoginCodeRequest, opts ...grpc.CallOption) (*RequestLoginCodeResponse, error)
+	// RedeemLoginCode returns the same token as Login, and fails like Login with
+	// FAILED_PRECONDITION and MFA_REQUIRED error info if user has MFA enabled
+	RedeemLoginCode(ctx context.Context, in *RedeemLoginCodeRequest, opts ...grpc.CallOption) (*RedeemLoginCodeResponse, error)
+}
+
+type passwordlessClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPasswordlessClient(cc grpc.ClientConnInterface) PasswordlessClient {
+	return &passwordlessClient{cc}
+}
+
+func (c *passwordlessClient) RequestLoginCode(ctx context.Context, in *RequestLoginCodeRequest, opts ...grpc.CallOption) (*RequestLoginCodeResponse, error) {
+	out := new(RequestLoginCodeResponse)
+	err := c.cc.Invoke(ctx, Passwordless_RequestLoginCode_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *passwordlessClient) RedeemLoginCode(ctx context.Context, in *RedeemLoginCodeRequest, opts ...grpc.CallOption) (*RedeemLoginCodeResponse, error) {
+	out := new(RedeemLoginCodeResponse)
+	err := c.cc.Invoke(ctx, Passwordless_RedeemLoginCode_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PasswordlessServer is the server API for Passwordless service.
+// All implementations must embed UnimplementedPasswordlessServer
+// for forward compatibility
+type PasswordlessServer interface {
+	// RequestLoginCode succeeds for unknown emails too, not to reveal registered users
+	RequestLoginCode(context.Context, *RequestLoginCodeRequest) (*RequestLoginCodeResponse, error)
+	// RedeemLoginCode returns the same token as Login, and fails like Login with
+	// FAILED_PRECONDITION and MFA_REQUIRED error info if user has MFA enabled
+	RedeemLoginCode(context.Context, *RedeemLoginCodeRequest) (*RedeemLoginCodeResponse, error)
+	mustEmbedUnimplementedPasswordlessServer()
+}
+
+// UnimplementedPasswordlessServer must be embedded to have forward compatible implementations.
+type UnimplementedPasswordlessServer struct {
+}
+
+func (UnimplementedPasswordlessServer) RequestLoginCode(context.Context, *RequestLoginCodeRequest) (*RequestLoginCodeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RequestLoginCode not implemented")
+}
+func (UnimplementedPasswordlessServer) RedeemLoginCode(context.Context, *RedeemLoginCodeRequest) (*RedeemLoginCodeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RedeemLoginCode not implemented")
+}
+func (UnimplementedPasswordlessServer) mustEmbedUnimplementedPasswordlessServer() {}
+
+// UnsafePasswordlessServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PasswordlessServer will
+// result in compilation errors.
+type UnsafePasswordlessServer interface {
+	mustEmbedUnimplementedPasswordlessServer()
+}
+
+func RegisterPasswordlessServer(s grpc.ServiceRegistrar, srv PasswordlessServer) {
+	s.RegisterService(&Passwordless_ServiceDesc, srv)
+}
+
+func _Passwordless_RequestLoginCode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestLoginCodeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PasswordlessServer).RequestLoginCode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Passwordless_RequestLoginCode_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PasswordlessServer).RequestLoginCode(ctx, req.(*RequestLoginCodeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Passwordless_RedeemLoginCode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RedeemLoginCodeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PasswordlessServer).RedeemLoginCode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Passwordless_RedeemLoginCode_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PasswordlessServer).RedeemLoginCode(ctx, req.(*RedeemLoginCodeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Passwordless_ServiceDesc is the grpc.ServiceDesc for Passwordless service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Passwordless_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "authsvc.Passwordless",
+	HandlerType: (*PasswordlessServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "RequestLoginCode",
+			Handler:    _Passwordless_RequestLoginCode_Handler,
+		},
+		{
+			MethodName: "RedeemLoginCode",
+			Handler:    _Passwordless_RedeemLoginCode_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "authsvc/passwordless.proto",
+}
@@ -0,0 +1,345 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: authsvc/relations.proto
+
+package authsvcv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Relations_WriteSchema_FullMethodName  = "/authsvc.Relations/WriteSchema"
+	Relations_ReadSchema_FullMethodName   = "/authsvc.Relations/ReadSchema"
+	Relations_WriteTuples_FullMethodName  = "/authsvc.Relations/WriteTuples"
+	Relations_DeleteTuples_FullMethodName = "/authsvc.Relations/DeleteTuples"
+	Relations_ReadTuples_FullMethodName   = "/authsvc.Relations/ReadTuples"
+	Relations_Check_FullMethodName        = "/authsvc.Relations/Check"
+	Relations_Expand_FullMethodName       = "/authsvc.Relations/Expand"
+)
+
+// RelationsClient is the client API for Relations service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type RelationsClient interface {
+	// WriteSchema creates or replaces namespaces
+	WriteSchema(ctx context.Context, in *WriteSchemaRequest, opts ...grpc.CallOption) (*WriteSchemaResponse, error)
+	ReadSchema(ctx context.Context, in *ReadSchemaRequest, opts ...grpc.CallOption) (*ReadSchemaResponse, error)
+	// WriteTuples stores tuples, writing existing tuples is not an error
+	WriteTuples(ctx context.Context, in *WriteTuplesRequest, opts ...grpc.CallOption) (*WriteTuplesResponse, error)
+	// DeleteTuples removes tuples, deleting missing tuples is not an error
+	DeleteTuples(ctx context.Context, in *DeleteTuplesRequest, opts ...grpc.CallOption) (*DeleteTuplesResponse, error)
+	// ReadTuples lists stored tuples of a namespace, without rewrites
+	ReadTuples(ctx context.Context, in *ReadTuplesRequest, opts ...grpc.CallOption) (*ReadTuplesResponse, error)
+	// Check reports whether user has relation to the object
+	Check(ctx context.Context, in *CheckRequest, opts ...grpc.CallOption) (*CheckResponse, error)
+	// Expand returns the tree of users and usersets of the relation,
+	// it's meant for debugging the schema
+	Expand(ctx context.Context, in *ExpandRequest, opts ...grpc.CallOption) (*ExpandResponse, error)
+}
+
+type relationsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRelationsClient(cc grpc.ClientConnInterface) RelationsClient {
+	return &relationsClient{cc}
+}
+
+func (c *relationsClient) WriteSchema(ctx context.Context, in *WriteSchemaRequest, opts ...grpc.CallOption) (*WriteSchemaResponse, error) {
+	out := new(WriteSchemaResponse)
+	err := c.cc.Invoke(ctx, Relations_WriteSchema_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *relationsClient) ReadSchema(ctx context.Context, in *ReadSchemaRequest, opts ...grpc.CallOption) (*ReadSchemaResponse, error) {
+	out := new(ReadSchemaResponse)
+	err := c.cc.Invoke(ctx, Relations_ReadSchema_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *relationsClient) WriteTuples(ctx context.Context, in *WriteTuplesRequest, opts ...grpc.CallOption) (*WriteTuplesResponse, error) {
+	out := new(WriteTuplesResponse)
+	err := c.cc.Invoke(ctx, Relations_WriteTuples_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *relationsClient) DeleteTuples(ctx context.Context, in *DeleteTuplesRequest, opts ...grpc.CallOption) (*DeleteTuplesResponse, error) {
+	out := new(DeleteTuplesResponse)
+	err := c.cc.Invoke(ctx, Relations_DeleteTuples_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *relationsClient) ReadTuples(ctx context.Context, in *ReadTuplesRequest, opts ...grpc.CallOption) (*ReadTuplesResponse, error) {
+	out := new(ReadTuplesResponse)
+	err := c.cc.Invoke(ctx, Relations_ReadTuples_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *relationsClient) Check(ctx context.Context, in *CheckRequest, opts ...grpc.CallOption) (*CheckResponse, error) {
+	out := new(CheckResponse)
+	err := c.cc.Invoke(ctx, Relations_Check_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *relationsClient) Expand(ctx context.Context, in *ExpandRequest, opts ...grpc.CallOption) (*ExpandResponse, error) {
+	out := new(ExpandResponse)
+	err := c.cc.Invoke(ctx, Relations_Expand_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RelationsServer is the server API for Relations service.
+// All implementations must embed UnimplementedRelationsServer
+// for forward compatibility
+type RelationsServer interface {
+	// WriteSchema creates or replaces namespaces
+	WriteSchema(context.Context, *WriteSchemaRequest) (*WriteSchemaResponse, error)
+	ReadSchema(context.Context, *ReadSchemaRequest) (*ReadSchemaResponse, error)
+	// WriteTuples stores tuples, writing existing tuples is not an error
+	WriteTuples(context.Context, *WriteTuplesRequest) (*WriteTuplesResponse, error)
+	// DeleteTuples removes tuples, deleting missing tuples is not an error
+	DeleteTuples(context.Context, *DeleteTuplesRequest) (*DeleteTuplesResponse, error)
+	// ReadTuples lists stored tuples of a namespace, without rewrites
+	ReadTuples(context.Context, *ReadTuplesRequest) (*ReadTuplesResponse, error)
+	// Check reports whether user has relation to the object
+	Check(context.Context, *CheckRequest) (*CheckResponse, error)
+	// Expand returns the tree of users and usersets of the relation,
+	// it's meant for debugging the schema
+	Expand(context.Context, *ExpandRequest) (*ExpandResponse, error)
+	mustEmbedUnimplementedRelationsServer()
+}
+
+// UnimplementedRelationsServer must be embedded to have forward compatible implementations.
+type UnimplementedRelationsServer struct {
+}
+
+func (UnimplementedRelationsServer) WriteSchema(context.Context, *WriteSchemaRequest) (*WriteSchemaResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method WriteSchema not implemented")
+}
+func (UnimplementedRelationsServer) ReadSchema(context.Context, *ReadSchemaRequest) (*ReadSchemaResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReadSchema not implemented")
+}
+func (UnimplementedRelationsServer) WriteTuples(context.Context, *WriteTuplesRequest) (*WriteTuplesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method WriteTuples not implemented")
+}
+func (UnimplementedRelationsServer) DeleteTuples(context.Context, *DeleteTuplesRequest) (*DeleteTuplesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteTuples not implemented")
+}
+func (UnimplementedRelationsServer) ReadTuples(context.Context, *ReadTuplesRequest) (*ReadTuplesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReadTuples not implemented")
+}
+func (UnimplementedRelationsServer) Check(context.Context, *CheckRequest) (*CheckResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Check not implemented")
+}
+func (UnimplementedRelationsServer) Expand(context.Context, *ExpandRequest) (*ExpandResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Expand not implemented")
+}
+func (UnimplementedRelationsServer) mustEmbedUnimplementedRelationsServer() {}
+
+// UnsafeRelationsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RelationsServer will
+// result in compilation errors.
+type UnsafeRelationsServer interface {
+	mustEmbedUnimplementedRelationsServer()
+}
+
+func RegisterRelationsServer(s grpc.ServiceRegistrar, srv RelationsServer) {
+	s.RegisterService(&Relations_ServiceDesc, srv)
+}
+
+func _Relations_WriteSchema_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WriteSchemaRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RelationsServer).WriteSchema(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Relations_WriteSchema_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RelationsServer).WriteSchema(ctx, req.(*WriteSchemaRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Relations_ReadSchema_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReadSchemaRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RelationsServer).ReadSchema(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Relations_ReadSchema_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RelationsServer).ReadSchema(ctx, req.(*ReadSchemaRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Relations_WriteTuples_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WriteTuplesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RelationsServer).WriteTuples(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Relations_WriteTuples_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RelationsServer).WriteTuples(ctx, req.(*WriteTuplesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Relations_DeleteTuples_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteTuplesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RelationsServer).DeleteTuples(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Relations_DeleteTuples_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RelationsServer).DeleteTuples(ctx, req.(*DeleteTuplesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Relations_ReadTuples_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReadTuplesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RelationsServer).ReadTuples(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Relations_ReadTuples_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RelationsServer).ReadTuples(ctx, req.(*ReadTuplesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Relations_Check_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CheckRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RelationsServer).Check(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Relations_Check_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RelationsServer).Check(ctx, req.(*CheckRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Relations_Expand_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExpandRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RelationsServer).Expand(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Relations_Expand_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RelationsServer).Expand(ctx, req.(*ExpandRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Relations_ServiceDesc is the grpc.ServiceDesc for Relations service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Relations_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "authsvc.Relations",
+	HandlerType: (*RelationsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "WriteSchema",
+			Handler:    _Relations_WriteSchema_Handler,
+		},
+		{
+			MethodName: "ReadSchema",
+			Handler:    _Relations_ReadSchema_Handler,
+		},
+		{
+			MethodName: "WriteTuples",
+			Handler:    _Relations_WriteTuples_Handler,
+		},
+		{
+			MethodName: "DeleteTuples",
+			Handler:    _Relations_DeleteTuples_Handler,
+		},
+		{
+			MethodName: "ReadTuples",
+			Handler:    _Relations_ReadTuples_Handler,
+		},
+		{
+			MethodName: "Check",
+			Handler:    _Relations_Check_Handler,
+		},
+		{
+			MethodName: "Expand",
+			Handler:    _Relations_Expand_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "authsvc/relations.proto",
+}
@@ -0,0 +1,298 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: authsvc/webauthn.proto
+
+package authsvcv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	WebAuthn_BeginRegistration_FullMethodName  = "/authsvc.WebAuthn/BeginRegistration"
+	WebAuthn_FinishRegistration_FullMethodName = "/authsvc.WebAuthn/FinishRegistration"
+	WebAuthn_ListCredentials_FullMethodName    = "/authsvc.WebAuthn/ListCredentials"
+	WebAuthn_DeleteCredential_FullMethodName   = "/authsvc.WebAuthn/DeleteCredential"
+	WebAuthn_BeginLogin_FullMethodName         = "/authsvc.WebAuthn/BeginLogin"
+	WebAuthn_FinishLogin_FullMethodName        = "/authsvc.WebAuthn/FinishLogin"
+)
+
+// WebAuthnClient is the client API for WebAuthn service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type WebAuthnClient interface {
+	BeginRegistration(ctx context.Context, in *BeginRegistrationRequest, opts ...grpc.CallOption) (*BeginRegistrationResponse, error)
+	FinishRegistration(ctx context.Context, in *FinishRegistrationRequest, opts ...grpc.CallOption) (*FinishRegistrationResponse, error)
+	ListCredentials(ctx context.Context, in *ListCredentialsRequest, opts ...grpc.CallOption) (*ListCredentialsResponse, error)
+	DeleteCredential(ctx context.Context, in *DeleteCredentialRequest, opts ...grpc.CallOption) (*DeleteCredentialResponse, error)
+	// BeginLogin starts passwordless login to the app, or completes Login that
+	// failed with MFA_REQUIRED error info when mfa_challenge is set
+	BeginLogin(ctx context.Context, in *BeginLoginRequest, opts ...grpc.CallOption) (*BeginLoginResponse, error)
+	FinishLogin(ctx context.Context, in *FinishLoginRequest, opts ...grpc.CallOption) (*FinishLoginResponse, error)
+}
+
+type webAuthnClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewWebAuthnClient(cc grpc.ClientConnInterface) WebAuthnClient {
+	return &webAuthnClient{cc}
+}
+
+func (c *webAuthnClient) BeginRegistration(ctx context.Context, in *BeginRegistrationRequest, opts ...grpc.CallOption) (*BeginRegistrationResponse, error) {
+	out := new(BeginRegistrationResponse)
+	err := c.cc.Invoke(ctx, WebAuthn_BeginRegistration_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *webAuthnClient) FinishRegistration(ctx context.Context, in *FinishRegistrationRequest, opts ...grpc.CallOption) (*FinishRegistrationResponse, error) {
+	out := new(FinishRegistrationResponse)
+	err := c.cc.Invoke(ctx, WebAuthn_FinishRegistration_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *webAuthnClient) ListCredentials(ctx context.Context, in *ListCredentialsRequest, opts ...grpc.CallOption) (*ListCredentialsResponse, error) {
+	out := new(ListCredentialsResponse)
+	err := c.cc.Invoke(ctx, WebAuthn_ListCredentials_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *webAuthnClient) DeleteCredential(ctx context.Context, in *DeleteCredentialRequest, opts ...grpc.CallOption) (*DeleteCredentialResponse, error) {
+	out := new(DeleteCredentialResponse)
+	err := c.cc.Invoke(ctx, WebAuthn_DeleteCredential_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *webAuthnClient) BeginLogin(ctx context.Context, in *BeginLoginRequest, opts ...grpc.CallOption) (*BeginLoginResponse, error) {
+	out := new(BeginLoginResponse)
+	err := c.cc.Invoke(ctx, WebAuthn_BeginLogin_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *webAuthnClient) FinishLogin(ctx context.Context, in *FinishLoginRequest, opts ...grpc.CallOption) (*FinishLoginResponse, error) {
+	out := new(FinishLoginResponse)
+	err := c.cc.Invoke(ctx, WebAuthn_FinishLogin_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// WebAuthnServer is the server API for WebAuthn service.
+// All implementations must embed UnimplementedWebAuthnServer
+// for forward compatibility
+type WebAuthnServer interface {
+	BeginRegistration(context.Context, *BeginRegistrationRequest) (*BeginRegistrationResponse, error)
+	FinishRegistration(context.Context, *FinishRegistrationRequest) (*FinishRegistrationResponse, error)
+	ListCredentials(context.Context, *ListCredentialsRequest) (*ListCredentialsResponse, error)
+	DeleteCredential(context.Context, *DeleteCredentialRequest) (*DeleteCredentialResponse, error)
+	// BeginLogin starts passwordless login to the app, or completes Login that
+	// failed with MFA_REQUIRED error info when mfa_challenge is set
+	BeginLogin(context.Context, *BeginLoginRequest) (*BeginLoginResponse, error)
+	FinishLogin(context.Context, *FinishLoginRequest) (*FinishLoginResponse, error)
+	mustEmbedUnimplementedWebAuthnServer()
+}
+
+// UnimplementedWebAuthnServer must be embedded to have forward compatible implementations.
+type UnimplementedWebAuthnServer struct {
+}
+
+func (UnimplementedWebAuthnServer) BeginRegistration(context.Context, *BeginRegistrationRequest) (*BeginRegistrationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BeginRegistration not implemented")
+}
+func (UnimplementedWebAuthnServer) FinishRegistration(context.Context, *FinishRegistrationRequest) (*FinishRegistrationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FinishRegistration not implemented")
+}
+func (UnimplementedWebAuthnServer) ListCredentials(context.Context, *ListCredentialsRequest) (*ListCredentialsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListCredentials not implemented")
+}
+func (UnimplementedWebAuthnServer) DeleteCredential(context.Context, *DeleteCredentialRequest) (*DeleteCredentialResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteCredential not implemented")
+}
+func (UnimplementedWebAuthnServer) BeginLogin(context.Context, *BeginLoginRequest) (*BeginLoginResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BeginLogin not implemented")
+}
+func (UnimplementedWebAuthnServer) FinishLogin(context.Context, *FinishLoginRequest) (*FinishLoginResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FinishLogin not implemented")
+}
+func (UnimplementedWebAuthnServer) mustEmbedUnimplementedWebAuthnServer() {}
+
+// UnsafeWebAuthnServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to WebAuthnServer will
+// result in compilation errors.
+type UnsafeWebAuthnServer interface {
+	mustEmbedUnimplementedWebAuthnServer()
+}
+
+func RegisterWebAuthnServer(s grpc.ServiceRegistrar, srv WebAuthnServer) {
+	s.RegisterService(&WebAuthn_ServiceDesc, srv)
+}
+
+func _WebAuthn_BeginRegistration_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BeginRegistrationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WebAuthnServer).BeginRegistration(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WebAuthn_BeginRegistration_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WebAuthnServer).BeginRegistration(ctx, req.(*BeginRegistrationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WebAuthn_FinishRegistration_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FinishRegistrationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WebAuthnServer).FinishRegistration(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WebAuthn_FinishRegistration_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WebAuthnServer).FinishRegistration(ctx, req.(*FinishRegistrationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WebAuthn_ListCredentials_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListCredentialsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WebAuthnServer).ListCredentials(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WebAuthn_ListCredentials_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WebAuthnServer).ListCredentials(ctx, req.(*ListCredentialsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WebAuthn_DeleteCredential_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteCredentialRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WebAuthnServer).DeleteCredential(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WebAuthn_DeleteCredential_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WebAuthnServer).DeleteCredential(ctx, req.(*DeleteCredentialRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WebAuthn_BeginLogin_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BeginLoginRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WebAuthnServer).BeginLogin(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WebAuthn_BeginLogin_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WebAuthnServer).BeginLogin(ctx, req.(*BeginLoginRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WebAuthn_FinishLogin_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FinishLoginRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WebAuthnServer).FinishLogin(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WebAuthn_FinishLogin_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WebAuthnServer).FinishLogin(ctx, req.(*FinishLoginRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// WebAuthn_ServiceDesc is the grpc.ServiceDesc for WebAuthn service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var WebAuthn_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "authsvc.WebAuthn",
+	HandlerType: (*WebAuthnServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "BeginRegistration",
+			Handler:    _WebAuthn_BeginRegistration_Handler,
+		},
+		{
+			MethodName: "FinishRegistration",
+			Handler:    _WebAuthn_FinishRegistration_Handler,
+		},
+		{
+			MethodName: "ListCredentials",
+			Handler:    _WebAuthn_ListCredentials_Handler,
+		},
+		{
+			MethodName: "DeleteCredential",
+			Handler:    _WebAuthn_DeleteCredential_Handler,
+		},
+		{
+			MethodName: "BeginLogin",
+			Handler:    _WebAuthn_BeginLogin_Handler,
+		},
+		{
+			MethodName: "FinishLogin",
+			Handler:    _WebAuthn_FinishLogin_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "authsvc/webauthn.proto",
+}
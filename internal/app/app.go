@@ -1,33 +1,245 @@
 package app
 
 import (
+	"crypto/rand"
+	"encoding/base64"
 	"log/slog"
-	"time"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
 
+	aaav1 "github.com/Len4i/aaa/gen/go/aaa"
 	grpcApp "github.com/Len4i/auth-service/internal/app/grpc"
+	httpApp "github.com/Len4i/auth-service/internal/app/http"
+	"github.com/Len4i/auth-service/internal/config"
+	"github.com/Len4i/auth-service/internal/grpc/extauthz"
+	oauthHTTP "github.com/Len4i/auth-service/internal/http/oauth"
+	sessionHTTP "github.com/Len4i/auth-service/internal/http/session"
+	"github.com/Len4i/auth-service/internal/lib/cors"
+	"github.com/Len4i/auth-service/internal/lib/jwt"
+	"github.com/Len4i/auth-service/internal/lib/linktoken"
+	"github.com/Len4i/auth-service/internal/lib/mailer"
+	"github.com/Len4i/auth-service/internal/lib/password"
+	"github.com/Len4i/auth-service/internal/lib/seal"
+	"github.com/Len4i/auth-service/internal/services/apikeys"
 	"github.com/Len4i/auth-service/internal/services/auth"
+	"github.com/Len4i/auth-service/internal/services/clients"
+	"github.com/Len4i/auth-service/internal/services/federation"
+	"github.com/Len4i/auth-service/internal/services/invites"
+	"github.com/Len4i/auth-service/internal/services/mfa"
+	"github.com/Len4i/auth-service/internal/services/oauth"
+	"github.com/Len4i/auth-service/internal/services/orgs"
+	"github.com/Len4i/auth-service/internal/services/passkeys"
+	"github.com/Len4i/auth-service/internal/services/policies"
+	"github.com/Len4i/auth-service/internal/services/provisioning"
+	"github.com/Len4i/auth-service/internal/services/rbac"
+	"github.com/Len4i/auth-service/internal/services/relations"
+	"github.com/Len4i/auth-service/internal/services/sessions"
 	"github.com/Len4i/auth-service/internal/storage/sqlite"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
+// sameSiteModes are values of the session same_site setting
+var sameSiteModes = map[string]http.SameSite{
+	"lax":    http.SameSiteLaxMode,
+	"strict": http.SameSiteStrictMode,
+	"none":   http.SameSiteNoneMode,
+}
+
 type App struct {
 	GRPCApp *grpcApp.App
+	// HTTPApp is nil if HTTP server is disabled
+	HTTPApp *httpApp.App
+	// GatewayApp is nil if JSON API is disabled
+	GatewayApp *httpApp.App
 }
 
-func NewApp(
-	log *slog.Logger,
-	storagePath string,
-	tokenTTL time.Duration,
-	port int,
-) *App {
-	storage, err := sqlite.New(storagePath)
+func NewApp(log *slog.Logger, cfg *config.Config) *App {
+	storage, err := sqlite.New(cfg.StoragePath)
 	if err != nil {
 		log.Error("failed to init storage", "error", err)
 		return nil
 	}
 
-	authSvc := auth.NewAuth(log, storage, storage, storage, tokenTTL)
-	grpcApp := grpcApp.NewApp(log, port, authSvc)
+	passHasher, err := password.NewHasher(cfg.Pepper.CurrentKeyID, cfg.Pepper.Keys)
+	if err != nil {
+		log.Error("failed to init password hasher", "error", err)
+		return nil
+	}
+
+	tokenKey := make([]byte, 32)
+	if cfg.TokenKey != "" {
+		tokenKey, err = base64.StdEncoding.DecodeString(cfg.TokenKey)
+		if err != nil || len(tokenKey) < 32 {
+			log.Error("token key must be a base64 encoded key of at least 32 bytes")
+			return nil
+		}
+	} else {
+		log.Warn("token key is not set, issued tokens are valid until restart")
+		if _, err := rand.Read(tokenKey); err != nil {
+			log.Error("failed to generate token key", "error", err)
+			return nil
+		}
+	}
+
+	var mfaSealer *seal.Sealer
+	if cfg.MFA.EncryptionKey != "" {
+		mfaSealer, err = seal.NewFromBase64(cfg.MFA.EncryptionKey)
+		if err != nil {
+			log.Error("failed to init mfa encryption", "error", err)
+			return nil
+		}
+	}
+
+	var webAuthn *webauthn.WebAuthn
+	if cfg.WebAuthn.RPID != "" {
+		webAuthn, err = webauthn.New(&webauthn.Config{
+			RPID:          cfg.WebAuthn.RPID,
+			RPDisplayName: cfg.WebAuthn.RPDisplayName,
+			RPOrigins:     cfg.WebAuthn.RPOrigins,
+		})
+		if err != nil {
+			log.Error("failed to init webauthn", "error", err)
+			return nil
+		}
+	}
+
+	registration := auth.RegistrationPolicy{
+		Mode:           cfg.Registration.Mode,
+		AllowedDomains: cfg.Registration.AllowedDomains,
+		DeniedDomains:  cfg.Registration.DeniedDomains,
+	}
+
+	mfaSvc := mfa.NewMFA(log, storage, storage, storage, storage, mfaSealer, cfg.MFA.Issuer)
+	var loginMailer auth.Mailer
+	switch {
+	case cfg.Mailer.SMTP.Host != "":
+		loginMailer = mailer.NewSMTP(
+			cfg.Mailer.SMTP.Host, cfg.Mailer.SMTP.Port, cfg.Mailer.SMTP.Username, cfg.Mailer.SMTP.Password, cfg.Mailer.From,
+		)
+	case cfg.Mailer.OutboxPath != "":
+		loginMailer = mailer.NewOutbox(cfg.Mailer.OutboxPath)
+	}
+
+	loginCodePolicy := auth.LoginCodePolicy{
+		TTL:     cfg.Passwordless.CodeTTL,
+		LinkURL: cfg.Passwordless.LinkURL,
+	}
+	if cfg.Passwordless.LinkKey != "" {
+		loginCodePolicy.LinkSigner = linktoken.NewSigner([]byte(cfg.Passwordless.LinkKey))
+	}
+
+	passkeysSvc := passkeys.NewPasskeys(log, storage, storage, webAuthn)
+	authSvc := auth.NewAuth(
+		log, storage, storage, storage, storage, storage, storage, storage, mfaSvc, passkeysSvc, storage, loginMailer,
+		passHasher, registration, loginCodePolicy, cfg.TokenTTL, tokenKey,
+	)
+	invitesSvc := invites.NewInvites(log, storage, storage, cfg.Registration.InviteTTL)
+	rbacSvc := rbac.NewRBAC(log, storage, storage, storage)
+	orgsSvc := orgs.NewOrgs(log, storage, storage, storage, cfg.Registration.InviteTTL)
+	provisioningSvc := provisioning.NewProvisioning(log, storage, storage)
+	policiesSvc := policies.NewPolicies(log, storage, storage)
+	relationsSvc := relations.NewRelations(log, storage)
+	apiKeysSvc := apikeys.NewAPIKeys(log, storage, storage, storage, authSvc, cfg.TokenTTL, tokenKey)
+	clientsSvc := clients.NewClients(log, storage, storage, cfg.Issuer, cfg.TokenTTL, tokenKey)
+	webConfig := grpcApp.WebConfig{
+		Port:       cfg.GRPC.Web.Port,
+		Timeout:    cfg.GRPC.Web.Timeout,
+		CORSMaxAge: cfg.GRPC.Web.CORSMaxAge,
+		Origins:    authSvc,
+	}
+	authzRoutes := make([]extauthz.Route, 0, len(cfg.GRPC.ExtAuthz.Routes))
+	for _, route := range cfg.GRPC.ExtAuthz.Routes {
+		authzRoutes = append(authzRoutes, extauthz.Route(route))
+	}
+	extAuthzConfig := grpcApp.ExtAuthzConfig{
+		Tokens: authSvc,
+		Roles:  rbacSvc,
+		Routes: authzRoutes,
+	}
+	grpcApp := grpcApp.NewApp(
+		log, cfg.GRPC.Port, webConfig, extAuthzConfig, authSvc, apiKeysSvc, invitesSvc, mfaSvc, authSvc, passkeysSvc, authSvc, authSvc, rbacSvc,
+		orgsSvc, authSvc, provisioningSvc, policiesSvc, relationsSvc, apiKeysSvc, clientsSvc,
+	)
+
+	var httpServer *httpApp.App
+	if cfg.HTTP.Port != 0 {
+		var signingKey *jwt.SigningKey
+		if cfg.OAuth.SigningKeyPath != "" {
+			pemKey, err := os.ReadFile(cfg.OAuth.SigningKeyPath)
+			if err != nil {
+				log.Error("failed to read oauth signing key", "error", err)
+				return nil
+			}
+			signingKey, err = jwt.ParseSigningKey(pemKey)
+			if err != nil {
+				log.Error("failed to parse oauth signing key", "error", err)
+				return nil
+			}
+		}
+
+		oauthSvc := oauth.NewOAuth(
+			log, storage, storage, storage, storage, storage, authSvc, signingKey, cfg.Issuer,
+			cfg.OAuth.CodeTTL, cfg.TokenTTL, cfg.OAuth.DeviceCodeTTL, cfg.OAuth.DevicePollInterval,
+		)
+		connectors := make([]federation.Connector, 0, len(cfg.Federation.Connectors))
+		for _, connector := range cfg.Federation.Connectors {
+			connectors = append(connectors, federation.Connector(connector))
+		}
+		federationSvc := federation.NewFederation(
+			log, storage, storage, storage, storage, authSvc, connectors,
+			oauthHTTP.FederationCallbackURL(cfg.Issuer), cfg.Federation.LoginTTL,
+		)
+		sameSite, ok := sameSiteModes[cfg.Session.SameSite]
+		if !ok {
+			log.Error("invalid session same_site", slog.String("sameSite", cfg.Session.SameSite))
+			return nil
+		}
+		sessionsSvc := sessions.NewSessions(log, storage, authSvc, authSvc, rbacSvc, storage, cfg.Session.TTL)
+		sessionCookie := sessionHTTP.Cookie{
+			Name:     cfg.Session.CookieName,
+			CSRFName: cfg.Session.CSRFCookieName,
+			Domain:   cfg.Session.CookieDomain,
+			SameSite: sameSite,
+		}
+		httpServer = httpApp.NewApp(
+			log, cfg.HTTP.Port, cfg.HTTP.Timeout, cfg.Issuer, oauthSvc, authSvc, clientsSvc, federationSvc,
+			sessionsSvc, authSvc, sessionCookie, apiKeysSvc, rbacSvc, provisioningSvc,
+		)
+	}
+
+	var gatewayServer *httpApp.App
+	if cfg.Gateway.Port != 0 {
+		// the gateway calls the gRPC server of the process like any client
+		conn, err := grpc.Dial(
+			net.JoinHostPort("localhost", strconv.Itoa(cfg.GRPC.Port)),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+		)
+		if err != nil {
+			log.Error("failed to dial grpc server", "error", err)
+			return nil
+		}
+		corsPolicy := cors.Policy{
+			AllowedOrigins: cfg.Gateway.CORS.AllowedOrigins,
+			AllowedHeaders: cfg.Gateway.CORS.AllowedHeaders,
+			MaxAge:         cfg.Gateway.CORS.MaxAge,
+		}
+		gatewayServer, err = httpApp.NewGatewayApp(
+			log, cfg.Gateway.Port, cfg.Gateway.Timeout, conn, corsPolicy,
+			aaav1.File_aaa_aaa_proto.Services().ByName("Auth"),
+		)
+		if err != nil {
+			log.Error("failed to init gateway", "error", err)
+			return nil
+		}
+	}
+
 	return &App{
-		GRPCApp: grpcApp,
+		GRPCApp:    grpcApp,
+		HTTPApp:    httpServer,
+		GatewayApp: gatewayServer,
 	}
 }
@@ -1,12 +1,29 @@
 package grpcApp
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net"
+	"net/http"
 	"os"
+	"time"
 
+	apikeysgRPC "github.com/Len4i/auth-service/internal/grpc/apikeys"
 	authgRPC "github.com/Len4i/auth-service/internal/grpc/auth"
+	"github.com/Len4i/auth-service/internal/grpc/authn"
+	clientsgRPC "github.com/Len4i/auth-service/internal/grpc/clients"
+	"github.com/Len4i/auth-service/internal/grpc/extauthz"
+	invitesgRPC "github.com/Len4i/auth-service/internal/grpc/invites"
+	mfagRPC "github.com/Len4i/auth-service/internal/grpc/mfa"
+	orgsgRPC "github.com/Len4i/auth-service/internal/grpc/orgs"
+	passwordlessgRPC "github.com/Len4i/auth-service/internal/grpc/passwordless"
+	policiesgRPC "github.com/Len4i/auth-service/internal/grpc/policies"
+	rbacgRPC "github.com/Len4i/auth-service/internal/grpc/rbac"
+	relationsgRPC "github.com/Len4i/auth-service/internal/grpc/relations"
+	"github.com/Len4i/auth-service/internal/grpc/web"
+	webauthngRPC "github.com/Len4i/auth-service/internal/grpc/webauthn"
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/recovery"
 	"google.golang.org/grpc"
 )
@@ -15,16 +32,86 @@ type App struct {
 	log        *slog.Logger
 	grpcServer *grpc.Server
 	port       int
+	// webServer serves gRPC-Web and Connect clients, nil if disabled
+	webServer  *http.Server
+	webPort    int
+	webTimeout time.Duration
 }
 
-func NewApp(log *slog.Logger, port int, authSvc authgRPC.Auth) *App {
-	grpcServer := grpc.NewServer(grpc.ChainUnaryInterceptor(recovery.UnaryServerInterceptor()))
+// WebConfig sets up the server of browser clients, it is not started if Port is 0
+type WebConfig struct {
+	Port       int
+	Timeout    time.Duration
+	CORSMaxAge time.Duration
+	Origins    web.Origins
+}
+
+// ExtAuthzConfig sets up the Envoy external authorization service,
+// Tokens verifies bearer tokens of requests Envoy checks, Roles returns
+// roles of their users
+type ExtAuthzConfig struct {
+	Tokens extauthz.TokenVerifier
+	Roles  extauthz.RoleProvider
+	Routes []extauthz.Route
+}
+
+func NewApp(
+	log *slog.Logger,
+	port int,
+	webConfig WebConfig,
+	extAuthzConfig ExtAuthzConfig,
+	authSvc authgRPC.Auth,
+	tokenVerifier authn.TokenVerifier,
+	invitesSvc invitesgRPC.Invites,
+	mfaSvc mfagRPC.MFA,
+	mfaVerifier mfagRPC.Verifier,
+	passkeysSvc webauthngRPC.Passkeys,
+	passkeyAuthenticator webauthngRPC.Authenticator,
+	passwordlessSvc passwordlessgRPC.Passwordless,
+	rbacSvc rbacgRPC.RBAC,
+	orgsSvc orgsgRPC.Orgs,
+	orgSwitcher orgsgRPC.Switcher,
+	scimTokens orgsgRPC.SCIMTokens,
+	policiesSvc policiesgRPC.Policies,
+	relationsSvc relationsgRPC.Relations,
+	apiKeysSvc apikeysgRPC.APIKeys,
+	clientsSvc clientsgRPC.Clients,
+) *App {
+	grpcServer := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		recovery.UnaryServerInterceptor(),
+		authn.UnaryServerInterceptor(tokenVerifier),
+		web.UnaryServerInterceptor(webConfig.Origins),
+	))
 	authgRPC.Register(grpcServer, authSvc)
-	return &App{
+	invitesgRPC.Register(grpcServer, invitesSvc, authSvc)
+	mfagRPC.Register(grpcServer, mfaSvc, mfaVerifier)
+	webauthngRPC.Register(grpcServer, passkeysSvc, passkeyAuthenticator)
+	passwordlessgRPC.Register(grpcServer, passwordlessSvc)
+	rbacgRPC.Register(grpcServer, rbacSvc, authSvc)
+	orgsgRPC.Register(grpcServer, orgsSvc, orgSwitcher, scimTokens, authSvc)
+	policiesgRPC.Register(grpcServer, policiesSvc, authSvc)
+	relationsgRPC.Register(grpcServer, relationsSvc, authSvc)
+	apikeysgRPC.Register(grpcServer, apiKeysSvc, authSvc)
+	clientsgRPC.Register(grpcServer, clientsSvc, authSvc)
+	extauthz.Register(grpcServer, log, extAuthzConfig.Tokens, extAuthzConfig.Roles, extAuthzConfig.Routes)
+	app := &App{
 		log:        log,
 		grpcServer: grpcServer,
 		port:       port,
 	}
+	if webConfig.Port != 0 {
+		// web clients share the gRPC server, its methods and interceptors
+		handler := web.CORSPolicy(webConfig.Origins, webConfig.CORSMaxAge).Handler(web.NewHandler(grpcServer))
+		app.webServer = &http.Server{
+			Handler:           handler,
+			ReadHeaderTimeout: webConfig.Timeout,
+			ReadTimeout:       webConfig.Timeout,
+			WriteTimeout:      webConfig.Timeout,
+		}
+		app.webPort = webConfig.Port
+		app.webTimeout = webConfig.Timeout
+	}
+	return app
 }
 
 func (a *App) MustRun() {
@@ -34,12 +121,32 @@ func (a *App) MustRun() {
 	log.Info("starting grpc server", slog.Int("port", a.port))
 	l, err := net.Listen("tcp", fmt.Sprintf(":%d", a.port))
 	if err != nil {
-		log.Error("failed to tcp listener server", err)
+		log.Error("failed to tcp listener server", "error", err)
 		os.Exit(1)
 	}
 	log.Info("grpc server started", slog.String("address", l.Addr().String()))
+	if a.webServer != nil {
+		go a.mustRunWeb()
+	}
 	if err := a.grpcServer.Serve(l); err != nil {
-		log.Error("failed to start grpc server", err)
+		log.Error("failed to start grpc server", "error", err)
+		os.Exit(1)
+	}
+}
+
+func (a *App) mustRunWeb() {
+	const op = "grpcApp.RunWeb"
+	log := a.log.With(slog.String("operation", op))
+
+	log.Info("starting grpc web server", slog.Int("port", a.webPort))
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", a.webPort))
+	if err != nil {
+		log.Error("failed to tcp listener server", "error", err)
+		os.Exit(1)
+	}
+	log.Info("grpc web server started", slog.String("address", l.Addr().String()))
+	if err := a.webServer.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Error("failed to start grpc web server", "error", err)
 		os.Exit(1)
 	}
 }
@@ -48,6 +155,15 @@ func (a *App) Stop() error {
 	const op = "grpcApp.Stop"
 	log := a.log.With(slog.String("operation", op))
 
+	if a.webServer != nil {
+		log.Info("stopping grpc web server")
+		ctx, cancel := context.WithTimeout(context.Background(), a.webTimeout)
+		defer cancel()
+		if err := a.webServer.Shutdown(ctx); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
 	log.Info("stopping grpc server")
 	a.grpcServer.GracefulStop()
 	log.Info("grpc server stopped")
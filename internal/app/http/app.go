@@ -0,0 +1,135 @@
+package httpApp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/Len4i/auth-service/internal/http/forwardauth"
+	"github.com/Len4i/auth-service/internal/http/gateway"
+	oauthHTTP "github.com/Len4i/auth-service/internal/http/oauth"
+	scimHTTP "github.com/Len4i/auth-service/internal/http/scim"
+	sessionHTTP "github.com/Len4i/auth-service/internal/http/session"
+	"github.com/Len4i/auth-service/internal/lib/cors"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// gatewayHeaders are request headers browsers may send to the gateway,
+// metadata of methods included
+var gatewayHeaders = []string{"Authorization", "Content-Type", "X-App-Id", "X-Org-Id", "X-Invite-Code"}
+
+type App struct {
+	log        *slog.Logger
+	httpServer *http.Server
+	port       int
+	// conn is closed once the server is stopped
+	conn io.Closer
+}
+
+func NewApp(
+	log *slog.Logger,
+	port int,
+	timeout time.Duration,
+	issuer string,
+	oauthSvc oauthHTTP.OAuth,
+	authenticator oauthHTTP.Authenticator,
+	clientsSvc oauthHTTP.Clients,
+	federationSvc oauthHTTP.Federation,
+	sessionsSvc sessionHTTP.Sessions,
+	origins sessionHTTP.Origins,
+	sessionCookie sessionHTTP.Cookie,
+	tokens forwardauth.TokenVerifier,
+	roles forwardauth.RoleProvider,
+	provisioningSvc scimHTTP.Provisioning,
+) *App {
+	mux := http.NewServeMux()
+	oauthHTTP.Register(mux, log, issuer, oauthSvc, authenticator, clientsSvc, federationSvc)
+	sessionHTTP.Register(mux, log, sessionsSvc, origins, sessionCookie)
+	forwardauth.Register(mux, log, tokens, sessionsSvc, roles, sessionCookie.Name)
+	scimHTTP.Register(mux, log, issuer, provisioningSvc)
+	return &App{
+		log: log,
+		httpServer: &http.Server{
+			Handler:           mux,
+			ReadHeaderTimeout: timeout,
+			ReadTimeout:       timeout,
+			WriteTimeout:      timeout,
+		},
+		port: port,
+	}
+}
+
+// NewGatewayApp serves unary methods of services called over conn
+// as JSON API, conn is owned by the app
+func NewGatewayApp(
+	log *slog.Logger,
+	port int,
+	timeout time.Duration,
+	conn *grpc.ClientConn,
+	corsPolicy cors.Policy,
+	services ...protoreflect.ServiceDescriptor,
+) (*App, error) {
+	const op = "httpApp.NewGatewayApp"
+
+	mux := http.NewServeMux()
+	if err := gateway.Register(mux, log, conn, timeout, services...); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	corsPolicy.AllowedMethods = []string{http.MethodGet, http.MethodPost}
+	corsPolicy.AllowedHeaders = append(append([]string{}, gatewayHeaders...), corsPolicy.AllowedHeaders...)
+	return &App{
+		log: log,
+		httpServer: &http.Server{
+			Handler:           corsPolicy.Handler(mux),
+			ReadHeaderTimeout: timeout,
+			ReadTimeout:       timeout,
+			// leave time to write the error of calls exceeding timeout
+			WriteTimeout: timeout + time.Second,
+		},
+		port: port,
+		conn: conn,
+	}, nil
+}
+
+func (a *App) MustRun() {
+	const op = "httpApp.Run"
+	log := a.log.With(slog.String("operation", op))
+
+	log.Info("starting http server", slog.Int("port", a.port))
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", a.port))
+	if err != nil {
+		log.Error("failed to tcp listener server", "error", err)
+		os.Exit(1)
+	}
+	log.Info("http server started", slog.String("address", l.Addr().String()))
+	if err := a.httpServer.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Error("failed to start http server", "error", err)
+		os.Exit(1)
+	}
+}
+
+// Stop waits for active requests to complete until ctx is done
+func (a *App) Stop(ctx context.Context) error {
+	const op = "httpApp.Stop"
+	log := a.log.With(slog.String("operation", op))
+
+	log.Info("stopping http server")
+	if err := a.httpServer.Shutdown(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if a.conn != nil {
+		if err := a.conn.Close(); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	log.Info("http server stopped")
+
+	return nil
+}
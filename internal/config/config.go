@@ -9,16 +9,225 @@ import (
 )
 
 type Config struct {
-	Env         string     `yaml:"env" env-default:"local"`
-	StoragePath string     `yaml:"storage_path" env-required:"true"`
-	GRPC        GRPCConfig `yaml:"grpc"`
+	Env         string        `yaml:"env" env-default:"local"`
+	StoragePath string        `yaml:"storage_path" env-required:"true"`
+	GRPC        GRPCConfig    `yaml:"grpc"`
+	HTTP        HTTPConfig    `yaml:"http"`
+	Gateway     GatewayConfig `yaml:"gateway"`
 	// MigrationsPath string
-	TokenTTL time.Duration `yaml:"token_ttl" env-default:"1h"`
+	TokenTTL     time.Duration      `yaml:"token_ttl" env-default:"1h"`
+	Issuer       string             `yaml:"issuer" env-default:"auth-service"`
+	Pepper       PepperConfig       `yaml:"pepper"`
+	Registration RegistrationConfig `yaml:"registration"`
+	MFA          MFAConfig          `yaml:"mfa"`
+	WebAuthn     WebAuthnConfig     `yaml:"webauthn"`
+	Passwordless PasswordlessConfig `yaml:"passwordless"`
+	Mailer       MailerConfig       `yaml:"mailer"`
+	OAuth        OAuthConfig        `yaml:"oauth"`
+	Federation   FederationConfig   `yaml:"federation"`
+	Session      SessionConfig      `yaml:"session"`
+	// TokenKey is a base64 encoded key of at least 32 bytes the service
+	// authenticates tokens it issues with. A random key is generated if it's
+	// empty, issued tokens are then valid only until restart.
+	TokenKey string `yaml:"token_key"`
 }
 
 type GRPCConfig struct {
+	Port     int            `yaml:"port"`
+	Timeout  time.Duration  `yaml:"timeout"`
+	Web      GRPCWebConfig  `yaml:"web"`
+	ExtAuthz ExtAuthzConfig `yaml:"ext_authz"`
+}
+
+// GRPCWebConfig holds settings of the server of gRPC-Web and Connect
+// browser clients
+//
+// The server is not started if Port is 0. Browsers may call it from origins
+// allowed by apps and cache preflight responses for CORSMaxAge.
+// Timeout bounds reading a request and writing its response.
+type GRPCWebConfig struct {
+	Port       int           `yaml:"port"`
+	Timeout    time.Duration `yaml:"timeout" env-default:"10s"`
+	CORSMaxAge time.Duration `yaml:"cors_max_age" env-default:"10m"`
+}
+
+// ExtAuthzConfig holds route policies of the Envoy external authorization
+// service of the gRPC server
+type ExtAuthzConfig struct {
+	Routes []ExtAuthzRouteConfig `yaml:"routes"`
+}
+
+// ExtAuthzRouteConfig is the policy of requests of a route
+//
+// Envoy routes select it by Name in the "route" context extension, otherwise
+// the first route matching PathPrefix and Methods applies, routes without
+// PathPrefix are selected by name only, the Default route applies to
+// requests no other route matches, requests of no route are denied. Public
+// routes don't require a token. AppID requires tokens of the app, Roles one
+// of the roles and Scopes all of the scopes.
+type ExtAuthzRouteConfig struct {
+	Name       string   `yaml:"name"`
+	PathPrefix string   `yaml:"path_prefix"`
+	Methods    []string `yaml:"methods"`
+	Default    bool     `yaml:"default"`
+	Public     bool     `yaml:"public"`
+	AppID      int      `yaml:"app_id"`
+	Roles      []string `yaml:"roles"`
+	Scopes     []string `yaml:"scopes"`
+}
+
+// HTTPConfig holds settings of the HTTP server of OAuth endpoints
+//
+// The server is not started if Port is 0. Timeout bounds reading
+// a request and writing its response.
+type HTTPConfig struct {
+	Port    int           `yaml:"port"`
+	Timeout time.Duration `yaml:"timeout" env-default:"10s"`
+}
+
+// GatewayConfig holds settings of the JSON HTTP API serving gRPC methods
+//
+// The gateway is not started if Port is 0. It calls the gRPC server of the
+// process, so every request is bounded by Timeout as on the HTTP server.
+type GatewayConfig struct {
 	Port    int           `yaml:"port"`
-	Timeout time.Duration `yaml:"timeout"`
+	Timeout time.Duration `yaml:"timeout" env-default:"10s"`
+	CORS    CORSConfig    `yaml:"cors"`
+}
+
+// CORSConfig lets browser apps on AllowedOrigins call the API
+//
+// "*" allows any origin. AllowedHeaders extend the default Authorization,
+// Content-Type and metadata headers of methods. Browsers cache preflight
+// responses for MaxAge.
+type CORSConfig struct {
+	AllowedOrigins []string      `yaml:"allowed_origins"`
+	AllowedHeaders []string      `yaml:"allowed_headers"`
+	MaxAge         time.Duration `yaml:"max_age" env-default:"10m"`
+}
+
+// PepperConfig holds server-side password pepper keys
+//
+// Keys maps key ID to secret. New hashes are peppered with CurrentKeyID,
+// old keys are kept to verify and upgrade existing hashes.
+// Empty CurrentKeyID disables pepper for new hashes.
+type PepperConfig struct {
+	CurrentKeyID string            `yaml:"current_key_id"`
+	Keys         map[string]string `yaml:"keys"`
+}
+
+// RegistrationConfig controls who can register
+//
+// Mode is one of open, closed or invite_only and can be overridden per app.
+// Email domain lists apply in every mode, denied domains take precedence.
+type RegistrationConfig struct {
+	Mode           string        `yaml:"mode" env-default:"open"`
+	AllowedDomains []string      `yaml:"allowed_domains"`
+	DeniedDomains  []string      `yaml:"denied_domains"`
+	InviteTTL      time.Duration `yaml:"invite_ttl" env-default:"168h"`
+}
+
+// MFAConfig holds second factor settings
+//
+// EncryptionKey is a base64 encoded 32-byte key for TOTP secrets,
+// MFA enrollment is disabled without it.
+type MFAConfig struct {
+	Issuer        string `yaml:"issuer" env-default:"auth-service"`
+	EncryptionKey string `yaml:"encryption_key"`
+}
+
+// WebAuthnConfig holds relying party settings for passkeys
+//
+// RPID is the domain passkeys are bound to, RPOrigins are web origins
+// allowed to run ceremonies. Passkeys are disabled without RPID.
+type WebAuthnConfig struct {
+	RPID          string   `yaml:"rp_id"`
+	RPDisplayName string   `yaml:"rp_display_name" env-default:"auth-service"`
+	RPOrigins     []string `yaml:"rp_origins"`
+}
+
+// PasswordlessConfig controls login with one-time codes sent by email
+//
+// It requires a configured mailer. Magic links are sent along with codes
+// if both LinkURL and LinkKey are set, LinkKey signs link tokens.
+type PasswordlessConfig struct {
+	CodeTTL time.Duration `yaml:"code_ttl" env-default:"10m"`
+	LinkURL string        `yaml:"link_url"`
+	LinkKey string        `yaml:"link_key"`
+}
+
+// MailerConfig selects how emails are delivered
+//
+// SMTP is used if its host is set, otherwise messages are appended to
+// OutboxPath as JSON lines, meant for development and tests.
+// Without both emails are not sent.
+type MailerConfig struct {
+	From       string     `yaml:"from" env-default:"auth-service@localhost"`
+	SMTP       SMTPConfig `yaml:"smtp"`
+	OutboxPath string     `yaml:"outbox_path"`
+}
+
+type SMTPConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port" env-default:"587"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// OAuthConfig holds authorization server settings
+//
+// Authorization codes have to be exchanged for tokens within CodeTTL.
+// SigningKeyPath is a PEM file with the RSA key ID tokens are signed with,
+// OpenID Connect is disabled without it. Issuer is then the base URL
+// of the HTTP server. Device codes of the device flow expire after
+// DeviceCodeTTL, devices poll for them DevicePollInterval apart.
+type OAuthConfig struct {
+	CodeTTL            time.Duration `yaml:"code_ttl" env-default:"1m"`
+	SigningKeyPath     string        `yaml:"signing_key_path"`
+	DeviceCodeTTL      time.Duration `yaml:"device_code_ttl" env-default:"10m"`
+	DevicePollInterval time.Duration `yaml:"device_poll_interval" env-default:"5s"`
+}
+
+// FederationConfig holds upstream OpenID Connect providers users can sign in
+// with on the OAuth login page
+//
+// Providers redirect users back to the callback under Issuer,
+// users have LoginTTL to sign in there.
+type FederationConfig struct {
+	LoginTTL   time.Duration     `yaml:"login_ttl" env-default:"10m"`
+	Connectors []ConnectorConfig `yaml:"connectors"`
+}
+
+// ConnectorConfig is an upstream OpenID Connect provider
+//
+// ID is shown in URLs and names linked identities, it must not change.
+// Scopes default to openid, email and profile. LinkByEmail links identities
+// to users with the same email if the provider verified it, otherwise users
+// link them with their password. Provision registers unknown users with
+// a verified email on their first login.
+type ConnectorConfig struct {
+	ID           string   `yaml:"id"`
+	Name         string   `yaml:"name"`
+	Issuer       string   `yaml:"issuer"`
+	ClientID     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret"`
+	Scopes       []string `yaml:"scopes"`
+	LinkByEmail  bool     `yaml:"link_by_email"`
+	Provision    bool     `yaml:"provision"`
+}
+
+// SessionConfig holds cookie-based sessions of first-party web apps,
+// served by the HTTP server
+//
+// Sessions expire TTL after login. Cookies are HttpOnly and Secure,
+// CookieDomain shares them with subdomains. SameSite is one of lax,
+// strict or none, none is needed for apps on another site.
+type SessionConfig struct {
+	TTL            time.Duration `yaml:"ttl" env-default:"24h"`
+	CookieName     string        `yaml:"cookie_name" env-default:"auth_session"`
+	CSRFCookieName string        `yaml:"csrf_cookie_name" env-default:"auth_csrf"`
+	CookieDomain   string        `yaml:"cookie_domain"`
+	SameSite       string        `yaml:"same_site" env-default:"lax"`
 }
 
 func MustLoad() *Config {
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// APIKey is a long-lived credential a user creates for scripts and tools
+//
+// Only a hash of the key is stored, Prefix is the visible part of the key
+// that identifies it in listings. Scopes are permissions of the user in
+// the app the key is restricted to, "*" keeps all of them.
+type APIKey struct {
+	ID     int64
+	UserID int64
+	AppID  int
+	Name   string
+	Prefix string
+	Scopes []string
+	// ExpiresAt is zero if the key never expires
+	ExpiresAt  time.Time
+	LastUsedAt time.Time
+	Revoked    bool
+	CreatedAt  time.Time
+}
+
+// Active reports whether key can be used at the given time
+func (k APIKey) Active(now time.Time) bool {
+	return !k.Revoked && (k.ExpiresAt.IsZero() || now.Before(k.ExpiresAt))
+}
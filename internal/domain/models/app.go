@@ -4,4 +4,10 @@ type App struct {
 	ID     int
 	Name   string
 	Secret string
+	// RegistrationMode overrides global registration mode, empty to inherit it
+	RegistrationMode string
+	// RedirectURIs are the only URIs OAuth authorization responses of the app are sent to
+	RedirectURIs []string
+	// AllowedOrigins are web origins browsers may call the API from on behalf of the app
+	AllowedOrigins []string
 }
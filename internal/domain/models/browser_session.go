@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// BrowserSession is a server-side session of a user logged in to
+// a first-party web app, the browser keeps its token in a cookie
+//
+// The token is stored hashed. CSRFToken has to accompany state changing
+// requests of the session. OrgID, Methods, Level and AuthTime describe
+// the login as its token would. Roles and OrgRole are the ones the user
+// currently holds, Email is the current email of the user.
+type BrowserSession struct {
+	ID        int64
+	CSRFToken string
+	UserID    int64
+	Email     string
+	AppID     int
+	Roles     []string
+	OrgID     int64
+	OrgRole   string
+	Methods   []string
+	Level     string
+	AuthTime  time.Time
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Client is a machine identity of an app authenticating with client
+// credentials instead of a user login
+//
+// Only a hash of the client secret is stored. PublicKey is a PEM encoded
+// key verifying signed JWT assertions, empty if the client authenticates
+// only with its secret. Permissions are the scopes tokens of the client
+// may be granted.
+type Client struct {
+	ID          int64
+	ClientID    string
+	AppID       int
+	Name        string
+	PublicKey   string
+	Permissions []string
+	CreatedAt   time.Time
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+const (
+	DeviceCodePending  = "pending"
+	DeviceCodeApproved = "approved"
+	DeviceCodeDenied   = "denied"
+)
+
+// DeviceCode is a pending OAuth device authorization (RFC 8628)
+//
+// The device polls with the device code while the user approves or denies
+// it with the user code, both are stored hashed. Once decided, UserID,
+// OrgID, Methods, Level and AuthTime describe the login of the user.
+// Interval is the minimum time between polls, PolledAt is the last poll.
+type DeviceCode struct {
+	ID        int64
+	AppID     int
+	Scope     string
+	Status    string
+	UserID    int64
+	OrgID     int64
+	Methods   []string
+	Level     string
+	AuthTime  time.Time
+	Interval  time.Duration
+	PolledAt  time.Time
+	ExpiresAt time.Time
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// FederatedIdentity links user to an account at an upstream identity
+// provider, Subject is the id of the account at the Connector
+type FederatedIdentity struct {
+	ID        int64
+	UserID    int64
+	Connector string
+	Subject   string
+	Email     string
+	CreatedAt time.Time
+}
+
+// FederatedLogin is a login redirected to an upstream identity provider
+//
+// Nonce and CodeVerifier bind the callback to the redirect. Request is
+// resumed once user is logged in, it's opaque to the service.
+type FederatedLogin struct {
+	ID           int64
+	Connector    string
+	AppID        int
+	Request      string
+	Nonce        string
+	CodeVerifier string
+	ExpiresAt    time.Time
+}
+
+// FederatedLink is an upstream identity waiting to be linked to the existing
+// user with the same email, once the user proves the account is theirs
+type FederatedLink struct {
+	ID        int64
+	UserID    int64
+	Connector string
+	Subject   string
+	Email     string
+	AppID     int
+	Request   string
+	Attempts  int
+	ExpiresAt time.Time
+}
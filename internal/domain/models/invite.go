@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Invite allows registration when registration is invite-only
+//
+// Only a hash of the invite code is stored.
+type Invite struct {
+	ID int64
+	// AppID is 0 if invite is valid for any app
+	AppID int
+	// Email is empty if invite is valid for any email
+	Email     string
+	MaxUses   int
+	Uses      int
+	ExpiresAt time.Time
+	Revoked   bool
+	CreatedBy int64
+	CreatedAt time.Time
+}
+
+// Active reports whether invite can still be used at the given time
+func (i Invite) Active(now time.Time) bool {
+	return !i.Revoked && i.Uses < i.MaxUses && now.Before(i.ExpiresAt)
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// LoginCode is a pending passwordless login sent to user by email
+//
+// The numeric code is stored hashed, the magic link carries a signed ID.
+type LoginCode struct {
+	ID        int64
+	UserID    int64
+	AppID     int
+	CodeHash  []byte
+	Attempts  int
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
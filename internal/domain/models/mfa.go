@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// TOTPFactor is a user authenticator app enrollment
+//
+// Secret is encrypted, the factor is used only after it is confirmed
+// with a first code.
+type TOTPFactor struct {
+	UserID    int64
+	Secret    []byte
+	Confirmed bool
+	// LastStep is the time step of the last accepted code, used to reject replays
+	LastStep  int64
+	CreatedAt time.Time
+}
+
+// MFAChallenge is a pending login waiting for the second factor
+//
+// Methods are the authentication methods the user already passed,
+// the second factor is added to them in the issued token.
+// OrgID is the org the token is requested for, 0 if none.
+type MFAChallenge struct {
+	ID        int64
+	UserID    int64
+	AppID     int
+	OrgID     int64
+	Methods   []string
+	Attempts  int
+	ExpiresAt time.Time
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// AuthorizationCode is an OAuth authorization code waiting to be exchanged
+// for a token of the user who logged in to the app
+//
+// The code is stored hashed and is used once. CodeChallenge is the S256
+// PKCE challenge, RedirectURI must be repeated in the token request.
+// Nonce is put in the ID token if the scope includes openid.
+// Methods, Level and AuthTime describe the login the code was issued for.
+type AuthorizationCode struct {
+	ID            int64
+	AppID         int
+	UserID        int64
+	OrgID         int64
+	RedirectURI   string
+	CodeChallenge string
+	Scope         string
+	Nonce         string
+	Methods       []string
+	Level         string
+	AuthTime      time.Time
+	ExpiresAt     time.Time
+}
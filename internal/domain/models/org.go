@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// Organization member roles, from the most to the least privileged
+const (
+	OrgRoleOwner  = "owner"
+	OrgRoleAdmin  = "admin"
+	OrgRoleMember = "member"
+)
+
+// Org is an organization users are grouped into
+type Org struct {
+	ID   int64
+	Slug string
+	Name string
+	// AllowedApps are apps members may log in to with the org, empty allows any
+	AllowedApps []int
+	LoginPolicy OrgLoginPolicy
+	CreatedAt   time.Time
+}
+
+// OrgLoginPolicy restricts how members log in to the org
+type OrgLoginPolicy struct {
+	RequireMFA bool
+	// AllowedMethods are amr values members may authenticate with, empty allows any
+	AllowedMethods []string
+}
+
+// OrgMember is a membership of user in an org
+type OrgMember struct {
+	OrgID     int64
+	UserID    int64
+	Email     string
+	Role      string
+	CreatedAt time.Time
+}
+
+// OrgInvite invites a user with the email to join the org
+//
+// Only a hash of the invite code is stored.
+type OrgInvite struct {
+	ID        int64
+	OrgID     int64
+	Email     string
+	Role      string
+	ExpiresAt time.Time
+	// AcceptedBy is the ID of the user who accepted the invite, 0 if pending
+	AcceptedBy int64
+	Revoked    bool
+	CreatedBy  int64
+	CreatedAt  time.Time
+}
+
+// Active reports whether invite can still be accepted at the given time
+func (i OrgInvite) Active(now time.Time) bool {
+	return !i.Revoked && i.AcceptedBy == 0 && now.Before(i.ExpiresAt)
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Policy effects, a matching deny policy overrides any allow policy
+const (
+	PolicyEffectAllow = "allow"
+	PolicyEffectDeny  = "deny"
+)
+
+// Policy is an attribute-based access rule of an app
+//
+// Expression is a CEL expression over subject, resource, action, context
+// and now, the policy applies when it evaluates to true.
+// Policies with AppID 0 are global and evaluated in every app.
+type Policy struct {
+	ID          int64
+	AppID       int
+	Name        string
+	Description string
+	Effect      string
+	Expression  string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// Namespace is the schema of objects of one type in relation tuples
+type Namespace struct {
+	Name      string     `json:"-"`
+	Relations []Relation `json:"relations"`
+	UpdatedAt time.Time  `json:"-"`
+}
+
+// Relation of a namespace, its users are the union of Rewrite usersets
+//
+// Empty Rewrite stands for users of the relation tuples only.
+type Relation struct {
+	Name    string    `json:"name"`
+	Rewrite []Userset `json:"rewrite,omitempty"`
+}
+
+// Userset is one of the ways to compute users of a relation, exactly one field is set
+type Userset struct {
+	// This refers to users of tuples with the relation itself
+	This bool `json:"this,omitempty"`
+	// ComputedUserset refers to users of another relation of the same object
+	ComputedUserset string `json:"computed_userset,omitempty"`
+	// TupleToUserset refers to users of a relation of objects related by a tupleset
+	TupleToUserset *TupleToUserset `json:"tuple_to_userset,omitempty"`
+}
+
+// TupleToUserset takes objects the object is related to by Tupleset
+// and users of their ComputedUserset relation,
+// e.g. viewers of the parent folder of a document
+type TupleToUserset struct {
+	Tupleset        string `json:"tupleset"`
+	ComputedUserset string `json:"computed_userset"`
+}
+
+// RelationTuple states that subject has relation to the object
+type RelationTuple struct {
+	Namespace string
+	ObjectID  string
+	Relation  string
+	Subject   RelationSubject
+}
+
+// RelationSubject is a user or a userset, i.e. users having Relation
+// to the object, empty Relation refers to the object itself
+type RelationSubject struct {
+	UserID    string
+	Namespace string
+	ObjectID  string
+	Relation  string
+}
+
+// IsUserset reports whether subject is a userset rather than a user
+func (s RelationSubject) IsUserset() bool {
+	return s.UserID == ""
+}
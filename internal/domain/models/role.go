@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+const (
+	// AdminRoleName is the built-in global role IsAdmin is based on
+	AdminRoleName = "admin"
+	// PermissionAll grants every permission
+	PermissionAll = "*"
+)
+
+// Role is a named set of permissions in an app
+//
+// Roles with AppID 0 are global and granted in every app.
+// Built-in roles can't be deleted.
+type Role struct {
+	ID          int64
+	AppID       int
+	Name        string
+	Description string
+	Permissions []string
+	Builtin     bool
+	CreatedAt   time.Time
+}
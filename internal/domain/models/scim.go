@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+// SCIMToken is a bearer token a SCIM client, usually the identity provider
+// of the org, provisions users and groups of the org with
+//
+// Only a hash of the token is stored.
+type SCIMToken struct {
+	ID         int64
+	OrgID      int64
+	Name       string
+	CreatedBy  int64
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+	Revoked    bool
+}
+
+// SCIMUser is a user provisioned to an org over SCIM
+//
+// Active users are members of the org. Provisioned is set if the user was
+// created by the org, only such users may have email and profile changed
+// by the org.
+type SCIMUser struct {
+	OrgID       int64
+	UserID      int64
+	ExternalID  string
+	Email       string
+	Profile     Profile
+	Active      bool
+	Provisioned bool
+	// Groups are read-only, membership is changed on the groups
+	Groups    []SCIMGroupRef
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// SCIMGroupRef refers to a group a SCIM user is a member of
+type SCIMGroupRef struct {
+	ID          int64
+	DisplayName string
+}
+
+// SCIMGroup is a group of SCIM users of an org
+type SCIMGroup struct {
+	ID          int64
+	OrgID       int64
+	DisplayName string
+	ExternalID  string
+	Members     []SCIMMember
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// SCIMMember is a SCIM user in a group
+type SCIMMember struct {
+	UserID int64
+	Email  string
+}
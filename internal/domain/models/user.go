@@ -4,4 +4,22 @@ type User struct {
 	ID       int64
 	Email    string
 	PassHash []byte
+	// PassLegacy is set for hashes imported from foreign systems,
+	// they are replaced with bcrypt on first successful login
+	PassLegacy bool
+	// PepperKeyID is the ID of the pepper key PassHash was made with,
+	// empty if the hash is not peppered
+	PepperKeyID string
+	// IsAdmin reports whether user has the built-in admin role
+	IsAdmin bool
+	Profile Profile
+}
+
+// Profile holds optional user profile fields
+type Profile struct {
+	Name       string
+	GivenName  string
+	FamilyName string
+	Picture    string
+	Locale     string
 }
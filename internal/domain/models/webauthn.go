@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// WebAuthnCredential is a passkey or security key registered by user
+type WebAuthnCredential struct {
+	ID              int64
+	UserID          int64
+	CredentialID    []byte
+	PublicKey       []byte
+	AttestationType string
+	AAGUID          []byte
+	// SignCount is the last signature counter reported by the authenticator,
+	// used to detect cloned authenticators
+	SignCount      uint32
+	Transports     []string
+	BackupEligible bool
+	BackupState    bool
+	Name           string
+	CreatedAt      time.Time
+	// LastUsedAt is zero if credential was never used to log in
+	LastUsedAt time.Time
+}
+
+// WebAuthnSession is a pending registration or login ceremony
+//
+// UserID is 0 for login with a discoverable credential, MFAChallengeID is
+// set when login completes the second factor of a password login.
+type WebAuthnSession struct {
+	ID             int64
+	Purpose        string
+	UserID         int64
+	AppID          int
+	MFAChallengeID int64
+	Data           []byte
+	ExpiresAt      time.Time
+}
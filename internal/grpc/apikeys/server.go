@@ -0,0 +1,190 @@
+package apikeys
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	authsvcv1 "github.com/Len4i/auth-service/gen/go/authsvc"
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/grpc/authn"
+	"github.com/Len4i/auth-service/internal/services/apikeys"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type APIKeys interface {
+	CreateAPIKey(
+		ctx context.Context,
+		userID int64,
+		appID int,
+		name string,
+		scopes []string,
+		ttl time.Duration,
+	) (key models.APIKey, plain string, err error)
+	ListAPIKeys(ctx context.Context, userID int64, includeInactive bool) ([]models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID int64, id int64) error
+	ExchangeAPIKey(ctx context.Context, plain string) (token string, err error)
+}
+
+type ServerApi struct {
+	authsvcv1.UnimplementedAPIKeysServer
+	keys   APIKeys
+	admins authn.AdminChecker
+}
+
+func Register(gRPC *grpc.Server, keys APIKeys, admins authn.AdminChecker) {
+	authsvcv1.RegisterAPIKeysServer(gRPC, &ServerApi{
+		keys:   keys,
+		admins: admins,
+	})
+}
+
+func (s *ServerApi) CreateAPIKey(
+	ctx context.Context,
+	req *authsvcv1.CreateAPIKeyRequest,
+) (*authsvcv1.CreateAPIKeyResponse, error) {
+	claims, err := authn.RequireLogin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	appID := int(req.GetAppId())
+	if appID == 0 {
+		appID = claims.AppID
+	}
+
+	key, plain, err := s.keys.CreateAPIKey(
+		ctx,
+		claims.UserID,
+		appID,
+		req.GetName(),
+		req.GetScopes(),
+		time.Duration(req.GetTtlSeconds())*time.Second,
+	)
+	if err != nil {
+		return nil, apiKeysError(err)
+	}
+
+	return &authsvcv1.CreateAPIKeyResponse{
+		ApiKey: apiKeyToProto(key),
+		Key:    plain,
+	}, nil
+}
+
+func (s *ServerApi) ListAPIKeys(
+	ctx context.Context,
+	req *authsvcv1.ListAPIKeysRequest,
+) (*authsvcv1.ListAPIKeysResponse, error) {
+	userID, err := s.requireSelfOrAdmin(ctx, req.GetUserId())
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := s.keys.ListAPIKeys(ctx, userID, req.GetIncludeInactive())
+	if err != nil {
+		return nil, apiKeysError(err)
+	}
+
+	resp := &authsvcv1.ListAPIKeysResponse{
+		ApiKeys: make([]*authsvcv1.APIKey, 0, len(keys)),
+	}
+	for _, key := range keys {
+		resp.ApiKeys = append(resp.ApiKeys, apiKeyToProto(key))
+	}
+
+	return resp, nil
+}
+
+func (s *ServerApi) RevokeAPIKey(
+	ctx context.Context,
+	req *authsvcv1.RevokeAPIKeyRequest,
+) (*authsvcv1.RevokeAPIKeyResponse, error) {
+	userID, err := s.requireSelfOrAdmin(ctx, req.GetUserId())
+	if err != nil {
+		return nil, err
+	}
+	if req.GetId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+
+	if err := s.keys.RevokeAPIKey(ctx, userID, req.GetId()); err != nil {
+		return nil, apiKeysError(err)
+	}
+
+	return &authsvcv1.RevokeAPIKeyResponse{}, nil
+}
+
+func (s *ServerApi) ExchangeAPIKey(
+	ctx context.Context,
+	req *authsvcv1.ExchangeAPIKeyRequest,
+) (*authsvcv1.ExchangeAPIKeyResponse, error) {
+	if req.GetKey() == "" {
+		return nil, status.Error(codes.InvalidArgument, "key is required")
+	}
+
+	token, err := s.keys.ExchangeAPIKey(ctx, req.GetKey())
+	if err != nil {
+		return nil, apiKeysError(err)
+	}
+
+	return &authsvcv1.ExchangeAPIKeyResponse{
+		Token: token,
+	}, nil
+}
+
+// requireSelfOrAdmin returns id of the user whose keys are managed,
+// the caller if userID is 0, otherwise the caller must be an admin
+func (s *ServerApi) requireSelfOrAdmin(ctx context.Context, userID int64) (int64, error) {
+	claims, err := authn.RequireLogin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if userID == 0 || userID == claims.UserID {
+		return claims.UserID, nil
+	}
+
+	if _, err := authn.RequireAdmin(ctx, s.admins); err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+func apiKeyToProto(key models.APIKey) *authsvcv1.APIKey {
+	k := &authsvcv1.APIKey{
+		Id:        key.ID,
+		UserId:    key.UserID,
+		AppId:     int32(key.AppID),
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		Scopes:    key.Scopes,
+		Revoked:   key.Revoked,
+		CreatedAt: key.CreatedAt.Unix(),
+	}
+	if !key.ExpiresAt.IsZero() {
+		k.ExpiresAt = key.ExpiresAt.Unix()
+	}
+	if !key.LastUsedAt.IsZero() {
+		k.LastUsedAt = key.LastUsedAt.Unix()
+	}
+	return k
+}
+
+func apiKeysError(err error) error {
+	switch {
+	case errors.Is(err, apikeys.ErrorInvalidName):
+		return status.Error(codes.InvalidArgument, "name must be 1 to 64 characters")
+	case errors.Is(err, apikeys.ErrorInvalidScope):
+		return status.Error(codes.InvalidArgument, "scopes are not valid")
+	case errors.Is(err, apikeys.ErrorInvalidTTL):
+		return status.Error(codes.InvalidArgument, "ttl must not be negative")
+	case errors.Is(err, apikeys.ErrorInvalidAppID):
+		return status.Error(codes.InvalidArgument, "app id is not valid")
+	case errors.Is(err, apikeys.ErrorKeyNotFound):
+		return status.Error(codes.NotFound, "api key not found")
+	case errors.Is(err, apikeys.ErrorInvalidAPIKey):
+		return status.Error(codes.Unauthenticated, "invalid api key")
+	}
+	return status.Error(codes.Internal, "internal error")
+}
@@ -4,19 +4,42 @@ import (
 	"context"
 	"errors"
 	"net/mail"
+	"strconv"
+	"strings"
 
 	aaav1 "github.com/Len4i/aaa/gen/go/aaa"
 	"github.com/Len4i/auth-service/internal/services/auth"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
 
 const emptyUserID = 0
 
+// RegisterRequest has no app and invite fields, clients pass them as metadata
+const (
+	appIDMetadataKey      = "x-app-id"
+	inviteCodeMetadataKey = "x-invite-code"
+)
+
+// LoginRequest has no org field, clients pass it as metadata
+const orgIDMetadataKey = "x-org-id"
+
+// LoginResponse has no MFA fields, Login fails with FAILED_PRECONDITION status
+// carrying ErrorInfo with this reason, the challenge and comma separated
+// second factor methods in its metadata
+const (
+	errorInfoDomain       = "auth-service"
+	mfaRequiredReason     = "MFA_REQUIRED"
+	mfaChallengeErrorInfo = "challenge"
+	mfaMethodsErrorInfo   = "methods"
+)
+
 type Auth interface {
-	Login(ctx context.Context, email string, password string, appID int) (token string, err error)
-	Register(ctx context.Context, email string, password string) (userID int64, err error)
+	Login(ctx context.Context, email string, password string, appID int, orgID int64) (token string, err error)
+	Register(ctx context.Context, email string, password string, appID int, inviteCode string) (userID int64, err error)
 	IsAdmin(ctx context.Context, userID int64) (bool, error)
 }
 
@@ -36,8 +59,20 @@ func (s *ServerApi) Login(ctx context.Context, req *aaav1.LoginRequest) (*aaav1.
 	if err := validateRequestCreds(req.GetEmail(), req.GetPassword()); err != nil {
 		return nil, err
 	}
-	token, err := s.auth.Login(ctx, req.GetEmail(), req.GetPassword(), int(req.GetAppId()))
+	orgID, err := loginMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := s.auth.Login(ctx, req.GetEmail(), req.GetPassword(), int(req.GetAppId()), orgID)
 	if err != nil {
+		var mfaErr *auth.MFARequiredError
+		if errors.As(err, &mfaErr) {
+			return nil, MFARequiredStatus(mfaErr)
+		}
+		if st, ok := OrgPolicyStatus(err); ok {
+			return nil, st
+		}
 		// TODO: handle errors
 		return nil, status.Error(codes.Internal, "internal error")
 	}
@@ -52,10 +87,26 @@ func (s *ServerApi) Register(ctx context.Context, req *aaav1.RegisterRequest) (*
 		return nil, err
 	}
 
-	userID, err := s.auth.Register(ctx, req.GetEmail(), req.GetPassword())
+	appID, inviteCode, err := registrationMetadata(ctx)
 	if err != nil {
-		if errors.Is(err, auth.ErrorUserExists) {
+		return nil, err
+	}
+
+	userID, err := s.auth.Register(ctx, req.GetEmail(), req.GetPassword(), appID, inviteCode)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrorUserExists):
 			return nil, status.Error(codes.Internal, "user already exists")
+		case errors.Is(err, auth.ErrorInvalidAppID):
+			return nil, status.Error(codes.InvalidArgument, "app id is not valid")
+		case errors.Is(err, auth.ErrorRegistrationClosed):
+			return nil, status.Error(codes.PermissionDenied, "registration is closed")
+		case errors.Is(err, auth.ErrorEmailDomainNotAllowed):
+			return nil, status.Error(codes.PermissionDenied, "email domain is not allowed")
+		case errors.Is(err, auth.ErrorInviteRequired):
+			return nil, status.Error(codes.PermissionDenied, "invite code is required")
+		case errors.Is(err, auth.ErrorInvalidInvite):
+			return nil, status.Error(codes.PermissionDenied, "invite code is not valid")
 		}
 		return nil, status.Error(codes.Internal, "internal error")
 	}
@@ -79,6 +130,39 @@ func (s *ServerApi) IsAdmin(ctx context.Context, req *aaav1.IsAdminRequest) (*aa
 	}, nil
 }
 
+// MFARequiredStatus converts login error to FAILED_PRECONDITION status with
+// MFA_REQUIRED error info, shared by all login methods
+func MFARequiredStatus(mfaErr *auth.MFARequiredError) error {
+	st, err := status.New(codes.FailedPrecondition, "mfa required").WithDetails(&errdetails.ErrorInfo{
+		Reason: mfaRequiredReason,
+		Domain: errorInfoDomain,
+		Metadata: map[string]string{
+			mfaChallengeErrorInfo: mfaErr.Challenge,
+			mfaMethodsErrorInfo:   strings.Join(mfaErr.Methods, ","),
+		},
+	})
+	if err != nil {
+		return status.Error(codes.Internal, "internal error")
+	}
+	return st.Err()
+}
+
+// OrgPolicyStatus converts error of login scoped to an org to status,
+// shared by all login methods
+func OrgPolicyStatus(err error) (error, bool) {
+	switch {
+	case errors.Is(err, auth.ErrorInvalidOrg):
+		return status.Error(codes.PermissionDenied, "not a member of the org"), true
+	case errors.Is(err, auth.ErrorOrgAppNotAllowed):
+		return status.Error(codes.PermissionDenied, "app is not allowed in the org"), true
+	case errors.Is(err, auth.ErrorOrgMethodNotAllowed):
+		return status.Error(codes.PermissionDenied, "authentication method is not allowed in the org"), true
+	case errors.Is(err, auth.ErrorOrgMFARequired):
+		return status.Error(codes.FailedPrecondition, "org requires mfa"), true
+	}
+	return nil, false
+}
+
 func validateRequestCreds(email string, password string) error {
 	if email == "" {
 		return status.Error(codes.InvalidArgument, "email is required")
@@ -94,6 +178,43 @@ func validateRequestCreds(email string, password string) error {
 	return nil
 }
 
+// registrationMetadata returns optional app ID and invite code from request metadata
+func registrationMetadata(ctx context.Context) (appID int, inviteCode string, err error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return 0, "", nil
+	}
+
+	if values := md.Get(appIDMetadataKey); len(values) > 0 && values[0] != "" {
+		appID, err = strconv.Atoi(values[0])
+		if err != nil || appID <= 0 {
+			return 0, "", status.Error(codes.InvalidArgument, "app id is not valid")
+		}
+	}
+	if values := md.Get(inviteCodeMetadataKey); len(values) > 0 {
+		inviteCode = values[0]
+	}
+
+	return appID, inviteCode, nil
+}
+
+// loginMetadata returns optional org ID from request metadata
+func loginMetadata(ctx context.Context) (orgID int64, err error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return 0, nil
+	}
+
+	if values := md.Get(orgIDMetadataKey); len(values) > 0 && values[0] != "" {
+		orgID, err = strconv.ParseInt(values[0], 10, 64)
+		if err != nil || orgID <= 0 {
+			return 0, status.Error(codes.InvalidArgument, "org id is not valid")
+		}
+	}
+
+	return orgID, nil
+}
+
 func validateIsAdmin(userID int64) error {
 	if userID == emptyUserID {
 		return status.Error(codes.InvalidArgument, "userID is required")
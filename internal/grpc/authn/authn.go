@@ -0,0 +1,125 @@
+// Package authn authenticates gRPC callers by the bearer token issued by Login,
+// an api key or a client token.
+package authn
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Len4i/auth-service/internal/lib/jwt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	authorizationHeader = "authorization"
+	bearerPrefix        = "bearer "
+)
+
+type TokenVerifier interface {
+	VerifyToken(ctx context.Context, token string) (jwt.Claims, error)
+}
+
+type AdminChecker interface {
+	IsAdmin(ctx context.Context, userID int64) (bool, error)
+}
+
+type claimsKey struct{}
+
+// UnaryServerInterceptor verifies bearer token from request metadata
+// and stores its claims in context
+//
+// Requests without token pass through, handlers decide whether
+// authentication is required. Invalid token is rejected.
+func UnaryServerInterceptor(verifier TokenVerifier) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		token := bearerToken(ctx)
+		if token == "" {
+			return handler(ctx, req)
+		}
+
+		claims, err := verifier.VerifyToken(ctx, token)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		}
+
+		return handler(context.WithValue(ctx, claimsKey{}, claims), req)
+	}
+}
+
+// ClaimsFromContext returns claims of the authenticated caller
+func ClaimsFromContext(ctx context.Context) (jwt.Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(jwt.Claims)
+	return claims, ok
+}
+
+// RequireUser returns claims of the authenticated caller or Unauthenticated error
+//
+// Tokens of clients are rejected, they act on behalf of no user.
+func RequireUser(ctx context.Context) (jwt.Claims, error) {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		return jwt.Claims{}, status.Error(codes.Unauthenticated, "authentication required")
+	}
+	if claims.ClientID != "" {
+		return jwt.Claims{}, status.Error(codes.PermissionDenied, "user token required")
+	}
+	return claims, nil
+}
+
+// RequireLogin returns claims of the caller authenticated by login
+//
+// Scoped credentials, i.e. api keys and tokens exchanged for them, are
+// rejected, so they can't manage credentials or mint other tokens.
+func RequireLogin(ctx context.Context) (jwt.Claims, error) {
+	claims, err := RequireUser(ctx)
+	if err != nil {
+		return jwt.Claims{}, err
+	}
+	if claims.Scopes != nil {
+		return jwt.Claims{}, status.Error(codes.PermissionDenied, "api keys are not allowed")
+	}
+	return claims, nil
+}
+
+// RequireAdmin returns claims of the authenticated caller if it is an admin
+//
+// Scoped credentials must have the "*" scope to act as an admin.
+func RequireAdmin(ctx context.Context, admins AdminChecker) (jwt.Claims, error) {
+	claims, err := RequireUser(ctx)
+	if err != nil {
+		return jwt.Claims{}, err
+	}
+	if !claims.Allows("*") {
+		return jwt.Claims{}, status.Error(codes.PermissionDenied, "admin permission required")
+	}
+
+	ok, err := admins.IsAdmin(ctx, claims.UserID)
+	if err != nil {
+		return jwt.Claims{}, status.Error(codes.Internal, "internal error")
+	}
+	if !ok {
+		return jwt.Claims{}, status.Error(codes.PermissionDenied, "admin permission required")
+	}
+
+	return claims, nil
+}
+
+func bearerToken(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	values := md.Get(authorizationHeader)
+	if len(values) == 0 {
+		return ""
+	}
+
+	if len(values[0]) <= len(bearerPrefix) || !strings.EqualFold(values[0][:len(bearerPrefix)], bearerPrefix) {
+		return ""
+	}
+	return strings.TrimSpace(values[0][len(bearerPrefix):])
+}
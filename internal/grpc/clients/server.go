@@ -0,0 +1,184 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	authsvcv1 "github.com/Len4i/auth-service/gen/go/authsvc"
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/grpc/authn"
+	"github.com/Len4i/auth-service/internal/services/clients"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type Clients interface {
+	CreateClient(
+		ctx context.Context,
+		appID int,
+		name string,
+		permissions []string,
+		publicKey string,
+	) (client models.Client, secret string, err error)
+	ListClients(ctx context.Context, appID int) ([]models.Client, error)
+	RotateClientSecret(ctx context.Context, clientID string) (secret string, err error)
+	DeleteClient(ctx context.Context, clientID string) error
+	IssueToken(
+		ctx context.Context,
+		creds clients.Credentials,
+		scopes []string,
+	) (token string, granted []string, ttl time.Duration, err error)
+}
+
+type ServerApi struct {
+	authsvcv1.UnimplementedClientsServer
+	clients Clients
+	admins  authn.AdminChecker
+}
+
+func Register(gRPC *grpc.Server, clients Clients, admins authn.AdminChecker) {
+	authsvcv1.RegisterClientsServer(gRPC, &ServerApi{
+		clients: clients,
+		admins:  admins,
+	})
+}
+
+func (s *ServerApi) CreateClient(
+	ctx context.Context,
+	req *authsvcv1.CreateClientRequest,
+) (*authsvcv1.CreateClientResponse, error) {
+	if _, err := authn.RequireAdmin(ctx, s.admins); err != nil {
+		return nil, err
+	}
+
+	client, secret, err := s.clients.CreateClient(
+		ctx, int(req.GetAppId()), req.GetName(), req.GetPermissions(), req.GetPublicKey(),
+	)
+	if err != nil {
+		return nil, clientsError(err)
+	}
+
+	return &authsvcv1.CreateClientResponse{
+		Client:       clientToProto(client),
+		ClientSecret: secret,
+	}, nil
+}
+
+func (s *ServerApi) ListClients(
+	ctx context.Context,
+	req *authsvcv1.ListClientsRequest,
+) (*authsvcv1.ListClientsResponse, error) {
+	if _, err := authn.RequireAdmin(ctx, s.admins); err != nil {
+		return nil, err
+	}
+
+	list, err := s.clients.ListClients(ctx, int(req.GetAppId()))
+	if err != nil {
+		return nil, clientsError(err)
+	}
+
+	resp := &authsvcv1.ListClientsResponse{
+		Clients: make([]*authsvcv1.Client, 0, len(list)),
+	}
+	for _, client := range list {
+		resp.Clients = append(resp.Clients, clientToProto(client))
+	}
+
+	return resp, nil
+}
+
+func (s *ServerApi) RotateClientSecret(
+	ctx context.Context,
+	req *authsvcv1.RotateClientSecretRequest,
+) (*authsvcv1.RotateClientSecretResponse, error) {
+	if _, err := authn.RequireAdmin(ctx, s.admins); err != nil {
+		return nil, err
+	}
+	if req.GetClientId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "client_id is required")
+	}
+
+	secret, err := s.clients.RotateClientSecret(ctx, req.GetClientId())
+	if err != nil {
+		return nil, clientsError(err)
+	}
+
+	return &authsvcv1.RotateClientSecretResponse{
+		ClientSecret: secret,
+	}, nil
+}
+
+func (s *ServerApi) DeleteClient(
+	ctx context.Context,
+	req *authsvcv1.DeleteClientRequest,
+) (*authsvcv1.DeleteClientResponse, error) {
+	if _, err := authn.RequireAdmin(ctx, s.admins); err != nil {
+		return nil, err
+	}
+	if req.GetClientId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "client_id is required")
+	}
+
+	if err := s.clients.DeleteClient(ctx, req.GetClientId()); err != nil {
+		return nil, clientsError(err)
+	}
+
+	return &authsvcv1.DeleteClientResponse{}, nil
+}
+
+func (s *ServerApi) IssueClientToken(
+	ctx context.Context,
+	req *authsvcv1.IssueClientTokenRequest,
+) (*authsvcv1.IssueClientTokenResponse, error) {
+	if req.GetClientAssertion() == "" && (req.GetClientId() == "" || req.GetClientSecret() == "") {
+		return nil, status.Error(codes.InvalidArgument, "client_id with client_secret or client_assertion is required")
+	}
+
+	token, granted, ttl, err := s.clients.IssueToken(ctx, clients.Credentials{
+		ClientID:  req.GetClientId(),
+		Secret:    req.GetClientSecret(),
+		Assertion: req.GetClientAssertion(),
+	}, req.GetScopes())
+	if err != nil {
+		return nil, clientsError(err)
+	}
+
+	return &authsvcv1.IssueClientTokenResponse{
+		Token:     token,
+		Scopes:    granted,
+		ExpiresIn: int64(ttl / time.Second),
+	}, nil
+}
+
+func clientToProto(client models.Client) *authsvcv1.Client {
+	return &authsvcv1.Client{
+		ClientId:    client.ClientID,
+		AppId:       int32(client.AppID),
+		Name:        client.Name,
+		Permissions: client.Permissions,
+		PublicKey:   client.PublicKey,
+		CreatedAt:   client.CreatedAt.Unix(),
+	}
+}
+
+func clientsError(err error) error {
+	switch {
+	case errors.Is(err, clients.ErrorInvalidName):
+		return status.Error(codes.InvalidArgument, "name must be 1 to 64 characters")
+	case errors.Is(err, clients.ErrorInvalidPermission):
+		return status.Error(codes.InvalidArgument, "permission is not valid")
+	case errors.Is(err, clients.ErrorInvalidPublicKey):
+		return status.Error(codes.InvalidArgument, "public key is not valid")
+	case errors.Is(err, clients.ErrorInvalidAppID):
+		return status.Error(codes.InvalidArgument, "app id is not valid")
+	case errors.Is(err, clients.ErrorClientNotFound):
+		return status.Error(codes.NotFound, "client not found")
+	case errors.Is(err, clients.ErrorInvalidCredentials):
+		return status.Error(codes.Unauthenticated, "invalid client credentials")
+	case errors.Is(err, clients.ErrorScopeNotAllowed):
+		return status.Error(codes.PermissionDenied, "scope is not allowed")
+	}
+	return status.Error(codes.Internal, "internal error")
+}
@@ -0,0 +1,268 @@
+// Package extauthz serves the Envoy external authorization API, see
+// https://www.envoyproxy.io/docs/envoy/latest/api-v3/service/auth/v3/external_auth.proto
+//
+// Envoy asks the server whether to let a request through before routing it
+// upstream. Requests are authenticated by their bearer token and checked
+// against the policy of their route, allowed ones get identity headers.
+package extauthz
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/lib/jwt"
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	authorizationHeader = "authorization"
+	bearerPrefix        = "bearer "
+
+	// routeExtension is the context extension of Envoy routes naming
+	// the route policy, see ExtAuthzPerRoute
+	routeExtension = "route"
+
+	// Identity headers injected into allowed requests
+	userIDHeader   = "x-auth-user-id"
+	emailHeader    = "x-auth-email"
+	rolesHeader    = "x-auth-roles"
+	appIDHeader    = "x-auth-app-id"
+	orgIDHeader    = "x-auth-org-id"
+	clientIDHeader = "x-auth-client-id"
+)
+
+// identityHeaders are removed from requests which don't get them,
+// so clients can't pass their own
+var identityHeaders = []string{userIDHeader, emailHeader, rolesHeader, appIDHeader, orgIDHeader, clientIDHeader}
+
+type TokenVerifier interface {
+	VerifyToken(ctx context.Context, token string) (jwt.Claims, error)
+}
+
+// RoleProvider returns roles users currently hold in apps
+type RoleProvider interface {
+	UserRoles(ctx context.Context, userID int64, appID int) ([]models.Role, error)
+}
+
+// Route is the policy of requests of a route
+//
+// Envoy routes select the route by Name in the "route" context extension,
+// otherwise the first route whose PathPrefix and Methods match applies,
+// routes without PathPrefix are selected by name only.
+// Public routes let requests without a valid token through. AppID requires
+// tokens of the app, Roles one of the roles the user holds in the app of
+// the token, Scopes all of the scopes.
+// Requests of no route are denied unless a Default route applies to them.
+type Route struct {
+	Name       string
+	PathPrefix string
+	Methods    []string
+	Default    bool
+	Public     bool
+	AppID      int
+	Roles      []string
+	Scopes     []string
+}
+
+type ServerApi struct {
+	authv3.UnimplementedAuthorizationServer
+	log    *slog.Logger
+	tokens TokenVerifier
+	roles  RoleProvider
+	routes []Route
+}
+
+func Register(gRPC *grpc.Server, log *slog.Logger, tokens TokenVerifier, roles RoleProvider, routes []Route) {
+	authv3.RegisterAuthorizationServer(gRPC, &ServerApi{
+		log:    log,
+		tokens: tokens,
+		roles:  roles,
+		routes: routes,
+	})
+}
+
+// Check authorizes the HTTP request of the attributes
+//
+// Denials are answered with the HTTP response Envoy sends to the client,
+// not with a gRPC error, which Envoy would treat as a failure of the server.
+func (s *ServerApi) Check(ctx context.Context, req *authv3.CheckRequest) (*authv3.CheckResponse, error) {
+	const op = "extauthz.Check"
+	log := s.log.With(slog.String("operation", op))
+
+	request := req.GetAttributes().GetRequest().GetHttp()
+	path, _, _ := strings.Cut(request.GetPath(), "?")
+
+	route, ok := s.route(req.GetAttributes().GetContextExtensions()[routeExtension], request.GetMethod(), path)
+	if !ok {
+		log.Warn("route policy not found", slog.String("route", req.GetAttributes().GetContextExtensions()[routeExtension]))
+		return denied(codes.PermissionDenied, "route is not configured"), nil
+	}
+
+	token := bearerToken(request.GetHeaders())
+	if token == "" {
+		if route.Public {
+			return allowed(jwt.Claims{}), nil
+		}
+		return denied(codes.Unauthenticated, "authentication required"), nil
+	}
+	claims, err := s.tokens.VerifyToken(ctx, token)
+	if err != nil {
+		if route.Public {
+			return allowed(jwt.Claims{}), nil
+		}
+		log.Warn("invalid token", slog.String("path", path), "error", err)
+		return denied(codes.Unauthenticated, "invalid token"), nil
+	}
+
+	// roles of the token may have been revoked since it was issued
+	claims.Roles = nil
+	if claims.UserID != 0 {
+		roles, err := s.roles.UserRoles(ctx, claims.UserID, claims.AppID)
+		if err != nil {
+			log.Error("failed to get user roles", "error", err)
+			return denied(codes.Internal, "internal error"), nil
+		}
+		for _, role := range roles {
+			claims.Roles = append(claims.Roles, role.Name)
+		}
+	}
+
+	if !route.permits(claims) {
+		log.Warn("route policy denies request",
+			slog.String("path", path),
+			slog.Int64("userID", claims.UserID),
+			slog.Int("appID", claims.AppID),
+		)
+		return denied(codes.PermissionDenied, "access denied"), nil
+	}
+
+	return allowed(claims), nil
+}
+
+// route returns the route named name, or the first route matching
+// method and path if name is empty, the default route if none matches
+func (s *ServerApi) route(name string, method string, path string) (Route, bool) {
+	if name != "" {
+		i := slices.IndexFunc(s.routes, func(route Route) bool { return route.Name == name })
+		if i < 0 {
+			return Route{}, false
+		}
+		return s.routes[i], true
+	}
+
+	for _, route := range s.routes {
+		if route.PathPrefix == "" || !strings.HasPrefix(path, route.PathPrefix) {
+			continue
+		}
+		if len(route.Methods) > 0 && !slices.ContainsFunc(route.Methods, func(m string) bool {
+			return strings.EqualFold(m, method)
+		}) {
+			continue
+		}
+		return route, true
+	}
+
+	i := slices.IndexFunc(s.routes, func(route Route) bool { return route.Default })
+	if i < 0 {
+		return Route{}, false
+	}
+	return s.routes[i], true
+}
+
+// permits reports whether claims satisfy the route policy
+func (r Route) permits(claims jwt.Claims) bool {
+	if r.AppID != 0 && claims.AppID != r.AppID {
+		return false
+	}
+	if len(r.Roles) > 0 && !slices.ContainsFunc(r.Roles, func(role string) bool {
+		return slices.Contains(claims.Roles, role)
+	}) {
+		return false
+	}
+	for _, scope := range r.Scopes {
+		if !claims.Allows(scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// bearerToken returns token of the authorization header,
+// Envoy passes header names in lower case
+func bearerToken(headers map[string]string) string {
+	header := headers[authorizationHeader]
+	if len(header) < len(bearerPrefix) || !strings.EqualFold(header[:len(bearerPrefix)], bearerPrefix) {
+		return ""
+	}
+	return strings.TrimSpace(header[len(bearerPrefix):])
+}
+
+// allowed lets the request through with identity headers of claims,
+// identity headers it doesn't get are removed
+func allowed(claims jwt.Claims) *authv3.CheckResponse {
+	values := map[string]string{}
+	if claims.UserID != 0 {
+		values[userIDHeader] = strconv.FormatInt(claims.UserID, 10)
+		values[emailHeader] = claims.Email
+	}
+	if claims.ClientID != "" {
+		values[clientIDHeader] = claims.ClientID
+	}
+	if claims.AppID != 0 {
+		values[appIDHeader] = strconv.Itoa(claims.AppID)
+	}
+	if claims.OrgID != 0 {
+		values[orgIDHeader] = strconv.FormatInt(claims.OrgID, 10)
+	}
+	if len(claims.Roles) > 0 {
+		values[rolesHeader] = strings.Join(claims.Roles, ",")
+	}
+
+	resp := &authv3.OkHttpResponse{}
+	for _, name := range identityHeaders {
+		value, ok := values[name]
+		if !ok {
+			resp.HeadersToRemove = append(resp.HeadersToRemove, name)
+			continue
+		}
+		resp.Headers = append(resp.Headers, &corev3.HeaderValueOption{
+			Header:       &corev3.HeaderValue{Key: name, Value: value},
+			AppendAction: corev3.HeaderValueOption_OVERWRITE_IF_EXISTS_OR_ADD,
+		})
+	}
+
+	return &authv3.CheckResponse{
+		Status:       &rpcstatus.Status{Code: int32(codes.OK)},
+		HttpResponse: &authv3.CheckResponse_OkResponse{OkResponse: resp},
+	}
+}
+
+// denied answers the client with 401 if code is Unauthenticated,
+// with 403 otherwise
+func denied(code codes.Code, message string) *authv3.CheckResponse {
+	resp := &authv3.DeniedHttpResponse{
+		Status: &typev3.HttpStatus{Code: typev3.StatusCode_Forbidden},
+		Body:   message,
+	}
+	if code == codes.Unauthenticated {
+		resp.Status.Code = typev3.StatusCode_Unauthorized
+		resp.Headers = []*corev3.HeaderValueOption{{
+			Header:       &corev3.HeaderValue{Key: "www-authenticate", Value: "Bearer"},
+			AppendAction: corev3.HeaderValueOption_OVERWRITE_IF_EXISTS_OR_ADD,
+		}}
+	}
+
+	return &authv3.CheckResponse{
+		Status:       &rpcstatus.Status{Code: int32(code), Message: message},
+		HttpResponse: &authv3.CheckResponse_DeniedResponse{DeniedResponse: resp},
+	}
+}
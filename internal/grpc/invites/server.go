@@ -0,0 +1,124 @@
+package invites
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	authsvcv1 "github.com/Len4i/auth-service/gen/go/authsvc"
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/grpc/authn"
+	"github.com/Len4i/auth-service/internal/services/invites"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type Invites interface {
+	CreateInvite(
+		ctx context.Context,
+		createdBy int64,
+		appID int,
+		email string,
+		maxUses int,
+		ttl time.Duration,
+	) (invite models.Invite, code string, err error)
+	ListInvites(ctx context.Context, appID int, includeInactive bool) ([]models.Invite, error)
+	RevokeInvite(ctx context.Context, id int64) error
+}
+
+type ServerApi struct {
+	authsvcv1.UnimplementedInvitesServer
+	invites Invites
+	admins  authn.AdminChecker
+}
+
+func Register(gRPC *grpc.Server, invites Invites, admins authn.AdminChecker) {
+	authsvcv1.RegisterInvitesServer(gRPC, &ServerApi{
+		invites: invites,
+		admins:  admins,
+	})
+}
+
+func (s *ServerApi) CreateInvite(ctx context.Context, req *authsvcv1.CreateInviteRequest) (*authsvcv1.CreateInviteResponse, error) {
+	claims, err := authn.RequireAdmin(ctx, s.admins)
+	if err != nil {
+		return nil, err
+	}
+
+	invite, code, err := s.invites.CreateInvite(
+		ctx,
+		claims.UserID,
+		int(req.GetAppId()),
+		req.GetEmail(),
+		int(req.GetMaxUses()),
+		time.Duration(req.GetTtlSeconds())*time.Second,
+	)
+	if err != nil {
+		if errors.Is(err, invites.ErrorInvalidMaxUses) {
+			return nil, status.Error(codes.InvalidArgument, "max uses must not be negative")
+		}
+		if errors.Is(err, invites.ErrorInvalidTTL) {
+			return nil, status.Error(codes.InvalidArgument, "ttl must not be negative")
+		}
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &authsvcv1.CreateInviteResponse{
+		Invite: inviteToProto(invite),
+		Code:   code,
+	}, nil
+}
+
+func (s *ServerApi) ListInvites(ctx context.Context, req *authsvcv1.ListInvitesRequest) (*authsvcv1.ListInvitesResponse, error) {
+	if _, err := authn.RequireAdmin(ctx, s.admins); err != nil {
+		return nil, err
+	}
+
+	list, err := s.invites.ListInvites(ctx, int(req.GetAppId()), req.GetIncludeInactive())
+	if err != nil {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	resp := &authsvcv1.ListInvitesResponse{
+		Invites: make([]*authsvcv1.Invite, 0, len(list)),
+	}
+	for _, invite := range list {
+		resp.Invites = append(resp.Invites, inviteToProto(invite))
+	}
+
+	return resp, nil
+}
+
+func (s *ServerApi) RevokeInvite(ctx context.Context, req *authsvcv1.RevokeInviteRequest) (*authsvcv1.RevokeInviteResponse, error) {
+	if _, err := authn.RequireAdmin(ctx, s.admins); err != nil {
+		return nil, err
+	}
+
+	if req.GetId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+
+	if err := s.invites.RevokeInvite(ctx, req.GetId()); err != nil {
+		if errors.Is(err, invites.ErrorInviteNotFound) {
+			return nil, status.Error(codes.NotFound, "invite not found")
+		}
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &authsvcv1.RevokeInviteResponse{}, nil
+}
+
+func inviteToProto(invite models.Invite) *authsvcv1.Invite {
+	return &authsvcv1.Invite{
+		Id:        invite.ID,
+		AppId:     int32(invite.AppID),
+		Email:     invite.Email,
+		MaxUses:   int32(invite.MaxUses),
+		Uses:      int32(invite.Uses),
+		ExpiresAt: invite.ExpiresAt.Unix(),
+		Revoked:   invite.Revoked,
+		CreatedBy: invite.CreatedBy,
+		CreatedAt: invite.CreatedAt.Unix(),
+	}
+}
@@ -0,0 +1,207 @@
+package mfa
+
+import (
+	"context"
+	"errors"
+
+	authsvcv1 "github.com/Len4i/auth-service/gen/go/authsvc"
+	authgRPC "github.com/Len4i/auth-service/internal/grpc/auth"
+	"github.com/Len4i/auth-service/internal/grpc/authn"
+	"github.com/Len4i/auth-service/internal/lib/jwt"
+	"github.com/Len4i/auth-service/internal/services/auth"
+	"github.com/Len4i/auth-service/internal/services/mfa"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type MFA interface {
+	EnrollTOTP(ctx context.Context, userID int64, email string) (secret string, uri string, err error)
+	ConfirmTOTP(ctx context.Context, userID int64, code string) (recoveryCodes []string, err error)
+	DisableTOTP(ctx context.Context, userID int64, code string) error
+	Status(ctx context.Context, userID int64) (totpEnabled bool, recoveryCodesRemaining int, err error)
+	RegenerateRecoveryCodes(ctx context.Context, userID int64, code string) ([]string, error)
+}
+
+// Verifier completes two-step login and step-up of authenticated users
+type Verifier interface {
+	VerifyMFA(ctx context.Context, challenge string, code string) (token string, err error)
+	StepUp(ctx context.Context, claims jwt.Claims, code string) (token string, err error)
+}
+
+type ServerApi struct {
+	authsvcv1.UnimplementedMFAServer
+	mfa      MFA
+	verifier Verifier
+}
+
+func Register(gRPC *grpc.Server, mfa MFA, verifier Verifier) {
+	authsvcv1.RegisterMFAServer(gRPC, &ServerApi{
+		mfa:      mfa,
+		verifier: verifier,
+	})
+}
+
+func (s *ServerApi) EnrollTOTP(ctx context.Context, req *authsvcv1.EnrollTOTPRequest) (*authsvcv1.EnrollTOTPResponse, error) {
+	claims, err := authn.RequireLogin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, uri, err := s.mfa.EnrollTOTP(ctx, claims.UserID, claims.Email)
+	if err != nil {
+		return nil, mfaError(err)
+	}
+
+	return &authsvcv1.EnrollTOTPResponse{
+		Secret: secret,
+		Uri:    uri,
+	}, nil
+}
+
+func (s *ServerApi) ConfirmTOTP(ctx context.Context, req *authsvcv1.ConfirmTOTPRequest) (*authsvcv1.ConfirmTOTPResponse, error) {
+	claims, err := authn.RequireLogin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.GetCode() == "" {
+		return nil, status.Error(codes.InvalidArgument, "code is required")
+	}
+
+	recoveryCodes, err := s.mfa.ConfirmTOTP(ctx, claims.UserID, req.GetCode())
+	if err != nil {
+		return nil, mfaError(err)
+	}
+
+	return &authsvcv1.ConfirmTOTPResponse{
+		RecoveryCodes: recoveryCodes,
+	}, nil
+}
+
+func (s *ServerApi) DisableTOTP(ctx context.Context, req *authsvcv1.DisableTOTPRequest) (*authsvcv1.DisableTOTPResponse, error) {
+	claims, err := authn.RequireLogin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.GetCode() == "" {
+		return nil, status.Error(codes.InvalidArgument, "code is required")
+	}
+
+	if err := s.mfa.DisableTOTP(ctx, claims.UserID, req.GetCode()); err != nil {
+		return nil, mfaError(err)
+	}
+
+	return &authsvcv1.DisableTOTPResponse{}, nil
+}
+
+func (s *ServerApi) GetMFAStatus(ctx context.Context, req *authsvcv1.GetMFAStatusRequest) (*authsvcv1.GetMFAStatusResponse, error) {
+	claims, err := authn.RequireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	totpEnabled, remaining, err := s.mfa.Status(ctx, claims.UserID)
+	if err != nil {
+		return nil, mfaError(err)
+	}
+
+	return &authsvcv1.GetMFAStatusResponse{
+		TotpEnabled:            totpEnabled,
+		RecoveryCodesRemaining: int32(remaining),
+	}, nil
+}
+
+func (s *ServerApi) RegenerateRecoveryCodes(
+	ctx context.Context,
+	req *authsvcv1.RegenerateRecoveryCodesRequest,
+) (*authsvcv1.RegenerateRecoveryCodesResponse, error) {
+	claims, err := authn.RequireLogin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.GetCode() == "" {
+		return nil, status.Error(codes.InvalidArgument, "code is required")
+	}
+
+	recoveryCodes, err := s.mfa.RegenerateRecoveryCodes(ctx, claims.UserID, req.GetCode())
+	if err != nil {
+		return nil, mfaError(err)
+	}
+
+	return &authsvcv1.RegenerateRecoveryCodesResponse{
+		RecoveryCodes: recoveryCodes,
+	}, nil
+}
+
+func (s *ServerApi) VerifyMFA(ctx context.Context, req *authsvcv1.VerifyMFARequest) (*authsvcv1.VerifyMFAResponse, error) {
+	if req.GetChallenge() == "" {
+		return nil, status.Error(codes.InvalidArgument, "challenge is required")
+	}
+	if req.GetCode() == "" {
+		return nil, status.Error(codes.InvalidArgument, "code is required")
+	}
+
+	token, err := s.verifier.VerifyMFA(ctx, req.GetChallenge(), req.GetCode())
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrorInvalidChallenge):
+			return nil, status.Error(codes.Unauthenticated, "challenge is not valid")
+		case errors.Is(err, auth.ErrorInvalidMFACode):
+			return nil, status.Error(codes.Unauthenticated, "code is not valid")
+		}
+		if st, ok := authgRPC.OrgPolicyStatus(err); ok {
+			return nil, st
+		}
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &authsvcv1.VerifyMFAResponse{
+		Token: token,
+	}, nil
+}
+
+func (s *ServerApi) StepUp(ctx context.Context, req *authsvcv1.StepUpRequest) (*authsvcv1.StepUpResponse, error) {
+	claims, err := authn.RequireLogin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := s.verifier.StepUp(ctx, claims, req.GetCode())
+	if err != nil {
+		var mfaErr *auth.MFARequiredError
+		switch {
+		case errors.As(err, &mfaErr):
+			return nil, authgRPC.MFARequiredStatus(mfaErr)
+		case errors.Is(err, auth.ErrorMFANotEnabled):
+			return nil, status.Error(codes.FailedPrecondition, "second factor is not enabled")
+		case errors.Is(err, auth.ErrorInvalidMFACode):
+			return nil, status.Error(codes.Unauthenticated, "code is not valid")
+		case errors.Is(err, auth.ErrorTooManyMFAAttempts):
+			return nil, status.Error(codes.ResourceExhausted, "too many attempts, try again later")
+		}
+		if st, ok := authgRPC.OrgPolicyStatus(err); ok {
+			return nil, st
+		}
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &authsvcv1.StepUpResponse{
+		Token: token,
+	}, nil
+}
+
+func mfaError(err error) error {
+	switch {
+	case errors.Is(err, mfa.ErrorMFANotConfigured):
+		return status.Error(codes.FailedPrecondition, "mfa is not configured")
+	case errors.Is(err, mfa.ErrorMFAAlreadyEnabled):
+		return status.Error(codes.AlreadyExists, "mfa is already enabled")
+	case errors.Is(err, mfa.ErrorMFANotEnrolled):
+		return status.Error(codes.FailedPrecondition, "mfa is not enrolled")
+	case errors.Is(err, mfa.ErrorInvalidCode):
+		return status.Error(codes.InvalidArgument, "code is not valid")
+	case errors.Is(err, mfa.ErrorTooManyAttempts):
+		return status.Error(codes.ResourceExhausted, "too many attempts, try again later")
+	}
+	return status.Error(codes.Internal, "internal error")
+}
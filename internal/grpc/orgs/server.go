@@ -0,0 +1,591 @@
+package orgs
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	authsvcv1 "github.com/Len4i/auth-service/gen/go/authsvc"
+	"github.com/Len4i/auth-service/internal/domain/models"
+	authgRPC "github.com/Len4i/auth-service/internal/grpc/auth"
+	"github.com/Len4i/auth-service/internal/grpc/authn"
+	"github.com/Len4i/auth-service/internal/lib/jwt"
+	"github.com/Len4i/auth-service/internal/services/orgs"
+	"github.com/Len4i/auth-service/internal/services/provisioning"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type Orgs interface {
+	CreateOrg(ctx context.Context, org models.Org, ownerID int64) (models.Org, error)
+	Org(ctx context.Context, id int64) (models.Org, error)
+	ListOrgs(ctx context.Context, userID int64) ([]models.Org, error)
+	UpdateOrg(ctx context.Context, org models.Org) (models.Org, error)
+	DeleteOrg(ctx context.Context, id int64) error
+	MemberRole(ctx context.Context, orgID int64, userID int64) (string, error)
+	ListMembers(ctx context.Context, orgID int64) ([]models.OrgMember, error)
+	AddMember(ctx context.Context, orgID int64, userID int64, role string) error
+	RemoveMember(ctx context.Context, orgID int64, userID int64) error
+	CreateInvite(
+		ctx context.Context,
+		createdBy int64,
+		orgID int64,
+		email string,
+		role string,
+		ttl time.Duration,
+	) (models.OrgInvite, string, error)
+	ListInvites(ctx context.Context, orgID int64, includeInactive bool) ([]models.OrgInvite, error)
+	RevokeInvite(ctx context.Context, orgID int64, id int64) error
+	AcceptInvite(ctx context.Context, userID int64, email string, code string) (models.OrgInvite, error)
+}
+
+// Switcher reissues tokens scoped to another org
+type Switcher interface {
+	SwitchOrg(ctx context.Context, claims jwt.Claims, orgID int64) (token string, err error)
+}
+
+// SCIMTokens manages tokens orgs provision users over SCIM with
+type SCIMTokens interface {
+	CreateToken(ctx context.Context, orgID int64, name string, createdBy int64) (models.SCIMToken, string, error)
+	ListTokens(ctx context.Context, orgID int64, includeRevoked bool) ([]models.SCIMToken, error)
+	RevokeToken(ctx context.Context, orgID int64, id int64) error
+}
+
+type ServerApi struct {
+	authsvcv1.UnimplementedOrgsServer
+	orgs       Orgs
+	switcher   Switcher
+	scimTokens SCIMTokens
+	admins     authn.AdminChecker
+}
+
+func Register(gRPC *grpc.Server, orgs Orgs, switcher Switcher, scimTokens SCIMTokens, admins authn.AdminChecker) {
+	authsvcv1.RegisterOrgsServer(gRPC, &ServerApi{
+		orgs:       orgs,
+		switcher:   switcher,
+		scimTokens: scimTokens,
+		admins:     admins,
+	})
+}
+
+func (s *ServerApi) CreateOrg(ctx context.Context, req *authsvcv1.CreateOrgRequest) (*authsvcv1.CreateOrgResponse, error) {
+	if _, err := authn.RequireAdmin(ctx, s.admins); err != nil {
+		return nil, err
+	}
+
+	org := orgFromProto(req.GetName(), req.GetAllowedApps(), req.GetLoginPolicy())
+	org.Slug = req.GetSlug()
+
+	org, err := s.orgs.CreateOrg(ctx, org, req.GetOwnerUserId())
+	if err != nil {
+		return nil, orgsError(err)
+	}
+
+	return &authsvcv1.CreateOrgResponse{
+		Org: orgToProto(org),
+	}, nil
+}
+
+func (s *ServerApi) GetOrg(ctx context.Context, req *authsvcv1.GetOrgRequest) (*authsvcv1.GetOrgResponse, error) {
+	if _, _, err := s.requireMember(ctx, req.GetOrgId()); err != nil {
+		return nil, err
+	}
+
+	org, err := s.orgs.Org(ctx, req.GetOrgId())
+	if err != nil {
+		return nil, orgsError(err)
+	}
+
+	return &authsvcv1.GetOrgResponse{
+		Org: orgToProto(org),
+	}, nil
+}
+
+func (s *ServerApi) ListOrgs(ctx context.Context, req *authsvcv1.ListOrgsRequest) (*authsvcv1.ListOrgsResponse, error) {
+	claims, err := authn.RequireLogin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	userID := claims.UserID
+	if req.GetAll() {
+		if _, err := authn.RequireAdmin(ctx, s.admins); err != nil {
+			return nil, err
+		}
+		userID = 0
+	}
+
+	list, err := s.orgs.ListOrgs(ctx, userID)
+	if err != nil {
+		return nil, orgsError(err)
+	}
+
+	resp := &authsvcv1.ListOrgsResponse{
+		Orgs: make([]*authsvcv1.Org, 0, len(list)),
+	}
+	for _, org := range list {
+		resp.Orgs = append(resp.Orgs, orgToProto(org))
+	}
+
+	return resp, nil
+}
+
+func (s *ServerApi) UpdateOrg(ctx context.Context, req *authsvcv1.UpdateOrgRequest) (*authsvcv1.UpdateOrgResponse, error) {
+	if _, _, err := s.requireManager(ctx, req.GetOrgId()); err != nil {
+		return nil, err
+	}
+
+	org := orgFromProto(req.GetName(), req.GetAllowedApps(), req.GetLoginPolicy())
+	org.ID = req.GetOrgId()
+
+	org, err := s.orgs.UpdateOrg(ctx, org)
+	if err != nil {
+		return nil, orgsError(err)
+	}
+
+	return &authsvcv1.UpdateOrgResponse{
+		Org: orgToProto(org),
+	}, nil
+}
+
+func (s *ServerApi) DeleteOrg(ctx context.Context, req *authsvcv1.DeleteOrgRequest) (*authsvcv1.DeleteOrgResponse, error) {
+	if _, err := authn.RequireAdmin(ctx, s.admins); err != nil {
+		return nil, err
+	}
+
+	if err := s.orgs.DeleteOrg(ctx, req.GetOrgId()); err != nil {
+		return nil, orgsError(err)
+	}
+
+	return &authsvcv1.DeleteOrgResponse{}, nil
+}
+
+func (s *ServerApi) ListMembers(ctx context.Context, req *authsvcv1.ListMembersRequest) (*authsvcv1.ListMembersResponse, error) {
+	if _, _, err := s.requireMember(ctx, req.GetOrgId()); err != nil {
+		return nil, err
+	}
+
+	members, err := s.orgs.ListMembers(ctx, req.GetOrgId())
+	if err != nil {
+		return nil, orgsError(err)
+	}
+
+	resp := &authsvcv1.ListMembersResponse{
+		Members: make([]*authsvcv1.OrgMember, 0, len(members)),
+	}
+	for _, member := range members {
+		resp.Members = append(resp.Members, &authsvcv1.OrgMember{
+			UserId:    member.UserID,
+			Email:     member.Email,
+			Role:      member.Role,
+			CreatedAt: member.CreatedAt.Unix(),
+		})
+	}
+
+	return resp, nil
+}
+
+func (s *ServerApi) AddMember(ctx context.Context, req *authsvcv1.AddMemberRequest) (*authsvcv1.AddMemberResponse, error) {
+	_, role, err := s.requireManager(ctx, req.GetOrgId())
+	if err != nil {
+		return nil, err
+	}
+	if req.GetUserId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+	if err := requireOwnerToGrant(role, req.GetRole()); err != nil {
+		return nil, err
+	}
+	if err := s.requireOwnerToChangeOwner(ctx, req.GetOrgId(), role, req.GetUserId()); err != nil {
+		return nil, err
+	}
+
+	if err := s.orgs.AddMember(ctx, req.GetOrgId(), req.GetUserId(), req.GetRole()); err != nil {
+		return nil, orgsError(err)
+	}
+
+	return &authsvcv1.AddMemberResponse{}, nil
+}
+
+func (s *ServerApi) RemoveMember(ctx context.Context, req *authsvcv1.RemoveMemberRequest) (*authsvcv1.RemoveMemberResponse, error) {
+	claims, role, err := s.requireMember(ctx, req.GetOrgId())
+	if err != nil {
+		return nil, err
+	}
+	if req.GetUserId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+	// members may leave the org on their own
+	if req.GetUserId() != claims.UserID && !canManage(role) {
+		return nil, status.Error(codes.PermissionDenied, "org admin permission required")
+	}
+	if err := s.requireOwnerToChangeOwner(ctx, req.GetOrgId(), role, req.GetUserId()); err != nil {
+		return nil, err
+	}
+
+	if err := s.orgs.RemoveMember(ctx, req.GetOrgId(), req.GetUserId()); err != nil {
+		return nil, orgsError(err)
+	}
+
+	return &authsvcv1.RemoveMemberResponse{}, nil
+}
+
+func (s *ServerApi) CreateOrgInvite(
+	ctx context.Context,
+	req *authsvcv1.CreateOrgInviteRequest,
+) (*authsvcv1.CreateOrgInviteResponse, error) {
+	claims, role, err := s.requireManager(ctx, req.GetOrgId())
+	if err != nil {
+		return nil, err
+	}
+	inviteRole := req.GetRole()
+	if inviteRole == "" {
+		inviteRole = models.OrgRoleMember
+	}
+	if err := requireOwnerToGrant(role, inviteRole); err != nil {
+		return nil, err
+	}
+
+	ttl := time.Duration(req.GetTtlSeconds()) * time.Second
+	invite, code, err := s.orgs.CreateInvite(ctx, claims.UserID, req.GetOrgId(), req.GetEmail(), inviteRole, ttl)
+	if err != nil {
+		return nil, orgsError(err)
+	}
+
+	return &authsvcv1.CreateOrgInviteResponse{
+		Invite: inviteToProto(invite),
+		Code:   code,
+	}, nil
+}
+
+func (s *ServerApi) ListOrgInvites(
+	ctx context.Context,
+	req *authsvcv1.ListOrgInvitesRequest,
+) (*authsvcv1.ListOrgInvitesResponse, error) {
+	if _, _, err := s.requireManager(ctx, req.GetOrgId()); err != nil {
+		return nil, err
+	}
+
+	invites, err := s.orgs.ListInvites(ctx, req.GetOrgId(), req.GetIncludeInactive())
+	if err != nil {
+		return nil, orgsError(err)
+	}
+
+	resp := &authsvcv1.ListOrgInvitesResponse{
+		Invites: make([]*authsvcv1.OrgInvite, 0, len(invites)),
+	}
+	for _, invite := range invites {
+		resp.Invites = append(resp.Invites, inviteToProto(invite))
+	}
+
+	return resp, nil
+}
+
+func (s *ServerApi) RevokeOrgInvite(
+	ctx context.Context,
+	req *authsvcv1.RevokeOrgInviteRequest,
+) (*authsvcv1.RevokeOrgInviteResponse, error) {
+	if _, _, err := s.requireManager(ctx, req.GetOrgId()); err != nil {
+		return nil, err
+	}
+	if req.GetId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+
+	if err := s.orgs.RevokeInvite(ctx, req.GetOrgId(), req.GetId()); err != nil {
+		return nil, orgsError(err)
+	}
+
+	return &authsvcv1.RevokeOrgInviteResponse{}, nil
+}
+
+func (s *ServerApi) AcceptOrgInvite(
+	ctx context.Context,
+	req *authsvcv1.AcceptOrgInviteRequest,
+) (*authsvcv1.AcceptOrgInviteResponse, error) {
+	claims, err := authn.RequireLogin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.GetCode() == "" {
+		return nil, status.Error(codes.InvalidArgument, "code is required")
+	}
+
+	invite, err := s.orgs.AcceptInvite(ctx, claims.UserID, claims.Email, req.GetCode())
+	if err != nil {
+		return nil, orgsError(err)
+	}
+
+	return &authsvcv1.AcceptOrgInviteResponse{
+		OrgId: invite.OrgID,
+		Role:  invite.Role,
+	}, nil
+}
+
+func (s *ServerApi) SwitchOrg(ctx context.Context, req *authsvcv1.SwitchOrgRequest) (*authsvcv1.SwitchOrgResponse, error) {
+	claims, err := authn.RequireLogin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := s.switcher.SwitchOrg(ctx, claims, req.GetOrgId())
+	if err != nil {
+		if st, ok := authgRPC.OrgPolicyStatus(err); ok {
+			return nil, st
+		}
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &authsvcv1.SwitchOrgResponse{
+		Token: token,
+	}, nil
+}
+
+func (s *ServerApi) CreateSCIMToken(
+	ctx context.Context,
+	req *authsvcv1.CreateSCIMTokenRequest,
+) (*authsvcv1.CreateSCIMTokenResponse, error) {
+	claims, _, err := s.requireManager(ctx, req.GetOrgId())
+	if err != nil {
+		return nil, err
+	}
+
+	scimToken, token, err := s.scimTokens.CreateToken(ctx, req.GetOrgId(), req.GetName(), claims.UserID)
+	if err != nil {
+		return nil, scimTokensError(err)
+	}
+
+	return &authsvcv1.CreateSCIMTokenResponse{
+		ScimToken: scimTokenToProto(scimToken),
+		Token:     token,
+	}, nil
+}
+
+func (s *ServerApi) ListSCIMTokens(
+	ctx context.Context,
+	req *authsvcv1.ListSCIMTokensRequest,
+) (*authsvcv1.ListSCIMTokensResponse, error) {
+	if _, _, err := s.requireManager(ctx, req.GetOrgId()); err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.scimTokens.ListTokens(ctx, req.GetOrgId(), req.GetIncludeRevoked())
+	if err != nil {
+		return nil, scimTokensError(err)
+	}
+
+	resp := &authsvcv1.ListSCIMTokensResponse{
+		ScimTokens: make([]*authsvcv1.SCIMToken, 0, len(tokens)),
+	}
+	for _, token := range tokens {
+		resp.ScimTokens = append(resp.ScimTokens, scimTokenToProto(token))
+	}
+
+	return resp, nil
+}
+
+func (s *ServerApi) RevokeSCIMToken(
+	ctx context.Context,
+	req *authsvcv1.RevokeSCIMTokenRequest,
+) (*authsvcv1.RevokeSCIMTokenResponse, error) {
+	if _, _, err := s.requireManager(ctx, req.GetOrgId()); err != nil {
+		return nil, err
+	}
+	if req.GetId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+
+	if err := s.scimTokens.RevokeToken(ctx, req.GetOrgId(), req.GetId()); err != nil {
+		return nil, scimTokensError(err)
+	}
+
+	return &authsvcv1.RevokeSCIMTokenResponse{}, nil
+}
+
+// requireMember returns claims of the caller and its role in the org,
+// the caller must be a member or an admin
+//
+// Admins act as owners of every org. Orgs are managed by login only,
+// api keys can't act as members.
+func (s *ServerApi) requireMember(ctx context.Context, orgID int64) (jwt.Claims, string, error) {
+	claims, err := authn.RequireLogin(ctx)
+	if err != nil {
+		return jwt.Claims{}, "", err
+	}
+	if orgID == 0 {
+		return jwt.Claims{}, "", status.Error(codes.InvalidArgument, "org_id is required")
+	}
+
+	role, err := s.orgs.MemberRole(ctx, orgID, claims.UserID)
+	if err != nil && !errors.Is(err, orgs.ErrorMemberNotFound) {
+		return jwt.Claims{}, "", status.Error(codes.Internal, "internal error")
+	}
+
+	if role != models.OrgRoleOwner {
+		isAdmin, err := s.admins.IsAdmin(ctx, claims.UserID)
+		if err != nil {
+			return jwt.Claims{}, "", status.Error(codes.Internal, "internal error")
+		}
+		if isAdmin {
+			role = models.OrgRoleOwner
+		}
+	}
+	if role == "" {
+		return jwt.Claims{}, "", status.Error(codes.PermissionDenied, "not a member of the org")
+	}
+
+	return claims, role, nil
+}
+
+// requireManager returns claims of the caller and its role in the org,
+// the caller must be an owner or admin of the org or an admin
+func (s *ServerApi) requireManager(ctx context.Context, orgID int64) (jwt.Claims, string, error) {
+	claims, role, err := s.requireMember(ctx, orgID)
+	if err != nil {
+		return jwt.Claims{}, "", err
+	}
+	if !canManage(role) {
+		return jwt.Claims{}, "", status.Error(codes.PermissionDenied, "org admin permission required")
+	}
+
+	return claims, role, nil
+}
+
+func canManage(role string) bool {
+	return role == models.OrgRoleOwner || role == models.OrgRoleAdmin
+}
+
+// requireOwnerToGrant prevents org admins from making new owners
+func requireOwnerToGrant(callerRole string, role string) error {
+	if role == models.OrgRoleOwner && callerRole != models.OrgRoleOwner {
+		return status.Error(codes.PermissionDenied, "org owner permission required")
+	}
+	return nil
+}
+
+// requireOwnerToChangeOwner prevents org admins from demoting or removing
+// owners of the org
+func (s *ServerApi) requireOwnerToChangeOwner(ctx context.Context, orgID int64, callerRole string, userID int64) error {
+	if callerRole == models.OrgRoleOwner {
+		return nil
+	}
+
+	role, err := s.orgs.MemberRole(ctx, orgID, userID)
+	if err != nil {
+		if errors.Is(err, orgs.ErrorMemberNotFound) {
+			return nil
+		}
+		return status.Error(codes.Internal, "internal error")
+	}
+	if role == models.OrgRoleOwner {
+		return status.Error(codes.PermissionDenied, "org owner permission required")
+	}
+	return nil
+}
+
+func orgFromProto(name string, allowedApps []int32, policy *authsvcv1.OrgLoginPolicy) models.Org {
+	org := models.Org{
+		Name: name,
+		LoginPolicy: models.OrgLoginPolicy{
+			RequireMFA:     policy.GetRequireMfa(),
+			AllowedMethods: policy.GetAllowedMethods(),
+		},
+	}
+	for _, appID := range allowedApps {
+		org.AllowedApps = append(org.AllowedApps, int(appID))
+	}
+	return org
+}
+
+func orgToProto(org models.Org) *authsvcv1.Org {
+	allowedApps := make([]int32, 0, len(org.AllowedApps))
+	for _, appID := range org.AllowedApps {
+		allowedApps = append(allowedApps, int32(appID))
+	}
+
+	return &authsvcv1.Org{
+		Id:          org.ID,
+		Slug:        org.Slug,
+		Name:        org.Name,
+		AllowedApps: allowedApps,
+		LoginPolicy: &authsvcv1.OrgLoginPolicy{
+			RequireMfa:     org.LoginPolicy.RequireMFA,
+			AllowedMethods: org.LoginPolicy.AllowedMethods,
+		},
+		CreatedAt: org.CreatedAt.Unix(),
+	}
+}
+
+func inviteToProto(invite models.OrgInvite) *authsvcv1.OrgInvite {
+	return &authsvcv1.OrgInvite{
+		Id:         invite.ID,
+		OrgId:      invite.OrgID,
+		Email:      invite.Email,
+		Role:       invite.Role,
+		ExpiresAt:  invite.ExpiresAt.Unix(),
+		AcceptedBy: invite.AcceptedBy,
+		Revoked:    invite.Revoked,
+		CreatedBy:  invite.CreatedBy,
+		CreatedAt:  invite.CreatedAt.Unix(),
+	}
+}
+
+func orgsError(err error) error {
+	switch {
+	case errors.Is(err, orgs.ErrorInvalidSlug):
+		return status.Error(codes.InvalidArgument, "slug is not valid")
+	case errors.Is(err, orgs.ErrorInvalidName):
+		return status.Error(codes.InvalidArgument, "name is not valid")
+	case errors.Is(err, orgs.ErrorInvalidMethod):
+		return status.Error(codes.InvalidArgument, "authentication method is not valid")
+	case errors.Is(err, orgs.ErrorInvalidRole):
+		return status.Error(codes.InvalidArgument, "role is not valid")
+	case errors.Is(err, orgs.ErrorInvalidAppID):
+		return status.Error(codes.InvalidArgument, "app id is not valid")
+	case errors.Is(err, orgs.ErrorInvalidTTL):
+		return status.Error(codes.InvalidArgument, "ttl is not valid")
+	case errors.Is(err, orgs.ErrorUserNotFound):
+		return status.Error(codes.NotFound, "user not found")
+	case errors.Is(err, orgs.ErrorOrgNotFound):
+		return status.Error(codes.NotFound, "org not found")
+	case errors.Is(err, orgs.ErrorOrgExists):
+		return status.Error(codes.AlreadyExists, "org already exists")
+	case errors.Is(err, orgs.ErrorMemberNotFound):
+		return status.Error(codes.NotFound, "org member not found")
+	case errors.Is(err, orgs.ErrorInviteNotFound):
+		return status.Error(codes.NotFound, "invite not found")
+	case errors.Is(err, orgs.ErrorInvalidInvite):
+		return status.Error(codes.PermissionDenied, "invite code is not valid")
+	case errors.Is(err, orgs.ErrorLastOwnerRemoval):
+		return status.Error(codes.FailedPrecondition, "last owner can't be removed")
+	}
+	return status.Error(codes.Internal, "internal error")
+}
+
+func scimTokenToProto(token models.SCIMToken) *authsvcv1.SCIMToken {
+	resp := &authsvcv1.SCIMToken{
+		Id:        token.ID,
+		OrgId:     token.OrgID,
+		Name:      token.Name,
+		CreatedBy: token.CreatedBy,
+		CreatedAt: token.CreatedAt.Unix(),
+		Revoked:   token.Revoked,
+	}
+	if !token.LastUsedAt.IsZero() {
+		resp.LastUsedAt = token.LastUsedAt.Unix()
+	}
+	return resp
+}
+
+func scimTokensError(err error) error {
+	switch {
+	case errors.Is(err, provisioning.ErrorInvalidName):
+		return status.Error(codes.InvalidArgument, "name is not valid")
+	case errors.Is(err, provisioning.ErrorOrgNotFound):
+		return status.Error(codes.NotFound, "org not found")
+	case errors.Is(err, provisioning.ErrorTokenNotFound):
+		return status.Error(codes.NotFound, "scim token not found")
+	}
+	return status.Error(codes.Internal, "internal error")
+}
@@ -0,0 +1,99 @@
+package passwordless
+
+import (
+	"context"
+	"errors"
+	"net/mail"
+
+	authsvcv1 "github.com/Len4i/auth-service/gen/go/authsvc"
+	authgRPC "github.com/Len4i/auth-service/internal/grpc/auth"
+	"github.com/Len4i/auth-service/internal/services/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const emptyAppID = 0
+
+type Passwordless interface {
+	RequestLoginCode(ctx context.Context, email string, appID int) error
+	RedeemLoginCode(ctx context.Context, email string, code string, linkToken string) (token string, err error)
+}
+
+type ServerApi struct {
+	authsvcv1.UnimplementedPasswordlessServer
+	passwordless Passwordless
+}
+
+func Register(gRPC *grpc.Server, passwordless Passwordless) {
+	authsvcv1.RegisterPasswordlessServer(gRPC, &ServerApi{
+		passwordless: passwordless,
+	})
+}
+
+func (s *ServerApi) RequestLoginCode(
+	ctx context.Context,
+	req *authsvcv1.RequestLoginCodeRequest,
+) (*authsvcv1.RequestLoginCodeResponse, error) {
+	if err := validateEmail(req.GetEmail()); err != nil {
+		return nil, err
+	}
+	if req.GetAppId() == emptyAppID {
+		return nil, status.Error(codes.InvalidArgument, "app_id is required")
+	}
+
+	if err := s.passwordless.RequestLoginCode(ctx, req.GetEmail(), int(req.GetAppId())); err != nil {
+		return nil, passwordlessError(err)
+	}
+
+	return &authsvcv1.RequestLoginCodeResponse{}, nil
+}
+
+func (s *ServerApi) RedeemLoginCode(
+	ctx context.Context,
+	req *authsvcv1.RedeemLoginCodeRequest,
+) (*authsvcv1.RedeemLoginCodeResponse, error) {
+	if req.GetLinkToken() == "" {
+		if err := validateEmail(req.GetEmail()); err != nil {
+			return nil, err
+		}
+		if req.GetCode() == "" {
+			return nil, status.Error(codes.InvalidArgument, "code is required")
+		}
+	}
+
+	token, err := s.passwordless.RedeemLoginCode(ctx, req.GetEmail(), req.GetCode(), req.GetLinkToken())
+	if err != nil {
+		var mfaErr *auth.MFARequiredError
+		if errors.As(err, &mfaErr) {
+			return nil, authgRPC.MFARequiredStatus(mfaErr)
+		}
+		return nil, passwordlessError(err)
+	}
+
+	return &authsvcv1.RedeemLoginCodeResponse{
+		Token: token,
+	}, nil
+}
+
+func validateEmail(email string) error {
+	if email == "" {
+		return status.Error(codes.InvalidArgument, "email is required")
+	}
+	if _, err := mail.ParseAddress(email); err != nil {
+		return status.Error(codes.InvalidArgument, "email is not valid")
+	}
+	return nil
+}
+
+func passwordlessError(err error) error {
+	switch {
+	case errors.Is(err, auth.ErrorPasswordlessNotConfigured):
+		return status.Error(codes.FailedPrecondition, "passwordless login is not configured")
+	case errors.Is(err, auth.ErrorInvalidAppID):
+		return status.Error(codes.InvalidArgument, "app id is not valid")
+	case errors.Is(err, auth.ErrorInvalidLoginCode):
+		return status.Error(codes.Unauthenticated, "code is not valid")
+	}
+	return status.Error(codes.Internal, "internal error")
+}
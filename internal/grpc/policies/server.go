@@ -0,0 +1,260 @@
+package policies
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	authsvcv1 "github.com/Len4i/auth-service/gen/go/authsvc"
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/grpc/authn"
+	"github.com/Len4i/auth-service/internal/lib/jwt"
+	"github.com/Len4i/auth-service/internal/lib/policy"
+	"github.com/Len4i/auth-service/internal/services/policies"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type Policies interface {
+	CreatePolicy(ctx context.Context, policy models.Policy) (models.Policy, error)
+	UpdatePolicy(ctx context.Context, policy models.Policy) (models.Policy, error)
+	ListPolicies(ctx context.Context, appID int) ([]models.Policy, error)
+	DeletePolicy(ctx context.Context, appID int, name string) error
+	CheckAccess(ctx context.Context, appID int, in policy.Input) (policies.Decision, error)
+	Evaluate(ctx context.Context, appID int, expression string, in policy.Input) (policies.Decision, error)
+}
+
+type ServerApi struct {
+	authsvcv1.UnimplementedPoliciesServer
+	policies Policies
+	admins   authn.AdminChecker
+}
+
+func Register(gRPC *grpc.Server, policies Policies, admins authn.AdminChecker) {
+	authsvcv1.RegisterPoliciesServer(gRPC, &ServerApi{
+		policies: policies,
+		admins:   admins,
+	})
+}
+
+func (s *ServerApi) CreatePolicy(
+	ctx context.Context,
+	req *authsvcv1.CreatePolicyRequest,
+) (*authsvcv1.CreatePolicyResponse, error) {
+	if _, err := authn.RequireAdmin(ctx, s.admins); err != nil {
+		return nil, err
+	}
+
+	pol, err := s.policies.CreatePolicy(ctx, models.Policy{
+		AppID:       int(req.GetAppId()),
+		Name:        req.GetName(),
+		Description: req.GetDescription(),
+		Effect:      req.GetEffect(),
+		Expression:  req.GetExpression(),
+	})
+	if err != nil {
+		return nil, policiesError(err)
+	}
+
+	return &authsvcv1.CreatePolicyResponse{
+		Policy: policyToProto(pol),
+	}, nil
+}
+
+func (s *ServerApi) UpdatePolicy(
+	ctx context.Context,
+	req *authsvcv1.UpdatePolicyRequest,
+) (*authsvcv1.UpdatePolicyResponse, error) {
+	if _, err := authn.RequireAdmin(ctx, s.admins); err != nil {
+		return nil, err
+	}
+
+	pol, err := s.policies.UpdatePolicy(ctx, models.Policy{
+		AppID:       int(req.GetAppId()),
+		Name:        req.GetName(),
+		Description: req.GetDescription(),
+		Effect:      req.GetEffect(),
+		Expression:  req.GetExpression(),
+	})
+	if err != nil {
+		return nil, policiesError(err)
+	}
+
+	return &authsvcv1.UpdatePolicyResponse{
+		Policy: policyToProto(pol),
+	}, nil
+}
+
+func (s *ServerApi) ListPolicies(
+	ctx context.Context,
+	req *authsvcv1.ListPoliciesRequest,
+) (*authsvcv1.ListPoliciesResponse, error) {
+	if _, err := authn.RequireAdmin(ctx, s.admins); err != nil {
+		return nil, err
+	}
+
+	list, err := s.policies.ListPolicies(ctx, int(req.GetAppId()))
+	if err != nil {
+		return nil, policiesError(err)
+	}
+
+	resp := &authsvcv1.ListPoliciesResponse{
+		Policies: make([]*authsvcv1.Policy, 0, len(list)),
+	}
+	for _, pol := range list {
+		resp.Policies = append(resp.Policies, policyToProto(pol))
+	}
+
+	return resp, nil
+}
+
+func (s *ServerApi) DeletePolicy(
+	ctx context.Context,
+	req *authsvcv1.DeletePolicyRequest,
+) (*authsvcv1.DeletePolicyResponse, error) {
+	if _, err := authn.RequireAdmin(ctx, s.admins); err != nil {
+		return nil, err
+	}
+	if req.GetName() == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+
+	if err := s.policies.DeletePolicy(ctx, int(req.GetAppId()), req.GetName()); err != nil {
+		return nil, policiesError(err)
+	}
+
+	return &authsvcv1.DeletePolicyResponse{}, nil
+}
+
+func (s *ServerApi) CheckAccess(
+	ctx context.Context,
+	req *authsvcv1.CheckAccessRequest,
+) (*authsvcv1.CheckAccessResponse, error) {
+	claims, err := authn.RequireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.GetAction() == "" {
+		return nil, status.Error(codes.InvalidArgument, "action is required")
+	}
+
+	appID := int(req.GetAppId())
+	if appID == 0 {
+		appID = claims.AppID
+	}
+
+	decision, err := s.policies.CheckAccess(ctx, appID, policy.Input{
+		Subject:  subject(claims),
+		Resource: req.GetResource().AsMap(),
+		Action:   req.GetAction(),
+		Context:  req.GetContext().AsMap(),
+		Now:      time.Now(),
+	})
+	if err != nil {
+		return nil, policiesError(err)
+	}
+
+	return &authsvcv1.CheckAccessResponse{
+		// api keys are allowed only actions within their scopes
+		Allowed: decision.Allowed && claims.Allows(req.GetAction()),
+		Policy:  decision.Policy,
+	}, nil
+}
+
+func (s *ServerApi) EvaluatePolicies(
+	ctx context.Context,
+	req *authsvcv1.EvaluatePoliciesRequest,
+) (*authsvcv1.EvaluatePoliciesResponse, error) {
+	if _, err := authn.RequireAdmin(ctx, s.admins); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if req.GetNow() != 0 {
+		now = time.Unix(req.GetNow(), 0)
+	}
+
+	decision, err := s.policies.Evaluate(ctx, int(req.GetAppId()), req.GetExpression(), policy.Input{
+		Subject:  req.GetSubject().AsMap(),
+		Resource: req.GetResource().AsMap(),
+		Action:   req.GetAction(),
+		Context:  req.GetContext().AsMap(),
+		Now:      now,
+	})
+	if err != nil {
+		return nil, policiesError(err)
+	}
+
+	resp := &authsvcv1.EvaluatePoliciesResponse{
+		Allowed: decision.Allowed,
+		Policy:  decision.Policy,
+		Results: make([]*authsvcv1.PolicyResult, 0, len(decision.Results)),
+	}
+	for _, result := range decision.Results {
+		res := &authsvcv1.PolicyResult{
+			Policy:  result.Policy,
+			Effect:  result.Effect,
+			Matched: result.Matched,
+		}
+		if result.Err != nil {
+			res.Error = result.Err.Error()
+		}
+		resp.Results = append(resp.Results, res)
+	}
+
+	return resp, nil
+}
+
+// subject describes the caller to policies
+func subject(claims jwt.Claims) map[string]any {
+	return map[string]any{
+		"id":       claims.UserID,
+		"email":    claims.Email,
+		"app_id":   int64(claims.AppID),
+		"roles":    nonNil(claims.Roles),
+		"org_id":   claims.OrgID,
+		"org_role": claims.OrgRole,
+		"amr":      nonNil(claims.Methods),
+		"acr":      claims.Level,
+	}
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+func policyToProto(pol models.Policy) *authsvcv1.Policy {
+	return &authsvcv1.Policy{
+		Id:          pol.ID,
+		AppId:       int32(pol.AppID),
+		Name:        pol.Name,
+		Description: pol.Description,
+		Effect:      pol.Effect,
+		Expression:  pol.Expression,
+		CreatedAt:   pol.CreatedAt.Unix(),
+		UpdatedAt:   pol.UpdatedAt.Unix(),
+	}
+}
+
+func policiesError(err error) error {
+	var compileErr *policy.CompileError
+	switch {
+	case errors.As(err, &compileErr):
+		return status.Error(codes.InvalidArgument, "expression is not valid: "+compileErr.Issues)
+	case errors.Is(err, policies.ErrorInvalidName):
+		return status.Error(codes.InvalidArgument, "policy name is not valid")
+	case errors.Is(err, policies.ErrorInvalidEffect):
+		return status.Error(codes.InvalidArgument, "effect must be allow or deny")
+	case errors.Is(err, policies.ErrorInvalidAppID):
+		return status.Error(codes.InvalidArgument, "app id is not valid")
+	case errors.Is(err, policies.ErrorPolicyNotFound):
+		return status.Error(codes.NotFound, "policy not found")
+	case errors.Is(err, policies.ErrorPolicyExists):
+		return status.Error(codes.AlreadyExists, "policy already exists")
+	}
+	return status.Error(codes.Internal, "internal error")
+}
@@ -0,0 +1,225 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+
+	authsvcv1 "github.com/Len4i/auth-service/gen/go/authsvc"
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/grpc/authn"
+	"github.com/Len4i/auth-service/internal/lib/jwt"
+	"github.com/Len4i/auth-service/internal/services/rbac"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type RBAC interface {
+	HasPermission(ctx context.Context, userID int64, appID int, permission string) (bool, error)
+	ListRoles(ctx context.Context, appID int) ([]models.Role, error)
+	UserRoles(ctx context.Context, userID int64, appID int) ([]models.Role, error)
+	AssignRole(ctx context.Context, userID int64, appID int, name string) error
+	RevokeRole(ctx context.Context, userID int64, appID int, name string) error
+	CreateRole(ctx context.Context, appID int, name string, description string, permissions []string) (models.Role, error)
+	DeleteRole(ctx context.Context, appID int, name string) error
+}
+
+type ServerApi struct {
+	authsvcv1.UnimplementedRBACServer
+	rbac   RBAC
+	admins authn.AdminChecker
+}
+
+func Register(gRPC *grpc.Server, rbac RBAC, admins authn.AdminChecker) {
+	authsvcv1.RegisterRBACServer(gRPC, &ServerApi{
+		rbac:   rbac,
+		admins: admins,
+	})
+}
+
+func (s *ServerApi) HasPermission(
+	ctx context.Context,
+	req *authsvcv1.HasPermissionRequest,
+) (*authsvcv1.HasPermissionResponse, error) {
+	claims, err := s.requireSelfOrAdmin(ctx, req.GetUserId())
+	if err != nil {
+		return nil, err
+	}
+	if req.GetPermission() == "" {
+		return nil, status.Error(codes.InvalidArgument, "permission is required")
+	}
+
+	userID := req.GetUserId()
+	if userID == 0 {
+		userID = claims.UserID
+	}
+	appID := int(req.GetAppId())
+	if appID == 0 {
+		appID = claims.AppID
+	}
+
+	ok, err := s.rbac.HasPermission(ctx, userID, appID, req.GetPermission())
+	if err != nil {
+		return nil, rbacError(err)
+	}
+	if userID == claims.UserID {
+		// api keys grant only permissions within their scopes
+		ok = ok && claims.Allows(req.GetPermission())
+	}
+
+	return &authsvcv1.HasPermissionResponse{
+		Allowed: ok,
+	}, nil
+}
+
+func (s *ServerApi) ListRoles(ctx context.Context, req *authsvcv1.ListRolesRequest) (*authsvcv1.ListRolesResponse, error) {
+	var roles []models.Role
+	if req.GetUserId() != 0 {
+		if _, err := s.requireSelfOrAdmin(ctx, req.GetUserId()); err != nil {
+			return nil, err
+		}
+
+		var err error
+		roles, err = s.rbac.UserRoles(ctx, req.GetUserId(), int(req.GetAppId()))
+		if err != nil {
+			return nil, rbacError(err)
+		}
+	} else {
+		if _, err := authn.RequireUser(ctx); err != nil {
+			return nil, err
+		}
+
+		var err error
+		roles, err = s.rbac.ListRoles(ctx, int(req.GetAppId()))
+		if err != nil {
+			return nil, rbacError(err)
+		}
+	}
+
+	resp := &authsvcv1.ListRolesResponse{
+		Roles: make([]*authsvcv1.Role, 0, len(roles)),
+	}
+	for _, role := range roles {
+		resp.Roles = append(resp.Roles, roleToProto(role))
+	}
+
+	return resp, nil
+}
+
+func (s *ServerApi) AssignRole(ctx context.Context, req *authsvcv1.AssignRoleRequest) (*authsvcv1.AssignRoleResponse, error) {
+	if _, err := authn.RequireAdmin(ctx, s.admins); err != nil {
+		return nil, err
+	}
+	if err := validateAssignment(req.GetUserId(), req.GetRole()); err != nil {
+		return nil, err
+	}
+
+	if err := s.rbac.AssignRole(ctx, req.GetUserId(), int(req.GetAppId()), req.GetRole()); err != nil {
+		return nil, rbacError(err)
+	}
+
+	return &authsvcv1.AssignRoleResponse{}, nil
+}
+
+func (s *ServerApi) RevokeRole(ctx context.Context, req *authsvcv1.RevokeRoleRequest) (*authsvcv1.RevokeRoleResponse, error) {
+	if _, err := authn.RequireAdmin(ctx, s.admins); err != nil {
+		return nil, err
+	}
+	if err := validateAssignment(req.GetUserId(), req.GetRole()); err != nil {
+		return nil, err
+	}
+
+	if err := s.rbac.RevokeRole(ctx, req.GetUserId(), int(req.GetAppId()), req.GetRole()); err != nil {
+		return nil, rbacError(err)
+	}
+
+	return &authsvcv1.RevokeRoleResponse{}, nil
+}
+
+func (s *ServerApi) CreateRole(ctx context.Context, req *authsvcv1.CreateRoleRequest) (*authsvcv1.CreateRoleResponse, error) {
+	if _, err := authn.RequireAdmin(ctx, s.admins); err != nil {
+		return nil, err
+	}
+
+	role, err := s.rbac.CreateRole(ctx, int(req.GetAppId()), req.GetName(), req.GetDescription(), req.GetPermissions())
+	if err != nil {
+		return nil, rbacError(err)
+	}
+
+	return &authsvcv1.CreateRoleResponse{
+		Role: roleToProto(role),
+	}, nil
+}
+
+func (s *ServerApi) DeleteRole(ctx context.Context, req *authsvcv1.DeleteRoleRequest) (*authsvcv1.DeleteRoleResponse, error) {
+	if _, err := authn.RequireAdmin(ctx, s.admins); err != nil {
+		return nil, err
+	}
+	if req.GetName() == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+
+	if err := s.rbac.DeleteRole(ctx, int(req.GetAppId()), req.GetName()); err != nil {
+		return nil, rbacError(err)
+	}
+
+	return &authsvcv1.DeleteRoleResponse{}, nil
+}
+
+// requireSelfOrAdmin returns claims of the caller if userID is the caller
+// or 0, otherwise the caller must be an admin
+func (s *ServerApi) requireSelfOrAdmin(ctx context.Context, userID int64) (jwt.Claims, error) {
+	claims, err := authn.RequireUser(ctx)
+	if err != nil {
+		return jwt.Claims{}, err
+	}
+	if userID == 0 || userID == claims.UserID {
+		return claims, nil
+	}
+
+	return authn.RequireAdmin(ctx, s.admins)
+}
+
+func validateAssignment(userID int64, role string) error {
+	if userID == 0 {
+		return status.Error(codes.InvalidArgument, "user_id is required")
+	}
+	if role == "" {
+		return status.Error(codes.InvalidArgument, "role is required")
+	}
+	return nil
+}
+
+func roleToProto(role models.Role) *authsvcv1.Role {
+	return &authsvcv1.Role{
+		Id:          role.ID,
+		AppId:       int32(role.AppID),
+		Name:        role.Name,
+		Description: role.Description,
+		Permissions: role.Permissions,
+		Builtin:     role.Builtin,
+		CreatedAt:   role.CreatedAt.Unix(),
+	}
+}
+
+func rbacError(err error) error {
+	switch {
+	case errors.Is(err, rbac.ErrorInvalidRoleName):
+		return status.Error(codes.InvalidArgument, "role name is not valid")
+	case errors.Is(err, rbac.ErrorInvalidPermission):
+		return status.Error(codes.InvalidArgument, "permission is not valid")
+	case errors.Is(err, rbac.ErrorInvalidAppID):
+		return status.Error(codes.InvalidArgument, "app id is not valid")
+	case errors.Is(err, rbac.ErrorUserNotFound):
+		return status.Error(codes.NotFound, "user not found")
+	case errors.Is(err, rbac.ErrorRoleNotFound):
+		return status.Error(codes.NotFound, "role not found")
+	case errors.Is(err, rbac.ErrorRoleExists):
+		return status.Error(codes.AlreadyExists, "role already exists")
+	case errors.Is(err, rbac.ErrorRoleNotAssigned):
+		return status.Error(codes.NotFound, "role is not assigned")
+	case errors.Is(err, rbac.ErrorBuiltinRole):
+		return status.Error(codes.FailedPrecondition, "built-in role can't be deleted")
+	}
+	return status.Error(codes.Internal, "internal error")
+}
@@ -0,0 +1,379 @@
+package relations
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	authsvcv1 "github.com/Len4i/auth-service/gen/go/authsvc"
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/grpc/authn"
+	"github.com/Len4i/auth-service/internal/services/relations"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type Relations interface {
+	WriteSchema(ctx context.Context, namespaces []models.Namespace) error
+	ReadSchema(ctx context.Context) ([]models.Namespace, error)
+	WriteTuples(ctx context.Context, tuples []models.RelationTuple) (token string, err error)
+	DeleteTuples(ctx context.Context, tuples []models.RelationTuple) (token string, err error)
+	ReadTuples(ctx context.Context, namespace string, objectID string, relation string) ([]models.RelationTuple, error)
+	Check(
+		ctx context.Context,
+		namespace string,
+		objectID string,
+		relation string,
+		userID string,
+		token string,
+	) (allowed bool, checkedAt string, err error)
+	Expand(
+		ctx context.Context,
+		namespace string,
+		objectID string,
+		relation string,
+		token string,
+	) (tree relations.ExpandNode, expandedAt string, err error)
+}
+
+type ServerApi struct {
+	authsvcv1.UnimplementedRelationsServer
+	relations Relations
+	admins    authn.AdminChecker
+}
+
+func Register(gRPC *grpc.Server, relations Relations, admins authn.AdminChecker) {
+	authsvcv1.RegisterRelationsServer(gRPC, &ServerApi{
+		relations: relations,
+		admins:    admins,
+	})
+}
+
+func (s *ServerApi) WriteSchema(
+	ctx context.Context,
+	req *authsvcv1.WriteSchemaRequest,
+) (*authsvcv1.WriteSchemaResponse, error) {
+	if _, err := authn.RequireAdmin(ctx, s.admins); err != nil {
+		return nil, err
+	}
+	if len(req.GetNamespaces()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "namespaces are required")
+	}
+
+	namespaces := make([]models.Namespace, 0, len(req.GetNamespaces()))
+	for _, ns := range req.GetNamespaces() {
+		namespaces = append(namespaces, namespaceFromProto(ns))
+	}
+
+	if err := s.relations.WriteSchema(ctx, namespaces); err != nil {
+		return nil, relationsError(err)
+	}
+
+	return &authsvcv1.WriteSchemaResponse{}, nil
+}
+
+func (s *ServerApi) ReadSchema(
+	ctx context.Context,
+	req *authsvcv1.ReadSchemaRequest,
+) (*authsvcv1.ReadSchemaResponse, error) {
+	if _, err := authn.RequireAdmin(ctx, s.admins); err != nil {
+		return nil, err
+	}
+
+	namespaces, err := s.relations.ReadSchema(ctx)
+	if err != nil {
+		return nil, relationsError(err)
+	}
+
+	resp := &authsvcv1.ReadSchemaResponse{
+		Namespaces: make([]*authsvcv1.Namespace, 0, len(namespaces)),
+	}
+	for _, ns := range namespaces {
+		resp.Namespaces = append(resp.Namespaces, namespaceToProto(ns))
+	}
+
+	return resp, nil
+}
+
+func (s *ServerApi) WriteTuples(
+	ctx context.Context,
+	req *authsvcv1.WriteTuplesRequest,
+) (*authsvcv1.WriteTuplesResponse, error) {
+	if _, err := authn.RequireAdmin(ctx, s.admins); err != nil {
+		return nil, err
+	}
+
+	tuples, err := tuplesFromProto(req.GetTuples())
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := s.relations.WriteTuples(ctx, tuples)
+	if err != nil {
+		return nil, relationsError(err)
+	}
+
+	return &authsvcv1.WriteTuplesResponse{
+		ConsistencyToken: token,
+	}, nil
+}
+
+func (s *ServerApi) DeleteTuples(
+	ctx context.Context,
+	req *authsvcv1.DeleteTuplesRequest,
+) (*authsvcv1.DeleteTuplesResponse, error) {
+	if _, err := authn.RequireAdmin(ctx, s.admins); err != nil {
+		return nil, err
+	}
+
+	tuples, err := tuplesFromProto(req.GetTuples())
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := s.relations.DeleteTuples(ctx, tuples)
+	if err != nil {
+		return nil, relationsError(err)
+	}
+
+	return &authsvcv1.DeleteTuplesResponse{
+		ConsistencyToken: token,
+	}, nil
+}
+
+func (s *ServerApi) ReadTuples(
+	ctx context.Context,
+	req *authsvcv1.ReadTuplesRequest,
+) (*authsvcv1.ReadTuplesResponse, error) {
+	if _, err := authn.RequireAdmin(ctx, s.admins); err != nil {
+		return nil, err
+	}
+	if req.GetNamespace() == "" {
+		return nil, status.Error(codes.InvalidArgument, "namespace is required")
+	}
+
+	tuples, err := s.relations.ReadTuples(ctx, req.GetNamespace(), req.GetObjectId(), req.GetRelation())
+	if err != nil {
+		return nil, relationsError(err)
+	}
+
+	resp := &authsvcv1.ReadTuplesResponse{
+		Tuples: make([]*authsvcv1.RelationTuple, 0, len(tuples)),
+	}
+	for _, t := range tuples {
+		resp.Tuples = append(resp.Tuples, tupleToProto(t))
+	}
+
+	return resp, nil
+}
+
+func (s *ServerApi) Check(
+	ctx context.Context,
+	req *authsvcv1.CheckRequest,
+) (*authsvcv1.CheckResponse, error) {
+	claims, err := authn.RequireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateObject(req.GetNamespace(), req.GetObjectId(), req.GetRelation()); err != nil {
+		return nil, err
+	}
+
+	caller := strconv.FormatInt(claims.UserID, 10)
+	userID := req.GetUserId()
+	if userID == "" {
+		userID = caller
+	}
+	if userID != caller {
+		if _, err := authn.RequireAdmin(ctx, s.admins); err != nil {
+			return nil, err
+		}
+	}
+
+	allowed, token, err := s.relations.Check(
+		ctx, req.GetNamespace(), req.GetObjectId(), req.GetRelation(), userID, req.GetConsistencyToken(),
+	)
+	if err != nil {
+		return nil, relationsError(err)
+	}
+	if userID == caller {
+		// api keys hold only relations within their scopes, e.g. "doc:viewer"
+		allowed = allowed && claims.Allows(req.GetNamespace()+":"+req.GetRelation())
+	}
+
+	return &authsvcv1.CheckResponse{
+		Allowed:          allowed,
+		ConsistencyToken: token,
+	}, nil
+}
+
+func (s *ServerApi) Expand(
+	ctx context.Context,
+	req *authsvcv1.ExpandRequest,
+) (*authsvcv1.ExpandResponse, error) {
+	if _, err := authn.RequireAdmin(ctx, s.admins); err != nil {
+		return nil, err
+	}
+	if err := validateObject(req.GetNamespace(), req.GetObjectId(), req.GetRelation()); err != nil {
+		return nil, err
+	}
+
+	tree, token, err := s.relations.Expand(
+		ctx, req.GetNamespace(), req.GetObjectId(), req.GetRelation(), req.GetConsistencyToken(),
+	)
+	if err != nil {
+		return nil, relationsError(err)
+	}
+
+	return &authsvcv1.ExpandResponse{
+		Tree:             expandNodeToProto(tree),
+		ConsistencyToken: token,
+	}, nil
+}
+
+func validateObject(namespace string, objectID string, relation string) error {
+	if namespace == "" {
+		return status.Error(codes.InvalidArgument, "namespace is required")
+	}
+	if objectID == "" {
+		return status.Error(codes.InvalidArgument, "object_id is required")
+	}
+	if relation == "" {
+		return status.Error(codes.InvalidArgument, "relation is required")
+	}
+	return nil
+}
+
+func namespaceFromProto(ns *authsvcv1.Namespace) models.Namespace {
+	namespace := models.Namespace{
+		Name:      ns.GetName(),
+		Relations: make([]models.Relation, 0, len(ns.GetRelations())),
+	}
+	for _, rel := range ns.GetRelations() {
+		relation := models.Relation{Name: rel.GetName()}
+		for _, userset := range rel.GetRewrite() {
+			var us models.Userset
+			switch u := userset.GetUserset().(type) {
+			case *authsvcv1.Userset_This:
+				us.This = u.This
+			case *authsvcv1.Userset_ComputedUserset:
+				us.ComputedUserset = u.ComputedUserset
+			case *authsvcv1.Userset_TupleToUserset:
+				us.TupleToUserset = &models.TupleToUserset{
+					Tupleset:        u.TupleToUserset.GetTupleset(),
+					ComputedUserset: u.TupleToUserset.GetComputedUserset(),
+				}
+			}
+			relation.Rewrite = append(relation.Rewrite, us)
+		}
+		namespace.Relations = append(namespace.Relations, relation)
+	}
+	return namespace
+}
+
+func namespaceToProto(ns models.Namespace) *authsvcv1.Namespace {
+	namespace := &authsvcv1.Namespace{
+		Name:      ns.Name,
+		Relations: make([]*authsvcv1.Relation, 0, len(ns.Relations)),
+	}
+	for _, rel := range ns.Relations {
+		relation := &authsvcv1.Relation{Name: rel.Name}
+		for _, us := range rel.Rewrite {
+			userset := &authsvcv1.Userset{}
+			switch {
+			case us.This:
+				userset.Userset = &authsvcv1.Userset_This{This: true}
+			case us.ComputedUserset != "":
+				userset.Userset = &authsvcv1.Userset_ComputedUserset{ComputedUserset: us.ComputedUserset}
+			case us.TupleToUserset != nil:
+				userset.Userset = &authsvcv1.Userset_TupleToUserset{TupleToUserset: &authsvcv1.TupleToUserset{
+					Tupleset:        us.TupleToUserset.Tupleset,
+					ComputedUserset: us.TupleToUserset.ComputedUserset,
+				}}
+			}
+			relation.Rewrite = append(relation.Rewrite, userset)
+		}
+		namespace.Relations = append(namespace.Relations, relation)
+	}
+	return namespace
+}
+
+func tuplesFromProto(tuples []*authsvcv1.RelationTuple) ([]models.RelationTuple, error) {
+	if len(tuples) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "tuples are required")
+	}
+
+	result := make([]models.RelationTuple, 0, len(tuples))
+	for _, t := range tuples {
+		tuple := models.RelationTuple{
+			Namespace: t.GetNamespace(),
+			ObjectID:  t.GetObjectId(),
+			Relation:  t.GetRelation(),
+		}
+		switch s := t.GetSubject().GetSubject().(type) {
+		case *authsvcv1.Subject_UserId:
+			tuple.Subject.UserID = s.UserId
+		case *authsvcv1.Subject_SubjectSet:
+			tuple.Subject.Namespace = s.SubjectSet.GetNamespace()
+			tuple.Subject.ObjectID = s.SubjectSet.GetObjectId()
+			tuple.Subject.Relation = s.SubjectSet.GetRelation()
+		default:
+			return nil, status.Error(codes.InvalidArgument, "tuple subject is required")
+		}
+		result = append(result, tuple)
+	}
+	return result, nil
+}
+
+func tupleToProto(t models.RelationTuple) *authsvcv1.RelationTuple {
+	tuple := &authsvcv1.RelationTuple{
+		Namespace: t.Namespace,
+		ObjectId:  t.ObjectID,
+		Relation:  t.Relation,
+		Subject:   &authsvcv1.Subject{},
+	}
+	if t.Subject.IsUserset() {
+		tuple.Subject.Subject = &authsvcv1.Subject_SubjectSet{SubjectSet: &authsvcv1.SubjectSet{
+			Namespace: t.Subject.Namespace,
+			ObjectId:  t.Subject.ObjectID,
+			Relation:  t.Subject.Relation,
+		}}
+	} else {
+		tuple.Subject.Subject = &authsvcv1.Subject_UserId{UserId: t.Subject.UserID}
+	}
+	return tuple
+}
+
+func expandNodeToProto(node relations.ExpandNode) *authsvcv1.ExpandNode {
+	n := &authsvcv1.ExpandNode{
+		Namespace: node.Namespace,
+		ObjectId:  node.ObjectID,
+		Relation:  node.Relation,
+		Users:     node.Users,
+	}
+	for _, child := range node.Children {
+		n.Children = append(n.Children, expandNodeToProto(child))
+	}
+	return n
+}
+
+func relationsError(err error) error {
+	switch {
+	case errors.Is(err, relations.ErrorInvalidNamespace):
+		return status.Error(codes.InvalidArgument, "namespace is not valid")
+	case errors.Is(err, relations.ErrorInvalidTuple):
+		return status.Error(codes.InvalidArgument, "relation tuple is not valid")
+	case errors.Is(err, relations.ErrorNamespaceUnknown):
+		return status.Error(codes.InvalidArgument, "namespace is not defined")
+	case errors.Is(err, relations.ErrorRelationUnknown):
+		return status.Error(codes.InvalidArgument, "relation is not defined")
+	case errors.Is(err, relations.ErrorInvalidToken):
+		return status.Error(codes.InvalidArgument, "consistency token is not valid")
+	case errors.Is(err, relations.ErrorTokenAhead):
+		return status.Error(codes.FailedPrecondition, "consistency token is ahead of the store")
+	case errors.Is(err, relations.ErrorDepthExceeded):
+		return status.Error(codes.FailedPrecondition, "relation graph is too deep")
+	}
+	return status.Error(codes.Internal, "internal error")
+}
@@ -0,0 +1,97 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Len4i/auth-service/internal/grpc/authn"
+	"github.com/Len4i/auth-service/internal/lib/cors"
+	"github.com/Len4i/auth-service/internal/services/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// originMetadataKey is the Origin header browsers send with requests
+	originMetadataKey = "origin"
+	appIDMetadataKey  = "x-app-id"
+)
+
+// Origins checks origins allowed per app
+type Origins interface {
+	OriginAllowed(ctx context.Context, appID int, origin string) (bool, error)
+	KnownOrigin(ctx context.Context, origin string) (bool, error)
+}
+
+// CORSPolicy lets browsers call from origins allowed by any app, requests
+// are checked against the app by UnaryServerInterceptor
+func CORSPolicy(origins Origins, maxAge time.Duration) cors.Policy {
+	return cors.Policy{
+		AllowedMethods: []string{http.MethodPost},
+		AllowedHeaders: allowedHeaders,
+		ExposedHeaders: exposedHeaders,
+		MaxAge:         maxAge,
+		AllowOriginFunc: func(ctx context.Context, origin string) bool {
+			ok, err := origins.KnownOrigin(ctx, origin)
+			return err == nil && ok
+		},
+	}
+}
+
+// appRequest is a request message carrying the app, e.g. LoginRequest
+type appRequest interface {
+	GetAppId() int32
+}
+
+// UnaryServerInterceptor rejects browser requests from origins the app
+// of the request doesn't allow
+//
+// The app is taken from app_id of the request, x-app-id metadata or
+// the token of the caller, in this order, so the interceptor goes after
+// authn. Requests without origin, i.e. of native clients, pass through.
+func UnaryServerInterceptor(origins Origins) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		values := md.Get(originMetadataKey)
+		if len(values) == 0 {
+			return handler(ctx, req)
+		}
+
+		appID := requestAppID(ctx, md, req)
+		if appID == 0 {
+			return nil, status.Error(codes.PermissionDenied, "app is required for browser requests")
+		}
+		ok, err := origins.OriginAllowed(ctx, appID, values[0])
+		if err != nil {
+			if errors.Is(err, auth.ErrorInvalidAppID) {
+				return nil, status.Error(codes.PermissionDenied, "origin is not allowed")
+			}
+			return nil, status.Error(codes.Internal, "internal error")
+		}
+		if !ok {
+			return nil, status.Error(codes.PermissionDenied, "origin is not allowed")
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+func requestAppID(ctx context.Context, md metadata.MD, req any) int {
+	if r, ok := req.(appRequest); ok && r.GetAppId() != 0 {
+		return int(r.GetAppId())
+	}
+	if values := md.Get(appIDMetadataKey); len(values) > 0 {
+		if appID, err := strconv.Atoi(values[0]); err == nil && appID > 0 {
+			return appID
+		}
+	}
+	if claims, ok := authn.ClaimsFromContext(ctx); ok {
+		return claims.AppID
+	}
+	return 0
+}
@@ -0,0 +1,411 @@
+// Package web serves gRPC methods to browsers over gRPC-Web and the unary
+// Connect protocol, see
+// https://github.com/grpc/grpc/blob/master/doc/PROTOCOL-WEB.md and
+// https://connectrpc.com/docs/protocol
+//
+// Requests are translated to gRPC and served by the gRPC server itself,
+// so methods and interceptors are shared with native clients.
+package web
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	grpcWebContentType     = "application/grpc-web"
+	grpcWebTextContentType = "application/grpc-web-text"
+	connectProtoType       = "application/proto"
+	connectJSONType        = "application/json"
+
+	protoCodecName = "proto"
+
+	// messages are prefixed with flags and length, trailers of gRPC-Web
+	// are sent as the last message flagged with trailerFlag
+	envelopeSize = 5
+	trailerFlag  = 0x80
+
+	// maxMessageSize is the default limit of the gRPC server
+	maxMessageSize = 4 << 20
+
+	// trailerPrefix marks trailers the gRPC server sets after the body,
+	// see http2.TrailerPrefix
+	trailerPrefix = "Trailer:"
+)
+
+var (
+	// allowedHeaders are request headers of web clients, metadata of methods included
+	allowedHeaders = []string{
+		"Authorization", "Content-Type", "X-Grpc-Web", "X-User-Agent", "Grpc-Timeout",
+		"Connect-Protocol-Version", "Connect-Timeout-Ms", "X-App-Id", "X-Org-Id", "X-Invite-Code",
+	}
+	// exposedHeaders are response headers web clients read status from
+	exposedHeaders = []string{"Grpc-Status", "Grpc-Message", "Grpc-Status-Details-Bin"}
+)
+
+// droppedHeaders are headers of web requests not passed to the gRPC server
+var droppedHeaders = []string{
+	"Content-Length", "Content-Encoding", "Accept-Encoding", "Connection", "Te",
+	"Connect-Protocol-Version", "Connect-Timeout-Ms", "X-Grpc-Web",
+}
+
+// responseHeaders are headers of the gRPC response carried differently by web protocols
+var responseHeaders = []string{
+	"Content-Type", "Trailer", "Date", "Grpc-Encoding", "Grpc-Status", "Grpc-Message", "Grpc-Status-Details-Bin",
+}
+
+type Handler struct {
+	server http.Handler
+}
+
+// NewHandler translates web requests to gRPC requests served by server,
+// which is usually *grpc.Server
+//
+// Unary methods only are supported, responses are buffered.
+func NewHandler(server http.Handler) *Handler {
+	return &Handler{server: server}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+		return
+	}
+	baseType, subtype, _ := strings.Cut(mediaType, "+")
+	if subtype == "" {
+		subtype = protoCodecName
+	}
+
+	switch {
+	case baseType == grpcWebContentType && validSubtype(subtype):
+		h.serveGRPCWeb(w, r, subtype, false)
+	case baseType == grpcWebTextContentType && validSubtype(subtype):
+		h.serveGRPCWeb(w, r, subtype, true)
+	case mediaType == connectProtoType:
+		h.serveConnect(w, r, protoCodecName)
+	case mediaType == connectJSONType:
+		h.serveConnect(w, r, jsonCodecName)
+	default:
+		http.Error(w, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+	}
+}
+
+// serveGRPCWeb serves gRPC-Web request, its body is already made
+// of enveloped messages
+//
+// Text requests and responses are base64 encoded.
+func (h *Handler) serveGRPCWeb(w http.ResponseWriter, r *http.Request, subtype string, text bool) {
+	var body io.Reader = r.Body
+	if text {
+		body = base64.NewDecoder(base64.StdEncoding, r.Body)
+	}
+	rec := h.call(r, grpcHeader(r.Header, subtype), body)
+
+	header, trailer := rec.metadata()
+	for name, values := range header {
+		w.Header()[name] = values
+	}
+
+	st := rec.status()
+	var trailers bytes.Buffer
+	fmt.Fprintf(&trailers, "grpc-status: %d\r\n", st.Code())
+	if st.Message() != "" {
+		fmt.Fprintf(&trailers, "grpc-message: %s\r\n", url.PathEscape(st.Message()))
+	}
+	if details := rec.header.Get("Grpc-Status-Details-Bin"); details != "" {
+		fmt.Fprintf(&trailers, "grpc-status-details-bin: %s\r\n", details)
+	}
+	for name, values := range trailer {
+		for _, value := range values {
+			fmt.Fprintf(&trailers, "%s: %s\r\n", strings.ToLower(name), value)
+		}
+	}
+
+	out := append(rec.messages(), envelope(trailerFlag, trailers.Bytes())...)
+	contentType := grpcWebContentType
+	if text {
+		contentType = grpcWebTextContentType
+		out = []byte(base64.StdEncoding.EncodeToString(out))
+	}
+	w.Header().Set("Content-Type", contentType+"+"+subtype)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(out)
+}
+
+// serveConnect serves unary Connect request, its body is a single message
+// without envelope, errors are sent as JSON with HTTP status mapped from code
+func (h *Handler) serveConnect(w http.ResponseWriter, r *http.Request, subtype string) {
+	if encoding := r.Header.Get("Content-Encoding"); encoding != "" && encoding != "identity" {
+		writeConnectError(w, status.New(codes.Unimplemented, "content encoding is not supported"))
+		return
+	}
+	msg, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxMessageSize))
+	if err != nil {
+		writeConnectError(w, status.New(codes.ResourceExhausted, "message is too large"))
+		return
+	}
+
+	header := grpcHeader(r.Header, subtype)
+	if timeout := r.Header.Get("Connect-Timeout-Ms"); timeout != "" {
+		// gRPC timeouts have at most 8 digits
+		ms, err := strconv.ParseUint(timeout, 10, 64)
+		if err != nil || ms >= 1e8 {
+			writeConnectError(w, status.New(codes.InvalidArgument, "timeout is not valid"))
+			return
+		}
+		header.Set("Grpc-Timeout", strconv.FormatUint(ms, 10)+"m")
+	}
+	rec := h.call(r, header, bytes.NewReader(envelope(0, msg)))
+
+	respHeader, trailer := rec.metadata()
+	for name, values := range respHeader {
+		w.Header()[name] = values
+	}
+	for name, values := range trailer {
+		w.Header()["Trailer-"+name] = values
+	}
+
+	st := rec.status()
+	if st.Code() != codes.OK {
+		writeConnectError(w, st)
+		return
+	}
+	resp, ok := firstMessage(rec.messages())
+	if !ok {
+		writeConnectError(w, status.New(codes.Internal, "response has no message"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/"+subtype)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(resp)
+}
+
+// call serves gRPC request made of web request r with header and body of
+// enveloped messages, and records the response
+func (h *Handler) call(r *http.Request, header http.Header, body io.Reader) *recorder {
+	req := r.Clone(r.Context())
+	req.Proto, req.ProtoMajor, req.ProtoMinor = "HTTP/2", 2, 0
+	req.Header = header
+	req.Body = io.NopCloser(body)
+	req.ContentLength = -1
+
+	rec := &recorder{header: http.Header{}}
+	h.server.ServeHTTP(rec, req)
+	return rec
+}
+
+// grpcHeader returns header of gRPC request with messages in subtype codec
+func grpcHeader(webHeader http.Header, subtype string) http.Header {
+	header := webHeader.Clone()
+	for _, name := range droppedHeaders {
+		header.Del(name)
+	}
+	header.Set("Content-Type", "application/grpc+"+subtype)
+	return header
+}
+
+func validSubtype(subtype string) bool {
+	return subtype == protoCodecName || subtype == jsonCodecName
+}
+
+// envelope prefixes msg with flags and its length
+func envelope(flags byte, msg []byte) []byte {
+	res := make([]byte, envelopeSize, envelopeSize+len(msg))
+	res[0] = flags
+	binary.BigEndian.PutUint32(res[1:], uint32(len(msg)))
+	return append(res, msg...)
+}
+
+// firstMessage returns the first message of enveloped messages
+func firstMessage(data []byte) ([]byte, bool) {
+	if len(data) < envelopeSize {
+		return nil, false
+	}
+	size := binary.BigEndian.Uint32(data[1:envelopeSize])
+	if uint64(len(data)-envelopeSize) < uint64(size) {
+		return nil, false
+	}
+	return data[envelopeSize : envelopeSize+int(size)], true
+}
+
+// recorder records response of the gRPC server, it is written by
+// the goroutine serving the request only
+type recorder struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (rec *recorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	if rec.code == 0 {
+		rec.code = http.StatusOK
+	}
+	return rec.body.Write(b)
+}
+
+func (rec *recorder) WriteHeader(code int) {
+	if rec.code == 0 {
+		rec.code = code
+	}
+}
+
+func (rec *recorder) Flush() {}
+
+// rejected reports whether the server rejected the request before serving it,
+// e.g. because of malformed timeout, body is the error text then
+func (rec *recorder) rejected() bool {
+	return rec.code != 0 && rec.code != http.StatusOK
+}
+
+// messages returns enveloped response messages
+func (rec *recorder) messages() []byte {
+	if rec.rejected() {
+		return nil
+	}
+	return rec.body.Bytes()
+}
+
+// status returns status the call ended with
+func (rec *recorder) status() *status.Status {
+	if rec.rejected() {
+		return status.New(codes.Internal, strings.TrimSpace(rec.body.String()))
+	}
+
+	code, err := strconv.Atoi(rec.header.Get("Grpc-Status"))
+	if err != nil {
+		return status.New(codes.Unknown, "response has no status")
+	}
+	if details := rec.header.Get("Grpc-Status-Details-Bin"); details != "" {
+		raw, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(details, "="))
+		st := &spb.Status{}
+		if err == nil && proto.Unmarshal(raw, st) == nil {
+			return status.FromProto(st)
+		}
+	}
+	msg := rec.header.Get("Grpc-Message")
+	if decoded, err := url.PathUnescape(msg); err == nil {
+		msg = decoded
+	}
+	return status.New(codes.Code(code), msg)
+}
+
+// metadata returns custom headers and trailers of the response
+func (rec *recorder) metadata() (header http.Header, trailer http.Header) {
+	header, trailer = http.Header{}, http.Header{}
+	for name, values := range rec.header {
+		if strings.HasPrefix(name, trailerPrefix) {
+			trailer[http.CanonicalHeaderKey(strings.TrimPrefix(name, trailerPrefix))] = values
+			continue
+		}
+		header[name] = values
+	}
+	for _, name := range responseHeaders {
+		header.Del(name)
+	}
+	return header, trailer
+}
+
+// connectError is the error body of Connect, see
+// https://connectrpc.com/docs/protocol#error-end-stream
+type connectError struct {
+	Code    string          `json:"code"`
+	Message string          `json:"message,omitempty"`
+	Details []connectDetail `json:"details,omitempty"`
+}
+
+// connectDetail is error detail with unpadded base64 encoded value
+type connectDetail struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+func writeConnectError(w http.ResponseWriter, st *status.Status) {
+	if _, ok := connectCodes[st.Code()]; !ok {
+		st = status.New(codes.Unknown, st.Message())
+	}
+	body := connectError{
+		Code:    connectCodes[st.Code()],
+		Message: st.Message(),
+	}
+	for _, detail := range st.Proto().GetDetails() {
+		typeURL := detail.GetTypeUrl()
+		body.Details = append(body.Details, connectDetail{
+			Type:  typeURL[strings.LastIndexByte(typeURL, '/')+1:],
+			Value: base64.RawStdEncoding.EncodeToString(detail.GetValue()),
+		})
+	}
+
+	w.Header().Set("Content-Type", connectJSONType)
+	w.WriteHeader(connectHTTPStatus(st.Code()))
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// connectCodes are names of codes in Connect
+var connectCodes = map[codes.Code]string{
+	codes.Canceled:           "canceled",
+	codes.Unknown:            "unknown",
+	codes.InvalidArgument:    "invalid_argument",
+	codes.DeadlineExceeded:   "deadline_exceeded",
+	codes.NotFound:           "not_found",
+	codes.AlreadyExists:      "already_exists",
+	codes.PermissionDenied:   "permission_denied",
+	codes.ResourceExhausted:  "resource_exhausted",
+	codes.FailedPrecondition: "failed_precondition",
+	codes.Aborted:            "aborted",
+	codes.OutOfRange:         "out_of_range",
+	codes.Unimplemented:      "unimplemented",
+	codes.Internal:           "internal",
+	codes.Unavailable:        "unavailable",
+	codes.DataLoss:           "data_loss",
+	codes.Unauthenticated:    "unauthenticated",
+}
+
+// connectHTTPStatus maps code to HTTP status as in the Connect protocol
+func connectHTTPStatus(code codes.Code) int {
+	switch code {
+	case codes.Canceled, codes.DeadlineExceeded:
+		return http.StatusRequestTimeout
+	case codes.InvalidArgument, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.NotFound, codes.Unimplemented:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.FailedPrecondition:
+		return http.StatusPreconditionFailed
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	}
+	return http.StatusInternalServerError
+}
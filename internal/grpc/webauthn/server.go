@@ -0,0 +1,221 @@
+package webauthn
+
+import (
+	"context"
+	"errors"
+
+	authsvcv1 "github.com/Len4i/auth-service/gen/go/authsvc"
+	"github.com/Len4i/auth-service/internal/domain/models"
+	authgRPC "github.com/Len4i/auth-service/internal/grpc/auth"
+	"github.com/Len4i/auth-service/internal/grpc/authn"
+	"github.com/Len4i/auth-service/internal/services/auth"
+	"github.com/Len4i/auth-service/internal/services/passkeys"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const emptyAppID = 0
+
+type Passkeys interface {
+	BeginRegistration(ctx context.Context, userID int64, email string) (options []byte, session string, err error)
+	FinishRegistration(
+		ctx context.Context,
+		userID int64,
+		session string,
+		credential []byte,
+		name string,
+	) (models.WebAuthnCredential, error)
+	Credentials(ctx context.Context, userID int64) ([]models.WebAuthnCredential, error)
+	DeleteCredential(ctx context.Context, userID int64, id int64) error
+}
+
+// Authenticator logs users in with passkeys
+type Authenticator interface {
+	BeginPasskeyLogin(ctx context.Context, email string, appID int, mfaChallenge string) (options []byte, session string, err error)
+	FinishPasskeyLogin(ctx context.Context, session string, credential []byte) (token string, err error)
+}
+
+type ServerApi struct {
+	authsvcv1.UnimplementedWebAuthnServer
+	passkeys      Passkeys
+	authenticator Authenticator
+}
+
+func Register(gRPC *grpc.Server, passkeys Passkeys, authenticator Authenticator) {
+	authsvcv1.RegisterWebAuthnServer(gRPC, &ServerApi{
+		passkeys:      passkeys,
+		authenticator: authenticator,
+	})
+}
+
+func (s *ServerApi) BeginRegistration(
+	ctx context.Context,
+	req *authsvcv1.BeginRegistrationRequest,
+) (*authsvcv1.BeginRegistrationResponse, error) {
+	claims, err := authn.RequireLogin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	options, session, err := s.passkeys.BeginRegistration(ctx, claims.UserID, claims.Email)
+	if err != nil {
+		return nil, webAuthnError(err)
+	}
+
+	return &authsvcv1.BeginRegistrationResponse{
+		Session: session,
+		Options: options,
+	}, nil
+}
+
+func (s *ServerApi) FinishRegistration(
+	ctx context.Context,
+	req *authsvcv1.FinishRegistrationRequest,
+) (*authsvcv1.FinishRegistrationResponse, error) {
+	claims, err := authn.RequireLogin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateCeremony(req.GetSession(), req.GetCredential()); err != nil {
+		return nil, err
+	}
+
+	cred, err := s.passkeys.FinishRegistration(ctx, claims.UserID, req.GetSession(), req.GetCredential(), req.GetName())
+	if err != nil {
+		return nil, webAuthnError(err)
+	}
+
+	return &authsvcv1.FinishRegistrationResponse{
+		Credential: credentialToProto(cred),
+	}, nil
+}
+
+func (s *ServerApi) ListCredentials(
+	ctx context.Context,
+	req *authsvcv1.ListCredentialsRequest,
+) (*authsvcv1.ListCredentialsResponse, error) {
+	claims, err := authn.RequireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := s.passkeys.Credentials(ctx, claims.UserID)
+	if err != nil {
+		return nil, webAuthnError(err)
+	}
+
+	resp := &authsvcv1.ListCredentialsResponse{
+		Credentials: make([]*authsvcv1.WebAuthnCredential, 0, len(creds)),
+	}
+	for _, cred := range creds {
+		resp.Credentials = append(resp.Credentials, credentialToProto(cred))
+	}
+
+	return resp, nil
+}
+
+func (s *ServerApi) DeleteCredential(
+	ctx context.Context,
+	req *authsvcv1.DeleteCredentialRequest,
+) (*authsvcv1.DeleteCredentialResponse, error) {
+	claims, err := authn.RequireLogin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.GetId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+
+	if err := s.passkeys.DeleteCredential(ctx, claims.UserID, req.GetId()); err != nil {
+		return nil, webAuthnError(err)
+	}
+
+	return &authsvcv1.DeleteCredentialResponse{}, nil
+}
+
+func (s *ServerApi) BeginLogin(ctx context.Context, req *authsvcv1.BeginLoginRequest) (*authsvcv1.BeginLoginResponse, error) {
+	if req.GetMfaChallenge() == "" && req.GetAppId() == emptyAppID {
+		return nil, status.Error(codes.InvalidArgument, "app_id is required")
+	}
+
+	options, session, err := s.authenticator.BeginPasskeyLogin(ctx, req.GetEmail(), int(req.GetAppId()), req.GetMfaChallenge())
+	if err != nil {
+		return nil, webAuthnError(err)
+	}
+
+	return &authsvcv1.BeginLoginResponse{
+		Session: session,
+		Options: options,
+	}, nil
+}
+
+func (s *ServerApi) FinishLogin(ctx context.Context, req *authsvcv1.FinishLoginRequest) (*authsvcv1.FinishLoginResponse, error) {
+	if err := validateCeremony(req.GetSession(), req.GetCredential()); err != nil {
+		return nil, err
+	}
+
+	token, err := s.authenticator.FinishPasskeyLogin(ctx, req.GetSession(), req.GetCredential())
+	if err != nil {
+		return nil, webAuthnError(err)
+	}
+
+	return &authsvcv1.FinishLoginResponse{
+		Token: token,
+	}, nil
+}
+
+func validateCeremony(session string, credential []byte) error {
+	if session == "" {
+		return status.Error(codes.InvalidArgument, "session is required")
+	}
+	if len(credential) == 0 {
+		return status.Error(codes.InvalidArgument, "credential is required")
+	}
+	return nil
+}
+
+func credentialToProto(cred models.WebAuthnCredential) *authsvcv1.WebAuthnCredential {
+	var lastUsedAt int64
+	if !cred.LastUsedAt.IsZero() {
+		lastUsedAt = cred.LastUsedAt.Unix()
+	}
+
+	return &authsvcv1.WebAuthnCredential{
+		Id:             cred.ID,
+		Name:           cred.Name,
+		Transports:     cred.Transports,
+		SignCount:      cred.SignCount,
+		BackupEligible: cred.BackupEligible,
+		BackupState:    cred.BackupState,
+		CreatedAt:      cred.CreatedAt.Unix(),
+		LastUsedAt:     lastUsedAt,
+	}
+}
+
+func webAuthnError(err error) error {
+	switch {
+	case errors.Is(err, passkeys.ErrorPasskeysNotConfigured):
+		return status.Error(codes.FailedPrecondition, "passkeys are not configured")
+	case errors.Is(err, passkeys.ErrorInvalidSession):
+		return status.Error(codes.Unauthenticated, "session is not valid")
+	case errors.Is(err, passkeys.ErrorInvalidCredential):
+		return status.Error(codes.Unauthenticated, "credential is not valid")
+	case errors.Is(err, passkeys.ErrorCredentialExists):
+		return status.Error(codes.AlreadyExists, "credential is already registered")
+	case errors.Is(err, passkeys.ErrorCredentialNotFound):
+		return status.Error(codes.NotFound, "credential not found")
+	case errors.Is(err, passkeys.ErrorNoCredentials):
+		return status.Error(codes.FailedPrecondition, "no passkeys registered")
+	case errors.Is(err, auth.ErrorInvalidAppID):
+		return status.Error(codes.InvalidArgument, "app id is not valid")
+	case errors.Is(err, auth.ErrorInvalidCredentials):
+		return status.Error(codes.Unauthenticated, "invalid credentials")
+	case errors.Is(err, auth.ErrorInvalidChallenge):
+		return status.Error(codes.Unauthenticated, "challenge is not valid")
+	}
+	if st, ok := authgRPC.OrgPolicyStatus(err); ok {
+		return st
+	}
+	return status.Error(codes.Internal, "internal error")
+}
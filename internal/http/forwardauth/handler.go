@@ -0,0 +1,200 @@
+// Package forwardauth lets reverse proxies authenticate requests to services
+// behind them, as nginx auth_request and Traefik ForwardAuth do
+//
+// The proxy sends headers of the original request to the endpoint. It passes
+// the request on if the answer is 2xx, copying identity headers of the answer
+// to it, and answers the client with 401 or 403 otherwise.
+package forwardauth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/lib/jwt"
+	"github.com/Len4i/auth-service/internal/services/sessions"
+)
+
+const (
+	forwardAuthPath = "/forward-auth"
+
+	// Identity headers of the answer
+	UserIDHeader   = "X-Auth-User-Id"
+	EmailHeader    = "X-Auth-Email"
+	RolesHeader    = "X-Auth-Roles"
+	AppIDHeader    = "X-Auth-App-Id"
+	OrgIDHeader    = "X-Auth-Org-Id"
+	ClientIDHeader = "X-Auth-Client-Id"
+)
+
+// TokenVerifier verifies bearer tokens, api keys included
+type TokenVerifier interface {
+	VerifyToken(ctx context.Context, token string) (jwt.Claims, error)
+}
+
+// Sessions looks up sessions of session cookies
+type Sessions interface {
+	Session(ctx context.Context, token string) (models.BrowserSession, error)
+}
+
+// RoleProvider returns roles users currently hold in apps
+type RoleProvider interface {
+	UserRoles(ctx context.Context, userID int64, appID int) ([]models.Role, error)
+}
+
+type Handler struct {
+	log        *slog.Logger
+	tokens     TokenVerifier
+	sessions   Sessions
+	roles      RoleProvider
+	cookieName string
+}
+
+// Register adds the forward-auth endpoint to mux, requests are authenticated
+// with a bearer token or the session cookie of cookieName
+func Register(
+	mux *http.ServeMux,
+	log *slog.Logger,
+	tokens TokenVerifier,
+	sessions Sessions,
+	roles RoleProvider,
+	cookieName string,
+) {
+	h := &Handler{
+		log:        log,
+		tokens:     tokens,
+		sessions:   sessions,
+		roles:      roles,
+		cookieName: cookieName,
+	}
+	mux.HandleFunc(forwardAuthPath, h.ForwardAuth)
+}
+
+// identity is who the request is authenticated as
+type identity struct {
+	UserID   int64
+	Email    string
+	ClientID string
+	AppID    int
+	OrgID    int64
+}
+
+// ForwardAuth answers 200 with identity headers if the request carries
+// valid credentials of the app, 401 if it doesn't
+//
+// The app_id query param is required, credentials of other apps are
+// answered with 403. role requires one of the listed roles the user
+// currently holds in the app, it may be repeated. Any method is accepted,
+// since proxies may forward the method of the original request.
+func (h *Handler) ForwardAuth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+
+	query := r.URL.Query()
+	if !query.Has("app_id") {
+		http.Error(w, "app_id is required", http.StatusBadRequest)
+		return
+	}
+	appID, err := strconv.Atoi(query.Get("app_id"))
+	if err != nil || appID <= 0 {
+		http.Error(w, "app_id is not valid", http.StatusBadRequest)
+		return
+	}
+
+	id, err := h.identity(r)
+	switch {
+	case errors.Is(err, jwt.ErrInvalidToken), errors.Is(err, sessions.ErrorInvalidSession):
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	case err != nil:
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if id.AppID != appID {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	// roles are read again, they may have been revoked since the login
+	var roles []string
+	if id.UserID != 0 {
+		userRoles, err := h.roles.UserRoles(r.Context(), id.UserID, appID)
+		if err != nil {
+			h.log.Error("failed to get user roles", "error", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		for _, role := range userRoles {
+			roles = append(roles, role.Name)
+		}
+	}
+	if required := query["role"]; len(required) > 0 && !slices.ContainsFunc(required, func(role string) bool {
+		return slices.Contains(roles, role)
+	}) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	if id.UserID != 0 {
+		w.Header().Set(UserIDHeader, strconv.FormatInt(id.UserID, 10))
+		w.Header().Set(EmailHeader, id.Email)
+	}
+	if id.ClientID != "" {
+		w.Header().Set(ClientIDHeader, id.ClientID)
+	}
+	if id.AppID != 0 {
+		w.Header().Set(AppIDHeader, strconv.Itoa(id.AppID))
+	}
+	if id.OrgID != 0 {
+		w.Header().Set(OrgIDHeader, strconv.FormatInt(id.OrgID, 10))
+	}
+	w.Header().Set(RolesHeader, strings.Join(roles, ","))
+	w.WriteHeader(http.StatusOK)
+}
+
+// identity authenticates the request with its bearer token or,
+// if it has none, with its session cookie
+//
+// Returns jwt.ErrInvalidToken or sessions.ErrorInvalidSession
+// if credentials are missing or not valid.
+func (h *Handler) identity(r *http.Request) (identity, error) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok || token == "" {
+			return identity{}, jwt.ErrInvalidToken
+		}
+		claims, err := h.tokens.VerifyToken(r.Context(), token)
+		if err != nil {
+			h.log.Warn("invalid bearer token", "error", err)
+			return identity{}, jwt.ErrInvalidToken
+		}
+		return identity{
+			UserID:   claims.UserID,
+			Email:    claims.Email,
+			ClientID: claims.ClientID,
+			AppID:    claims.AppID,
+			OrgID:    claims.OrgID,
+		}, nil
+	}
+
+	cookie, err := r.Cookie(h.cookieName)
+	if err != nil {
+		return identity{}, sessions.ErrorInvalidSession
+	}
+	session, err := h.sessions.Session(r.Context(), cookie.Value)
+	if err != nil {
+		return identity{}, err
+	}
+	return identity{
+		UserID: session.UserID,
+		Email:  session.Email,
+		AppID:  session.AppID,
+		OrgID:  session.OrgID,
+	}, nil
+}
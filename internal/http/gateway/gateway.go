@@ -0,0 +1,228 @@
+// Package gateway serves unary gRPC methods as a JSON HTTP API for clients
+// that cannot speak gRPC
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	// error details of statuses are resolved from the global registry
+	_ "google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const (
+	apiPrefix   = "/v1/"
+	openAPIPath = "/openapi.json"
+
+	maxBodySize = 1 << 20
+)
+
+// metadataHeaders are passed to methods as metadata along with X- prefixed
+// headers, e.g. X-App-Id or X-Org-Id
+var metadataHeaders = []string{"Authorization"}
+
+var (
+	unmarshalOptions = protojson.UnmarshalOptions{}
+	marshalOptions   = protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}
+)
+
+// method is a unary gRPC method served at its path
+type method struct {
+	fullName string
+	input    protoreflect.MessageType
+	output   protoreflect.MessageType
+}
+
+type Handler struct {
+	log     *slog.Logger
+	conn    grpc.ClientConnInterface
+	timeout time.Duration
+	methods map[string]method
+	openAPI []byte
+}
+
+// Register adds every unary method of services to mux and the OpenAPI
+// document describing them
+//
+// Methods are served at POST /v1/{service}/{method} with names in kebab case,
+// e.g. auth.Auth/IsAdmin at /v1/auth/is-admin. Request body is the JSON
+// mapping of the request message, calls are made over conn so the server
+// interceptors apply as for native clients. Calls exceeding timeout fail
+// with DEADLINE_EXCEEDED.
+func Register(
+	mux *http.ServeMux,
+	log *slog.Logger,
+	conn grpc.ClientConnInterface,
+	timeout time.Duration,
+	services ...protoreflect.ServiceDescriptor,
+) error {
+	h := &Handler{
+		log:     log,
+		conn:    conn,
+		timeout: timeout,
+		methods: make(map[string]method),
+	}
+	for _, service := range services {
+		for _, md := range unaryMethods(service) {
+			h.methods[methodPath(service, md)] = method{
+				fullName: "/" + string(service.FullName()) + "/" + string(md.Name()),
+				input:    messageType(md.Input()),
+				output:   messageType(md.Output()),
+			}
+		}
+	}
+
+	openAPI, err := json.Marshal(openAPIDocument(services))
+	if err != nil {
+		return err
+	}
+	h.openAPI = openAPI
+
+	mux.Handle(apiPrefix, h)
+	mux.HandleFunc(openAPIPath, h.OpenAPI)
+	return nil
+}
+
+// ServeHTTP calls the method at request path with request body
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m, ok := h.methods[r.URL.Path]
+	if !ok {
+		writeStatus(w, status.New(codes.NotFound, "method not found"))
+		return
+	}
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			writeStatus(w, status.New(codes.ResourceExhausted, "request body is too large"))
+			return
+		}
+		writeStatus(w, status.New(codes.InvalidArgument, "failed to read request body"))
+		return
+	}
+	in := m.input.New().Interface()
+	if len(body) > 0 {
+		if err := unmarshalOptions.Unmarshal(body, in); err != nil {
+			writeStatus(w, status.New(codes.InvalidArgument, "request body is not valid"))
+			return
+		}
+	}
+
+	out := m.output.New().Interface()
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+	ctx = metadata.NewOutgoingContext(ctx, requestMetadata(r))
+	if err := h.conn.Invoke(ctx, m.fullName, in, out); err != nil {
+		st := status.Convert(err)
+		if st.Code() == codes.Unknown || st.Code() == codes.Unavailable {
+			h.log.Error("failed to call method", slog.String("method", m.fullName), "error", err)
+		}
+		writeStatus(w, st)
+		return
+	}
+
+	resp, err := marshalOptions.Marshal(out)
+	if err != nil {
+		h.log.Error("failed to marshal response", slog.String("method", m.fullName), "error", err)
+		writeStatus(w, status.New(codes.Internal, "internal error"))
+		return
+	}
+	writeJSON(w, http.StatusOK, json.RawMessage(resp))
+}
+
+// OpenAPI serves the OpenAPI document of served methods
+func (h *Handler) OpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(h.openAPI)
+}
+
+// requestMetadata returns headers passed to methods as metadata
+func requestMetadata(r *http.Request) metadata.MD {
+	md := metadata.MD{}
+	for name, values := range r.Header {
+		if strings.HasPrefix(name, "X-") {
+			md.Append(name, values...)
+		}
+	}
+	for _, name := range metadataHeaders {
+		if values := r.Header.Values(name); len(values) > 0 {
+			md.Append(name, values...)
+		}
+	}
+	return md
+}
+
+// methodPath returns path the method is served at
+func methodPath(service protoreflect.ServiceDescriptor, md protoreflect.MethodDescriptor) string {
+	return apiPrefix + kebabCase(string(service.Name())) + "/" + kebabCase(string(md.Name()))
+}
+
+// kebabCase converts CamelCase name to kebab case, acronyms are kept
+// together, e.g. IsAdmin to is-admin and APIKeys to api-keys
+func kebabCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteByte('-')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// messageType returns the generated type of the message if it is linked in,
+// otherwise a dynamic one
+func messageType(md protoreflect.MessageDescriptor) protoreflect.MessageType {
+	if mt, err := protoregistry.GlobalTypes.FindMessageByName(md.FullName()); err == nil {
+		return mt
+	}
+	return dynamicpb.NewMessageType(md)
+}
+
+// unaryMethods returns served methods of the service
+func unaryMethods(service protoreflect.ServiceDescriptor) []protoreflect.MethodDescriptor {
+	var res []protoreflect.MethodDescriptor
+	methods := service.Methods()
+	for i := 0; i < methods.Len(); i++ {
+		if md := methods.Get(i); !md.IsStreamingClient() && !md.IsStreamingServer() {
+			res = append(res, md)
+		}
+	}
+	return res
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,186 @@
+package gateway
+
+import (
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	openAPIVersion = "3.0.3"
+	errorSchema    = "Error"
+	bearerScheme   = "bearer"
+)
+
+// wellKnownSchemas are schemas of well-known types with special JSON mapping
+var wellKnownSchemas = map[protoreflect.FullName]map[string]any{
+	"google.protobuf.Timestamp": {"type": "string", "format": "date-time"},
+	"google.protobuf.Duration":  {"type": "string"},
+	"google.protobuf.Empty":     {"type": "object"},
+	"google.protobuf.Struct":    {"type": "object"},
+	"google.protobuf.Value":     {},
+}
+
+// openAPIDocument describes methods of services as they are served,
+// generated from descriptors so new methods are documented once served
+//
+// Schemas of messages are named by their full names, fields by their proto
+// names. 64-bit integers are strings as in the JSON mapping of protobuf.
+func openAPIDocument(services []protoreflect.ServiceDescriptor) map[string]any {
+	schemas := map[string]any{
+		errorSchema: map[string]any{
+			"type":     "object",
+			"required": []string{"error"},
+			"properties": map[string]any{
+				"error": map[string]any{
+					"type":     "object",
+					"required": []string{"code", "status", "message"},
+					"properties": map[string]any{
+						"code":    map[string]any{"type": "integer", "format": "int32", "description": "HTTP status"},
+						"status":  map[string]any{"type": "string", "description": "gRPC status code name"},
+						"message": map[string]any{"type": "string"},
+						"details": map[string]any{
+							"type": "array",
+							"items": map[string]any{
+								"type":                 "object",
+								"properties":           map[string]any{"@type": map[string]any{"type": "string"}},
+								"additionalProperties": true,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	errorResponse := map[string]any{
+		"description": "Error mapped from gRPC status",
+		"content":     jsonContent(ref(errorSchema)),
+	}
+
+	paths := map[string]any{}
+	var tags []any
+	for _, service := range services {
+		tags = append(tags, map[string]any{"name": string(service.FullName())})
+		for _, md := range unaryMethods(service) {
+			paths[methodPath(service, md)] = map[string]any{
+				"post": map[string]any{
+					"operationId": string(service.Name()) + "_" + string(md.Name()),
+					"tags":        []string{string(service.FullName())},
+					"requestBody": map[string]any{
+						"content": jsonContent(messageSchema(schemas, md.Input())),
+					},
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "OK",
+							"content":     jsonContent(messageSchema(schemas, md.Output())),
+						},
+						"default": errorResponse,
+					},
+				},
+			}
+		}
+	}
+
+	return map[string]any{
+		"openapi": openAPIVersion,
+		"info": map[string]any{
+			"title":   "auth-service",
+			"version": "v1",
+		},
+		"tags":  tags,
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				bearerScheme: map[string]any{"type": "http", "scheme": "bearer"},
+			},
+		},
+		// methods decide themselves whether they need a token
+		"security": []any{
+			map[string]any{},
+			map[string]any{bearerScheme: []string{}},
+		},
+	}
+}
+
+// messageSchema returns reference to schema of md, adding schemas of md
+// and messages of its fields
+func messageSchema(schemas map[string]any, md protoreflect.MessageDescriptor) map[string]any {
+	if schema, ok := wellKnownSchemas[md.FullName()]; ok {
+		return schema
+	}
+	name := string(md.FullName())
+	if _, ok := schemas[name]; ok {
+		return ref(name)
+	}
+
+	properties := map[string]any{}
+	schemas[name] = map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		properties[string(fd.Name())] = fieldSchema(schemas, fd)
+	}
+	return ref(name)
+}
+
+func fieldSchema(schemas map[string]any, fd protoreflect.FieldDescriptor) map[string]any {
+	switch {
+	case fd.IsMap():
+		return map[string]any{
+			"type":                 "object",
+			"additionalProperties": valueSchema(schemas, fd.MapValue()),
+		}
+	case fd.IsList():
+		return map[string]any{
+			"type":  "array",
+			"items": valueSchema(schemas, fd),
+		}
+	}
+	return valueSchema(schemas, fd)
+}
+
+// valueSchema returns schema of a single value of the field
+func valueSchema(schemas map[string]any, fd protoreflect.FieldDescriptor) map[string]any {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return map[string]any{"type": "boolean"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return map[string]any{"type": "integer", "format": "uint32"}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return map[string]any{"type": "string", "format": "int64"}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return map[string]any{"type": "string", "format": "uint64"}
+	case protoreflect.FloatKind:
+		return map[string]any{"type": "number", "format": "float"}
+	case protoreflect.DoubleKind:
+		return map[string]any{"type": "number", "format": "double"}
+	case protoreflect.StringKind:
+		return map[string]any{"type": "string"}
+	case protoreflect.BytesKind:
+		return map[string]any{"type": "string", "format": "byte"}
+	case protoreflect.EnumKind:
+		values := fd.Enum().Values()
+		names := make([]string, 0, values.Len())
+		for i := 0; i < values.Len(); i++ {
+			names = append(names, string(values.Get(i).Name()))
+		}
+		return map[string]any{"type": "string", "enum": names}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return messageSchema(schemas, fd.Message())
+	}
+	return map[string]any{}
+}
+
+func ref(schema string) map[string]any {
+	return map[string]any{"$ref": "#/components/schemas/" + schema}
+}
+
+func jsonContent(schema map[string]any) map[string]any {
+	return map[string]any{
+		"application/json": map[string]any{"schema": schema},
+	}
+}
@@ -0,0 +1,168 @@
+package oauth
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Len4i/auth-service/internal/services/oauth"
+)
+
+// deviceAuthorizationResponse is the response of the device authorization
+// endpoint, see RFC 8628 section 3.2
+type deviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
+
+// DeviceAuthorization is the device authorization endpoint, see RFC 8628 section 3.1
+//
+// Apps on devices without a browser get a device code to poll the token
+// endpoint with, and a user code for the user to enter on the verification page.
+func (h *Handler) DeviceAuthorization(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		tokenError(w, http.StatusMethodNotAllowed, "invalid_request", "method must be POST")
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, http.StatusBadRequest, "invalid_request", "request body is not valid")
+		return
+	}
+
+	clientID := appClientID(r)
+	if clientID == "" {
+		tokenError(w, http.StatusBadRequest, "invalid_request", "client_id is required")
+		return
+	}
+
+	device, err := h.oauth.StartDeviceAuthorization(r.Context(), clientID, r.PostForm.Get("scope"))
+	switch {
+	case errors.Is(err, oauth.ErrorInvalidClient):
+		tokenError(w, http.StatusUnauthorized, "invalid_client", "client is not valid")
+		return
+	case errors.Is(err, oauth.ErrorInvalidScope):
+		tokenError(w, http.StatusBadRequest, "invalid_scope", "scope is not valid")
+		return
+	case err != nil:
+		tokenError(w, http.StatusInternalServerError, "server_error", "internal error")
+		return
+	}
+
+	verificationURI := h.issuer + devicePath
+	writeJSON(w, http.StatusOK, deviceAuthorizationResponse{
+		DeviceCode:              device.DeviceCode,
+		UserCode:                device.UserCode,
+		VerificationURI:         verificationURI,
+		VerificationURIComplete: verificationURI + "?" + url.Values{"user_code": {device.UserCode}}.Encode(),
+		ExpiresIn:               int64(device.ExpiresIn / time.Second),
+		Interval:                int64(device.Interval / time.Second),
+	})
+}
+
+// Device is the verification page of the device flow
+//
+// GET asks for the user code unless it's in the query, then user signs in
+// to the app of the code and approves or denies the device, which gets
+// tokens of the login on its next poll.
+func (h *Handler) Device(w http.ResponseWriter, r *http.Request) {
+	if !h.allowMethods(w, r, http.MethodGet, http.MethodPost) {
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		h.render(w, http.StatusBadRequest, "error", loginPage{Error: "The request is not valid."})
+		return
+	}
+
+	page := loginPage{UserCode: r.Form.Get("user_code")}
+	if page.UserCode == "" {
+		h.render(w, http.StatusOK, "device_code", page)
+		return
+	}
+
+	app, err := h.oauth.DeviceApp(r.Context(), page.UserCode)
+	if err != nil {
+		h.deviceError(w, page, err)
+		return
+	}
+
+	page.App = app.Name
+	if r.Method == http.MethodGet {
+		h.render(w, http.StatusOK, "device", page)
+		return
+	}
+
+	page.Approve = r.PostForm.Get("decision") == "approve"
+	token, ok := h.login(w, r, &page, app.ID, "device", "device_mfa")
+	if !ok {
+		return
+	}
+
+	if err := h.oauth.DecideDevice(r.Context(), page.UserCode, token, page.Approve); err != nil {
+		h.deviceError(w, page, err)
+		return
+	}
+
+	h.render(w, http.StatusOK, "device_done", page)
+}
+
+// deviceError renders error of the verification page
+func (h *Handler) deviceError(w http.ResponseWriter, page loginPage, err error) {
+	switch {
+	case errors.Is(err, oauth.ErrorInvalidUserCode):
+		page.Error = "The code is not valid or has expired."
+		h.render(w, http.StatusBadRequest, "device_code", page)
+	case errors.Is(err, oauth.ErrorInvalidLogin):
+		h.render(w, http.StatusForbidden, "error", loginPage{Error: "The sign in is not valid for the app."})
+	default:
+		h.render(w, http.StatusInternalServerError, "error", loginPage{Error: "Something went wrong, please try again."})
+	}
+}
+
+// pollDevice handles the device_code grant, see RFC 8628 section 3.4
+func (h *Handler) pollDevice(w http.ResponseWriter, r *http.Request) {
+	clientID := appClientID(r)
+	deviceCode := r.PostForm.Get("device_code")
+	if clientID == "" || deviceCode == "" {
+		tokenError(w, http.StatusBadRequest, "invalid_request", "client_id and device_code are required")
+		return
+	}
+
+	tokens, err := h.oauth.PollDevice(r.Context(), clientID, deviceCode)
+	switch {
+	case errors.Is(err, oauth.ErrorInvalidClient):
+		tokenError(w, http.StatusUnauthorized, "invalid_client", "client is not valid")
+		return
+	case errors.Is(err, oauth.ErrorAuthorizationPending):
+		tokenError(w, http.StatusBadRequest, "authorization_pending", "user has not approved the device yet")
+		return
+	case errors.Is(err, oauth.ErrorSlowDown):
+		tokenError(w, http.StatusBadRequest, "slow_down", "polling too fast, interval is increased by 5 seconds")
+		return
+	case errors.Is(err, oauth.ErrorAccessDenied):
+		tokenError(w, http.StatusBadRequest, "access_denied", "user denied the device")
+		return
+	case errors.Is(err, oauth.ErrorExpiredToken):
+		tokenError(w, http.StatusBadRequest, "expired_token", "device code has expired")
+		return
+	case errors.Is(err, oauth.ErrorInvalidGrant):
+		tokenError(w, http.StatusBadRequest, "invalid_grant", "device code is not valid")
+		return
+	case err != nil:
+		tokenError(w, http.StatusInternalServerError, "server_error", "internal error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tokenResponse{
+		AccessToken: tokens.AccessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(tokens.ExpiresIn / time.Second),
+		Scope:       tokens.Scope,
+		IDToken:     tokens.IDToken,
+	})
+}
@@ -0,0 +1,137 @@
+package oauth
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Len4i/auth-service/internal/services/auth"
+	"github.com/Len4i/auth-service/internal/services/federation"
+)
+
+// FederationCallbackURL returns the URL upstream providers redirect users back to,
+// it has to be registered at every provider
+func FederationCallbackURL(issuer string) string {
+	return strings.TrimSuffix(issuer, "/") + callbackPath
+}
+
+// startFederation redirects user to sign in at the upstream provider
+// of the connector, the authorization request is resumed by Callback
+func (h *Handler) startFederation(w http.ResponseWriter, r *http.Request, page loginPage, appID int, connector string) {
+	request := url.Values{
+		"client_id":             {page.Request.ClientID},
+		"redirect_uri":          {page.Request.RedirectURI},
+		"response_type":         {page.Request.ResponseType},
+		"scope":                 {page.Request.Scope},
+		"nonce":                 {page.Request.Nonce},
+		"code_challenge":        {page.Request.CodeChallenge},
+		"code_challenge_method": {page.Request.CodeChallengeMethod},
+		"state":                 {page.State},
+	}
+
+	authURL, err := h.federation.Start(r.Context(), connector, appID, request.Encode())
+	switch {
+	case errors.Is(err, federation.ErrorConnectorNotFound):
+		page.Error = "The sign in option is not available."
+		h.render(w, http.StatusBadRequest, "login", page)
+		return
+	case errors.Is(err, federation.ErrorProviderFailed):
+		page.Error = "The sign in option is not available right now, please try again later."
+		h.render(w, http.StatusBadGateway, "login", page)
+		return
+	case err != nil:
+		h.render(w, http.StatusInternalServerError, "error", loginPage{Error: "Something went wrong, please try again."})
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// Callback is where upstream providers redirect users back to
+//
+// GET completes the login at the provider. If the identity has to be
+// linked to an existing account, the link page asks for its password
+// and posts it back. Once logged in, user is redirected to the app
+// with the authorization code as from the login page.
+func (h *Handler) Callback(w http.ResponseWriter, r *http.Request) {
+	if !h.allowMethods(w, r, http.MethodGet, http.MethodPost) {
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		h.render(w, http.StatusBadRequest, "error", loginPage{Error: "The request is not valid."})
+		return
+	}
+
+	var request, token string
+	var err error
+	if r.Method == http.MethodGet {
+		// the provider sends an error instead of the code if user didn't sign in
+		request, token, err = h.federation.Finish(r.Context(), r.Form.Get("state"), r.Form.Get("code"))
+	} else {
+		request, token, err = h.federation.Link(r.Context(), r.PostForm.Get("link_token"), r.PostForm.Get("password"))
+	}
+
+	form, parseErr := url.ParseQuery(request)
+	if request == "" || parseErr != nil {
+		h.render(w, http.StatusBadRequest, "error", loginPage{Error: "The sign in has expired, please try again."})
+		return
+	}
+
+	req, state := authorizationRequest(form)
+	app, authorizeErr := h.oauth.Authorize(r.Context(), req)
+	if authorizeErr != nil {
+		h.authorizeError(w, r, req, state, authorizeErr)
+		return
+	}
+	page := loginPage{
+		App:     app.Name,
+		Request: req,
+		State:   state,
+	}
+
+	var linkErr *federation.LinkRequiredError
+	var mfaErr *auth.MFARequiredError
+	switch {
+	case errors.As(err, &linkErr):
+		page.Email = linkErr.Email
+		page.LinkToken = linkErr.Token
+		h.render(w, http.StatusOK, "link", page)
+		return
+	case errors.Is(err, federation.ErrorInvalidCredentials):
+		page.Email = r.PostForm.Get("email")
+		page.LinkToken = r.PostForm.Get("link_token")
+		page.Error = "Invalid password."
+		h.render(w, http.StatusUnauthorized, "link", page)
+		return
+	case errors.As(err, &mfaErr):
+		page.Challenge = mfaErr.Challenge
+		h.render(w, http.StatusOK, "mfa", page)
+		return
+	case errors.Is(err, federation.ErrorUpstreamDenied):
+		redirect(w, r, req.RedirectURI, url.Values{
+			"error":             {"access_denied"},
+			"error_description": {"sign in at the identity provider failed"},
+		}, state)
+		return
+	case errors.Is(err, federation.ErrorAccountNotFound),
+		errors.Is(err, auth.ErrorRegistrationClosed),
+		errors.Is(err, auth.ErrorInviteRequired),
+		errors.Is(err, auth.ErrorEmailDomainNotAllowed):
+		h.render(w, http.StatusForbidden, "error", loginPage{Error: "There is no account for this sign in."})
+		return
+	case errors.Is(err, federation.ErrorIdentityLinked),
+		errors.Is(err, federation.ErrorInvalidLink):
+		h.render(w, http.StatusConflict, "error", loginPage{Error: "The sign in has expired, please try again."})
+		return
+	case errors.Is(err, federation.ErrorProviderFailed):
+		h.render(w, http.StatusBadGateway, "error", loginPage{Error: "The sign in option is not available right now, please try again later."})
+		return
+	case err != nil:
+		h.render(w, http.StatusInternalServerError, "error", loginPage{Error: "Something went wrong, please try again."})
+		return
+	}
+
+	h.issueCode(w, r, req, state, token)
+}
@@ -0,0 +1,487 @@
+package oauth
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/lib/jwt"
+	"github.com/Len4i/auth-service/internal/services/auth"
+	"github.com/Len4i/auth-service/internal/services/clients"
+	"github.com/Len4i/auth-service/internal/services/federation"
+	"github.com/Len4i/auth-service/internal/services/oauth"
+)
+
+const (
+	authorizePath = "/oauth/authorize"
+	tokenPath     = "/oauth/token"
+	discoveryPath = "/.well-known/openid-configuration"
+	jwksPath      = "/oauth/jwks"
+	userInfoPath  = "/oauth/userinfo"
+
+	deviceAuthorizationPath = "/oauth/device_authorization"
+	devicePath              = "/oauth/device"
+	callbackPath            = "/oauth/callback"
+)
+
+const (
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeClientCredentials = "client_credentials"
+	GrantTypeDeviceCode        = "urn:ietf:params:oauth:grant-type:device_code"
+
+	// clientAssertionType is the only client assertion type, see RFC 7523 section 2.2
+	clientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+)
+
+//go:embed templates/*.html
+var templatesFS embed.FS
+
+var pages = template.Must(template.ParseFS(templatesFS, "templates/*.html"))
+
+type OAuth interface {
+	Authorize(ctx context.Context, req oauth.AuthorizationRequest) (models.App, error)
+	IssueCode(ctx context.Context, req oauth.AuthorizationRequest, token string) (code string, err error)
+	ExchangeCode(ctx context.Context, req oauth.CodeExchange) (oauth.IssuedTokens, error)
+	UserInfo(ctx context.Context, accessToken string) (map[string]any, error)
+	SigningKeys() []jwt.JWK
+	StartDeviceAuthorization(ctx context.Context, clientID string, scope string) (oauth.DeviceAuthorization, error)
+	DeviceApp(ctx context.Context, userCode string) (models.App, error)
+	DecideDevice(ctx context.Context, userCode string, token string, approve bool) error
+	PollDevice(ctx context.Context, clientID string, deviceCode string) (oauth.IssuedTokens, error)
+}
+
+// Authenticator logs users in on the login page
+type Authenticator interface {
+	Login(ctx context.Context, email string, password string, appID int, orgID int64) (token string, err error)
+	VerifyMFA(ctx context.Context, challenge string, code string) (token string, err error)
+}
+
+// Federation logs users in through upstream identity providers
+type Federation interface {
+	Connectors() []federation.Connector
+	Start(ctx context.Context, connectorID string, appID int, request string) (authURL string, err error)
+	Finish(ctx context.Context, state string, code string) (request string, token string, err error)
+	Link(ctx context.Context, linkToken string, password string) (request string, token string, err error)
+}
+
+type Clients interface {
+	IssueToken(
+		ctx context.Context,
+		creds clients.Credentials,
+		scopes []string,
+	) (token string, granted []string, ttl time.Duration, err error)
+}
+
+type Handler struct {
+	log        *slog.Logger
+	issuer     string
+	oauth      OAuth
+	auth       Authenticator
+	clients    Clients
+	federation Federation
+}
+
+// Register adds the authorization endpoint with its login page and
+// the callback of upstream providers, the token endpoint, device flow
+// endpoints and OpenID Connect endpoints to mux
+//
+// issuer is the base URL of the endpoints.
+func Register(
+	mux *http.ServeMux,
+	log *slog.Logger,
+	issuer string,
+	oauth OAuth,
+	auth Authenticator,
+	clients Clients,
+	federation Federation,
+) {
+	h := &Handler{
+		log:        log,
+		issuer:     strings.TrimSuffix(issuer, "/"),
+		oauth:      oauth,
+		auth:       auth,
+		clients:    clients,
+		federation: federation,
+	}
+	mux.HandleFunc(authorizePath, h.Authorize)
+	mux.HandleFunc(tokenPath, h.Token)
+	mux.HandleFunc(discoveryPath, h.Discovery)
+	mux.HandleFunc(jwksPath, h.JWKS)
+	mux.HandleFunc(userInfoPath, h.UserInfo)
+	mux.HandleFunc(deviceAuthorizationPath, h.DeviceAuthorization)
+	mux.HandleFunc(devicePath, h.Device)
+	mux.HandleFunc(callbackPath, h.Callback)
+}
+
+// loginPage is the data of login, mfa, link, device and error pages
+//
+// Connectors are upstream providers offered on the login page, LinkToken
+// is set on the link page. UserCode and Approve are set on device pages only.
+type loginPage struct {
+	App        string
+	Request    oauth.AuthorizationRequest
+	State      string
+	Email      string
+	Challenge  string
+	Connectors []federation.Connector
+	LinkToken  string
+	UserCode   string
+	Approve    bool
+	Error      string
+}
+
+// Authorize is the authorization endpoint, see RFC 6749 section 3.1
+//
+// GET renders the login page, the page posts credentials back along with
+// the request, or the connector to sign in with at an upstream provider.
+// If user has a second factor, the code is asked on another page.
+// Once logged in, user is redirected with the authorization code.
+func (h *Handler) Authorize(w http.ResponseWriter, r *http.Request) {
+	if !h.allowMethods(w, r, http.MethodGet, http.MethodPost) {
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		h.render(w, http.StatusBadRequest, "error", loginPage{Error: "The request is not valid."})
+		return
+	}
+
+	req, state := authorizationRequest(r.Form)
+
+	app, err := h.oauth.Authorize(r.Context(), req)
+	if err != nil {
+		h.authorizeError(w, r, req, state, err)
+		return
+	}
+
+	page := loginPage{
+		App:        app.Name,
+		Request:    req,
+		State:      state,
+		Connectors: h.federation.Connectors(),
+	}
+	if r.Method == http.MethodGet {
+		h.render(w, http.StatusOK, "login", page)
+		return
+	}
+
+	if connector := r.PostForm.Get("connector"); connector != "" {
+		h.startFederation(w, r, page, app.ID, connector)
+		return
+	}
+
+	token, ok := h.login(w, r, &page, app.ID, "login", "mfa")
+	if !ok {
+		return
+	}
+
+	h.issueCode(w, r, req, state, token)
+}
+
+// issueCode redirects user logged in with token back to the app
+// with the authorization code
+func (h *Handler) issueCode(w http.ResponseWriter, r *http.Request, req oauth.AuthorizationRequest, state string, token string) {
+	code, err := h.oauth.IssueCode(r.Context(), req, token)
+	if err != nil {
+		h.authorizeError(w, r, req, state, err)
+		return
+	}
+
+	redirect(w, r, req.RedirectURI, url.Values{"code": {code}}, state)
+}
+
+// authorizationRequest reads authorization request and its state from form
+func authorizationRequest(form url.Values) (oauth.AuthorizationRequest, string) {
+	return oauth.AuthorizationRequest{
+		ClientID:            form.Get("client_id"),
+		RedirectURI:         form.Get("redirect_uri"),
+		ResponseType:        form.Get("response_type"),
+		Scope:               form.Get("scope"),
+		Nonce:               form.Get("nonce"),
+		CodeChallenge:       form.Get("code_challenge"),
+		CodeChallengeMethod: form.Get("code_challenge_method"),
+	}, form.Get("state")
+}
+
+// login logs user in to the app with credentials posted by the login page,
+// or with the code posted by the mfa page
+//
+// If it fails, the page to continue with is rendered and ok is false.
+func (h *Handler) login(
+	w http.ResponseWriter,
+	r *http.Request,
+	page *loginPage,
+	appID int,
+	loginName string,
+	mfaName string,
+) (token string, ok bool) {
+	var err error
+	challenge := r.PostForm.Get("mfa_challenge")
+	if challenge != "" {
+		token, err = h.auth.VerifyMFA(r.Context(), challenge, r.PostForm.Get("code"))
+	} else {
+		page.Email = r.PostForm.Get("email")
+		token, err = h.auth.Login(r.Context(), page.Email, r.PostForm.Get("password"), appID, 0)
+	}
+
+	var mfaErr *auth.MFARequiredError
+	switch {
+	case errors.As(err, &mfaErr):
+		page.Challenge = mfaErr.Challenge
+		h.render(w, http.StatusOK, mfaName, *page)
+		return "", false
+	case errors.Is(err, auth.ErrorInvalidMFACode):
+		page.Challenge = challenge
+		page.Error = "The code is not valid."
+		h.render(w, http.StatusUnauthorized, mfaName, *page)
+		return "", false
+	case errors.Is(err, auth.ErrorInvalidChallenge):
+		page.Error = "The sign in has expired, please try again."
+		h.render(w, http.StatusUnauthorized, loginName, *page)
+		return "", false
+	case errors.Is(err, auth.ErrorInvalidCredentials):
+		page.Error = "Invalid email or password."
+		h.render(w, http.StatusUnauthorized, loginName, *page)
+		return "", false
+	case err != nil:
+		h.render(w, http.StatusInternalServerError, "error", loginPage{Error: "Something went wrong, please try again."})
+		return "", false
+	}
+
+	return token, true
+}
+
+// authorizeError sends error of the authorization request to the redirect
+// URI of the app, or renders it if the redirect URI can't be trusted
+func (h *Handler) authorizeError(
+	w http.ResponseWriter,
+	r *http.Request,
+	req oauth.AuthorizationRequest,
+	state string,
+	err error,
+) {
+	var code, description string
+	switch {
+	case errors.Is(err, oauth.ErrorInvalidClient):
+		h.render(w, http.StatusBadRequest, "error", loginPage{Error: "The app is not known."})
+		return
+	case errors.Is(err, oauth.ErrorInvalidRedirectURI):
+		h.render(w, http.StatusBadRequest, "error", loginPage{Error: "The redirect URI is not registered for the app."})
+		return
+	case errors.Is(err, oauth.ErrorUnsupportedResponseType):
+		code, description = "unsupported_response_type", "response_type must be code"
+	case errors.Is(err, oauth.ErrorInvalidCodeChallenge):
+		code, description = "invalid_request", "code_challenge with code_challenge_method S256 is required"
+	case errors.Is(err, oauth.ErrorInvalidScope):
+		code, description = "invalid_scope", "scope is not valid"
+	case errors.Is(err, oauth.ErrorInvalidLogin):
+		code, description = "access_denied", "login is not valid for the app"
+	default:
+		code, description = "server_error", "internal error"
+	}
+
+	redirect(w, r, req.RedirectURI, url.Values{"error": {code}, "error_description": {description}}, state)
+}
+
+// Token is the token endpoint, see RFC 6749 section 3.2
+//
+// It supports the authorization_code grant of apps, which must send
+// the PKCE code verifier, the device_code grant of apps polling for
+// an approved device, and the client_credentials grant of clients
+// authenticated with a secret or a JWT assertion.
+func (h *Handler) Token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		tokenError(w, http.StatusMethodNotAllowed, "invalid_request", "method must be POST")
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, http.StatusBadRequest, "invalid_request", "request body is not valid")
+		return
+	}
+
+	switch grantType := r.PostForm.Get("grant_type"); grantType {
+	case GrantTypeAuthorizationCode:
+		h.exchangeCode(w, r)
+	case GrantTypeClientCredentials:
+		h.clientCredentials(w, r)
+	case GrantTypeDeviceCode:
+		h.pollDevice(w, r)
+	case "":
+		tokenError(w, http.StatusBadRequest, "invalid_request", "grant_type is required")
+	default:
+		tokenError(w, http.StatusBadRequest, "unsupported_grant_type", "grant_type is not supported")
+	}
+}
+
+func (h *Handler) exchangeCode(w http.ResponseWriter, r *http.Request) {
+	req := oauth.CodeExchange{
+		Code:         r.PostForm.Get("code"),
+		RedirectURI:  r.PostForm.Get("redirect_uri"),
+		CodeVerifier: r.PostForm.Get("code_verifier"),
+	}
+	req.ClientID = appClientID(r)
+	if req.ClientID == "" || req.Code == "" || req.RedirectURI == "" || req.CodeVerifier == "" {
+		tokenError(w, http.StatusBadRequest, "invalid_request", "client_id, code, redirect_uri and code_verifier are required")
+		return
+	}
+
+	tokens, err := h.oauth.ExchangeCode(r.Context(), req)
+	switch {
+	case errors.Is(err, oauth.ErrorInvalidClient):
+		tokenError(w, http.StatusUnauthorized, "invalid_client", "client is not valid")
+		return
+	case errors.Is(err, oauth.ErrorInvalidGrant):
+		tokenError(w, http.StatusBadRequest, "invalid_grant", "authorization code is not valid")
+		return
+	case err != nil:
+		tokenError(w, http.StatusInternalServerError, "server_error", "internal error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tokenResponse{
+		AccessToken: tokens.AccessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(tokens.ExpiresIn / time.Second),
+		Scope:       tokens.Scope,
+		IDToken:     tokens.IDToken,
+	})
+}
+
+func (h *Handler) clientCredentials(w http.ResponseWriter, r *http.Request) {
+	creds, ok := clientCredentials(r)
+	if !ok {
+		tokenError(w, http.StatusBadRequest, "invalid_request", "client authentication is required")
+		return
+	}
+
+	token, granted, ttl, err := h.clients.IssueToken(r.Context(), creds, strings.Fields(r.PostForm.Get("scope")))
+	switch {
+	case errors.Is(err, clients.ErrorInvalidCredentials):
+		if _, _, basic := r.BasicAuth(); basic {
+			w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+		}
+		tokenError(w, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+		return
+	case errors.Is(err, clients.ErrorScopeNotAllowed):
+		tokenError(w, http.StatusBadRequest, "invalid_scope", "scope is not allowed")
+		return
+	case err != nil:
+		tokenError(w, http.StatusInternalServerError, "server_error", "internal error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(ttl / time.Second),
+		Scope:       strings.Join(granted, " "),
+	})
+}
+
+// appClientID returns client id of a public client, some libraries
+// send it with an empty secret as basic auth
+func appClientID(r *http.Request) string {
+	if clientID := r.PostForm.Get("client_id"); clientID != "" {
+		return clientID
+	}
+	if user, _, ok := r.BasicAuth(); ok {
+		clientID, _ := url.QueryUnescape(user)
+		return clientID
+	}
+	return ""
+}
+
+// clientCredentials reads client authentication from basic auth,
+// the client_secret parameter or a client assertion
+func clientCredentials(r *http.Request) (clients.Credentials, bool) {
+	if user, pass, ok := r.BasicAuth(); ok {
+		// basic auth credentials are form-encoded, see RFC 6749 section 2.3.1
+		clientID, errID := url.QueryUnescape(user)
+		clientSecret, errSecret := url.QueryUnescape(pass)
+		return clients.Credentials{ClientID: clientID, Secret: clientSecret},
+			errID == nil && errSecret == nil && clientID != "" && clientSecret != ""
+	}
+
+	creds := clients.Credentials{
+		ClientID: r.PostForm.Get("client_id"),
+		Secret:   r.PostForm.Get("client_secret"),
+	}
+	if assertion := r.PostForm.Get("client_assertion"); assertion != "" {
+		if r.PostForm.Get("client_assertion_type") != clientAssertionType {
+			return clients.Credentials{}, false
+		}
+		creds.Secret = ""
+		creds.Assertion = assertion
+		return creds, true
+	}
+
+	return creds, creds.ClientID != "" && creds.Secret != ""
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope,omitempty"`
+	IDToken     string `json:"id_token,omitempty"`
+}
+
+type errorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// tokenError writes error response of the token endpoint, see RFC 6749 section 5.2
+func tokenError(w http.ResponseWriter, status int, code string, description string) {
+	writeJSON(w, status, errorResponse{Error: code, ErrorDescription: description})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// redirect sends user back to the app with params and state
+// added to the query of the redirect URI
+func redirect(w http.ResponseWriter, r *http.Request, redirectURI string, params url.Values, state string) {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	query := u.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	if state != "" {
+		query.Set("state", state)
+	}
+	u.RawQuery = query.Encode()
+
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+// render writes page, pages must not be cached or framed
+func (h *Handler) render(w http.ResponseWriter, status int, name string, page loginPage) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; frame-ancestors 'none'")
+	w.WriteHeader(status)
+	if err := pages.ExecuteTemplate(w, name, page); err != nil {
+		h.log.Error("failed to render page", slog.String("page", name), "error", err)
+	}
+}
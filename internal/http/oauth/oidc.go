@@ -0,0 +1,123 @@
+package oauth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Len4i/auth-service/internal/lib/jwt"
+	"github.com/Len4i/auth-service/internal/services/oauth"
+)
+
+// providerMetadata is the OpenID Provider configuration,
+// see OpenID Connect Discovery section 3
+type providerMetadata struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+	DeviceAuthorizationEndpoint       string   `json:"device_authorization_endpoint"`
+}
+
+// Discovery serves the OpenID Provider configuration,
+// it's not found if OpenID Connect is disabled
+func (h *Handler) Discovery(w http.ResponseWriter, r *http.Request) {
+	if !h.allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	keys := h.oauth.SigningKeys()
+	if len(keys) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	// apps are public clients, only clients authenticate at the token endpoint
+	writeJSON(w, http.StatusOK, providerMetadata{
+		Issuer:                            h.issuer,
+		AuthorizationEndpoint:             h.issuer + authorizePath,
+		TokenEndpoint:                     h.issuer + tokenPath,
+		UserInfoEndpoint:                  h.issuer + userInfoPath,
+		JWKSURI:                           h.issuer + jwksPath,
+		ScopesSupported:                   oauth.ScopesSupported,
+		ResponseTypesSupported:            []string{oauth.ResponseTypeCode},
+		GrantTypesSupported:               []string{GrantTypeAuthorizationCode, GrantTypeClientCredentials, GrantTypeDeviceCode},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{keys[0].Algorithm},
+		TokenEndpointAuthMethodsSupported: []string{"none", "client_secret_basic", "client_secret_post", "private_key_jwt"},
+		CodeChallengeMethodsSupported:     []string{oauth.CodeChallengeS256},
+		ClaimsSupported:                   oauth.ClaimsSupported,
+		DeviceAuthorizationEndpoint:       h.issuer + deviceAuthorizationPath,
+	})
+}
+
+// JWKS serves public keys verifying ID tokens
+func (h *Handler) JWKS(w http.ResponseWriter, r *http.Request) {
+	if !h.allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	keys := h.oauth.SigningKeys()
+	if len(keys) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Keys []jwt.JWK `json:"keys"`
+	}{
+		Keys: keys,
+	})
+}
+
+// UserInfo is the userinfo endpoint, see OpenID Connect Core section 5.3
+//
+// The access token is sent as a bearer token.
+func (h *Handler) UserInfo(w http.ResponseWriter, r *http.Request) {
+	if !h.allowMethods(w, r, http.MethodGet, http.MethodPost) {
+		return
+	}
+
+	accessToken, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || accessToken == "" {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="userinfo"`)
+		writeJSON(w, http.StatusUnauthorized, errorResponse{Error: "invalid_request", ErrorDescription: "bearer token is required"})
+		return
+	}
+
+	info, err := h.oauth.UserInfo(r.Context(), accessToken)
+	switch {
+	case errors.Is(err, oauth.ErrorInvalidToken):
+		w.Header().Set("WWW-Authenticate", `Bearer realm="userinfo", error="invalid_token"`)
+		writeJSON(w, http.StatusUnauthorized, errorResponse{Error: "invalid_token", ErrorDescription: "access token is not valid"})
+		return
+	case errors.Is(err, oauth.ErrorInsufficientScope):
+		w.Header().Set("WWW-Authenticate", `Bearer realm="userinfo", error="insufficient_scope", scope="openid"`)
+		writeJSON(w, http.StatusForbidden, errorResponse{Error: "insufficient_scope", ErrorDescription: "openid scope is not granted"})
+		return
+	case err != nil:
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "server_error", ErrorDescription: "internal error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, info)
+}
+
+// allowMethods responds with 405 if request method is not one of methods
+func (h *Handler) allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, method := range methods {
+		if r.Method == method {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	return false
+}
@@ -0,0 +1,667 @@
+// Package scim serves the SCIM 2.0 protocol (RFC 7643, RFC 7644) identity
+// providers of orgs provision users and groups of the orgs with
+//
+// Requests are authenticated with a bearer token of the org, every resource
+// is scoped to it. Users are identified by userName, which is their email,
+// their id is the ID of the user in the service.
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/services/provisioning"
+)
+
+const (
+	basePath                  = "/scim/v2"
+	usersPath                 = basePath + "/Users"
+	groupsPath                = basePath + "/Groups"
+	serviceProviderConfigPath = basePath + "/ServiceProviderConfig"
+
+	userSchema                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	groupSchema                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	listResponseSchema          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	errorSchema                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+	serviceProviderConfigSchema = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+
+	contentType = "application/scim+json"
+	// maxBodySize bounds JSON bodies of requests, groups may list many members
+	maxBodySize = 1 << 20
+)
+
+// Provisioning manages users and groups of orgs
+type Provisioning interface {
+	Authenticate(ctx context.Context, token string) (orgID int64, err error)
+
+	CreateUser(ctx context.Context, user models.SCIMUser) (models.SCIMUser, error)
+	User(ctx context.Context, orgID int64, userID int64) (models.SCIMUser, error)
+	ListUsers(ctx context.Context, orgID int64, filter string, startIndex int, count int) ([]models.SCIMUser, int, error)
+	ReplaceUser(ctx context.Context, user models.SCIMUser) (models.SCIMUser, error)
+	PatchUser(ctx context.Context, orgID int64, userID int64, ops []provisioning.PatchOp) (models.SCIMUser, error)
+	DeleteUser(ctx context.Context, orgID int64, userID int64) error
+
+	CreateGroup(ctx context.Context, group models.SCIMGroup) (models.SCIMGroup, error)
+	Group(ctx context.Context, orgID int64, id int64) (models.SCIMGroup, error)
+	ListGroups(ctx context.Context, orgID int64, filter string, startIndex int, count int) ([]models.SCIMGroup, int, error)
+	ReplaceGroup(ctx context.Context, group models.SCIMGroup) (models.SCIMGroup, error)
+	PatchGroup(ctx context.Context, orgID int64, id int64, ops []provisioning.PatchOp) (models.SCIMGroup, error)
+	DeleteGroup(ctx context.Context, orgID int64, id int64) error
+}
+
+type Handler struct {
+	log          *slog.Logger
+	issuer       string
+	provisioning Provisioning
+}
+
+// Register adds SCIM endpoints to mux
+//
+// issuer is the base URL of the endpoints, locations of resources are built from it.
+func Register(mux *http.ServeMux, log *slog.Logger, issuer string, provisioning Provisioning) {
+	h := &Handler{
+		log:          log,
+		issuer:       strings.TrimSuffix(issuer, "/"),
+		provisioning: provisioning,
+	}
+	mux.HandleFunc(usersPath, h.authenticated(h.Users))
+	mux.HandleFunc(usersPath+"/", h.authenticated(h.User))
+	mux.HandleFunc(groupsPath, h.authenticated(h.Groups))
+	mux.HandleFunc(groupsPath+"/", h.authenticated(h.Group))
+	mux.HandleFunc(serviceProviderConfigPath, h.ServiceProviderConfig)
+}
+
+type multiValue struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+type name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+type meta struct {
+	ResourceType string `json:"resourceType"`
+	Created      string `json:"created"`
+	LastModified string `json:"lastModified"`
+	Location     string `json:"location"`
+}
+
+type userResource struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id"`
+	ExternalID  string       `json:"externalId,omitempty"`
+	UserName    string       `json:"userName"`
+	Name        *name        `json:"name,omitempty"`
+	DisplayName string       `json:"displayName,omitempty"`
+	Locale      string       `json:"locale,omitempty"`
+	Emails      []multiValue `json:"emails"`
+	Photos      []multiValue `json:"photos,omitempty"`
+	Active      bool         `json:"active"`
+	Groups      []multiValue `json:"groups,omitempty"`
+	Meta        meta         `json:"meta"`
+}
+
+// userRequest is a user to create or replace, active is true if missing
+type userRequest struct {
+	UserName    string       `json:"userName"`
+	ExternalID  string       `json:"externalId"`
+	Name        *name        `json:"name"`
+	DisplayName string       `json:"displayName"`
+	Locale      string       `json:"locale"`
+	Photos      []multiValue `json:"photos"`
+	Active      *bool        `json:"active"`
+}
+
+type groupResource struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id"`
+	ExternalID  string       `json:"externalId,omitempty"`
+	DisplayName string       `json:"displayName"`
+	Members     []multiValue `json:"members"`
+	Meta        meta         `json:"meta"`
+}
+
+type groupRequest struct {
+	DisplayName string       `json:"displayName"`
+	ExternalID  string       `json:"externalId"`
+	Members     []multiValue `json:"members"`
+}
+
+type patchRequest struct {
+	Schemas    []string `json:"schemas"`
+	Operations []struct {
+		Op    string `json:"op"`
+		Path  string `json:"path"`
+		Value any    `json:"value"`
+	} `json:"Operations"`
+}
+
+type listResponse struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int      `json:"totalResults"`
+	StartIndex   int      `json:"startIndex"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	Resources    []any    `json:"Resources"`
+}
+
+type errorResponse struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	SCIMType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+type supported struct {
+	Supported bool `json:"supported"`
+}
+
+type filterSupported struct {
+	Supported  bool `json:"supported"`
+	MaxResults int  `json:"maxResults"`
+}
+
+type bulkSupported struct {
+	Supported      bool `json:"supported"`
+	MaxOperations  int  `json:"maxOperations"`
+	MaxPayloadSize int  `json:"maxPayloadSize"`
+}
+
+type authenticationScheme struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Primary     bool   `json:"primary"`
+}
+
+type serviceProviderConfig struct {
+	Schemas               []string               `json:"schemas"`
+	Patch                 supported              `json:"patch"`
+	Bulk                  bulkSupported          `json:"bulk"`
+	Filter                filterSupported        `json:"filter"`
+	ChangePassword        supported              `json:"changePassword"`
+	Sort                  supported              `json:"sort"`
+	ETag                  supported              `json:"etag"`
+	AuthenticationSchemes []authenticationScheme `json:"authenticationSchemes"`
+}
+
+// orgKey keys ID of the org the request is authenticated for in its context
+type orgKey struct{}
+
+// authenticated authenticates requests of next with the bearer token of an org
+func (h *Handler) authenticated(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "", "bearer token is required")
+			return
+		}
+
+		orgID, err := h.provisioning.Authenticate(r.Context(), token)
+		switch {
+		case errors.Is(err, provisioning.ErrorInvalidToken):
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			writeError(w, http.StatusUnauthorized, "", "bearer token is not valid")
+			return
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, "", "internal error")
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), orgKey{}, orgID)))
+	}
+}
+
+// Users lists users of the org or provisions a user to it
+func (h *Handler) Users(w http.ResponseWriter, r *http.Request) {
+	orgID := r.Context().Value(orgKey{}).(int64)
+
+	switch r.Method {
+	case http.MethodGet:
+		startIndex, count, ok := pagination(w, r)
+		if !ok {
+			return
+		}
+		users, total, err := h.provisioning.ListUsers(r.Context(), orgID, r.URL.Query().Get("filter"), startIndex, count)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		resources := make([]any, 0, len(users))
+		for _, user := range users {
+			resources = append(resources, h.userResource(user))
+		}
+		writeList(w, resources, total, startIndex)
+	case http.MethodPost:
+		var req userRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+		user, err := h.provisioning.CreateUser(r.Context(), req.user(orgID, 0))
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		resource := h.userResource(user)
+		w.Header().Set("Location", resource.Meta.Location)
+		writeJSON(w, http.StatusCreated, resource)
+	default:
+		methodNotAllowed(w, "GET, POST")
+	}
+}
+
+// User returns, replaces, patches or deprovisions user of the org
+//
+// Deprovisioning removes the user from the org and its groups, the user is kept.
+func (h *Handler) User(w http.ResponseWriter, r *http.Request) {
+	orgID := r.Context().Value(orgKey{}).(int64)
+	userID, ok := resourceID(r, usersPath)
+	if !ok {
+		writeError(w, http.StatusNotFound, "", "user not found")
+		return
+	}
+
+	var user models.SCIMUser
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		user, err = h.provisioning.User(r.Context(), orgID, userID)
+	case http.MethodPut:
+		var req userRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+		user, err = h.provisioning.ReplaceUser(r.Context(), req.user(orgID, userID))
+	case http.MethodPatch:
+		ops, ok := readPatch(w, r)
+		if !ok {
+			return
+		}
+		user, err = h.provisioning.PatchUser(r.Context(), orgID, userID, ops)
+	case http.MethodDelete:
+		if err := h.provisioning.DeleteUser(r.Context(), orgID, userID); err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		methodNotAllowed(w, "GET, PUT, PATCH, DELETE")
+		return
+	}
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.userResource(user))
+}
+
+// Groups lists groups of the org or creates a group
+func (h *Handler) Groups(w http.ResponseWriter, r *http.Request) {
+	orgID := r.Context().Value(orgKey{}).(int64)
+
+	switch r.Method {
+	case http.MethodGet:
+		startIndex, count, ok := pagination(w, r)
+		if !ok {
+			return
+		}
+		groups, total, err := h.provisioning.ListGroups(r.Context(), orgID, r.URL.Query().Get("filter"), startIndex, count)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		resources := make([]any, 0, len(groups))
+		for _, group := range groups {
+			resources = append(resources, h.groupResource(group))
+		}
+		writeList(w, resources, total, startIndex)
+	case http.MethodPost:
+		var req groupRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+		group, ok := req.group(w, orgID, 0)
+		if !ok {
+			return
+		}
+		group, err := h.provisioning.CreateGroup(r.Context(), group)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		resource := h.groupResource(group)
+		w.Header().Set("Location", resource.Meta.Location)
+		writeJSON(w, http.StatusCreated, resource)
+	default:
+		methodNotAllowed(w, "GET, POST")
+	}
+}
+
+// Group returns, replaces, patches or deletes group of the org
+func (h *Handler) Group(w http.ResponseWriter, r *http.Request) {
+	orgID := r.Context().Value(orgKey{}).(int64)
+	id, ok := resourceID(r, groupsPath)
+	if !ok {
+		writeError(w, http.StatusNotFound, "", "group not found")
+		return
+	}
+
+	var group models.SCIMGroup
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		group, err = h.provisioning.Group(r.Context(), orgID, id)
+	case http.MethodPut:
+		var req groupRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+		var ok bool
+		if group, ok = req.group(w, orgID, id); !ok {
+			return
+		}
+		group, err = h.provisioning.ReplaceGroup(r.Context(), group)
+	case http.MethodPatch:
+		ops, ok := readPatch(w, r)
+		if !ok {
+			return
+		}
+		group, err = h.provisioning.PatchGroup(r.Context(), orgID, id, ops)
+	case http.MethodDelete:
+		if err := h.provisioning.DeleteGroup(r.Context(), orgID, id); err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		methodNotAllowed(w, "GET, PUT, PATCH, DELETE")
+		return
+	}
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.groupResource(group))
+}
+
+// ServiceProviderConfig describes SCIM features the service supports,
+// it doesn't require authentication
+func (h *Handler) ServiceProviderConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, serviceProviderConfig{
+		Schemas: []string{serviceProviderConfigSchema},
+		Patch:   supported{Supported: true},
+		Filter:  filterSupported{Supported: true, MaxResults: provisioning.MaxPageSize},
+		AuthenticationSchemes: []authenticationScheme{{
+			Type:        "oauthbearertoken",
+			Name:        "OAuth Bearer Token",
+			Description: "SCIM token of the org",
+			Primary:     true,
+		}},
+	})
+}
+
+func (req userRequest) user(orgID int64, userID int64) models.SCIMUser {
+	user := models.SCIMUser{
+		OrgID:      orgID,
+		UserID:     userID,
+		ExternalID: req.ExternalID,
+		Email:      req.UserName,
+		Active:     req.Active == nil || *req.Active,
+		Profile: models.Profile{
+			Name:   req.DisplayName,
+			Locale: req.Locale,
+		},
+	}
+	if req.Name != nil {
+		user.Profile.GivenName = req.Name.GivenName
+		user.Profile.FamilyName = req.Name.FamilyName
+		if user.Profile.Name == "" {
+			user.Profile.Name = req.Name.Formatted
+		}
+	}
+	for i, photo := range req.Photos {
+		if photo.Primary || i == 0 {
+			user.Profile.Picture = photo.Value
+		}
+	}
+	return user
+}
+
+// group converts the request to a group, writing the error if a member is not valid
+func (req groupRequest) group(w http.ResponseWriter, orgID int64, id int64) (models.SCIMGroup, bool) {
+	group := models.SCIMGroup{
+		ID:          id,
+		OrgID:       orgID,
+		DisplayName: req.DisplayName,
+		ExternalID:  req.ExternalID,
+	}
+	for _, member := range req.Members {
+		userID, err := strconv.ParseInt(member.Value, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalidValue", "member value is not a user id")
+			return models.SCIMGroup{}, false
+		}
+		group.Members = append(group.Members, models.SCIMMember{UserID: userID})
+	}
+	return group, true
+}
+
+func (h *Handler) userResource(user models.SCIMUser) userResource {
+	id := strconv.FormatInt(user.UserID, 10)
+	resource := userResource{
+		Schemas:     []string{userSchema},
+		ID:          id,
+		ExternalID:  user.ExternalID,
+		UserName:    user.Email,
+		DisplayName: user.Profile.Name,
+		Locale:      user.Profile.Locale,
+		Emails:      []multiValue{{Value: user.Email, Primary: true}},
+		Active:      user.Active,
+		Meta:        h.meta("User", usersPath, id, user.CreatedAt, user.UpdatedAt),
+	}
+	if user.Profile.Name != "" || user.Profile.GivenName != "" || user.Profile.FamilyName != "" {
+		resource.Name = &name{
+			Formatted:  user.Profile.Name,
+			GivenName:  user.Profile.GivenName,
+			FamilyName: user.Profile.FamilyName,
+		}
+	}
+	if user.Profile.Picture != "" {
+		resource.Photos = []multiValue{{Value: user.Profile.Picture, Type: "photo", Primary: true}}
+	}
+	for _, group := range user.Groups {
+		groupID := strconv.FormatInt(group.ID, 10)
+		resource.Groups = append(resource.Groups, multiValue{
+			Value:   groupID,
+			Display: group.DisplayName,
+			Ref:     h.issuer + groupsPath + "/" + groupID,
+		})
+	}
+	return resource
+}
+
+func (h *Handler) groupResource(group models.SCIMGroup) groupResource {
+	id := strconv.FormatInt(group.ID, 10)
+	resource := groupResource{
+		Schemas:     []string{groupSchema},
+		ID:          id,
+		ExternalID:  group.ExternalID,
+		DisplayName: group.DisplayName,
+		Members:     make([]multiValue, 0, len(group.Members)),
+		Meta:        h.meta("Group", groupsPath, id, group.CreatedAt, group.UpdatedAt),
+	}
+	for _, member := range group.Members {
+		userID := strconv.FormatInt(member.UserID, 10)
+		resource.Members = append(resource.Members, multiValue{
+			Value:   userID,
+			Display: member.Email,
+			Ref:     h.issuer + usersPath + "/" + userID,
+		})
+	}
+	return resource
+}
+
+func (h *Handler) meta(resourceType string, path string, id string, created time.Time, lastModified time.Time) meta {
+	return meta{
+		ResourceType: resourceType,
+		Created:      created.UTC().Format(time.RFC3339),
+		LastModified: lastModified.UTC().Format(time.RFC3339),
+		Location:     h.issuer + path + "/" + id,
+	}
+}
+
+// resourceID returns ID of the resource at prefix/{id} path of the request
+func resourceID(r *http.Request, prefix string) (int64, bool) {
+	id, ok := strings.CutPrefix(r.URL.Path, prefix+"/")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// pagination returns 1-based start index and count of a list request,
+// writing the error if they are not valid
+func pagination(w http.ResponseWriter, r *http.Request) (startIndex int, count int, ok bool) {
+	query := r.URL.Query()
+	startIndex, count = 1, provisioning.MaxPageSize
+	if value := query.Get("startIndex"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalidValue", "startIndex is not a number")
+			return 0, 0, false
+		}
+		// values below 1 are interpreted as 1
+		startIndex = max(n, 1)
+	}
+	if value := query.Get("count"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalidValue", "count is not a number")
+			return 0, 0, false
+		}
+		// negative values are interpreted as 0
+		count = min(max(n, 0), provisioning.MaxPageSize)
+	}
+	return startIndex, count, true
+}
+
+// readJSON decodes JSON body of the request into v, writing the error
+// if it's not valid
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != contentType && mediaType != "application/json" {
+		writeError(w, http.StatusUnsupportedMediaType, "", "content type must be "+contentType)
+		return false
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalidSyntax", "body is not valid json")
+		return false
+	}
+	return true
+}
+
+// readPatch decodes operations of a patch request, writing the error if it's not valid
+func readPatch(w http.ResponseWriter, r *http.Request) ([]provisioning.PatchOp, bool) {
+	var req patchRequest
+	if !readJSON(w, r, &req) {
+		return nil, false
+	}
+	if len(req.Operations) == 0 {
+		writeError(w, http.StatusBadRequest, "invalidSyntax", "operations are required")
+		return nil, false
+	}
+
+	ops := make([]provisioning.PatchOp, 0, len(req.Operations))
+	for _, op := range req.Operations {
+		ops = append(ops, provisioning.PatchOp{Op: op.Op, Path: op.Path, Value: op.Value})
+	}
+	return ops, true
+}
+
+// writeServiceError writes the error of the provisioning service
+func writeServiceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, provisioning.ErrorUserNotFound):
+		writeError(w, http.StatusNotFound, "", "user not found")
+	case errors.Is(err, provisioning.ErrorGroupNotFound):
+		writeError(w, http.StatusNotFound, "", "group not found")
+	case errors.Is(err, provisioning.ErrorUserExists):
+		writeError(w, http.StatusConflict, "uniqueness", "user already exists")
+	case errors.Is(err, provisioning.ErrorGroupExists):
+		writeError(w, http.StatusConflict, "uniqueness", "group already exists")
+	case errors.Is(err, provisioning.ErrorInvalidFilter):
+		writeError(w, http.StatusBadRequest, "invalidFilter", "filter is not valid or not supported")
+	case errors.Is(err, provisioning.ErrorInvalidPath):
+		writeError(w, http.StatusBadRequest, "invalidPath", "patch path is not valid")
+	case errors.Is(err, provisioning.ErrorImmutable):
+		writeError(w, http.StatusBadRequest, "mutability", "userName of a user not created by the org can't be changed")
+	case errors.Is(err, provisioning.ErrorInvalidUserName):
+		writeError(w, http.StatusBadRequest, "invalidValue", "userName must be an email")
+	case errors.Is(err, provisioning.ErrorInvalidDisplayName):
+		writeError(w, http.StatusBadRequest, "invalidValue", "displayName is required")
+	case errors.Is(err, provisioning.ErrorMemberNotFound):
+		writeError(w, http.StatusBadRequest, "invalidValue", "member is not a user of the org")
+	case errors.Is(err, provisioning.ErrorInvalidValue):
+		writeError(w, http.StatusBadRequest, "invalidValue", "value is not valid")
+	default:
+		writeError(w, http.StatusInternalServerError, "", "internal error")
+	}
+}
+
+func methodNotAllowed(w http.ResponseWriter, allowed string) {
+	w.Header().Set("Allow", allowed)
+	writeError(w, http.StatusMethodNotAllowed, "", "method is not allowed")
+}
+
+func writeList(w http.ResponseWriter, resources []any, total int, startIndex int) {
+	writeJSON(w, http.StatusOK, listResponse{
+		Schemas:      []string{listResponseSchema},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+func writeError(w http.ResponseWriter, status int, scimType string, detail string) {
+	writeJSON(w, status, errorResponse{
+		Schemas:  []string{errorSchema},
+		Status:   strconv.Itoa(status),
+		SCIMType: scimType,
+		Detail:   detail,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,391 @@
+// Package session serves cookie-based sessions of first-party web apps,
+// which don't hand tokens to scripts
+//
+// The session token is kept in an HttpOnly cookie. State changing requests
+// carry a CSRF token in the X-CSRF-Token header: before login it has to
+// match the CSRF cookie issued by GET /session/csrf (double submit), after
+// login the token issued with the session.
+package session
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/lib/cors"
+	"github.com/Len4i/auth-service/internal/lib/secret"
+	"github.com/Len4i/auth-service/internal/services/auth"
+	"github.com/Len4i/auth-service/internal/services/sessions"
+)
+
+const (
+	sessionPath = "/session"
+	csrfPath    = "/session/csrf"
+	loginPath   = "/session/login"
+	mfaPath     = "/session/mfa"
+	logoutPath  = "/session/logout"
+
+	// CSRFHeader carries the CSRF token of state changing requests
+	CSRFHeader = "X-CSRF-Token"
+
+	csrfBytes = 32
+	// csrfTTL bounds the CSRF cookie of a login
+	csrfTTL = time.Hour
+	// maxBodySize bounds JSON bodies of requests
+	maxBodySize = 1 << 16
+)
+
+// Sessions starts, looks up and ends sessions
+type Sessions interface {
+	Login(ctx context.Context, email string, password string, appID int, orgID int64) (models.BrowserSession, string, error)
+	VerifyMFA(ctx context.Context, challenge string, code string) (models.BrowserSession, string, error)
+	Session(ctx context.Context, token string) (models.BrowserSession, error)
+	Logout(ctx context.Context, token string, csrfToken string) error
+}
+
+// Origins checks origins allowed per app
+type Origins interface {
+	OriginAllowed(ctx context.Context, appID int, origin string) (bool, error)
+	KnownOrigin(ctx context.Context, origin string) (bool, error)
+}
+
+// Cookie configures cookies of sessions
+//
+// Cookies are always HttpOnly and Secure. Domain shares them with
+// subdomains, it's the host of the server if empty.
+type Cookie struct {
+	Name     string
+	CSRFName string
+	Domain   string
+	SameSite http.SameSite
+}
+
+type Handler struct {
+	log      *slog.Logger
+	sessions Sessions
+	origins  Origins
+	cookie   Cookie
+}
+
+// Register adds session endpoints to mux
+//
+// Browsers may call them with credentials from origins allowed by apps,
+// a login is rejected if the app doesn't allow the origin it comes from.
+func Register(mux *http.ServeMux, log *slog.Logger, sessions Sessions, origins Origins, cookie Cookie) {
+	h := &Handler{
+		log:      log,
+		sessions: sessions,
+		origins:  origins,
+		cookie:   cookie,
+	}
+	policy := cors.Policy{
+		AllowedMethods:   []string{http.MethodGet, http.MethodPost},
+		AllowedHeaders:   []string{"Content-Type", CSRFHeader},
+		AllowCredentials: true,
+		AllowOriginFunc: func(ctx context.Context, origin string) bool {
+			ok, err := origins.KnownOrigin(ctx, origin)
+			return err == nil && ok
+		},
+	}
+	mux.Handle(sessionPath, policy.Handler(http.HandlerFunc(h.Session)))
+	mux.Handle(csrfPath, policy.Handler(http.HandlerFunc(h.CSRF)))
+	mux.Handle(loginPath, policy.Handler(http.HandlerFunc(h.Login)))
+	mux.Handle(mfaPath, policy.Handler(http.HandlerFunc(h.VerifyMFA)))
+	mux.Handle(logoutPath, policy.Handler(http.HandlerFunc(h.Logout)))
+}
+
+type csrfResponse struct {
+	CSRFToken string `json:"csrf_token"`
+}
+
+// sessionResponse describes the session to the app, CSRFToken has to be
+// sent with its state changing requests
+type sessionResponse struct {
+	UserID    int64    `json:"user_id"`
+	Email     string   `json:"email"`
+	AppID     int      `json:"app_id"`
+	Roles     []string `json:"roles,omitempty"`
+	OrgID     int64    `json:"org_id,omitempty"`
+	OrgRole   string   `json:"org_role,omitempty"`
+	AMR       []string `json:"amr,omitempty"`
+	ACR       string   `json:"acr,omitempty"`
+	AuthTime  int64    `json:"auth_time,omitempty"`
+	ExpiresAt int64    `json:"expires_at"`
+	CSRFToken string   `json:"csrf_token"`
+}
+
+type errorResponse struct {
+	Error            string   `json:"error"`
+	ErrorDescription string   `json:"error_description,omitempty"`
+	MFAChallenge     string   `json:"mfa_challenge,omitempty"`
+	MFAMethods       []string `json:"mfa_methods,omitempty"`
+}
+
+type loginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	AppID    int    `json:"app_id"`
+	OrgID    int64  `json:"org_id"`
+}
+
+type mfaRequest struct {
+	Challenge string `json:"mfa_challenge"`
+	Code      string `json:"code"`
+}
+
+// CSRF issues the CSRF token of a login, both in the body and the CSRF cookie
+func (h *Handler) CSRF(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	token, err := secret.New(csrfBytes)
+	if err != nil {
+		h.log.Error("failed to generate csrf token", "error", err)
+		writeError(w, http.StatusInternalServerError, "server_error", "internal error")
+		return
+	}
+
+	http.SetCookie(w, h.newCookie(h.cookie.CSRFName, token, csrfTTL))
+	writeJSON(w, http.StatusOK, csrfResponse{CSRFToken: token})
+}
+
+// Login logs user in with credentials and starts a session
+//
+// If the user has to complete the second factor, the challenge is returned
+// with the mfa_required error and completed by VerifyMFA.
+func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+	if !h.loginCSRF(w, r) {
+		return
+	}
+
+	var req loginRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if req.Email == "" || req.Password == "" || req.AppID == 0 {
+		writeError(w, http.StatusBadRequest, "invalid_request", "email, password and app_id are required")
+		return
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		ok, err := h.origins.OriginAllowed(r.Context(), req.AppID, origin)
+		if err != nil && !errors.Is(err, auth.ErrorInvalidAppID) {
+			writeError(w, http.StatusInternalServerError, "server_error", "internal error")
+			return
+		}
+		if !ok {
+			writeError(w, http.StatusForbidden, "origin_not_allowed", "origin is not allowed for the app")
+			return
+		}
+	}
+
+	session, token, err := h.sessions.Login(r.Context(), req.Email, req.Password, req.AppID, req.OrgID)
+	h.started(w, session, token, err)
+}
+
+// VerifyMFA completes the second factor of a login and starts a session
+func (h *Handler) VerifyMFA(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+	if !h.loginCSRF(w, r) {
+		return
+	}
+
+	var req mfaRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if req.Challenge == "" || req.Code == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request", "mfa_challenge and code are required")
+		return
+	}
+
+	session, token, err := h.sessions.VerifyMFA(r.Context(), req.Challenge, req.Code)
+	h.started(w, session, token, err)
+}
+
+// Session describes the session of the cookie, or answers 401 if there's none
+func (h *Handler) Session(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	session, err := h.sessions.Session(r.Context(), h.sessionToken(r))
+	switch {
+	case errors.Is(err, sessions.ErrorInvalidSession):
+		writeError(w, http.StatusUnauthorized, "invalid_session", "no valid session")
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "server_error", "internal error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newSessionResponse(session))
+}
+
+// Logout ends the session of the cookie and clears the cookie,
+// the CSRF token of the session is required
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	err := h.sessions.Logout(r.Context(), h.sessionToken(r), r.Header.Get(CSRFHeader))
+	switch {
+	case errors.Is(err, sessions.ErrorInvalidCSRFToken):
+		writeError(w, http.StatusForbidden, "invalid_csrf_token", "csrf token is not valid")
+		return
+	case errors.Is(err, sessions.ErrorInvalidSession):
+		http.SetCookie(w, h.newCookie(h.cookie.Name, "", -1))
+		writeError(w, http.StatusUnauthorized, "invalid_session", "no valid session")
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "server_error", "internal error")
+		return
+	}
+
+	http.SetCookie(w, h.newCookie(h.cookie.Name, "", -1))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// started sets the cookie of the session started by a login,
+// or writes the error of the login
+func (h *Handler) started(w http.ResponseWriter, session models.BrowserSession, token string, err error) {
+	var mfaErr *auth.MFARequiredError
+	switch {
+	case errors.As(err, &mfaErr):
+		writeJSON(w, http.StatusUnauthorized, errorResponse{
+			Error:            "mfa_required",
+			ErrorDescription: "second factor is required",
+			MFAChallenge:     mfaErr.Challenge,
+			MFAMethods:       mfaErr.Methods,
+		})
+		return
+	case errors.Is(err, auth.ErrorInvalidCredentials), errors.Is(err, auth.ErrorInvalidAppID):
+		writeError(w, http.StatusUnauthorized, "invalid_credentials", "invalid email or password")
+		return
+	case errors.Is(err, auth.ErrorInvalidMFACode):
+		writeError(w, http.StatusUnauthorized, "invalid_mfa_code", "mfa code is not valid")
+		return
+	case errors.Is(err, auth.ErrorInvalidChallenge):
+		writeError(w, http.StatusUnauthorized, "invalid_mfa_challenge", "mfa challenge is not valid")
+		return
+	case errors.Is(err, auth.ErrorInvalidOrg), errors.Is(err, auth.ErrorOrgAppNotAllowed),
+		errors.Is(err, auth.ErrorOrgMethodNotAllowed), errors.Is(err, auth.ErrorOrgMFARequired):
+		writeError(w, http.StatusForbidden, "access_denied", err.Error())
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "server_error", "internal error")
+		return
+	}
+
+	http.SetCookie(w, h.newCookie(h.cookie.Name, token, time.Until(session.ExpiresAt)))
+	http.SetCookie(w, h.newCookie(h.cookie.CSRFName, "", -1))
+	writeJSON(w, http.StatusOK, newSessionResponse(session))
+}
+
+// loginCSRF checks the CSRF header of a login against the CSRF cookie,
+// writing the error if it doesn't match
+func (h *Handler) loginCSRF(w http.ResponseWriter, r *http.Request) bool {
+	header := r.Header.Get(CSRFHeader)
+	cookie, err := r.Cookie(h.cookie.CSRFName)
+	if err != nil || header == "" || subtle.ConstantTimeCompare([]byte(header), []byte(cookie.Value)) != 1 {
+		writeError(w, http.StatusForbidden, "invalid_csrf_token", "csrf token is not valid")
+		return false
+	}
+	return true
+}
+
+func (h *Handler) sessionToken(r *http.Request) string {
+	cookie, err := r.Cookie(h.cookie.Name)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// newCookie returns cookie of the value valid for ttl, negative ttl
+// deletes the cookie
+func (h *Handler) newCookie(name string, value string, ttl time.Duration) *http.Cookie {
+	maxAge := int(ttl.Seconds())
+	if ttl < 0 {
+		maxAge = -1
+	}
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Domain:   h.cookie.Domain,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: h.cookie.SameSite,
+	}
+}
+
+func newSessionResponse(session models.BrowserSession) sessionResponse {
+	resp := sessionResponse{
+		UserID:    session.UserID,
+		Email:     session.Email,
+		AppID:     session.AppID,
+		Roles:     session.Roles,
+		OrgID:     session.OrgID,
+		OrgRole:   session.OrgRole,
+		AMR:       session.Methods,
+		ACR:       session.Level,
+		ExpiresAt: session.ExpiresAt.Unix(),
+		CSRFToken: session.CSRFToken,
+	}
+	if !session.AuthTime.IsZero() {
+		resp.AuthTime = session.AuthTime.Unix()
+	}
+	return resp
+}
+
+// readJSON decodes JSON body of the request into v, writing the error
+// if it's not valid
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		writeError(w, http.StatusUnsupportedMediaType, "invalid_request", "content type must be application/json")
+		return false
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "body is not valid json")
+		return false
+	}
+	return true
+}
+
+func methodNotAllowed(w http.ResponseWriter, allowed string) {
+	w.Header().Set("Allow", allowed)
+	writeError(w, http.StatusMethodNotAllowed, "invalid_request", "method is not allowed")
+}
+
+func writeError(w http.ResponseWriter, status int, code string, description string) {
+	writeJSON(w, status, errorResponse{Error: code, ErrorDescription: description})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,89 @@
+// Package cors answers cross-origin requests of browsers, see
+// https://fetch.spec.whatwg.org/#http-cors-protocol
+package cors
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy allows listed origins to call wrapped handlers
+//
+// "*" in AllowedOrigins allows any origin. Requests without credentials are
+// expected then, since the origin is echoed back anyway. Origins not listed
+// are allowed if AllowOriginFunc is set and returns true. AllowedHeaders are
+// request headers besides CORS-safelisted ones, browsers cache preflight
+// responses for MaxAge. AllowCredentials lets allowed origins send cookies,
+// it must not be combined with "*".
+type Policy struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	MaxAge           time.Duration
+	AllowCredentials bool
+	// AllowOriginFunc looks up origins not listed, e.g. in storage
+	AllowOriginFunc func(ctx context.Context, origin string) bool
+}
+
+// Allowed reports whether origin is listed by the policy
+func (p Policy) Allowed(origin string) bool {
+	for _, allowed := range p.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handler wraps next with the policy
+//
+// Preflight requests of allowed origins are answered with no content,
+// of other origins with 403. Actual requests are passed to next either way,
+// browsers hide responses without CORS headers from scripts.
+func (p Policy) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Origin")
+
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if !p.Allowed(origin) && (p.AllowOriginFunc == nil || !p.AllowOriginFunc(r.Context(), origin)) {
+			if preflight {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		if p.AllowCredentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+		if !preflight {
+			if len(p.ExposedHeaders) > 0 {
+				w.Header().Set("Access-Control-Expose-Headers", strings.Join(p.ExposedHeaders, ", "))
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(p.AllowedMethods, ", "))
+		if len(p.AllowedHeaders) > 0 {
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(p.AllowedHeaders, ", "))
+		}
+		if p.MaxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(p.MaxAge.Seconds())))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
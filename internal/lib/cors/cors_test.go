@@ -0,0 +1,158 @@
+package cors
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHandler(t *testing.T) {
+	p := Policy{
+		AllowedOrigins: []string{"https://app.example.com"},
+		AllowedMethods: []string{http.MethodPost},
+		AllowedHeaders: []string{"Authorization", "Content-Type"},
+		ExposedHeaders: []string{"X-Request-Id"},
+		MaxAge:         10 * time.Minute,
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	tests := []struct {
+		name        string
+		method      string
+		origin      string
+		preflight   bool
+		wantStatus  int
+		wantOrigin  string
+		wantMethods string
+		wantMaxAge  string
+		wantExposed string
+	}{
+		{
+			name:       "same origin",
+			method:     http.MethodPost,
+			wantStatus: http.StatusTeapot,
+		},
+		{
+			name:        "allowed",
+			method:      http.MethodPost,
+			origin:      "https://app.example.com",
+			wantStatus:  http.StatusTeapot,
+			wantOrigin:  "https://app.example.com",
+			wantExposed: "X-Request-Id",
+		},
+		{
+			name:       "not allowed",
+			method:     http.MethodPost,
+			origin:     "https://evil.example.com",
+			wantStatus: http.StatusTeapot,
+		},
+		{
+			name:        "preflight",
+			method:      http.MethodOptions,
+			origin:      "https://app.example.com",
+			preflight:   true,
+			wantStatus:  http.StatusNoContent,
+			wantOrigin:  "https://app.example.com",
+			wantMethods: "POST",
+			wantMaxAge:  "600",
+		},
+		{
+			name:       "preflight not allowed",
+			method:     http.MethodOptions,
+			origin:     "https://evil.example.com",
+			preflight:  true,
+			wantStatus: http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			}
+			w := httptest.NewRecorder()
+			p.Handler(next).ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("allow origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Methods"); got != tt.wantMethods {
+				t.Errorf("allow methods = %q, want %q", got, tt.wantMethods)
+			}
+			if got := w.Header().Get("Access-Control-Max-Age"); got != tt.wantMaxAge {
+				t.Errorf("max age = %q, want %q", got, tt.wantMaxAge)
+			}
+			if got := w.Header().Get("Access-Control-Expose-Headers"); got != tt.wantExposed {
+				t.Errorf("expose headers = %q, want %q", got, tt.wantExposed)
+			}
+		})
+	}
+}
+
+func TestHandlerAllowOriginFunc(t *testing.T) {
+	p := Policy{
+		AllowedMethods: []string{http.MethodPost},
+		AllowOriginFunc: func(ctx context.Context, origin string) bool {
+			return origin == "https://app.example.com"
+		},
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	for origin, want := range map[string]int{
+		"https://app.example.com":  http.StatusNoContent,
+		"https://evil.example.com": http.StatusForbidden,
+	} {
+		r := httptest.NewRequest(http.MethodOptions, "/", nil)
+		r.Header.Set("Origin", origin)
+		r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		w := httptest.NewRecorder()
+		p.Handler(next).ServeHTTP(w, r)
+
+		if w.Code != want {
+			t.Errorf("%s: status = %d, want %d", origin, w.Code, want)
+		}
+	}
+}
+
+func TestHandlerAllowCredentials(t *testing.T) {
+	p := Policy{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowedMethods:   []string{http.MethodPost},
+		AllowCredentials: true,
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	for origin, want := range map[string]string{
+		"https://app.example.com":  "true",
+		"https://evil.example.com": "",
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		r.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
+		p.Handler(next).ServeHTTP(w, r)
+
+		if got := w.Header().Get("Access-Control-Allow-Credentials"); got != want {
+			t.Errorf("%s: allow credentials = %q, want %q", origin, got, want)
+		}
+	}
+}
+
+func TestAllowedWildcard(t *testing.T) {
+	p := Policy{AllowedOrigins: []string{"*"}}
+	if !p.Allowed("https://any.example.com") {
+		t.Error("wildcard does not allow origin")
+	}
+	if (Policy{}).Allowed("https://any.example.com") {
+		t.Error("empty policy allows origin")
+	}
+}
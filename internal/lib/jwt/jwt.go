@@ -1,23 +1,298 @@
 package jwt
 
 import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/Len4i/auth-service/internal/domain/models"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func NewToken(user models.User, app models.App, duration time.Duration) (string, error) {
+var ErrInvalidToken = errors.New("invalid token")
+
+// serviceMACHeader is the header holding MAC of the payload of a token with
+// the service key
+//
+// Tokens are signed with secret of the app so the app can verify them, but
+// any holder of the secret could sign one. The MAC proves the service issued
+// the token, only such tokens authenticate callers of the service.
+const serviceMACHeader = "svc"
+
+// Authentication describes how the user authenticated to get a token
+//
+// Methods are put in the amr claim (RFC 8176), Level in acr
+// and Time in auth_time, as in OpenID Connect ID tokens.
+type Authentication struct {
+	Methods []string
+	Level   string
+	Time    time.Time
+}
+
+// Scope is what the token grants within the app
+type Scope struct {
+	// Roles are names of user roles in the app at the time of login
+	Roles []string
+	// OrgID is the org the token is scoped to, 0 if none
+	OrgID int64
+	// OrgRole is the role of the user in the org
+	OrgRole string
+	// Scopes restrict the token to these permissions, nil if it's unrestricted
+	Scopes []string
+}
+
+// Allows reports whether scopes of the token include permission
+func (s Scope) Allows(permission string) bool {
+	return s.Scopes == nil || slices.Contains(s.Scopes, "*") || slices.Contains(s.Scopes, permission)
+}
+
+// Claims are the claims of a token issued by NewToken or NewClientToken
+//
+// Tokens of clients have ClientID set instead of UserID and Email.
+type Claims struct {
+	UserID    int64
+	Email     string
+	ClientID  string
+	AppID     int
+	ExpiresAt time.Time
+	Scope
+	Authentication
+}
+
+func NewToken(
+	user models.User,
+	app models.App,
+	scope Scope,
+	authn Authentication,
+	duration time.Duration,
+	key []byte,
+) (string, error) {
 	token := jwt.New(jwt.SigningMethodHS256)
 	claims := token.Claims.(jwt.MapClaims)
 	claims["user_id"] = user.ID
 	claims["email"] = user.Email
 	claims["app_id"] = app.ID
 	claims["exp"] = time.Now().Add(duration).Unix()
+	if len(scope.Roles) > 0 {
+		claims["roles"] = scope.Roles
+	}
+	if scope.OrgID != 0 {
+		claims["org_id"] = scope.OrgID
+		claims["org_role"] = scope.OrgRole
+	}
+	if scope.Scopes != nil {
+		claims["scope"] = strings.Join(scope.Scopes, " ")
+	}
+	if len(authn.Methods) > 0 {
+		claims["amr"] = authn.Methods
+	}
+	if authn.Level != "" {
+		claims["acr"] = authn.Level
+	}
+	if !authn.Time.IsZero() {
+		claims["auth_time"] = authn.Time.Unix()
+	}
+
+	return sign(token, app.Secret, key)
+}
+
+// NewClientToken returns token of the client of the app, its subject is the client
+//
+// Client tokens are always scoped, empty scopes grant nothing.
+func NewClientToken(clientID string, app models.App, scopes []string, duration time.Duration, key []byte) (string, error) {
+	token := jwt.New(jwt.SigningMethodHS256)
+	claims := token.Claims.(jwt.MapClaims)
+	claims["sub"] = clientID
+	claims["client_id"] = clientID
+	claims["app_id"] = app.ID
+	claims["exp"] = time.Now().Add(duration).Unix()
+	claims["scope"] = strings.Join(scopes, " ")
+
+	return sign(token, app.Secret, key)
+}
 
-	tokenString, err := token.SignedString([]byte(app.Secret))
+// sign signs token with secret of the app, adding MAC of its payload with key
+func sign(token *jwt.Token, appSecret string, key []byte) (string, error) {
+	unsigned, err := token.SigningString()
 	if err != nil {
 		return "", err
 	}
-	return tokenString, nil
+	_, payload, _ := strings.Cut(unsigned, ".")
+	token.Header[serviceMACHeader] = serviceMAC(key, payload)
+
+	return token.SignedString([]byte(appSecret))
+}
+
+func serviceMAC(key []byte, payload string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// ParseToken verifies token issued by NewToken or NewClientToken and returns its claims
+//
+// appSecret looks up the signing secret by the app_id claim, key is the
+// service key the token was issued with. Tokens signed only with secret
+// of the app are rejected.
+func ParseToken(tokenString string, appSecret func(appID int) (string, error), key []byte) (Claims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok {
+			return nil, ErrInvalidToken
+		}
+		appID, ok := claims["app_id"].(float64)
+		if !ok {
+			return nil, ErrInvalidToken
+		}
+		secret, err := appSecret(int(appID))
+		if err != nil {
+			return nil, err
+		}
+		return []byte(secret), nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	mac, _ := token.Header[serviceMACHeader].(string)
+	parts := strings.Split(tokenString, ".")
+	if !hmac.Equal([]byte(mac), []byte(serviceMAC(key, parts[1]))) {
+		return Claims{}, ErrInvalidToken
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+	userID, _ := claims["user_id"].(float64)
+	appID, _ := claims["app_id"].(float64)
+	email, _ := claims["email"].(string)
+	clientID, _ := claims["client_id"].(string)
+	exp, err := claims.GetExpirationTime()
+	if err != nil || (userID == 0) == (clientID == "") {
+		return Claims{}, ErrInvalidToken
+	}
+
+	scope := Scope{Roles: stringsClaim(claims, "roles")}
+	if orgID, ok := claims["org_id"].(float64); ok {
+		scope.OrgID = int64(orgID)
+		scope.OrgRole, _ = claims["org_role"].(string)
+	}
+	if scopes, ok := claims["scope"].(string); ok {
+		scope.Scopes = strings.Fields(scopes)
+	}
+	if clientID != "" && scope.Scopes == nil {
+		scope.Scopes = []string{}
+	}
+
+	authn := Authentication{Methods: stringsClaim(claims, "amr")}
+	authn.Level, _ = claims["acr"].(string)
+	if authTime, ok := claims["auth_time"].(float64); ok {
+		authn.Time = time.Unix(int64(authTime), 0)
+	}
+
+	return Claims{
+		UserID:         int64(userID),
+		Email:          email,
+		ClientID:       clientID,
+		AppID:          int(appID),
+		ExpiresAt:      exp.Time,
+		Scope:          scope,
+		Authentication: authn,
+	}, nil
+}
+
+func stringsClaim(claims jwt.MapClaims, name string) []string {
+	values, ok := claims[name].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	var strs []string
+	for _, value := range values {
+		if str, ok := value.(string); ok {
+			strs = append(strs, str)
+		}
+	}
+	return strs
+}
+
+// Assertion is a signed JWT a client authenticates with (RFC 7523)
+type Assertion struct {
+	ClientID  string
+	ID        string
+	ExpiresAt time.Time
+}
+
+var (
+	ErrInvalidAssertion = errors.New("invalid client assertion")
+	ErrInvalidKey       = errors.New("invalid public key")
+)
+
+// assertionMethods are asymmetric algorithms client assertions may be signed with
+var assertionMethods = []string{
+	jwt.SigningMethodRS256.Alg(), jwt.SigningMethodRS384.Alg(), jwt.SigningMethodRS512.Alg(),
+	jwt.SigningMethodPS256.Alg(), jwt.SigningMethodPS384.Alg(), jwt.SigningMethodPS512.Alg(),
+	jwt.SigningMethodES256.Alg(), jwt.SigningMethodES384.Alg(), jwt.SigningMethodES512.Alg(),
+	jwt.SigningMethodEdDSA.Alg(),
+}
+
+// ParsePublicKey parses PEM encoded RSA, ECDSA or Ed25519 public key
+func ParsePublicKey(pemKey string) (crypto.PublicKey, error) {
+	if key, err := jwt.ParseRSAPublicKeyFromPEM([]byte(pemKey)); err == nil {
+		return key, nil
+	}
+	if key, err := jwt.ParseECPublicKeyFromPEM([]byte(pemKey)); err == nil {
+		return key, nil
+	}
+	if key, err := jwt.ParseEdPublicKeyFromPEM([]byte(pemKey)); err == nil {
+		return key, nil
+	}
+	return nil, ErrInvalidKey
+}
+
+// ParseAssertion verifies client assertion addressed to audience
+//
+// Issuer and subject must both be the client, publicKey looks up its key.
+// The assertion must have expiry and id, so it can't be replayed.
+func ParseAssertion(
+	assertion string,
+	audience string,
+	publicKey func(clientID string) (crypto.PublicKey, error),
+) (Assertion, error) {
+	token, err := jwt.Parse(assertion, func(token *jwt.Token) (interface{}, error) {
+		sub, err := token.Claims.GetSubject()
+		if err != nil || sub == "" {
+			return nil, ErrInvalidAssertion
+		}
+		return publicKey(sub)
+	},
+		jwt.WithValidMethods(assertionMethods),
+		jwt.WithAudience(audience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return Assertion{}, fmt.Errorf("%w: %w", ErrInvalidAssertion, err)
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+	sub, _ := claims.GetSubject()
+	iss, _ := claims.GetIssuer()
+	jti, _ := claims["jti"].(string)
+	exp, _ := claims.GetExpirationTime()
+	if iss != sub || jti == "" {
+		return Assertion{}, ErrInvalidAssertion
+	}
+
+	return Assertion{
+		ClientID:  sub,
+		ID:        jti,
+		ExpiresAt: exp.Time,
+	}, nil
 }
@@ -1,6 +1,13 @@
 package jwt
 
 import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"reflect"
 	"testing"
 	"time"
 
@@ -8,6 +15,8 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
+var testKey = []byte("service-key")
+
 func TestNewToken(t *testing.T) {
 	type args struct {
 		user     models.User
@@ -55,7 +64,7 @@ func TestNewToken(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := NewToken(tt.args.user, tt.args.app, tt.args.duration)
+			got, err := NewToken(tt.args.user, tt.args.app, Scope{}, Authentication{}, tt.args.duration, testKey)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("NewToken() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -76,3 +85,237 @@ func TestNewToken(t *testing.T) {
 		})
 	}
 }
+
+func TestParseToken(t *testing.T) {
+	user := models.User{ID: 1, Email: "mail1@buba.com"}
+	app := models.App{ID: 12, Secret: "secret"}
+	secretFn := func(appID int) (string, error) {
+		if appID != app.ID {
+			t.Fatalf("unexpected app id %d", appID)
+		}
+		return app.Secret, nil
+	}
+
+	authn := Authentication{
+		Methods: []string{"pwd", "otp", "mfa"},
+		Level:   "aal2",
+		Time:    time.Unix(1700000000, 0),
+	}
+
+	scope := Scope{
+		Roles:   []string{"admin", "editor"},
+		OrgID:   7,
+		OrgRole: "member",
+		Scopes:  []string{"docs:read", "docs:write"},
+	}
+
+	token, err := NewToken(user, app, scope, authn, 5*time.Minute, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := ParseToken(token, secretFn, testKey)
+	if err != nil {
+		t.Fatalf("ParseToken() error = %v", err)
+	}
+	if claims.UserID != user.ID || claims.Email != user.Email || claims.AppID != app.ID {
+		t.Errorf("ParseToken() = %+v", claims)
+	}
+	if !reflect.DeepEqual(claims.Scope, scope) {
+		t.Errorf("ParseToken() scope = %+v, want %+v", claims.Scope, scope)
+	}
+	if !reflect.DeepEqual(claims.Authentication, authn) {
+		t.Errorf("ParseToken() authentication = %+v, want %+v", claims.Authentication, authn)
+	}
+
+	expired, err := NewToken(user, app, scope, authn, -time.Minute, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseToken(expired, secretFn, testKey); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ParseToken() expired error = %v, want ErrInvalidToken", err)
+	}
+
+	forged, err := NewToken(user, models.App{ID: app.ID, Secret: "other-secret"}, scope, authn, 5*time.Minute, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseToken(forged, secretFn, testKey); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ParseToken() forged error = %v, want ErrInvalidToken", err)
+	}
+
+	// apps hold their secret, but can't mint tokens the service accepts
+	minted, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": user.ID,
+		"email":   user.Email,
+		"app_id":  app.ID,
+		"exp":     time.Now().Add(5 * time.Minute).Unix(),
+	}).SignedString([]byte(app.Secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseToken(minted, secretFn, testKey); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ParseToken() minted by app error = %v, want ErrInvalidToken", err)
+	}
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": 999,
+		"email":   user.Email,
+		"app_id":  app.ID,
+		"exp":     time.Now().Add(5 * time.Minute).Unix(),
+	})
+	tampered.Header[serviceMACHeader] = parsed.Header[serviceMACHeader]
+	tamperedToken, err := tampered.SignedString([]byte(app.Secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseToken(tamperedToken, secretFn, testKey); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ParseToken() tampered error = %v, want ErrInvalidToken", err)
+	}
+	if _, err := ParseToken(token, secretFn, []byte("other-key")); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ParseToken() other key error = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestParseClientToken(t *testing.T) {
+	app := models.App{ID: 12, Secret: "secret"}
+	secretFn := func(appID int) (string, error) {
+		return app.Secret, nil
+	}
+
+	token, err := NewClientToken("cl_billing", app, []string{"invoices:read"}, 5*time.Minute, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := ParseToken(token, secretFn, testKey)
+	if err != nil {
+		t.Fatalf("ParseToken() error = %v", err)
+	}
+	if claims.ClientID != "cl_billing" || claims.UserID != 0 || claims.AppID != app.ID {
+		t.Errorf("ParseToken() = %+v", claims)
+	}
+	if !reflect.DeepEqual(claims.Scopes, []string{"invoices:read"}) {
+		t.Errorf("ParseToken() scopes = %v", claims.Scopes)
+	}
+
+	unscoped, err := NewClientToken("cl_billing", app, nil, 5*time.Minute, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err = ParseToken(unscoped, secretFn, testKey)
+	if err != nil {
+		t.Fatalf("ParseToken() error = %v", err)
+	}
+	if claims.Scopes == nil || claims.Allows("invoices:read") {
+		t.Errorf("ParseToken() client without scopes is unrestricted: %+v", claims.Scope)
+	}
+}
+
+func TestScopeAllows(t *testing.T) {
+	tests := []struct {
+		name       string
+		scopes     []string
+		permission string
+		want       bool
+	}{
+		{name: "unrestricted", scopes: nil, permission: "docs:read", want: true},
+		{name: "listed", scopes: []string{"docs:read"}, permission: "docs:read", want: true},
+		{name: "not listed", scopes: []string{"docs:read"}, permission: "docs:write", want: false},
+		{name: "wildcard", scopes: []string{"*"}, permission: "docs:write", want: true},
+		{name: "empty", scopes: []string{}, permission: "docs:read", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (Scope{Scopes: tt.scopes}).Allows(tt.permission); got != tt.want {
+				t.Errorf("Allows() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAssertion(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pemKey := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+
+	key, err := ParsePublicKey(pemKey)
+	if err != nil {
+		t.Fatalf("ParsePublicKey() error = %v", err)
+	}
+	if _, err := ParsePublicKey("not a key"); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("ParsePublicKey() error = %v, want ErrInvalidKey", err)
+	}
+
+	publicKey := func(clientID string) (crypto.PublicKey, error) {
+		if clientID != "cl_billing" {
+			return nil, errors.New("unknown client")
+		}
+		return key, nil
+	}
+	sign := func(method jwt.SigningMethod, signKey any, claims jwt.MapClaims) string {
+		signed, err := jwt.NewWithClaims(method, claims).SignedString(signKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	claims := func(changes jwt.MapClaims) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"iss": "cl_billing",
+			"sub": "cl_billing",
+			"aud": "auth-service",
+			"jti": "1",
+			"exp": time.Now().Add(time.Minute).Unix(),
+		}
+		for k, v := range changes {
+			if v == nil {
+				delete(c, k)
+				continue
+			}
+			c[k] = v
+		}
+		return c
+	}
+
+	tests := []struct {
+		name      string
+		assertion string
+		wantErr   bool
+	}{
+		{name: "valid", assertion: sign(jwt.SigningMethodEdDSA, priv, claims(nil))},
+		{name: "other audience", assertion: sign(jwt.SigningMethodEdDSA, priv, claims(jwt.MapClaims{"aud": "other"})), wantErr: true},
+		{name: "issuer is not subject", assertion: sign(jwt.SigningMethodEdDSA, priv, claims(jwt.MapClaims{"iss": "x"})), wantErr: true},
+		{name: "no id", assertion: sign(jwt.SigningMethodEdDSA, priv, claims(jwt.MapClaims{"jti": nil})), wantErr: true},
+		{name: "no expiry", assertion: sign(jwt.SigningMethodEdDSA, priv, claims(jwt.MapClaims{"exp": nil})), wantErr: true},
+		{name: "expired", assertion: sign(jwt.SigningMethodEdDSA, priv, claims(jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()})), wantErr: true},
+		{name: "unknown client", assertion: sign(jwt.SigningMethodEdDSA, priv, claims(jwt.MapClaims{"iss": "cl_x", "sub": "cl_x"})), wantErr: true},
+		{name: "symmetric", assertion: sign(jwt.SigningMethodHS256, []byte("secret"), claims(nil)), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAssertion(tt.assertion, "auth-service", publicKey)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidAssertion) {
+					t.Errorf("ParseAssertion() error = %v, want ErrInvalidAssertion", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseAssertion() error = %v", err)
+			}
+			if got.ClientID != "cl_billing" || got.ID != "1" {
+				t.Errorf("ParseAssertion() = %+v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package jwt
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SigningKey is the RSA key ID tokens are signed with, ID is its key id
+type SigningKey struct {
+	ID  string
+	Key *rsa.PrivateKey
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517)
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	N         string `json:"n"`
+	E         string `json:"e"`
+}
+
+// ParseSigningKey parses PEM encoded RSA private key
+//
+// The key id is the JWK thumbprint of the public key (RFC 7638),
+// so it changes only with the key.
+func ParseSigningKey(pemKey []byte) (*SigningKey, error) {
+	key, err := jwt.ParseRSAPrivateKeyFromPEM(pemKey)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+
+	// members of the thumbprint input are in lexicographic order
+	thumbprint, err := json.Marshal(struct {
+		E   string `json:"e"`
+		Kty string `json:"kty"`
+		N   string `json:"n"`
+	}{
+		E:   encodeInt(big.NewInt(int64(key.E))),
+		Kty: "RSA",
+		N:   encodeInt(key.N),
+	})
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(thumbprint)
+
+	return &SigningKey{
+		ID:  base64.RawURLEncoding.EncodeToString(digest[:]),
+		Key: key,
+	}, nil
+}
+
+// JWK returns the public key
+func (k *SigningKey) JWK() JWK {
+	return JWK{
+		KeyType:   "RSA",
+		Use:       "sig",
+		Algorithm: jwt.SigningMethodRS256.Alg(),
+		KeyID:     k.ID,
+		N:         encodeInt(k.Key.N),
+		E:         encodeInt(big.NewInt(int64(k.Key.E))),
+	}
+}
+
+func encodeInt(n *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(n.Bytes())
+}
+
+// IDToken is an OpenID Connect ID token of the user
+//
+// Audience is the client the token is issued to, Nonce is the value
+// the client sent with its authorization request. Claims are added
+// to the token as is, e.g. profile and email claims.
+type IDToken struct {
+	Issuer   string
+	Subject  string
+	Audience string
+	Nonce    string
+	Authentication
+	Claims map[string]any
+}
+
+// NewIDToken returns ID token signed with RS256
+func NewIDToken(key *SigningKey, idToken IDToken, duration time.Duration) (string, error) {
+	claims := jwt.MapClaims{}
+	for name, value := range idToken.Claims {
+		claims[name] = value
+	}
+
+	now := time.Now()
+	claims["iss"] = idToken.Issuer
+	claims["sub"] = idToken.Subject
+	claims["aud"] = idToken.Audience
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(duration).Unix()
+	if idToken.Nonce != "" {
+		claims["nonce"] = idToken.Nonce
+	}
+	if len(idToken.Methods) > 0 {
+		claims["amr"] = idToken.Methods
+	}
+	if idToken.Level != "" {
+		claims["acr"] = idToken.Level
+	}
+	if !idToken.Time.IsZero() {
+		claims["auth_time"] = idToken.Time.Unix()
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = key.ID
+
+	return token.SignedString(key.Key)
+}
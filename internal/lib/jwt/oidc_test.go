@@ -0,0 +1,87 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestNewIDToken(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})
+
+	key, err := ParseSigningKey(pemKey)
+	if err != nil {
+		t.Fatalf("ParseSigningKey() error = %v", err)
+	}
+	again, err := ParseSigningKey(pemKey)
+	if err != nil {
+		t.Fatalf("ParseSigningKey() error = %v", err)
+	}
+	if key.ID == "" || key.ID != again.ID {
+		t.Errorf("ParseSigningKey() key id = %q, then %q, want the same thumbprint", key.ID, again.ID)
+	}
+	if _, err := ParseSigningKey([]byte("not a key")); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("ParseSigningKey() error = %v, want ErrInvalidKey", err)
+	}
+
+	authTime := time.Now().Add(-time.Minute).Truncate(time.Second)
+	signed, err := NewIDToken(key, IDToken{
+		Issuer:   "https://auth.example.com",
+		Subject:  "42",
+		Audience: "999",
+		Nonce:    "n-0S6_WzA2Mj",
+		Authentication: Authentication{
+			Methods: []string{"pwd"},
+			Level:   "aal1",
+			Time:    authTime,
+		},
+		Claims: map[string]any{"email": "user@example.com"},
+	}, time.Hour)
+	if err != nil {
+		t.Fatalf("NewIDToken() error = %v", err)
+	}
+
+	// verify with the published key only
+	jwk := key.JWK()
+	n, _ := base64.RawURLEncoding.DecodeString(jwk.N)
+	e, _ := base64.RawURLEncoding.DecodeString(jwk.E)
+	publicKey := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+
+	token, err := jwt.Parse(signed, func(token *jwt.Token) (interface{}, error) {
+		if token.Header["kid"] != jwk.KeyID {
+			t.Errorf("kid = %v, want %v", token.Header["kid"], jwk.KeyID)
+		}
+		return publicKey, nil
+	}, jwt.WithValidMethods([]string{jwk.Algorithm}), jwt.WithAudience("999"), jwt.WithIssuer("https://auth.example.com"))
+	if err != nil {
+		t.Fatalf("failed to verify ID token: %v", err)
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+	want := map[string]any{
+		"sub":       "42",
+		"nonce":     "n-0S6_WzA2Mj",
+		"amr":       []any{"pwd"},
+		"acr":       "aal1",
+		"auth_time": float64(authTime.Unix()),
+		"email":     "user@example.com",
+	}
+	for name, value := range want {
+		if !reflect.DeepEqual(claims[name], value) {
+			t.Errorf("claim %s = %v, want %v", name, claims[name], value)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package linktoken
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSigner(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	s := NewSigner([]byte("key"))
+	token := s.Sign(42, now.Add(time.Minute))
+
+	tests := []struct {
+		name    string
+		signer  *Signer
+		token   string
+		now     time.Time
+		wantID  int64
+		wantErr error
+	}{
+		{
+			name:   "valid",
+			signer: s,
+			token:  token,
+			now:    now,
+			wantID: 42,
+		},
+		{
+			name:    "expired",
+			signer:  s,
+			token:   token,
+			now:     now.Add(2 * time.Minute),
+			wantErr: ErrExpiredToken,
+		},
+		{
+			name:    "other key",
+			signer:  NewSigner([]byte("other")),
+			token:   token,
+			now:     now,
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "tampered",
+			signer:  s,
+			token:   tamper(token, 10),
+			now:     now,
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "truncated",
+			signer:  s,
+			token:   token[:len(token)-4],
+			now:     now,
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "not base64",
+			signer:  s,
+			token:   "not a token",
+			now:     now,
+			wantErr: ErrInvalidToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := tt.signer.Verify(tt.token, tt.now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+			if id != tt.wantID {
+				t.Errorf("Verify() = %d, want %d", id, tt.wantID)
+			}
+		})
+	}
+}
+
+// tamper replaces character at i with another base64url character
+func tamper(token string, i int) string {
+	c := byte('A')
+	if token[i] == c {
+		c = 'B'
+	}
+	return token[:i] + string(c) + token[i+1:]
+}
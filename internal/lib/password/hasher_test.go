@@ -0,0 +1,76 @@
+package password
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestHasher_Rotation(t *testing.T) {
+	keys := map[string]string{"k1": "pepper-one", "k2": "pepper-two"}
+
+	noPepper, err := NewHasher("", keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h1, err := NewHasher("k1", keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h2, err := NewHasher("k2", keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plainHash, plainKeyID, err := noPepper.Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plainKeyID != "" {
+		t.Errorf("Hash() keyID = %q, want empty", plainKeyID)
+	}
+
+	hash, keyID, err := h1.Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyID != "k1" {
+		t.Errorf("Hash() keyID = %q, want k1", keyID)
+	}
+
+	// after rotation to k2 old hashes are still verified and need rehash
+	if err := h2.Compare(hash, keyID, false, "secret"); err != nil {
+		t.Errorf("Compare() error = %v", err)
+	}
+	if err := h2.Compare(plainHash, plainKeyID, false, "secret"); err != nil {
+		t.Errorf("Compare() unpeppered error = %v", err)
+	}
+	if err := h2.Compare(hash, keyID, false, "not-secret"); !errors.Is(err, ErrMismatch) {
+		t.Errorf("Compare() error = %v, want ErrMismatch", err)
+	}
+	if !h2.NeedsRehash(keyID, false) || !h2.NeedsRehash(plainKeyID, false) {
+		t.Error("NeedsRehash() = false for hash with old key")
+	}
+	if h1.NeedsRehash(keyID, false) {
+		t.Error("NeedsRehash() = true for hash with current key")
+	}
+
+	// peppered hash is useless without the key
+	if err := noPepper.Compare(hash, "", false, "secret"); !errors.Is(err, ErrMismatch) {
+		t.Errorf("Compare() without pepper error = %v, want ErrMismatch", err)
+	}
+
+	removed, err := NewHasher("", map[string]string{"k2": "pepper-two"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := removed.Compare(hash, keyID, false, "secret"); !errors.Is(err, ErrUnknownPepperKey) {
+		t.Errorf("Compare() error = %v, want ErrUnknownPepperKey", err)
+	}
+}
+
+func TestNewHasher_UnknownCurrentKey(t *testing.T) {
+	_, err := NewHasher("k3", map[string]string{"k1": "pepper-one"})
+	if !errors.Is(err, ErrUnknownPepperKey) {
+		t.Errorf("NewHasher() error = %v, want ErrUnknownPepperKey", err)
+	}
+}
@@ -0,0 +1,100 @@
+// Package password verifies password hashes imported from foreign systems
+// (Django PBKDF2-SHA256, SHA-crypt and MD5-crypt).
+//
+// Such hashes are treated as legacy: they are only verified, never generated.
+// Once a user logs in successfully the password is rehashed with bcrypt.
+package password
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/GehirnInc/crypt"
+	_ "github.com/GehirnInc/crypt/md5_crypt"
+	_ "github.com/GehirnInc/crypt/sha256_crypt"
+	_ "github.com/GehirnInc/crypt/sha512_crypt"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+var (
+	ErrMismatch        = errors.New("password does not match hash")
+	ErrUnsupportedHash = errors.New("unsupported password hash")
+)
+
+const (
+	djangoPBKDF2Prefix = "pbkdf2_sha256$"
+	md5CryptPrefix     = "$1$"
+	sha256CryptPrefix  = "$5$"
+	sha512CryptPrefix  = "$6$"
+)
+
+// IsLegacy reports whether hash is in one of the supported foreign formats
+func IsLegacy(hash []byte) bool {
+	for _, prefix := range []string{djangoPBKDF2Prefix, md5CryptPrefix, sha256CryptPrefix, sha512CryptPrefix} {
+		if bytes.HasPrefix(hash, []byte(prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// CompareLegacy compares a legacy hash with a plaintext password
+//
+// Returns ErrMismatch if password is incorrect and ErrUnsupportedHash
+// if hash is not in one of the supported formats.
+func CompareLegacy(hash []byte, password string) error {
+	switch {
+	case bytes.HasPrefix(hash, []byte(djangoPBKDF2Prefix)):
+		return compareDjangoPBKDF2(string(hash), password)
+	case bytes.HasPrefix(hash, []byte(md5CryptPrefix)),
+		bytes.HasPrefix(hash, []byte(sha256CryptPrefix)),
+		bytes.HasPrefix(hash, []byte(sha512CryptPrefix)):
+		return compareCrypt(string(hash), password)
+	default:
+		return ErrUnsupportedHash
+	}
+}
+
+// compareDjangoPBKDF2 verifies hash in Django format: pbkdf2_sha256$<iterations>$<salt>$<base64 hash>
+func compareDjangoPBKDF2(hash string, password string) error {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 {
+		return ErrUnsupportedHash
+	}
+
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return ErrUnsupportedHash
+	}
+
+	want, err := base64.StdEncoding.DecodeString(parts[3])
+	if err != nil || len(want) == 0 {
+		return ErrUnsupportedHash
+	}
+
+	got := pbkdf2.Key([]byte(password), []byte(parts[2]), iterations, len(want), sha256.New)
+	if subtle.ConstantTimeCompare(got, want) != 1 {
+		return ErrMismatch
+	}
+	return nil
+}
+
+// compareCrypt verifies SHA-crypt ($5$, $6$) and MD5-crypt ($1$) hashes
+func compareCrypt(hash string, password string) error {
+	if !crypt.IsHashSupported(hash) {
+		return ErrUnsupportedHash
+	}
+
+	if err := crypt.NewFromHash(hash).Verify(hash, []byte(password)); err != nil {
+		if errors.Is(err, crypt.ErrKeyMismatch) {
+			return ErrMismatch
+		}
+		return ErrUnsupportedHash
+	}
+	return nil
+}
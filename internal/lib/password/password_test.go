@@ -0,0 +1,87 @@
+package password
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCompareLegacy(t *testing.T) {
+	tests := []struct {
+		name     string
+		hash     string
+		password string
+		wantErr  error
+	}{
+		{
+			name:     "django pbkdf2",
+			hash:     "pbkdf2_sha256$260000$c2FsdHk$ZqcDj1r3rRm8O6QfH1Nc+A6bajyqohF04qSM2CjLea4=",
+			password: "secret",
+		},
+		{
+			name:     "django pbkdf2, wrong password",
+			hash:     "pbkdf2_sha256$260000$c2FsdHk$ZqcDj1r3rRm8O6QfH1Nc+A6bajyqohF04qSM2CjLea4=",
+			password: "not-secret",
+			wantErr:  ErrMismatch,
+		},
+		{
+			name:     "django pbkdf2, malformed",
+			hash:     "pbkdf2_sha256$abc$c2FsdHk",
+			password: "secret",
+			wantErr:  ErrUnsupportedHash,
+		},
+		{
+			name:     "md5-crypt",
+			hash:     "$1$saltsalt$9xy1btjgzLYfb7hivXtC//",
+			password: "secret",
+		},
+		{
+			name:     "sha256-crypt",
+			hash:     "$5$saltsalt$0IyaXrmV7.sGNS6tirgqHLqX/G.FBvgkYA.lpPdS5sA",
+			password: "secret",
+		},
+		{
+			name:     "sha512-crypt",
+			hash:     "$6$saltsalt$TVLlQcbpFVof5W3Yz4DTP6gRstiNuHwwTt6GLc1E5n0U0aDehy0S5knV8wiOQSpT0Y77vwPZN.Pq.H91p5hVO1",
+			password: "secret",
+		},
+		{
+			name:     "sha512-crypt, wrong password",
+			hash:     "$6$saltsalt$TVLlQcbpFVof5W3Yz4DTP6gRstiNuHwwTt6GLc1E5n0U0aDehy0S5knV8wiOQSpT0Y77vwPZN.Pq.H91p5hVO1",
+			password: "not-secret",
+			wantErr:  ErrMismatch,
+		},
+		{
+			name:     "bcrypt is not legacy",
+			hash:     "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy",
+			password: "secret",
+			wantErr:  ErrUnsupportedHash,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CompareLegacy([]byte(tt.hash), tt.password)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("CompareLegacy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIsLegacy(t *testing.T) {
+	tests := []struct {
+		hash string
+		want bool
+	}{
+		{hash: "pbkdf2_sha256$260000$salt$hash", want: true},
+		{hash: "$1$salt$hash", want: true},
+		{hash: "$5$salt$hash", want: true},
+		{hash: "$6$rounds=5000$salt$hash", want: true},
+		{hash: "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy", want: false},
+		{hash: "", want: false},
+	}
+	for _, tt := range tests {
+		if got := IsLegacy([]byte(tt.hash)); got != tt.want {
+			t.Errorf("IsLegacy(%q) = %v, want %v", tt.hash, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Package policy compiles and evaluates attribute-based access policies
+// written as CEL expressions
+//
+// Expressions see the variables subject, resource and context as maps,
+// action as a string and now as a timestamp, and must return a bool.
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/cel-go/cel"
+)
+
+// costLimit bounds the work a single evaluation may do
+const costLimit = 100000
+
+var (
+	ErrInvalidExpression = errors.New("invalid policy expression")
+	ErrEvaluation        = errors.New("policy evaluation failed")
+)
+
+// CompileError describes why expression doesn't compile, it matches ErrInvalidExpression
+type CompileError struct {
+	Issues string
+}
+
+func (e *CompileError) Error() string {
+	return ErrInvalidExpression.Error() + ": " + e.Issues
+}
+
+func (e *CompileError) Is(target error) bool {
+	return target == ErrInvalidExpression
+}
+
+// Input is what the expression is evaluated against
+type Input struct {
+	Subject  map[string]any
+	Resource map[string]any
+	Action   string
+	Context  map[string]any
+	Now      time.Time
+}
+
+var env = mustEnv()
+
+func mustEnv() *cel.Env {
+	env, err := cel.NewEnv(
+		cel.Variable("subject", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("resource", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("action", cel.StringType),
+		cel.Variable("context", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("now", cel.TimestampType),
+	)
+	if err != nil {
+		panic(err)
+	}
+	return env
+}
+
+// Program is a compiled policy expression, safe for concurrent use
+type Program struct {
+	prg cel.Program
+}
+
+// Compile parses and type-checks expression
+func Compile(expression string) (*Program, error) {
+	ast, iss := env.Compile(expression)
+	if iss.Err() != nil {
+		return nil, &CompileError{Issues: iss.Err().Error()}
+	}
+	if ast.OutputType() != cel.BoolType {
+		return nil, &CompileError{Issues: "expression must return bool, got " + ast.OutputType().String()}
+	}
+
+	prg, err := env.Program(ast, cel.CostLimit(costLimit))
+	if err != nil {
+		return nil, &CompileError{Issues: err.Error()}
+	}
+
+	return &Program{prg: prg}, nil
+}
+
+// Eval reports whether the expression holds for input
+//
+// Missing map keys and exceeded cost limit fail with ErrEvaluation.
+func (p *Program) Eval(in Input) (bool, error) {
+	out, _, err := p.prg.Eval(map[string]any{
+		"subject":  nonNil(in.Subject),
+		"resource": nonNil(in.Resource),
+		"action":   in.Action,
+		"context":  nonNil(in.Context),
+		"now":      in.Now,
+	})
+	if err != nil {
+		return false, fmt.Errorf("%w: %s", ErrEvaluation, err)
+	}
+
+	result, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("%w: result is %T", ErrEvaluation, out.Value())
+	}
+	return result, nil
+}
+
+func nonNil(m map[string]any) map[string]any {
+	if m == nil {
+		return map[string]any{}
+	}
+	return m
+}
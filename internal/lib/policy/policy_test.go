@@ -0,0 +1,124 @@
+package policy
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		wantErr    error
+	}{
+		{
+			name:       "valid",
+			expression: `subject.id == resource.owner_id && action == "edit"`,
+		},
+		{
+			name:       "syntax error",
+			expression: `subject.id ==`,
+			wantErr:    ErrInvalidExpression,
+		},
+		{
+			name:       "unknown variable",
+			expression: `user.id == 1`,
+			wantErr:    ErrInvalidExpression,
+		},
+		{
+			name:       "not bool",
+			expression: `action + "x"`,
+			wantErr:    ErrInvalidExpression,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(tt.expression)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Compile() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProgram_Eval(t *testing.T) {
+	// Monday 10:00 UTC
+	workday := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	in := Input{
+		Subject:  map[string]any{"id": int64(42), "roles": []string{"editor"}, "org_role": "member"},
+		Resource: map[string]any{"owner_id": int64(42), "tags": []any{"draft"}},
+		Action:   "edit",
+		Now:      workday,
+	}
+	businessHours := `now.getHours("UTC") >= 9 && now.getHours("UTC") < 17`
+
+	tests := []struct {
+		name       string
+		expression string
+		in         Input
+		want       bool
+		wantErr    error
+	}{
+		{
+			name:       "owner",
+			expression: `subject.id == resource.owner_id`,
+			in:         in,
+			want:       true,
+		},
+		{
+			name:       "role",
+			expression: `"editor" in subject.roles && "draft" in resource.tags`,
+			in:         in,
+			want:       true,
+		},
+		{
+			name:       "owner or org admin within business hours",
+			expression: `(subject.id == resource.owner_id || subject.org_role == "admin") && ` + businessHours,
+			in:         in,
+			want:       true,
+		},
+		{
+			name:       "outside business hours",
+			expression: businessHours,
+			in:         Input{Now: workday.Add(10 * time.Hour)},
+			want:       false,
+		},
+		{
+			name:       "context",
+			expression: `has(context.ip) && context.ip.startsWith("10.")`,
+			in:         Input{Context: map[string]any{"ip": "10.0.0.1"}},
+			want:       true,
+		},
+		{
+			name:       "missing key",
+			expression: `resource.owner_id == 1`,
+			in:         Input{},
+			wantErr:    ErrEvaluation,
+		},
+		{
+			name:       "cost limit",
+			expression: `[1,2,3,4,5,6,7,8,9,10].all(a, [1,2,3,4,5,6,7,8,9,10].all(b, [1,2,3,4,5,6,7,8,9,10].all(c, [1,2,3,4,5,6,7,8,9,10].all(d, [1,2,3,4,5,6,7,8,9,10].all(e, a+b+c+d+e > 0)))))`,
+			in:         Input{},
+			wantErr:    ErrEvaluation,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prg, err := Compile(tt.expression)
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+
+			got, err := prg.Eval(tt.in)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Eval() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Eval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,302 @@
+// Package scim parses the filters and attribute paths of SCIM 2.0 requests (RFC 7644)
+//
+// Supported filters are comparisons of attributes with values joined by
+// "and" and "or", "and" binds tighter. Grouping with parentheses, "not" and
+// complex attribute filters are not supported.
+package scim
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrInvalidFilter = errors.New("invalid scim filter")
+	ErrInvalidPath   = errors.New("invalid scim path")
+)
+
+// Comparison operators of filters
+const (
+	OpEqual          = "eq"
+	OpNotEqual       = "ne"
+	OpContains       = "co"
+	OpStartsWith     = "sw"
+	OpEndsWith       = "ew"
+	OpPresent        = "pr"
+	OpGreater        = "gt"
+	OpGreaterOrEqual = "ge"
+	OpLess           = "lt"
+	OpLessOrEqual    = "le"
+)
+
+// Condition compares attribute with value, Value is empty for OpPresent
+//
+// Attr is lower-cased, sub-attributes are joined with dots, e.g. "name.givenname".
+type Condition struct {
+	Attr  string
+	Op    string
+	Value string
+}
+
+// Filter is a disjunction of conjunctions of conditions, the empty filter matches anything
+type Filter [][]Condition
+
+// Values returns values of the lower-cased attribute of a resource, booleans are
+// "true" or "false"
+type Values func(attr string) []string
+
+// ParseFilter parses filter expression
+func ParseFilter(expr string) (Filter, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	var filter Filter
+	var and []Condition
+	for len(tokens) > 0 {
+		if len(tokens) < 2 {
+			return nil, fmt.Errorf("%w: incomplete expression", ErrInvalidFilter)
+		}
+
+		cond := Condition{Attr: strings.ToLower(tokens[0].text), Op: strings.ToLower(tokens[1].text)}
+		if tokens[0].quoted || tokens[1].quoted || !validAttr(cond.Attr) {
+			return nil, fmt.Errorf("%w: invalid attribute %q", ErrInvalidFilter, tokens[0].text)
+		}
+		switch cond.Op {
+		case OpPresent:
+			tokens = tokens[2:]
+		case OpEqual, OpNotEqual, OpContains, OpStartsWith, OpEndsWith,
+			OpGreater, OpGreaterOrEqual, OpLess, OpLessOrEqual:
+			if len(tokens) < 3 {
+				return nil, fmt.Errorf("%w: missing value of %q", ErrInvalidFilter, cond.Attr)
+			}
+			value := tokens[2]
+			if !value.quoted && !validLiteral(value.text) {
+				return nil, fmt.Errorf("%w: invalid value %q", ErrInvalidFilter, value.text)
+			}
+			cond.Value = value.text
+			if !value.quoted {
+				cond.Value = strings.ToLower(value.text)
+			}
+			tokens = tokens[3:]
+		default:
+			return nil, fmt.Errorf("%w: unsupported operator %q", ErrInvalidFilter, tokens[1].text)
+		}
+		and = append(and, cond)
+
+		if len(tokens) == 0 {
+			break
+		}
+		if tokens[0].quoted || len(tokens) == 1 {
+			return nil, fmt.Errorf("%w: expected \"and\" or \"or\"", ErrInvalidFilter)
+		}
+		switch strings.ToLower(tokens[0].text) {
+		case "and":
+		case "or":
+			filter = append(filter, and)
+			and = nil
+		default:
+			return nil, fmt.Errorf("%w: unexpected %q", ErrInvalidFilter, tokens[0].text)
+		}
+		tokens = tokens[1:]
+	}
+	if len(and) > 0 {
+		filter = append(filter, and)
+	}
+
+	return filter, nil
+}
+
+// Match reports whether resource with the values matches the filter
+//
+// Values are compared case-insensitively, a multi-valued attribute matches
+// if any of its values does.
+func (f Filter) Match(values Values) bool {
+	if len(f) == 0 {
+		return true
+	}
+
+	for _, and := range f {
+		matched := true
+		for _, cond := range and {
+			if !cond.match(values(cond.Attr)) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c Condition) match(values []string) bool {
+	if c.Op == OpNotEqual {
+		return !Condition{Attr: c.Attr, Op: OpEqual, Value: c.Value}.match(values)
+	}
+
+	want := strings.ToLower(c.Value)
+	for _, value := range values {
+		value = strings.ToLower(value)
+		var ok bool
+		switch c.Op {
+		case OpPresent:
+			ok = value != ""
+		case OpEqual:
+			ok = value == want
+		case OpContains:
+			ok = strings.Contains(value, want)
+		case OpStartsWith:
+			ok = strings.HasPrefix(value, want)
+		case OpEndsWith:
+			ok = strings.HasSuffix(value, want)
+		case OpGreater:
+			ok = value > want
+		case OpGreaterOrEqual:
+			ok = value >= want
+		case OpLess:
+			ok = value < want
+		case OpLessOrEqual:
+			ok = value <= want
+		}
+		if ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Path is an attribute path of a patch operation, e.g. `members[value eq "2"].display`
+//
+// Attr and Sub are lower-cased, Filter selects values of a multi-valued attribute.
+type Path struct {
+	Attr   string
+	Filter Filter
+	Sub    string
+}
+
+// ParsePath parses attribute path of a patch operation
+func ParsePath(path string) (Path, error) {
+	attr, rest, hasFilter := strings.Cut(path, "[")
+	if !hasFilter {
+		attr = strings.ToLower(attr)
+		if !validAttr(attr) {
+			return Path{}, fmt.Errorf("%w: %q", ErrInvalidPath, path)
+		}
+		return Path{Attr: attr}, nil
+	}
+
+	expr, sub, ok := strings.Cut(rest, "]")
+	if !ok {
+		return Path{}, fmt.Errorf("%w: unterminated filter in %q", ErrInvalidPath, path)
+	}
+	p := Path{Attr: strings.ToLower(attr)}
+	if !validAttr(p.Attr) || strings.Contains(p.Attr, ".") {
+		return Path{}, fmt.Errorf("%w: %q", ErrInvalidPath, path)
+	}
+	if sub != "" {
+		p.Sub = strings.ToLower(strings.TrimPrefix(sub, "."))
+		if !strings.HasPrefix(sub, ".") || !validAttr(p.Sub) || strings.Contains(p.Sub, ".") {
+			return Path{}, fmt.Errorf("%w: %q", ErrInvalidPath, path)
+		}
+	}
+
+	filter, err := ParseFilter(expr)
+	if err != nil {
+		return Path{}, fmt.Errorf("%w: %w", ErrInvalidPath, err)
+	}
+	if len(filter) == 0 {
+		return Path{}, fmt.Errorf("%w: empty filter in %q", ErrInvalidPath, path)
+	}
+	p.Filter = filter
+
+	return p, nil
+}
+
+type token struct {
+	text   string
+	quoted bool
+}
+
+// tokenize splits expression by spaces, quoted strings are JSON-like with
+// backslash escapes
+func tokenize(expr string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(expr); {
+		switch c := expr[i]; {
+		case c == ' ':
+			i++
+		case c == '"':
+			var b strings.Builder
+			i++
+			for {
+				if i >= len(expr) {
+					return nil, fmt.Errorf("%w: unterminated string", ErrInvalidFilter)
+				}
+				if expr[i] == '"' {
+					i++
+					break
+				}
+				if expr[i] == '\\' {
+					i++
+					if i >= len(expr) {
+						return nil, fmt.Errorf("%w: unterminated string", ErrInvalidFilter)
+					}
+				}
+				b.WriteByte(expr[i])
+				i++
+			}
+			tokens = append(tokens, token{text: b.String(), quoted: true})
+		case c == '(' || c == ')' || c == '[' || c == ']':
+			return nil, fmt.Errorf("%w: grouping is not supported", ErrInvalidFilter)
+		default:
+			start := i
+			for i < len(expr) && expr[i] != ' ' && expr[i] != '"' {
+				i++
+			}
+			tokens = append(tokens, token{text: expr[start:i]})
+		}
+	}
+	return tokens, nil
+}
+
+// validAttr reports whether attr is an attribute name with optional
+// sub-attributes, schema URN prefixes are not supported
+func validAttr(attr string) bool {
+	if attr == "" {
+		return false
+	}
+	for _, part := range strings.Split(attr, ".") {
+		if part == "" {
+			return false
+		}
+		for i, c := range part {
+			switch {
+			case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+			case i > 0 && (c >= '0' && c <= '9' || c == '_' || c == '-' || c == '$'):
+			default:
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// validLiteral reports whether unquoted value is a boolean, null or a number
+func validLiteral(value string) bool {
+	switch strings.ToLower(value) {
+	case "true", "false", "null":
+		return true
+	}
+	for i, c := range value {
+		if !(c >= '0' && c <= '9' || c == '.' || c == '-' && i == 0) {
+			return false
+		}
+	}
+	return value != "" && value != "-"
+}
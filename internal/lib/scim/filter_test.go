@@ -0,0 +1,213 @@
+package scim
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		want    Filter
+		wantErr error
+	}{
+		{
+			name: "empty",
+			expr: "",
+		},
+		{
+			name: "eq",
+			expr: `userName eq "Bjensen@Example.com"`,
+			want: Filter{{{Attr: "username", Op: OpEqual, Value: "Bjensen@Example.com"}}},
+		},
+		{
+			name: "escaped quote",
+			expr: `displayName eq "say \"hi\""`,
+			want: Filter{{{Attr: "displayname", Op: OpEqual, Value: `say "hi"`}}},
+		},
+		{
+			name: "present and boolean",
+			expr: `externalId pr AND active EQ True`,
+			want: Filter{{
+				{Attr: "externalid", Op: OpPresent},
+				{Attr: "active", Op: OpEqual, Value: "true"},
+			}},
+		},
+		{
+			name: "and binds tighter than or",
+			expr: `name.givenName sw "a" and emails.value co "x" or userName ew ".org"`,
+			want: Filter{
+				{
+					{Attr: "name.givenname", Op: OpStartsWith, Value: "a"},
+					{Attr: "emails.value", Op: OpContains, Value: "x"},
+				},
+				{{Attr: "username", Op: OpEndsWith, Value: ".org"}},
+			},
+		},
+		{
+			name:    "unsupported operator",
+			expr:    `userName like "a"`,
+			wantErr: ErrInvalidFilter,
+		},
+		{
+			name:    "missing value",
+			expr:    `userName eq`,
+			wantErr: ErrInvalidFilter,
+		},
+		{
+			name:    "unquoted string",
+			expr:    `userName eq bjensen`,
+			wantErr: ErrInvalidFilter,
+		},
+		{
+			name:    "unterminated string",
+			expr:    `userName eq "bjensen`,
+			wantErr: ErrInvalidFilter,
+		},
+		{
+			name:    "grouping",
+			expr:    `(userName eq "a")`,
+			wantErr: ErrInvalidFilter,
+		},
+		{
+			name:    "dangling and",
+			expr:    `userName eq "a" and`,
+			wantErr: ErrInvalidFilter,
+		},
+		{
+			name:    "missing conjunction",
+			expr:    `userName eq "a" active eq true`,
+			wantErr: ErrInvalidFilter,
+		},
+		{
+			name:    "urn attribute",
+			expr:    `urn:ietf:params:scim:schemas:core:2.0:User:userName eq "a"`,
+			wantErr: ErrInvalidFilter,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFilter(tt.expr)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseFilter() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseFilter() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter_Match(t *testing.T) {
+	resource := map[string][]string{
+		"username":     {"BJensen@example.com"},
+		"emails.value": {"bjensen@example.com", "babs@jensen.org"},
+		"active":       {"true"},
+		"externalid":   {""},
+	}
+	values := func(attr string) []string { return resource[attr] }
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{expr: ``, want: true},
+		{expr: `userName eq "bjensen@example.com"`, want: true},
+		{expr: `userName eq "other@example.com"`, want: false},
+		{expr: `userName ne "other@example.com"`, want: true},
+		{expr: `emails.value ew "@jensen.org"`, want: true},
+		{expr: `emails.value ne "babs@jensen.org"`, want: false},
+		{expr: `userName sw "bjen" and active eq true`, want: true},
+		{expr: `userName sw "bjen" and active eq false`, want: false},
+		{expr: `active eq false or emails.value co "babs"`, want: true},
+		{expr: `externalId pr`, want: false},
+		{expr: `displayName pr`, want: false},
+		{expr: `userName gt "a" and userName lt "c"`, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			filter, err := ParseFilter(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseFilter() error = %v", err)
+			}
+			if got := filter.Match(values); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		want    Path
+		wantErr error
+	}{
+		{
+			name: "attribute",
+			path: "displayName",
+			want: Path{Attr: "displayname"},
+		},
+		{
+			name: "sub-attribute",
+			path: "name.familyName",
+			want: Path{Attr: "name.familyname"},
+		},
+		{
+			name: "value filter",
+			path: `members[value eq "2"]`,
+			want: Path{Attr: "members", Filter: Filter{{{Attr: "value", Op: OpEqual, Value: "2"}}}},
+		},
+		{
+			name: "value filter with sub-attribute",
+			path: `emails[type eq "work"].value`,
+			want: Path{
+				Attr:   "emails",
+				Filter: Filter{{{Attr: "type", Op: OpEqual, Value: "work"}}},
+				Sub:    "value",
+			},
+		},
+		{
+			name:    "empty",
+			path:    "",
+			wantErr: ErrInvalidPath,
+		},
+		{
+			name:    "unterminated filter",
+			path:    `members[value eq "2"`,
+			wantErr: ErrInvalidPath,
+		},
+		{
+			name:    "empty filter",
+			path:    `members[]`,
+			wantErr: ErrInvalidPath,
+		},
+		{
+			name:    "invalid filter",
+			path:    `members[value eq]`,
+			wantErr: ErrInvalidFilter,
+		},
+		{
+			name:    "garbage after filter",
+			path:    `members[value eq "2"]x`,
+			wantErr: ErrInvalidPath,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePath(tt.path)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParsePath() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePath() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
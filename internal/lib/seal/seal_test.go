@@ -0,0 +1,48 @@
+package seal
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestSealer(t *testing.T) {
+	s, err := New(bytes.Repeat([]byte{1}, KeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, err := s.Seal([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, []byte("secret")) {
+		t.Error("Seal() output contains plaintext")
+	}
+
+	got, err := s.Open(sealed)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if string(got) != "secret" {
+		t.Errorf("Open() = %q, want %q", got, "secret")
+	}
+
+	sealed[len(sealed)-1] ^= 1
+	if _, err := s.Open(sealed); !errors.Is(err, ErrInvalidCiphertext) {
+		t.Errorf("Open() tampered error = %v, want ErrInvalidCiphertext", err)
+	}
+
+	other, err := New(bytes.Repeat([]byte{2}, KeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, _ = s.Seal([]byte("secret"))
+	if _, err := other.Open(sealed); !errors.Is(err, ErrInvalidCiphertext) {
+		t.Errorf("Open() with other key error = %v, want ErrInvalidCiphertext", err)
+	}
+
+	if _, err := New([]byte("short")); err == nil {
+		t.Error("New() accepted short key")
+	}
+}
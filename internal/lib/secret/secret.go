@@ -0,0 +1,45 @@
+// Package secret generates random codes handed out to users
+// and hashes them for storage.
+package secret
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"math/big"
+)
+
+// New returns a URL-safe random string made of n random bytes
+func New(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Hash returns SHA-256 of the code
+//
+// Codes are random and long enough, so a fast hash is sufficient.
+func Hash(code string) []byte {
+	h := sha256.Sum256([]byte(code))
+	return h[:]
+}
+
+// NewDigits returns a random decimal code of n digits, leading zeros included
+func NewDigits(n int) (string, error) {
+	return NewFromAlphabet("0123456789", n)
+}
+
+// NewFromAlphabet returns a random code of n characters of alphabet
+func NewFromAlphabet(alphabet string, n int) (string, error) {
+	code := make([]byte, n)
+	for i := range code {
+		c, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+		if err != nil {
+			return "", err
+		}
+		code[i] = alphabet[c.Int64()]
+	}
+	return string(code), nil
+}
@@ -0,0 +1,288 @@
+// Package userfile reads and writes user records in CSV and JSONL formats
+// used by bulk import and export.
+package userfile
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"strconv"
+	"strings"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/lib/password"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+var (
+	ErrUnknownFormat   = errors.New("unknown format")
+	ErrEmailRequired   = errors.New("email is required")
+	ErrInvalidEmail    = errors.New("email is not valid")
+	ErrInvalidPassHash = errors.New("password hash is not bcrypt or supported legacy format")
+)
+
+// csvHeader is the column order used for export, import accepts any order
+var csvHeader = []string{"email", "pass_hash", "pepper_key_id", "is_admin", "name", "given_name", "family_name", "picture", "locale"}
+
+// Record is a single user as it appears in import and export files
+//
+// PepperKeyID is only set for hashes exported from a server with pepper enabled.
+type Record struct {
+	Email       string `json:"email"`
+	PassHash    string `json:"pass_hash,omitempty"`
+	PepperKeyID string `json:"pepper_key_id,omitempty"`
+	IsAdmin     bool   `json:"is_admin"`
+	Name        string `json:"name,omitempty"`
+	GivenName   string `json:"given_name,omitempty"`
+	FamilyName  string `json:"family_name,omitempty"`
+	Picture     string `json:"picture,omitempty"`
+	Locale      string `json:"locale,omitempty"`
+}
+
+// User validates record and converts it to user model
+//
+// Password hash is optional, a user without it can't log in with password.
+// Hashes in foreign formats are marked as legacy.
+func (r Record) User() (models.User, error) {
+	if r.Email == "" {
+		return models.User{}, ErrEmailRequired
+	}
+	if _, err := mail.ParseAddress(r.Email); err != nil {
+		return models.User{}, ErrInvalidEmail
+	}
+
+	passHash := []byte(r.PassHash)
+	legacy := password.IsLegacy(passHash)
+	if len(passHash) > 0 && !legacy {
+		if _, err := bcrypt.Cost(passHash); err != nil {
+			return models.User{}, ErrInvalidPassHash
+		}
+	}
+
+	if legacy && r.PepperKeyID != "" {
+		return models.User{}, ErrInvalidPassHash
+	}
+
+	return models.User{
+		Email:       r.Email,
+		PassHash:    passHash,
+		PassLegacy:  legacy,
+		PepperKeyID: r.PepperKeyID,
+		IsAdmin:     r.IsAdmin,
+		Profile: models.Profile{
+			Name:       r.Name,
+			GivenName:  r.GivenName,
+			FamilyName: r.FamilyName,
+			Picture:    r.Picture,
+			Locale:     r.Locale,
+		},
+	}, nil
+}
+
+// FromUser converts user model to record
+func FromUser(user models.User) Record {
+	return Record{
+		Email:       user.Email,
+		PassHash:    string(user.PassHash),
+		PepperKeyID: user.PepperKeyID,
+		IsAdmin:     user.IsAdmin,
+		Name:        user.Profile.Name,
+		GivenName:   user.Profile.GivenName,
+		FamilyName:  user.Profile.FamilyName,
+		Picture:     user.Profile.Picture,
+		Locale:      user.Profile.Locale,
+	}
+}
+
+// RowError is returned for a malformed row, reading can continue after it
+type RowError struct {
+	Line int
+	Err  error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// Reader reads records one by one
+//
+// Read returns the record with its line number in the file. A malformed row
+// is reported as *RowError, any other error is fatal.
+// io.EOF is returned when there are no more records.
+type Reader interface {
+	Read() (rec Record, line int, err error)
+}
+
+// Writer writes records, Flush must be called when done
+type Writer interface {
+	Write(rec Record) error
+	Flush() error
+}
+
+// NewReader creates reader for the given format
+func NewReader(r io.Reader, format string) (Reader, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVReader(r)
+	case FormatJSONL:
+		return &jsonlReader{scanner: bufio.NewScanner(r)}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+	}
+}
+
+// NewWriter creates writer for the given format
+func NewWriter(w io.Writer, format string) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(csvHeader); err != nil {
+			return nil, err
+		}
+		return &csvWriter{w: cw}, nil
+	case FormatJSONL:
+		bw := bufio.NewWriter(w)
+		return &jsonlWriter{w: bw, enc: json.NewEncoder(bw)}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+	}
+}
+
+type csvReader struct {
+	r       *csv.Reader
+	columns map[string]int
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["email"]; !ok {
+		return nil, fmt.Errorf("csv header: %w", ErrEmailRequired)
+	}
+
+	return &csvReader{r: cr, columns: columns}, nil
+}
+
+func (c *csvReader) Read() (Record, int, error) {
+	fields, err := c.r.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return Record{}, parseErr.StartLine, &RowError{Line: parseErr.StartLine, Err: err}
+		}
+		return Record{}, 0, err
+	}
+	line, _ := c.r.FieldPos(0)
+
+	field := func(name string) string {
+		i, ok := c.columns[name]
+		if !ok || i >= len(fields) {
+			return ""
+		}
+		return strings.TrimSpace(fields[i])
+	}
+
+	rec := Record{
+		Email:       field("email"),
+		PassHash:    field("pass_hash"),
+		PepperKeyID: field("pepper_key_id"),
+		Name:        field("name"),
+		GivenName:   field("given_name"),
+		FamilyName:  field("family_name"),
+		Picture:     field("picture"),
+		Locale:      field("locale"),
+	}
+	if v := field("is_admin"); v != "" {
+		rec.IsAdmin, err = strconv.ParseBool(v)
+		if err != nil {
+			return Record{}, line, &RowError{Line: line, Err: fmt.Errorf("is_admin: %w", err)}
+		}
+	}
+
+	return rec, line, nil
+}
+
+type jsonlReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (j *jsonlReader) Read() (Record, int, error) {
+	for j.scanner.Scan() {
+		j.line++
+		data := strings.TrimSpace(j.scanner.Text())
+		if data == "" {
+			continue
+		}
+
+		var rec Record
+		if err := json.Unmarshal([]byte(data), &rec); err != nil {
+			return Record{}, j.line, &RowError{Line: j.line, Err: err}
+		}
+		return rec, j.line, nil
+	}
+	if err := j.scanner.Err(); err != nil {
+		return Record{}, j.line, err
+	}
+	return Record{}, j.line, io.EOF
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvWriter) Write(rec Record) error {
+	return c.w.Write([]string{
+		rec.Email,
+		rec.PassHash,
+		rec.PepperKeyID,
+		strconv.FormatBool(rec.IsAdmin),
+		rec.Name,
+		rec.GivenName,
+		rec.FamilyName,
+		rec.Picture,
+		rec.Locale,
+	})
+}
+
+func (c *csvWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type jsonlWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func (j *jsonlWriter) Write(rec Record) error {
+	return j.enc.Encode(rec)
+}
+
+func (j *jsonlWriter) Flush() error {
+	return j.w.Flush()
+}
@@ -0,0 +1,134 @@
+package userfile
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	records := []Record{
+		{
+			Email:    "mail1@buba.com",
+			PassHash: "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy",
+			IsAdmin:  true,
+			Name:     "Buba, Jr.",
+		},
+		{
+			Email:      "mail2@buba.com",
+			GivenName:  "Mail",
+			FamilyName: "Two",
+			Locale:     "en-US",
+		},
+	}
+
+	for _, format := range []string{FormatCSV, FormatJSONL} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewWriter(&buf, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, rec := range records {
+				if err := w.Write(rec); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			r, err := NewReader(&buf, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range records {
+				got, _, err := r.Read()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != want {
+					t.Errorf("Read() = %+v, want %+v", got, want)
+				}
+			}
+			if _, _, err := r.Read(); !errors.Is(err, io.EOF) {
+				t.Errorf("Read() error = %v, want io.EOF", err)
+			}
+		})
+	}
+}
+
+func TestReader_RowErrors(t *testing.T) {
+	input := `{"email":"mail1@buba.com"}
+not json
+
+{"email":"mail2@buba.com","is_admin":true}
+`
+	r, err := NewReader(strings.NewReader(input), FormatJSONL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantLines := []int{1, 2, 4}
+	for i, wantLine := range wantLines {
+		_, line, err := r.Read()
+		if line != wantLine {
+			t.Errorf("Read() line = %d, want %d", line, wantLine)
+		}
+		var rowErr *RowError
+		if isRowErr := errors.As(err, &rowErr); isRowErr != (i == 1) {
+			t.Errorf("Read() error = %v on line %d", err, line)
+		}
+	}
+}
+
+func TestRecord_User(t *testing.T) {
+	tests := []struct {
+		name       string
+		rec        Record
+		wantLegacy bool
+		wantErr    error
+	}{
+		{
+			name: "without password",
+			rec:  Record{Email: "mail1@buba.com"},
+		},
+		{
+			name: "bcrypt",
+			rec:  Record{Email: "mail1@buba.com", PassHash: "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy"},
+		},
+		{
+			name:       "legacy",
+			rec:        Record{Email: "mail1@buba.com", PassHash: "$1$saltsalt$9xy1btjgzLYfb7hivXtC//"},
+			wantLegacy: true,
+		},
+		{
+			name:    "plaintext password",
+			rec:     Record{Email: "mail1@buba.com", PassHash: "hunter2"},
+			wantErr: ErrInvalidPassHash,
+		},
+		{
+			name:    "empty email",
+			rec:     Record{},
+			wantErr: ErrEmailRequired,
+		},
+		{
+			name:    "invalid email",
+			rec:     Record{Email: "this is not an email"},
+			wantErr: ErrInvalidEmail,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := tt.rec.User()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("User() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if user.PassLegacy != tt.wantLegacy {
+				t.Errorf("User() legacy = %v, want %v", user.PassLegacy, tt.wantLegacy)
+			}
+		})
+	}
+}
@@ -0,0 +1,335 @@
+package apikeys
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/lib/jwt"
+	"github.com/Len4i/auth-service/internal/lib/secret"
+	"github.com/Len4i/auth-service/internal/services/storage"
+)
+
+var (
+	ErrorInvalidName   = errors.New("invalid api key name")
+	ErrorInvalidScope  = errors.New("invalid api key scope")
+	ErrorInvalidTTL    = errors.New("invalid ttl")
+	ErrorInvalidAppID  = errors.New("invalid app id")
+	ErrorInvalidAPIKey = errors.New("invalid api key")
+	ErrorKeyNotFound   = errors.New("api key not found")
+)
+
+const (
+	// keyType starts every api key so it's told apart from tokens and
+	// easy to find by secret scanners
+	keyType = "ak_"
+	// prefixBytes encode to the visible prefix of the key, secretBytes to the rest
+	prefixBytes = 6
+	secretBytes = 24
+	maxNameLen  = 64
+	// lastUsedResolution limits how often using a key is written to storage
+	lastUsedResolution = time.Minute
+)
+
+var (
+	prefixLen = len(keyType) + base64.RawURLEncoding.EncodedLen(prefixBytes)
+	scopeRe   = regexp.MustCompile(`^([a-z0-9][a-z0-9_.:-]{0,127}|\*)$`)
+)
+
+type APIKeyStorage interface {
+	SaveAPIKey(ctx context.Context, key models.APIKey, keyHash []byte) (id int64, err error)
+	APIKey(ctx context.Context, prefix string) (key models.APIKey, keyHash []byte, err error)
+	APIKeys(ctx context.Context, userID int64) ([]models.APIKey, error)
+	TouchAPIKey(ctx context.Context, id int64, usedAt time.Time) error
+	RevokeAPIKey(ctx context.Context, userID int64, id int64) error
+}
+
+type UserProvider interface {
+	UserByID(ctx context.Context, id int64) (models.User, error)
+}
+
+type AppProvider interface {
+	App(ctx context.Context, appID int) (app models.App, err error)
+}
+
+type TokenVerifier interface {
+	VerifyToken(ctx context.Context, token string) (jwt.Claims, error)
+}
+
+type APIKeys struct {
+	log          *slog.Logger
+	keys         APIKeyStorage
+	userProvider UserProvider
+	appProvider  AppProvider
+	tokens       TokenVerifier
+	tokenTTL     time.Duration
+	tokenKey     []byte
+}
+
+// NewAPIKeys creates new api keys service
+//
+// tokens verifies bearer tokens that are not api keys.
+func NewAPIKeys(
+	log *slog.Logger,
+	keys APIKeyStorage,
+	userProvider UserProvider,
+	appProvider AppProvider,
+	tokens TokenVerifier,
+	tokenTTL time.Duration,
+	tokenKey []byte,
+) *APIKeys {
+	return &APIKeys{
+		log:          log,
+		keys:         keys,
+		userProvider: userProvider,
+		appProvider:  appProvider,
+		tokens:       tokens,
+		tokenTTL:     tokenTTL,
+		tokenKey:     tokenKey,
+	}
+}
+
+// CreateAPIKey creates api key of the user in the app and returns it with the key
+//
+// ttl 0 creates a key that never expires.
+// The key is not stored and can't be retrieved later.
+func (a *APIKeys) CreateAPIKey(
+	ctx context.Context,
+	userID int64,
+	appID int,
+	name string,
+	scopes []string,
+	ttl time.Duration,
+) (models.APIKey, string, error) {
+	const op = "apikeys.CreateAPIKey"
+	log := a.log.With(slog.String("operation", op))
+
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxNameLen {
+		return models.APIKey{}, "", fmt.Errorf("%s: %w", op, ErrorInvalidName)
+	}
+	if len(scopes) == 0 {
+		return models.APIKey{}, "", fmt.Errorf("%s: %w", op, ErrorInvalidScope)
+	}
+	for _, scope := range scopes {
+		if !scopeRe.MatchString(scope) {
+			return models.APIKey{}, "", fmt.Errorf("%s: %w", op, ErrorInvalidScope)
+		}
+	}
+	if ttl < 0 {
+		return models.APIKey{}, "", fmt.Errorf("%s: %w", op, ErrorInvalidTTL)
+	}
+
+	if _, err := a.appProvider.App(ctx, appID); err != nil {
+		if errors.Is(err, storage.ErrorAppNotFound) {
+			log.Warn("app not found", slog.Int("appID", appID))
+			return models.APIKey{}, "", fmt.Errorf("%s: %w", op, ErrorInvalidAppID)
+		}
+		log.Error("failed to get app", "error", err)
+		return models.APIKey{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	prefix, err := secret.New(prefixBytes)
+	if err != nil {
+		log.Error("failed to generate api key", "error", err)
+		return models.APIKey{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+	rest, err := secret.New(secretBytes)
+	if err != nil {
+		log.Error("failed to generate api key", "error", err)
+		return models.APIKey{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+	prefix = keyType + prefix
+	plain := prefix + "_" + rest
+
+	scopes = slices.Clone(scopes)
+	slices.Sort(scopes)
+
+	now := time.Now()
+	key := models.APIKey{
+		UserID:    userID,
+		AppID:     appID,
+		Name:      name,
+		Prefix:    prefix,
+		Scopes:    slices.Compact(scopes),
+		CreatedAt: now,
+	}
+	if ttl > 0 {
+		key.ExpiresAt = now.Add(ttl)
+	}
+
+	key.ID, err = a.keys.SaveAPIKey(ctx, key, secret.Hash(plain))
+	if err != nil {
+		log.Error("failed to save api key", "error", err)
+		return models.APIKey{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("api key created", slog.Int64("keyID", key.ID), slog.Int64("userID", userID))
+
+	return key, plain, nil
+}
+
+// ListAPIKeys returns api keys of the user
+//
+// Expired and revoked keys are skipped unless includeInactive is set
+func (a *APIKeys) ListAPIKeys(ctx context.Context, userID int64, includeInactive bool) ([]models.APIKey, error) {
+	const op = "apikeys.ListAPIKeys"
+	log := a.log.With(slog.String("operation", op))
+
+	keys, err := a.keys.APIKeys(ctx, userID)
+	if err != nil {
+		log.Error("failed to get api keys", "error", err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if includeInactive {
+		return keys, nil
+	}
+
+	now := time.Now()
+	active := keys[:0]
+	for _, key := range keys {
+		if key.Active(now) {
+			active = append(active, key)
+		}
+	}
+
+	return active, nil
+}
+
+// RevokeAPIKey revokes api key of the user
+//
+// Tokens already exchanged for the key stay valid until they expire.
+func (a *APIKeys) RevokeAPIKey(ctx context.Context, userID int64, id int64) error {
+	const op = "apikeys.RevokeAPIKey"
+	log := a.log.With(slog.String("operation", op))
+
+	if err := a.keys.RevokeAPIKey(ctx, userID, id); err != nil {
+		if errors.Is(err, storage.ErrorAPIKeyNotFound) {
+			log.Warn("api key not found", slog.Int64("keyID", id), slog.Int64("userID", userID))
+			return fmt.Errorf("%s: %w", op, ErrorKeyNotFound)
+		}
+		log.Error("failed to revoke api key", "error", err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("api key revoked", slog.Int64("keyID", id), slog.Int64("userID", userID))
+
+	return nil
+}
+
+// VerifyToken returns claims of the bearer token, which is either
+// an api key or a token verified by the wrapped verifier
+//
+// Claims of api keys are restricted to their scopes and app.
+func (a *APIKeys) VerifyToken(ctx context.Context, token string) (jwt.Claims, error) {
+	const op = "apikeys.VerifyToken"
+
+	if !strings.HasPrefix(token, keyType) {
+		return a.tokens.VerifyToken(ctx, token)
+	}
+
+	key, user, err := a.verifyKey(ctx, token)
+	if err != nil {
+		return jwt.Claims{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return jwt.Claims{
+		UserID:    user.ID,
+		Email:     user.Email,
+		AppID:     key.AppID,
+		ExpiresAt: key.ExpiresAt,
+		Scope:     jwt.Scope{Scopes: key.Scopes},
+	}, nil
+}
+
+// ExchangeAPIKey returns a token of the app the key belongs to,
+// restricted to the scopes of the key
+//
+// The token expires with the key if it's sooner than the token TTL.
+func (a *APIKeys) ExchangeAPIKey(ctx context.Context, plain string) (string, error) {
+	const op = "apikeys.ExchangeAPIKey"
+	log := a.log.With(slog.String("operation", op))
+
+	key, user, err := a.verifyKey(ctx, plain)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	app, err := a.appProvider.App(ctx, key.AppID)
+	if err != nil {
+		log.Error("failed to get app", "error", err)
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	ttl := a.tokenTTL
+	if !key.ExpiresAt.IsZero() && time.Until(key.ExpiresAt) < ttl {
+		ttl = time.Until(key.ExpiresAt)
+	}
+
+	token, err := jwt.NewToken(user, app, jwt.Scope{Scopes: key.Scopes}, jwt.Authentication{}, ttl, a.tokenKey)
+	if err != nil {
+		log.Error("failed to generate token", "error", err)
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("api key exchanged", slog.Int64("keyID", key.ID), slog.Int64("userID", user.ID))
+
+	return token, nil
+}
+
+// verifyKey looks up active api key by its prefix and checks the rest of it
+func (a *APIKeys) verifyKey(ctx context.Context, plain string) (models.APIKey, models.User, error) {
+	log := a.log.With(slog.String("operation", "apikeys.verifyKey"))
+
+	if len(plain) <= prefixLen || plain[prefixLen] != '_' {
+		return models.APIKey{}, models.User{}, ErrorInvalidAPIKey
+	}
+
+	key, keyHash, err := a.keys.APIKey(ctx, plain[:prefixLen])
+	if err != nil {
+		if errors.Is(err, storage.ErrorAPIKeyNotFound) {
+			log.Warn("api key not found", slog.String("prefix", plain[:prefixLen]))
+			return models.APIKey{}, models.User{}, ErrorInvalidAPIKey
+		}
+		log.Error("failed to get api key", "error", err)
+		return models.APIKey{}, models.User{}, err
+	}
+
+	now := time.Now()
+	if subtle.ConstantTimeCompare(keyHash, secret.Hash(plain)) != 1 {
+		log.Warn("api key doesn't match", slog.Int64("keyID", key.ID))
+		return models.APIKey{}, models.User{}, ErrorInvalidAPIKey
+	}
+	if !key.Active(now) {
+		log.Warn("api key is not active", slog.Int64("keyID", key.ID))
+		return models.APIKey{}, models.User{}, ErrorInvalidAPIKey
+	}
+
+	user, err := a.userProvider.UserByID(ctx, key.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrorUserNotFound) {
+			log.Warn("user of api key not found", slog.Int64("keyID", key.ID))
+			return models.APIKey{}, models.User{}, ErrorInvalidAPIKey
+		}
+		log.Error("failed to get user", "error", err)
+		return models.APIKey{}, models.User{}, err
+	}
+
+	if now.Sub(key.LastUsedAt) >= lastUsedResolution {
+		if err := a.keys.TouchAPIKey(ctx, key.ID, now); err != nil {
+			// not worth failing the request
+			log.Error("failed to update api key last use", "error", err)
+		}
+	}
+
+	return key, user, nil
+}
@@ -5,12 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 	"time"
 
 	"github.com/Len4i/auth-service/internal/domain/models"
 	"github.com/Len4i/auth-service/internal/lib/jwt"
+	"github.com/Len4i/auth-service/internal/lib/password"
 	"github.com/Len4i/auth-service/internal/services/storage"
-	"golang.org/x/crypto/bcrypt"
 )
 
 var (
@@ -18,60 +19,141 @@ var (
 	ErrorInvalidAppID       = errors.New("invalid app id")
 	ErrorInvalidUserID      = errors.New("invalid user id")
 	ErrorUserExists         = errors.New("user already exists")
+
+	ErrorRegistrationClosed    = errors.New("registration is closed")
+	ErrorInviteRequired        = errors.New("invite code is required")
+	ErrorInvalidInvite         = errors.New("invite code is not valid")
+	ErrorEmailDomainNotAllowed = errors.New("email domain is not allowed")
+
+	ErrorMFARequired      = errors.New("mfa required")
+	ErrorInvalidChallenge = errors.New("invalid mfa challenge")
+	ErrorInvalidMFACode   = errors.New("invalid mfa code")
 )
 
 type UserSaver interface {
-	SaveUser(ctx context.Context, email string, passHash []byte) (userID int64, err error)
+	SaveUser(ctx context.Context, email string, passHash []byte, pepperKeyID string) (userID int64, err error)
+	SaveInvitedUser(
+		ctx context.Context,
+		email string,
+		passHash []byte,
+		pepperKeyID string,
+		inviteID int64,
+		now time.Time,
+	) (userID int64, err error)
+	UpdatePassHash(ctx context.Context, userID int64, passHash []byte, pepperKeyID string) error
+	SaveFederatedUser(ctx context.Context, user models.User, identity models.FederatedIdentity) (userID int64, err error)
 }
 
 type UserProvider interface {
 	User(ctx context.Context, email string) (user models.User, err error)
+	UserByID(ctx context.Context, id int64) (user models.User, err error)
 	IsAdmin(ctx context.Context, userID int64) (bool, error)
 }
 
 type AppProvider interface {
 	App(ctx context.Context, appID int) (app models.App, err error)
+	AnyAppAllowsOrigin(ctx context.Context, origin string) (bool, error)
+}
+
+// RoleProvider returns user roles embedded in tokens
+type RoleProvider interface {
+	UserRoles(ctx context.Context, userID int64, appID int) ([]models.Role, error)
 }
 
 type Auth struct {
-	log          *slog.Logger
-	userSaver    UserSaver
-	userProvider UserProvider
-	appProvider  AppProvider
-	tokenTTL     time.Duration
+	log              *slog.Logger
+	userSaver        UserSaver
+	userProvider     UserProvider
+	appProvider      AppProvider
+	inviteProvider   InviteProvider
+	roleProvider     RoleProvider
+	orgProvider      OrgProvider
+	challengeStorage ChallengeStorage
+	mfa              MFAVerifier
+	passkeys         PasskeyAuthenticator
+	loginCodes       LoginCodeStorage
+	mailer           Mailer
+	passHasher       *password.Hasher
+	registration     RegistrationPolicy
+	loginCodePolicy  LoginCodePolicy
+	tokenTTL         time.Duration
+	tokenKey         []byte
 }
 
 // NewAuth creates new auth service
-func NewAuth(log *slog.Logger, userSaver UserSaver, userProvider UserProvider, appProvider AppProvider, tokenTTL time.Duration) *Auth {
+//
+// Nil mailer disables passwordless login by email.
+func NewAuth(
+	log *slog.Logger,
+	userSaver UserSaver,
+	userProvider UserProvider,
+	appProvider AppProvider,
+	inviteProvider InviteProvider,
+	roleProvider RoleProvider,
+	orgProvider OrgProvider,
+	challengeStorage ChallengeStorage,
+	mfa MFAVerifier,
+	passkeys PasskeyAuthenticator,
+	loginCodes LoginCodeStorage,
+	mailer Mailer,
+	passHasher *password.Hasher,
+	registration RegistrationPolicy,
+	loginCodePolicy LoginCodePolicy,
+	tokenTTL time.Duration,
+	tokenKey []byte,
+) *Auth {
 	return &Auth{
-		log:          log,
-		userSaver:    userSaver,
-		userProvider: userProvider,
-		appProvider:  appProvider,
-		tokenTTL:     tokenTTL,
+		log:              log,
+		userSaver:        userSaver,
+		userProvider:     userProvider,
+		appProvider:      appProvider,
+		inviteProvider:   inviteProvider,
+		roleProvider:     roleProvider,
+		orgProvider:      orgProvider,
+		challengeStorage: challengeStorage,
+		mfa:              mfa,
+		passkeys:         passkeys,
+		loginCodes:       loginCodes,
+		mailer:           mailer,
+		passHasher:       passHasher,
+		registration:     registration,
+		loginCodePolicy:  loginCodePolicy,
+		tokenTTL:         tokenTTL,
+		tokenKey:         tokenKey,
 	}
 }
 
 // RegisterNewUser registers new user
 //
+// Registration mode of the app (global one if appID is 0) and email domain
+// lists are checked first, invite-only mode requires a valid inviteCode.
 // If user with such email already exists, returns error
-func (a *Auth) Register(ctx context.Context, email string, password string) (userID int64, err error) {
+func (a *Auth) Register(ctx context.Context, email string, password string, appID int, inviteCode string) (userID int64, err error) {
 	const op = "auth.Register"
 	log := a.log.With(slog.String("operation", op))
 
-	passHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	invite, err := a.checkRegistration(ctx, log, email, appID, inviteCode)
 	if err != nil {
-		log.Error("failed to generate password hash", err)
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
-	userID, err = a.userSaver.SaveUser(ctx, email, passHash)
+	passHash, pepperKeyID, err := a.passHasher.Hash(password)
+	if err != nil {
+		log.Error("failed to generate password hash", "error", err)
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	userID, err = a.saveUser(ctx, email, passHash, pepperKeyID, invite)
 	if err != nil {
 		if errors.Is(err, storage.ErrorUserExists) {
 			log.Warn("user already exists", slog.String("email", email))
 			return 0, fmt.Errorf("%s: %w", op, ErrorUserExists)
 		}
-		log.Error("failed to save user", err)
+		if errors.Is(err, ErrorInvalidInvite) {
+			log.Warn("invite is no longer valid", slog.Int64("inviteID", invite.ID))
+			return 0, fmt.Errorf("%s: %w", op, ErrorInvalidInvite)
+		}
+		log.Error("failed to save user", "error", err)
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
@@ -83,8 +165,12 @@ func (a *Auth) Register(ctx context.Context, email string, password string) (use
 
 // Login logs user in and returns token
 //
-// If user is not found or password is incorrect, returns error
-func (a *Auth) Login(ctx context.Context, email string, password string, appID int) (token string, err error) {
+// If user is not found or password is incorrect, returns error.
+// If user has MFA enabled, returns *MFARequiredError with the challenge
+// to be completed by VerifyMFA or FinishPasskeyLogin.
+// Non-zero orgID scopes the token to the org, user must be its member
+// and satisfy the org login policy.
+func (a *Auth) Login(ctx context.Context, email string, password string, appID int, orgID int64) (token string, err error) {
 	const op = "auth.Login"
 	log := a.log.With(slog.String("operation", op))
 
@@ -95,34 +181,175 @@ func (a *Auth) Login(ctx context.Context, email string, password string, appID i
 			return "", fmt.Errorf("%s: %w", op, ErrorInvalidCredentials)
 		}
 
-		log.Error("failed to get user", err)
+		log.Error("failed to get user", "error", err)
 		return "", fmt.Errorf("%s: %w", op, err)
 	}
 
-	if err := bcrypt.CompareHashAndPassword(user.PassHash, []byte(password)); err != nil {
-		log.Error("failed to compare password", err)
+	if err := a.passHasher.Compare(user.PassHash, user.PepperKeyID, user.PassLegacy, password); err != nil {
+		log.Error("failed to compare password", "error", err)
 		return "", fmt.Errorf("%s: %w", op, ErrorInvalidCredentials)
 	}
 
+	if a.passHasher.NeedsRehash(user.PepperKeyID, user.PassLegacy) {
+		a.upgradePassHash(ctx, log, user.ID, password)
+	}
+
 	app, err := a.appProvider.App(ctx, appID)
 	if err != nil {
 		if errors.Is(err, storage.ErrorAppNotFound) {
 			a.log.Warn("app not found", slog.Int("appID", appID))
 			return "", fmt.Errorf("%s: %w", op, err)
 		}
+		log.Error("failed to get app", "error", err)
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	token, err = a.passFirstFactor(ctx, log, user, app, orgID, AMRPassword)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
 	}
+
+	return token, nil
+}
+
+// passFirstFactor returns token for user who passed the first factor
+// with authnMethod, or *MFARequiredError if user has a second factor enabled
+func (a *Auth) passFirstFactor(
+	ctx context.Context,
+	log *slog.Logger,
+	user models.User,
+	app models.App,
+	orgID int64,
+	authnMethod string,
+) (string, error) {
+	// fail before the second factor if user can't log in with the org anyway
+	if orgID != 0 {
+		authn := newAuthentication(authnMethod)
+		if _, err := a.orgMember(ctx, log, user.ID, app.ID, orgID, authn, false); err != nil {
+			return "", err
+		}
+	}
+
+	methods, err := a.mfaMethods(ctx, user.ID)
+	if err != nil {
+		log.Error("failed to check mfa", "error", err)
+		return "", err
+	}
+	if len(methods) > 0 {
+		mfaErr, err := a.startMFA(ctx, user.ID, app.ID, orgID, []string{authnMethod}, methods)
+		if err != nil {
+			log.Error("failed to start mfa", "error", err)
+			return "", err
+		}
+		log.Info("second factor required", slog.Int64("userID", user.ID))
+		return "", mfaErr
+	}
+
 	log.Info("user logged in", slog.Int64("userID", user.ID))
 
-	token, err = jwt.NewToken(user, app, a.tokenTTL)
+	return a.newToken(ctx, log, user, app, orgID, newAuthentication(authnMethod), a.tokenTTL)
+}
+
+// newToken issues token with user roles in app, scoped to the org if orgID is not 0
+func (a *Auth) newToken(
+	ctx context.Context,
+	log *slog.Logger,
+	user models.User,
+	app models.App,
+	orgID int64,
+	authn jwt.Authentication,
+	duration time.Duration,
+) (string, error) {
+	roles, err := a.roleProvider.UserRoles(ctx, user.ID, app.ID)
+	if err != nil {
+		log.Error("failed to get user roles", "error", err)
+		return "", err
+	}
+
+	var scope jwt.Scope
+	for _, role := range roles {
+		// app role may have the name of a global one
+		if !slices.Contains(scope.Roles, role.Name) {
+			scope.Roles = append(scope.Roles, role.Name)
+		}
+	}
+
+	if orgID != 0 {
+		member, err := a.orgMember(ctx, log, user.ID, app.ID, orgID, authn, true)
+		if err != nil {
+			return "", err
+		}
+		scope.OrgID = member.OrgID
+		scope.OrgRole = member.Role
+	}
+
+	token, err := jwt.NewToken(user, app, scope, authn, duration, a.tokenKey)
+	if err != nil {
+		log.Error("failed to generate token", "error", err)
+		return "", err
+	}
+
+	return token, nil
+}
+
+// upgradePassHash rehashes legacy or outdated peppered password
+// with bcrypt and the current pepper key
+//
+// Failure is not fatal for login, user will be upgraded on next login
+func (a *Auth) upgradePassHash(ctx context.Context, log *slog.Logger, userID int64, pass string) {
+	passHash, pepperKeyID, err := a.passHasher.Hash(pass)
+	if err != nil {
+		log.Error("failed to generate password hash", "error", err)
+		return
+	}
+
+	if err := a.userSaver.UpdatePassHash(ctx, userID, passHash, pepperKeyID); err != nil {
+		log.Error("failed to upgrade password hash", "error", err)
+		return
+	}
+
+	log.Info("password hash upgraded", slog.Int64("userID", userID), slog.String("pepperKeyID", pepperKeyID))
+}
+
+// VerifyToken verifies token issued by Login and returns its claims
+//
+// Only tokens issued by the service are accepted, not ones signed by apps
+// with their secret.
+func (a *Auth) VerifyToken(ctx context.Context, token string) (jwt.Claims, error) {
+	const op = "auth.VerifyToken"
+
+	claims, err := jwt.ParseToken(token, func(appID int) (string, error) {
+		app, err := a.appProvider.App(ctx, appID)
+		if err != nil {
+			return "", err
+		}
+		return app.Secret, nil
+	}, a.tokenKey)
+	if err != nil {
+		return jwt.Claims{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return claims, nil
+}
+
+// IssueToken issues a new token for the login described by claims,
+// e.g. one completed on the OAuth login page and redeemed with a code
+//
+// The token is for the same user, app, org and authentication,
+// roles are read again.
+func (a *Auth) IssueToken(ctx context.Context, claims jwt.Claims) (token string, err error) {
+	const op = "auth.IssueToken"
+	log := a.log.With(slog.String("operation", op))
+
+	token, err = a.issueToken(ctx, log, claims.UserID, claims.AppID, claims.OrgID, claims.Authentication)
 	if err != nil {
-		log.Error("failed to generate token", err)
 		return "", fmt.Errorf("%s: %w", op, err)
 	}
 
 	return token, nil
 }
 
-// IsAdmin checks if user is admin
+// IsAdmin checks if user has the built-in admin role
 //
 // If user is not found, returns error
 func (a *Auth) IsAdmin(ctx context.Context, userID int64) (bool, error) {
@@ -135,9 +362,42 @@ func (a *Auth) IsAdmin(ctx context.Context, userID int64) (bool, error) {
 			a.log.Warn("user not found", slog.Int64("userID", userID))
 			return false, fmt.Errorf("%s: %w", op, ErrorInvalidAppID)
 		}
-		log.Error("failed to get user", err)
+		log.Error("failed to get user", "error", err)
 		return false, err
 	}
 
 	return isAdmin, nil
 }
+
+// OriginAllowed reports whether browsers may call the API from the web
+// origin on behalf of the app
+func (a *Auth) OriginAllowed(ctx context.Context, appID int, origin string) (bool, error) {
+	const op = "auth.OriginAllowed"
+	log := a.log.With(slog.String("operation", op))
+
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrorAppNotFound) {
+			log.Warn("app not found", slog.Int("appID", appID))
+			return false, fmt.Errorf("%s: %w", op, ErrorInvalidAppID)
+		}
+		log.Error("failed to get app", "error", err)
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return slices.Contains(app.AllowedOrigins, origin), nil
+}
+
+// KnownOrigin reports whether any app allows the web origin, browsers
+// are let to send requests from it, OriginAllowed checks them then
+func (a *Auth) KnownOrigin(ctx context.Context, origin string) (bool, error) {
+	const op = "auth.KnownOrigin"
+
+	ok, err := a.appProvider.AnyAppAllowsOrigin(ctx, origin)
+	if err != nil {
+		a.log.Error("failed to check origin", slog.String("operation", op), "error", err)
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ok, nil
+}
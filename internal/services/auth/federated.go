@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/services/storage"
+)
+
+// LoginFederated logs user in to the app after the user signed in
+// at an upstream identity provider linked to the account
+//
+// Like Login, returns *MFARequiredError if user has MFA enabled.
+func (a *Auth) LoginFederated(ctx context.Context, userID int64, appID int) (token string, err error) {
+	const op = "auth.LoginFederated"
+	log := a.log.With(slog.String("operation", op))
+
+	user, err := a.userProvider.UserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrorUserNotFound) {
+			log.Warn("user not found", slog.Int64("userID", userID))
+			return "", fmt.Errorf("%s: %w", op, ErrorInvalidUserID)
+		}
+		log.Error("failed to get user", "error", err)
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrorAppNotFound) {
+			log.Warn("app not found", slog.Int("appID", appID))
+			return "", fmt.Errorf("%s: %w", op, ErrorInvalidAppID)
+		}
+		log.Error("failed to get app", "error", err)
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	token, err = a.passFirstFactor(ctx, log, user, app, 0, AMRFederated)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return token, nil
+}
+
+// RegisterFederated provisions user on the first login at an upstream
+// identity provider and links the identity to it
+//
+// Registration mode of the app and email domain lists apply as for Register,
+// there is no invite, so invite-only registration is closed for it.
+// The user has no password, only the profile of the identity.
+func (a *Auth) RegisterFederated(
+	ctx context.Context,
+	user models.User,
+	identity models.FederatedIdentity,
+	appID int,
+) (userID int64, err error) {
+	const op = "auth.RegisterFederated"
+	log := a.log.With(slog.String("operation", op))
+
+	if _, err := a.checkRegistration(ctx, log, user.Email, appID, ""); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	userID, err = a.userSaver.SaveFederatedUser(ctx, user, identity)
+	if err != nil {
+		if errors.Is(err, storage.ErrorUserExists) {
+			log.Warn("user already exists", slog.String("email", user.Email))
+			return 0, fmt.Errorf("%s: %w", op, ErrorUserExists)
+		}
+		log.Error("failed to save user", "error", err)
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("user provisioned", slog.Int64("userID", userID), slog.String("connector", identity.Connector))
+
+	return userID, nil
+}
+
+// VerifyPassword checks password of the user, e.g. before linking
+// another identity to the account
+//
+// Users without a password never match.
+func (a *Auth) VerifyPassword(ctx context.Context, userID int64, password string) error {
+	const op = "auth.VerifyPassword"
+	log := a.log.With(slog.String("operation", op))
+
+	user, err := a.userProvider.UserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrorUserNotFound) {
+			log.Warn("user not found", slog.Int64("userID", userID))
+			return fmt.Errorf("%s: %w", op, ErrorInvalidCredentials)
+		}
+		log.Error("failed to get user", "error", err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(user.PassHash) == 0 {
+		log.Warn("user has no password", slog.Int64("userID", userID))
+		return fmt.Errorf("%s: %w", op, ErrorInvalidCredentials)
+	}
+
+	if err := a.passHasher.Compare(user.PassHash, user.PepperKeyID, user.PassLegacy, password); err != nil {
+		log.Warn("password mismatch", slog.Int64("userID", userID))
+		return fmt.Errorf("%s: %w", op, ErrorInvalidCredentials)
+	}
+
+	return nil
+}
@@ -0,0 +1,269 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/lib/linktoken"
+	"github.com/Len4i/auth-service/internal/lib/mailer"
+	"github.com/Len4i/auth-service/internal/lib/secret"
+	"github.com/Len4i/auth-service/internal/services/storage"
+)
+
+const (
+	loginCodeDigits         = 6
+	loginCodeAttempts       = 5
+	loginCodeResendInterval = 30 * time.Second
+
+	loginLinkTokenParam = "token"
+)
+
+var (
+	ErrorPasswordlessNotConfigured = errors.New("passwordless login is not configured")
+	ErrorInvalidLoginCode          = errors.New("invalid login code")
+)
+
+type Mailer interface {
+	Send(ctx context.Context, msg mailer.Message) error
+}
+
+type LoginCodeStorage interface {
+	ReplaceLoginCode(ctx context.Context, code models.LoginCode) (id int64, err error)
+	LoginCode(ctx context.Context, id int64) (code models.LoginCode, err error)
+	UserLoginCode(ctx context.Context, userID int64) (code models.LoginCode, err error)
+	ClaimLoginCodeAttempt(ctx context.Context, id int64, maxAttempts int, now time.Time) error
+	DeleteLoginCode(ctx context.Context, id int64) error
+}
+
+// LoginCodePolicy configures passwordless login by email
+//
+// Magic links are sent along with codes only if both LinkURL and LinkSigner are set.
+type LoginCodePolicy struct {
+	TTL        time.Duration
+	LinkURL    string
+	LinkSigner *linktoken.Signer
+}
+
+// RequestLoginCode emails a one-time login code for appID to user
+//
+// Unknown emails are silently ignored, not to reveal registered users,
+// as are requests repeated too often. A new code invalidates the previous one.
+func (a *Auth) RequestLoginCode(ctx context.Context, email string, appID int) error {
+	const op = "auth.RequestLoginCode"
+	log := a.log.With(slog.String("operation", op))
+
+	if a.mailer == nil {
+		return fmt.Errorf("%s: %w", op, ErrorPasswordlessNotConfigured)
+	}
+
+	if _, err := a.appProvider.App(ctx, appID); err != nil {
+		if errors.Is(err, storage.ErrorAppNotFound) {
+			log.Warn("app not found", slog.Int("appID", appID))
+			return fmt.Errorf("%s: %w", op, ErrorInvalidAppID)
+		}
+		log.Error("failed to get app", "error", err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := a.userProvider.User(ctx, email)
+	if err != nil {
+		if errors.Is(err, storage.ErrorUserNotFound) {
+			log.Warn("user not found", slog.String("email", email))
+			return nil
+		}
+		log.Error("failed to get user", "error", err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now()
+
+	previous, err := a.loginCodes.UserLoginCode(ctx, user.ID)
+	if err == nil && now.Sub(previous.CreatedAt) < loginCodeResendInterval {
+		log.Warn("login code requested too often", slog.Int64("userID", user.ID))
+		return nil
+	}
+	if err != nil && !errors.Is(err, storage.ErrorLoginCodeNotFound) {
+		log.Error("failed to get login code", "error", err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	code, err := secret.NewDigits(loginCodeDigits)
+	if err != nil {
+		log.Error("failed to generate login code", "error", err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	loginCode := models.LoginCode{
+		UserID:    user.ID,
+		AppID:     appID,
+		CodeHash:  secret.Hash(code),
+		ExpiresAt: now.Add(a.loginCodePolicy.TTL),
+		CreatedAt: now,
+	}
+	loginCode.ID, err = a.loginCodes.ReplaceLoginCode(ctx, loginCode)
+	if err != nil {
+		log.Error("failed to save login code", "error", err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	body := fmt.Sprintf("Your login code is %s. It expires in %s.\n", code, a.loginCodePolicy.TTL)
+	if link, ok := a.loginLink(loginCode); ok {
+		body += fmt.Sprintf("\nOr log in with this link:\n%s\n", link)
+	}
+
+	if err := a.mailer.Send(ctx, mailer.Message{To: user.Email, Subject: "Your login code", Body: body}); err != nil {
+		log.Error("failed to send login code", "error", err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("login code sent", slog.Int64("userID", user.ID))
+
+	return nil
+}
+
+// RedeemLoginCode logs user in with the code sent by RequestLoginCode,
+// or with the token of the magic link if linkToken is set
+//
+// Code is single use, expires quickly and allows a limited number of attempts.
+// If user has MFA enabled, returns *MFARequiredError like Login.
+func (a *Auth) RedeemLoginCode(ctx context.Context, email string, code string, linkToken string) (token string, err error) {
+	const op = "auth.RedeemLoginCode"
+	log := a.log.With(slog.String("operation", op))
+
+	if a.mailer == nil {
+		return "", fmt.Errorf("%s: %w", op, ErrorPasswordlessNotConfigured)
+	}
+
+	var loginCode models.LoginCode
+	if linkToken != "" {
+		loginCode, err = a.linkLoginCode(ctx, log, linkToken)
+	} else {
+		loginCode, err = a.checkLoginCode(ctx, log, email, code)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.loginCodes.DeleteLoginCode(ctx, loginCode.ID); err != nil {
+		if errors.Is(err, storage.ErrorLoginCodeNotFound) {
+			// redeemed concurrently
+			return "", fmt.Errorf("%s: %w", op, ErrorInvalidLoginCode)
+		}
+		log.Error("failed to delete login code", "error", err)
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := a.userProvider.UserByID(ctx, loginCode.UserID)
+	if err != nil {
+		log.Error("failed to get user", "error", err)
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	app, err := a.appProvider.App(ctx, loginCode.AppID)
+	if err != nil {
+		log.Error("failed to get app", "error", err)
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	token, err = a.passFirstFactor(ctx, log, user, app, 0, AMREmail)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return token, nil
+}
+
+// checkLoginCode returns pending login code of user matching code
+func (a *Auth) checkLoginCode(ctx context.Context, log *slog.Logger, email string, code string) (models.LoginCode, error) {
+	user, err := a.userProvider.User(ctx, email)
+	if err != nil {
+		if errors.Is(err, storage.ErrorUserNotFound) {
+			log.Warn("user not found", slog.String("email", email))
+			return models.LoginCode{}, ErrorInvalidLoginCode
+		}
+		log.Error("failed to get user", "error", err)
+		return models.LoginCode{}, err
+	}
+
+	loginCode, err := a.loginCodes.UserLoginCode(ctx, user.ID)
+	if err != nil {
+		if errors.Is(err, storage.ErrorLoginCodeNotFound) {
+			log.Warn("login code not found", slog.Int64("userID", user.ID))
+			return models.LoginCode{}, ErrorInvalidLoginCode
+		}
+		log.Error("failed to get login code", "error", err)
+		return models.LoginCode{}, err
+	}
+
+	// the attempt is counted before the comparison, so concurrent guesses
+	// can't exceed the limit
+	if err := a.loginCodes.ClaimLoginCodeAttempt(ctx, loginCode.ID, loginCodeAttempts, time.Now()); err != nil {
+		if errors.Is(err, storage.ErrorLoginCodeNotFound) {
+			log.Warn("login code expired", slog.Int64("userID", user.ID))
+			return models.LoginCode{}, ErrorInvalidLoginCode
+		}
+		log.Error("failed to count login code attempt", "error", err)
+		return models.LoginCode{}, err
+	}
+
+	if subtle.ConstantTimeCompare(secret.Hash(code), loginCode.CodeHash) != 1 {
+		log.Warn("login code mismatch", slog.Int64("userID", user.ID))
+		return models.LoginCode{}, ErrorInvalidLoginCode
+	}
+
+	return loginCode, nil
+}
+
+// linkLoginCode returns pending login code the magic link token points to
+func (a *Auth) linkLoginCode(ctx context.Context, log *slog.Logger, linkToken string) (models.LoginCode, error) {
+	if a.loginCodePolicy.LinkSigner == nil {
+		return models.LoginCode{}, ErrorInvalidLoginCode
+	}
+
+	id, err := a.loginCodePolicy.LinkSigner.Verify(linkToken, time.Now())
+	if err != nil {
+		log.Warn("login link token is not valid", "error", err)
+		return models.LoginCode{}, ErrorInvalidLoginCode
+	}
+
+	loginCode, err := a.loginCodes.LoginCode(ctx, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrorLoginCodeNotFound) {
+			log.Warn("login code not found", slog.Int64("loginCodeID", id))
+			return models.LoginCode{}, ErrorInvalidLoginCode
+		}
+		log.Error("failed to get login code", "error", err)
+		return models.LoginCode{}, err
+	}
+
+	if time.Now().After(loginCode.ExpiresAt) || loginCode.Attempts >= loginCodeAttempts {
+		log.Warn("login code expired", slog.Int64("userID", loginCode.UserID))
+		return models.LoginCode{}, ErrorInvalidLoginCode
+	}
+
+	return loginCode, nil
+}
+
+// loginLink returns magic link for login code if links are configured
+func (a *Auth) loginLink(loginCode models.LoginCode) (string, bool) {
+	if a.loginCodePolicy.LinkURL == "" || a.loginCodePolicy.LinkSigner == nil {
+		return "", false
+	}
+
+	u, err := url.Parse(a.loginCodePolicy.LinkURL)
+	if err != nil {
+		return "", false
+	}
+
+	q := u.Query()
+	q.Set(loginLinkTokenParam, a.loginCodePolicy.LinkSigner.Sign(loginCode.ID, loginCode.ExpiresAt))
+	u.RawQuery = q.Encode()
+
+	return u.String(), true
+}
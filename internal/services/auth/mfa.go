@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/lib/jwt"
+	"github.com/Len4i/auth-service/internal/lib/secret"
+	"github.com/Len4i/auth-service/internal/services/storage"
+)
+
+const (
+	mfaChallengeTTL      = 5 * time.Minute
+	mfaChallengeAttempts = 5
+	mfaChallengeBytes    = 24
+)
+
+// MFAVerifier checks second factor of users who enabled it
+type MFAVerifier interface {
+	Enabled(ctx context.Context, userID int64) (bool, error)
+	Verify(ctx context.Context, userID int64, code string) error
+}
+
+type ChallengeStorage interface {
+	SaveMFAChallenge(ctx context.Context, challenge models.MFAChallenge, handleHash []byte) (id int64, err error)
+	MFAChallenge(ctx context.Context, handleHash []byte) (challenge models.MFAChallenge, err error)
+	MFAChallengeByID(ctx context.Context, id int64) (challenge models.MFAChallenge, err error)
+	ClaimMFAChallengeAttempt(ctx context.Context, id int64, maxAttempts int, now time.Time) error
+	DeleteMFAChallenge(ctx context.Context, id int64) error
+}
+
+const (
+	MFAMethodTOTP     = "totp"
+	MFAMethodWebAuthn = "webauthn"
+)
+
+// MFARequiredError is returned by Login when password is correct, but user
+// has to complete the second factor using the challenge handle, with VerifyMFA
+// or a passkey login
+//
+// Methods lists second factors enabled by user.
+type MFARequiredError struct {
+	Challenge string
+	Methods   []string
+}
+
+func (e *MFARequiredError) Error() string {
+	return ErrorMFARequired.Error()
+}
+
+func (e *MFARequiredError) Unwrap() error {
+	return ErrorMFARequired
+}
+
+// VerifyMFA completes login started by Login with the second factor code
+//
+// Challenge is single use, expires in a few minutes and allows a limited
+// number of attempts.
+func (a *Auth) VerifyMFA(ctx context.Context, challengeHandle string, code string) (token string, err error) {
+	const op = "auth.VerifyMFA"
+	log := a.log.With(slog.String("operation", op))
+
+	challenge, err := a.claimChallenge(ctx, log, challengeHandle)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.mfa.Verify(ctx, challenge.UserID, code); err != nil {
+		log.Warn("second factor verification failed", slog.Int64("userID", challenge.UserID), "error", err)
+		return "", fmt.Errorf("%s: %w", op, ErrorInvalidMFACode)
+	}
+
+	if err := a.challengeStorage.DeleteMFAChallenge(ctx, challenge.ID); err != nil {
+		if errors.Is(err, storage.ErrorChallengeNotFound) {
+			// completed concurrently
+			return "", fmt.Errorf("%s: %w", op, ErrorInvalidChallenge)
+		}
+		log.Error("failed to delete mfa challenge", "error", err)
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	authn := newAuthentication(append(challenge.Methods, AMROneTimePassword)...)
+	token, err = a.issueToken(ctx, log, challenge.UserID, challenge.AppID, challenge.OrgID, authn)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return token, nil
+}
+
+// claimChallenge returns challenge that is not expired and has attempts left,
+// counting an attempt before the caller verifies the second factor
+func (a *Auth) claimChallenge(ctx context.Context, log *slog.Logger, challengeHandle string) (models.MFAChallenge, error) {
+	challenge, err := a.challengeStorage.MFAChallenge(ctx, secret.Hash(challengeHandle))
+	if err != nil {
+		if errors.Is(err, storage.ErrorChallengeNotFound) {
+			log.Warn("mfa challenge not found")
+			return models.MFAChallenge{}, ErrorInvalidChallenge
+		}
+		log.Error("failed to get mfa challenge", "error", err)
+		return models.MFAChallenge{}, err
+	}
+
+	if err := a.challengeStorage.ClaimMFAChallengeAttempt(ctx, challenge.ID, mfaChallengeAttempts, time.Now()); err != nil {
+		if errors.Is(err, storage.ErrorChallengeNotFound) {
+			log.Warn("mfa challenge expired", slog.Int64("userID", challenge.UserID))
+			return models.MFAChallenge{}, ErrorInvalidChallenge
+		}
+		log.Error("failed to count mfa attempt", "error", err)
+		return models.MFAChallenge{}, err
+	}
+
+	return challenge, nil
+}
+
+// issueToken issues token for user who completed login to app
+func (a *Auth) issueToken(
+	ctx context.Context,
+	log *slog.Logger,
+	userID int64,
+	appID int,
+	orgID int64,
+	authn jwt.Authentication,
+) (string, error) {
+	user, err := a.userProvider.UserByID(ctx, userID)
+	if err != nil {
+		log.Error("failed to get user", "error", err)
+		return "", err
+	}
+
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		log.Error("failed to get app", "error", err)
+		return "", err
+	}
+
+	log.Info("user logged in", slog.Int64("userID", user.ID))
+
+	return a.newToken(ctx, log, user, app, orgID, authn, a.tokenTTL)
+}
+
+// startMFA creates challenge for user who passed the first factor
+// with authnMethods, methods are the second factors user can complete it with
+func (a *Auth) startMFA(
+	ctx context.Context,
+	userID int64,
+	appID int,
+	orgID int64,
+	authnMethods []string,
+	methods []string,
+) (*MFARequiredError, error) {
+	handle, err := secret.New(mfaChallengeBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge := models.MFAChallenge{
+		UserID:    userID,
+		AppID:     appID,
+		OrgID:     orgID,
+		Methods:   authnMethods,
+		ExpiresAt: time.Now().Add(mfaChallengeTTL),
+	}
+	if _, err := a.challengeStorage.SaveMFAChallenge(ctx, challenge, secret.Hash(handle)); err != nil {
+		return nil, err
+	}
+
+	return &MFARequiredError{Challenge: handle, Methods: methods}, nil
+}
@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/lib/jwt"
+	"github.com/Len4i/auth-service/internal/services/storage"
+)
+
+var (
+	ErrorInvalidOrg          = errors.New("not a member of the org")
+	ErrorOrgAppNotAllowed    = errors.New("app is not allowed in the org")
+	ErrorOrgMFARequired      = errors.New("org requires mfa")
+	ErrorOrgMethodNotAllowed = errors.New("authentication method is not allowed in the org")
+)
+
+// OrgProvider returns orgs and memberships tokens are scoped to
+type OrgProvider interface {
+	Org(ctx context.Context, id int64) (org models.Org, err error)
+	OrgMember(ctx context.Context, orgID int64, userID int64) (member models.OrgMember, err error)
+}
+
+// SwitchOrg reissues token of claims scoped to the org, orgID 0 drops the org scope
+//
+// Authentication of claims is kept and has to satisfy the org login policy,
+// the new token expires together with the old one.
+func (a *Auth) SwitchOrg(ctx context.Context, claims jwt.Claims, orgID int64) (token string, err error) {
+	const op = "auth.SwitchOrg"
+	log := a.log.With(slog.String("operation", op))
+
+	user, err := a.userProvider.UserByID(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrorUserNotFound) {
+			log.Warn("user not found", slog.Int64("userID", claims.UserID))
+			return "", fmt.Errorf("%s: %w", op, ErrorInvalidUserID)
+		}
+		log.Error("failed to get user", "error", err)
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	app, err := a.appProvider.App(ctx, claims.AppID)
+	if err != nil {
+		log.Error("failed to get app", "error", err)
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	token, err = a.newToken(ctx, log, user, app, orgID, claims.Authentication, time.Until(claims.ExpiresAt))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("org switched", slog.Int64("userID", user.ID), slog.Int64("orgID", orgID))
+
+	return token, nil
+}
+
+// orgMember returns membership of user in the org after checking
+// the org allows logging in to the app with authn
+//
+// Multi-factor requirement is checked only if login is complete,
+// so that first factor could be checked before the second one.
+func (a *Auth) orgMember(
+	ctx context.Context,
+	log *slog.Logger,
+	userID int64,
+	appID int,
+	orgID int64,
+	authn jwt.Authentication,
+	complete bool,
+) (models.OrgMember, error) {
+	org, err := a.orgProvider.Org(ctx, orgID)
+	if err != nil {
+		if errors.Is(err, storage.ErrorOrgNotFound) {
+			log.Warn("org not found", slog.Int64("orgID", orgID))
+			return models.OrgMember{}, ErrorInvalidOrg
+		}
+		log.Error("failed to get org", "error", err)
+		return models.OrgMember{}, err
+	}
+
+	member, err := a.orgProvider.OrgMember(ctx, orgID, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrorOrgMemberNotFound) {
+			log.Warn("user is not a member of the org", slog.Int64("userID", userID), slog.Int64("orgID", orgID))
+			return models.OrgMember{}, ErrorInvalidOrg
+		}
+		log.Error("failed to get org member", "error", err)
+		return models.OrgMember{}, err
+	}
+
+	if len(org.AllowedApps) > 0 && !slices.Contains(org.AllowedApps, appID) {
+		log.Warn("app is not allowed in the org", slog.Int("appID", appID), slog.Int64("orgID", orgID))
+		return models.OrgMember{}, ErrorOrgAppNotAllowed
+	}
+
+	if allowed := org.LoginPolicy.AllowedMethods; len(allowed) > 0 {
+		for _, method := range authn.Methods {
+			if method != AMRMultiFactor && !slices.Contains(allowed, method) {
+				log.Warn("method is not allowed in the org", slog.String("method", method), slog.Int64("orgID", orgID))
+				return models.OrgMember{}, ErrorOrgMethodNotAllowed
+			}
+		}
+	}
+
+	if complete && org.LoginPolicy.RequireMFA && authn.Level != ACRMultiFactor {
+		log.Warn("org requires mfa", slog.Int64("userID", userID), slog.Int64("orgID", orgID))
+		return models.OrgMember{}, ErrorOrgMFARequired
+	}
+
+	return member, nil
+}
@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/services/passkeys"
+	"github.com/Len4i/auth-service/internal/services/storage"
+)
+
+// PasskeyAuthenticator runs WebAuthn login ceremonies
+type PasskeyAuthenticator interface {
+	Enabled(ctx context.Context, userID int64) (bool, error)
+	BeginLogin(ctx context.Context, userID int64, appID int, mfaChallengeID int64) (options []byte, session string, err error)
+	FinishLogin(ctx context.Context, session string, response []byte) (models.WebAuthnSession, error)
+}
+
+// BeginPasskeyLogin starts login with a passkey
+//
+// With mfaChallenge the passkey completes the second factor of Login.
+// Otherwise it is a passwordless login to appID, limited to credentials
+// of the user with given email, or any discoverable credential if email is empty.
+func (a *Auth) BeginPasskeyLogin(
+	ctx context.Context,
+	email string,
+	appID int,
+	mfaChallenge string,
+) (options []byte, session string, err error) {
+	const op = "auth.BeginPasskeyLogin"
+	log := a.log.With(slog.String("operation", op))
+
+	if mfaChallenge != "" {
+		// every ceremony counts as an attempt, as its failure is not reported back to the challenge
+		challenge, err := a.claimChallenge(ctx, log, mfaChallenge)
+		if err != nil {
+			return nil, "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		options, session, err = a.passkeys.BeginLogin(ctx, challenge.UserID, challenge.AppID, challenge.ID)
+		if err != nil {
+			return nil, "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		return options, session, nil
+	}
+
+	if _, err := a.appProvider.App(ctx, appID); err != nil {
+		if errors.Is(err, storage.ErrorAppNotFound) {
+			log.Warn("app not found", slog.Int("appID", appID))
+			return nil, "", fmt.Errorf("%s: %w", op, ErrorInvalidAppID)
+		}
+		log.Error("failed to get app", "error", err)
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	var userID int64
+	if email != "" {
+		user, err := a.userProvider.User(ctx, email)
+		if err != nil {
+			if errors.Is(err, storage.ErrorUserNotFound) {
+				log.Warn("user not found", slog.String("email", email))
+				return nil, "", fmt.Errorf("%s: %w", op, ErrorInvalidCredentials)
+			}
+			log.Error("failed to get user", "error", err)
+			return nil, "", fmt.Errorf("%s: %w", op, err)
+		}
+		userID = user.ID
+	}
+
+	options, session, err = a.passkeys.BeginLogin(ctx, userID, appID, 0)
+	if err != nil {
+		if errors.Is(err, passkeys.ErrorNoCredentials) {
+			log.Warn("user has no passkeys", slog.Int64("userID", userID))
+			return nil, "", fmt.Errorf("%s: %w", op, ErrorInvalidCredentials)
+		}
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return options, session, nil
+}
+
+// FinishPasskeyLogin verifies passkey assertion and returns token
+func (a *Auth) FinishPasskeyLogin(ctx context.Context, session string, response []byte) (token string, err error) {
+	const op = "auth.FinishPasskeyLogin"
+	log := a.log.With(slog.String("operation", op))
+
+	login, err := a.passkeys.FinishLogin(ctx, session, response)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	// user verification makes passwordless passkey login multi-factor
+	authn := newAuthentication(AMRHardwareKey, AMRMultiFactor)
+	var orgID int64
+
+	if login.MFAChallengeID != 0 {
+		challenge, err := a.challengeStorage.MFAChallengeByID(ctx, login.MFAChallengeID)
+		if err != nil {
+			if errors.Is(err, storage.ErrorChallengeNotFound) {
+				return "", fmt.Errorf("%s: %w", op, ErrorInvalidChallenge)
+			}
+			log.Error("failed to get mfa challenge", "error", err)
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+		authn = newAuthentication(append(challenge.Methods, AMRHardwareKey)...)
+		orgID = challenge.OrgID
+
+		if err := a.challengeStorage.DeleteMFAChallenge(ctx, login.MFAChallengeID); err != nil {
+			if errors.Is(err, storage.ErrorChallengeNotFound) {
+				// completed concurrently
+				return "", fmt.Errorf("%s: %w", op, ErrorInvalidChallenge)
+			}
+			log.Error("failed to delete mfa challenge", "error", err)
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	token, err = a.issueToken(ctx, log, login.UserID, login.AppID, orgID, authn)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return token, nil
+}
+
+// mfaMethods returns second factors enabled by user
+func (a *Auth) mfaMethods(ctx context.Context, userID int64) ([]string, error) {
+	var methods []string
+
+	totpEnabled, err := a.mfa.Enabled(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if totpEnabled {
+		methods = append(methods, MFAMethodTOTP)
+	}
+
+	passkeysEnabled, err := a.passkeys.Enabled(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if passkeysEnabled {
+		methods = append(methods, MFAMethodWebAuthn)
+	}
+
+	return methods, nil
+}
@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/lib/secret"
+	"github.com/Len4i/auth-service/internal/services/storage"
+)
+
+const (
+	RegistrationOpen       = "open"
+	RegistrationClosed     = "closed"
+	RegistrationInviteOnly = "invite_only"
+)
+
+// RegistrationPolicy controls who can register
+//
+// Mode is one of RegistrationOpen, RegistrationClosed or RegistrationInviteOnly,
+// an app may override it. Unknown mode is treated as closed due to security.
+// Email domains are matched case-insensitively, denied domains take precedence,
+// non-empty AllowedDomains rejects any other domain.
+type RegistrationPolicy struct {
+	Mode           string
+	AllowedDomains []string
+	DeniedDomains  []string
+}
+
+type InviteProvider interface {
+	Invite(ctx context.Context, codeHash []byte) (invite models.Invite, err error)
+}
+
+// checkRegistration checks registration mode and email domain
+//
+// Returns invite to be used if registration is invite-only
+func (a *Auth) checkRegistration(
+	ctx context.Context,
+	log *slog.Logger,
+	email string,
+	appID int,
+	inviteCode string,
+) (*models.Invite, error) {
+	mode := a.registration.Mode
+	if appID != 0 {
+		app, err := a.appProvider.App(ctx, appID)
+		if err != nil {
+			if errors.Is(err, storage.ErrorAppNotFound) {
+				log.Warn("app not found", slog.Int("appID", appID))
+				return nil, ErrorInvalidAppID
+			}
+			return nil, err
+		}
+		if app.RegistrationMode != "" {
+			mode = app.RegistrationMode
+		}
+	}
+
+	switch mode {
+	case RegistrationOpen, RegistrationInviteOnly:
+	default:
+		log.Warn("registration is closed", slog.String("mode", mode), slog.Int("appID", appID))
+		return nil, ErrorRegistrationClosed
+	}
+
+	if !a.registration.domainAllowed(email) {
+		log.Warn("email domain is not allowed", slog.String("email", email))
+		return nil, ErrorEmailDomainNotAllowed
+	}
+
+	if mode == RegistrationOpen {
+		return nil, nil
+	}
+
+	if inviteCode == "" {
+		return nil, ErrorInviteRequired
+	}
+
+	invite, err := a.inviteProvider.Invite(ctx, secret.Hash(inviteCode))
+	if err != nil {
+		if errors.Is(err, storage.ErrorInviteNotFound) {
+			log.Warn("invite not found")
+			return nil, ErrorInvalidInvite
+		}
+		return nil, err
+	}
+
+	if !invite.Active(time.Now()) ||
+		(invite.AppID != 0 && invite.AppID != appID) ||
+		(invite.Email != "" && !strings.EqualFold(invite.Email, email)) {
+		log.Warn("invite is not valid", slog.Int64("inviteID", invite.ID))
+		return nil, ErrorInvalidInvite
+	}
+
+	return &invite, nil
+}
+
+func (p RegistrationPolicy) domainAllowed(email string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[at+1:])
+
+	for _, denied := range p.DeniedDomains {
+		if strings.EqualFold(domain, denied) {
+			return false
+		}
+	}
+
+	if len(p.AllowedDomains) == 0 {
+		return true
+	}
+	for _, allowed := range p.AllowedDomains {
+		if strings.EqualFold(domain, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// saveUser saves user, using the invite if registration required one
+func (a *Auth) saveUser(ctx context.Context, email string, passHash []byte, pepperKeyID string, invite *models.Invite) (int64, error) {
+	if invite == nil {
+		return a.userSaver.SaveUser(ctx, email, passHash, pepperKeyID)
+	}
+
+	userID, err := a.userSaver.SaveInvitedUser(ctx, email, passHash, pepperKeyID, invite.ID, time.Now())
+	if err != nil {
+		if errors.Is(err, storage.ErrorInviteNotFound) {
+			// invite was used up or revoked concurrently
+			return 0, fmt.Errorf("%w: %w", ErrorInvalidInvite, err)
+		}
+		return 0, err
+	}
+	return userID, nil
+}
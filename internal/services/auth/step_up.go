@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/Len4i/auth-service/internal/lib/jwt"
+	"github.com/Len4i/auth-service/internal/services/mfa"
+)
+
+// Authentication method references put in the amr claim, see RFC 8176
+const (
+	AMRPassword        = "pwd"
+	AMROneTimePassword = "otp"
+	AMRHardwareKey     = "hwk"
+	AMRMultiFactor     = "mfa"
+	// AMREmail is a login code or magic link sent by email, not registered in RFC 8176
+	AMREmail = "email"
+	// AMRFederated is a login at an upstream identity provider, not registered in RFC 8176
+	AMRFederated = "fed"
+)
+
+// Authentication context classes put in the acr claim, named after
+// NIST SP 800-63B authenticator assurance levels
+const (
+	ACRSingleFactor = "aal1"
+	ACRMultiFactor  = "aal2"
+)
+
+var (
+	ErrorMFANotEnabled      = errors.New("second factor is not enabled")
+	ErrorTooManyMFAAttempts = errors.New("too many mfa attempts")
+)
+
+// StepUp re-verifies the second factor of the user authenticated by claims
+// and returns a multi-factor token for the same app with a fresh auth_time
+//
+// Code is a TOTP code or a recovery code. If code is empty, returns
+// *MFARequiredError with a challenge to be completed by VerifyMFA or
+// FinishPasskeyLogin instead. Codes take the attempts of the user the mfa
+// service allows, ErrorTooManyMFAAttempts is returned once they are used up.
+func (a *Auth) StepUp(ctx context.Context, claims jwt.Claims, code string) (token string, err error) {
+	const op = "auth.StepUp"
+	log := a.log.With(slog.String("operation", op))
+
+	methods, err := a.mfaMethods(ctx, claims.UserID)
+	if err != nil {
+		log.Error("failed to check mfa", "error", err)
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	if len(methods) == 0 {
+		log.Warn("step-up without second factor", slog.Int64("userID", claims.UserID))
+		return "", fmt.Errorf("%s: %w", op, ErrorMFANotEnabled)
+	}
+
+	if code == "" {
+		mfaErr, err := a.startMFA(ctx, claims.UserID, claims.AppID, claims.OrgID, claims.Methods, methods)
+		if err != nil {
+			log.Error("failed to start mfa", "error", err)
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+		return "", fmt.Errorf("%s: %w", op, mfaErr)
+	}
+
+	if err := a.mfa.Verify(ctx, claims.UserID, code); err != nil {
+		log.Warn("second factor verification failed", slog.Int64("userID", claims.UserID), "error", err)
+		if errors.Is(err, mfa.ErrorTooManyAttempts) {
+			return "", fmt.Errorf("%s: %w", op, ErrorTooManyMFAAttempts)
+		}
+		return "", fmt.Errorf("%s: %w", op, ErrorInvalidMFACode)
+	}
+
+	authn := newAuthentication(append(slices.Clone(claims.Methods), AMROneTimePassword)...)
+	token, err = a.issueToken(ctx, log, claims.UserID, claims.AppID, claims.OrgID, authn)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return token, nil
+}
+
+// newAuthentication describes login completed now with given methods
+//
+// Login combining several methods is multi-factor.
+func newAuthentication(methods ...string) jwt.Authentication {
+	amr := make([]string, 0, len(methods)+1)
+	for _, method := range methods {
+		if !slices.Contains(amr, method) {
+			amr = append(amr, method)
+		}
+	}
+
+	level := ACRSingleFactor
+	if len(amr) > 1 {
+		level = ACRMultiFactor
+		if !slices.Contains(amr, AMRMultiFactor) {
+			amr = append(amr, AMRMultiFactor)
+		}
+	}
+
+	return jwt.Authentication{
+		Methods: amr,
+		Level:   level,
+		Time:    time.Now(),
+	}
+}
@@ -0,0 +1,335 @@
+package clients
+
+import (
+	"context"
+	"crypto"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/lib/jwt"
+	"github.com/Len4i/auth-service/internal/lib/secret"
+	"github.com/Len4i/auth-service/internal/services/storage"
+)
+
+var (
+	ErrorInvalidName        = errors.New("invalid client name")
+	ErrorInvalidPermission  = errors.New("invalid permission")
+	ErrorInvalidPublicKey   = errors.New("invalid public key")
+	ErrorInvalidAppID       = errors.New("invalid app id")
+	ErrorClientNotFound     = errors.New("client not found")
+	ErrorInvalidCredentials = errors.New("invalid client credentials")
+	ErrorScopeNotAllowed    = errors.New("scope is not allowed")
+)
+
+const (
+	clientIDBytes = 9
+	secretBytes   = 32
+	maxNameLen    = 64
+	// maxAssertionTTL bounds how far in the future assertions may expire,
+	// used assertion ids are kept until then
+	maxAssertionTTL = 10 * time.Minute
+)
+
+var permissionRe = regexp.MustCompile(`^([a-z0-9][a-z0-9_.:-]{0,127}|\*)$`)
+
+type ClientStorage interface {
+	SaveClient(ctx context.Context, client models.Client, secretHash []byte) (id int64, err error)
+	Client(ctx context.Context, clientID string) (client models.Client, secretHash []byte, err error)
+	Clients(ctx context.Context, appID int) ([]models.Client, error)
+	UpdateClientSecret(ctx context.Context, clientID string, secretHash []byte) error
+	DeleteClient(ctx context.Context, clientID string) error
+	UseClientAssertion(ctx context.Context, clientID string, jti string, expiresAt time.Time, now time.Time) error
+}
+
+type AppProvider interface {
+	App(ctx context.Context, appID int) (app models.App, err error)
+}
+
+// Credentials authenticate a client, either with its secret or with
+// a signed JWT assertion
+type Credentials struct {
+	ClientID  string
+	Secret    string
+	Assertion string
+}
+
+type Clients struct {
+	log         *slog.Logger
+	clients     ClientStorage
+	appProvider AppProvider
+	issuer      string
+	tokenTTL    time.Duration
+	tokenKey    []byte
+}
+
+// NewClients creates new client credentials service
+//
+// issuer is the audience client assertions must be addressed to.
+func NewClients(
+	log *slog.Logger,
+	clients ClientStorage,
+	appProvider AppProvider,
+	issuer string,
+	tokenTTL time.Duration,
+	tokenKey []byte,
+) *Clients {
+	return &Clients{
+		log:         log,
+		clients:     clients,
+		appProvider: appProvider,
+		issuer:      issuer,
+		tokenTTL:    tokenTTL,
+		tokenKey:    tokenKey,
+	}
+}
+
+// CreateClient creates client of the app and returns it with its secret
+//
+// The secret is not stored and can't be retrieved later.
+func (c *Clients) CreateClient(
+	ctx context.Context,
+	appID int,
+	name string,
+	permissions []string,
+	publicKey string,
+) (models.Client, string, error) {
+	const op = "clients.CreateClient"
+	log := c.log.With(slog.String("operation", op))
+
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxNameLen {
+		return models.Client{}, "", fmt.Errorf("%s: %w", op, ErrorInvalidName)
+	}
+	for _, permission := range permissions {
+		if !permissionRe.MatchString(permission) {
+			return models.Client{}, "", fmt.Errorf("%s: %w", op, ErrorInvalidPermission)
+		}
+	}
+	if publicKey != "" {
+		if _, err := jwt.ParsePublicKey(publicKey); err != nil {
+			return models.Client{}, "", fmt.Errorf("%s: %w", op, ErrorInvalidPublicKey)
+		}
+	}
+
+	if _, err := c.appProvider.App(ctx, appID); err != nil {
+		if errors.Is(err, storage.ErrorAppNotFound) {
+			log.Warn("app not found", slog.Int("appID", appID))
+			return models.Client{}, "", fmt.Errorf("%s: %w", op, ErrorInvalidAppID)
+		}
+		log.Error("failed to get app", "error", err)
+		return models.Client{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	clientID, err := secret.New(clientIDBytes)
+	if err != nil {
+		log.Error("failed to generate client id", "error", err)
+		return models.Client{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+	clientSecret, err := secret.New(secretBytes)
+	if err != nil {
+		log.Error("failed to generate client secret", "error", err)
+		return models.Client{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	permissions = slices.Clone(permissions)
+	slices.Sort(permissions)
+
+	client := models.Client{
+		ClientID:    "cl_" + clientID,
+		AppID:       appID,
+		Name:        name,
+		PublicKey:   publicKey,
+		Permissions: slices.Compact(permissions),
+		CreatedAt:   time.Now(),
+	}
+
+	client.ID, err = c.clients.SaveClient(ctx, client, secret.Hash(clientSecret))
+	if err != nil {
+		log.Error("failed to save client", "error", err)
+		return models.Client{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("client created", slog.String("clientID", client.ClientID), slog.Int("appID", appID))
+
+	return client, clientSecret, nil
+}
+
+// ListClients returns clients of the app
+func (c *Clients) ListClients(ctx context.Context, appID int) ([]models.Client, error) {
+	const op = "clients.ListClients"
+	log := c.log.With(slog.String("operation", op))
+
+	clients, err := c.clients.Clients(ctx, appID)
+	if err != nil {
+		log.Error("failed to get clients", "error", err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return clients, nil
+}
+
+// RotateClientSecret replaces secret of the client and returns the new one
+func (c *Clients) RotateClientSecret(ctx context.Context, clientID string) (string, error) {
+	const op = "clients.RotateClientSecret"
+	log := c.log.With(slog.String("operation", op))
+
+	clientSecret, err := secret.New(secretBytes)
+	if err != nil {
+		log.Error("failed to generate client secret", "error", err)
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := c.clients.UpdateClientSecret(ctx, clientID, secret.Hash(clientSecret)); err != nil {
+		if errors.Is(err, storage.ErrorClientNotFound) {
+			log.Warn("client not found", slog.String("clientID", clientID))
+			return "", fmt.Errorf("%s: %w", op, ErrorClientNotFound)
+		}
+		log.Error("failed to update client secret", "error", err)
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("client secret rotated", slog.String("clientID", clientID))
+
+	return clientSecret, nil
+}
+
+// DeleteClient removes client, tokens already issued to it stay valid until they expire
+func (c *Clients) DeleteClient(ctx context.Context, clientID string) error {
+	const op = "clients.DeleteClient"
+	log := c.log.With(slog.String("operation", op))
+
+	if err := c.clients.DeleteClient(ctx, clientID); err != nil {
+		if errors.Is(err, storage.ErrorClientNotFound) {
+			log.Warn("client not found", slog.String("clientID", clientID))
+			return fmt.Errorf("%s: %w", op, ErrorClientNotFound)
+		}
+		log.Error("failed to delete client", "error", err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("client deleted", slog.String("clientID", clientID))
+
+	return nil
+}
+
+// IssueToken authenticates client and returns token of its app
+// with the subject set to the client
+//
+// Empty scopes grant all permissions of the client, otherwise they must be
+// a subset of them. Returns the granted scopes and the token lifetime.
+func (c *Clients) IssueToken(
+	ctx context.Context,
+	creds Credentials,
+	scopes []string,
+) (token string, granted []string, ttl time.Duration, err error) {
+	const op = "clients.IssueToken"
+	log := c.log.With(slog.String("operation", op))
+
+	client, err := c.authenticate(ctx, log, creds)
+	if err != nil {
+		return "", nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	granted = client.Permissions
+	if len(scopes) > 0 {
+		for _, scope := range scopes {
+			if !slices.Contains(client.Permissions, scope) && !slices.Contains(client.Permissions, "*") {
+				log.Warn("scope is not allowed", slog.String("clientID", client.ClientID), slog.String("scope", scope))
+				return "", nil, 0, fmt.Errorf("%s: %w", op, ErrorScopeNotAllowed)
+			}
+		}
+		granted = scopes
+	}
+
+	app, err := c.appProvider.App(ctx, client.AppID)
+	if err != nil {
+		log.Error("failed to get app", "error", err)
+		return "", nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	token, err = jwt.NewClientToken(client.ClientID, app, granted, c.tokenTTL, c.tokenKey)
+	if err != nil {
+		log.Error("failed to generate token", "error", err)
+		return "", nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("client token issued", slog.String("clientID", client.ClientID))
+
+	return token, granted, c.tokenTTL, nil
+}
+
+func (c *Clients) authenticate(ctx context.Context, log *slog.Logger, creds Credentials) (models.Client, error) {
+	if creds.Assertion != "" {
+		return c.authenticateAssertion(ctx, log, creds)
+	}
+
+	client, secretHash, err := c.clients.Client(ctx, creds.ClientID)
+	if err != nil {
+		if errors.Is(err, storage.ErrorClientNotFound) {
+			log.Warn("client not found", slog.String("clientID", creds.ClientID))
+			return models.Client{}, ErrorInvalidCredentials
+		}
+		log.Error("failed to get client", "error", err)
+		return models.Client{}, err
+	}
+
+	if subtle.ConstantTimeCompare(secretHash, secret.Hash(creds.Secret)) != 1 {
+		log.Warn("client secret doesn't match", slog.String("clientID", creds.ClientID))
+		return models.Client{}, ErrorInvalidCredentials
+	}
+
+	return client, nil
+}
+
+// authenticateAssertion verifies assertion signed by the key of the client
+// and makes sure it's used once
+func (c *Clients) authenticateAssertion(ctx context.Context, log *slog.Logger, creds Credentials) (models.Client, error) {
+	var client models.Client
+	var lookupErr error
+	assertion, err := jwt.ParseAssertion(creds.Assertion, c.issuer, func(clientID string) (crypto.PublicKey, error) {
+		client, _, lookupErr = c.clients.Client(ctx, clientID)
+		if lookupErr != nil {
+			return nil, lookupErr
+		}
+		if client.PublicKey == "" {
+			return nil, ErrorInvalidCredentials
+		}
+		return jwt.ParsePublicKey(client.PublicKey)
+	})
+	if lookupErr != nil && !errors.Is(lookupErr, storage.ErrorClientNotFound) {
+		log.Error("failed to get client", "error", lookupErr)
+		return models.Client{}, lookupErr
+	}
+	if err != nil {
+		log.Warn("invalid client assertion", "error", err)
+		return models.Client{}, ErrorInvalidCredentials
+	}
+	if creds.ClientID != "" && creds.ClientID != assertion.ClientID {
+		log.Warn("client assertion of another client", slog.String("clientID", creds.ClientID))
+		return models.Client{}, ErrorInvalidCredentials
+	}
+
+	now := time.Now()
+	if assertion.ExpiresAt.After(now.Add(maxAssertionTTL)) {
+		log.Warn("client assertion expires too late", slog.String("clientID", client.ClientID))
+		return models.Client{}, ErrorInvalidCredentials
+	}
+
+	if err := c.clients.UseClientAssertion(ctx, client.ClientID, assertion.ID, assertion.ExpiresAt, now); err != nil {
+		if errors.Is(err, storage.ErrorAssertionReplayed) {
+			log.Warn("client assertion replayed", slog.String("clientID", client.ClientID))
+			return models.Client{}, ErrorInvalidCredentials
+		}
+		log.Error("failed to record client assertion", "error", err)
+		return models.Client{}, err
+	}
+
+	return client, nil
+}
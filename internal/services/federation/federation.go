@@ -0,0 +1,579 @@
+package federation
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/lib/secret"
+	"github.com/Len4i/auth-service/internal/services/storage"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrorConnectorNotFound  = errors.New("connector not found")
+	ErrorProviderFailed     = errors.New("upstream identity provider failed")
+	ErrorInvalidState       = errors.New("invalid federated login state")
+	ErrorUpstreamDenied     = errors.New("upstream login failed")
+	ErrorAccountNotFound    = errors.New("no account for the identity")
+	ErrorLinkRequired       = errors.New("identity has to be linked")
+	ErrorInvalidLink        = errors.New("invalid link token")
+	ErrorInvalidCredentials = errors.New("invalid credentials")
+	ErrorIdentityLinked     = errors.New("identity is already linked")
+)
+
+const (
+	stateBytes   = 32
+	linkBytes    = 32
+	linkTTL      = 10 * time.Minute
+	linkAttempts = 5
+	// providerTimeout bounds requests to upstream providers
+	providerTimeout = 10 * time.Second
+)
+
+// defaultScopes are requested if the connector doesn't configure scopes
+var defaultScopes = []string{oidc.ScopeOpenID, "email", "profile"}
+
+// Connector is an upstream OpenID Connect provider users can sign in with
+//
+// ID names the connector in URLs and identities, Name is shown on the login
+// page. With LinkByEmail, the first login links the identity to the user
+// with the same email if the provider verified it, otherwise user has to
+// prove the account with its password. With Provision, unknown users with
+// a verified email are registered on their first login.
+type Connector struct {
+	ID           string
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	LinkByEmail  bool
+	Provision    bool
+}
+
+type IdentityStorage interface {
+	FederatedIdentity(ctx context.Context, connector string, subject string) (models.FederatedIdentity, error)
+	SaveFederatedIdentity(ctx context.Context, identity models.FederatedIdentity) (id int64, err error)
+}
+
+type LoginStorage interface {
+	SaveFederatedLogin(ctx context.Context, login models.FederatedLogin, stateHash []byte, now time.Time) (id int64, err error)
+	UseFederatedLogin(ctx context.Context, stateHash []byte) (models.FederatedLogin, error)
+}
+
+type LinkStorage interface {
+	SaveFederatedLink(ctx context.Context, link models.FederatedLink, tokenHash []byte, now time.Time) (id int64, err error)
+	FederatedLink(ctx context.Context, tokenHash []byte) (models.FederatedLink, error)
+	ClaimFederatedLinkAttempt(ctx context.Context, id int64, maxAttempts int, now time.Time) error
+	DeleteFederatedLink(ctx context.Context, id int64) error
+}
+
+type UserProvider interface {
+	User(ctx context.Context, email string) (models.User, error)
+}
+
+// Authenticator logs in and registers users of federated identities
+type Authenticator interface {
+	LoginFederated(ctx context.Context, userID int64, appID int) (token string, err error)
+	RegisterFederated(
+		ctx context.Context,
+		user models.User,
+		identity models.FederatedIdentity,
+		appID int,
+	) (userID int64, err error)
+	VerifyPassword(ctx context.Context, userID int64, password string) error
+}
+
+// LinkRequiredError is returned by Finish when the identity has the email
+// of an existing user, but can't be linked to it automatically
+//
+// The user has to prove the account with Link and the Token.
+type LinkRequiredError struct {
+	Token string
+	Email string
+}
+
+func (e *LinkRequiredError) Error() string {
+	return ErrorLinkRequired.Error()
+}
+
+func (e *LinkRequiredError) Unwrap() error {
+	return ErrorLinkRequired
+}
+
+type Federation struct {
+	log          *slog.Logger
+	identities   IdentityStorage
+	logins       LoginStorage
+	links        LinkStorage
+	userProvider UserProvider
+	auth         Authenticator
+	connectors   []Connector
+	callbackURL  string
+	loginTTL     time.Duration
+	client       *http.Client
+
+	mu        sync.Mutex
+	providers map[string]*oidc.Provider
+}
+
+// NewFederation creates new service of logins through upstream providers
+//
+// Providers redirect users back to callbackURL, they have loginTTL to sign in
+// there. Providers are discovered on first use, so an unavailable one
+// doesn't prevent the service from starting.
+func NewFederation(
+	log *slog.Logger,
+	identities IdentityStorage,
+	logins LoginStorage,
+	links LinkStorage,
+	userProvider UserProvider,
+	auth Authenticator,
+	connectors []Connector,
+	callbackURL string,
+	loginTTL time.Duration,
+) *Federation {
+	return &Federation{
+		log:          log,
+		identities:   identities,
+		logins:       logins,
+		links:        links,
+		userProvider: userProvider,
+		auth:         auth,
+		connectors:   connectors,
+		callbackURL:  callbackURL,
+		loginTTL:     loginTTL,
+		client:       &http.Client{Timeout: providerTimeout},
+		providers:    map[string]*oidc.Provider{},
+	}
+}
+
+// Connectors returns configured connectors
+func (f *Federation) Connectors() []Connector {
+	return f.connectors
+}
+
+// Start begins login to the app at the upstream provider of the connector
+// and returns the URL to redirect user to
+//
+// request is returned by Finish once the provider redirects user back.
+func (f *Federation) Start(ctx context.Context, connectorID string, appID int, request string) (authURL string, err error) {
+	const op = "federation.Start"
+	log := f.log.With(slog.String("operation", op))
+
+	connector, err := f.connector(log, connectorID)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	config, _, err := f.oauthConfig(log, connector)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	state, err := secret.New(stateBytes)
+	if err != nil {
+		log.Error("failed to generate state", "error", err)
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	nonce, err := secret.New(stateBytes)
+	if err != nil {
+		log.Error("failed to generate nonce", "error", err)
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now()
+	login := models.FederatedLogin{
+		Connector:    connector.ID,
+		AppID:        appID,
+		Request:      request,
+		Nonce:        nonce,
+		CodeVerifier: oauth2.GenerateVerifier(),
+		ExpiresAt:    now.Add(f.loginTTL),
+	}
+	if _, err := f.logins.SaveFederatedLogin(ctx, login, secret.Hash(state), now); err != nil {
+		log.Error("failed to save login", "error", err)
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("federated login started", slog.String("connector", connector.ID), slog.Int("appID", appID))
+
+	return config.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(login.CodeVerifier)), nil
+}
+
+// Finish completes login the provider redirected back with state and code
+// and logs user in to the app
+//
+// The identity logs in its linked user. Otherwise it's linked to the user
+// with the same email or a new user is provisioned, as the connector allows,
+// *LinkRequiredError asks user to link it explicitly. Empty code means the
+// provider didn't authenticate user. Like Login, returns *MFARequiredError
+// if user has MFA enabled. request passed to Start is returned unless
+// the state is not valid.
+func (f *Federation) Finish(ctx context.Context, state string, code string) (request string, token string, err error) {
+	const op = "federation.Finish"
+	log := f.log.With(slog.String("operation", op))
+
+	login, err := f.logins.UseFederatedLogin(ctx, secret.Hash(state))
+	if err != nil {
+		if errors.Is(err, storage.ErrorFederatedLoginNotFound) {
+			log.Warn("federated login not found")
+			return "", "", fmt.Errorf("%s: %w", op, ErrorInvalidState)
+		}
+		log.Error("failed to get login", "error", err)
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+	if time.Now().After(login.ExpiresAt) {
+		log.Warn("federated login expired", slog.String("connector", login.Connector))
+		return "", "", fmt.Errorf("%s: %w", op, ErrorInvalidState)
+	}
+	request = login.Request
+
+	if code == "" {
+		log.Warn("upstream login failed", slog.String("connector", login.Connector))
+		return request, "", fmt.Errorf("%s: %w", op, ErrorUpstreamDenied)
+	}
+
+	connector, err := f.connector(log, login.Connector)
+	if err != nil {
+		return request, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	claims, err := f.exchange(ctx, log, connector, login, code)
+	if err != nil {
+		return request, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	userID, err := f.identityUser(ctx, log, connector, login, claims)
+	if err != nil {
+		return request, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	token, err = f.auth.LoginFederated(ctx, userID, login.AppID)
+	if err != nil {
+		return request, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return request, token, nil
+}
+
+// Link links identity waiting for it to the account of user who proved it
+// with password and logs user in to the app
+//
+// A link allows a few attempts. request passed to Start is returned
+// unless the link token is not valid.
+func (f *Federation) Link(ctx context.Context, linkToken string, password string) (request string, token string, err error) {
+	const op = "federation.Link"
+	log := f.log.With(slog.String("operation", op))
+
+	link, err := f.links.FederatedLink(ctx, secret.Hash(linkToken))
+	if err != nil {
+		if errors.Is(err, storage.ErrorFederatedLinkNotFound) {
+			log.Warn("link not found")
+			return "", "", fmt.Errorf("%s: %w", op, ErrorInvalidLink)
+		}
+		log.Error("failed to get link", "error", err)
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+	// the attempt is counted before the password is verified, so concurrent
+	// guesses can't exceed the limit
+	if err := f.links.ClaimFederatedLinkAttempt(ctx, link.ID, linkAttempts, time.Now()); err != nil {
+		if errors.Is(err, storage.ErrorFederatedLinkNotFound) {
+			log.Warn("link expired", slog.Int64("userID", link.UserID))
+			return "", "", fmt.Errorf("%s: %w", op, ErrorInvalidLink)
+		}
+		log.Error("failed to count link attempt", "error", err)
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+	request = link.Request
+
+	if err := f.auth.VerifyPassword(ctx, link.UserID, password); err != nil {
+		log.Warn("link password mismatch", slog.Int64("userID", link.UserID))
+		return request, "", fmt.Errorf("%s: %w", op, ErrorInvalidCredentials)
+	}
+
+	if err := f.links.DeleteFederatedLink(ctx, link.ID); err != nil {
+		if errors.Is(err, storage.ErrorFederatedLinkNotFound) {
+			// linked concurrently
+			return "", "", fmt.Errorf("%s: %w", op, ErrorInvalidLink)
+		}
+		log.Error("failed to delete link", "error", err)
+		return request, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	identity := models.FederatedIdentity{
+		UserID:    link.UserID,
+		Connector: link.Connector,
+		Subject:   link.Subject,
+		Email:     link.Email,
+		CreatedAt: time.Now(),
+	}
+	if err := f.saveIdentity(ctx, log, identity); err != nil {
+		return request, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	token, err = f.auth.LoginFederated(ctx, link.UserID, link.AppID)
+	if err != nil {
+		return request, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return request, token, nil
+}
+
+// upstreamClaims are the claims of upstream ID tokens used by the service
+type upstreamClaims struct {
+	Subject       string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified any    `json:"email_verified"`
+	Name          string `json:"name"`
+	GivenName     string `json:"given_name"`
+	FamilyName    string `json:"family_name"`
+	Picture       string `json:"picture"`
+	Locale        string `json:"locale"`
+}
+
+// emailVerified reports whether the provider verified the email,
+// some providers send the claim as a string
+func (c upstreamClaims) emailVerified() bool {
+	switch verified := c.EmailVerified.(type) {
+	case bool:
+		return verified
+	case string:
+		return verified == "true"
+	}
+	return false
+}
+
+// exchange redeems code at the provider and returns claims of its verified ID token
+func (f *Federation) exchange(
+	ctx context.Context,
+	log *slog.Logger,
+	connector Connector,
+	login models.FederatedLogin,
+	code string,
+) (upstreamClaims, error) {
+	config, provider, err := f.oauthConfig(log, connector)
+	if err != nil {
+		return upstreamClaims{}, err
+	}
+
+	ctx = oidc.ClientContext(ctx, f.client)
+	oauthToken, err := config.Exchange(ctx, code, oauth2.VerifierOption(login.CodeVerifier))
+	if err != nil {
+		log.Warn("failed to exchange code", slog.String("connector", connector.ID), "error", err)
+		return upstreamClaims{}, ErrorUpstreamDenied
+	}
+
+	rawIDToken, ok := oauthToken.Extra("id_token").(string)
+	if !ok {
+		log.Warn("no id token", slog.String("connector", connector.ID))
+		return upstreamClaims{}, ErrorUpstreamDenied
+	}
+
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: connector.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		log.Warn("invalid id token", slog.String("connector", connector.ID), "error", err)
+		return upstreamClaims{}, ErrorUpstreamDenied
+	}
+	if subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(login.Nonce)) != 1 {
+		log.Warn("id token nonce mismatch", slog.String("connector", connector.ID))
+		return upstreamClaims{}, ErrorUpstreamDenied
+	}
+
+	var claims upstreamClaims
+	if err := idToken.Claims(&claims); err != nil {
+		log.Warn("invalid id token claims", slog.String("connector", connector.ID), "error", err)
+		return upstreamClaims{}, ErrorUpstreamDenied
+	}
+
+	return claims, nil
+}
+
+// identityUser returns user the identity of claims is linked to,
+// linking or provisioning one if the connector allows
+func (f *Federation) identityUser(
+	ctx context.Context,
+	log *slog.Logger,
+	connector Connector,
+	login models.FederatedLogin,
+	claims upstreamClaims,
+) (int64, error) {
+	identity, err := f.identities.FederatedIdentity(ctx, connector.ID, claims.Subject)
+	if err == nil {
+		return identity.UserID, nil
+	}
+	if !errors.Is(err, storage.ErrorIdentityNotFound) {
+		log.Error("failed to get identity", "error", err)
+		return 0, err
+	}
+
+	identity = models.FederatedIdentity{
+		Connector: connector.ID,
+		Subject:   claims.Subject,
+		Email:     claims.Email,
+		CreatedAt: time.Now(),
+	}
+	if claims.Email == "" {
+		log.Warn("identity has no email", slog.String("connector", connector.ID))
+		return 0, ErrorAccountNotFound
+	}
+
+	user, err := f.userProvider.User(ctx, claims.Email)
+	if errors.Is(err, storage.ErrorUserNotFound) {
+		return f.provision(ctx, log, connector, login, identity, claims)
+	}
+	if err != nil {
+		log.Error("failed to get user", "error", err)
+		return 0, err
+	}
+
+	if !connector.LinkByEmail || !claims.emailVerified() {
+		return 0, f.startLink(ctx, log, login, identity, user.ID)
+	}
+
+	identity.UserID = user.ID
+	if err := f.saveIdentity(ctx, log, identity); err != nil {
+		return 0, err
+	}
+
+	return user.ID, nil
+}
+
+// provision registers user of the identity if the connector allows
+func (f *Federation) provision(
+	ctx context.Context,
+	log *slog.Logger,
+	connector Connector,
+	login models.FederatedLogin,
+	identity models.FederatedIdentity,
+	claims upstreamClaims,
+) (int64, error) {
+	if !connector.Provision || !claims.emailVerified() {
+		log.Warn("no user for the identity", slog.String("connector", connector.ID))
+		return 0, ErrorAccountNotFound
+	}
+
+	user := models.User{
+		Email: claims.Email,
+		Profile: models.Profile{
+			Name:       claims.Name,
+			GivenName:  claims.GivenName,
+			FamilyName: claims.FamilyName,
+			Picture:    claims.Picture,
+			Locale:     claims.Locale,
+		},
+	}
+	userID, err := f.auth.RegisterFederated(ctx, user, identity, login.AppID)
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// startLink saves identity waiting to be linked to the user and returns
+// *LinkRequiredError with the link token
+func (f *Federation) startLink(
+	ctx context.Context,
+	log *slog.Logger,
+	login models.FederatedLogin,
+	identity models.FederatedIdentity,
+	userID int64,
+) error {
+	linkToken, err := secret.New(linkBytes)
+	if err != nil {
+		log.Error("failed to generate link token", "error", err)
+		return err
+	}
+
+	now := time.Now()
+	link := models.FederatedLink{
+		UserID:    userID,
+		Connector: identity.Connector,
+		Subject:   identity.Subject,
+		Email:     identity.Email,
+		AppID:     login.AppID,
+		Request:   login.Request,
+		ExpiresAt: now.Add(linkTTL),
+	}
+	if _, err := f.links.SaveFederatedLink(ctx, link, secret.Hash(linkToken), now); err != nil {
+		log.Error("failed to save link", "error", err)
+		return err
+	}
+
+	log.Info("identity link required", slog.Int64("userID", userID), slog.String("connector", identity.Connector))
+
+	return &LinkRequiredError{Token: linkToken, Email: identity.Email}
+}
+
+func (f *Federation) saveIdentity(ctx context.Context, log *slog.Logger, identity models.FederatedIdentity) error {
+	if _, err := f.identities.SaveFederatedIdentity(ctx, identity); err != nil {
+		if errors.Is(err, storage.ErrorIdentityExists) {
+			log.Warn("identity already linked", slog.String("connector", identity.Connector))
+			return ErrorIdentityLinked
+		}
+		log.Error("failed to save identity", "error", err)
+		return err
+	}
+
+	log.Info("identity linked", slog.Int64("userID", identity.UserID), slog.String("connector", identity.Connector))
+
+	return nil
+}
+
+// connector returns connector by id
+func (f *Federation) connector(log *slog.Logger, id string) (Connector, error) {
+	for _, connector := range f.connectors {
+		if connector.ID == id {
+			return connector, nil
+		}
+	}
+	log.Warn("connector not found", slog.String("connector", id))
+	return Connector{}, ErrorConnectorNotFound
+}
+
+// oauthConfig returns OAuth client config of the connector at its provider,
+// discovering the provider on first use
+func (f *Federation) oauthConfig(
+	log *slog.Logger,
+	connector Connector,
+) (*oauth2.Config, *oidc.Provider, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	provider, ok := f.providers[connector.ID]
+	if !ok {
+		// the provider keeps the context to fetch its keys later on
+		providerCtx := oidc.ClientContext(context.Background(), f.client)
+
+		var err error
+		provider, err = oidc.NewProvider(providerCtx, strings.TrimSuffix(connector.Issuer, "/"))
+		if err != nil {
+			log.Error("failed to discover provider", slog.String("connector", connector.ID), "error", err)
+			return nil, nil, ErrorProviderFailed
+		}
+		f.providers[connector.ID] = provider
+	}
+
+	scopes := connector.Scopes
+	if len(scopes) == 0 {
+		scopes = defaultScopes
+	}
+
+	return &oauth2.Config{
+		ClientID:     connector.ClientID,
+		ClientSecret: connector.ClientSecret,
+		Endpoint:     provider.Endpoint(),
+		RedirectURL:  f.callbackURL,
+		Scopes:       scopes,
+	}, provider, nil
+}
@@ -0,0 +1,151 @@
+package invites
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/lib/secret"
+	"github.com/Len4i/auth-service/internal/services/storage"
+)
+
+var (
+	ErrorInviteNotFound = errors.New("invite not found")
+	ErrorInvalidMaxUses = errors.New("invalid max uses")
+	ErrorInvalidTTL     = errors.New("invalid ttl")
+)
+
+const inviteCodeBytes = 18
+
+type InviteSaver interface {
+	SaveInvite(ctx context.Context, invite models.Invite, codeHash []byte) (id int64, err error)
+	RevokeInvite(ctx context.Context, id int64) error
+}
+
+type InviteProvider interface {
+	Invites(ctx context.Context, appID int) ([]models.Invite, error)
+}
+
+type Invites struct {
+	log            *slog.Logger
+	inviteSaver    InviteSaver
+	inviteProvider InviteProvider
+	defaultTTL     time.Duration
+}
+
+// NewInvites creates new invites service
+func NewInvites(log *slog.Logger, inviteSaver InviteSaver, inviteProvider InviteProvider, defaultTTL time.Duration) *Invites {
+	return &Invites{
+		log:            log,
+		inviteSaver:    inviteSaver,
+		inviteProvider: inviteProvider,
+		defaultTTL:     defaultTTL,
+	}
+}
+
+// CreateInvite creates invite and returns it with its code
+//
+// maxUses 0 creates a single-use invite, ttl 0 uses the default one.
+// The code is not stored and can't be retrieved later.
+func (i *Invites) CreateInvite(
+	ctx context.Context,
+	createdBy int64,
+	appID int,
+	email string,
+	maxUses int,
+	ttl time.Duration,
+) (models.Invite, string, error) {
+	const op = "invites.CreateInvite"
+	log := i.log.With(slog.String("operation", op))
+
+	if maxUses < 0 {
+		return models.Invite{}, "", fmt.Errorf("%s: %w", op, ErrorInvalidMaxUses)
+	}
+	if maxUses == 0 {
+		maxUses = 1
+	}
+	if ttl < 0 {
+		return models.Invite{}, "", fmt.Errorf("%s: %w", op, ErrorInvalidTTL)
+	}
+	if ttl == 0 {
+		ttl = i.defaultTTL
+	}
+
+	code, err := secret.New(inviteCodeBytes)
+	if err != nil {
+		log.Error("failed to generate invite code", "error", err)
+		return models.Invite{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now()
+	invite := models.Invite{
+		AppID:     appID,
+		Email:     email,
+		MaxUses:   maxUses,
+		ExpiresAt: now.Add(ttl),
+		CreatedBy: createdBy,
+		CreatedAt: now,
+	}
+
+	invite.ID, err = i.inviteSaver.SaveInvite(ctx, invite, secret.Hash(code))
+	if err != nil {
+		log.Error("failed to save invite", "error", err)
+		return models.Invite{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("invite created", slog.Int64("inviteID", invite.ID), slog.Int64("createdBy", createdBy))
+
+	return invite, code, nil
+}
+
+// ListInvites returns invites of the app, appID 0 returns invites of all apps
+//
+// Expired, revoked and used up invites are skipped unless includeInactive is set
+func (i *Invites) ListInvites(ctx context.Context, appID int, includeInactive bool) ([]models.Invite, error) {
+	const op = "invites.ListInvites"
+	log := i.log.With(slog.String("operation", op))
+
+	invites, err := i.inviteProvider.Invites(ctx, appID)
+	if err != nil {
+		log.Error("failed to get invites", "error", err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if includeInactive {
+		return invites, nil
+	}
+
+	now := time.Now()
+	active := invites[:0]
+	for _, invite := range invites {
+		if invite.Active(now) {
+			active = append(active, invite)
+		}
+	}
+
+	return active, nil
+}
+
+// RevokeInvite revokes invite
+//
+// If invite is not found, returns error
+func (i *Invites) RevokeInvite(ctx context.Context, id int64) error {
+	const op = "invites.RevokeInvite"
+	log := i.log.With(slog.String("operation", op))
+
+	if err := i.inviteSaver.RevokeInvite(ctx, id); err != nil {
+		if errors.Is(err, storage.ErrorInviteNotFound) {
+			log.Warn("invite not found", slog.Int64("inviteID", id))
+			return fmt.Errorf("%s: %w", op, ErrorInviteNotFound)
+		}
+		log.Error("failed to revoke invite", "error", err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("invite revoked", slog.Int64("inviteID", id))
+
+	return nil
+}
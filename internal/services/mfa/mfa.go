@@ -0,0 +1,296 @@
+package mfa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/lib/seal"
+	"github.com/Len4i/auth-service/internal/lib/totp"
+	"github.com/Len4i/auth-service/internal/services/storage"
+)
+
+var (
+	ErrorMFANotConfigured  = errors.New("mfa is not configured")
+	ErrorMFAAlreadyEnabled = errors.New("mfa is already enabled")
+	ErrorMFANotEnrolled    = errors.New("mfa is not enrolled")
+	ErrorInvalidCode       = errors.New("invalid code")
+	ErrorTooManyAttempts   = errors.New("too many attempts")
+)
+
+const (
+	// attempts of a user to verify the second factor are limited whatever
+	// asks for it, so that new challenges don't bring new attempts
+	maxAttempts   = 10
+	attemptWindow = 15 * time.Minute
+)
+
+type FactorSaver interface {
+	SaveTOTPFactor(ctx context.Context, userID int64, secret []byte, now time.Time) error
+	ConfirmTOTPFactor(ctx context.Context, userID int64, step int64) error
+	UseTOTPStep(ctx context.Context, userID int64, step int64) (bool, error)
+	DeleteTOTPFactor(ctx context.Context, userID int64) error
+}
+
+type FactorProvider interface {
+	TOTPFactor(ctx context.Context, userID int64) (factor models.TOTPFactor, err error)
+}
+
+type AttemptStorage interface {
+	ClaimMFAAttempt(ctx context.Context, userID int64, maxAttempts int, window time.Duration, now time.Time) error
+	ResetMFAAttempts(ctx context.Context, userID int64) error
+}
+
+type MFA struct {
+	log            *slog.Logger
+	factorSaver    FactorSaver
+	factorProvider FactorProvider
+	recoveryCodes  RecoveryCodeStorage
+	attempts       AttemptStorage
+	sealer         *seal.Sealer
+	issuer         string
+}
+
+// NewMFA creates new mfa service
+//
+// TOTP secrets are encrypted with sealer, nil sealer disables enrollment.
+// Issuer is shown in authenticator apps.
+func NewMFA(
+	log *slog.Logger,
+	factorSaver FactorSaver,
+	factorProvider FactorProvider,
+	recoveryCodes RecoveryCodeStorage,
+	attempts AttemptStorage,
+	sealer *seal.Sealer,
+	issuer string,
+) *MFA {
+	return &MFA{
+		log:            log,
+		factorSaver:    factorSaver,
+		factorProvider: factorProvider,
+		recoveryCodes:  recoveryCodes,
+		attempts:       attempts,
+		sealer:         sealer,
+		issuer:         issuer,
+	}
+}
+
+// EnrollTOTP generates new TOTP secret for user
+//
+// The factor is not used until it is confirmed with ConfirmTOTP.
+// Returns secret and otpauth URI for authenticator apps.
+func (m *MFA) EnrollTOTP(ctx context.Context, userID int64, email string) (secret string, uri string, err error) {
+	const op = "mfa.EnrollTOTP"
+	log := m.log.With(slog.String("operation", op))
+
+	if m.sealer == nil {
+		return "", "", fmt.Errorf("%s: %w", op, ErrorMFANotConfigured)
+	}
+
+	factor, err := m.factorProvider.TOTPFactor(ctx, userID)
+	if err == nil && factor.Confirmed {
+		log.Warn("mfa is already enabled", slog.Int64("userID", userID))
+		return "", "", fmt.Errorf("%s: %w", op, ErrorMFAAlreadyEnabled)
+	}
+	if err != nil && !errors.Is(err, storage.ErrorFactorNotFound) {
+		log.Error("failed to get totp factor", "error", err)
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	secret, err = totp.GenerateSecret()
+	if err != nil {
+		log.Error("failed to generate totp secret", "error", err)
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	sealed, err := m.sealer.Seal([]byte(secret))
+	if err != nil {
+		log.Error("failed to encrypt totp secret", "error", err)
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := m.factorSaver.SaveTOTPFactor(ctx, userID, sealed, time.Now()); err != nil {
+		log.Error("failed to save totp factor", "error", err)
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("totp enrollment started", slog.Int64("userID", userID))
+
+	return secret, totp.URI(m.issuer, email, secret), nil
+}
+
+// ConfirmTOTP enables TOTP factor with the first code from authenticator app
+//
+// Returns a new set of recovery codes, they are shown to the user only once.
+func (m *MFA) ConfirmTOTP(ctx context.Context, userID int64, code string) (recoveryCodes []string, err error) {
+	const op = "mfa.ConfirmTOTP"
+	log := m.log.With(slog.String("operation", op))
+
+	factor, err := m.totpFactor(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if factor.Confirmed {
+		return nil, fmt.Errorf("%s: %w", op, ErrorMFAAlreadyEnabled)
+	}
+
+	step, err := m.validate(factor, code)
+	if err != nil {
+		log.Warn("invalid totp code", slog.Int64("userID", userID))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	recoveryCodes, err = m.newRecoveryCodes(ctx, userID)
+	if err != nil {
+		log.Error("failed to generate recovery codes", "error", err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := m.factorSaver.ConfirmTOTPFactor(ctx, userID, step); err != nil {
+		log.Error("failed to confirm totp factor", "error", err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("totp enabled", slog.Int64("userID", userID))
+
+	return recoveryCodes, nil
+}
+
+// DisableTOTP removes TOTP factor and recovery codes, current code is required
+func (m *MFA) DisableTOTP(ctx context.Context, userID int64, code string) error {
+	const op = "mfa.DisableTOTP"
+	log := m.log.With(slog.String("operation", op))
+
+	if err := m.Verify(ctx, userID, code); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := m.factorSaver.DeleteTOTPFactor(ctx, userID); err != nil {
+		log.Error("failed to delete totp factor", "error", err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := m.recoveryCodes.DeleteRecoveryCodes(ctx, userID); err != nil {
+		log.Error("failed to delete recovery codes", "error", err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("totp disabled", slog.Int64("userID", userID))
+
+	return nil
+}
+
+// Enabled reports whether user has a confirmed second factor
+func (m *MFA) Enabled(ctx context.Context, userID int64) (bool, error) {
+	const op = "mfa.Enabled"
+
+	factor, err := m.factorProvider.TOTPFactor(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrorFactorNotFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return factor.Confirmed, nil
+}
+
+// Verify checks second factor code of user with enabled MFA
+//
+// Code is either TOTP code or one of recovery codes.
+// Each code is accepted only once. Users have a few attempts in a window,
+// returns ErrorTooManyAttempts once they are used up.
+func (m *MFA) Verify(ctx context.Context, userID int64, code string) error {
+	const op = "mfa.Verify"
+	log := m.log.With(slog.String("operation", op))
+
+	factor, err := m.totpFactor(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if !factor.Confirmed {
+		return fmt.Errorf("%s: %w", op, ErrorMFANotEnrolled)
+	}
+
+	if err := m.attempts.ClaimMFAAttempt(ctx, userID, maxAttempts, attemptWindow, time.Now()); err != nil {
+		if errors.Is(err, storage.ErrorMFAAttemptsExhausted) {
+			log.Warn("mfa attempts exhausted", slog.Int64("userID", userID))
+			return fmt.Errorf("%s: %w", op, ErrorTooManyAttempts)
+		}
+		log.Error("failed to count mfa attempt", "error", err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := m.verify(ctx, log, userID, factor, code); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := m.attempts.ResetMFAAttempts(ctx, userID); err != nil {
+		log.Error("failed to reset mfa attempts", "error", err)
+	}
+
+	return nil
+}
+
+// verify checks code against factor of user
+func (m *MFA) verify(ctx context.Context, log *slog.Logger, userID int64, factor models.TOTPFactor, code string) error {
+	if isRecoveryCode(code) {
+		if err := m.useRecoveryCode(ctx, userID, code); err != nil {
+			log.Warn("invalid recovery code", slog.Int64("userID", userID))
+			return err
+		}
+		log.Info("recovery code used", slog.Int64("userID", userID))
+		return nil
+	}
+
+	step, err := m.validate(factor, code)
+	if err != nil {
+		log.Warn("invalid totp code", slog.Int64("userID", userID))
+		return err
+	}
+
+	fresh, err := m.factorSaver.UseTOTPStep(ctx, userID, step)
+	if err != nil {
+		log.Error("failed to save totp step", "error", err)
+		return err
+	}
+	if !fresh {
+		log.Warn("totp code replayed", slog.Int64("userID", userID))
+		return ErrorInvalidCode
+	}
+
+	return nil
+}
+
+func (m *MFA) totpFactor(ctx context.Context, userID int64) (models.TOTPFactor, error) {
+	factor, err := m.factorProvider.TOTPFactor(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrorFactorNotFound) {
+			return models.TOTPFactor{}, ErrorMFANotEnrolled
+		}
+		m.log.Error("failed to get totp factor", "error", err)
+		return models.TOTPFactor{}, err
+	}
+	return factor, nil
+}
+
+// validate checks code against factor secret and returns its time step
+func (m *MFA) validate(factor models.TOTPFactor, code string) (int64, error) {
+	if m.sealer == nil {
+		return 0, ErrorMFANotConfigured
+	}
+
+	secret, err := m.sealer.Open(factor.Secret)
+	if err != nil {
+		return 0, err
+	}
+
+	step, ok := totp.Validate(string(secret), code, time.Now())
+	if !ok || step <= factor.LastStep {
+		return 0, ErrorInvalidCode
+	}
+	return step, nil
+}
@@ -0,0 +1,125 @@
+package mfa
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/Len4i/auth-service/internal/lib/secret"
+)
+
+const (
+	recoveryCodesCount = 10
+	// 10 bytes is 16 base32 characters, shown in groups of 4
+	recoveryCodeBytes = 10
+	recoveryCodeGroup = 4
+)
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type RecoveryCodeStorage interface {
+	ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes [][]byte, now time.Time) error
+	UseRecoveryCode(ctx context.Context, userID int64, codeHash []byte, now time.Time) (bool, error)
+	RecoveryCodesRemaining(ctx context.Context, userID int64) (int, error)
+	DeleteRecoveryCodes(ctx context.Context, userID int64) error
+}
+
+// RegenerateRecoveryCodes replaces user recovery codes with a new set
+//
+// Current TOTP code or an unused recovery code is required, it takes one of
+// the attempts of user Verify allows.
+func (m *MFA) RegenerateRecoveryCodes(ctx context.Context, userID int64, code string) ([]string, error) {
+	const op = "mfa.RegenerateRecoveryCodes"
+	log := m.log.With(slog.String("operation", op))
+
+	if err := m.Verify(ctx, userID, code); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	codes, err := m.newRecoveryCodes(ctx, userID)
+	if err != nil {
+		log.Error("failed to generate recovery codes", "error", err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("recovery codes regenerated", slog.Int64("userID", userID))
+
+	return codes, nil
+}
+
+// Status reports whether TOTP is enabled and how many recovery codes are left
+func (m *MFA) Status(ctx context.Context, userID int64) (totpEnabled bool, recoveryCodesRemaining int, err error) {
+	const op = "mfa.Status"
+
+	totpEnabled, err = m.Enabled(ctx, userID)
+	if err != nil {
+		return false, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	recoveryCodesRemaining, err = m.recoveryCodes.RecoveryCodesRemaining(ctx, userID)
+	if err != nil {
+		return false, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return totpEnabled, recoveryCodesRemaining, nil
+}
+
+// newRecoveryCodes generates and stores a new set of recovery codes,
+// invalidating the old one
+func (m *MFA) newRecoveryCodes(ctx context.Context, userID int64) ([]string, error) {
+	codes := make([]string, recoveryCodesCount)
+	hashes := make([][]byte, recoveryCodesCount)
+	for i := range codes {
+		b := make([]byte, recoveryCodeBytes)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		raw := strings.ToLower(recoveryEncoding.EncodeToString(b))
+
+		groups := make([]string, 0, len(raw)/recoveryCodeGroup)
+		for j := 0; j < len(raw); j += recoveryCodeGroup {
+			groups = append(groups, raw[j:j+recoveryCodeGroup])
+		}
+
+		codes[i] = strings.Join(groups, "-")
+		hashes[i] = secret.Hash(raw)
+	}
+
+	if err := m.recoveryCodes.ReplaceRecoveryCodes(ctx, userID, hashes, time.Now()); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// useRecoveryCode accepts recovery code once
+func (m *MFA) useRecoveryCode(ctx context.Context, userID int64, code string) error {
+	ok, err := m.recoveryCodes.UseRecoveryCode(ctx, userID, secret.Hash(normalizeRecoveryCode(code)), time.Now())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrorInvalidCode
+	}
+	return nil
+}
+
+// normalizeRecoveryCode drops separators and case users may type differently
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, code)
+}
+
+// isRecoveryCode tells recovery codes from TOTP codes which are digits only
+func isRecoveryCode(code string) bool {
+	return len(normalizeRecoveryCode(code)) == recoveryEncoding.EncodedLen(recoveryCodeBytes)
+}
@@ -0,0 +1,299 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/lib/jwt"
+	"github.com/Len4i/auth-service/internal/lib/secret"
+	"github.com/Len4i/auth-service/internal/services/storage"
+)
+
+// Errors of the device authorization grant, see RFC 8628 section 3.5
+var (
+	ErrorAuthorizationPending = errors.New("authorization pending")
+	ErrorSlowDown             = errors.New("polling too fast")
+	ErrorAccessDenied         = errors.New("user denied the device")
+	ErrorExpiredToken         = errors.New("device code expired")
+	ErrorInvalidUserCode      = errors.New("invalid user code")
+)
+
+const (
+	// userCodeAlphabet has no vowels to avoid words and no
+	// characters easily confused with each other, see RFC 8628 section 6.1
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength   = 8
+	// slowDownStep is added to the poll interval on each slow_down
+	slowDownStep = 5 * time.Second
+)
+
+type DeviceStorage interface {
+	SaveDeviceCode(
+		ctx context.Context,
+		code models.DeviceCode,
+		deviceCodeHash []byte,
+		userCodeHash []byte,
+		expiredBefore time.Time,
+	) (id int64, err error)
+	DeviceCode(ctx context.Context, deviceCodeHash []byte) (models.DeviceCode, error)
+	DeviceCodeByUserCode(ctx context.Context, userCodeHash []byte) (models.DeviceCode, error)
+	UpdateDeviceCodePoll(ctx context.Context, id int64, polledAt time.Time, interval time.Duration) error
+	DecideDeviceCode(ctx context.Context, code models.DeviceCode) error
+	DeleteDeviceCode(ctx context.Context, id int64) error
+}
+
+// DeviceAuthorization is the response of the device authorization endpoint,
+// see RFC 8628 section 3.2
+//
+// UserCode is formatted for display, e.g. BCDF-GHJK.
+type DeviceAuthorization struct {
+	DeviceCode string
+	UserCode   string
+	ExpiresIn  time.Duration
+	Interval   time.Duration
+}
+
+// StartDeviceAuthorization issues device and user codes for the app
+//
+// The device shows the user code and polls PollDevice with the device code
+// while the user approves it on the verification page.
+func (o *OAuth) StartDeviceAuthorization(ctx context.Context, clientID string, scope string) (DeviceAuthorization, error) {
+	const op = "oauth.StartDeviceAuthorization"
+	log := o.log.With(slog.String("operation", op))
+
+	app, err := o.app(ctx, log, clientID)
+	if err != nil {
+		return DeviceAuthorization{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if scope != "" && !scopeRe.MatchString(scope) {
+		return DeviceAuthorization{}, fmt.Errorf("%s: %w", op, ErrorInvalidScope)
+	}
+	if o.signingKey == nil && slices.Contains(strings.Fields(scope), ScopeOpenID) {
+		log.Warn("openid scope without signing key", slog.Int("appID", app.ID))
+		return DeviceAuthorization{}, fmt.Errorf("%s: %w", op, ErrorInvalidScope)
+	}
+
+	deviceCode, err := secret.New(codeBytes)
+	if err != nil {
+		log.Error("failed to generate device code", "error", err)
+		return DeviceAuthorization{}, fmt.Errorf("%s: %w", op, err)
+	}
+	userCode, err := secret.NewFromAlphabet(userCodeAlphabet, userCodeLength)
+	if err != nil {
+		log.Error("failed to generate user code", "error", err)
+		return DeviceAuthorization{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now()
+	code := models.DeviceCode{
+		AppID:     app.ID,
+		Scope:     scope,
+		Interval:  o.pollInterval,
+		ExpiresAt: now.Add(o.deviceTTL),
+	}
+	// expired codes are kept for a while, so devices still polling get expired_token
+	expiredBefore := now.Add(-o.deviceTTL)
+	if _, err := o.devices.SaveDeviceCode(ctx, code, secret.Hash(deviceCode), secret.Hash(userCode), expiredBefore); err != nil {
+		log.Error("failed to save device code", "error", err)
+		return DeviceAuthorization{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("device authorization started", slog.Int("appID", app.ID))
+
+	return DeviceAuthorization{
+		DeviceCode: deviceCode,
+		UserCode:   userCode[:userCodeLength/2] + "-" + userCode[userCodeLength/2:],
+		ExpiresIn:  o.deviceTTL,
+		Interval:   o.pollInterval,
+	}, nil
+}
+
+// DeviceApp returns the app pending device authorization of the user code is for
+func (o *OAuth) DeviceApp(ctx context.Context, userCode string) (models.App, error) {
+	const op = "oauth.DeviceApp"
+	log := o.log.With(slog.String("operation", op))
+
+	code, err := o.pendingDeviceCode(ctx, log, userCode)
+	if err != nil {
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	app, err := o.appProvider.App(ctx, code.AppID)
+	if err != nil {
+		if errors.Is(err, storage.ErrorAppNotFound) {
+			log.Warn("app not found", slog.Int("appID", code.AppID))
+			return models.App{}, fmt.Errorf("%s: %w", op, ErrorInvalidUserCode)
+		}
+		log.Error("failed to get app", "error", err)
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return app, nil
+}
+
+// DecideDevice approves or denies pending device authorization of the
+// user code, token is the result of the login of the user to the app
+//
+// The device gets tokens of the login on its next poll.
+func (o *OAuth) DecideDevice(ctx context.Context, userCode string, token string, approve bool) error {
+	const op = "oauth.DecideDevice"
+	log := o.log.With(slog.String("operation", op))
+
+	code, err := o.pendingDeviceCode(ctx, log, userCode)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	claims, err := o.tokens.VerifyToken(ctx, token)
+	if err != nil || claims.UserID == 0 || claims.AppID != code.AppID {
+		log.Warn("login token is not valid for the app", slog.Int("appID", code.AppID))
+		return fmt.Errorf("%s: %w", op, ErrorInvalidLogin)
+	}
+
+	code.Status = models.DeviceCodeDenied
+	if approve {
+		code.Status = models.DeviceCodeApproved
+	}
+	code.UserID = claims.UserID
+	code.OrgID = claims.OrgID
+	code.Methods = claims.Methods
+	code.Level = claims.Level
+	code.AuthTime = claims.Time
+	if err := o.devices.DecideDeviceCode(ctx, code); err != nil {
+		if errors.Is(err, storage.ErrorDeviceCodeNotFound) {
+			log.Warn("device code already decided", slog.Int64("userID", claims.UserID))
+			return fmt.Errorf("%s: %w", op, ErrorInvalidUserCode)
+		}
+		log.Error("failed to save decision", "error", err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("device decided",
+		slog.Int64("userID", claims.UserID),
+		slog.Int("appID", code.AppID),
+		slog.String("status", code.Status),
+	)
+
+	return nil
+}
+
+// PollDevice exchanges device code for tokens of the user who approved it,
+// see RFC 8628 section 3.4
+//
+// While the user hasn't decided it returns ErrorAuthorizationPending, or
+// ErrorSlowDown if the device polls more often than its interval, which
+// then grows by 5 seconds. A decided device code is redeemed once.
+func (o *OAuth) PollDevice(ctx context.Context, clientID string, deviceCode string) (IssuedTokens, error) {
+	const op = "oauth.PollDevice"
+	log := o.log.With(slog.String("operation", op))
+
+	app, err := o.app(ctx, log, clientID)
+	if err != nil {
+		return IssuedTokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	code, err := o.devices.DeviceCode(ctx, secret.Hash(deviceCode))
+	if err != nil {
+		if errors.Is(err, storage.ErrorDeviceCodeNotFound) {
+			log.Warn("device code not found", slog.Int("appID", app.ID))
+			return IssuedTokens{}, fmt.Errorf("%s: %w", op, ErrorInvalidGrant)
+		}
+		log.Error("failed to get device code", "error", err)
+		return IssuedTokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now()
+	switch {
+	case code.AppID != app.ID:
+		log.Warn("device code of another app", slog.Int("appID", app.ID))
+		return IssuedTokens{}, fmt.Errorf("%s: %w", op, ErrorInvalidGrant)
+	case now.After(code.ExpiresAt):
+		log.Warn("device code expired", slog.Int("appID", app.ID))
+		return IssuedTokens{}, fmt.Errorf("%s: %w", op, ErrorExpiredToken)
+	case code.Status == models.DeviceCodePending:
+		pollErr := ErrorAuthorizationPending
+		interval := code.Interval
+		if !code.PolledAt.IsZero() && now.Sub(code.PolledAt) < code.Interval {
+			pollErr = ErrorSlowDown
+			interval += slowDownStep
+		}
+		if err := o.devices.UpdateDeviceCodePoll(ctx, code.ID, now, interval); err != nil {
+			log.Error("failed to save poll", "error", err)
+			return IssuedTokens{}, fmt.Errorf("%s: %w", op, err)
+		}
+		return IssuedTokens{}, fmt.Errorf("%s: %w", op, pollErr)
+	}
+
+	// concurrent polls race for the decided code, only one gets it
+	if err := o.devices.DeleteDeviceCode(ctx, code.ID); err != nil {
+		if errors.Is(err, storage.ErrorDeviceCodeNotFound) {
+			log.Warn("device code already redeemed", slog.Int("appID", app.ID))
+			return IssuedTokens{}, fmt.Errorf("%s: %w", op, ErrorInvalidGrant)
+		}
+		log.Error("failed to delete device code", "error", err)
+		return IssuedTokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if code.Status == models.DeviceCodeDenied {
+		log.Info("device denied", slog.Int64("userID", code.UserID), slog.Int("appID", app.ID))
+		return IssuedTokens{}, fmt.Errorf("%s: %w", op, ErrorAccessDenied)
+	}
+
+	tokens, err := o.issueTokens(ctx, log, grant{
+		UserID: code.UserID,
+		AppID:  code.AppID,
+		OrgID:  code.OrgID,
+		Scope:  code.Scope,
+		Authentication: jwt.Authentication{
+			Methods: code.Methods,
+			Level:   code.Level,
+			Time:    code.AuthTime,
+		},
+	}, now)
+	if err != nil {
+		return IssuedTokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("device code exchanged", slog.Int64("userID", code.UserID), slog.Int("appID", app.ID))
+
+	return tokens, nil
+}
+
+// pendingDeviceCode returns device authorization of the user code
+// the user has yet to decide
+func (o *OAuth) pendingDeviceCode(ctx context.Context, log *slog.Logger, userCode string) (models.DeviceCode, error) {
+	code, err := o.devices.DeviceCodeByUserCode(ctx, secret.Hash(NormalizeUserCode(userCode)))
+	if err != nil {
+		if errors.Is(err, storage.ErrorDeviceCodeNotFound) {
+			log.Warn("user code not found")
+			return models.DeviceCode{}, ErrorInvalidUserCode
+		}
+		log.Error("failed to get device code", "error", err)
+		return models.DeviceCode{}, err
+	}
+
+	if code.Status != models.DeviceCodePending || time.Now().After(code.ExpiresAt) {
+		log.Warn("user code is not pending", slog.Int("appID", code.AppID), slog.String("status", code.Status))
+		return models.DeviceCode{}, ErrorInvalidUserCode
+	}
+
+	return code, nil
+}
+
+// NormalizeUserCode returns user code as it's issued, users may
+// type it in lower case, without the dash or with spaces
+func NormalizeUserCode(userCode string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(userCode))
+}
@@ -0,0 +1,498 @@
+package oauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/lib/jwt"
+	"github.com/Len4i/auth-service/internal/lib/secret"
+	"github.com/Len4i/auth-service/internal/services/storage"
+)
+
+var (
+	ErrorInvalidClient           = errors.New("invalid client")
+	ErrorInvalidRedirectURI      = errors.New("redirect uri is not registered")
+	ErrorUnsupportedResponseType = errors.New("unsupported response type")
+	ErrorInvalidCodeChallenge    = errors.New("invalid code challenge")
+	ErrorInvalidScope            = errors.New("invalid scope")
+	ErrorInvalidLogin            = errors.New("login is not valid for the app")
+	ErrorInvalidGrant            = errors.New("invalid authorization code")
+	ErrorInvalidToken            = errors.New("invalid access token")
+	ErrorInsufficientScope       = errors.New("openid scope is not granted")
+)
+
+const (
+	ResponseTypeCode = "code"
+	// CodeChallengeS256 is the only PKCE method accepted, plain is not
+	CodeChallengeS256 = "S256"
+
+	codeBytes = 32
+)
+
+// Standard OpenID Connect scopes, see OpenID Connect Core section 5.4
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+var (
+	// ScopesSupported are the scopes with a meaning to this server,
+	// other scopes are passed through
+	ScopesSupported = []string{ScopeOpenID, ScopeProfile, ScopeEmail}
+	// ClaimsSupported are the claims of ID tokens and userinfo responses
+	ClaimsSupported = []string{
+		"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce", "amr", "acr",
+		"email", "name", "given_name", "family_name", "picture", "locale",
+	}
+)
+
+var (
+	// codeChallengeRe matches base64url SHA-256 digests
+	codeChallengeRe = regexp.MustCompile(`^[A-Za-z0-9_-]{43}$`)
+	// codeVerifierRe matches code verifiers, see RFC 7636 section 4.1
+	codeVerifierRe = regexp.MustCompile(`^[A-Za-z0-9._~-]{43,128}$`)
+	// scopeRe matches space separated scope tokens, see RFC 6749 section 3.3
+	scopeRe = regexp.MustCompile(`^[\x21\x23-\x5b\x5d-\x7e]+( [\x21\x23-\x5b\x5d-\x7e]+)*$`)
+)
+
+type CodeStorage interface {
+	SaveAuthorizationCode(
+		ctx context.Context,
+		code models.AuthorizationCode,
+		codeHash []byte,
+		now time.Time,
+	) (id int64, err error)
+	UseAuthorizationCode(ctx context.Context, codeHash []byte) (models.AuthorizationCode, error)
+}
+
+// GrantStorage keeps the scope users granted to apps
+type GrantStorage interface {
+	SaveOAuthGrant(ctx context.Context, userID int64, appID int, scope string, now time.Time) error
+	OAuthGrant(ctx context.Context, userID int64, appID int) (scope string, err error)
+}
+
+type AppProvider interface {
+	App(ctx context.Context, appID int) (app models.App, err error)
+}
+
+type UserProvider interface {
+	UserByID(ctx context.Context, id int64) (models.User, error)
+}
+
+// Tokens verifies tokens of users who logged in on the authorization page
+// and issues tokens for redeemed codes
+type Tokens interface {
+	VerifyToken(ctx context.Context, token string) (jwt.Claims, error)
+	IssueToken(ctx context.Context, claims jwt.Claims) (token string, err error)
+}
+
+// AuthorizationRequest is a request to the authorization endpoint,
+// see RFC 6749 section 4.1.1 and RFC 7636 section 4.3
+//
+// ClientID is the id of the app. Nonce is an OpenID Connect parameter.
+type AuthorizationRequest struct {
+	ClientID            string
+	RedirectURI         string
+	ResponseType        string
+	Scope               string
+	Nonce               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+}
+
+// CodeExchange is a token request with the authorization_code grant,
+// see RFC 6749 section 4.1.3 and RFC 7636 section 4.5
+type CodeExchange struct {
+	ClientID     string
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+}
+
+// IssuedTokens is the result of a redeemed authorization code
+//
+// IDToken is set if the granted scope includes openid.
+type IssuedTokens struct {
+	AccessToken string
+	IDToken     string
+	Scope       string
+	ExpiresIn   time.Duration
+}
+
+type OAuth struct {
+	log          *slog.Logger
+	codes        CodeStorage
+	grants       GrantStorage
+	devices      DeviceStorage
+	appProvider  AppProvider
+	userProvider UserProvider
+	tokens       Tokens
+	signingKey   *jwt.SigningKey
+	issuer       string
+	codeTTL      time.Duration
+	tokenTTL     time.Duration
+	deviceTTL    time.Duration
+	pollInterval time.Duration
+}
+
+// NewOAuth creates new OAuth authorization server service
+//
+// Apps are public clients identified by their id, they have to register
+// redirect URIs and use PKCE instead of a client secret.
+// ID tokens of OpenID Connect are signed with signingKey and issued
+// by issuer, the openid scope is rejected if signingKey is nil.
+// Devices poll for their device codes at least pollInterval apart.
+func NewOAuth(
+	log *slog.Logger,
+	codes CodeStorage,
+	grants GrantStorage,
+	devices DeviceStorage,
+	appProvider AppProvider,
+	userProvider UserProvider,
+	tokens Tokens,
+	signingKey *jwt.SigningKey,
+	issuer string,
+	codeTTL time.Duration,
+	tokenTTL time.Duration,
+	deviceTTL time.Duration,
+	pollInterval time.Duration,
+) *OAuth {
+	return &OAuth{
+		log:          log,
+		codes:        codes,
+		grants:       grants,
+		devices:      devices,
+		appProvider:  appProvider,
+		userProvider: userProvider,
+		tokens:       tokens,
+		signingKey:   signingKey,
+		issuer:       issuer,
+		codeTTL:      codeTTL,
+		tokenTTL:     tokenTTL,
+		deviceTTL:    deviceTTL,
+		pollInterval: pollInterval,
+	}
+}
+
+// Authorize validates authorization request and returns the app it's for
+//
+// ErrorInvalidClient and ErrorInvalidRedirectURI mean the request can't be
+// answered with a redirect, other errors are sent to the redirect URI.
+func (o *OAuth) Authorize(ctx context.Context, req AuthorizationRequest) (models.App, error) {
+	const op = "oauth.Authorize"
+	log := o.log.With(slog.String("operation", op))
+
+	app, err := o.app(ctx, log, req.ClientID)
+	if err != nil {
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if req.RedirectURI == "" || !slices.Contains(app.RedirectURIs, req.RedirectURI) {
+		log.Warn("redirect uri is not registered", slog.Int("appID", app.ID), slog.String("redirectURI", req.RedirectURI))
+		return models.App{}, fmt.Errorf("%s: %w", op, ErrorInvalidRedirectURI)
+	}
+	if req.ResponseType != ResponseTypeCode {
+		return models.App{}, fmt.Errorf("%s: %w", op, ErrorUnsupportedResponseType)
+	}
+	if req.CodeChallengeMethod != CodeChallengeS256 || !codeChallengeRe.MatchString(req.CodeChallenge) {
+		return models.App{}, fmt.Errorf("%s: %w", op, ErrorInvalidCodeChallenge)
+	}
+	if req.Scope != "" && !scopeRe.MatchString(req.Scope) {
+		return models.App{}, fmt.Errorf("%s: %w", op, ErrorInvalidScope)
+	}
+	if o.signingKey == nil && slices.Contains(strings.Fields(req.Scope), ScopeOpenID) {
+		log.Warn("openid scope without signing key", slog.Int("appID", app.ID))
+		return models.App{}, fmt.Errorf("%s: %w", op, ErrorInvalidScope)
+	}
+
+	return app, nil
+}
+
+// IssueCode returns authorization code for the request, token is the
+// result of the login of the user to the app
+//
+// The code is valid for a short time and can be exchanged once.
+func (o *OAuth) IssueCode(ctx context.Context, req AuthorizationRequest, token string) (string, error) {
+	const op = "oauth.IssueCode"
+	log := o.log.With(slog.String("operation", op))
+
+	app, err := o.Authorize(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	claims, err := o.tokens.VerifyToken(ctx, token)
+	if err != nil || claims.UserID == 0 || claims.AppID != app.ID {
+		log.Warn("login token is not valid for the app", slog.Int("appID", app.ID))
+		return "", fmt.Errorf("%s: %w", op, ErrorInvalidLogin)
+	}
+
+	code, err := secret.New(codeBytes)
+	if err != nil {
+		log.Error("failed to generate code", "error", err)
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now()
+	authorizationCode := models.AuthorizationCode{
+		AppID:         app.ID,
+		UserID:        claims.UserID,
+		OrgID:         claims.OrgID,
+		RedirectURI:   req.RedirectURI,
+		CodeChallenge: req.CodeChallenge,
+		Scope:         req.Scope,
+		Nonce:         req.Nonce,
+		Methods:       claims.Methods,
+		Level:         claims.Level,
+		AuthTime:      claims.Time,
+		ExpiresAt:     now.Add(o.codeTTL),
+	}
+	if _, err := o.codes.SaveAuthorizationCode(ctx, authorizationCode, secret.Hash(code), now); err != nil {
+		log.Error("failed to save code", "error", err)
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("authorization code issued", slog.Int64("userID", claims.UserID), slog.Int("appID", app.ID))
+
+	return code, nil
+}
+
+// ExchangeCode redeems authorization code and returns token of the user,
+// along with ID token if the scope includes openid
+//
+// Any mismatch of the client, redirect URI or PKCE verifier
+// burns the code as well.
+func (o *OAuth) ExchangeCode(ctx context.Context, req CodeExchange) (IssuedTokens, error) {
+	const op = "oauth.ExchangeCode"
+	log := o.log.With(slog.String("operation", op))
+
+	app, err := o.app(ctx, log, req.ClientID)
+	if err != nil {
+		return IssuedTokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	code, err := o.codes.UseAuthorizationCode(ctx, secret.Hash(req.Code))
+	if err != nil {
+		if errors.Is(err, storage.ErrorAuthorizationCodeNotFound) {
+			log.Warn("authorization code not found", slog.Int("appID", app.ID))
+			return IssuedTokens{}, fmt.Errorf("%s: %w", op, ErrorInvalidGrant)
+		}
+		log.Error("failed to get code", "error", err)
+		return IssuedTokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now()
+	switch {
+	case now.After(code.ExpiresAt):
+		log.Warn("authorization code expired", slog.Int64("userID", code.UserID))
+		return IssuedTokens{}, fmt.Errorf("%s: %w", op, ErrorInvalidGrant)
+	case code.AppID != app.ID:
+		log.Warn("authorization code of another app", slog.Int("appID", app.ID))
+		return IssuedTokens{}, fmt.Errorf("%s: %w", op, ErrorInvalidGrant)
+	case code.RedirectURI != req.RedirectURI:
+		log.Warn("redirect uri doesn't match", slog.Int64("userID", code.UserID))
+		return IssuedTokens{}, fmt.Errorf("%s: %w", op, ErrorInvalidGrant)
+	case !verifyCodeChallenge(code.CodeChallenge, req.CodeVerifier):
+		log.Warn("code verifier doesn't match", slog.Int64("userID", code.UserID))
+		return IssuedTokens{}, fmt.Errorf("%s: %w", op, ErrorInvalidGrant)
+	}
+
+	tokens, err := o.issueTokens(ctx, log, grant{
+		UserID: code.UserID,
+		AppID:  code.AppID,
+		OrgID:  code.OrgID,
+		Scope:  code.Scope,
+		Nonce:  code.Nonce,
+		Authentication: jwt.Authentication{
+			Methods: code.Methods,
+			Level:   code.Level,
+			Time:    code.AuthTime,
+		},
+	}, now)
+	if err != nil {
+		return IssuedTokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("authorization code exchanged", slog.Int64("userID", code.UserID), slog.Int("appID", app.ID))
+
+	return tokens, nil
+}
+
+// UserInfo returns claims of the user the access token is issued to,
+// see OpenID Connect Core section 5.3
+//
+// Claims are selected by the scope the user last granted to the app of the
+// token, which must include openid.
+func (o *OAuth) UserInfo(ctx context.Context, accessToken string) (map[string]any, error) {
+	const op = "oauth.UserInfo"
+	log := o.log.With(slog.String("operation", op))
+
+	claims, err := o.tokens.VerifyToken(ctx, accessToken)
+	if err != nil || claims.UserID == 0 {
+		log.Warn("invalid access token", "error", err)
+		return nil, fmt.Errorf("%s: %w", op, ErrorInvalidToken)
+	}
+
+	scope, err := o.grants.OAuthGrant(ctx, claims.UserID, claims.AppID)
+	if err != nil {
+		if errors.Is(err, storage.ErrorOAuthGrantNotFound) {
+			log.Warn("no openid grant", slog.Int64("userID", claims.UserID), slog.Int("appID", claims.AppID))
+			return nil, fmt.Errorf("%s: %w", op, ErrorInsufficientScope)
+		}
+		log.Error("failed to get grant", "error", err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	scopes := strings.Fields(scope)
+
+	user, err := o.userProvider.UserByID(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrorUserNotFound) {
+			log.Warn("user not found", slog.Int64("userID", claims.UserID))
+			return nil, fmt.Errorf("%s: %w", op, ErrorInvalidToken)
+		}
+		log.Error("failed to get user", "error", err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	info := userClaims(user, scopes)
+	info["sub"] = strconv.FormatInt(user.ID, 10)
+
+	return info, nil
+}
+
+// SigningKeys returns public keys verifying ID tokens, none if OpenID Connect is disabled
+func (o *OAuth) SigningKeys() []jwt.JWK {
+	if o.signingKey == nil {
+		return nil
+	}
+	return []jwt.JWK{o.signingKey.JWK()}
+}
+
+// grant is the login of the user approved for the app,
+// either by an authorization code or a device code
+type grant struct {
+	UserID int64
+	AppID  int
+	OrgID  int64
+	Scope  string
+	Nonce  string
+	jwt.Authentication
+}
+
+// issueTokens issues token of the user to the app the same way Auth.Login does,
+// along with ID token if the scope includes openid
+func (o *OAuth) issueTokens(ctx context.Context, log *slog.Logger, g grant, now time.Time) (IssuedTokens, error) {
+	tokens := IssuedTokens{
+		Scope:     g.Scope,
+		ExpiresIn: o.tokenTTL,
+	}
+	var err error
+	tokens.AccessToken, err = o.tokens.IssueToken(ctx, jwt.Claims{
+		UserID:         g.UserID,
+		AppID:          g.AppID,
+		Scope:          jwt.Scope{OrgID: g.OrgID},
+		Authentication: g.Authentication,
+	})
+	if err != nil {
+		log.Error("failed to issue token", "error", err)
+		return IssuedTokens{}, err
+	}
+
+	scopes := strings.Fields(g.Scope)
+	if !slices.Contains(scopes, ScopeOpenID) {
+		return tokens, nil
+	}
+
+	user, err := o.userProvider.UserByID(ctx, g.UserID)
+	if err != nil {
+		log.Error("failed to get user", "error", err)
+		return IssuedTokens{}, err
+	}
+
+	tokens.IDToken, err = jwt.NewIDToken(o.signingKey, jwt.IDToken{
+		Issuer:         o.issuer,
+		Subject:        strconv.FormatInt(user.ID, 10),
+		Audience:       strconv.Itoa(g.AppID),
+		Nonce:          g.Nonce,
+		Authentication: g.Authentication,
+		Claims:         userClaims(user, scopes),
+	}, o.tokenTTL)
+	if err != nil {
+		log.Error("failed to generate id token", "error", err)
+		return IssuedTokens{}, err
+	}
+
+	if err := o.grants.SaveOAuthGrant(ctx, user.ID, g.AppID, g.Scope, now); err != nil {
+		log.Error("failed to save grant", "error", err)
+		return IssuedTokens{}, err
+	}
+
+	return tokens, nil
+}
+
+// userClaims returns claims of the user released by scopes,
+// empty profile fields are left out
+func userClaims(user models.User, scopes []string) map[string]any {
+	claims := map[string]any{}
+	if slices.Contains(scopes, ScopeEmail) {
+		claims["email"] = user.Email
+	}
+	if slices.Contains(scopes, ScopeProfile) {
+		profile := map[string]string{
+			"name":        user.Profile.Name,
+			"given_name":  user.Profile.GivenName,
+			"family_name": user.Profile.FamilyName,
+			"picture":     user.Profile.Picture,
+			"locale":      user.Profile.Locale,
+		}
+		for name, value := range profile {
+			if value != "" {
+				claims[name] = value
+			}
+		}
+	}
+	return claims
+}
+
+// app returns app by client id
+func (o *OAuth) app(ctx context.Context, log *slog.Logger, clientID string) (models.App, error) {
+	appID, err := strconv.Atoi(clientID)
+	if err != nil || appID <= 0 {
+		log.Warn("invalid client id", slog.String("clientID", clientID))
+		return models.App{}, ErrorInvalidClient
+	}
+
+	app, err := o.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrorAppNotFound) {
+			log.Warn("app not found", slog.Int("appID", appID))
+			return models.App{}, ErrorInvalidClient
+		}
+		log.Error("failed to get app", "error", err)
+		return models.App{}, err
+	}
+
+	return app, nil
+}
+
+// verifyCodeChallenge checks verifier against S256 challenge
+func verifyCodeChallenge(challenge string, verifier string) bool {
+	if !codeVerifierRe.MatchString(verifier) {
+		return false
+	}
+	digest := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(digest[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
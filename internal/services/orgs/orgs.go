@@ -0,0 +1,522 @@
+package orgs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/lib/secret"
+	"github.com/Len4i/auth-service/internal/services/storage"
+)
+
+var (
+	ErrorInvalidSlug      = errors.New("invalid org slug")
+	ErrorInvalidName      = errors.New("invalid org name")
+	ErrorInvalidMethod    = errors.New("invalid authentication method")
+	ErrorInvalidRole      = errors.New("invalid org role")
+	ErrorInvalidAppID     = errors.New("invalid app id")
+	ErrorInvalidTTL       = errors.New("invalid ttl")
+	ErrorUserNotFound     = errors.New("user not found")
+	ErrorOrgNotFound      = errors.New("org not found")
+	ErrorOrgExists        = errors.New("org already exists")
+	ErrorMemberNotFound   = errors.New("org member not found")
+	ErrorInviteNotFound   = errors.New("org invite not found")
+	ErrorInvalidInvite    = errors.New("org invite is not valid")
+	ErrorLastOwnerRemoval = errors.New("last owner can't be removed")
+)
+
+const inviteCodeBytes = 18
+
+var (
+	slugRe   = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+	methodRe = regexp.MustCompile(`^[a-z0-9]{1,32}$`)
+)
+
+var roles = []string{models.OrgRoleOwner, models.OrgRoleAdmin, models.OrgRoleMember}
+
+type OrgStorage interface {
+	SaveOrg(ctx context.Context, org models.Org) (id int64, err error)
+	UpdateOrg(ctx context.Context, org models.Org) error
+	Org(ctx context.Context, id int64) (org models.Org, err error)
+	Orgs(ctx context.Context, userID int64) ([]models.Org, error)
+	DeleteOrg(ctx context.Context, id int64) error
+	SaveOrgMember(ctx context.Context, member models.OrgMember) error
+	OrgMember(ctx context.Context, orgID int64, userID int64) (member models.OrgMember, err error)
+	OrgMembers(ctx context.Context, orgID int64) ([]models.OrgMember, error)
+	DeleteOrgMember(ctx context.Context, orgID int64, userID int64) error
+	SaveOrgInvite(ctx context.Context, invite models.OrgInvite, codeHash []byte) (id int64, err error)
+	OrgInvites(ctx context.Context, orgID int64) ([]models.OrgInvite, error)
+	OrgInviteByCode(ctx context.Context, codeHash []byte) (invite models.OrgInvite, err error)
+	RevokeOrgInvite(ctx context.Context, orgID int64, id int64) error
+	AcceptOrgInvite(ctx context.Context, invite models.OrgInvite, userID int64, now time.Time) error
+}
+
+type UserProvider interface {
+	UserByID(ctx context.Context, id int64) (user models.User, err error)
+}
+
+type AppProvider interface {
+	App(ctx context.Context, appID int) (app models.App, err error)
+}
+
+type Orgs struct {
+	log              *slog.Logger
+	orgs             OrgStorage
+	userProvider     UserProvider
+	appProvider      AppProvider
+	defaultInviteTTL time.Duration
+}
+
+// NewOrgs creates new organizations service
+func NewOrgs(
+	log *slog.Logger,
+	orgs OrgStorage,
+	userProvider UserProvider,
+	appProvider AppProvider,
+	defaultInviteTTL time.Duration,
+) *Orgs {
+	return &Orgs{
+		log:              log,
+		orgs:             orgs,
+		userProvider:     userProvider,
+		appProvider:      appProvider,
+		defaultInviteTTL: defaultInviteTTL,
+	}
+}
+
+// CreateOrg creates org and makes user with ownerID its owner, ownerID 0 creates org without members
+func (o *Orgs) CreateOrg(ctx context.Context, org models.Org, ownerID int64) (models.Org, error) {
+	const op = "orgs.CreateOrg"
+	log := o.log.With(slog.String("operation", op))
+
+	if !slugRe.MatchString(org.Slug) {
+		return models.Org{}, fmt.Errorf("%s: %w", op, ErrorInvalidSlug)
+	}
+	org, err := o.normalize(ctx, log, org)
+	if err != nil {
+		return models.Org{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if ownerID != 0 {
+		if err := o.checkUser(ctx, log, ownerID); err != nil {
+			return models.Org{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	org.CreatedAt = time.Now()
+	org.ID, err = o.orgs.SaveOrg(ctx, org)
+	if err != nil {
+		if errors.Is(err, storage.ErrorOrgExists) {
+			log.Warn("org already exists", slog.String("slug", org.Slug))
+			return models.Org{}, fmt.Errorf("%s: %w", op, ErrorOrgExists)
+		}
+		log.Error("failed to save org", "error", err)
+		return models.Org{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if ownerID != 0 {
+		owner := models.OrgMember{OrgID: org.ID, UserID: ownerID, Role: models.OrgRoleOwner, CreatedAt: org.CreatedAt}
+		if err := o.orgs.SaveOrgMember(ctx, owner); err != nil {
+			log.Error("failed to save org owner", "error", err)
+			return models.Org{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	log.Info("org created", slog.Int64("orgID", org.ID))
+
+	return org, nil
+}
+
+// Org returns org by ID
+func (o *Orgs) Org(ctx context.Context, id int64) (models.Org, error) {
+	const op = "orgs.Org"
+	log := o.log.With(slog.String("operation", op))
+
+	org, err := o.org(ctx, log, id)
+	if err != nil {
+		return models.Org{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return org, nil
+}
+
+// ListOrgs returns orgs user is a member of, userID 0 returns all orgs
+func (o *Orgs) ListOrgs(ctx context.Context, userID int64) ([]models.Org, error) {
+	const op = "orgs.ListOrgs"
+	log := o.log.With(slog.String("operation", op))
+
+	orgs, err := o.orgs.Orgs(ctx, userID)
+	if err != nil {
+		log.Error("failed to get orgs", "error", err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return orgs, nil
+}
+
+// UpdateOrg changes name, allowed apps and login policy of the org, slug can't be changed
+func (o *Orgs) UpdateOrg(ctx context.Context, org models.Org) (models.Org, error) {
+	const op = "orgs.UpdateOrg"
+	log := o.log.With(slog.String("operation", op))
+
+	current, err := o.org(ctx, log, org.ID)
+	if err != nil {
+		return models.Org{}, fmt.Errorf("%s: %w", op, err)
+	}
+	org, err = o.normalize(ctx, log, org)
+	if err != nil {
+		return models.Org{}, fmt.Errorf("%s: %w", op, err)
+	}
+	org.Slug = current.Slug
+	org.CreatedAt = current.CreatedAt
+
+	if err := o.orgs.UpdateOrg(ctx, org); err != nil {
+		if errors.Is(err, storage.ErrorOrgNotFound) {
+			return models.Org{}, fmt.Errorf("%s: %w", op, ErrorOrgNotFound)
+		}
+		log.Error("failed to update org", "error", err)
+		return models.Org{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("org updated", slog.Int64("orgID", org.ID))
+
+	return org, nil
+}
+
+// DeleteOrg removes org with its memberships and invites
+func (o *Orgs) DeleteOrg(ctx context.Context, id int64) error {
+	const op = "orgs.DeleteOrg"
+	log := o.log.With(slog.String("operation", op))
+
+	if err := o.orgs.DeleteOrg(ctx, id); err != nil {
+		if errors.Is(err, storage.ErrorOrgNotFound) {
+			log.Warn("org not found", slog.Int64("orgID", id))
+			return fmt.Errorf("%s: %w", op, ErrorOrgNotFound)
+		}
+		log.Error("failed to delete org", "error", err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("org deleted", slog.Int64("orgID", id))
+
+	return nil
+}
+
+// MemberRole returns role of user in the org
+func (o *Orgs) MemberRole(ctx context.Context, orgID int64, userID int64) (string, error) {
+	const op = "orgs.MemberRole"
+	log := o.log.With(slog.String("operation", op))
+
+	member, err := o.orgs.OrgMember(ctx, orgID, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrorOrgMemberNotFound) {
+			return "", fmt.Errorf("%s: %w", op, ErrorMemberNotFound)
+		}
+		log.Error("failed to get org member", "error", err)
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return member.Role, nil
+}
+
+// ListMembers returns members of the org
+func (o *Orgs) ListMembers(ctx context.Context, orgID int64) ([]models.OrgMember, error) {
+	const op = "orgs.ListMembers"
+	log := o.log.With(slog.String("operation", op))
+
+	if _, err := o.org(ctx, log, orgID); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	members, err := o.orgs.OrgMembers(ctx, orgID)
+	if err != nil {
+		log.Error("failed to get org members", "error", err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return members, nil
+}
+
+// AddMember adds user to the org with the role or changes the role of a member
+func (o *Orgs) AddMember(ctx context.Context, orgID int64, userID int64, role string) error {
+	const op = "orgs.AddMember"
+	log := o.log.With(slog.String("operation", op))
+
+	if !slices.Contains(roles, role) {
+		return fmt.Errorf("%s: %w", op, ErrorInvalidRole)
+	}
+	if _, err := o.org(ctx, log, orgID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := o.checkUser(ctx, log, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if role != models.OrgRoleOwner {
+		if err := o.checkNotLastOwner(ctx, log, orgID, userID); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	member := models.OrgMember{OrgID: orgID, UserID: userID, Role: role, CreatedAt: time.Now()}
+	if err := o.orgs.SaveOrgMember(ctx, member); err != nil {
+		log.Error("failed to save org member", "error", err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("org member saved", slog.Int64("orgID", orgID), slog.Int64("userID", userID), slog.String("role", role))
+
+	return nil
+}
+
+// RemoveMember removes user from the org, the last owner can't be removed
+func (o *Orgs) RemoveMember(ctx context.Context, orgID int64, userID int64) error {
+	const op = "orgs.RemoveMember"
+	log := o.log.With(slog.String("operation", op))
+
+	if err := o.checkNotLastOwner(ctx, log, orgID, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := o.orgs.DeleteOrgMember(ctx, orgID, userID); err != nil {
+		if errors.Is(err, storage.ErrorOrgMemberNotFound) {
+			log.Warn("org member not found", slog.Int64("orgID", orgID), slog.Int64("userID", userID))
+			return fmt.Errorf("%s: %w", op, ErrorMemberNotFound)
+		}
+		log.Error("failed to delete org member", "error", err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("org member removed", slog.Int64("orgID", orgID), slog.Int64("userID", userID))
+
+	return nil
+}
+
+// CreateInvite creates invite to join the org with the role and returns it with its code
+//
+// ttl 0 uses the default one. The code is not stored and can't be retrieved later.
+func (o *Orgs) CreateInvite(
+	ctx context.Context,
+	createdBy int64,
+	orgID int64,
+	email string,
+	role string,
+	ttl time.Duration,
+) (models.OrgInvite, string, error) {
+	const op = "orgs.CreateInvite"
+	log := o.log.With(slog.String("operation", op))
+
+	if !slices.Contains(roles, role) {
+		return models.OrgInvite{}, "", fmt.Errorf("%s: %w", op, ErrorInvalidRole)
+	}
+	if ttl < 0 {
+		return models.OrgInvite{}, "", fmt.Errorf("%s: %w", op, ErrorInvalidTTL)
+	}
+	if ttl == 0 {
+		ttl = o.defaultInviteTTL
+	}
+	if _, err := o.org(ctx, log, orgID); err != nil {
+		return models.OrgInvite{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	code, err := secret.New(inviteCodeBytes)
+	if err != nil {
+		log.Error("failed to generate invite code", "error", err)
+		return models.OrgInvite{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now()
+	invite := models.OrgInvite{
+		OrgID:     orgID,
+		Email:     email,
+		Role:      role,
+		ExpiresAt: now.Add(ttl),
+		CreatedBy: createdBy,
+		CreatedAt: now,
+	}
+
+	invite.ID, err = o.orgs.SaveOrgInvite(ctx, invite, secret.Hash(code))
+	if err != nil {
+		log.Error("failed to save org invite", "error", err)
+		return models.OrgInvite{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("org invite created", slog.Int64("inviteID", invite.ID), slog.Int64("createdBy", createdBy))
+
+	return invite, code, nil
+}
+
+// ListInvites returns invites of the org
+//
+// Expired, revoked and accepted invites are skipped unless includeInactive is set
+func (o *Orgs) ListInvites(ctx context.Context, orgID int64, includeInactive bool) ([]models.OrgInvite, error) {
+	const op = "orgs.ListInvites"
+	log := o.log.With(slog.String("operation", op))
+
+	invites, err := o.orgs.OrgInvites(ctx, orgID)
+	if err != nil {
+		log.Error("failed to get org invites", "error", err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if includeInactive {
+		return invites, nil
+	}
+
+	now := time.Now()
+	active := invites[:0]
+	for _, invite := range invites {
+		if invite.Active(now) {
+			active = append(active, invite)
+		}
+	}
+
+	return active, nil
+}
+
+// RevokeInvite revokes invite of the org
+func (o *Orgs) RevokeInvite(ctx context.Context, orgID int64, id int64) error {
+	const op = "orgs.RevokeInvite"
+	log := o.log.With(slog.String("operation", op))
+
+	if err := o.orgs.RevokeOrgInvite(ctx, orgID, id); err != nil {
+		if errors.Is(err, storage.ErrorOrgInviteNotFound) {
+			log.Warn("org invite not found", slog.Int64("inviteID", id))
+			return fmt.Errorf("%s: %w", op, ErrorInviteNotFound)
+		}
+		log.Error("failed to revoke org invite", "error", err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("org invite revoked", slog.Int64("inviteID", id))
+
+	return nil
+}
+
+// AcceptInvite adds user with the email to the org of the invite
+//
+// Invite bound to an email can only be accepted by user with that email.
+func (o *Orgs) AcceptInvite(ctx context.Context, userID int64, email string, code string) (models.OrgInvite, error) {
+	const op = "orgs.AcceptInvite"
+	log := o.log.With(slog.String("operation", op))
+
+	invite, err := o.orgs.OrgInviteByCode(ctx, secret.Hash(code))
+	if err != nil {
+		if errors.Is(err, storage.ErrorOrgInviteNotFound) {
+			log.Warn("org invite not found")
+			return models.OrgInvite{}, fmt.Errorf("%s: %w", op, ErrorInvalidInvite)
+		}
+		log.Error("failed to get org invite", "error", err)
+		return models.OrgInvite{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now()
+	if !invite.Active(now) || (invite.Email != "" && !strings.EqualFold(invite.Email, email)) {
+		log.Warn("org invite is not valid", slog.Int64("inviteID", invite.ID))
+		return models.OrgInvite{}, fmt.Errorf("%s: %w", op, ErrorInvalidInvite)
+	}
+
+	if err := o.orgs.AcceptOrgInvite(ctx, invite, userID, now); err != nil {
+		if errors.Is(err, storage.ErrorOrgInviteNotFound) {
+			// accepted or revoked concurrently
+			return models.OrgInvite{}, fmt.Errorf("%s: %w", op, ErrorInvalidInvite)
+		}
+		log.Error("failed to accept org invite", "error", err)
+		return models.OrgInvite{}, fmt.Errorf("%s: %w", op, err)
+	}
+	invite.AcceptedBy = userID
+
+	log.Info("org invite accepted", slog.Int64("inviteID", invite.ID), slog.Int64("userID", userID))
+
+	return invite, nil
+}
+
+func (o *Orgs) org(ctx context.Context, log *slog.Logger, id int64) (models.Org, error) {
+	org, err := o.orgs.Org(ctx, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrorOrgNotFound) {
+			log.Warn("org not found", slog.Int64("orgID", id))
+			return models.Org{}, ErrorOrgNotFound
+		}
+		log.Error("failed to get org", "error", err)
+		return models.Org{}, err
+	}
+
+	return org, nil
+}
+
+// normalize validates name, allowed apps and login policy of org
+// and sorts its lists
+func (o *Orgs) normalize(ctx context.Context, log *slog.Logger, org models.Org) (models.Org, error) {
+	org.Name = strings.TrimSpace(org.Name)
+	if org.Name == "" || len(org.Name) > 128 {
+		return models.Org{}, ErrorInvalidName
+	}
+
+	methods := slices.Clone(org.LoginPolicy.AllowedMethods)
+	for _, method := range methods {
+		if !methodRe.MatchString(method) {
+			return models.Org{}, ErrorInvalidMethod
+		}
+	}
+	slices.Sort(methods)
+	org.LoginPolicy.AllowedMethods = slices.Compact(methods)
+
+	apps := slices.Clone(org.AllowedApps)
+	slices.Sort(apps)
+	apps = slices.Compact(apps)
+	for _, appID := range apps {
+		if _, err := o.appProvider.App(ctx, appID); err != nil {
+			if errors.Is(err, storage.ErrorAppNotFound) {
+				log.Warn("app not found", slog.Int("appID", appID))
+				return models.Org{}, ErrorInvalidAppID
+			}
+			log.Error("failed to get app", "error", err)
+			return models.Org{}, err
+		}
+	}
+	org.AllowedApps = apps
+
+	return org, nil
+}
+
+func (o *Orgs) checkUser(ctx context.Context, log *slog.Logger, userID int64) error {
+	if _, err := o.userProvider.UserByID(ctx, userID); err != nil {
+		if errors.Is(err, storage.ErrorUserNotFound) {
+			log.Warn("user not found", slog.Int64("userID", userID))
+			return ErrorUserNotFound
+		}
+		log.Error("failed to get user", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// checkNotLastOwner fails if user is the only owner of the org
+func (o *Orgs) checkNotLastOwner(ctx context.Context, log *slog.Logger, orgID int64, userID int64) error {
+	members, err := o.orgs.OrgMembers(ctx, orgID)
+	if err != nil {
+		log.Error("failed to get org members", "error", err)
+		return err
+	}
+
+	owners := 0
+	isOwner := false
+	for _, member := range members {
+		if member.Role == models.OrgRoleOwner {
+			owners++
+			isOwner = isOwner || member.UserID == userID
+		}
+	}
+	if isOwner && owners == 1 {
+		log.Warn("last org owner", slog.Int64("orgID", orgID), slog.Int64("userID", userID))
+		return ErrorLastOwnerRemoval
+	}
+
+	return nil
+}
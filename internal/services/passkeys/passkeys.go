@@ -0,0 +1,509 @@
+package passkeys
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/lib/secret"
+	"github.com/Len4i/auth-service/internal/services/storage"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+const (
+	sessionTTL   = 5 * time.Minute
+	sessionBytes = 24
+
+	purposeRegistration = "registration"
+	purposeLogin        = "login"
+)
+
+var (
+	ErrorPasskeysNotConfigured = errors.New("passkeys are not configured")
+	ErrorInvalidSession        = errors.New("invalid webauthn session")
+	ErrorInvalidCredential     = errors.New("invalid webauthn credential")
+	ErrorCredentialExists      = errors.New("webauthn credential already registered")
+	ErrorCredentialNotFound    = errors.New("webauthn credential not found")
+	ErrorNoCredentials         = errors.New("user has no webauthn credentials")
+)
+
+type CredentialStorage interface {
+	SaveWebAuthnCredential(ctx context.Context, cred models.WebAuthnCredential) (id int64, err error)
+	WebAuthnCredentials(ctx context.Context, userID int64) ([]models.WebAuthnCredential, error)
+	UpdateWebAuthnCredentialUsage(ctx context.Context, id int64, signCount uint32, backupState bool, now time.Time) error
+	DeleteWebAuthnCredential(ctx context.Context, userID int64, id int64) error
+}
+
+type SessionStorage interface {
+	SaveWebAuthnSession(ctx context.Context, session models.WebAuthnSession, handleHash []byte) (id int64, err error)
+	WebAuthnSession(ctx context.Context, handleHash []byte) (session models.WebAuthnSession, err error)
+	DeleteWebAuthnSession(ctx context.Context, id int64) error
+}
+
+type Passkeys struct {
+	log         *slog.Logger
+	credentials CredentialStorage
+	sessions    SessionStorage
+	webAuthn    *webauthn.WebAuthn
+}
+
+// NewPasskeys creates new passkeys service
+//
+// webAuthn holds relying party settings, nil disables passkeys.
+func NewPasskeys(
+	log *slog.Logger,
+	credentials CredentialStorage,
+	sessions SessionStorage,
+	webAuthn *webauthn.WebAuthn,
+) *Passkeys {
+	return &Passkeys{
+		log:         log,
+		credentials: credentials,
+		sessions:    sessions,
+		webAuthn:    webAuthn,
+	}
+}
+
+// BeginRegistration starts registration of a new credential for user
+//
+// Returns PublicKeyCredentialCreationOptions JSON for navigator.credentials.create
+// and the session handle to pass to FinishRegistration.
+func (p *Passkeys) BeginRegistration(ctx context.Context, userID int64, email string) (options []byte, session string, err error) {
+	const op = "passkeys.BeginRegistration"
+	log := p.log.With(slog.String("operation", op))
+
+	if p.webAuthn == nil {
+		return nil, "", fmt.Errorf("%s: %w", op, ErrorPasskeysNotConfigured)
+	}
+
+	user, err := p.user(ctx, userID, email)
+	if err != nil {
+		log.Error("failed to get credentials", "error", err)
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	var exclusions []protocol.CredentialDescriptor
+	for _, cred := range user.WebAuthnCredentials() {
+		exclusions = append(exclusions, cred.Descriptor())
+	}
+
+	creation, data, err := p.webAuthn.BeginRegistration(user,
+		webauthn.WithExclusions(exclusions),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred),
+	)
+	if err != nil {
+		log.Error("failed to begin registration", "error", err)
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	options, session, err = p.startSession(ctx, creation, data, models.WebAuthnSession{
+		Purpose: purposeRegistration,
+		UserID:  userID,
+	})
+	if err != nil {
+		log.Error("failed to save session", "error", err)
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return options, session, nil
+}
+
+// FinishRegistration verifies authenticator response and stores the new credential
+func (p *Passkeys) FinishRegistration(
+	ctx context.Context,
+	userID int64,
+	session string,
+	response []byte,
+	name string,
+) (models.WebAuthnCredential, error) {
+	const op = "passkeys.FinishRegistration"
+	log := p.log.With(slog.String("operation", op))
+
+	if p.webAuthn == nil {
+		return models.WebAuthnCredential{}, fmt.Errorf("%s: %w", op, ErrorPasskeysNotConfigured)
+	}
+
+	s, data, err := p.takeSession(ctx, session, purposeRegistration)
+	if err != nil {
+		log.Warn("registration session is not valid", "error", err)
+		return models.WebAuthnCredential{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if s.UserID != userID {
+		log.Warn("registration session belongs to another user", slog.Int64("userID", userID))
+		return models.WebAuthnCredential{}, fmt.Errorf("%s: %w", op, ErrorInvalidSession)
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(response))
+	if err != nil {
+		log.Warn("failed to parse registration response", "error", err)
+		return models.WebAuthnCredential{}, fmt.Errorf("%s: %w", op, ErrorInvalidCredential)
+	}
+
+	user, err := p.user(ctx, userID, "")
+	if err != nil {
+		log.Error("failed to get credentials", "error", err)
+		return models.WebAuthnCredential{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	credential, err := p.webAuthn.CreateCredential(user, data, parsed)
+	if err != nil {
+		log.Warn("registration response verification failed", "error", err)
+		return models.WebAuthnCredential{}, fmt.Errorf("%s: %w", op, ErrorInvalidCredential)
+	}
+
+	cred := models.WebAuthnCredential{
+		UserID:          userID,
+		CredentialID:    credential.ID,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		AAGUID:          credential.Authenticator.AAGUID,
+		SignCount:       credential.Authenticator.SignCount,
+		Transports:      make([]string, 0, len(credential.Transport)),
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+		Name:            name,
+		CreatedAt:       time.Now(),
+	}
+	for _, transport := range credential.Transport {
+		cred.Transports = append(cred.Transports, string(transport))
+	}
+
+	cred.ID, err = p.credentials.SaveWebAuthnCredential(ctx, cred)
+	if err != nil {
+		if errors.Is(err, storage.ErrorCredentialExists) {
+			log.Warn("credential already registered", slog.Int64("userID", userID))
+			return models.WebAuthnCredential{}, fmt.Errorf("%s: %w", op, ErrorCredentialExists)
+		}
+		log.Error("failed to save credential", "error", err)
+		return models.WebAuthnCredential{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("webauthn credential registered", slog.Int64("userID", userID), slog.Int64("credentialID", cred.ID))
+
+	return cred, nil
+}
+
+// Credentials returns credentials registered by user
+func (p *Passkeys) Credentials(ctx context.Context, userID int64) ([]models.WebAuthnCredential, error) {
+	const op = "passkeys.Credentials"
+
+	creds, err := p.credentials.WebAuthnCredentials(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return creds, nil
+}
+
+// DeleteCredential removes credential of user
+func (p *Passkeys) DeleteCredential(ctx context.Context, userID int64, id int64) error {
+	const op = "passkeys.DeleteCredential"
+	log := p.log.With(slog.String("operation", op))
+
+	if err := p.credentials.DeleteWebAuthnCredential(ctx, userID, id); err != nil {
+		if errors.Is(err, storage.ErrorCredentialNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrorCredentialNotFound)
+		}
+		log.Error("failed to delete credential", "error", err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("webauthn credential deleted", slog.Int64("userID", userID), slog.Int64("credentialID", id))
+
+	return nil
+}
+
+// Enabled reports if user can log in with a credential
+func (p *Passkeys) Enabled(ctx context.Context, userID int64) (bool, error) {
+	const op = "passkeys.Enabled"
+
+	if p.webAuthn == nil {
+		return false, nil
+	}
+
+	creds, err := p.credentials.WebAuthnCredentials(ctx, userID)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return len(creds) > 0, nil
+}
+
+// BeginLogin starts login ceremony for app
+//
+// With userID 0 any discoverable credential is accepted and user verification
+// is required, as the credential is the only factor. With mfaChallengeID the
+// ceremony is the second factor of a password login.
+// Returns PublicKeyCredentialRequestOptions JSON for navigator.credentials.get
+// and the session handle to pass to FinishLogin.
+func (p *Passkeys) BeginLogin(
+	ctx context.Context,
+	userID int64,
+	appID int,
+	mfaChallengeID int64,
+) (options []byte, session string, err error) {
+	const op = "passkeys.BeginLogin"
+	log := p.log.With(slog.String("operation", op))
+
+	if p.webAuthn == nil {
+		return nil, "", fmt.Errorf("%s: %w", op, ErrorPasskeysNotConfigured)
+	}
+
+	var opts []webauthn.LoginOption
+	if mfaChallengeID == 0 {
+		opts = append(opts, webauthn.WithUserVerification(protocol.VerificationRequired))
+	}
+
+	var assertion *protocol.CredentialAssertion
+	var data *webauthn.SessionData
+	if userID == 0 {
+		assertion, data, err = p.webAuthn.BeginDiscoverableLogin(opts...)
+	} else {
+		var user *webAuthnUser
+		user, err = p.user(ctx, userID, "")
+		if err != nil {
+			log.Error("failed to get credentials", "error", err)
+			return nil, "", fmt.Errorf("%s: %w", op, err)
+		}
+		if len(user.credentials) == 0 {
+			return nil, "", fmt.Errorf("%s: %w", op, ErrorNoCredentials)
+		}
+		assertion, data, err = p.webAuthn.BeginLogin(user, opts...)
+	}
+	if err != nil {
+		log.Error("failed to begin login", "error", err)
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	options, session, err = p.startSession(ctx, assertion, data, models.WebAuthnSession{
+		Purpose:        purposeLogin,
+		UserID:         userID,
+		AppID:          appID,
+		MFAChallengeID: mfaChallengeID,
+	})
+	if err != nil {
+		log.Error("failed to save session", "error", err)
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return options, session, nil
+}
+
+// FinishLogin verifies authenticator assertion
+//
+// Returns the login session with UserID of the credential owner.
+// Assertions with a signature counter that did not increase are rejected
+// as coming from a cloned authenticator.
+func (p *Passkeys) FinishLogin(ctx context.Context, session string, response []byte) (models.WebAuthnSession, error) {
+	const op = "passkeys.FinishLogin"
+	log := p.log.With(slog.String("operation", op))
+
+	if p.webAuthn == nil {
+		return models.WebAuthnSession{}, fmt.Errorf("%s: %w", op, ErrorPasskeysNotConfigured)
+	}
+
+	s, data, err := p.takeSession(ctx, session, purposeLogin)
+	if err != nil {
+		log.Warn("login session is not valid", "error", err)
+		return models.WebAuthnSession{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(response))
+	if err != nil {
+		log.Warn("failed to parse login response", "error", err)
+		return models.WebAuthnSession{}, fmt.Errorf("%s: %w", op, ErrorInvalidCredential)
+	}
+
+	var user *webAuthnUser
+	var credential *webauthn.Credential
+	if s.UserID == 0 {
+		credential, err = p.webAuthn.ValidateDiscoverableLogin(func(_, userHandle []byte) (webauthn.User, error) {
+			userID, err := parseUserHandle(userHandle)
+			if err != nil {
+				return nil, err
+			}
+			user, err = p.user(ctx, userID, "")
+			return user, err
+		}, data, parsed)
+	} else {
+		user, err = p.user(ctx, s.UserID, "")
+		if err != nil {
+			log.Error("failed to get credentials", "error", err)
+			return models.WebAuthnSession{}, fmt.Errorf("%s: %w", op, err)
+		}
+		credential, err = p.webAuthn.ValidateLogin(user, data, parsed)
+	}
+	if err != nil {
+		log.Warn("login response verification failed", "error", err)
+		return models.WebAuthnSession{}, fmt.Errorf("%s: %w", op, ErrorInvalidCredential)
+	}
+
+	if credential.Authenticator.CloneWarning {
+		log.Warn("signature counter did not increase, authenticator may be cloned", slog.Int64("userID", user.id))
+		return models.WebAuthnSession{}, fmt.Errorf("%s: %w", op, ErrorInvalidCredential)
+	}
+
+	for _, cred := range user.credentials {
+		if !bytes.Equal(cred.CredentialID, credential.ID) {
+			continue
+		}
+		err := p.credentials.UpdateWebAuthnCredentialUsage(
+			ctx, cred.ID, credential.Authenticator.SignCount, credential.Flags.BackupState, time.Now(),
+		)
+		if err != nil {
+			log.Error("failed to update credential usage", "error", err)
+			return models.WebAuthnSession{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	s.UserID = user.id
+
+	return s, nil
+}
+
+// startSession stores ceremony session data and returns options JSON with session handle
+func (p *Passkeys) startSession(
+	ctx context.Context,
+	options any,
+	data *webauthn.SessionData,
+	session models.WebAuthnSession,
+) ([]byte, string, error) {
+	optionsJSON, err := json.Marshal(options)
+	if err != nil {
+		return nil, "", err
+	}
+
+	session.Data, err = json.Marshal(data)
+	if err != nil {
+		return nil, "", err
+	}
+	session.ExpiresAt = time.Now().Add(sessionTTL)
+
+	handle, err := secret.New(sessionBytes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if _, err := p.sessions.SaveWebAuthnSession(ctx, session, secret.Hash(handle)); err != nil {
+		return nil, "", err
+	}
+
+	return optionsJSON, handle, nil
+}
+
+// takeSession removes session so that it can be used only once and returns its data
+func (p *Passkeys) takeSession(
+	ctx context.Context,
+	handle string,
+	purpose string,
+) (models.WebAuthnSession, webauthn.SessionData, error) {
+	session, err := p.sessions.WebAuthnSession(ctx, secret.Hash(handle))
+	if err != nil {
+		if errors.Is(err, storage.ErrorSessionNotFound) {
+			return models.WebAuthnSession{}, webauthn.SessionData{}, ErrorInvalidSession
+		}
+		return models.WebAuthnSession{}, webauthn.SessionData{}, err
+	}
+
+	if err := p.sessions.DeleteWebAuthnSession(ctx, session.ID); err != nil {
+		if errors.Is(err, storage.ErrorSessionNotFound) {
+			// finished concurrently
+			return models.WebAuthnSession{}, webauthn.SessionData{}, ErrorInvalidSession
+		}
+		return models.WebAuthnSession{}, webauthn.SessionData{}, err
+	}
+
+	if session.Purpose != purpose || time.Now().After(session.ExpiresAt) {
+		return models.WebAuthnSession{}, webauthn.SessionData{}, ErrorInvalidSession
+	}
+
+	var data webauthn.SessionData
+	if err := json.Unmarshal(session.Data, &data); err != nil {
+		return models.WebAuthnSession{}, webauthn.SessionData{}, err
+	}
+
+	return session, data, nil
+}
+
+// user loads credentials of user for the webauthn library
+func (p *Passkeys) user(ctx context.Context, userID int64, email string) (*webAuthnUser, error) {
+	creds, err := p.credentials.WebAuthnCredentials(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &webAuthnUser{
+		id:          userID,
+		name:        email,
+		credentials: creds,
+	}, nil
+}
+
+// webAuthnUser implements webauthn.User
+//
+// User handle is the big-endian user ID, it is returned by discoverable
+// credentials to identify the user.
+type webAuthnUser struct {
+	id          int64
+	name        string
+	credentials []models.WebAuthnCredential
+}
+
+func userHandle(userID int64) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(userID))
+}
+
+func parseUserHandle(handle []byte) (int64, error) {
+	if len(handle) != 8 {
+		return 0, fmt.Errorf("invalid user handle length %d", len(handle))
+	}
+	return int64(binary.BigEndian.Uint64(handle)), nil
+}
+
+func (u *webAuthnUser) WebAuthnID() []byte {
+	return userHandle(u.id)
+}
+
+func (u *webAuthnUser) WebAuthnName() string {
+	return u.name
+}
+
+func (u *webAuthnUser) WebAuthnDisplayName() string {
+	return u.name
+}
+
+func (u *webAuthnUser) WebAuthnIcon() string {
+	return ""
+}
+
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	creds := make([]webauthn.Credential, 0, len(u.credentials))
+	for _, cred := range u.credentials {
+		transports := make([]protocol.AuthenticatorTransport, 0, len(cred.Transports))
+		for _, transport := range cred.Transports {
+			transports = append(transports, protocol.AuthenticatorTransport(transport))
+		}
+		creds = append(creds, webauthn.Credential{
+			ID:              cred.CredentialID,
+			PublicKey:       cred.PublicKey,
+			AttestationType: cred.AttestationType,
+			Transport:       transports,
+			Flags: webauthn.CredentialFlags{
+				BackupEligible: cred.BackupEligible,
+				BackupState:    cred.BackupState,
+			},
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    cred.AAGUID,
+				SignCount: cred.SignCount,
+			},
+		})
+	}
+	return creds
+}
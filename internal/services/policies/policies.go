@@ -0,0 +1,302 @@
+package policies
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/lib/policy"
+	"github.com/Len4i/auth-service/internal/services/storage"
+)
+
+var (
+	ErrorInvalidName   = errors.New("invalid policy name")
+	ErrorInvalidEffect = errors.New("invalid policy effect")
+	// ErrorInvalidExpression errors are *policy.CompileError describing the issues
+	ErrorInvalidExpression = policy.ErrInvalidExpression
+	ErrorInvalidAppID      = errors.New("invalid app id")
+	ErrorPolicyNotFound    = errors.New("policy not found")
+	ErrorPolicyExists      = errors.New("policy already exists")
+)
+
+// dryRunPolicyName names the expression evaluated by Evaluate instead of stored policies
+const dryRunPolicyName = "dry-run"
+
+var nameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,63}$`)
+
+type PolicyStorage interface {
+	SavePolicy(ctx context.Context, policy models.Policy) (id int64, err error)
+	UpdatePolicy(ctx context.Context, policy models.Policy) error
+	Policy(ctx context.Context, appID int, name string) (policy models.Policy, err error)
+	Policies(ctx context.Context, appID int) ([]models.Policy, error)
+	DeletePolicy(ctx context.Context, appID int, name string) error
+}
+
+type AppProvider interface {
+	App(ctx context.Context, appID int) (app models.App, err error)
+}
+
+// Decision is the outcome of evaluating policies of an app
+//
+// Policy is the name of the deny policy that matched, or of the first
+// matching allow policy if none did, empty if access is denied by default.
+type Decision struct {
+	Allowed bool
+	Policy  string
+	Results []Result
+}
+
+// Result is the outcome of a single policy
+type Result struct {
+	Policy  string
+	Effect  string
+	Matched bool
+	// Err is set if the policy failed to evaluate
+	Err error
+}
+
+type Policies struct {
+	log         *slog.Logger
+	policies    PolicyStorage
+	appProvider AppProvider
+	// programs caches compiled expressions by their source
+	programs sync.Map
+}
+
+// NewPolicies creates new attribute-based access control service
+func NewPolicies(log *slog.Logger, policies PolicyStorage, appProvider AppProvider) *Policies {
+	return &Policies{
+		log:         log,
+		policies:    policies,
+		appProvider: appProvider,
+	}
+}
+
+// CreatePolicy creates policy of the app, appID 0 creates a global policy
+func (p *Policies) CreatePolicy(ctx context.Context, pol models.Policy) (models.Policy, error) {
+	const op = "policies.CreatePolicy"
+	log := p.log.With(slog.String("operation", op))
+
+	if err := p.validate(ctx, log, pol); err != nil {
+		return models.Policy{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now()
+	pol.CreatedAt = now
+	pol.UpdatedAt = now
+
+	var err error
+	pol.ID, err = p.policies.SavePolicy(ctx, pol)
+	if err != nil {
+		if errors.Is(err, storage.ErrorPolicyExists) {
+			log.Warn("policy already exists", slog.Int("appID", pol.AppID), slog.String("policy", pol.Name))
+			return models.Policy{}, fmt.Errorf("%s: %w", op, ErrorPolicyExists)
+		}
+		log.Error("failed to save policy", "error", err)
+		return models.Policy{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("policy created", slog.Int64("policyID", pol.ID))
+
+	return pol, nil
+}
+
+// UpdatePolicy replaces description, effect and expression of the policy
+// found by app and name
+func (p *Policies) UpdatePolicy(ctx context.Context, pol models.Policy) (models.Policy, error) {
+	const op = "policies.UpdatePolicy"
+	log := p.log.With(slog.String("operation", op))
+
+	if err := p.validate(ctx, log, pol); err != nil {
+		return models.Policy{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	pol.UpdatedAt = time.Now()
+	if err := p.policies.UpdatePolicy(ctx, pol); err != nil {
+		if errors.Is(err, storage.ErrorPolicyNotFound) {
+			log.Warn("policy not found", slog.Int("appID", pol.AppID), slog.String("policy", pol.Name))
+			return models.Policy{}, fmt.Errorf("%s: %w", op, ErrorPolicyNotFound)
+		}
+		log.Error("failed to update policy", "error", err)
+		return models.Policy{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	updated, err := p.policies.Policy(ctx, pol.AppID, pol.Name)
+	if err != nil {
+		log.Error("failed to get policy", "error", err)
+		return models.Policy{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("policy updated", slog.Int64("policyID", updated.ID))
+
+	return updated, nil
+}
+
+// ListPolicies returns policies of the app and global policies
+func (p *Policies) ListPolicies(ctx context.Context, appID int) ([]models.Policy, error) {
+	const op = "policies.ListPolicies"
+	log := p.log.With(slog.String("operation", op))
+
+	policies, err := p.policies.Policies(ctx, appID)
+	if err != nil {
+		log.Error("failed to get policies", "error", err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return policies, nil
+}
+
+// DeletePolicy removes policy of the app
+func (p *Policies) DeletePolicy(ctx context.Context, appID int, name string) error {
+	const op = "policies.DeletePolicy"
+	log := p.log.With(slog.String("operation", op))
+
+	if err := p.policies.DeletePolicy(ctx, appID, name); err != nil {
+		if errors.Is(err, storage.ErrorPolicyNotFound) {
+			log.Warn("policy not found", slog.Int("appID", appID), slog.String("policy", name))
+			return fmt.Errorf("%s: %w", op, ErrorPolicyNotFound)
+		}
+		log.Error("failed to delete policy", "error", err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("policy deleted", slog.Int("appID", appID), slog.String("policy", name))
+
+	return nil
+}
+
+// CheckAccess evaluates policies of the app and global policies against input
+//
+// A matching deny policy denies access, otherwise a matching allow policy
+// allows it. Access is denied if no policy matches. Policies failing to
+// evaluate, e.g. on a missing attribute, don't match, so expressions should
+// guard optional attributes with has().
+func (p *Policies) CheckAccess(ctx context.Context, appID int, in policy.Input) (Decision, error) {
+	const op = "policies.CheckAccess"
+	log := p.log.With(slog.String("operation", op))
+
+	policies, err := p.policies.Policies(ctx, appID)
+	if err != nil {
+		log.Error("failed to get policies", "error", err)
+		return Decision{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	decision := p.decide(policies, in)
+	for _, result := range decision.Results {
+		if result.Err != nil {
+			log.Warn("policy evaluation failed", slog.String("policy", result.Policy), "error", result.Err)
+		}
+	}
+
+	return decision, nil
+}
+
+// Evaluate is a dry run of CheckAccess
+//
+// Non-empty expression is evaluated as the only allow policy instead of
+// the stored ones, so it can be tested before it's saved.
+func (p *Policies) Evaluate(ctx context.Context, appID int, expression string, in policy.Input) (Decision, error) {
+	const op = "policies.Evaluate"
+
+	if expression == "" {
+		decision, err := p.CheckAccess(ctx, appID, in)
+		if err != nil {
+			return Decision{}, fmt.Errorf("%s: %w", op, err)
+		}
+		return decision, nil
+	}
+
+	if _, err := p.program(expression); err != nil {
+		return Decision{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return p.decide([]models.Policy{{
+		AppID:      appID,
+		Name:       dryRunPolicyName,
+		Effect:     models.PolicyEffectAllow,
+		Expression: expression,
+	}}, in), nil
+}
+
+func (p *Policies) decide(policies []models.Policy, in policy.Input) Decision {
+	var decision Decision
+	var allowedBy, deniedBy string
+	for _, pol := range policies {
+		result := Result{Policy: pol.Name, Effect: pol.Effect}
+
+		prg, err := p.program(pol.Expression)
+		if err == nil {
+			result.Matched, err = prg.Eval(in)
+		}
+		result.Err = err
+		decision.Results = append(decision.Results, result)
+
+		switch {
+		case pol.Effect == models.PolicyEffectDeny && result.Matched:
+			if deniedBy == "" {
+				deniedBy = pol.Name
+			}
+		case pol.Effect == models.PolicyEffectAllow && result.Matched:
+			if allowedBy == "" {
+				allowedBy = pol.Name
+			}
+		}
+	}
+
+	switch {
+	case deniedBy != "":
+		decision.Policy = deniedBy
+	case allowedBy != "":
+		decision.Allowed = true
+		decision.Policy = allowedBy
+	}
+
+	return decision
+}
+
+// program returns compiled expression, compiling it on first use
+func (p *Policies) program(expression string) (*policy.Program, error) {
+	if prg, ok := p.programs.Load(expression); ok {
+		return prg.(*policy.Program), nil
+	}
+
+	prg, err := policy.Compile(expression)
+	if err != nil {
+		return nil, err
+	}
+	p.programs.Store(expression, prg)
+
+	return prg, nil
+}
+
+func (p *Policies) validate(ctx context.Context, log *slog.Logger, pol models.Policy) error {
+	if !nameRe.MatchString(pol.Name) {
+		return ErrorInvalidName
+	}
+	if pol.Effect != models.PolicyEffectAllow && pol.Effect != models.PolicyEffectDeny {
+		return ErrorInvalidEffect
+	}
+	if _, err := p.program(pol.Expression); err != nil {
+		log.Warn("invalid policy expression", "error", err)
+		return err
+	}
+
+	if pol.AppID == 0 {
+		return nil
+	}
+	if _, err := p.appProvider.App(ctx, pol.AppID); err != nil {
+		if errors.Is(err, storage.ErrorAppNotFound) {
+			log.Warn("app not found", slog.Int("appID", pol.AppID))
+			return ErrorInvalidAppID
+		}
+		log.Error("failed to get app", "error", err)
+		return err
+	}
+
+	return nil
+}
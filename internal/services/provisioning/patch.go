@@ -0,0 +1,342 @@
+package provisioning
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/lib/scim"
+)
+
+const (
+	userSchema  = "urn:ietf:params:scim:schemas:core:2.0:user:"
+	groupSchema = "urn:ietf:params:scim:schemas:core:2.0:group:"
+)
+
+// patchUser applies patch operation to the user
+//
+// Attributes of schema extensions are not stored, operations on them are ignored.
+func patchUser(user models.SCIMUser, patch PatchOp) (models.SCIMUser, error) {
+	op, err := patchOp(patch)
+	if err != nil {
+		return models.SCIMUser{}, err
+	}
+
+	if patch.Path == "" {
+		if op == PatchRemove {
+			return models.SCIMUser{}, ErrorInvalidPath
+		}
+		attrs, ok := patch.Value.(map[string]any)
+		if !ok {
+			return models.SCIMUser{}, ErrorInvalidValue
+		}
+		for name, value := range attrs {
+			attr := strings.TrimPrefix(strings.ToLower(name), userSchema)
+			if strings.HasPrefix(attr, "urn:") {
+				continue
+			}
+			if err := setUserAttr(&user, attr, value, false); err != nil {
+				return models.SCIMUser{}, err
+			}
+		}
+		return user, nil
+	}
+
+	path := strings.TrimPrefix(strings.ToLower(patch.Path), userSchema)
+	if strings.HasPrefix(path, "urn:") {
+		return user, nil
+	}
+	p, err := scim.ParsePath(path)
+	if err != nil {
+		return models.SCIMUser{}, ErrorInvalidPath
+	}
+	attr := p.Attr
+	if p.Filter != nil {
+		// only a single email and photo are kept, the filter selects them
+		if attr != "emails" && attr != "photos" {
+			return models.SCIMUser{}, ErrorInvalidPath
+		}
+		if p.Sub != "" {
+			attr += "." + p.Sub
+		}
+	}
+
+	if err := setUserAttr(&user, attr, patch.Value, op == PatchRemove); err != nil {
+		return models.SCIMUser{}, err
+	}
+
+	return user, nil
+}
+
+// setUserAttr sets or removes lower-cased attribute of the user
+func setUserAttr(user *models.SCIMUser, attr string, value any, remove bool) error {
+	if remove {
+		value = nil
+	}
+
+	var err error
+	switch attr {
+	case "username", "emails", "emails.value":
+		if remove {
+			return ErrorInvalidPath
+		}
+		if attr == "emails" {
+			value, err = primaryValue(value)
+			if err != nil {
+				return err
+			}
+		}
+		user.Email, err = stringValue(value)
+	case "active":
+		if remove {
+			return ErrorInvalidPath
+		}
+		user.Active, err = boolValue(value)
+	case "externalid":
+		user.ExternalID, err = stringValue(value)
+	case "displayname", "name.formatted":
+		user.Profile.Name, err = stringValue(value)
+	case "name.givenname":
+		user.Profile.GivenName, err = stringValue(value)
+	case "name.familyname":
+		user.Profile.FamilyName, err = stringValue(value)
+	case "locale":
+		user.Profile.Locale, err = stringValue(value)
+	case "photos", "photos.value":
+		if attr == "photos" {
+			value, err = primaryValue(value)
+			if err != nil {
+				return err
+			}
+		}
+		user.Profile.Picture, err = stringValue(value)
+	case "name":
+		name, ok := value.(map[string]any)
+		if !ok && value != nil {
+			return ErrorInvalidValue
+		}
+		if remove {
+			user.Profile.Name, user.Profile.GivenName, user.Profile.FamilyName = "", "", ""
+		}
+		for sub, v := range name {
+			if err := setUserAttr(user, "name."+strings.ToLower(sub), v, false); err != nil {
+				return err
+			}
+		}
+	default:
+		return ErrorInvalidPath
+	}
+
+	return err
+}
+
+// patchGroup applies patch operation to the group
+func patchGroup(group models.SCIMGroup, patch PatchOp) (models.SCIMGroup, error) {
+	op, err := patchOp(patch)
+	if err != nil {
+		return models.SCIMGroup{}, err
+	}
+
+	if patch.Path == "" {
+		if op == PatchRemove {
+			return models.SCIMGroup{}, ErrorInvalidPath
+		}
+		attrs, ok := patch.Value.(map[string]any)
+		if !ok {
+			return models.SCIMGroup{}, ErrorInvalidValue
+		}
+		for name, value := range attrs {
+			attr := strings.TrimPrefix(strings.ToLower(name), groupSchema)
+			if strings.HasPrefix(attr, "urn:") {
+				continue
+			}
+			if err := setGroupAttr(&group, op, attr, value); err != nil {
+				return models.SCIMGroup{}, err
+			}
+		}
+		return group, nil
+	}
+
+	path := strings.TrimPrefix(strings.ToLower(patch.Path), groupSchema)
+	if strings.HasPrefix(path, "urn:") {
+		return group, nil
+	}
+	p, err := scim.ParsePath(path)
+	if err != nil {
+		return models.SCIMGroup{}, ErrorInvalidPath
+	}
+
+	if p.Filter == nil {
+		if err := setGroupAttr(&group, op, p.Attr, patch.Value); err != nil {
+			return models.SCIMGroup{}, err
+		}
+		return group, nil
+	}
+
+	// members[value eq "id"] selects members to remove
+	if p.Attr != "members" || p.Sub != "" || op != PatchRemove {
+		return models.SCIMGroup{}, ErrorInvalidPath
+	}
+	group.Members = slices.DeleteFunc(slices.Clone(group.Members), func(member models.SCIMMember) bool {
+		return p.Filter.Match(memberValues(member))
+	})
+
+	return group, nil
+}
+
+// setGroupAttr applies operation to lower-cased attribute of the group
+func setGroupAttr(group *models.SCIMGroup, op string, attr string, value any) error {
+	if attr == "members" {
+		return setGroupMembers(group, op, value)
+	}
+	if op == PatchRemove {
+		value = nil
+	}
+
+	var err error
+	switch attr {
+	case "displayname":
+		if op == PatchRemove {
+			return ErrorInvalidPath
+		}
+		group.DisplayName, err = stringValue(value)
+	case "externalid":
+		group.ExternalID, err = stringValue(value)
+	default:
+		return ErrorInvalidPath
+	}
+
+	return err
+}
+
+// setGroupMembers adds, replaces or removes members of the group,
+// remove without a value removes all of them, with a value the listed ones
+func setGroupMembers(group *models.SCIMGroup, op string, value any) error {
+	var members []models.SCIMMember
+	if value != nil {
+		var err error
+		if members, err = memberValue(value); err != nil {
+			return err
+		}
+	}
+
+	switch op {
+	case PatchAdd:
+		group.Members = append(slices.Clone(group.Members), members...)
+	case PatchReplace:
+		group.Members = members
+	case PatchRemove:
+		if members == nil {
+			group.Members = nil
+			return nil
+		}
+		group.Members = slices.DeleteFunc(slices.Clone(group.Members), func(member models.SCIMMember) bool {
+			return slices.ContainsFunc(members, func(m models.SCIMMember) bool { return m.UserID == member.UserID })
+		})
+	}
+
+	return nil
+}
+
+func patchOp(patch PatchOp) (string, error) {
+	op := strings.ToLower(patch.Op)
+	switch op {
+	case PatchAdd, PatchReplace:
+		if patch.Value == nil {
+			return "", ErrorInvalidValue
+		}
+	case PatchRemove:
+	default:
+		return "", ErrorInvalidValue
+	}
+	return op, nil
+}
+
+func memberValues(member models.SCIMMember) scim.Values {
+	return func(attr string) []string {
+		switch attr {
+		case "value":
+			return []string{strconv.FormatInt(member.UserID, 10)}
+		case "display":
+			return []string{member.Email}
+		}
+		return nil
+	}
+}
+
+// memberValue parses a member or a list of members, referenced by "value" holding the user ID
+func memberValue(value any) ([]models.SCIMMember, error) {
+	values, ok := value.([]any)
+	if !ok {
+		values = []any{value}
+	}
+
+	members := make([]models.SCIMMember, 0, len(values))
+	for _, v := range values {
+		member, ok := v.(map[string]any)
+		if !ok {
+			return nil, ErrorInvalidValue
+		}
+		id, ok := member["value"].(string)
+		if !ok {
+			return nil, ErrorInvalidValue
+		}
+		userID, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return nil, ErrorInvalidValue
+		}
+		members = append(members, models.SCIMMember{UserID: userID})
+	}
+
+	return members, nil
+}
+
+// primaryValue returns "value" of the primary item of a multi-valued attribute,
+// or of the first item if none is primary
+func primaryValue(value any) (any, error) {
+	items, ok := value.([]any)
+	if !ok {
+		items = []any{value}
+	}
+
+	var found any
+	for i, item := range items {
+		attrs, ok := item.(map[string]any)
+		if !ok {
+			return nil, ErrorInvalidValue
+		}
+		if primary, _ := attrs["primary"].(bool); primary || i == 0 {
+			found = attrs["value"]
+		}
+	}
+
+	return found, nil
+}
+
+// stringValue returns string value, null is the empty string
+func stringValue(value any) (string, error) {
+	if value == nil {
+		return "", nil
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", ErrorInvalidValue
+	}
+	return s, nil
+}
+
+// boolValue returns boolean value, some clients send them as strings
+func boolValue(value any) (bool, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		b, err := strconv.ParseBool(strings.ToLower(v))
+		if err != nil {
+			return false, ErrorInvalidValue
+		}
+		return b, nil
+	}
+	return false, ErrorInvalidValue
+}
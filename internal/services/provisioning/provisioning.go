@@ -0,0 +1,742 @@
+package provisioning
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/lib/scim"
+	"github.com/Len4i/auth-service/internal/lib/secret"
+	"github.com/Len4i/auth-service/internal/services/storage"
+)
+
+var (
+	ErrorInvalidName        = errors.New("invalid scim token name")
+	ErrorInvalidToken       = errors.New("invalid scim token")
+	ErrorTokenNotFound      = errors.New("scim token not found")
+	ErrorOrgNotFound        = errors.New("org not found")
+	ErrorInvalidUserName    = errors.New("invalid user name")
+	ErrorInvalidDisplayName = errors.New("invalid group display name")
+	ErrorInvalidFilter      = errors.New("invalid filter")
+	ErrorInvalidPath        = errors.New("invalid patch path")
+	ErrorInvalidValue       = errors.New("invalid patch value")
+	ErrorImmutable          = errors.New("attribute can't be changed")
+	ErrorUserNotFound       = errors.New("scim user not found")
+	ErrorUserExists         = errors.New("scim user already exists")
+	ErrorGroupNotFound      = errors.New("scim group not found")
+	ErrorGroupExists        = errors.New("scim group already exists")
+	ErrorMemberNotFound     = errors.New("group member is not a scim user of the org")
+)
+
+const (
+	// tokenType starts every scim token so it's told apart from other
+	// credentials and easy to find by secret scanners
+	tokenType   = "scim_"
+	tokenBytes  = 32
+	maxNameLen  = 64
+	maxValueLen = 256
+	// MaxPageSize caps the number of resources in a page of list results
+	MaxPageSize = 100
+	// lastUsedResolution limits how often using a token is written to storage
+	lastUsedResolution = time.Minute
+)
+
+type SCIMStorage interface {
+	SaveSCIMToken(ctx context.Context, token models.SCIMToken, tokenHash []byte) (id int64, err error)
+	SCIMToken(ctx context.Context, tokenHash []byte) (token models.SCIMToken, err error)
+	SCIMTokens(ctx context.Context, orgID int64) ([]models.SCIMToken, error)
+	TouchSCIMToken(ctx context.Context, id int64, usedAt time.Time) error
+	RevokeSCIMToken(ctx context.Context, orgID int64, id int64) error
+
+	SaveSCIMUser(ctx context.Context, user models.SCIMUser) (userID int64, err error)
+	UpdateSCIMUser(ctx context.Context, user models.SCIMUser) error
+	SCIMUser(ctx context.Context, orgID int64, userID int64) (user models.SCIMUser, err error)
+	SCIMUsers(ctx context.Context, orgID int64) ([]models.SCIMUser, error)
+	DeleteSCIMUser(ctx context.Context, orgID int64, userID int64) error
+
+	SaveSCIMGroup(ctx context.Context, group models.SCIMGroup) (id int64, err error)
+	UpdateSCIMGroup(ctx context.Context, group models.SCIMGroup) error
+	SCIMGroup(ctx context.Context, orgID int64, id int64) (group models.SCIMGroup, err error)
+	SCIMGroups(ctx context.Context, orgID int64) ([]models.SCIMGroup, error)
+	DeleteSCIMGroup(ctx context.Context, orgID int64, id int64) error
+}
+
+type OrgProvider interface {
+	Org(ctx context.Context, id int64) (org models.Org, err error)
+}
+
+// PatchOp is an operation of a SCIM PATCH request, Value is decoded JSON
+type PatchOp struct {
+	Op    string
+	Path  string
+	Value any
+}
+
+// Patch operations, matched case-insensitively
+const (
+	PatchAdd     = "add"
+	PatchReplace = "replace"
+	PatchRemove  = "remove"
+)
+
+type Provisioning struct {
+	log         *slog.Logger
+	scim        SCIMStorage
+	orgProvider OrgProvider
+}
+
+// NewProvisioning creates new service provisioning users and groups of orgs
+// over SCIM, authenticated with bearer tokens of the orgs
+func NewProvisioning(log *slog.Logger, scim SCIMStorage, orgProvider OrgProvider) *Provisioning {
+	return &Provisioning{
+		log:         log,
+		scim:        scim,
+		orgProvider: orgProvider,
+	}
+}
+
+// CreateToken creates scim token of the org and returns it with the token
+//
+// The token is not stored and can't be retrieved later.
+func (p *Provisioning) CreateToken(
+	ctx context.Context,
+	orgID int64,
+	name string,
+	createdBy int64,
+) (models.SCIMToken, string, error) {
+	const op = "provisioning.CreateToken"
+	log := p.log.With(slog.String("operation", op))
+
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxNameLen {
+		return models.SCIMToken{}, "", fmt.Errorf("%s: %w", op, ErrorInvalidName)
+	}
+
+	if _, err := p.orgProvider.Org(ctx, orgID); err != nil {
+		if errors.Is(err, storage.ErrorOrgNotFound) {
+			log.Warn("org not found", slog.Int64("orgID", orgID))
+			return models.SCIMToken{}, "", fmt.Errorf("%s: %w", op, ErrorOrgNotFound)
+		}
+		log.Error("failed to get org", "error", err)
+		return models.SCIMToken{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	plain, err := secret.New(tokenBytes)
+	if err != nil {
+		log.Error("failed to generate scim token", "error", err)
+		return models.SCIMToken{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+	plain = tokenType + plain
+
+	token := models.SCIMToken{
+		OrgID:     orgID,
+		Name:      name,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now(),
+	}
+	token.ID, err = p.scim.SaveSCIMToken(ctx, token, secret.Hash(plain))
+	if err != nil {
+		log.Error("failed to save scim token", "error", err)
+		return models.SCIMToken{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("scim token created", slog.Int64("tokenID", token.ID), slog.Int64("orgID", orgID))
+
+	return token, plain, nil
+}
+
+// ListTokens returns scim tokens of the org, revoked ones only if includeRevoked is set
+func (p *Provisioning) ListTokens(ctx context.Context, orgID int64, includeRevoked bool) ([]models.SCIMToken, error) {
+	const op = "provisioning.ListTokens"
+	log := p.log.With(slog.String("operation", op))
+
+	tokens, err := p.scim.SCIMTokens(ctx, orgID)
+	if err != nil {
+		log.Error("failed to get scim tokens", "error", err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if includeRevoked {
+		return tokens, nil
+	}
+
+	active := tokens[:0]
+	for _, token := range tokens {
+		if !token.Revoked {
+			active = append(active, token)
+		}
+	}
+
+	return active, nil
+}
+
+// RevokeToken revokes scim token of the org
+func (p *Provisioning) RevokeToken(ctx context.Context, orgID int64, id int64) error {
+	const op = "provisioning.RevokeToken"
+	log := p.log.With(slog.String("operation", op))
+
+	if err := p.scim.RevokeSCIMToken(ctx, orgID, id); err != nil {
+		if errors.Is(err, storage.ErrorSCIMTokenNotFound) {
+			log.Warn("scim token not found", slog.Int64("tokenID", id), slog.Int64("orgID", orgID))
+			return fmt.Errorf("%s: %w", op, ErrorTokenNotFound)
+		}
+		log.Error("failed to revoke scim token", "error", err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("scim token revoked", slog.Int64("tokenID", id), slog.Int64("orgID", orgID))
+
+	return nil
+}
+
+// Authenticate returns ID of the org the scim token belongs to
+func (p *Provisioning) Authenticate(ctx context.Context, plain string) (int64, error) {
+	const op = "provisioning.Authenticate"
+	log := p.log.With(slog.String("operation", op))
+
+	if !strings.HasPrefix(plain, tokenType) {
+		return 0, fmt.Errorf("%s: %w", op, ErrorInvalidToken)
+	}
+
+	token, err := p.scim.SCIMToken(ctx, secret.Hash(plain))
+	if err != nil {
+		if errors.Is(err, storage.ErrorSCIMTokenNotFound) {
+			log.Warn("scim token not found")
+			return 0, fmt.Errorf("%s: %w", op, ErrorInvalidToken)
+		}
+		log.Error("failed to get scim token", "error", err)
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if token.Revoked {
+		log.Warn("scim token is revoked", slog.Int64("tokenID", token.ID))
+		return 0, fmt.Errorf("%s: %w", op, ErrorInvalidToken)
+	}
+
+	now := time.Now()
+	if now.Sub(token.LastUsedAt) >= lastUsedResolution {
+		if err := p.scim.TouchSCIMToken(ctx, token.ID, now); err != nil {
+			// not worth failing the request
+			log.Error("failed to update scim token last use", "error", err)
+		}
+	}
+
+	return token.OrgID, nil
+}
+
+// CreateUser provisions user to the org
+//
+// A user with the email who already exists is linked to the org as is if
+// they are its member, ErrorUserExists is returned for users of others.
+// Otherwise a user without a password is created. Active users become
+// members of the org.
+func (p *Provisioning) CreateUser(ctx context.Context, user models.SCIMUser) (models.SCIMUser, error) {
+	const op = "provisioning.CreateUser"
+	log := p.log.With(slog.String("operation", op))
+
+	if err := validateUser(user); err != nil {
+		return models.SCIMUser{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user.CreatedAt = time.Now()
+	user.UpdatedAt = user.CreatedAt
+	userID, err := p.scim.SaveSCIMUser(ctx, user)
+	if err != nil {
+		if errors.Is(err, storage.ErrorSCIMUserExists) {
+			log.Warn("scim user already exists", slog.Int64("orgID", user.OrgID))
+			return models.SCIMUser{}, fmt.Errorf("%s: %w", op, ErrorUserExists)
+		}
+		if errors.Is(err, storage.ErrorUserExists) {
+			log.Warn("user is not a member of the org", slog.Int64("orgID", user.OrgID))
+			return models.SCIMUser{}, fmt.Errorf("%s: %w", op, ErrorUserExists)
+		}
+		log.Error("failed to save scim user", "error", err)
+		return models.SCIMUser{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("scim user created", slog.Int64("orgID", user.OrgID), slog.Int64("userID", userID))
+
+	user, err = p.user(ctx, log, user.OrgID, userID)
+	if err != nil {
+		return models.SCIMUser{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// User returns scim user of the org
+func (p *Provisioning) User(ctx context.Context, orgID int64, userID int64) (models.SCIMUser, error) {
+	const op = "provisioning.User"
+	log := p.log.With(slog.String("operation", op))
+
+	user, err := p.user(ctx, log, orgID, userID)
+	if err != nil {
+		return models.SCIMUser{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// ListUsers returns a page of scim users of the org matching the filter,
+// along with the number of all matching users
+//
+// startIndex is 1-based, count is capped at MaxPageSize.
+func (p *Provisioning) ListUsers(
+	ctx context.Context,
+	orgID int64,
+	filter string,
+	startIndex int,
+	count int,
+) ([]models.SCIMUser, int, error) {
+	const op = "provisioning.ListUsers"
+	log := p.log.With(slog.String("operation", op))
+
+	f, err := scim.ParseFilter(filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w: %w", op, ErrorInvalidFilter, err)
+	}
+
+	users, err := p.scim.SCIMUsers(ctx, orgID)
+	if err != nil {
+		log.Error("failed to get scim users", "error", err)
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	matched := users[:0]
+	for _, user := range users {
+		if f.Match(userValues(user)) {
+			matched = append(matched, user)
+		}
+	}
+
+	return page(matched, startIndex, count), len(matched), nil
+}
+
+// ReplaceUser replaces attributes of scim user of the org
+//
+// Email of users who were not created by the org can't be changed and
+// their profile is kept.
+func (p *Provisioning) ReplaceUser(ctx context.Context, user models.SCIMUser) (models.SCIMUser, error) {
+	const op = "provisioning.ReplaceUser"
+	log := p.log.With(slog.String("operation", op))
+
+	current, err := p.user(ctx, log, user.OrgID, user.UserID)
+	if err != nil {
+		return models.SCIMUser{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err = p.updateUser(ctx, log, current, user)
+	if err != nil {
+		return models.SCIMUser{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// PatchUser applies patch operations to scim user of the org
+//
+// Email of users who were not created by the org can't be changed and
+// their profile is kept.
+func (p *Provisioning) PatchUser(ctx context.Context, orgID int64, userID int64, ops []PatchOp) (models.SCIMUser, error) {
+	const op = "provisioning.PatchUser"
+	log := p.log.With(slog.String("operation", op))
+
+	current, err := p.user(ctx, log, orgID, userID)
+	if err != nil {
+		return models.SCIMUser{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user := current
+	for _, patch := range ops {
+		if user, err = patchUser(user, patch); err != nil {
+			return models.SCIMUser{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	user, err = p.updateUser(ctx, log, current, user)
+	if err != nil {
+		return models.SCIMUser{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// DeleteUser removes scim user from the org and its groups, the user itself is kept
+// and so are owners of the org, only the org manages them
+func (p *Provisioning) DeleteUser(ctx context.Context, orgID int64, userID int64) error {
+	const op = "provisioning.DeleteUser"
+	log := p.log.With(slog.String("operation", op))
+
+	if err := p.scim.DeleteSCIMUser(ctx, orgID, userID); err != nil {
+		if errors.Is(err, storage.ErrorSCIMUserNotFound) {
+			log.Warn("scim user not found", slog.Int64("orgID", orgID), slog.Int64("userID", userID))
+			return fmt.Errorf("%s: %w", op, ErrorUserNotFound)
+		}
+		log.Error("failed to delete scim user", "error", err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("scim user deleted", slog.Int64("orgID", orgID), slog.Int64("userID", userID))
+
+	return nil
+}
+
+// CreateGroup creates scim group of the org, members must be scim users of the org
+func (p *Provisioning) CreateGroup(ctx context.Context, group models.SCIMGroup) (models.SCIMGroup, error) {
+	const op = "provisioning.CreateGroup"
+	log := p.log.With(slog.String("operation", op))
+
+	group, err := normalizeGroup(group)
+	if err != nil {
+		return models.SCIMGroup{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	group.CreatedAt = time.Now()
+	group.UpdatedAt = group.CreatedAt
+	group.ID, err = p.scim.SaveSCIMGroup(ctx, group)
+	if err != nil {
+		if err := groupStorageError(log, err); err != nil {
+			return models.SCIMGroup{}, fmt.Errorf("%s: %w", op, err)
+		}
+		log.Error("failed to save scim group", "error", err)
+		return models.SCIMGroup{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("scim group created", slog.Int64("orgID", group.OrgID), slog.Int64("groupID", group.ID))
+
+	group, err = p.group(ctx, log, group.OrgID, group.ID)
+	if err != nil {
+		return models.SCIMGroup{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return group, nil
+}
+
+// Group returns scim group of the org
+func (p *Provisioning) Group(ctx context.Context, orgID int64, id int64) (models.SCIMGroup, error) {
+	const op = "provisioning.Group"
+	log := p.log.With(slog.String("operation", op))
+
+	group, err := p.group(ctx, log, orgID, id)
+	if err != nil {
+		return models.SCIMGroup{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return group, nil
+}
+
+// ListGroups returns a page of scim groups of the org matching the filter,
+// along with the number of all matching groups
+//
+// startIndex is 1-based, count is capped at MaxPageSize.
+func (p *Provisioning) ListGroups(
+	ctx context.Context,
+	orgID int64,
+	filter string,
+	startIndex int,
+	count int,
+) ([]models.SCIMGroup, int, error) {
+	const op = "provisioning.ListGroups"
+	log := p.log.With(slog.String("operation", op))
+
+	f, err := scim.ParseFilter(filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w: %w", op, ErrorInvalidFilter, err)
+	}
+
+	groups, err := p.scim.SCIMGroups(ctx, orgID)
+	if err != nil {
+		log.Error("failed to get scim groups", "error", err)
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	matched := groups[:0]
+	for _, group := range groups {
+		if f.Match(groupValues(group)) {
+			matched = append(matched, group)
+		}
+	}
+
+	return page(matched, startIndex, count), len(matched), nil
+}
+
+// ReplaceGroup replaces attributes and members of scim group of the org
+func (p *Provisioning) ReplaceGroup(ctx context.Context, group models.SCIMGroup) (models.SCIMGroup, error) {
+	const op = "provisioning.ReplaceGroup"
+	log := p.log.With(slog.String("operation", op))
+
+	group, err := p.updateGroup(ctx, log, group)
+	if err != nil {
+		return models.SCIMGroup{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return group, nil
+}
+
+// PatchGroup applies patch operations to scim group of the org
+func (p *Provisioning) PatchGroup(ctx context.Context, orgID int64, id int64, ops []PatchOp) (models.SCIMGroup, error) {
+	const op = "provisioning.PatchGroup"
+	log := p.log.With(slog.String("operation", op))
+
+	group, err := p.group(ctx, log, orgID, id)
+	if err != nil {
+		return models.SCIMGroup{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, patch := range ops {
+		if group, err = patchGroup(group, patch); err != nil {
+			return models.SCIMGroup{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	group, err = p.updateGroup(ctx, log, group)
+	if err != nil {
+		return models.SCIMGroup{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return group, nil
+}
+
+// DeleteGroup removes scim group of the org
+func (p *Provisioning) DeleteGroup(ctx context.Context, orgID int64, id int64) error {
+	const op = "provisioning.DeleteGroup"
+	log := p.log.With(slog.String("operation", op))
+
+	if err := p.scim.DeleteSCIMGroup(ctx, orgID, id); err != nil {
+		if errors.Is(err, storage.ErrorSCIMGroupNotFound) {
+			log.Warn("scim group not found", slog.Int64("orgID", orgID), slog.Int64("groupID", id))
+			return fmt.Errorf("%s: %w", op, ErrorGroupNotFound)
+		}
+		log.Error("failed to delete scim group", "error", err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("scim group deleted", slog.Int64("orgID", orgID), slog.Int64("groupID", id))
+
+	return nil
+}
+
+func (p *Provisioning) user(ctx context.Context, log *slog.Logger, orgID int64, userID int64) (models.SCIMUser, error) {
+	user, err := p.scim.SCIMUser(ctx, orgID, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrorSCIMUserNotFound) {
+			log.Warn("scim user not found", slog.Int64("orgID", orgID), slog.Int64("userID", userID))
+			return models.SCIMUser{}, ErrorUserNotFound
+		}
+		log.Error("failed to get scim user", "error", err)
+		return models.SCIMUser{}, err
+	}
+	return user, nil
+}
+
+// updateUser stores user replacing current, the result is read back from storage
+func (p *Provisioning) updateUser(
+	ctx context.Context,
+	log *slog.Logger,
+	current models.SCIMUser,
+	user models.SCIMUser,
+) (models.SCIMUser, error) {
+	if err := validateUser(user); err != nil {
+		return models.SCIMUser{}, err
+	}
+	user.Provisioned = current.Provisioned
+	if !user.Provisioned && !strings.EqualFold(user.Email, current.Email) {
+		log.Warn("email of linked user can't be changed", slog.Int64("userID", current.UserID))
+		return models.SCIMUser{}, ErrorImmutable
+	}
+
+	user.UpdatedAt = time.Now()
+	if err := p.scim.UpdateSCIMUser(ctx, user); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrorSCIMUserNotFound):
+			log.Warn("scim user not found", slog.Int64("orgID", user.OrgID), slog.Int64("userID", user.UserID))
+			return models.SCIMUser{}, ErrorUserNotFound
+		case errors.Is(err, storage.ErrorUserExists):
+			log.Warn("email is taken by another user", slog.Int64("userID", user.UserID))
+			return models.SCIMUser{}, ErrorUserExists
+		}
+		log.Error("failed to update scim user", "error", err)
+		return models.SCIMUser{}, err
+	}
+
+	log.Info("scim user updated", slog.Int64("orgID", user.OrgID), slog.Int64("userID", user.UserID))
+
+	return p.user(ctx, log, user.OrgID, user.UserID)
+}
+
+func (p *Provisioning) group(ctx context.Context, log *slog.Logger, orgID int64, id int64) (models.SCIMGroup, error) {
+	group, err := p.scim.SCIMGroup(ctx, orgID, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrorSCIMGroupNotFound) {
+			log.Warn("scim group not found", slog.Int64("orgID", orgID), slog.Int64("groupID", id))
+			return models.SCIMGroup{}, ErrorGroupNotFound
+		}
+		log.Error("failed to get scim group", "error", err)
+		return models.SCIMGroup{}, err
+	}
+	return group, nil
+}
+
+// updateGroup stores group, the result is read back from storage
+func (p *Provisioning) updateGroup(ctx context.Context, log *slog.Logger, group models.SCIMGroup) (models.SCIMGroup, error) {
+	group, err := normalizeGroup(group)
+	if err != nil {
+		return models.SCIMGroup{}, err
+	}
+
+	group.UpdatedAt = time.Now()
+	if err := p.scim.UpdateSCIMGroup(ctx, group); err != nil {
+		if errors.Is(err, storage.ErrorSCIMGroupNotFound) {
+			log.Warn("scim group not found", slog.Int64("orgID", group.OrgID), slog.Int64("groupID", group.ID))
+			return models.SCIMGroup{}, ErrorGroupNotFound
+		}
+		if err := groupStorageError(log, err); err != nil {
+			return models.SCIMGroup{}, err
+		}
+		log.Error("failed to update scim group", "error", err)
+		return models.SCIMGroup{}, err
+	}
+
+	log.Info("scim group updated", slog.Int64("orgID", group.OrgID), slog.Int64("groupID", group.ID))
+
+	return p.group(ctx, log, group.OrgID, group.ID)
+}
+
+// groupStorageError maps expected errors of saving a group, nil if err is unexpected
+func groupStorageError(log *slog.Logger, err error) error {
+	switch {
+	case errors.Is(err, storage.ErrorSCIMGroupExists):
+		log.Warn("scim group already exists")
+		return ErrorGroupExists
+	case errors.Is(err, storage.ErrorSCIMUserNotFound):
+		log.Warn("scim group member not found")
+		return ErrorMemberNotFound
+	}
+	return nil
+}
+
+func validateUser(user models.SCIMUser) error {
+	if user.Email == "" || len(user.Email) > maxValueLen {
+		return ErrorInvalidUserName
+	}
+	if _, err := mail.ParseAddress(user.Email); err != nil {
+		return ErrorInvalidUserName
+	}
+	for _, v := range []string{
+		user.ExternalID, user.Profile.Name, user.Profile.GivenName, user.Profile.FamilyName,
+		user.Profile.Picture, user.Profile.Locale,
+	} {
+		if len(v) > maxValueLen {
+			return ErrorInvalidValue
+		}
+	}
+	return nil
+}
+
+// normalizeGroup validates group and removes duplicate members
+func normalizeGroup(group models.SCIMGroup) (models.SCIMGroup, error) {
+	group.DisplayName = strings.TrimSpace(group.DisplayName)
+	if group.DisplayName == "" || len(group.DisplayName) > maxValueLen {
+		return models.SCIMGroup{}, ErrorInvalidDisplayName
+	}
+	if len(group.ExternalID) > maxValueLen {
+		return models.SCIMGroup{}, ErrorInvalidValue
+	}
+
+	seen := make(map[int64]bool, len(group.Members))
+	members := make([]models.SCIMMember, 0, len(group.Members))
+	for _, member := range group.Members {
+		if !seen[member.UserID] {
+			seen[member.UserID] = true
+			members = append(members, member)
+		}
+	}
+	group.Members = members
+
+	return group, nil
+}
+
+// page returns resources of the page starting at 1-based startIndex
+func page[T any](resources []T, startIndex int, count int) []T {
+	count = min(max(count, 0), MaxPageSize)
+	start := max(startIndex, 1) - 1
+	if start >= len(resources) {
+		return nil
+	}
+	return resources[start:min(start+count, len(resources))]
+}
+
+// userValues returns values of filter attributes of the user
+func userValues(user models.SCIMUser) scim.Values {
+	groups := make([]string, 0, len(user.Groups))
+	groupNames := make([]string, 0, len(user.Groups))
+	for _, group := range user.Groups {
+		groups = append(groups, strconv.FormatInt(group.ID, 10))
+		groupNames = append(groupNames, group.DisplayName)
+	}
+
+	return func(attr string) []string {
+		switch attr {
+		case "id":
+			return []string{strconv.FormatInt(user.UserID, 10)}
+		case "username", "emails", "emails.value":
+			return []string{user.Email}
+		case "externalid":
+			return []string{user.ExternalID}
+		case "active":
+			return []string{strconv.FormatBool(user.Active)}
+		case "displayname", "name.formatted":
+			return []string{user.Profile.Name}
+		case "name.givenname":
+			return []string{user.Profile.GivenName}
+		case "name.familyname":
+			return []string{user.Profile.FamilyName}
+		case "locale":
+			return []string{user.Profile.Locale}
+		case "groups", "groups.value":
+			return groups
+		case "groups.display":
+			return groupNames
+		case "meta.created":
+			return []string{user.CreatedAt.UTC().Format(time.RFC3339)}
+		case "meta.lastmodified":
+			return []string{user.UpdatedAt.UTC().Format(time.RFC3339)}
+		}
+		return nil
+	}
+}
+
+// groupValues returns values of filter attributes of the group
+func groupValues(group models.SCIMGroup) scim.Values {
+	members := make([]string, 0, len(group.Members))
+	memberNames := make([]string, 0, len(group.Members))
+	for _, member := range group.Members {
+		members = append(members, strconv.FormatInt(member.UserID, 10))
+		memberNames = append(memberNames, member.Email)
+	}
+
+	return func(attr string) []string {
+		switch attr {
+		case "id":
+			return []string{strconv.FormatInt(group.ID, 10)}
+		case "displayname":
+			return []string{group.DisplayName}
+		case "externalid":
+			return []string{group.ExternalID}
+		case "members", "members.value":
+			return members
+		case "members.display":
+			return memberNames
+		case "meta.created":
+			return []string{group.CreatedAt.UTC().Format(time.RFC3339)}
+		case "meta.lastmodified":
+			return []string{group.UpdatedAt.UTC().Format(time.RFC3339)}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,273 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/services/storage"
+)
+
+var (
+	ErrorInvalidRoleName   = errors.New("invalid role name")
+	ErrorInvalidPermission = errors.New("invalid permission")
+	ErrorInvalidAppID      = errors.New("invalid app id")
+	ErrorUserNotFound      = errors.New("user not found")
+	ErrorRoleNotFound      = errors.New("role not found")
+	ErrorRoleExists        = errors.New("role already exists")
+	ErrorRoleNotAssigned   = errors.New("role is not assigned")
+	ErrorBuiltinRole       = errors.New("built-in role can't be deleted")
+)
+
+var (
+	roleNameRe   = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,63}$`)
+	permissionRe = regexp.MustCompile(`^([a-z0-9][a-z0-9_.:-]{0,127}|\*)$`)
+)
+
+type RoleStorage interface {
+	SaveRole(ctx context.Context, role models.Role) (id int64, err error)
+	Role(ctx context.Context, appID int, name string) (role models.Role, err error)
+	Roles(ctx context.Context, appID int) ([]models.Role, error)
+	UserRoles(ctx context.Context, userID int64, appID int) ([]models.Role, error)
+	DeleteRole(ctx context.Context, id int64) error
+	AssignRole(ctx context.Context, userID int64, roleID int64, now time.Time) error
+	RevokeRole(ctx context.Context, userID int64, roleID int64) error
+	HasPermission(ctx context.Context, userID int64, appID int, permission string) (bool, error)
+}
+
+type UserProvider interface {
+	UserByID(ctx context.Context, id int64) (user models.User, err error)
+}
+
+type AppProvider interface {
+	App(ctx context.Context, appID int) (app models.App, err error)
+}
+
+type RBAC struct {
+	log          *slog.Logger
+	roles        RoleStorage
+	userProvider UserProvider
+	appProvider  AppProvider
+}
+
+// NewRBAC creates new role-based access control service
+func NewRBAC(log *slog.Logger, roles RoleStorage, userProvider UserProvider, appProvider AppProvider) *RBAC {
+	return &RBAC{
+		log:          log,
+		roles:        roles,
+		userProvider: userProvider,
+		appProvider:  appProvider,
+	}
+}
+
+// CreateRole creates role of the app, appID 0 creates a global role
+//
+// Permission "*" grants every permission.
+func (r *RBAC) CreateRole(
+	ctx context.Context,
+	appID int,
+	name string,
+	description string,
+	permissions []string,
+) (models.Role, error) {
+	const op = "rbac.CreateRole"
+	log := r.log.With(slog.String("operation", op))
+
+	if !roleNameRe.MatchString(name) {
+		return models.Role{}, fmt.Errorf("%s: %w", op, ErrorInvalidRoleName)
+	}
+	for _, permission := range permissions {
+		if !permissionRe.MatchString(permission) {
+			return models.Role{}, fmt.Errorf("%s: %w", op, ErrorInvalidPermission)
+		}
+	}
+	if err := r.checkApp(ctx, log, appID); err != nil {
+		return models.Role{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	permissions = slices.Clone(permissions)
+	slices.Sort(permissions)
+	permissions = slices.Compact(permissions)
+
+	role := models.Role{
+		AppID:       appID,
+		Name:        name,
+		Description: description,
+		Permissions: permissions,
+		CreatedAt:   time.Now(),
+	}
+
+	var err error
+	role.ID, err = r.roles.SaveRole(ctx, role)
+	if err != nil {
+		if errors.Is(err, storage.ErrorRoleExists) {
+			log.Warn("role already exists", slog.Int("appID", appID), slog.String("role", name))
+			return models.Role{}, fmt.Errorf("%s: %w", op, ErrorRoleExists)
+		}
+		log.Error("failed to save role", "error", err)
+		return models.Role{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("role created", slog.Int64("roleID", role.ID))
+
+	return role, nil
+}
+
+// DeleteRole removes role of the app with all its assignments
+func (r *RBAC) DeleteRole(ctx context.Context, appID int, name string) error {
+	const op = "rbac.DeleteRole"
+	log := r.log.With(slog.String("operation", op))
+
+	role, err := r.role(ctx, log, appID, name)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if role.Builtin {
+		return fmt.Errorf("%s: %w", op, ErrorBuiltinRole)
+	}
+
+	if err := r.roles.DeleteRole(ctx, role.ID); err != nil {
+		if errors.Is(err, storage.ErrorRoleNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrorRoleNotFound)
+		}
+		log.Error("failed to delete role", "error", err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("role deleted", slog.Int64("roleID", role.ID))
+
+	return nil
+}
+
+// ListRoles returns roles defined for the app and global roles
+func (r *RBAC) ListRoles(ctx context.Context, appID int) ([]models.Role, error) {
+	const op = "rbac.ListRoles"
+	log := r.log.With(slog.String("operation", op))
+
+	roles, err := r.roles.Roles(ctx, appID)
+	if err != nil {
+		log.Error("failed to get roles", "error", err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return roles, nil
+}
+
+// UserRoles returns roles of user granted in the app, including global ones
+func (r *RBAC) UserRoles(ctx context.Context, userID int64, appID int) ([]models.Role, error) {
+	const op = "rbac.UserRoles"
+	log := r.log.With(slog.String("operation", op))
+
+	roles, err := r.roles.UserRoles(ctx, userID, appID)
+	if err != nil {
+		log.Error("failed to get user roles", "error", err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return roles, nil
+}
+
+// AssignRole grants role of the app to user, appID 0 refers to a global role
+func (r *RBAC) AssignRole(ctx context.Context, userID int64, appID int, name string) error {
+	const op = "rbac.AssignRole"
+	log := r.log.With(slog.String("operation", op))
+
+	if _, err := r.userProvider.UserByID(ctx, userID); err != nil {
+		if errors.Is(err, storage.ErrorUserNotFound) {
+			log.Warn("user not found", slog.Int64("userID", userID))
+			return fmt.Errorf("%s: %w", op, ErrorUserNotFound)
+		}
+		log.Error("failed to get user", "error", err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	role, err := r.role(ctx, log, appID, name)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := r.roles.AssignRole(ctx, userID, role.ID, time.Now()); err != nil {
+		log.Error("failed to assign role", "error", err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("role assigned", slog.Int64("userID", userID), slog.Int64("roleID", role.ID))
+
+	return nil
+}
+
+// RevokeRole takes role of the app from user, appID 0 refers to a global role
+func (r *RBAC) RevokeRole(ctx context.Context, userID int64, appID int, name string) error {
+	const op = "rbac.RevokeRole"
+	log := r.log.With(slog.String("operation", op))
+
+	role, err := r.role(ctx, log, appID, name)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := r.roles.RevokeRole(ctx, userID, role.ID); err != nil {
+		if errors.Is(err, storage.ErrorRoleNotAssigned) {
+			log.Warn("role is not assigned", slog.Int64("userID", userID), slog.Int64("roleID", role.ID))
+			return fmt.Errorf("%s: %w", op, ErrorRoleNotAssigned)
+		}
+		log.Error("failed to revoke role", "error", err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("role revoked", slog.Int64("userID", userID), slog.Int64("roleID", role.ID))
+
+	return nil
+}
+
+// HasPermission reports whether user has the permission in the app
+// through any of the app roles or global roles
+func (r *RBAC) HasPermission(ctx context.Context, userID int64, appID int, permission string) (bool, error) {
+	const op = "rbac.HasPermission"
+	log := r.log.With(slog.String("operation", op))
+
+	ok, err := r.roles.HasPermission(ctx, userID, appID, permission)
+	if err != nil {
+		log.Error("failed to check permission", "error", err)
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ok, nil
+}
+
+func (r *RBAC) role(ctx context.Context, log *slog.Logger, appID int, name string) (models.Role, error) {
+	role, err := r.roles.Role(ctx, appID, name)
+	if err != nil {
+		if errors.Is(err, storage.ErrorRoleNotFound) {
+			log.Warn("role not found", slog.Int("appID", appID), slog.String("role", name))
+			return models.Role{}, ErrorRoleNotFound
+		}
+		log.Error("failed to get role", "error", err)
+		return models.Role{}, err
+	}
+
+	return role, nil
+}
+
+// checkApp checks that app exists, appID 0 stands for global roles
+func (r *RBAC) checkApp(ctx context.Context, log *slog.Logger, appID int) error {
+	if appID == 0 {
+		return nil
+	}
+
+	if _, err := r.appProvider.App(ctx, appID); err != nil {
+		if errors.Is(err, storage.ErrorAppNotFound) {
+			log.Warn("app not found", slog.Int("appID", appID))
+			return ErrorInvalidAppID
+		}
+		log.Error("failed to get app", "error", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,551 @@
+package relations
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"time"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/services/storage"
+)
+
+var (
+	ErrorInvalidNamespace = errors.New("invalid namespace")
+	ErrorInvalidTuple     = errors.New("invalid relation tuple")
+	ErrorNamespaceUnknown = errors.New("namespace is not defined")
+	ErrorRelationUnknown  = errors.New("relation is not defined")
+	ErrorInvalidToken     = errors.New("invalid consistency token")
+	ErrorTokenAhead       = errors.New("consistency token is ahead of the store")
+	ErrorDepthExceeded    = errors.New("relation graph is too deep")
+)
+
+// maxDepth bounds the number of nested usersets Check and Expand follow,
+// which also stops cycles
+const maxDepth = 25
+
+var (
+	nameRe     = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+	objectIDRe = regexp.MustCompile(`^[A-Za-z0-9_.:@|/=+-]{1,256}$`)
+)
+
+type RelationStorage interface {
+	SaveNamespaces(ctx context.Context, namespaces []models.Namespace) error
+	Namespace(ctx context.Context, name string) (ns models.Namespace, err error)
+	Namespaces(ctx context.Context) ([]models.Namespace, error)
+	WriteRelationTuples(
+		ctx context.Context,
+		writes []models.RelationTuple,
+		deletes []models.RelationTuple,
+		now time.Time,
+	) (revision int64, err error)
+	RelationTuples(ctx context.Context, namespace string, objectID string, relation string) ([]models.RelationTuple, error)
+	RelationRevision(ctx context.Context) (int64, error)
+}
+
+// ExpandNode is a node of the userset tree of a relation
+//
+// Users are users of the node itself, Children are the nested usersets
+// the node is a union of.
+type ExpandNode struct {
+	Namespace string
+	ObjectID  string
+	Relation  string
+	Users     []string
+	Children  []ExpandNode
+}
+
+type Relations struct {
+	log     *slog.Logger
+	storage RelationStorage
+}
+
+// NewRelations creates new relationship-based access control service
+func NewRelations(log *slog.Logger, storage RelationStorage) *Relations {
+	return &Relations{
+		log:     log,
+		storage: storage,
+	}
+}
+
+// WriteSchema creates or replaces namespaces
+//
+// Rewrites may only refer to relations of the same namespace, tuplesets
+// are followed to whatever namespace tuples point to.
+func (r *Relations) WriteSchema(ctx context.Context, namespaces []models.Namespace) error {
+	const op = "relations.WriteSchema"
+	log := r.log.With(slog.String("operation", op))
+
+	now := time.Now()
+	for i, ns := range namespaces {
+		if err := validateNamespace(ns); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		namespaces[i].UpdatedAt = now
+	}
+
+	if err := r.storage.SaveNamespaces(ctx, namespaces); err != nil {
+		log.Error("failed to save namespaces", "error", err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("relation schema written", slog.Int("namespaces", len(namespaces)))
+
+	return nil
+}
+
+// ReadSchema returns all namespaces
+func (r *Relations) ReadSchema(ctx context.Context) ([]models.Namespace, error) {
+	const op = "relations.ReadSchema"
+	log := r.log.With(slog.String("operation", op))
+
+	namespaces, err := r.storage.Namespaces(ctx)
+	if err != nil {
+		log.Error("failed to get namespaces", "error", err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return namespaces, nil
+}
+
+// WriteTuples stores tuples and returns consistency token of the write
+func (r *Relations) WriteTuples(ctx context.Context, tuples []models.RelationTuple) (string, error) {
+	const op = "relations.WriteTuples"
+
+	token, err := r.write(ctx, op, tuples, nil)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return token, nil
+}
+
+// DeleteTuples removes tuples and returns consistency token of the delete
+func (r *Relations) DeleteTuples(ctx context.Context, tuples []models.RelationTuple) (string, error) {
+	const op = "relations.DeleteTuples"
+
+	token, err := r.write(ctx, op, nil, tuples)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return token, nil
+}
+
+func (r *Relations) write(ctx context.Context, op string, writes []models.RelationTuple, deletes []models.RelationTuple) (string, error) {
+	log := r.log.With(slog.String("operation", op))
+
+	schema := r.schema(ctx)
+	for _, t := range append(append([]models.RelationTuple(nil), writes...), deletes...) {
+		if err := schema.validateTuple(t); err != nil {
+			log.Warn("invalid relation tuple", "error", err)
+			return "", err
+		}
+	}
+
+	revision, err := r.storage.WriteRelationTuples(ctx, writes, deletes, time.Now())
+	if err != nil {
+		log.Error("failed to write relation tuples", "error", err)
+		return "", err
+	}
+
+	log.Info("relation tuples written",
+		slog.Int("writes", len(writes)), slog.Int("deletes", len(deletes)), slog.Int64("revision", revision),
+	)
+
+	return encodeToken(revision), nil
+}
+
+// ReadTuples returns tuples of the namespace, empty objectID or relation matches any
+func (r *Relations) ReadTuples(ctx context.Context, namespace string, objectID string, relation string) ([]models.RelationTuple, error) {
+	const op = "relations.ReadTuples"
+	log := r.log.With(slog.String("operation", op))
+
+	tuples, err := r.storage.RelationTuples(ctx, namespace, objectID, relation)
+	if err != nil {
+		log.Error("failed to get relation tuples", "error", err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tuples, nil
+}
+
+// Check reports whether user has relation to the object, following userset rewrites
+//
+// Non-empty token makes sure the check sees all writes up to the one
+// the token was returned by. Returns token of the revision checked at.
+func (r *Relations) Check(
+	ctx context.Context,
+	namespace string,
+	objectID string,
+	relation string,
+	userID string,
+	token string,
+) (allowed bool, checkedAt string, err error) {
+	const op = "relations.Check"
+	log := r.log.With(slog.String("operation", op))
+
+	revision, err := r.revision(ctx, log, token)
+	if err != nil {
+		return false, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	schema := r.schema(ctx)
+	allowed, err = schema.check(namespace, objectID, relation, userID, 0)
+	if err != nil {
+		if !isClientError(err) {
+			log.Error("failed to check relation", "error", err)
+		}
+		return false, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return allowed, encodeToken(revision), nil
+}
+
+// Expand returns userset tree of the relation of the object
+func (r *Relations) Expand(
+	ctx context.Context,
+	namespace string,
+	objectID string,
+	relation string,
+	token string,
+) (tree ExpandNode, expandedAt string, err error) {
+	const op = "relations.Expand"
+	log := r.log.With(slog.String("operation", op))
+
+	revision, err := r.revision(ctx, log, token)
+	if err != nil {
+		return ExpandNode{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	schema := r.schema(ctx)
+	tree, err = schema.expand(namespace, objectID, relation, 0)
+	if err != nil {
+		if !isClientError(err) {
+			log.Error("failed to expand relation", "error", err)
+		}
+		return ExpandNode{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tree, encodeToken(revision), nil
+}
+
+// revision returns the current revision, making sure it's not behind token
+//
+// Tuples are read from the primary store, so reads at the current revision
+// are at least as fresh as any token it returned.
+func (r *Relations) revision(ctx context.Context, log *slog.Logger, token string) (int64, error) {
+	var min int64
+	if token != "" {
+		var err error
+		min, err = decodeToken(token)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	revision, err := r.storage.RelationRevision(ctx)
+	if err != nil {
+		log.Error("failed to get relation revision", "error", err)
+		return 0, err
+	}
+	if revision < min {
+		log.Warn("consistency token is ahead of the store", slog.Int64("token", min), slog.Int64("revision", revision))
+		return 0, ErrorTokenAhead
+	}
+
+	return revision, nil
+}
+
+func isClientError(err error) bool {
+	return errors.Is(err, ErrorNamespaceUnknown) ||
+		errors.Is(err, ErrorRelationUnknown) ||
+		errors.Is(err, ErrorDepthExceeded)
+}
+
+// schema reads namespaces and tuples for a single request,
+// caching namespaces it has seen
+type schema struct {
+	ctx        context.Context
+	storage    RelationStorage
+	namespaces map[string]models.Namespace
+}
+
+func (r *Relations) schema(ctx context.Context) *schema {
+	return &schema{
+		ctx:        ctx,
+		storage:    r.storage,
+		namespaces: make(map[string]models.Namespace),
+	}
+}
+
+func (s *schema) relation(namespace string, relation string) (models.Relation, error) {
+	ns, ok := s.namespaces[namespace]
+	if !ok {
+		var err error
+		ns, err = s.storage.Namespace(s.ctx, namespace)
+		if err != nil {
+			if errors.Is(err, storage.ErrorNamespaceNotFound) {
+				return models.Relation{}, fmt.Errorf("%w: %s", ErrorNamespaceUnknown, namespace)
+			}
+			return models.Relation{}, err
+		}
+		s.namespaces[namespace] = ns
+	}
+
+	for _, rel := range ns.Relations {
+		if rel.Name == relation {
+			return rel, nil
+		}
+	}
+	return models.Relation{}, fmt.Errorf("%w: %s#%s", ErrorRelationUnknown, namespace, relation)
+}
+
+func (s *schema) validateTuple(t models.RelationTuple) error {
+	if !objectIDRe.MatchString(t.ObjectID) {
+		return fmt.Errorf("%w: object id", ErrorInvalidTuple)
+	}
+	if _, err := s.relation(t.Namespace, t.Relation); err != nil {
+		return err
+	}
+
+	if !t.Subject.IsUserset() {
+		if !objectIDRe.MatchString(t.Subject.UserID) {
+			return fmt.Errorf("%w: user id", ErrorInvalidTuple)
+		}
+		return nil
+	}
+
+	if !objectIDRe.MatchString(t.Subject.ObjectID) {
+		return fmt.Errorf("%w: subject object id", ErrorInvalidTuple)
+	}
+	if t.Subject.Relation == "" {
+		// object itself, e.g. parent folder of a document
+		if _, ok := s.namespaces[t.Subject.Namespace]; ok {
+			return nil
+		}
+		if _, err := s.storage.Namespace(s.ctx, t.Subject.Namespace); err != nil {
+			if errors.Is(err, storage.ErrorNamespaceNotFound) {
+				return fmt.Errorf("%w: %s", ErrorNamespaceUnknown, t.Subject.Namespace)
+			}
+			return err
+		}
+		return nil
+	}
+	_, err := s.relation(t.Subject.Namespace, t.Subject.Relation)
+	return err
+}
+
+// rewrite returns usersets relation is a union of
+func rewrite(rel models.Relation) []models.Userset {
+	if len(rel.Rewrite) == 0 {
+		return []models.Userset{{This: true}}
+	}
+	return rel.Rewrite
+}
+
+func (s *schema) check(namespace string, objectID string, relation string, userID string, depth int) (bool, error) {
+	if depth > maxDepth {
+		return false, ErrorDepthExceeded
+	}
+
+	rel, err := s.relation(namespace, relation)
+	if err != nil {
+		return false, err
+	}
+
+	for _, userset := range rewrite(rel) {
+		var ok bool
+		switch {
+		case userset.This:
+			ok, err = s.checkThis(namespace, objectID, relation, userID, depth)
+		case userset.ComputedUserset != "":
+			ok, err = s.check(namespace, objectID, userset.ComputedUserset, userID, depth+1)
+		case userset.TupleToUserset != nil:
+			ok, err = s.checkTupleToUserset(namespace, objectID, *userset.TupleToUserset, userID, depth)
+		}
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (s *schema) checkThis(namespace string, objectID string, relation string, userID string, depth int) (bool, error) {
+	tuples, err := s.storage.RelationTuples(s.ctx, namespace, objectID, relation)
+	if err != nil {
+		return false, err
+	}
+
+	for _, t := range tuples {
+		if t.Subject.UserID == userID {
+			return true, nil
+		}
+	}
+	for _, t := range tuples {
+		if !t.Subject.IsUserset() || t.Subject.Relation == "" {
+			continue
+		}
+		ok, err := s.check(t.Subject.Namespace, t.Subject.ObjectID, t.Subject.Relation, userID, depth+1)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+
+	return false, nil
+}
+
+func (s *schema) checkTupleToUserset(
+	namespace string,
+	objectID string,
+	ttu models.TupleToUserset,
+	userID string,
+	depth int,
+) (bool, error) {
+	tuples, err := s.storage.RelationTuples(s.ctx, namespace, objectID, ttu.Tupleset)
+	if err != nil {
+		return false, err
+	}
+
+	for _, t := range tuples {
+		if !t.Subject.IsUserset() {
+			continue
+		}
+		ok, err := s.check(t.Subject.Namespace, t.Subject.ObjectID, ttu.ComputedUserset, userID, depth+1)
+		if errors.Is(err, ErrorRelationUnknown) {
+			// related object of another type may not have the relation
+			continue
+		}
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+
+	return false, nil
+}
+
+func (s *schema) expand(namespace string, objectID string, relation string, depth int) (ExpandNode, error) {
+	if depth > maxDepth {
+		return ExpandNode{}, ErrorDepthExceeded
+	}
+
+	rel, err := s.relation(namespace, relation)
+	if err != nil {
+		return ExpandNode{}, err
+	}
+
+	node := ExpandNode{Namespace: namespace, ObjectID: objectID, Relation: relation}
+	for _, userset := range rewrite(rel) {
+		switch {
+		case userset.This:
+			tuples, err := s.storage.RelationTuples(s.ctx, namespace, objectID, relation)
+			if err != nil {
+				return ExpandNode{}, err
+			}
+			for _, t := range tuples {
+				if !t.Subject.IsUserset() {
+					node.Users = append(node.Users, t.Subject.UserID)
+					continue
+				}
+				if t.Subject.Relation == "" {
+					continue
+				}
+				child, err := s.expand(t.Subject.Namespace, t.Subject.ObjectID, t.Subject.Relation, depth+1)
+				if err != nil {
+					return ExpandNode{}, err
+				}
+				node.Children = append(node.Children, child)
+			}
+		case userset.ComputedUserset != "":
+			child, err := s.expand(namespace, objectID, userset.ComputedUserset, depth+1)
+			if err != nil {
+				return ExpandNode{}, err
+			}
+			node.Children = append(node.Children, child)
+		case userset.TupleToUserset != nil:
+			tuples, err := s.storage.RelationTuples(s.ctx, namespace, objectID, userset.TupleToUserset.Tupleset)
+			if err != nil {
+				return ExpandNode{}, err
+			}
+			for _, t := range tuples {
+				if !t.Subject.IsUserset() {
+					continue
+				}
+				child, err := s.expand(t.Subject.Namespace, t.Subject.ObjectID, userset.TupleToUserset.ComputedUserset, depth+1)
+				if errors.Is(err, ErrorRelationUnknown) {
+					continue
+				}
+				if err != nil {
+					return ExpandNode{}, err
+				}
+				node.Children = append(node.Children, child)
+			}
+		}
+	}
+
+	return node, nil
+}
+
+func validateNamespace(ns models.Namespace) error {
+	if !nameRe.MatchString(ns.Name) {
+		return fmt.Errorf("%w: name %q", ErrorInvalidNamespace, ns.Name)
+	}
+
+	relations := make(map[string]bool, len(ns.Relations))
+	for _, rel := range ns.Relations {
+		if !nameRe.MatchString(rel.Name) || relations[rel.Name] {
+			return fmt.Errorf("%w: relation %q of %s", ErrorInvalidNamespace, rel.Name, ns.Name)
+		}
+		relations[rel.Name] = true
+	}
+
+	for _, rel := range ns.Relations {
+		for _, userset := range rel.Rewrite {
+			set := 0
+			if userset.This {
+				set++
+			}
+			if userset.ComputedUserset != "" {
+				set++
+				if !relations[userset.ComputedUserset] {
+					return fmt.Errorf("%w: %s#%s refers to unknown relation %q",
+						ErrorInvalidNamespace, ns.Name, rel.Name, userset.ComputedUserset)
+				}
+			}
+			if ttu := userset.TupleToUserset; ttu != nil {
+				set++
+				if !relations[ttu.Tupleset] || !nameRe.MatchString(ttu.ComputedUserset) {
+					return fmt.Errorf("%w: %s#%s has invalid tuple to userset",
+						ErrorInvalidNamespace, ns.Name, rel.Name)
+				}
+			}
+			if set != 1 {
+				return fmt.Errorf("%w: %s#%s userset must set exactly one rewrite",
+					ErrorInvalidNamespace, ns.Name, rel.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// encodeToken returns opaque consistency token of revision
+func encodeToken(revision int64) string {
+	return base64.RawURLEncoding.EncodeToString(binary.BigEndian.AppendUint64(nil, uint64(revision)))
+}
+
+func decodeToken(token string) (int64, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(b) != 8 {
+		return 0, ErrorInvalidToken
+	}
+	return int64(binary.BigEndian.Uint64(b)), nil
+}
@@ -0,0 +1,292 @@
+package sessions
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/lib/jwt"
+	"github.com/Len4i/auth-service/internal/lib/secret"
+	"github.com/Len4i/auth-service/internal/services/storage"
+)
+
+var (
+	ErrorInvalidSession   = errors.New("invalid session")
+	ErrorInvalidCSRFToken = errors.New("invalid csrf token")
+	ErrorInvalidLogin     = errors.New("login is not valid")
+)
+
+const (
+	tokenBytes = 32
+	csrfBytes  = 32
+)
+
+type SessionStorage interface {
+	SaveBrowserSession(ctx context.Context, session models.BrowserSession, tokenHash []byte, now time.Time) (id int64, err error)
+	BrowserSession(ctx context.Context, tokenHash []byte) (models.BrowserSession, error)
+	DeleteBrowserSession(ctx context.Context, id int64) error
+}
+
+// Authenticator logs users in with their credentials and second factor
+type Authenticator interface {
+	Login(ctx context.Context, email string, password string, appID int, orgID int64) (token string, err error)
+	VerifyMFA(ctx context.Context, challenge string, code string) (token string, err error)
+}
+
+// TokenVerifier verifies tokens of completed logins
+type TokenVerifier interface {
+	VerifyToken(ctx context.Context, token string) (jwt.Claims, error)
+}
+
+// RoleProvider returns roles users currently hold in apps
+type RoleProvider interface {
+	UserRoles(ctx context.Context, userID int64, appID int) ([]models.Role, error)
+}
+
+// MemberProvider returns org memberships of users
+type MemberProvider interface {
+	OrgMember(ctx context.Context, orgID int64, userID int64) (models.OrgMember, error)
+}
+
+type Sessions struct {
+	log      *slog.Logger
+	sessions SessionStorage
+	auth     Authenticator
+	tokens   TokenVerifier
+	roles    RoleProvider
+	members  MemberProvider
+	ttl      time.Duration
+}
+
+// NewSessions creates new service of server-side sessions of first-party
+// web apps, which keep the session token in a cookie instead of a JWT
+//
+// Sessions expire ttl after login. Roles and org role of a session are
+// read again whenever it's used, so changes apply to running sessions.
+func NewSessions(
+	log *slog.Logger,
+	sessions SessionStorage,
+	auth Authenticator,
+	tokens TokenVerifier,
+	roles RoleProvider,
+	members MemberProvider,
+	ttl time.Duration,
+) *Sessions {
+	return &Sessions{
+		log:      log,
+		sessions: sessions,
+		auth:     auth,
+		tokens:   tokens,
+		roles:    roles,
+		members:  members,
+		ttl:      ttl,
+	}
+}
+
+// Login logs user in to the app and starts a session, returning
+// the session and its token
+//
+// Errors of the login are returned as is, e.g. *auth.MFARequiredError
+// if the user has to complete the second factor with VerifyMFA.
+func (s *Sessions) Login(
+	ctx context.Context,
+	email string,
+	password string,
+	appID int,
+	orgID int64,
+) (models.BrowserSession, string, error) {
+	const op = "sessions.Login"
+	log := s.log.With(slog.String("operation", op))
+
+	loginToken, err := s.auth.Login(ctx, email, password, appID, orgID)
+	if err != nil {
+		return models.BrowserSession{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	session, token, err := s.start(ctx, log, loginToken)
+	if err != nil {
+		return models.BrowserSession{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return session, token, nil
+}
+
+// VerifyMFA completes the second factor of a login and starts a session,
+// returning the session and its token
+func (s *Sessions) VerifyMFA(ctx context.Context, challenge string, code string) (models.BrowserSession, string, error) {
+	const op = "sessions.VerifyMFA"
+	log := s.log.With(slog.String("operation", op))
+
+	loginToken, err := s.auth.VerifyMFA(ctx, challenge, code)
+	if err != nil {
+		return models.BrowserSession{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	session, token, err := s.start(ctx, log, loginToken)
+	if err != nil {
+		return models.BrowserSession{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return session, token, nil
+}
+
+// start stores session of the login the token is the result of
+func (s *Sessions) start(ctx context.Context, log *slog.Logger, loginToken string) (models.BrowserSession, string, error) {
+	claims, err := s.tokens.VerifyToken(ctx, loginToken)
+	if err != nil || claims.UserID == 0 {
+		log.Warn("login token is not valid", "error", err)
+		return models.BrowserSession{}, "", ErrorInvalidLogin
+	}
+
+	token, err := secret.New(tokenBytes)
+	if err != nil {
+		log.Error("failed to generate session token", "error", err)
+		return models.BrowserSession{}, "", err
+	}
+	csrfToken, err := secret.New(csrfBytes)
+	if err != nil {
+		log.Error("failed to generate csrf token", "error", err)
+		return models.BrowserSession{}, "", err
+	}
+
+	now := time.Now()
+	session := models.BrowserSession{
+		CSRFToken: csrfToken,
+		UserID:    claims.UserID,
+		Email:     claims.Email,
+		AppID:     claims.AppID,
+		Roles:     claims.Roles,
+		OrgID:     claims.OrgID,
+		OrgRole:   claims.OrgRole,
+		Methods:   claims.Methods,
+		Level:     claims.Level,
+		AuthTime:  claims.Time,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.ttl),
+	}
+	session.ID, err = s.sessions.SaveBrowserSession(ctx, session, secret.Hash(token), now)
+	if err != nil {
+		log.Error("failed to save session", "error", err)
+		return models.BrowserSession{}, "", err
+	}
+
+	log.Info("session started", slog.Int64("userID", session.UserID), slog.Int("appID", session.AppID))
+
+	return session, token, nil
+}
+
+// Session returns the session of the token
+//
+// Returns ErrorInvalidSession if it's not known or has expired.
+func (s *Sessions) Session(ctx context.Context, token string) (models.BrowserSession, error) {
+	const op = "sessions.Session"
+	log := s.log.With(slog.String("operation", op))
+
+	session, err := s.session(ctx, log, token)
+	if err != nil {
+		return models.BrowserSession{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return session, nil
+}
+
+// Logout ends the session of the token, csrfToken must be the one
+// issued with the session
+func (s *Sessions) Logout(ctx context.Context, token string, csrfToken string) error {
+	const op = "sessions.Logout"
+	log := s.log.With(slog.String("operation", op))
+
+	session, err := s.session(ctx, log, token)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if !ValidCSRF(session, csrfToken) {
+		log.Warn("csrf token doesn't match", slog.Int64("userID", session.UserID))
+		return fmt.Errorf("%s: %w", op, ErrorInvalidCSRFToken)
+	}
+
+	if err := s.sessions.DeleteBrowserSession(ctx, session.ID); err != nil {
+		if errors.Is(err, storage.ErrorBrowserSessionNotFound) {
+			log.Warn("session already ended", slog.Int64("userID", session.UserID))
+			return fmt.Errorf("%s: %w", op, ErrorInvalidSession)
+		}
+		log.Error("failed to delete session", "error", err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("session ended", slog.Int64("userID", session.UserID), slog.Int("appID", session.AppID))
+
+	return nil
+}
+
+// ValidCSRF reports whether csrfToken is the one issued with the session
+func ValidCSRF(session models.BrowserSession, csrfToken string) bool {
+	return csrfToken != "" && subtle.ConstantTimeCompare([]byte(csrfToken), []byte(session.CSRFToken)) == 1
+}
+
+func (s *Sessions) session(ctx context.Context, log *slog.Logger, token string) (models.BrowserSession, error) {
+	if token == "" {
+		return models.BrowserSession{}, ErrorInvalidSession
+	}
+
+	session, err := s.sessions.BrowserSession(ctx, secret.Hash(token))
+	if err != nil {
+		if errors.Is(err, storage.ErrorBrowserSessionNotFound) {
+			log.Warn("session not found")
+			return models.BrowserSession{}, ErrorInvalidSession
+		}
+		log.Error("failed to get session", "error", err)
+		return models.BrowserSession{}, err
+	}
+	if time.Now().After(session.ExpiresAt) {
+		log.Warn("session expired", slog.Int64("userID", session.UserID))
+		return models.BrowserSession{}, ErrorInvalidSession
+	}
+
+	if err := s.currentRoles(ctx, log, &session); err != nil {
+		return models.BrowserSession{}, err
+	}
+
+	return session, nil
+}
+
+// currentRoles replaces roles and org role the session was started with
+// by the ones the user holds now
+//
+// Returns ErrorInvalidSession if the user is no longer a member of the org
+// of the session.
+func (s *Sessions) currentRoles(ctx context.Context, log *slog.Logger, session *models.BrowserSession) error {
+	roles, err := s.roles.UserRoles(ctx, session.UserID, session.AppID)
+	if err != nil {
+		log.Error("failed to get user roles", "error", err)
+		return err
+	}
+	session.Roles = nil
+	for _, role := range roles {
+		// app role may have the name of a global one
+		if !slices.Contains(session.Roles, role.Name) {
+			session.Roles = append(session.Roles, role.Name)
+		}
+	}
+
+	if session.OrgID == 0 {
+		return nil
+	}
+	member, err := s.members.OrgMember(ctx, session.OrgID, session.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrorOrgMemberNotFound) {
+			log.Warn("user is no longer a member of the org", slog.Int64("userID", session.UserID), slog.Int64("orgID", session.OrgID))
+			return ErrorInvalidSession
+		}
+		log.Error("failed to get org member", "error", err)
+		return err
+	}
+	session.OrgRole = member.Role
+
+	return nil
+}
@@ -6,4 +6,52 @@ var (
 	ErrorUserNotFound = errors.New("user not found")
 	ErrorUserExists   = errors.New("user already exists")
 	ErrorAppNotFound  = errors.New("app not found")
+
+	ErrorInviteNotFound = errors.New("invite not found")
+
+	ErrorFactorNotFound       = errors.New("mfa factor not found")
+	ErrorChallengeNotFound    = errors.New("mfa challenge not found")
+	ErrorMFAAttemptsExhausted = errors.New("mfa attempts exhausted")
+
+	ErrorCredentialNotFound = errors.New("webauthn credential not found")
+	ErrorCredentialExists   = errors.New("webauthn credential already exists")
+	ErrorSessionNotFound    = errors.New("webauthn session not found")
+
+	ErrorLoginCodeNotFound = errors.New("login code not found")
+
+	ErrorRoleNotFound    = errors.New("role not found")
+	ErrorRoleExists      = errors.New("role already exists")
+	ErrorRoleNotAssigned = errors.New("role is not assigned")
+
+	ErrorOrgNotFound       = errors.New("org not found")
+	ErrorOrgExists         = errors.New("org already exists")
+	ErrorOrgMemberNotFound = errors.New("org member not found")
+	ErrorOrgInviteNotFound = errors.New("org invite not found")
+
+	ErrorPolicyNotFound = errors.New("policy not found")
+	ErrorPolicyExists   = errors.New("policy already exists")
+
+	ErrorNamespaceNotFound = errors.New("namespace not found")
+
+	ErrorAPIKeyNotFound = errors.New("api key not found")
+
+	ErrorClientNotFound    = errors.New("client not found")
+	ErrorAssertionReplayed = errors.New("client assertion already used")
+
+	ErrorAuthorizationCodeNotFound = errors.New("authorization code not found")
+	ErrorOAuthGrantNotFound        = errors.New("oauth grant not found")
+	ErrorDeviceCodeNotFound        = errors.New("device code not found")
+
+	ErrorIdentityNotFound       = errors.New("federated identity not found")
+	ErrorIdentityExists         = errors.New("federated identity already exists")
+	ErrorFederatedLoginNotFound = errors.New("federated login not found")
+	ErrorFederatedLinkNotFound  = errors.New("federated link not found")
+
+	ErrorBrowserSessionNotFound = errors.New("browser session not found")
+
+	ErrorSCIMTokenNotFound = errors.New("scim token not found")
+	ErrorSCIMUserNotFound  = errors.New("scim user not found")
+	ErrorSCIMUserExists    = errors.New("scim user already exists")
+	ErrorSCIMGroupNotFound = errors.New("scim group not found")
+	ErrorSCIMGroupExists   = errors.New("scim group already exists")
 )
@@ -0,0 +1,147 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/services/storage"
+)
+
+// SaveAPIKey stores api key with hash of the key
+func (s *Storage) SaveAPIKey(ctx context.Context, key models.APIKey, keyHash []byte) (int64, error) {
+	const op = "storage.sqlite.SaveAPIKey"
+
+	q, err := s.db.Prepare(`INSERT INTO api_keys
+		(user_id, app_id, name, prefix, key_hash, scopes, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := q.ExecContext(ctx,
+		key.UserID, key.AppID, key.Name, key.Prefix, keyHash,
+		strings.Join(key.Scopes, " "), unixOrZero(key.ExpiresAt), key.CreatedAt.Unix(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+const apiKeyColumns = "id, user_id, app_id, name, prefix, scopes, expires_at, last_used_at, revoked, created_at"
+
+func scanAPIKey(row rowScanner, dest ...any) (models.APIKey, error) {
+	var key models.APIKey
+	var scopes string
+	var expiresAt, lastUsedAt, createdAt int64
+	err := row.Scan(append([]any{
+		&key.ID, &key.UserID, &key.AppID, &key.Name, &key.Prefix, &scopes,
+		&expiresAt, &lastUsedAt, &key.Revoked, &createdAt,
+	}, dest...)...)
+	key.Scopes = strings.Fields(scopes)
+	key.ExpiresAt = timeOrZero(expiresAt)
+	key.LastUsedAt = timeOrZero(lastUsedAt)
+	key.CreatedAt = time.Unix(createdAt, 0)
+	return key, err
+}
+
+// APIKey returns api key by its prefix along with hash of the key
+func (s *Storage) APIKey(ctx context.Context, prefix string) (models.APIKey, []byte, error) {
+	const op = "storage.sqlite.APIKey"
+
+	q, err := s.db.Prepare("SELECT " + apiKeyColumns + ", key_hash FROM api_keys WHERE prefix = ?")
+	if err != nil {
+		return models.APIKey{}, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var keyHash []byte
+	key, err := scanAPIKey(q.QueryRowContext(ctx, prefix), &keyHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.APIKey{}, nil, fmt.Errorf("%s: %w", op, storage.ErrorAPIKeyNotFound)
+		}
+		return models.APIKey{}, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return key, keyHash, nil
+}
+
+// APIKeys returns api keys of the user ordered by id
+func (s *Storage) APIKeys(ctx context.Context, userID int64) ([]models.APIKey, error) {
+	const op = "storage.sqlite.APIKeys"
+
+	q, err := s.db.Prepare("SELECT " + apiKeyColumns + " FROM api_keys WHERE user_id = ? ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := q.QueryContext(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return keys, nil
+}
+
+// TouchAPIKey sets the time the key was last used at
+func (s *Storage) TouchAPIKey(ctx context.Context, id int64, usedAt time.Time) error {
+	const op = "storage.sqlite.TouchAPIKey"
+
+	q, err := s.db.Prepare("UPDATE api_keys SET last_used_at = ? WHERE id = ?")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return execAffectingRow(ctx, q, op, storage.ErrorAPIKeyNotFound, usedAt.Unix(), id)
+}
+
+// RevokeAPIKey marks api key of the user as revoked
+func (s *Storage) RevokeAPIKey(ctx context.Context, userID int64, id int64) error {
+	const op = "storage.sqlite.RevokeAPIKey"
+
+	q, err := s.db.Prepare("UPDATE api_keys SET revoked = TRUE WHERE id = ? AND user_id = ?")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return execAffectingRow(ctx, q, op, storage.ErrorAPIKeyNotFound, id, userID)
+}
+
+// unixOrZero stores zero time as 0
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func timeOrZero(unix int64) time.Time {
+	if unix == 0 {
+		return time.Time{}
+	}
+	return time.Unix(unix, 0)
+}
@@ -0,0 +1,107 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/services/storage"
+)
+
+// SaveBrowserSession stores session by hash of its token
+//
+// Sessions expired before now are removed on the way.
+func (s *Storage) SaveBrowserSession(
+	ctx context.Context,
+	session models.BrowserSession,
+	tokenHash []byte,
+	now time.Time,
+) (int64, error) {
+	const op = "storage.sqlite.SaveBrowserSession"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM browser_sessions WHERE expires_at < ?", now.Unix()); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := tx.ExecContext(ctx, `INSERT INTO browser_sessions
+		(token_hash, csrf_token, user_id, app_id, roles, org_id, org_role, amr, acr, auth_time, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		tokenHash, session.CSRFToken, session.UserID, session.AppID, strings.Join(session.Roles, " "),
+		session.OrgID, session.OrgRole, strings.Join(session.Methods, ","), session.Level, unixOrZero(session.AuthTime),
+		session.CreatedAt.Unix(), session.ExpiresAt.Unix(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// BrowserSession returns session by hash of its token, along with
+// the email of its user
+func (s *Storage) BrowserSession(ctx context.Context, tokenHash []byte) (models.BrowserSession, error) {
+	const op = "storage.sqlite.BrowserSession"
+
+	q, err := s.db.Prepare(`SELECT
+		s.id, s.csrf_token, s.user_id, u.email, s.app_id, s.roles, s.org_id, s.org_role, s.amr, s.acr, s.auth_time,
+		s.created_at, s.expires_at
+		FROM browser_sessions s JOIN users u ON u.id = s.user_id
+		WHERE s.token_hash = ?`)
+	if err != nil {
+		return models.BrowserSession{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var session models.BrowserSession
+	var roles, amr string
+	var authTime, createdAt, expiresAt int64
+	err = q.QueryRowContext(ctx, tokenHash).Scan(
+		&session.ID, &session.CSRFToken, &session.UserID, &session.Email, &session.AppID, &roles, &session.OrgID,
+		&session.OrgRole, &amr, &session.Level, &authTime, &createdAt, &expiresAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.BrowserSession{}, fmt.Errorf("%s: %w", op, storage.ErrorBrowserSessionNotFound)
+		}
+		return models.BrowserSession{}, fmt.Errorf("%s: %w", op, err)
+	}
+	session.Roles = strings.Fields(roles)
+	if amr != "" {
+		session.Methods = strings.Split(amr, ",")
+	}
+	session.AuthTime = timeOrZero(authTime)
+	session.CreatedAt = time.Unix(createdAt, 0)
+	session.ExpiresAt = time.Unix(expiresAt, 0)
+
+	return session, nil
+}
+
+// DeleteBrowserSession removes session once the user logs out
+func (s *Storage) DeleteBrowserSession(ctx context.Context, id int64) error {
+	const op = "storage.sqlite.DeleteBrowserSession"
+
+	q, err := s.db.Prepare("DELETE FROM browser_sessions WHERE id = ?")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return execAffectingRow(ctx, q, op, storage.ErrorBrowserSessionNotFound, id)
+}
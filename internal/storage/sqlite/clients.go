@@ -0,0 +1,170 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/services/storage"
+	"github.com/mattn/go-sqlite3"
+)
+
+// SaveClient stores client with hash of its secret
+func (s *Storage) SaveClient(ctx context.Context, client models.Client, secretHash []byte) (int64, error) {
+	const op = "storage.sqlite.SaveClient"
+
+	q, err := s.db.Prepare(`INSERT INTO clients
+		(client_id, app_id, name, secret_hash, public_key, permissions, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := q.ExecContext(ctx,
+		client.ClientID, client.AppID, client.Name, secretHash, client.PublicKey,
+		strings.Join(client.Permissions, " "), client.CreatedAt.Unix(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+const clientColumns = "id, client_id, app_id, name, public_key, permissions, created_at"
+
+func scanClient(row rowScanner, dest ...any) (models.Client, error) {
+	var client models.Client
+	var permissions string
+	var createdAt int64
+	err := row.Scan(append([]any{
+		&client.ID, &client.ClientID, &client.AppID, &client.Name, &client.PublicKey, &permissions, &createdAt,
+	}, dest...)...)
+	client.Permissions = strings.Fields(permissions)
+	client.CreatedAt = time.Unix(createdAt, 0)
+	return client, err
+}
+
+// Client returns client by its client id along with hash of its secret
+func (s *Storage) Client(ctx context.Context, clientID string) (models.Client, []byte, error) {
+	const op = "storage.sqlite.Client"
+
+	q, err := s.db.Prepare("SELECT " + clientColumns + ", secret_hash FROM clients WHERE client_id = ?")
+	if err != nil {
+		return models.Client{}, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var secretHash []byte
+	client, err := scanClient(q.QueryRowContext(ctx, clientID), &secretHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Client{}, nil, fmt.Errorf("%s: %w", op, storage.ErrorClientNotFound)
+		}
+		return models.Client{}, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return client, secretHash, nil
+}
+
+// Clients returns clients of the app ordered by id
+func (s *Storage) Clients(ctx context.Context, appID int) ([]models.Client, error) {
+	const op = "storage.sqlite.Clients"
+
+	q, err := s.db.Prepare("SELECT " + clientColumns + " FROM clients WHERE app_id = ? ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := q.QueryContext(ctx, appID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var clients []models.Client
+	for rows.Next() {
+		client, err := scanClient(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		clients = append(clients, client)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return clients, nil
+}
+
+// UpdateClientSecret replaces hash of the client secret
+func (s *Storage) UpdateClientSecret(ctx context.Context, clientID string, secretHash []byte) error {
+	const op = "storage.sqlite.UpdateClientSecret"
+
+	q, err := s.db.Prepare("UPDATE clients SET secret_hash = ? WHERE client_id = ?")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return execAffectingRow(ctx, q, op, storage.ErrorClientNotFound, secretHash, clientID)
+}
+
+func (s *Storage) DeleteClient(ctx context.Context, clientID string) error {
+	const op = "storage.sqlite.DeleteClient"
+
+	q, err := s.db.Prepare("DELETE FROM clients WHERE client_id = ?")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return execAffectingRow(ctx, q, op, storage.ErrorClientNotFound, clientID)
+}
+
+// UseClientAssertion records assertion id of the client until it expires
+//
+// Returns storage.ErrorAssertionReplayed if it was already used.
+// Expired records are removed on the way.
+func (s *Storage) UseClientAssertion(
+	ctx context.Context,
+	clientID string,
+	jti string,
+	expiresAt time.Time,
+	now time.Time,
+) error {
+	const op = "storage.sqlite.UseClientAssertion"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM client_assertions WHERE expires_at <= ?", now.Unix()); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO client_assertions (client_id, jti, expires_at) VALUES (?, ?, ?)",
+		clientID, jti, expiresAt.Unix(),
+	); err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey {
+			return fmt.Errorf("%s: %w", op, storage.ErrorAssertionReplayed)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
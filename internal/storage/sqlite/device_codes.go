@@ -0,0 +1,153 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/services/storage"
+)
+
+// SaveDeviceCode stores pending device authorization by hashes of its codes
+//
+// Device codes expired before expiredBefore are removed on the way.
+func (s *Storage) SaveDeviceCode(
+	ctx context.Context,
+	code models.DeviceCode,
+	deviceCodeHash []byte,
+	userCodeHash []byte,
+	expiredBefore time.Time,
+) (int64, error) {
+	const op = "storage.sqlite.SaveDeviceCode"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM device_codes WHERE expires_at < ?", expiredBefore.Unix()); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := tx.ExecContext(ctx, `INSERT INTO device_codes
+		(device_code_hash, user_code_hash, app_id, scope, status, poll_interval, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		deviceCodeHash, userCodeHash, code.AppID, code.Scope, models.DeviceCodePending,
+		int64(code.Interval/time.Second), code.ExpiresAt.Unix(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+const deviceCodeColumns = `id, app_id, scope, status, user_id, org_id, amr, acr, auth_time,
+	poll_interval, polled_at, expires_at`
+
+func scanDeviceCode(row rowScanner) (models.DeviceCode, error) {
+	var code models.DeviceCode
+	var amr string
+	var authTime, interval, polledAt, expiresAt int64
+	err := row.Scan(
+		&code.ID, &code.AppID, &code.Scope, &code.Status, &code.UserID, &code.OrgID, &amr, &code.Level, &authTime,
+		&interval, &polledAt, &expiresAt,
+	)
+	if amr != "" {
+		code.Methods = strings.Split(amr, ",")
+	}
+	code.AuthTime = timeOrZero(authTime)
+	code.Interval = time.Duration(interval) * time.Second
+	code.PolledAt = timeOrZero(polledAt)
+	code.ExpiresAt = time.Unix(expiresAt, 0)
+	return code, err
+}
+
+// DeviceCode returns device authorization by hash of its device code
+func (s *Storage) DeviceCode(ctx context.Context, deviceCodeHash []byte) (models.DeviceCode, error) {
+	const op = "storage.sqlite.DeviceCode"
+
+	return s.deviceCode(ctx, op, "device_code_hash", deviceCodeHash)
+}
+
+// DeviceCodeByUserCode returns device authorization by hash of its user code
+func (s *Storage) DeviceCodeByUserCode(ctx context.Context, userCodeHash []byte) (models.DeviceCode, error) {
+	const op = "storage.sqlite.DeviceCodeByUserCode"
+
+	return s.deviceCode(ctx, op, "user_code_hash", userCodeHash)
+}
+
+func (s *Storage) deviceCode(ctx context.Context, op string, column string, hash []byte) (models.DeviceCode, error) {
+	q, err := s.db.Prepare("SELECT " + deviceCodeColumns + " FROM device_codes WHERE " + column + " = ?")
+	if err != nil {
+		return models.DeviceCode{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	code, err := scanDeviceCode(q.QueryRowContext(ctx, hash))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.DeviceCode{}, fmt.Errorf("%s: %w", op, storage.ErrorDeviceCodeNotFound)
+		}
+		return models.DeviceCode{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return code, nil
+}
+
+// UpdateDeviceCodePoll records poll of the device along with the interval
+// it has to wait before the next one
+func (s *Storage) UpdateDeviceCodePoll(ctx context.Context, id int64, polledAt time.Time, interval time.Duration) error {
+	const op = "storage.sqlite.UpdateDeviceCodePoll"
+
+	q, err := s.db.Prepare("UPDATE device_codes SET polled_at = ?, poll_interval = ? WHERE id = ?")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return execAffectingRow(ctx, q, op, storage.ErrorDeviceCodeNotFound, polledAt.Unix(), int64(interval/time.Second), id)
+}
+
+// DecideDeviceCode stores decision of the user on pending device authorization
+//
+// Returns storage.ErrorDeviceCodeNotFound if it's not pending anymore.
+func (s *Storage) DecideDeviceCode(ctx context.Context, code models.DeviceCode) error {
+	const op = "storage.sqlite.DecideDeviceCode"
+
+	q, err := s.db.Prepare(`UPDATE device_codes
+		SET status = ?, user_id = ?, org_id = ?, amr = ?, acr = ?, auth_time = ?
+		WHERE id = ? AND status = ?`)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return execAffectingRow(ctx, q, op, storage.ErrorDeviceCodeNotFound,
+		code.Status, code.UserID, code.OrgID, strings.Join(code.Methods, ","), code.Level, unixOrZero(code.AuthTime),
+		code.ID, models.DeviceCodePending,
+	)
+}
+
+// DeleteDeviceCode removes device authorization once tokens are issued for it
+func (s *Storage) DeleteDeviceCode(ctx context.Context, id int64) error {
+	const op = "storage.sqlite.DeleteDeviceCode"
+
+	q, err := s.db.Prepare("DELETE FROM device_codes WHERE id = ?")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return execAffectingRow(ctx, q, op, storage.ErrorDeviceCodeNotFound, id)
+}
@@ -0,0 +1,274 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/services/storage"
+	"github.com/mattn/go-sqlite3"
+)
+
+// FederatedIdentity returns identity by connector and subject
+func (s *Storage) FederatedIdentity(ctx context.Context, connector string, subject string) (models.FederatedIdentity, error) {
+	const op = "storage.sqlite.FederatedIdentity"
+
+	q, err := s.db.Prepare(`SELECT id, user_id, connector, subject, email, created_at
+		FROM user_identities WHERE connector = ? AND subject = ?`)
+	if err != nil {
+		return models.FederatedIdentity{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var identity models.FederatedIdentity
+	var createdAt int64
+	err = q.QueryRowContext(ctx, connector, subject).Scan(
+		&identity.ID, &identity.UserID, &identity.Connector, &identity.Subject, &identity.Email, &createdAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.FederatedIdentity{}, fmt.Errorf("%s: %w", op, storage.ErrorIdentityNotFound)
+		}
+		return models.FederatedIdentity{}, fmt.Errorf("%s: %w", op, err)
+	}
+	identity.CreatedAt = time.Unix(createdAt, 0)
+
+	return identity, nil
+}
+
+// SaveFederatedIdentity links identity to its user
+func (s *Storage) SaveFederatedIdentity(ctx context.Context, identity models.FederatedIdentity) (int64, error) {
+	const op = "storage.sqlite.SaveFederatedIdentity"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	id, err := saveFederatedIdentity(ctx, tx, identity)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// SaveFederatedUser saves user provisioned on the first login with identity,
+// the user has no password
+func (s *Storage) SaveFederatedUser(ctx context.Context, user models.User, identity models.FederatedIdentity) (int64, error) {
+	const op = "storage.sqlite.SaveFederatedUser"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `INSERT INTO users
+		(email, pass_hash, name, given_name, family_name, picture, locale)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		user.Email, []byte{},
+		user.Profile.Name, user.Profile.GivenName, user.Profile.FamilyName, user.Profile.Picture, user.Profile.Locale,
+	)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrorUserExists)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	identity.UserID, err = res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := saveFederatedIdentity(ctx, tx, identity); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return identity.UserID, nil
+}
+
+func saveFederatedIdentity(ctx context.Context, tx *sql.Tx, identity models.FederatedIdentity) (int64, error) {
+	res, err := tx.ExecContext(ctx, `INSERT INTO user_identities
+		(user_id, connector, subject, email, created_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		identity.UserID, identity.Connector, identity.Subject, identity.Email, identity.CreatedAt.Unix(),
+	)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return 0, storage.ErrorIdentityExists
+		}
+		return 0, err
+	}
+
+	return res.LastInsertId()
+}
+
+// SaveFederatedLogin stores login redirected to an upstream identity provider
+// by hash of its state
+//
+// Expired logins are removed on the way.
+func (s *Storage) SaveFederatedLogin(ctx context.Context, login models.FederatedLogin, stateHash []byte, now time.Time) (int64, error) {
+	const op = "storage.sqlite.SaveFederatedLogin"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM federated_logins WHERE expires_at <= ?", now.Unix()); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := tx.ExecContext(ctx, `INSERT INTO federated_logins
+		(state_hash, connector, app_id, request, nonce, code_verifier, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		stateHash, login.Connector, login.AppID, login.Request, login.Nonce, login.CodeVerifier, login.ExpiresAt.Unix(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// UseFederatedLogin deletes login by hash of its state and returns it,
+// so a callback is accepted once
+func (s *Storage) UseFederatedLogin(ctx context.Context, stateHash []byte) (models.FederatedLogin, error) {
+	const op = "storage.sqlite.UseFederatedLogin"
+
+	q, err := s.db.Prepare(`DELETE FROM federated_logins WHERE state_hash = ?
+		RETURNING id, connector, app_id, request, nonce, code_verifier, expires_at`)
+	if err != nil {
+		return models.FederatedLogin{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var login models.FederatedLogin
+	var expiresAt int64
+	err = q.QueryRowContext(ctx, stateHash).Scan(
+		&login.ID, &login.Connector, &login.AppID, &login.Request, &login.Nonce, &login.CodeVerifier, &expiresAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.FederatedLogin{}, fmt.Errorf("%s: %w", op, storage.ErrorFederatedLoginNotFound)
+		}
+		return models.FederatedLogin{}, fmt.Errorf("%s: %w", op, err)
+	}
+	login.ExpiresAt = time.Unix(expiresAt, 0)
+
+	return login, nil
+}
+
+// SaveFederatedLink stores identity waiting to be linked by hash of its token
+//
+// Expired links are removed on the way.
+func (s *Storage) SaveFederatedLink(ctx context.Context, link models.FederatedLink, tokenHash []byte, now time.Time) (int64, error) {
+	const op = "storage.sqlite.SaveFederatedLink"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM federated_links WHERE expires_at <= ?", now.Unix()); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := tx.ExecContext(ctx, `INSERT INTO federated_links
+		(token_hash, user_id, connector, subject, email, app_id, request, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		tokenHash, link.UserID, link.Connector, link.Subject, link.Email, link.AppID, link.Request, link.ExpiresAt.Unix(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// FederatedLink returns identity waiting to be linked by hash of its token
+func (s *Storage) FederatedLink(ctx context.Context, tokenHash []byte) (models.FederatedLink, error) {
+	const op = "storage.sqlite.FederatedLink"
+
+	q, err := s.db.Prepare(`SELECT id, user_id, connector, subject, email, app_id, request, attempts, expires_at
+		FROM federated_links WHERE token_hash = ?`)
+	if err != nil {
+		return models.FederatedLink{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var link models.FederatedLink
+	var expiresAt int64
+	err = q.QueryRowContext(ctx, tokenHash).Scan(
+		&link.ID, &link.UserID, &link.Connector, &link.Subject, &link.Email, &link.AppID, &link.Request,
+		&link.Attempts, &expiresAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.FederatedLink{}, fmt.Errorf("%s: %w", op, storage.ErrorFederatedLinkNotFound)
+		}
+		return models.FederatedLink{}, fmt.Errorf("%s: %w", op, err)
+	}
+	link.ExpiresAt = time.Unix(expiresAt, 0)
+
+	return link, nil
+}
+
+// ClaimFederatedLinkAttempt counts an attempt to complete link, returns
+// storage.ErrorFederatedLinkNotFound if it is expired at now or has no
+// attempts left
+func (s *Storage) ClaimFederatedLinkAttempt(ctx context.Context, id int64, maxAttempts int, now time.Time) error {
+	const op = "storage.sqlite.ClaimFederatedLinkAttempt"
+
+	q, err := s.db.Prepare(`UPDATE federated_links SET attempts = attempts + 1
+		WHERE id = ? AND attempts < ? AND expires_at > ?`)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return execAffectingRow(ctx, q, op, storage.ErrorFederatedLinkNotFound, id, maxAttempts, now.Unix())
+}
+
+func (s *Storage) DeleteFederatedLink(ctx context.Context, id int64) error {
+	const op = "storage.sqlite.DeleteFederatedLink"
+
+	q, err := s.db.Prepare("DELETE FROM federated_links WHERE id = ?")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return execAffectingRow(ctx, q, op, storage.ErrorFederatedLinkNotFound, id)
+}
@@ -0,0 +1,127 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/services/storage"
+)
+
+const loginCodeColumns = "id, user_id, app_id, code_hash, attempts, expires_at, created_at"
+
+func scanLoginCode(row rowScanner) (models.LoginCode, error) {
+	var code models.LoginCode
+	var expiresAt, createdAt int64
+	err := row.Scan(&code.ID, &code.UserID, &code.AppID, &code.CodeHash, &code.Attempts, &expiresAt, &createdAt)
+	code.ExpiresAt = time.Unix(expiresAt, 0)
+	code.CreatedAt = time.Unix(createdAt, 0)
+	return code, err
+}
+
+// ReplaceLoginCode stores new login code of user, removing the previous ones
+// in a single transaction, so only the last sent code can be redeemed
+func (s *Storage) ReplaceLoginCode(ctx context.Context, code models.LoginCode) (int64, error) {
+	const op = "storage.sqlite.ReplaceLoginCode"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM login_codes WHERE user_id = ?", code.UserID); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := tx.ExecContext(ctx,
+		"INSERT INTO login_codes (user_id, app_id, code_hash, expires_at, created_at) VALUES (?, ?, ?, ?, ?)",
+		code.UserID, code.AppID, code.CodeHash, code.ExpiresAt.Unix(), code.CreatedAt.Unix(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+func (s *Storage) LoginCode(ctx context.Context, id int64) (models.LoginCode, error) {
+	const op = "storage.sqlite.LoginCode"
+
+	q, err := s.db.Prepare("SELECT " + loginCodeColumns + " FROM login_codes WHERE id = ?")
+	if err != nil {
+		return models.LoginCode{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	code, err := scanLoginCode(q.QueryRowContext(ctx, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.LoginCode{}, fmt.Errorf("%s: %w", op, storage.ErrorLoginCodeNotFound)
+		}
+		return models.LoginCode{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return code, nil
+}
+
+// UserLoginCode returns the last login code sent to user
+func (s *Storage) UserLoginCode(ctx context.Context, userID int64) (models.LoginCode, error) {
+	const op = "storage.sqlite.UserLoginCode"
+
+	q, err := s.db.Prepare("SELECT " + loginCodeColumns + " FROM login_codes WHERE user_id = ? ORDER BY id DESC LIMIT 1")
+	if err != nil {
+		return models.LoginCode{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	code, err := scanLoginCode(q.QueryRowContext(ctx, userID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.LoginCode{}, fmt.Errorf("%s: %w", op, storage.ErrorLoginCodeNotFound)
+		}
+		return models.LoginCode{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return code, nil
+}
+
+// ClaimLoginCodeAttempt counts an attempt to redeem login code, returns
+// storage.ErrorLoginCodeNotFound if it is expired at now or has no attempts left
+//
+// The check and the count are a single statement, so concurrent attempts
+// can't exceed maxAttempts.
+func (s *Storage) ClaimLoginCodeAttempt(ctx context.Context, id int64, maxAttempts int, now time.Time) error {
+	const op = "storage.sqlite.ClaimLoginCodeAttempt"
+
+	q, err := s.db.Prepare(`UPDATE login_codes SET attempts = attempts + 1
+		WHERE id = ? AND attempts < ? AND expires_at > ?`)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return execAffectingRow(ctx, q, op, storage.ErrorLoginCodeNotFound, id, maxAttempts, now.Unix())
+}
+
+// DeleteLoginCode removes login code, returns storage.ErrorLoginCodeNotFound
+// if it was already removed, so a code can be redeemed only once
+func (s *Storage) DeleteLoginCode(ctx context.Context, id int64) error {
+	const op = "storage.sqlite.DeleteLoginCode"
+
+	q, err := s.db.Prepare("DELETE FROM login_codes WHERE id = ?")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return execAffectingRow(ctx, q, op, storage.ErrorLoginCodeNotFound, id)
+}
@@ -0,0 +1,348 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/services/storage"
+)
+
+// SaveTOTPFactor stores unconfirmed TOTP factor, replacing existing one
+func (s *Storage) SaveTOTPFactor(ctx context.Context, userID int64, secret []byte, now time.Time) error {
+	const op = "storage.sqlite.SaveTOTPFactor"
+
+	q, err := s.db.Prepare(`INSERT INTO totp_factors (user_id, secret, created_at) VALUES (?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET
+			secret = excluded.secret, confirmed = FALSE, last_step = 0, created_at = excluded.created_at`)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := q.ExecContext(ctx, userID, secret, now.Unix()); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) TOTPFactor(ctx context.Context, userID int64) (models.TOTPFactor, error) {
+	const op = "storage.sqlite.TOTPFactor"
+
+	q, err := s.db.Prepare("SELECT user_id, secret, confirmed, last_step, created_at FROM totp_factors WHERE user_id = ?")
+	if err != nil {
+		return models.TOTPFactor{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var factor models.TOTPFactor
+	var createdAt int64
+	err = q.QueryRowContext(ctx, userID).Scan(&factor.UserID, &factor.Secret, &factor.Confirmed, &factor.LastStep, &createdAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.TOTPFactor{}, fmt.Errorf("%s: %w", op, storage.ErrorFactorNotFound)
+		}
+		return models.TOTPFactor{}, fmt.Errorf("%s: %w", op, err)
+	}
+	factor.CreatedAt = time.Unix(createdAt, 0)
+
+	return factor, nil
+}
+
+// ConfirmTOTPFactor marks factor as confirmed by the code of the given step
+func (s *Storage) ConfirmTOTPFactor(ctx context.Context, userID int64, step int64) error {
+	const op = "storage.sqlite.ConfirmTOTPFactor"
+
+	q, err := s.db.Prepare("UPDATE totp_factors SET confirmed = TRUE, last_step = ? WHERE user_id = ?")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return execAffectingRow(ctx, q, op, storage.ErrorFactorNotFound, step, userID)
+}
+
+// UseTOTPStep records accepted code step
+//
+// Returns false if the step is not greater than the last accepted one (replay).
+func (s *Storage) UseTOTPStep(ctx context.Context, userID int64, step int64) (bool, error) {
+	const op = "storage.sqlite.UseTOTPStep"
+
+	q, err := s.db.Prepare("UPDATE totp_factors SET last_step = ? WHERE user_id = ? AND last_step < ?")
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := q.ExecContext(ctx, step, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affected > 0, nil
+}
+
+func (s *Storage) DeleteTOTPFactor(ctx context.Context, userID int64) error {
+	const op = "storage.sqlite.DeleteTOTPFactor"
+
+	q, err := s.db.Prepare("DELETE FROM totp_factors WHERE user_id = ?")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return execAffectingRow(ctx, q, op, storage.ErrorFactorNotFound, userID)
+}
+
+// SaveMFAChallenge stores challenge with hash of its handle
+func (s *Storage) SaveMFAChallenge(ctx context.Context, challenge models.MFAChallenge, handleHash []byte) (int64, error) {
+	const op = "storage.sqlite.SaveMFAChallenge"
+
+	q, err := s.db.Prepare(`INSERT INTO mfa_challenges
+		(handle_hash, user_id, app_id, org_id, amr, expires_at) VALUES (?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := q.ExecContext(ctx,
+		handleHash, challenge.UserID, challenge.AppID, challenge.OrgID,
+		strings.Join(challenge.Methods, ","), challenge.ExpiresAt.Unix(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+const mfaChallengeColumns = "id, user_id, app_id, org_id, amr, attempts, expires_at"
+
+func scanMFAChallenge(row rowScanner) (models.MFAChallenge, error) {
+	var challenge models.MFAChallenge
+	var amr string
+	var expiresAt int64
+	err := row.Scan(
+		&challenge.ID, &challenge.UserID, &challenge.AppID, &challenge.OrgID, &amr, &challenge.Attempts, &expiresAt,
+	)
+	if amr != "" {
+		challenge.Methods = strings.Split(amr, ",")
+	}
+	challenge.ExpiresAt = time.Unix(expiresAt, 0)
+	return challenge, err
+}
+
+// MFAChallenge returns challenge by hash of its handle
+func (s *Storage) MFAChallenge(ctx context.Context, handleHash []byte) (models.MFAChallenge, error) {
+	const op = "storage.sqlite.MFAChallenge"
+
+	q, err := s.db.Prepare("SELECT " + mfaChallengeColumns + " FROM mfa_challenges WHERE handle_hash = ?")
+	if err != nil {
+		return models.MFAChallenge{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	challenge, err := scanMFAChallenge(q.QueryRowContext(ctx, handleHash))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.MFAChallenge{}, fmt.Errorf("%s: %w", op, storage.ErrorChallengeNotFound)
+		}
+		return models.MFAChallenge{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return challenge, nil
+}
+
+func (s *Storage) MFAChallengeByID(ctx context.Context, id int64) (models.MFAChallenge, error) {
+	const op = "storage.sqlite.MFAChallengeByID"
+
+	q, err := s.db.Prepare("SELECT " + mfaChallengeColumns + " FROM mfa_challenges WHERE id = ?")
+	if err != nil {
+		return models.MFAChallenge{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	challenge, err := scanMFAChallenge(q.QueryRowContext(ctx, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.MFAChallenge{}, fmt.Errorf("%s: %w", op, storage.ErrorChallengeNotFound)
+		}
+		return models.MFAChallenge{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return challenge, nil
+}
+
+// ClaimMFAChallengeAttempt counts an attempt to complete challenge, returns
+// storage.ErrorChallengeNotFound if it is expired at now or has no attempts left
+//
+// The check and the count are a single statement, so concurrent attempts
+// can't exceed maxAttempts.
+func (s *Storage) ClaimMFAChallengeAttempt(ctx context.Context, id int64, maxAttempts int, now time.Time) error {
+	const op = "storage.sqlite.ClaimMFAChallengeAttempt"
+
+	q, err := s.db.Prepare(`UPDATE mfa_challenges SET attempts = attempts + 1
+		WHERE id = ? AND attempts < ? AND expires_at > ?`)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return execAffectingRow(ctx, q, op, storage.ErrorChallengeNotFound, id, maxAttempts, now.Unix())
+}
+
+// DeleteMFAChallenge removes challenge, returns storage.ErrorChallengeNotFound
+// if it was already removed, so a challenge can be completed only once
+func (s *Storage) DeleteMFAChallenge(ctx context.Context, id int64) error {
+	const op = "storage.sqlite.DeleteMFAChallenge"
+
+	q, err := s.db.Prepare("DELETE FROM mfa_challenges WHERE id = ?")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return execAffectingRow(ctx, q, op, storage.ErrorChallengeNotFound, id)
+}
+
+// ReplaceRecoveryCodes removes user recovery codes and stores new ones in a single transaction
+func (s *Storage) ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes [][]byte, now time.Time) error {
+	const op = "storage.sqlite.ReplaceRecoveryCodes"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = ?", userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	q, err := tx.PrepareContext(ctx, "INSERT INTO recovery_codes (user_id, code_hash, created_at) VALUES (?, ?, ?)")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer q.Close()
+
+	for _, codeHash := range codeHashes {
+		if _, err := q.ExecContext(ctx, userID, codeHash, now.Unix()); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// UseRecoveryCode marks unused recovery code as used
+//
+// Returns false if user has no such unused code.
+func (s *Storage) UseRecoveryCode(ctx context.Context, userID int64, codeHash []byte, now time.Time) (bool, error) {
+	const op = "storage.sqlite.UseRecoveryCode"
+
+	q, err := s.db.Prepare(`UPDATE recovery_codes SET used_at = ?
+		WHERE user_id = ? AND code_hash = ? AND used_at IS NULL`)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := q.ExecContext(ctx, now.Unix(), userID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return affected > 0, nil
+}
+
+// RecoveryCodesRemaining returns number of unused recovery codes of user
+func (s *Storage) RecoveryCodesRemaining(ctx context.Context, userID int64) (int, error) {
+	const op = "storage.sqlite.RecoveryCodesRemaining"
+
+	q, err := s.db.Prepare("SELECT COUNT(*) FROM recovery_codes WHERE user_id = ? AND used_at IS NULL")
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var count int
+	if err := q.QueryRowContext(ctx, userID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return count, nil
+}
+
+func (s *Storage) DeleteRecoveryCodes(ctx context.Context, userID int64) error {
+	const op = "storage.sqlite.DeleteRecoveryCodes"
+
+	q, err := s.db.Prepare("DELETE FROM recovery_codes WHERE user_id = ?")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := q.ExecContext(ctx, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ClaimMFAAttempt counts an attempt of user to verify the second factor,
+// returns storage.ErrorMFAAttemptsExhausted if user has maxAttempts in the
+// window ending at now
+//
+// The window starts with the first attempt after the previous one ended.
+// The check and the count are a single statement, so concurrent attempts
+// can't exceed maxAttempts.
+func (s *Storage) ClaimMFAAttempt(
+	ctx context.Context,
+	userID int64,
+	maxAttempts int,
+	window time.Duration,
+	now time.Time,
+) error {
+	const op = "storage.sqlite.ClaimMFAAttempt"
+
+	q, err := s.db.Prepare(`INSERT INTO mfa_attempts (user_id, attempts, window_start) VALUES (?, 1, ?)
+		ON CONFLICT (user_id) DO UPDATE SET
+			attempts = CASE WHEN window_start <= ? THEN 1 ELSE attempts + 1 END,
+			window_start = CASE WHEN window_start <= ? THEN excluded.window_start ELSE window_start END
+		WHERE attempts < ? OR window_start <= ?`)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	expired := now.Add(-window).Unix()
+	return execAffectingRow(
+		ctx, q, op, storage.ErrorMFAAttemptsExhausted, userID, now.Unix(), expired, expired, maxAttempts, expired,
+	)
+}
+
+// ResetMFAAttempts forgets attempts of user, e.g. once one succeeded
+func (s *Storage) ResetMFAAttempts(ctx context.Context, userID int64) error {
+	const op = "storage.sqlite.ResetMFAAttempts"
+
+	q, err := s.db.Prepare("DELETE FROM mfa_attempts WHERE user_id = ?")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := q.ExecContext(ctx, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
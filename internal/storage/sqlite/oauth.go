@@ -0,0 +1,129 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/services/storage"
+)
+
+// SaveAuthorizationCode stores OAuth authorization code by hash
+//
+// Expired codes are removed on the way.
+func (s *Storage) SaveAuthorizationCode(
+	ctx context.Context,
+	code models.AuthorizationCode,
+	codeHash []byte,
+	now time.Time,
+) (int64, error) {
+	const op = "storage.sqlite.SaveAuthorizationCode"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM oauth_codes WHERE expires_at <= ?", now.Unix()); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := tx.ExecContext(ctx, `INSERT INTO oauth_codes
+		(code_hash, app_id, user_id, org_id, redirect_uri, code_challenge, scope, nonce, amr, acr, auth_time, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		codeHash, code.AppID, code.UserID, code.OrgID, code.RedirectURI, code.CodeChallenge, code.Scope, code.Nonce,
+		strings.Join(code.Methods, ","), code.Level, unixOrZero(code.AuthTime), code.ExpiresAt.Unix(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+const authorizationCodeColumns = `id, app_id, user_id, org_id, redirect_uri, code_challenge, scope, nonce,
+	amr, acr, auth_time, expires_at`
+
+// UseAuthorizationCode returns OAuth authorization code by hash and removes it,
+// so that it can't be used again
+func (s *Storage) UseAuthorizationCode(ctx context.Context, codeHash []byte) (models.AuthorizationCode, error) {
+	const op = "storage.sqlite.UseAuthorizationCode"
+
+	q, err := s.db.Prepare("DELETE FROM oauth_codes WHERE code_hash = ? RETURNING " + authorizationCodeColumns)
+	if err != nil {
+		return models.AuthorizationCode{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var code models.AuthorizationCode
+	var amr string
+	var authTime, expiresAt int64
+	err = q.QueryRowContext(ctx, codeHash).Scan(
+		&code.ID, &code.AppID, &code.UserID, &code.OrgID, &code.RedirectURI, &code.CodeChallenge, &code.Scope, &code.Nonce,
+		&amr, &code.Level, &authTime, &expiresAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.AuthorizationCode{}, fmt.Errorf("%s: %w", op, storage.ErrorAuthorizationCodeNotFound)
+		}
+		return models.AuthorizationCode{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if amr != "" {
+		code.Methods = strings.Split(amr, ",")
+	}
+	code.AuthTime = timeOrZero(authTime)
+	code.ExpiresAt = time.Unix(expiresAt, 0)
+
+	return code, nil
+}
+
+// SaveOAuthGrant stores scope the user granted to the app,
+// replacing the previous one
+func (s *Storage) SaveOAuthGrant(ctx context.Context, userID int64, appID int, scope string, now time.Time) error {
+	const op = "storage.sqlite.SaveOAuthGrant"
+
+	q, err := s.db.Prepare(`INSERT INTO oauth_grants (user_id, app_id, scope, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (user_id, app_id) DO UPDATE SET scope = excluded.scope, updated_at = excluded.updated_at`)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := q.ExecContext(ctx, userID, appID, scope, now.Unix()); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// OAuthGrant returns scope the user granted to the app
+func (s *Storage) OAuthGrant(ctx context.Context, userID int64, appID int) (string, error) {
+	const op = "storage.sqlite.OAuthGrant"
+
+	q, err := s.db.Prepare("SELECT scope FROM oauth_grants WHERE user_id = ? AND app_id = ?")
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	var scope string
+	if err := q.QueryRowContext(ctx, userID, appID).Scan(&scope); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", fmt.Errorf("%s: %w", op, storage.ErrorOAuthGrantNotFound)
+		}
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return scope, nil
+}
@@ -0,0 +1,469 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/services/storage"
+	"github.com/mattn/go-sqlite3"
+)
+
+// orgColumns selects org with its allowed apps joined by commas,
+// queries using it must join org_apps and group by orgs.id
+const orgColumns = `orgs.id, orgs.slug, orgs.name, orgs.require_mfa, orgs.allowed_methods, orgs.created_at,
+	COALESCE(GROUP_CONCAT(org_apps.app_id), '')`
+
+func scanOrg(row rowScanner) (models.Org, error) {
+	var org models.Org
+	var allowedMethods, allowedApps string
+	var createdAt int64
+	err := row.Scan(
+		&org.ID, &org.Slug, &org.Name, &org.LoginPolicy.RequireMFA, &allowedMethods, &createdAt, &allowedApps,
+	)
+	if err != nil {
+		return models.Org{}, err
+	}
+
+	org.CreatedAt = time.Unix(createdAt, 0)
+	if allowedMethods != "" {
+		org.LoginPolicy.AllowedMethods = strings.Split(allowedMethods, ",")
+	}
+	if allowedApps != "" {
+		for _, v := range strings.Split(allowedApps, ",") {
+			appID, err := strconv.Atoi(v)
+			if err != nil {
+				return models.Org{}, err
+			}
+			org.AllowedApps = append(org.AllowedApps, appID)
+		}
+		sort.Ints(org.AllowedApps)
+	}
+
+	return org, nil
+}
+
+// SaveOrg stores org with its allowed apps in a single transaction
+func (s *Storage) SaveOrg(ctx context.Context, org models.Org) (int64, error) {
+	const op = "storage.sqlite.SaveOrg"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		"INSERT INTO orgs (slug, name, require_mfa, allowed_methods, created_at) VALUES (?, ?, ?, ?, ?)",
+		org.Slug, org.Name, org.LoginPolicy.RequireMFA, strings.Join(org.LoginPolicy.AllowedMethods, ","),
+		org.CreatedAt.Unix(),
+	)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrorOrgExists)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := insertOrgApps(ctx, tx, id, org.AllowedApps); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// UpdateOrg updates name, login policy and allowed apps of org
+func (s *Storage) UpdateOrg(ctx context.Context, org models.Org) error {
+	const op = "storage.sqlite.UpdateOrg"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		"UPDATE orgs SET name = ?, require_mfa = ?, allowed_methods = ? WHERE id = ?",
+		org.Name, org.LoginPolicy.RequireMFA, strings.Join(org.LoginPolicy.AllowedMethods, ","), org.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrorOrgNotFound)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM org_apps WHERE org_id = ?", org.ID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := insertOrgApps(ctx, tx, org.ID, org.AllowedApps); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func insertOrgApps(ctx context.Context, tx *sql.Tx, orgID int64, appIDs []int) error {
+	for _, appID := range appIDs {
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO org_apps (org_id, app_id) VALUES (?, ?) ON CONFLICT DO NOTHING", orgID, appID,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Storage) Org(ctx context.Context, id int64) (models.Org, error) {
+	const op = "storage.sqlite.Org"
+
+	q, err := s.db.Prepare(`SELECT ` + orgColumns + ` FROM orgs
+		LEFT JOIN org_apps ON org_apps.org_id = orgs.id
+		WHERE orgs.id = ?
+		GROUP BY orgs.id`)
+	if err != nil {
+		return models.Org{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	org, err := scanOrg(q.QueryRowContext(ctx, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Org{}, fmt.Errorf("%s: %w", op, storage.ErrorOrgNotFound)
+		}
+		return models.Org{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return org, nil
+}
+
+// Orgs returns all orgs, or orgs user is a member of if userID is not 0
+func (s *Storage) Orgs(ctx context.Context, userID int64) ([]models.Org, error) {
+	const op = "storage.sqlite.Orgs"
+
+	q, err := s.db.Prepare(`SELECT ` + orgColumns + ` FROM orgs
+		LEFT JOIN org_apps ON org_apps.org_id = orgs.id
+		WHERE ? = 0 OR orgs.id IN (SELECT org_id FROM org_members WHERE user_id = ?)
+		GROUP BY orgs.id ORDER BY orgs.id`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := q.QueryContext(ctx, userID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var orgs []models.Org
+	for rows.Next() {
+		org, err := scanOrg(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		orgs = append(orgs, org)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return orgs, nil
+}
+
+// DeleteOrg removes org with its apps, members, invites and scim data in a single transaction
+func (s *Storage) DeleteOrg(ctx context.Context, id int64) error {
+	const op = "storage.sqlite.DeleteOrg"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		"DELETE FROM scim_group_members WHERE group_id IN (SELECT id FROM scim_groups WHERE org_id = ?)", id,
+	); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	for _, table := range []string{
+		"org_apps", "org_members", "org_invites", "scim_tokens", "scim_users", "scim_groups",
+	} {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE org_id = ?", id); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM orgs WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrorOrgNotFound)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+const orgMemberColumns = "org_members.org_id, org_members.user_id, users.email, org_members.role, org_members.created_at"
+
+func scanOrgMember(row rowScanner) (models.OrgMember, error) {
+	var member models.OrgMember
+	var createdAt int64
+	err := row.Scan(&member.OrgID, &member.UserID, &member.Email, &member.Role, &createdAt)
+	member.CreatedAt = time.Unix(createdAt, 0)
+	return member, err
+}
+
+// SaveOrgMember adds user to org or changes the role of a member
+func (s *Storage) SaveOrgMember(ctx context.Context, member models.OrgMember) error {
+	const op = "storage.sqlite.SaveOrgMember"
+
+	q, err := s.db.Prepare(`INSERT INTO org_members (org_id, user_id, role, created_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (org_id, user_id) DO UPDATE SET role = excluded.role`)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := q.ExecContext(ctx, member.OrgID, member.UserID, member.Role, member.CreatedAt.Unix()); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) OrgMember(ctx context.Context, orgID int64, userID int64) (models.OrgMember, error) {
+	const op = "storage.sqlite.OrgMember"
+
+	q, err := s.db.Prepare(`SELECT ` + orgMemberColumns + ` FROM org_members
+		JOIN users ON users.id = org_members.user_id
+		WHERE org_members.org_id = ? AND org_members.user_id = ?`)
+	if err != nil {
+		return models.OrgMember{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	member, err := scanOrgMember(q.QueryRowContext(ctx, orgID, userID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.OrgMember{}, fmt.Errorf("%s: %w", op, storage.ErrorOrgMemberNotFound)
+		}
+		return models.OrgMember{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return member, nil
+}
+
+func (s *Storage) OrgMembers(ctx context.Context, orgID int64) ([]models.OrgMember, error) {
+	const op = "storage.sqlite.OrgMembers"
+
+	q, err := s.db.Prepare(`SELECT ` + orgMemberColumns + ` FROM org_members
+		JOIN users ON users.id = org_members.user_id
+		WHERE org_members.org_id = ? ORDER BY org_members.user_id`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := q.QueryContext(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var members []models.OrgMember
+	for rows.Next() {
+		member, err := scanOrgMember(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		members = append(members, member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return members, nil
+}
+
+func (s *Storage) DeleteOrgMember(ctx context.Context, orgID int64, userID int64) error {
+	const op = "storage.sqlite.DeleteOrgMember"
+
+	q, err := s.db.Prepare("DELETE FROM org_members WHERE org_id = ? AND user_id = ?")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return execAffectingRow(ctx, q, op, storage.ErrorOrgMemberNotFound, orgID, userID)
+}
+
+// SaveOrgInvite stores org invite with hash of its code
+func (s *Storage) SaveOrgInvite(ctx context.Context, invite models.OrgInvite, codeHash []byte) (int64, error) {
+	const op = "storage.sqlite.SaveOrgInvite"
+
+	q, err := s.db.Prepare(`INSERT INTO org_invites
+		(org_id, code_hash, email, role, expires_at, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := q.ExecContext(ctx,
+		invite.OrgID, codeHash, invite.Email, invite.Role,
+		invite.ExpiresAt.Unix(), invite.CreatedBy, invite.CreatedAt.Unix(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+const orgInviteColumns = "id, org_id, email, role, expires_at, COALESCE(accepted_by, 0), revoked, created_by, created_at"
+
+func scanOrgInvite(row rowScanner) (models.OrgInvite, error) {
+	var invite models.OrgInvite
+	var expiresAt, createdAt int64
+	err := row.Scan(
+		&invite.ID, &invite.OrgID, &invite.Email, &invite.Role, &expiresAt,
+		&invite.AcceptedBy, &invite.Revoked, &invite.CreatedBy, &createdAt,
+	)
+	invite.ExpiresAt = time.Unix(expiresAt, 0)
+	invite.CreatedAt = time.Unix(createdAt, 0)
+	return invite, err
+}
+
+func (s *Storage) OrgInvites(ctx context.Context, orgID int64) ([]models.OrgInvite, error) {
+	const op = "storage.sqlite.OrgInvites"
+
+	q, err := s.db.Prepare("SELECT " + orgInviteColumns + " FROM org_invites WHERE org_id = ? ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := q.QueryContext(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var invites []models.OrgInvite
+	for rows.Next() {
+		invite, err := scanOrgInvite(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		invites = append(invites, invite)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return invites, nil
+}
+
+// OrgInviteByCode returns org invite by hash of its code
+func (s *Storage) OrgInviteByCode(ctx context.Context, codeHash []byte) (models.OrgInvite, error) {
+	const op = "storage.sqlite.OrgInviteByCode"
+
+	q, err := s.db.Prepare("SELECT " + orgInviteColumns + " FROM org_invites WHERE code_hash = ?")
+	if err != nil {
+		return models.OrgInvite{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	invite, err := scanOrgInvite(q.QueryRowContext(ctx, codeHash))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.OrgInvite{}, fmt.Errorf("%s: %w", op, storage.ErrorOrgInviteNotFound)
+		}
+		return models.OrgInvite{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return invite, nil
+}
+
+func (s *Storage) RevokeOrgInvite(ctx context.Context, orgID int64, id int64) error {
+	const op = "storage.sqlite.RevokeOrgInvite"
+
+	q, err := s.db.Prepare("UPDATE org_invites SET revoked = TRUE WHERE org_id = ? AND id = ?")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return execAffectingRow(ctx, q, op, storage.ErrorOrgInviteNotFound, orgID, id)
+}
+
+// AcceptOrgInvite marks pending invite accepted by user and adds user to the org
+// in a single transaction, an existing membership is kept as is
+//
+// Returns storage.ErrorOrgInviteNotFound if invite was accepted or revoked concurrently.
+func (s *Storage) AcceptOrgInvite(ctx context.Context, invite models.OrgInvite, userID int64, now time.Time) error {
+	const op = "storage.sqlite.AcceptOrgInvite"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		"UPDATE org_invites SET accepted_by = ? WHERE id = ? AND accepted_by IS NULL AND NOT revoked",
+		userID, invite.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrorOrgInviteNotFound)
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO org_members (org_id, user_id, role, created_at) VALUES (?, ?, ?, ?) ON CONFLICT DO NOTHING",
+		invite.OrgID, userID, invite.Role, now.Unix(),
+	); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
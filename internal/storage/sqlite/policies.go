@@ -0,0 +1,132 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/services/storage"
+	"github.com/mattn/go-sqlite3"
+)
+
+const policyColumns = "id, app_id, name, description, effect, expression, created_at, updated_at"
+
+func scanPolicy(row rowScanner) (models.Policy, error) {
+	var policy models.Policy
+	var createdAt, updatedAt int64
+	err := row.Scan(
+		&policy.ID, &policy.AppID, &policy.Name, &policy.Description,
+		&policy.Effect, &policy.Expression, &createdAt, &updatedAt,
+	)
+	policy.CreatedAt = time.Unix(createdAt, 0)
+	policy.UpdatedAt = time.Unix(updatedAt, 0)
+	return policy, err
+}
+
+func (s *Storage) SavePolicy(ctx context.Context, policy models.Policy) (int64, error) {
+	const op = "storage.sqlite.SavePolicy"
+
+	q, err := s.db.Prepare(`INSERT INTO policies
+		(app_id, name, description, effect, expression, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := q.ExecContext(ctx,
+		policy.AppID, policy.Name, policy.Description, policy.Effect, policy.Expression,
+		policy.CreatedAt.Unix(), policy.UpdatedAt.Unix(),
+	)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrorPolicyExists)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// UpdatePolicy changes description, effect and expression of the policy
+func (s *Storage) UpdatePolicy(ctx context.Context, policy models.Policy) error {
+	const op = "storage.sqlite.UpdatePolicy"
+
+	q, err := s.db.Prepare(`UPDATE policies SET description = ?, effect = ?, expression = ?, updated_at = ?
+		WHERE app_id = ? AND name = ?`)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return execAffectingRow(ctx, q, op, storage.ErrorPolicyNotFound,
+		policy.Description, policy.Effect, policy.Expression, policy.UpdatedAt.Unix(), policy.AppID, policy.Name,
+	)
+}
+
+func (s *Storage) Policy(ctx context.Context, appID int, name string) (models.Policy, error) {
+	const op = "storage.sqlite.Policy"
+
+	q, err := s.db.Prepare("SELECT " + policyColumns + " FROM policies WHERE app_id = ? AND name = ?")
+	if err != nil {
+		return models.Policy{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	policy, err := scanPolicy(q.QueryRowContext(ctx, appID, name))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Policy{}, fmt.Errorf("%s: %w", op, storage.ErrorPolicyNotFound)
+		}
+		return models.Policy{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return policy, nil
+}
+
+// Policies returns policies of the app and global policies
+func (s *Storage) Policies(ctx context.Context, appID int) ([]models.Policy, error) {
+	const op = "storage.sqlite.Policies"
+
+	q, err := s.db.Prepare("SELECT " + policyColumns + " FROM policies WHERE app_id IN (0, ?) ORDER BY app_id, name")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := q.QueryContext(ctx, appID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var policies []models.Policy
+	for rows.Next() {
+		policy, err := scanPolicy(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		policies = append(policies, policy)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return policies, nil
+}
+
+func (s *Storage) DeletePolicy(ctx context.Context, appID int, name string) error {
+	const op = "storage.sqlite.DeletePolicy"
+
+	q, err := s.db.Prepare("DELETE FROM policies WHERE app_id = ? AND name = ?")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return execAffectingRow(ctx, q, op, storage.ErrorPolicyNotFound, appID, name)
+}
@@ -0,0 +1,217 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/services/storage"
+)
+
+// SaveNamespaces creates or replaces namespaces in a single transaction
+func (s *Storage) SaveNamespaces(ctx context.Context, namespaces []models.Namespace) error {
+	const op = "storage.sqlite.SaveNamespaces"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	for _, ns := range namespaces {
+		config, err := json.Marshal(ns)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO relation_namespaces (name, config, updated_at) VALUES (?, ?, ?)
+			ON CONFLICT (name) DO UPDATE SET config = excluded.config, updated_at = excluded.updated_at`,
+			ns.Name, string(config), ns.UpdatedAt.Unix(),
+		); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func scanNamespace(row rowScanner) (models.Namespace, error) {
+	var ns models.Namespace
+	var config string
+	var updatedAt int64
+	if err := row.Scan(&ns.Name, &config, &updatedAt); err != nil {
+		return models.Namespace{}, err
+	}
+	if err := json.Unmarshal([]byte(config), &ns); err != nil {
+		return models.Namespace{}, err
+	}
+	ns.UpdatedAt = time.Unix(updatedAt, 0)
+	return ns, nil
+}
+
+func (s *Storage) Namespace(ctx context.Context, name string) (models.Namespace, error) {
+	const op = "storage.sqlite.Namespace"
+
+	q, err := s.db.Prepare("SELECT name, config, updated_at FROM relation_namespaces WHERE name = ?")
+	if err != nil {
+		return models.Namespace{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	ns, err := scanNamespace(q.QueryRowContext(ctx, name))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Namespace{}, fmt.Errorf("%s: %w", op, storage.ErrorNamespaceNotFound)
+		}
+		return models.Namespace{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ns, nil
+}
+
+func (s *Storage) Namespaces(ctx context.Context) ([]models.Namespace, error) {
+	const op = "storage.sqlite.Namespaces"
+
+	q, err := s.db.Prepare("SELECT name, config, updated_at FROM relation_namespaces ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := q.QueryContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var namespaces []models.Namespace
+	for rows.Next() {
+		ns, err := scanNamespace(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		namespaces = append(namespaces, ns)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return namespaces, nil
+}
+
+// WriteRelationTuples inserts writes and removes deletes in a single transaction
+// and returns the revision created for the change
+//
+// Writing an existing tuple or deleting a missing one is not an error.
+func (s *Storage) WriteRelationTuples(
+	ctx context.Context,
+	writes []models.RelationTuple,
+	deletes []models.RelationTuple,
+	now time.Time,
+) (int64, error) {
+	const op = "storage.sqlite.WriteRelationTuples"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "INSERT INTO relation_revisions (created_at) VALUES (?)", now.Unix())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	revision, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, t := range writes {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO relation_tuples (namespace, object_id, relation,
+			subject_user_id, subject_namespace, subject_object_id, subject_relation, revision)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT DO NOTHING`,
+			t.Namespace, t.ObjectID, t.Relation,
+			t.Subject.UserID, t.Subject.Namespace, t.Subject.ObjectID, t.Subject.Relation, revision,
+		); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	for _, t := range deletes {
+		if _, err := tx.ExecContext(ctx,
+			`DELETE FROM relation_tuples WHERE namespace = ? AND object_id = ? AND relation = ?
+			AND subject_user_id = ? AND subject_namespace = ? AND subject_object_id = ? AND subject_relation = ?`,
+			t.Namespace, t.ObjectID, t.Relation,
+			t.Subject.UserID, t.Subject.Namespace, t.Subject.ObjectID, t.Subject.Relation,
+		); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return revision, nil
+}
+
+// RelationTuples returns tuples of the object with the relation,
+// empty objectID or relation matches any
+func (s *Storage) RelationTuples(ctx context.Context, namespace string, objectID string, relation string) ([]models.RelationTuple, error) {
+	const op = "storage.sqlite.RelationTuples"
+
+	q, err := s.db.Prepare(`SELECT namespace, object_id, relation,
+		subject_user_id, subject_namespace, subject_object_id, subject_relation
+		FROM relation_tuples
+		WHERE namespace = ? AND (? = '' OR object_id = ?) AND (? = '' OR relation = ?)
+		ORDER BY object_id, relation, subject_user_id, subject_namespace, subject_object_id, subject_relation`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := q.QueryContext(ctx, namespace, objectID, objectID, relation, relation)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var tuples []models.RelationTuple
+	for rows.Next() {
+		var t models.RelationTuple
+		if err := rows.Scan(
+			&t.Namespace, &t.ObjectID, &t.Relation,
+			&t.Subject.UserID, &t.Subject.Namespace, &t.Subject.ObjectID, &t.Subject.Relation,
+		); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		tuples = append(tuples, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tuples, nil
+}
+
+// RelationRevision returns the latest revision of relation tuples, 0 if they were never written
+func (s *Storage) RelationRevision(ctx context.Context) (int64, error) {
+	const op = "storage.sqlite.RelationRevision"
+
+	q, err := s.db.Prepare("SELECT COALESCE(MAX(id), 0) FROM relation_revisions")
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var revision int64
+	if err := q.QueryRowContext(ctx).Scan(&revision); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return revision, nil
+}
@@ -0,0 +1,248 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/services/storage"
+	"github.com/mattn/go-sqlite3"
+)
+
+// roleColumns selects role with its permissions joined by commas,
+// queries using it must join role_permissions and group by roles.id
+const roleColumns = `roles.id, roles.app_id, roles.name, roles.description, roles.builtin, roles.created_at,
+	COALESCE(GROUP_CONCAT(role_permissions.permission), '')`
+
+func scanRole(row rowScanner) (models.Role, error) {
+	var role models.Role
+	var createdAt int64
+	var permissions string
+	err := row.Scan(&role.ID, &role.AppID, &role.Name, &role.Description, &role.Builtin, &createdAt, &permissions)
+	role.CreatedAt = time.Unix(createdAt, 0)
+	if permissions != "" {
+		role.Permissions = strings.Split(permissions, ",")
+		sort.Strings(role.Permissions)
+	}
+	return role, err
+}
+
+func scanRoles(rows *sql.Rows) ([]models.Role, error) {
+	var roles []models.Role
+	for rows.Next() {
+		role, err := scanRole(rows)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+// SaveRole stores role with its permissions in a single transaction
+func (s *Storage) SaveRole(ctx context.Context, role models.Role) (int64, error) {
+	const op = "storage.sqlite.SaveRole"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		"INSERT INTO roles (app_id, name, description, builtin, created_at) VALUES (?, ?, ?, ?, ?)",
+		role.AppID, role.Name, role.Description, role.Builtin, role.CreatedAt.Unix(),
+	)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrorRoleExists)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, permission := range role.Permissions {
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO role_permissions (role_id, permission) VALUES (?, ?) ON CONFLICT DO NOTHING",
+			id, permission,
+		); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// Role returns role of the app by name, appID 0 looks up global roles
+func (s *Storage) Role(ctx context.Context, appID int, name string) (models.Role, error) {
+	const op = "storage.sqlite.Role"
+
+	q, err := s.db.Prepare(`SELECT ` + roleColumns + ` FROM roles
+		LEFT JOIN role_permissions ON role_permissions.role_id = roles.id
+		WHERE roles.app_id = ? AND roles.name = ?
+		GROUP BY roles.id`)
+	if err != nil {
+		return models.Role{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	role, err := scanRole(q.QueryRowContext(ctx, appID, name))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Role{}, fmt.Errorf("%s: %w", op, storage.ErrorRoleNotFound)
+		}
+		return models.Role{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return role, nil
+}
+
+// Roles returns roles defined for the app and global roles
+func (s *Storage) Roles(ctx context.Context, appID int) ([]models.Role, error) {
+	const op = "storage.sqlite.Roles"
+
+	q, err := s.db.Prepare(`SELECT ` + roleColumns + ` FROM roles
+		LEFT JOIN role_permissions ON role_permissions.role_id = roles.id
+		WHERE roles.app_id IN (0, ?)
+		GROUP BY roles.id ORDER BY roles.app_id, roles.name`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := q.QueryContext(ctx, appID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	roles, err := scanRoles(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return roles, nil
+}
+
+// UserRoles returns roles of user granted in the app, including global ones
+func (s *Storage) UserRoles(ctx context.Context, userID int64, appID int) ([]models.Role, error) {
+	const op = "storage.sqlite.UserRoles"
+
+	q, err := s.db.Prepare(`SELECT ` + roleColumns + ` FROM roles
+		JOIN user_roles ON user_roles.role_id = roles.id
+		LEFT JOIN role_permissions ON role_permissions.role_id = roles.id
+		WHERE user_roles.user_id = ? AND roles.app_id IN (0, ?)
+		GROUP BY roles.id ORDER BY roles.app_id, roles.name`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := q.QueryContext(ctx, userID, appID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	roles, err := scanRoles(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return roles, nil
+}
+
+// DeleteRole removes role with its permissions and assignments in a single transaction
+func (s *Storage) DeleteRole(ctx context.Context, id int64) error {
+	const op = "storage.sqlite.DeleteRole"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_roles WHERE role_id = ?", id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM role_permissions WHERE role_id = ?", id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM roles WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrorRoleNotFound)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// AssignRole grants role to user, assigning it again is not an error
+func (s *Storage) AssignRole(ctx context.Context, userID int64, roleID int64, now time.Time) error {
+	const op = "storage.sqlite.AssignRole"
+
+	q, err := s.db.Prepare("INSERT INTO user_roles (user_id, role_id, created_at) VALUES (?, ?, ?) ON CONFLICT DO NOTHING")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := q.ExecContext(ctx, userID, roleID, now.Unix()); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) RevokeRole(ctx context.Context, userID int64, roleID int64) error {
+	const op = "storage.sqlite.RevokeRole"
+
+	q, err := s.db.Prepare("DELETE FROM user_roles WHERE user_id = ? AND role_id = ?")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return execAffectingRow(ctx, q, op, storage.ErrorRoleNotAssigned, userID, roleID)
+}
+
+// HasPermission reports whether any role of user granted in the app
+// has the permission or the wildcard one
+func (s *Storage) HasPermission(ctx context.Context, userID int64, appID int, permission string) (bool, error) {
+	const op = "storage.sqlite.HasPermission"
+
+	q, err := s.db.Prepare(`SELECT EXISTS (SELECT 1 FROM user_roles
+		JOIN roles ON roles.id = user_roles.role_id
+		JOIN role_permissions ON role_permissions.role_id = roles.id
+		WHERE user_roles.user_id = ? AND roles.app_id IN (0, ?) AND role_permissions.permission IN (?, ?))`)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var ok bool
+	if err := q.QueryRowContext(ctx, userID, appID, permission, models.PermissionAll).Scan(&ok); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ok, nil
+}
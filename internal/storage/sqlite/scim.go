@@ -0,0 +1,666 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/services/storage"
+	"github.com/mattn/go-sqlite3"
+)
+
+// SaveSCIMToken stores scim token with hash of the token
+func (s *Storage) SaveSCIMToken(ctx context.Context, token models.SCIMToken, tokenHash []byte) (int64, error) {
+	const op = "storage.sqlite.SaveSCIMToken"
+
+	q, err := s.db.Prepare(`INSERT INTO scim_tokens (org_id, name, token_hash, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := q.ExecContext(ctx, token.OrgID, token.Name, tokenHash, token.CreatedBy, token.CreatedAt.Unix())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+const scimTokenColumns = "id, org_id, name, created_by, created_at, last_used_at, revoked"
+
+func scanSCIMToken(row rowScanner) (models.SCIMToken, error) {
+	var token models.SCIMToken
+	var createdAt, lastUsedAt int64
+	err := row.Scan(&token.ID, &token.OrgID, &token.Name, &token.CreatedBy, &createdAt, &lastUsedAt, &token.Revoked)
+	token.CreatedAt = time.Unix(createdAt, 0)
+	token.LastUsedAt = timeOrZero(lastUsedAt)
+	return token, err
+}
+
+// SCIMToken returns scim token by hash of the token
+func (s *Storage) SCIMToken(ctx context.Context, tokenHash []byte) (models.SCIMToken, error) {
+	const op = "storage.sqlite.SCIMToken"
+
+	q, err := s.db.Prepare("SELECT " + scimTokenColumns + " FROM scim_tokens WHERE token_hash = ?")
+	if err != nil {
+		return models.SCIMToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	token, err := scanSCIMToken(q.QueryRowContext(ctx, tokenHash))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.SCIMToken{}, fmt.Errorf("%s: %w", op, storage.ErrorSCIMTokenNotFound)
+		}
+		return models.SCIMToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return token, nil
+}
+
+// SCIMTokens returns scim tokens of the org ordered by id
+func (s *Storage) SCIMTokens(ctx context.Context, orgID int64) ([]models.SCIMToken, error) {
+	const op = "storage.sqlite.SCIMTokens"
+
+	q, err := s.db.Prepare("SELECT " + scimTokenColumns + " FROM scim_tokens WHERE org_id = ? ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := q.QueryContext(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var tokens []models.SCIMToken
+	for rows.Next() {
+		token, err := scanSCIMToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		tokens = append(tokens, token)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tokens, nil
+}
+
+// TouchSCIMToken sets the time the token was last used at
+func (s *Storage) TouchSCIMToken(ctx context.Context, id int64, usedAt time.Time) error {
+	const op = "storage.sqlite.TouchSCIMToken"
+
+	q, err := s.db.Prepare("UPDATE scim_tokens SET last_used_at = ? WHERE id = ?")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return execAffectingRow(ctx, q, op, storage.ErrorSCIMTokenNotFound, usedAt.Unix(), id)
+}
+
+// RevokeSCIMToken marks scim token of the org as revoked
+func (s *Storage) RevokeSCIMToken(ctx context.Context, orgID int64, id int64) error {
+	const op = "storage.sqlite.RevokeSCIMToken"
+
+	q, err := s.db.Prepare("UPDATE scim_tokens SET revoked = TRUE WHERE org_id = ? AND id = ?")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return execAffectingRow(ctx, q, op, storage.ErrorSCIMTokenNotFound, orgID, id)
+}
+
+// SaveSCIMUser provisions user to the org in a single transaction and returns the user ID
+//
+// An existing user with the email is linked to the org only if they are its member,
+// returns storage.ErrorUserExists otherwise, so an org can't take over accounts of
+// others. New users are created without a password and marked provisioned.
+// Active users are added to the org as members, an existing membership is kept as is.
+func (s *Storage) SaveSCIMUser(ctx context.Context, user models.SCIMUser) (int64, error) {
+	const op = "storage.sqlite.SaveSCIMUser"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, "SELECT id FROM users WHERE email = ?", user.Email).Scan(&user.UserID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		res, err := tx.ExecContext(ctx, `INSERT INTO users
+			(email, pass_hash, name, given_name, family_name, picture, locale)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			user.Email, []byte{},
+			user.Profile.Name, user.Profile.GivenName, user.Profile.FamilyName, user.Profile.Picture, user.Profile.Locale,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		if user.UserID, err = res.LastInsertId(); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		user.Provisioned = true
+	case err != nil:
+		return 0, fmt.Errorf("%s: %w", op, err)
+	default:
+		var member bool
+		if err := tx.QueryRowContext(ctx,
+			"SELECT EXISTS (SELECT 1 FROM org_members WHERE org_id = ? AND user_id = ?)", user.OrgID, user.UserID,
+		).Scan(&member); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		if !member {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrorUserExists)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO scim_users
+		(org_id, user_id, external_id, active, provisioned, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		user.OrgID, user.UserID, user.ExternalID, user.Active, user.Provisioned,
+		user.CreatedAt.Unix(), user.UpdatedAt.Unix(),
+	); err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrorSCIMUserExists)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := syncSCIMMembership(ctx, tx, user); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user.UserID, nil
+}
+
+// UpdateSCIMUser updates external ID and active state of scim user in a single transaction,
+// email and profile are updated only for provisioned users
+func (s *Storage) UpdateSCIMUser(ctx context.Context, user models.SCIMUser) error {
+	const op = "storage.sqlite.UpdateSCIMUser"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		"UPDATE scim_users SET external_id = ?, active = ?, updated_at = ? WHERE org_id = ? AND user_id = ?",
+		user.ExternalID, user.Active, user.UpdatedAt.Unix(), user.OrgID, user.UserID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrorSCIMUserNotFound)
+	}
+
+	if user.Provisioned {
+		if _, err := tx.ExecContext(ctx, `UPDATE users
+			SET email = ?, name = ?, given_name = ?, family_name = ?, picture = ?, locale = ?
+			WHERE id = ?`,
+			user.Email, user.Profile.Name, user.Profile.GivenName, user.Profile.FamilyName,
+			user.Profile.Picture, user.Profile.Locale, user.UserID,
+		); err != nil {
+			var sqliteErr sqlite3.Error
+			if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+				return fmt.Errorf("%s: %w", op, storage.ErrorUserExists)
+			}
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := syncSCIMMembership(ctx, tx, user); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// syncSCIMMembership adds active scim user to the org and removes inactive one
+//
+// Owners are managed by the org, not by its identity provider, so they are
+// never removed and the org keeps an owner.
+func syncSCIMMembership(ctx context.Context, tx *sql.Tx, user models.SCIMUser) error {
+	if !user.Active {
+		_, err := tx.ExecContext(ctx, "DELETE FROM org_members WHERE org_id = ? AND user_id = ? AND role != ?",
+			user.OrgID, user.UserID, models.OrgRoleOwner,
+		)
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx,
+		"INSERT INTO org_members (org_id, user_id, role, created_at) VALUES (?, ?, ?, ?) ON CONFLICT DO NOTHING",
+		user.OrgID, user.UserID, models.OrgRoleMember, user.UpdatedAt.Unix(),
+	)
+	return err
+}
+
+const scimUserColumns = `scim_users.org_id, scim_users.user_id, scim_users.external_id, scim_users.active,
+	scim_users.provisioned, scim_users.created_at, scim_users.updated_at,
+	users.email, users.name, users.given_name, users.family_name, users.picture, users.locale`
+
+func scanSCIMUser(row rowScanner) (models.SCIMUser, error) {
+	var user models.SCIMUser
+	var createdAt, updatedAt int64
+	err := row.Scan(
+		&user.OrgID, &user.UserID, &user.ExternalID, &user.Active, &user.Provisioned, &createdAt, &updatedAt,
+		&user.Email, &user.Profile.Name, &user.Profile.GivenName, &user.Profile.FamilyName,
+		&user.Profile.Picture, &user.Profile.Locale,
+	)
+	user.CreatedAt = time.Unix(createdAt, 0)
+	user.UpdatedAt = time.Unix(updatedAt, 0)
+	return user, err
+}
+
+// SCIMUser returns scim user of the org with the groups of the org the user is in
+func (s *Storage) SCIMUser(ctx context.Context, orgID int64, userID int64) (models.SCIMUser, error) {
+	const op = "storage.sqlite.SCIMUser"
+
+	q, err := s.db.Prepare(`SELECT ` + scimUserColumns + ` FROM scim_users
+		JOIN users ON users.id = scim_users.user_id
+		WHERE scim_users.org_id = ? AND scim_users.user_id = ?`)
+	if err != nil {
+		return models.SCIMUser{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := scanSCIMUser(q.QueryRowContext(ctx, orgID, userID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.SCIMUser{}, fmt.Errorf("%s: %w", op, storage.ErrorSCIMUserNotFound)
+		}
+		return models.SCIMUser{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	groups, err := s.scimUserGroups(ctx, orgID, userID)
+	if err != nil {
+		return models.SCIMUser{}, fmt.Errorf("%s: %w", op, err)
+	}
+	user.Groups = groups[userID]
+
+	return user, nil
+}
+
+// SCIMUsers returns scim users of the org ordered by user ID
+func (s *Storage) SCIMUsers(ctx context.Context, orgID int64) ([]models.SCIMUser, error) {
+	const op = "storage.sqlite.SCIMUsers"
+
+	q, err := s.db.Prepare(`SELECT ` + scimUserColumns + ` FROM scim_users
+		JOIN users ON users.id = scim_users.user_id
+		WHERE scim_users.org_id = ? ORDER BY scim_users.user_id`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := q.QueryContext(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var users []models.SCIMUser
+	for rows.Next() {
+		user, err := scanSCIMUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	groups, err := s.scimUserGroups(ctx, orgID, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	for i := range users {
+		users[i].Groups = groups[users[i].UserID]
+	}
+
+	return users, nil
+}
+
+// scimUserGroups returns groups of the org by IDs of their members,
+// limited to the user if userID is not 0
+func (s *Storage) scimUserGroups(ctx context.Context, orgID int64, userID int64) (map[int64][]models.SCIMGroupRef, error) {
+	q, err := s.db.Prepare(`SELECT scim_group_members.user_id, scim_groups.id, scim_groups.display_name
+		FROM scim_group_members
+		JOIN scim_groups ON scim_groups.id = scim_group_members.group_id
+		WHERE scim_groups.org_id = ? AND (? = 0 OR scim_group_members.user_id = ?)
+		ORDER BY scim_groups.id`)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.QueryContext(ctx, orgID, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := make(map[int64][]models.SCIMGroupRef)
+	for rows.Next() {
+		var memberID int64
+		var group models.SCIMGroupRef
+		if err := rows.Scan(&memberID, &group.ID, &group.DisplayName); err != nil {
+			return nil, err
+		}
+		groups[memberID] = append(groups[memberID], group)
+	}
+
+	return groups, rows.Err()
+}
+
+// DeleteSCIMUser removes scim user from the org and its groups in a single transaction,
+// the user itself and memberships of owners are kept
+func (s *Storage) DeleteSCIMUser(ctx context.Context, orgID int64, userID int64) error {
+	const op = "storage.sqlite.DeleteSCIMUser"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM scim_users WHERE org_id = ? AND user_id = ?", orgID, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrorSCIMUserNotFound)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM scim_group_members
+		WHERE user_id = ? AND group_id IN (SELECT id FROM scim_groups WHERE org_id = ?)`,
+		userID, orgID,
+	); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	// owners stay, see syncSCIMMembership
+	if _, err := tx.ExecContext(ctx,
+		"DELETE FROM org_members WHERE org_id = ? AND user_id = ? AND role != ?", orgID, userID, models.OrgRoleOwner,
+	); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SaveSCIMGroup stores scim group with its members in a single transaction
+//
+// Returns storage.ErrorSCIMUserNotFound if a member is not a scim user of the org.
+func (s *Storage) SaveSCIMGroup(ctx context.Context, group models.SCIMGroup) (int64, error) {
+	const op = "storage.sqlite.SaveSCIMGroup"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `INSERT INTO scim_groups
+		(org_id, display_name, external_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?)`,
+		group.OrgID, group.DisplayName, group.ExternalID, group.CreatedAt.Unix(), group.UpdatedAt.Unix(),
+	)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrorSCIMGroupExists)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if group.ID, err = res.LastInsertId(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := insertSCIMGroupMembers(ctx, tx, group); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return group.ID, nil
+}
+
+// UpdateSCIMGroup updates name and external ID of scim group and replaces
+// its members in a single transaction
+//
+// Returns storage.ErrorSCIMUserNotFound if a member is not a scim user of the org.
+func (s *Storage) UpdateSCIMGroup(ctx context.Context, group models.SCIMGroup) error {
+	const op = "storage.sqlite.UpdateSCIMGroup"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		"UPDATE scim_groups SET display_name = ?, external_id = ?, updated_at = ? WHERE org_id = ? AND id = ?",
+		group.DisplayName, group.ExternalID, group.UpdatedAt.Unix(), group.OrgID, group.ID,
+	)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return fmt.Errorf("%s: %w", op, storage.ErrorSCIMGroupExists)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrorSCIMGroupNotFound)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM scim_group_members WHERE group_id = ?", group.ID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := insertSCIMGroupMembers(ctx, tx, group); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// insertSCIMGroupMembers adds members to the group, members must be distinct
+func insertSCIMGroupMembers(ctx context.Context, tx *sql.Tx, group models.SCIMGroup) error {
+	for _, member := range group.Members {
+		res, err := tx.ExecContext(ctx, `INSERT INTO scim_group_members (group_id, user_id)
+			SELECT ?, user_id FROM scim_users WHERE org_id = ? AND user_id = ?`,
+			group.ID, group.OrgID, member.UserID,
+		)
+		if err != nil {
+			return err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return storage.ErrorSCIMUserNotFound
+		}
+	}
+	return nil
+}
+
+const scimGroupColumns = "id, org_id, display_name, external_id, created_at, updated_at"
+
+func scanSCIMGroup(row rowScanner) (models.SCIMGroup, error) {
+	var group models.SCIMGroup
+	var createdAt, updatedAt int64
+	err := row.Scan(&group.ID, &group.OrgID, &group.DisplayName, &group.ExternalID, &createdAt, &updatedAt)
+	group.CreatedAt = time.Unix(createdAt, 0)
+	group.UpdatedAt = time.Unix(updatedAt, 0)
+	return group, err
+}
+
+// SCIMGroup returns scim group of the org with its members
+func (s *Storage) SCIMGroup(ctx context.Context, orgID int64, id int64) (models.SCIMGroup, error) {
+	const op = "storage.sqlite.SCIMGroup"
+
+	q, err := s.db.Prepare("SELECT " + scimGroupColumns + " FROM scim_groups WHERE org_id = ? AND id = ?")
+	if err != nil {
+		return models.SCIMGroup{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	group, err := scanSCIMGroup(q.QueryRowContext(ctx, orgID, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.SCIMGroup{}, fmt.Errorf("%s: %w", op, storage.ErrorSCIMGroupNotFound)
+		}
+		return models.SCIMGroup{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	members, err := s.scimGroupMembers(ctx, orgID, id)
+	if err != nil {
+		return models.SCIMGroup{}, fmt.Errorf("%s: %w", op, err)
+	}
+	group.Members = members[id]
+
+	return group, nil
+}
+
+// SCIMGroups returns scim groups of the org with their members ordered by id
+func (s *Storage) SCIMGroups(ctx context.Context, orgID int64) ([]models.SCIMGroup, error) {
+	const op = "storage.sqlite.SCIMGroups"
+
+	q, err := s.db.Prepare("SELECT " + scimGroupColumns + " FROM scim_groups WHERE org_id = ? ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := q.QueryContext(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var groups []models.SCIMGroup
+	for rows.Next() {
+		group, err := scanSCIMGroup(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		groups = append(groups, group)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	members, err := s.scimGroupMembers(ctx, orgID, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	for i := range groups {
+		groups[i].Members = members[groups[i].ID]
+	}
+
+	return groups, nil
+}
+
+// scimGroupMembers returns members of groups of the org by group IDs,
+// limited to the group if groupID is not 0
+func (s *Storage) scimGroupMembers(ctx context.Context, orgID int64, groupID int64) (map[int64][]models.SCIMMember, error) {
+	q, err := s.db.Prepare(`SELECT scim_group_members.group_id, users.id, users.email
+		FROM scim_group_members
+		JOIN scim_groups ON scim_groups.id = scim_group_members.group_id
+		JOIN users ON users.id = scim_group_members.user_id
+		WHERE scim_groups.org_id = ? AND (? = 0 OR scim_groups.id = ?)
+		ORDER BY users.id`)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.QueryContext(ctx, orgID, groupID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := make(map[int64][]models.SCIMMember)
+	for rows.Next() {
+		var id int64
+		var member models.SCIMMember
+		if err := rows.Scan(&id, &member.UserID, &member.Email); err != nil {
+			return nil, err
+		}
+		members[id] = append(members[id], member)
+	}
+
+	return members, rows.Err()
+}
+
+// DeleteSCIMGroup removes scim group of the org with its members in a single transaction
+func (s *Storage) DeleteSCIMGroup(ctx context.Context, orgID int64, id int64) error {
+	const op = "storage.sqlite.DeleteSCIMGroup"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM scim_groups WHERE org_id = ? AND id = ?", orgID, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrorSCIMGroupNotFound)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM scim_group_members WHERE group_id = ?", id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
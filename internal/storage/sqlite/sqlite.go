@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/Len4i/auth-service/internal/domain/models"
 	"github.com/Len4i/auth-service/internal/services/storage"
@@ -15,6 +17,44 @@ type Storage struct {
 	db *sql.DB
 }
 
+// isAdminColumn selects whether user has the built-in admin role
+const isAdminColumn = `EXISTS (SELECT 1 FROM user_roles JOIN roles ON roles.id = user_roles.role_id
+	WHERE user_roles.user_id = users.id AND roles.app_id = 0 AND roles.name = '` + models.AdminRoleName + `')`
+
+const userColumns = "id, email, pass_hash, pass_legacy, pepper_key_id, " + isAdminColumn +
+	", name, given_name, family_name, picture, locale"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// execAffectingRow executes statement and returns notFound error if no rows were affected
+func execAffectingRow(ctx context.Context, q *sql.Stmt, op string, notFound error, args ...any) error {
+	res, err := q.ExecContext(ctx, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, notFound)
+	}
+
+	return nil
+}
+
+func scanUser(row rowScanner) (models.User, error) {
+	var user models.User
+	err := row.Scan(
+		&user.ID, &user.Email, &user.PassHash, &user.PassLegacy, &user.PepperKeyID, &user.IsAdmin,
+		&user.Profile.Name, &user.Profile.GivenName, &user.Profile.FamilyName, &user.Profile.Picture, &user.Profile.Locale,
+	)
+	return user, err
+}
+
 func New(storagePath string) (*Storage, error) {
 	const op = "storage.sqlite.New"
 
@@ -30,15 +70,15 @@ func New(storagePath string) (*Storage, error) {
 	return &Storage{db: db}, nil
 }
 
-func (s *Storage) SaveUser(ctx context.Context, email string, passHash []byte) (int64, error) {
+func (s *Storage) SaveUser(ctx context.Context, email string, passHash []byte, pepperKeyID string) (int64, error) {
 	const op = "storage.sqlite.SaveUser"
 
-	q, err := s.db.Prepare("INSERT INTO users (email, pass_hash) VALUES (?, ?)")
+	q, err := s.db.Prepare("INSERT INTO users (email, pass_hash, pepper_key_id) VALUES (?, ?, ?)")
 	if err != nil {
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
-	res, err := q.ExecContext(ctx, email, passHash)
+	res, err := q.ExecContext(ctx, email, passHash, pepperKeyID)
 	if err != nil {
 		// Check if error is not constraint(uniq email) violation
 		var sqliteErr sqlite3.Error
@@ -56,18 +96,49 @@ func (s *Storage) SaveUser(ctx context.Context, email string, passHash []byte) (
 	return userID, nil
 }
 
+// UpdatePassHash replaces user password hash and clears legacy flag
+func (s *Storage) UpdatePassHash(ctx context.Context, userID int64, passHash []byte, pepperKeyID string) error {
+	const op = "storage.sqlite.UpdatePassHash"
+
+	q, err := s.db.Prepare("UPDATE users SET pass_hash = ?, pepper_key_id = ?, pass_legacy = FALSE WHERE id = ?")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return execAffectingRow(ctx, q, op, storage.ErrorUserNotFound, passHash, pepperKeyID, userID)
+}
+
 func (s *Storage) User(ctx context.Context, email string) (models.User, error) {
 	const op = "storage.sqlite.User"
 
-	q, err := s.db.Prepare("SELECT id, email, pass_hash FROM users WHERE email = ?")
+	q, err := s.db.Prepare("SELECT " + userColumns + " FROM users WHERE email = ?")
 	if err != nil {
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
 	row := q.QueryRowContext(ctx, email)
 
-	var user models.User
-	err = row.Scan(&user.ID, &user.Email, &user.PassHash)
+	user, err := scanUser(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrorUserNotFound)
+		}
+
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+func (s *Storage) UserByID(ctx context.Context, id int64) (models.User, error) {
+	const op = "storage.sqlite.UserByID"
+
+	q, err := s.db.Prepare("SELECT " + userColumns + " FROM users WHERE id = ?")
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := scanUser(q.QueryRowContext(ctx, id))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrorUserNotFound)
@@ -79,10 +150,109 @@ func (s *Storage) User(ctx context.Context, email string) (models.User, error) {
 	return user, nil
 }
 
+// Users returns up to limit users with id greater than afterID ordered by id
+func (s *Storage) Users(ctx context.Context, afterID int64, limit int) ([]models.User, error) {
+	const op = "storage.sqlite.Users"
+
+	q, err := s.db.Prepare("SELECT " + userColumns + " FROM users WHERE id > ? ORDER BY id LIMIT ?")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := q.QueryContext(ctx, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return users, nil
+}
+
+// ImportUsers inserts users in a single transaction
+//
+// A failing row doesn't abort the batch, returned slice holds an error
+// per user (nil on success). With dryRun the transaction is rolled back.
+func (s *Storage) ImportUsers(ctx context.Context, users []models.User, dryRun bool) ([]error, error) {
+	const op = "storage.sqlite.ImportUsers"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	q, err := tx.PrepareContext(ctx, `INSERT INTO users
+		(email, pass_hash, pass_legacy, pepper_key_id, name, given_name, family_name, picture, locale)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer q.Close()
+
+	qAdmin, err := tx.PrepareContext(ctx, `INSERT INTO user_roles (user_id, role_id, created_at)
+		SELECT ?, id, ? FROM roles WHERE app_id = 0 AND name = ?`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer qAdmin.Close()
+
+	now := time.Now().Unix()
+	rowErrs := make([]error, len(users))
+	for i, user := range users {
+		res, err := q.ExecContext(ctx,
+			user.Email, user.PassHash, user.PassLegacy, user.PepperKeyID,
+			user.Profile.Name, user.Profile.GivenName, user.Profile.FamilyName, user.Profile.Picture, user.Profile.Locale,
+		)
+		if err != nil {
+			var sqliteErr sqlite3.Error
+			if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+				rowErrs[i] = storage.ErrorUserExists
+				continue
+			}
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if !user.IsAdmin {
+			continue
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if _, err := qAdmin.ExecContext(ctx, id, now, models.AdminRoleName); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if dryRun {
+		return rowErrs, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rowErrs, nil
+}
+
 func (s *Storage) App(ctx context.Context, id int) (models.App, error) {
 	const op = "storage.sqlite.App"
 
-	q, err := s.db.Prepare("SELECT id, name, secret FROM apps WHERE id = ?")
+	q, err := s.db.Prepare(`
+		SELECT id, name, secret, registration_mode, redirect_uris, allowed_origins FROM apps WHERE id = ?
+	`)
 	if err != nil {
 		return models.App{}, fmt.Errorf("%s: %w", op, err)
 	}
@@ -90,7 +260,8 @@ func (s *Storage) App(ctx context.Context, id int) (models.App, error) {
 	row := q.QueryRowContext(ctx, id)
 
 	var app models.App
-	err = row.Scan(&app.ID, &app.Name, &app.Secret)
+	var redirectURIs, allowedOrigins string
+	err = row.Scan(&app.ID, &app.Name, &app.Secret, &app.RegistrationMode, &redirectURIs, &allowedOrigins)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return models.App{}, fmt.Errorf("%s: %w", op, storage.ErrorAppNotFound)
@@ -98,14 +269,38 @@ func (s *Storage) App(ctx context.Context, id int) (models.App, error) {
 
 		return models.App{}, fmt.Errorf("%s: %w", op, err)
 	}
+	app.RedirectURIs = strings.Fields(redirectURIs)
+	app.AllowedOrigins = strings.Fields(allowedOrigins)
 
 	return app, nil
 }
 
+// AnyAppAllowsOrigin reports whether any app allows the web origin
+func (s *Storage) AnyAppAllowsOrigin(ctx context.Context, origin string) (bool, error) {
+	const op = "storage.sqlite.AnyAppAllowsOrigin"
+
+	q, err := s.db.Prepare(`
+		SELECT EXISTS (
+			SELECT 1 FROM apps WHERE instr(' ' || allowed_origins || ' ', ' ' || ? || ' ') > 0
+		)
+	`)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var allowed bool
+	if err := q.QueryRowContext(ctx, origin).Scan(&allowed); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return allowed, nil
+}
+
+// IsAdmin reports whether user has the built-in admin role
 func (s *Storage) IsAdmin(ctx context.Context, userID int64) (bool, error) {
 	const op = "storage.sqlite.IsAdmin"
 
-	q, err := s.db.Prepare("SELECT is_admin FROM users WHERE id = ?")
+	q, err := s.db.Prepare("SELECT " + isAdminColumn + " FROM users WHERE id = ?")
 	if err != nil {
 		return false, fmt.Errorf("%s: %w", op, err)
 	}
@@ -124,3 +319,163 @@ func (s *Storage) IsAdmin(ctx context.Context, userID int64) (bool, error) {
 
 	return isAdmin, nil
 }
+
+// SaveInvite stores invite with hash of its code
+func (s *Storage) SaveInvite(ctx context.Context, invite models.Invite, codeHash []byte) (int64, error) {
+	const op = "storage.sqlite.SaveInvite"
+
+	q, err := s.db.Prepare(`INSERT INTO invites
+		(code_hash, app_id, email, max_uses, expires_at, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := q.ExecContext(ctx,
+		codeHash, invite.AppID, invite.Email, invite.MaxUses,
+		invite.ExpiresAt.Unix(), invite.CreatedBy, invite.CreatedAt.Unix(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+const inviteColumns = "id, app_id, email, max_uses, uses, expires_at, revoked, created_by, created_at"
+
+func scanInvite(row rowScanner) (models.Invite, error) {
+	var invite models.Invite
+	var expiresAt, createdAt int64
+	err := row.Scan(
+		&invite.ID, &invite.AppID, &invite.Email, &invite.MaxUses, &invite.Uses,
+		&expiresAt, &invite.Revoked, &invite.CreatedBy, &createdAt,
+	)
+	invite.ExpiresAt = time.Unix(expiresAt, 0)
+	invite.CreatedAt = time.Unix(createdAt, 0)
+	return invite, err
+}
+
+// Invite returns invite by hash of its code
+func (s *Storage) Invite(ctx context.Context, codeHash []byte) (models.Invite, error) {
+	const op = "storage.sqlite.Invite"
+
+	q, err := s.db.Prepare("SELECT " + inviteColumns + " FROM invites WHERE code_hash = ?")
+	if err != nil {
+		return models.Invite{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	invite, err := scanInvite(q.QueryRowContext(ctx, codeHash))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Invite{}, fmt.Errorf("%s: %w", op, storage.ErrorInviteNotFound)
+		}
+		return models.Invite{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return invite, nil
+}
+
+// Invites returns invites of the app ordered by id, appID 0 returns invites of all apps
+func (s *Storage) Invites(ctx context.Context, appID int) ([]models.Invite, error) {
+	const op = "storage.sqlite.Invites"
+
+	q, err := s.db.Prepare("SELECT " + inviteColumns + " FROM invites WHERE ? = 0 OR app_id = ? ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := q.QueryContext(ctx, appID, appID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var invites []models.Invite
+	for rows.Next() {
+		invite, err := scanInvite(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		invites = append(invites, invite)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return invites, nil
+}
+
+// RevokeInvite marks invite as revoked
+func (s *Storage) RevokeInvite(ctx context.Context, id int64) error {
+	const op = "storage.sqlite.RevokeInvite"
+
+	q, err := s.db.Prepare("UPDATE invites SET revoked = TRUE WHERE id = ?")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return execAffectingRow(ctx, q, op, storage.ErrorInviteNotFound, id)
+}
+
+// SaveInvitedUser uses the invite and saves user in a single transaction
+//
+// Returns storage.ErrorInviteNotFound if invite is no longer active.
+func (s *Storage) SaveInvitedUser(
+	ctx context.Context,
+	email string,
+	passHash []byte,
+	pepperKeyID string,
+	inviteID int64,
+	now time.Time,
+) (int64, error) {
+	const op = "storage.sqlite.SaveInvitedUser"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `UPDATE invites SET uses = uses + 1
+		WHERE id = ? AND revoked = FALSE AND uses < max_uses AND expires_at > ?`,
+		inviteID, now.Unix(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrorInviteNotFound)
+	}
+
+	res, err = tx.ExecContext(ctx, "INSERT INTO users (email, pass_hash, pepper_key_id) VALUES (?, ?, ?)",
+		email, passHash, pepperKeyID,
+	)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrorUserExists)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	userID, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return userID, nil
+}
@@ -0,0 +1,193 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/services/storage"
+	"github.com/mattn/go-sqlite3"
+)
+
+const webAuthnCredentialColumns = `id, user_id, credential_id, public_key, attestation_type, aaguid, sign_count,
+	transports, backup_eligible, backup_state, name, created_at, last_used_at`
+
+func scanWebAuthnCredential(row rowScanner) (models.WebAuthnCredential, error) {
+	var cred models.WebAuthnCredential
+	var transports string
+	var createdAt int64
+	var lastUsedAt sql.NullInt64
+	err := row.Scan(
+		&cred.ID, &cred.UserID, &cred.CredentialID, &cred.PublicKey, &cred.AttestationType, &cred.AAGUID, &cred.SignCount,
+		&transports, &cred.BackupEligible, &cred.BackupState, &cred.Name, &createdAt, &lastUsedAt,
+	)
+	if transports != "" {
+		cred.Transports = strings.Split(transports, ",")
+	}
+	cred.CreatedAt = time.Unix(createdAt, 0)
+	if lastUsedAt.Valid {
+		cred.LastUsedAt = time.Unix(lastUsedAt.Int64, 0)
+	}
+	return cred, err
+}
+
+// SaveWebAuthnCredential stores new credential of user
+//
+// Returns storage.ErrorCredentialExists if credential ID is already registered.
+func (s *Storage) SaveWebAuthnCredential(ctx context.Context, cred models.WebAuthnCredential) (int64, error) {
+	const op = "storage.sqlite.SaveWebAuthnCredential"
+
+	q, err := s.db.Prepare(`INSERT INTO webauthn_credentials (user_id, credential_id, public_key, attestation_type, aaguid,
+		sign_count, transports, backup_eligible, backup_state, name, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := q.ExecContext(ctx,
+		cred.UserID, cred.CredentialID, cred.PublicKey, cred.AttestationType, cred.AAGUID, cred.SignCount,
+		strings.Join(cred.Transports, ","), cred.BackupEligible, cred.BackupState, cred.Name, cred.CreatedAt.Unix(),
+	)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrorCredentialExists)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// WebAuthnCredentials returns credentials of user ordered by registration
+func (s *Storage) WebAuthnCredentials(ctx context.Context, userID int64) ([]models.WebAuthnCredential, error) {
+	const op = "storage.sqlite.WebAuthnCredentials"
+
+	q, err := s.db.Prepare("SELECT " + webAuthnCredentialColumns + " FROM webauthn_credentials WHERE user_id = ? ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := q.QueryContext(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var creds []models.WebAuthnCredential
+	for rows.Next() {
+		cred, err := scanWebAuthnCredential(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		creds = append(creds, cred)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return creds, nil
+}
+
+// UpdateWebAuthnCredentialUsage records successful login with credential
+func (s *Storage) UpdateWebAuthnCredentialUsage(
+	ctx context.Context,
+	id int64,
+	signCount uint32,
+	backupState bool,
+	now time.Time,
+) error {
+	const op = "storage.sqlite.UpdateWebAuthnCredentialUsage"
+
+	q, err := s.db.Prepare("UPDATE webauthn_credentials SET sign_count = ?, backup_state = ?, last_used_at = ? WHERE id = ?")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return execAffectingRow(ctx, q, op, storage.ErrorCredentialNotFound, signCount, backupState, now.Unix(), id)
+}
+
+// DeleteWebAuthnCredential removes credential owned by user
+func (s *Storage) DeleteWebAuthnCredential(ctx context.Context, userID int64, id int64) error {
+	const op = "storage.sqlite.DeleteWebAuthnCredential"
+
+	q, err := s.db.Prepare("DELETE FROM webauthn_credentials WHERE id = ? AND user_id = ?")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return execAffectingRow(ctx, q, op, storage.ErrorCredentialNotFound, id, userID)
+}
+
+// SaveWebAuthnSession stores ceremony session with hash of its handle
+func (s *Storage) SaveWebAuthnSession(ctx context.Context, session models.WebAuthnSession, handleHash []byte) (int64, error) {
+	const op = "storage.sqlite.SaveWebAuthnSession"
+
+	q, err := s.db.Prepare(`INSERT INTO webauthn_sessions (handle_hash, purpose, user_id, app_id, mfa_challenge_id, data, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := q.ExecContext(ctx,
+		handleHash, session.Purpose, session.UserID, session.AppID, session.MFAChallengeID, session.Data,
+		session.ExpiresAt.Unix(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// WebAuthnSession returns ceremony session by hash of its handle
+func (s *Storage) WebAuthnSession(ctx context.Context, handleHash []byte) (models.WebAuthnSession, error) {
+	const op = "storage.sqlite.WebAuthnSession"
+
+	q, err := s.db.Prepare(`SELECT id, purpose, user_id, app_id, mfa_challenge_id, data, expires_at
+		FROM webauthn_sessions WHERE handle_hash = ?`)
+	if err != nil {
+		return models.WebAuthnSession{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var session models.WebAuthnSession
+	var expiresAt int64
+	err = q.QueryRowContext(ctx, handleHash).Scan(
+		&session.ID, &session.Purpose, &session.UserID, &session.AppID, &session.MFAChallengeID, &session.Data, &expiresAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.WebAuthnSession{}, fmt.Errorf("%s: %w", op, storage.ErrorSessionNotFound)
+		}
+		return models.WebAuthnSession{}, fmt.Errorf("%s: %w", op, err)
+	}
+	session.ExpiresAt = time.Unix(expiresAt, 0)
+
+	return session, nil
+}
+
+// DeleteWebAuthnSession removes session, returns storage.ErrorSessionNotFound
+// if it was already removed, so a ceremony can be finished only once
+func (s *Storage) DeleteWebAuthnSession(ctx context.Context, id int64) error {
+	const op = "storage.sqlite.DeleteWebAuthnSession"
+
+	q, err := s.db.Prepare("DELETE FROM webauthn_sessions WHERE id = ?")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return execAffectingRow(ctx, q, op, storage.ErrorSessionNotFound, id)
+}
@@ -0,0 +1,12 @@
+CREATE TABLE
+    IF NOT EXISTS login_codes (
+        id INTEGER PRIMARY KEY,
+        user_id INTEGER NOT NULL,
+        app_id INTEGER NOT NULL,
+        code_hash BLOB NOT NULL,
+        attempts INTEGER NOT NULL DEFAULT 0,
+        expires_at INTEGER NOT NULL,
+        created_at INTEGER NOT NULL
+    );
+
+CREATE INDEX IF NOT EXISTS idx_login_codes_user_id ON login_codes (user_id);
@@ -0,0 +1 @@
+ALTER TABLE mfa_challenges ADD COLUMN amr TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,50 @@
+CREATE TABLE
+    IF NOT EXISTS roles (
+        id INTEGER PRIMARY KEY,
+        -- 0 for global roles granted in every app
+        app_id INTEGER NOT NULL DEFAULT 0,
+        name TEXT NOT NULL,
+        description TEXT NOT NULL DEFAULT '',
+        builtin BOOLEAN NOT NULL DEFAULT FALSE,
+        created_at INTEGER NOT NULL,
+        UNIQUE (app_id, name)
+    );
+
+CREATE TABLE
+    IF NOT EXISTS role_permissions (
+        role_id INTEGER NOT NULL,
+        permission TEXT NOT NULL,
+        PRIMARY KEY (role_id, permission)
+    );
+
+CREATE TABLE
+    IF NOT EXISTS user_roles (
+        user_id INTEGER NOT NULL,
+        role_id INTEGER NOT NULL,
+        created_at INTEGER NOT NULL,
+        PRIMARY KEY (user_id, role_id)
+    );
+
+CREATE INDEX IF NOT EXISTS idx_user_roles_role_id ON user_roles (role_id);
+
+INSERT INTO
+    roles (app_id, name, description, builtin, created_at)
+VALUES (
+        0,
+        'admin',
+        'Built-in administrator role with all permissions',
+        TRUE,
+        CAST(strftime('%s', 'now') AS INTEGER)
+    );
+
+INSERT INTO
+    role_permissions (role_id, permission)
+SELECT id, '*' FROM roles WHERE app_id = 0 AND name = 'admin';
+
+INSERT INTO
+    user_roles (user_id, role_id, created_at)
+SELECT users.id, roles.id, CAST(strftime('%s', 'now') AS INTEGER)
+FROM users, roles
+WHERE users.is_admin AND roles.app_id = 0 AND roles.name = 'admin';
+
+ALTER TABLE users DROP COLUMN is_admin;
@@ -0,0 +1,6 @@
+ALTER TABLE mfa_challenges DROP COLUMN org_id;
+
+DROP TABLE IF EXISTS org_invites;
+DROP TABLE IF EXISTS org_members;
+DROP TABLE IF EXISTS org_apps;
+DROP TABLE IF EXISTS orgs;
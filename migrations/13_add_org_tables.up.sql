@@ -0,0 +1,47 @@
+CREATE TABLE
+    IF NOT EXISTS orgs (
+        id INTEGER PRIMARY KEY,
+        slug TEXT NOT NULL UNIQUE,
+        name TEXT NOT NULL,
+        -- login policy, allowed_methods are comma separated amr values, empty allows any
+        require_mfa BOOLEAN NOT NULL DEFAULT FALSE,
+        allowed_methods TEXT NOT NULL DEFAULT '',
+        created_at INTEGER NOT NULL
+    );
+
+-- apps members of an org may log in to, an org without apps allows any
+CREATE TABLE
+    IF NOT EXISTS org_apps (
+        org_id INTEGER NOT NULL,
+        app_id INTEGER NOT NULL,
+        PRIMARY KEY (org_id, app_id)
+    );
+
+CREATE TABLE
+    IF NOT EXISTS org_members (
+        org_id INTEGER NOT NULL,
+        user_id INTEGER NOT NULL,
+        role TEXT NOT NULL,
+        created_at INTEGER NOT NULL,
+        PRIMARY KEY (org_id, user_id)
+    );
+
+CREATE INDEX IF NOT EXISTS idx_org_members_user_id ON org_members (user_id);
+
+CREATE TABLE
+    IF NOT EXISTS org_invites (
+        id INTEGER PRIMARY KEY,
+        org_id INTEGER NOT NULL,
+        code_hash BLOB NOT NULL UNIQUE,
+        email TEXT NOT NULL,
+        role TEXT NOT NULL,
+        expires_at INTEGER NOT NULL,
+        accepted_by INTEGER,
+        revoked BOOLEAN NOT NULL DEFAULT FALSE,
+        created_by INTEGER NOT NULL,
+        created_at INTEGER NOT NULL
+    );
+
+CREATE INDEX IF NOT EXISTS idx_org_invites_org_id ON org_invites (org_id);
+
+ALTER TABLE mfa_challenges ADD COLUMN org_id INTEGER NOT NULL DEFAULT 0;
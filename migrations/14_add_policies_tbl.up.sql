@@ -0,0 +1,15 @@
+CREATE TABLE
+    IF NOT EXISTS policies (
+        id INTEGER PRIMARY KEY,
+        -- 0 for global policies evaluated in every app
+        app_id INTEGER NOT NULL DEFAULT 0,
+        name TEXT NOT NULL,
+        description TEXT NOT NULL DEFAULT '',
+        -- allow or deny
+        effect TEXT NOT NULL,
+        -- CEL expression over subject, resource, action, context and now
+        expression TEXT NOT NULL,
+        created_at INTEGER NOT NULL,
+        updated_at INTEGER NOT NULL,
+        UNIQUE (app_id, name)
+    );
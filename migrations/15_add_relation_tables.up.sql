@@ -0,0 +1,36 @@
+CREATE TABLE
+    IF NOT EXISTS relation_namespaces (
+        name TEXT PRIMARY KEY,
+        -- JSON encoded relations with their userset rewrites
+        config TEXT NOT NULL,
+        updated_at INTEGER NOT NULL
+    );
+
+-- every write of tuples creates a revision, consistency tokens encode its id
+CREATE TABLE
+    IF NOT EXISTS relation_revisions (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        created_at INTEGER NOT NULL
+    );
+
+CREATE TABLE
+    IF NOT EXISTS relation_tuples (
+        namespace TEXT NOT NULL,
+        object_id TEXT NOT NULL,
+        relation TEXT NOT NULL,
+        -- either a user or a userset, empty subject_relation refers to the object itself
+        subject_user_id TEXT NOT NULL DEFAULT '',
+        subject_namespace TEXT NOT NULL DEFAULT '',
+        subject_object_id TEXT NOT NULL DEFAULT '',
+        subject_relation TEXT NOT NULL DEFAULT '',
+        revision INTEGER NOT NULL,
+        PRIMARY KEY (
+            namespace,
+            object_id,
+            relation,
+            subject_user_id,
+            subject_namespace,
+            subject_object_id,
+            subject_relation
+        )
+    );
@@ -0,0 +1,18 @@
+CREATE TABLE
+    IF NOT EXISTS api_keys (
+        id INTEGER PRIMARY KEY,
+        user_id INTEGER NOT NULL,
+        app_id INTEGER NOT NULL,
+        name TEXT NOT NULL,
+        prefix TEXT NOT NULL UNIQUE,
+        key_hash BLOB NOT NULL,
+        -- space separated permissions the key is restricted to
+        scopes TEXT NOT NULL,
+        -- 0 if the key never expires
+        expires_at INTEGER NOT NULL DEFAULT 0,
+        last_used_at INTEGER NOT NULL DEFAULT 0,
+        revoked BOOLEAN NOT NULL DEFAULT FALSE,
+        created_at INTEGER NOT NULL
+    );
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys (user_id);
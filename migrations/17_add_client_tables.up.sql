@@ -0,0 +1,24 @@
+CREATE TABLE
+    IF NOT EXISTS clients (
+        id INTEGER PRIMARY KEY,
+        client_id TEXT NOT NULL UNIQUE,
+        app_id INTEGER NOT NULL,
+        name TEXT NOT NULL,
+        secret_hash BLOB NOT NULL,
+        -- PEM public key verifying client assertions, empty if not registered
+        public_key TEXT NOT NULL DEFAULT '',
+        -- space separated permissions granted to the client
+        permissions TEXT NOT NULL,
+        created_at INTEGER NOT NULL
+    );
+
+CREATE INDEX IF NOT EXISTS idx_clients_app_id ON clients (app_id);
+
+-- ids of used client assertions, kept until they expire to stop replays
+CREATE TABLE
+    IF NOT EXISTS client_assertions (
+        client_id TEXT NOT NULL,
+        jti TEXT NOT NULL,
+        expires_at INTEGER NOT NULL,
+        PRIMARY KEY (client_id, jti)
+    );
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS oauth_codes;
+
+ALTER TABLE apps DROP COLUMN redirect_uris;
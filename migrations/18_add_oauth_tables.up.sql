@@ -0,0 +1,20 @@
+-- space separated redirect URIs OAuth authorization responses may be sent to
+ALTER TABLE apps ADD COLUMN redirect_uris TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE
+    IF NOT EXISTS oauth_codes (
+        id INTEGER PRIMARY KEY,
+        code_hash BLOB NOT NULL UNIQUE,
+        app_id INTEGER NOT NULL,
+        user_id INTEGER NOT NULL,
+        org_id INTEGER NOT NULL DEFAULT 0,
+        redirect_uri TEXT NOT NULL,
+        -- S256 PKCE challenge the code verifier must match
+        code_challenge TEXT NOT NULL,
+        scope TEXT NOT NULL DEFAULT '',
+        -- authentication of the login the code was issued for
+        amr TEXT NOT NULL DEFAULT '',
+        acr TEXT NOT NULL DEFAULT '',
+        auth_time INTEGER NOT NULL DEFAULT 0,
+        expires_at INTEGER NOT NULL
+    );
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS oauth_grants;
+
+ALTER TABLE oauth_codes DROP COLUMN nonce;
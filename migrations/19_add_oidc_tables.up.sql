@@ -0,0 +1,11 @@
+ALTER TABLE oauth_codes ADD COLUMN nonce TEXT NOT NULL DEFAULT '';
+
+-- scope the user last granted to the app, it selects userinfo claims
+CREATE TABLE
+    IF NOT EXISTS oauth_grants (
+        user_id INTEGER NOT NULL,
+        app_id INTEGER NOT NULL,
+        scope TEXT NOT NULL,
+        updated_at INTEGER NOT NULL,
+        PRIMARY KEY (user_id, app_id)
+    );
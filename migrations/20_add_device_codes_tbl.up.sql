@@ -0,0 +1,20 @@
+CREATE TABLE
+    IF NOT EXISTS device_codes (
+        id INTEGER PRIMARY KEY,
+        device_code_hash BLOB NOT NULL UNIQUE,
+        user_code_hash BLOB NOT NULL UNIQUE,
+        app_id INTEGER NOT NULL,
+        scope TEXT NOT NULL DEFAULT '',
+        -- pending, approved or denied
+        status TEXT NOT NULL DEFAULT 'pending',
+        -- user who approved or denied the device and the authentication of the login
+        user_id INTEGER NOT NULL DEFAULT 0,
+        org_id INTEGER NOT NULL DEFAULT 0,
+        amr TEXT NOT NULL DEFAULT '',
+        acr TEXT NOT NULL DEFAULT '',
+        auth_time INTEGER NOT NULL DEFAULT 0,
+        -- seconds the device has to wait between polls
+        poll_interval INTEGER NOT NULL,
+        polled_at INTEGER NOT NULL DEFAULT 0,
+        expires_at INTEGER NOT NULL
+    );
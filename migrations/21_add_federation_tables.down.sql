@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS federated_links;
+
+DROP TABLE IF EXISTS federated_logins;
+
+DROP TABLE IF EXISTS user_identities;
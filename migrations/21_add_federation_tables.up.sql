@@ -0,0 +1,41 @@
+-- accounts of users at upstream identity providers, subject is unique per connector
+CREATE TABLE
+    IF NOT EXISTS user_identities (
+        id INTEGER PRIMARY KEY,
+        user_id INTEGER NOT NULL,
+        connector TEXT NOT NULL,
+        subject TEXT NOT NULL,
+        email TEXT NOT NULL DEFAULT '',
+        created_at INTEGER NOT NULL,
+        UNIQUE (connector, subject)
+    );
+
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities (user_id);
+
+-- logins redirected to an upstream identity provider, waiting for its callback
+CREATE TABLE
+    IF NOT EXISTS federated_logins (
+        id INTEGER PRIMARY KEY,
+        state_hash BLOB NOT NULL UNIQUE,
+        connector TEXT NOT NULL,
+        app_id INTEGER NOT NULL,
+        request TEXT NOT NULL,
+        nonce TEXT NOT NULL,
+        code_verifier TEXT NOT NULL,
+        expires_at INTEGER NOT NULL
+    );
+
+-- upstream identities waiting for the user to prove the account they link to
+CREATE TABLE
+    IF NOT EXISTS federated_links (
+        id INTEGER PRIMARY KEY,
+        token_hash BLOB NOT NULL UNIQUE,
+        user_id INTEGER NOT NULL,
+        connector TEXT NOT NULL,
+        subject TEXT NOT NULL,
+        email TEXT NOT NULL,
+        app_id INTEGER NOT NULL,
+        request TEXT NOT NULL,
+        attempts INTEGER NOT NULL DEFAULT 0,
+        expires_at INTEGER NOT NULL
+    );
@@ -0,0 +1,2 @@
+-- space separated web origins browser apps may call the API from
+ALTER TABLE apps ADD COLUMN allowed_origins TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,20 @@
+-- server-side sessions of first-party web apps, the browser keeps the token in a cookie
+CREATE TABLE
+    IF NOT EXISTS browser_sessions (
+        id INTEGER PRIMARY KEY,
+        token_hash BLOB NOT NULL UNIQUE,
+        csrf_token TEXT NOT NULL,
+        user_id INTEGER NOT NULL,
+        app_id INTEGER NOT NULL,
+        -- scope and authentication of the login, as in its token
+        roles TEXT NOT NULL DEFAULT '',
+        org_id INTEGER NOT NULL DEFAULT 0,
+        org_role TEXT NOT NULL DEFAULT '',
+        amr TEXT NOT NULL DEFAULT '',
+        acr TEXT NOT NULL DEFAULT '',
+        auth_time INTEGER NOT NULL DEFAULT 0,
+        created_at INTEGER NOT NULL,
+        expires_at INTEGER NOT NULL
+    );
+
+CREATE INDEX IF NOT EXISTS idx_browser_sessions_user_id ON browser_sessions (user_id);
@@ -0,0 +1,50 @@
+-- bearer tokens SCIM clients of an org, usually its identity provider, provision users with
+CREATE TABLE
+    IF NOT EXISTS scim_tokens (
+        id INTEGER PRIMARY KEY,
+        org_id INTEGER NOT NULL,
+        name TEXT NOT NULL,
+        token_hash BLOB NOT NULL UNIQUE,
+        created_by INTEGER NOT NULL,
+        created_at INTEGER NOT NULL,
+        last_used_at INTEGER NOT NULL DEFAULT 0,
+        revoked BOOLEAN NOT NULL DEFAULT FALSE
+    );
+
+CREATE INDEX IF NOT EXISTS idx_scim_tokens_org_id ON scim_tokens (org_id);
+
+-- users provisioned to an org, active ones are members of the org,
+-- provisioned is set if the org created the user
+CREATE TABLE
+    IF NOT EXISTS scim_users (
+        org_id INTEGER NOT NULL,
+        user_id INTEGER NOT NULL,
+        external_id TEXT NOT NULL DEFAULT '',
+        active BOOLEAN NOT NULL DEFAULT TRUE,
+        provisioned BOOLEAN NOT NULL DEFAULT FALSE,
+        created_at INTEGER NOT NULL,
+        updated_at INTEGER NOT NULL,
+        PRIMARY KEY (org_id, user_id)
+    );
+
+CREATE INDEX IF NOT EXISTS idx_scim_users_user_id ON scim_users (user_id);
+
+CREATE TABLE
+    IF NOT EXISTS scim_groups (
+        id INTEGER PRIMARY KEY,
+        org_id INTEGER NOT NULL,
+        display_name TEXT NOT NULL,
+        external_id TEXT NOT NULL DEFAULT '',
+        created_at INTEGER NOT NULL,
+        updated_at INTEGER NOT NULL,
+        UNIQUE (org_id, display_name)
+    );
+
+CREATE TABLE
+    IF NOT EXISTS scim_group_members (
+        group_id INTEGER NOT NULL,
+        user_id INTEGER NOT NULL,
+        PRIMARY KEY (group_id, user_id)
+    );
+
+CREATE INDEX IF NOT EXISTS idx_scim_group_members_user_id ON scim_group_members (user_id);
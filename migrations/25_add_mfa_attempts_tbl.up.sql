@@ -0,0 +1,8 @@
+-- second factor attempts of users in the current throttling window, they
+-- survive challenges, so new logins don't bring new attempts
+CREATE TABLE
+    IF NOT EXISTS mfa_attempts (
+        user_id INTEGER PRIMARY KEY,
+        attempts INTEGER NOT NULL,
+        window_start INTEGER NOT NULL
+    );
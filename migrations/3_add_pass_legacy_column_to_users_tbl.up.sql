@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN pass_legacy BOOLEAN NOT NULL DEFAULT FALSE;
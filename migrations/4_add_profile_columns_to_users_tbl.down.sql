@@ -0,0 +1,9 @@
+ALTER TABLE users DROP COLUMN name;
+
+ALTER TABLE users DROP COLUMN given_name;
+
+ALTER TABLE users DROP COLUMN family_name;
+
+ALTER TABLE users DROP COLUMN picture;
+
+ALTER TABLE users DROP COLUMN locale;
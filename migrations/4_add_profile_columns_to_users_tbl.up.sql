@@ -0,0 +1,9 @@
+ALTER TABLE users ADD COLUMN name TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE users ADD COLUMN given_name TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE users ADD COLUMN family_name TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE users ADD COLUMN picture TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE users ADD COLUMN locale TEXT NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN pepper_key_id TEXT NOT NULL DEFAULT '';
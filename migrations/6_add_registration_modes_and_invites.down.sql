@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS invites;
+
+ALTER TABLE apps DROP COLUMN registration_mode;
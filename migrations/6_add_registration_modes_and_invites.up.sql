@@ -0,0 +1,17 @@
+ALTER TABLE apps ADD COLUMN registration_mode TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE
+    IF NOT EXISTS invites (
+        id INTEGER PRIMARY KEY,
+        code_hash BLOB NOT NULL UNIQUE,
+        app_id INTEGER NOT NULL DEFAULT 0,
+        email TEXT NOT NULL DEFAULT '',
+        max_uses INTEGER NOT NULL DEFAULT 1,
+        uses INTEGER NOT NULL DEFAULT 0,
+        expires_at INTEGER NOT NULL,
+        revoked BOOLEAN NOT NULL DEFAULT FALSE,
+        created_by INTEGER NOT NULL,
+        created_at INTEGER NOT NULL
+    );
+
+CREATE INDEX IF NOT EXISTS idx_invites_app_id ON invites (app_id);
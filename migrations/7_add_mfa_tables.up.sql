@@ -0,0 +1,18 @@
+CREATE TABLE
+    IF NOT EXISTS totp_factors (
+        user_id INTEGER PRIMARY KEY,
+        secret BLOB NOT NULL,
+        confirmed BOOLEAN NOT NULL DEFAULT FALSE,
+        last_step INTEGER NOT NULL DEFAULT 0,
+        created_at INTEGER NOT NULL
+    );
+
+CREATE TABLE
+    IF NOT EXISTS mfa_challenges (
+        id INTEGER PRIMARY KEY,
+        handle_hash BLOB NOT NULL UNIQUE,
+        user_id INTEGER NOT NULL,
+        app_id INTEGER NOT NULL,
+        attempts INTEGER NOT NULL DEFAULT 0,
+        expires_at INTEGER NOT NULL
+    );
@@ -0,0 +1,30 @@
+CREATE TABLE
+    IF NOT EXISTS webauthn_credentials (
+        id INTEGER PRIMARY KEY,
+        user_id INTEGER NOT NULL,
+        credential_id BLOB NOT NULL UNIQUE,
+        public_key BLOB NOT NULL,
+        attestation_type TEXT NOT NULL DEFAULT '',
+        aaguid BLOB,
+        sign_count INTEGER NOT NULL DEFAULT 0,
+        transports TEXT NOT NULL DEFAULT '',
+        backup_eligible BOOLEAN NOT NULL DEFAULT FALSE,
+        backup_state BOOLEAN NOT NULL DEFAULT FALSE,
+        name TEXT NOT NULL DEFAULT '',
+        created_at INTEGER NOT NULL,
+        last_used_at INTEGER
+    );
+
+CREATE INDEX IF NOT EXISTS idx_webauthn_credentials_user_id ON webauthn_credentials (user_id);
+
+CREATE TABLE
+    IF NOT EXISTS webauthn_sessions (
+        id INTEGER PRIMARY KEY,
+        handle_hash BLOB NOT NULL UNIQUE,
+        purpose TEXT NOT NULL,
+        user_id INTEGER NOT NULL DEFAULT 0,
+        app_id INTEGER NOT NULL DEFAULT 0,
+        mfa_challenge_id INTEGER NOT NULL DEFAULT 0,
+        data BLOB NOT NULL,
+        expires_at INTEGER NOT NULL
+    );
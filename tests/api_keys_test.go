@@ -0,0 +1,207 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	authsvcv1 "github.com/Len4i/auth-service/gen/go/authsvc"
+	"github.com/Len4i/auth-service/tests/suite"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeys_Bearer(t *testing.T) {
+	ctx, s := suite.New(t)
+	adminCtx := withToken(ctx, adminToken(t))
+
+	_, _, token := registerAndLogin(ctx, t, s)
+	userID := tokenUserID(t, token)
+	userCtx := withToken(ctx, token)
+
+	// permissions are unique so that roles of parallel tests don't interfere
+	resource := strings.ToLower(gofakeit.LetterN(8))
+	read, write := resource+":read", resource+":write"
+	roleName := "writer-" + resource
+
+	_, err := s.RBACClient.CreateRole(adminCtx, &authsvcv1.CreateRoleRequest{
+		AppId:       appID,
+		Name:        roleName,
+		Permissions: []string{read, write},
+	})
+	require.NoError(t, err)
+	_, err = s.RBACClient.AssignRole(adminCtx, &authsvcv1.AssignRoleRequest{UserId: userID, AppId: appID, Role: roleName})
+	require.NoError(t, err)
+
+	respCreate, err := s.APIKeysClient.CreateAPIKey(userCtx, &authsvcv1.CreateAPIKeyRequest{
+		Name:   "ci",
+		Scopes: []string{read},
+	})
+	require.NoError(t, err)
+	key := respCreate.GetKey()
+	require.True(t, strings.HasPrefix(key, respCreate.GetApiKey().GetPrefix()+"_"))
+	assert.True(t, strings.HasPrefix(key, "ak_"))
+	assert.Equal(t, int32(appID), respCreate.GetApiKey().GetAppId())
+	assert.Equal(t, []string{read}, respCreate.GetApiKey().GetScopes())
+	assert.Zero(t, respCreate.GetApiKey().GetExpiresAt())
+	assert.Zero(t, respCreate.GetApiKey().GetLastUsedAt())
+
+	keyCtx := withToken(ctx, key)
+
+	respHas, err := s.RBACClient.HasPermission(keyCtx, &authsvcv1.HasPermissionRequest{Permission: read})
+	require.NoError(t, err)
+	assert.True(t, respHas.GetAllowed())
+
+	// user has the permission, but the key is not scoped for it
+	respHas, err = s.RBACClient.HasPermission(keyCtx, &authsvcv1.HasPermissionRequest{Permission: write})
+	require.NoError(t, err)
+	assert.False(t, respHas.GetAllowed())
+
+	_, err = s.APIKeysClient.CreateAPIKey(keyCtx, &authsvcv1.CreateAPIKeyRequest{Name: "nested", Scopes: []string{"*"}})
+	assert.EqualError(t, err, "rpc error: code = PermissionDenied desc = api keys are not allowed")
+
+	respList, err := s.APIKeysClient.ListAPIKeys(userCtx, &authsvcv1.ListAPIKeysRequest{})
+	require.NoError(t, err)
+	require.Len(t, respList.GetApiKeys(), 1)
+	assert.Equal(t, respCreate.GetApiKey().GetId(), respList.GetApiKeys()[0].GetId())
+	assert.NotZero(t, respList.GetApiKeys()[0].GetLastUsedAt())
+
+	// exchanged token is restricted to the same scopes
+	respExchange, err := s.APIKeysClient.ExchangeAPIKey(ctx, &authsvcv1.ExchangeAPIKeyRequest{Key: key})
+	require.NoError(t, err)
+	assert.Equal(t, read, tokenClaims(t, respExchange.GetToken())["scope"])
+	assert.Equal(t, float64(userID), tokenClaims(t, respExchange.GetToken())["user_id"])
+
+	exchangedCtx := withToken(ctx, respExchange.GetToken())
+	respHas, err = s.RBACClient.HasPermission(exchangedCtx, &authsvcv1.HasPermissionRequest{Permission: write})
+	require.NoError(t, err)
+	assert.False(t, respHas.GetAllowed())
+
+	_, err = s.APIKeysClient.ListAPIKeys(exchangedCtx, &authsvcv1.ListAPIKeysRequest{})
+	assert.EqualError(t, err, "rpc error: code = PermissionDenied desc = api keys are not allowed")
+
+	_, err = s.APIKeysClient.RevokeAPIKey(userCtx, &authsvcv1.RevokeAPIKeyRequest{Id: respCreate.GetApiKey().GetId()})
+	require.NoError(t, err)
+
+	_, err = s.RBACClient.HasPermission(keyCtx, &authsvcv1.HasPermissionRequest{Permission: read})
+	assert.EqualError(t, err, "rpc error: code = Unauthenticated desc = invalid token")
+
+	_, err = s.APIKeysClient.ExchangeAPIKey(ctx, &authsvcv1.ExchangeAPIKeyRequest{Key: key})
+	assert.EqualError(t, err, "rpc error: code = Unauthenticated desc = invalid api key")
+
+	respList, err = s.APIKeysClient.ListAPIKeys(userCtx, &authsvcv1.ListAPIKeysRequest{})
+	require.NoError(t, err)
+	assert.Empty(t, respList.GetApiKeys())
+
+	respList, err = s.APIKeysClient.ListAPIKeys(userCtx, &authsvcv1.ListAPIKeysRequest{IncludeInactive: true})
+	require.NoError(t, err)
+	require.Len(t, respList.GetApiKeys(), 1)
+	assert.True(t, respList.GetApiKeys()[0].GetRevoked())
+}
+
+func TestAPIKeys_Admin(t *testing.T) {
+	ctx, s := suite.New(t)
+	adminCtx := withToken(ctx, adminToken(t))
+
+	_, _, token := registerAndLogin(ctx, t, s)
+	userID := tokenUserID(t, token)
+	userCtx := withToken(ctx, token)
+
+	respScoped, err := s.APIKeysClient.CreateAPIKey(adminCtx, &authsvcv1.CreateAPIKeyRequest{
+		Name:   "reports",
+		Scopes: []string{"reports:read"},
+	})
+	require.NoError(t, err)
+
+	respFull, err := s.APIKeysClient.CreateAPIKey(adminCtx, &authsvcv1.CreateAPIKeyRequest{
+		Name:       "automation",
+		Scopes:     []string{"*"},
+		TtlSeconds: 3600,
+	})
+	require.NoError(t, err)
+	assert.NotZero(t, respFull.GetApiKey().GetExpiresAt())
+
+	// admin keys act as an admin only with the "*" scope
+	_, err = s.APIKeysClient.ListAPIKeys(userCtx, &authsvcv1.ListAPIKeysRequest{UserId: adminUserID})
+	assert.EqualError(t, err, "rpc error: code = PermissionDenied desc = admin permission required")
+
+	_, err = s.InvitesClient.ListInvites(withToken(ctx, respScoped.GetKey()), &authsvcv1.ListInvitesRequest{AppId: appID})
+	assert.EqualError(t, err, "rpc error: code = PermissionDenied desc = admin permission required")
+
+	_, err = s.InvitesClient.ListInvites(withToken(ctx, respFull.GetKey()), &authsvcv1.ListInvitesRequest{AppId: appID})
+	require.NoError(t, err)
+
+	// admins manage keys of other users
+	respCreate, err := s.APIKeysClient.CreateAPIKey(userCtx, &authsvcv1.CreateAPIKeyRequest{
+		Name:   "laptop",
+		Scopes: []string{"*"},
+	})
+	require.NoError(t, err)
+
+	respList, err := s.APIKeysClient.ListAPIKeys(adminCtx, &authsvcv1.ListAPIKeysRequest{UserId: userID})
+	require.NoError(t, err)
+	require.Len(t, respList.GetApiKeys(), 1)
+	assert.Equal(t, "laptop", respList.GetApiKeys()[0].GetName())
+
+	_, err = s.APIKeysClient.RevokeAPIKey(userCtx, &authsvcv1.RevokeAPIKeyRequest{Id: respScoped.GetApiKey().GetId()})
+	assert.EqualError(t, err, "rpc error: code = NotFound desc = api key not found")
+
+	_, err = s.APIKeysClient.RevokeAPIKey(adminCtx, &authsvcv1.RevokeAPIKeyRequest{
+		Id:     respCreate.GetApiKey().GetId(),
+		UserId: userID,
+	})
+	require.NoError(t, err)
+}
+
+func TestAPIKeys_CreateFails(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	_, _, token := registerAndLogin(ctx, t, s)
+	userCtx := withToken(ctx, token)
+
+	tests := []struct {
+		name        string
+		req         *authsvcv1.CreateAPIKeyRequest
+		expectedErr string
+	}{
+		{
+			name:        "Empty name",
+			req:         &authsvcv1.CreateAPIKeyRequest{Scopes: []string{"*"}},
+			expectedErr: "name must be 1 to 64 characters",
+		},
+		{
+			name:        "No scopes",
+			req:         &authsvcv1.CreateAPIKeyRequest{Name: "ci"},
+			expectedErr: "scopes are not valid",
+		},
+		{
+			name:        "Invalid scope",
+			req:         &authsvcv1.CreateAPIKeyRequest{Name: "ci", Scopes: []string{"Posts Read"}},
+			expectedErr: "scopes are not valid",
+		},
+		{
+			name:        "Negative ttl",
+			req:         &authsvcv1.CreateAPIKeyRequest{Name: "ci", Scopes: []string{"*"}, TtlSeconds: -1},
+			expectedErr: "ttl must not be negative",
+		},
+		{
+			name:        "Unknown app",
+			req:         &authsvcv1.CreateAPIKeyRequest{Name: "ci", Scopes: []string{"*"}, AppId: 99999},
+			expectedErr: "app id is not valid",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.APIKeysClient.CreateAPIKey(userCtx, tt.req)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expectedErr)
+		})
+	}
+
+	_, err := s.APIKeysClient.CreateAPIKey(ctx, &authsvcv1.CreateAPIKeyRequest{Name: "ci", Scopes: []string{"*"}})
+	assert.EqualError(t, err, "rpc error: code = Unauthenticated desc = authentication required")
+
+	_, err = s.APIKeysClient.ExchangeAPIKey(ctx, &authsvcv1.ExchangeAPIKeyRequest{Key: "ak_notakey"})
+	assert.EqualError(t, err, "rpc error: code = Unauthenticated desc = invalid api key")
+}
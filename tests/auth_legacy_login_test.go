@@ -0,0 +1,68 @@
+package tests
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	aaav1 "github.com/Len4i/aaa/gen/go/aaa"
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/storage/sqlite"
+	"github.com/Len4i/auth-service/tests/suite"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// legacyPassHash is a Django PBKDF2 hash of legacyPass
+	legacyPassHash = "pbkdf2_sha256$260000$c2FsdHk$ZqcDj1r3rRm8O6QfH1Nc+A6bajyqohF04qSM2CjLea4="
+	legacyPass     = "secret"
+)
+
+func TestLogin_LegacyHash(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	email := legacyUser(ctx, t, s)
+
+	_, err := s.AuthClient.Login(ctx, &aaav1.LoginRequest{
+		Email:    email,
+		Password: "not-" + legacyPass,
+		AppId:    appID,
+	})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = Internal desc = internal error")
+
+	// First login verifies the imported hash and rehashes it,
+	// the second one goes through the current algorithm
+	for i := 0; i < 2; i++ {
+		respLogin, err := s.AuthClient.Login(ctx, &aaav1.LoginRequest{
+			Email:    email,
+			Password: legacyPass,
+			AppId:    appID,
+		})
+		require.NoError(t, err)
+		assert.NotEmpty(t, respLogin.GetToken())
+	}
+}
+
+// legacyUser imports user with the legacy hash, like usertool does, and
+// returns the email, every run gets a user whose hash is not rehashed yet
+func legacyUser(ctx context.Context, t *testing.T, s *suite.Suite) string {
+	t.Helper()
+
+	// config paths are relative to the repository root
+	storage, err := sqlite.New(filepath.Join("..", s.Cfg.StoragePath))
+	require.NoError(t, err)
+
+	email := gofakeit.Email()
+	rowErrs, err := storage.ImportUsers(ctx, []models.User{{
+		Email:      email,
+		PassHash:   []byte(legacyPassHash),
+		PassLegacy: true,
+	}}, false)
+	require.NoError(t, err)
+	require.NoError(t, rowErrs[0])
+
+	return email
+}
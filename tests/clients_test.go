@@ -0,0 +1,245 @@
+package tests
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	authsvcv1 "github.com/Len4i/auth-service/gen/go/authsvc"
+	"github.com/Len4i/auth-service/tests/suite"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// issuer is the issuer of the test server client assertions are addressed to
+const issuer = "http://localhost:44045"
+
+func TestClients_ClientSecret(t *testing.T) {
+	ctx, s := suite.New(t)
+	adminCtx := withToken(ctx, adminToken(t))
+
+	respCreate, err := s.ClientsClient.CreateClient(adminCtx, &authsvcv1.CreateClientRequest{
+		AppId:       appID,
+		Name:        "billing",
+		Permissions: []string{"invoices:write", "invoices:read"},
+	})
+	require.NoError(t, err)
+	clientID := respCreate.GetClient().GetClientId()
+	require.NotEmpty(t, clientID)
+	require.NotEmpty(t, respCreate.GetClientSecret())
+	assert.Equal(t, []string{"invoices:read", "invoices:write"}, respCreate.GetClient().GetPermissions())
+
+	respToken, err := s.ClientsClient.IssueClientToken(ctx, &authsvcv1.IssueClientTokenRequest{
+		ClientId:     clientID,
+		ClientSecret: respCreate.GetClientSecret(),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"invoices:read", "invoices:write"}, respToken.GetScopes())
+	assert.Equal(t, int64(time.Hour/time.Second), respToken.GetExpiresIn())
+
+	claims := tokenClaims(t, respToken.GetToken())
+	assert.Equal(t, clientID, claims["sub"])
+	assert.Equal(t, clientID, claims["client_id"])
+	assert.Equal(t, float64(appID), claims["app_id"])
+	assert.Equal(t, "invoices:read invoices:write", claims["scope"])
+	assert.NotContains(t, claims, "user_id")
+
+	// client tokens act on behalf of no user
+	_, err = s.APIKeysClient.ListAPIKeys(withToken(ctx, respToken.GetToken()), &authsvcv1.ListAPIKeysRequest{})
+	assert.EqualError(t, err, "rpc error: code = PermissionDenied desc = user token required")
+
+	respToken, err = s.ClientsClient.IssueClientToken(ctx, &authsvcv1.IssueClientTokenRequest{
+		ClientId:     clientID,
+		ClientSecret: respCreate.GetClientSecret(),
+		Scopes:       []string{"invoices:read"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "invoices:read", tokenClaims(t, respToken.GetToken())["scope"])
+
+	_, err = s.ClientsClient.IssueClientToken(ctx, &authsvcv1.IssueClientTokenRequest{
+		ClientId:     clientID,
+		ClientSecret: respCreate.GetClientSecret(),
+		Scopes:       []string{"invoices:delete"},
+	})
+	assert.EqualError(t, err, "rpc error: code = PermissionDenied desc = scope is not allowed")
+
+	_, err = s.ClientsClient.IssueClientToken(ctx, &authsvcv1.IssueClientTokenRequest{
+		ClientId:     clientID,
+		ClientSecret: gofakeit.Password(true, true, true, false, false, 32),
+	})
+	assert.EqualError(t, err, "rpc error: code = Unauthenticated desc = invalid client credentials")
+
+	respRotate, err := s.ClientsClient.RotateClientSecret(adminCtx, &authsvcv1.RotateClientSecretRequest{ClientId: clientID})
+	require.NoError(t, err)
+
+	_, err = s.ClientsClient.IssueClientToken(ctx, &authsvcv1.IssueClientTokenRequest{
+		ClientId:     clientID,
+		ClientSecret: respCreate.GetClientSecret(),
+	})
+	assert.EqualError(t, err, "rpc error: code = Unauthenticated desc = invalid client credentials")
+
+	_, err = s.ClientsClient.IssueClientToken(ctx, &authsvcv1.IssueClientTokenRequest{
+		ClientId:     clientID,
+		ClientSecret: respRotate.GetClientSecret(),
+	})
+	require.NoError(t, err)
+
+	respList, err := s.ClientsClient.ListClients(adminCtx, &authsvcv1.ListClientsRequest{AppId: appID})
+	require.NoError(t, err)
+	assert.Contains(t, clientIDs(respList.GetClients()), clientID)
+
+	_, err = s.ClientsClient.DeleteClient(adminCtx, &authsvcv1.DeleteClientRequest{ClientId: clientID})
+	require.NoError(t, err)
+
+	_, err = s.ClientsClient.IssueClientToken(ctx, &authsvcv1.IssueClientTokenRequest{
+		ClientId:     clientID,
+		ClientSecret: respRotate.GetClientSecret(),
+	})
+	assert.EqualError(t, err, "rpc error: code = Unauthenticated desc = invalid client credentials")
+
+	_, err = s.ClientsClient.DeleteClient(adminCtx, &authsvcv1.DeleteClientRequest{ClientId: clientID})
+	assert.EqualError(t, err, "rpc error: code = NotFound desc = client not found")
+}
+
+func TestClients_ClientAssertion(t *testing.T) {
+	ctx, s := suite.New(t)
+	adminCtx := withToken(ctx, adminToken(t))
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	require.NoError(t, err)
+
+	respCreate, err := s.ClientsClient.CreateClient(adminCtx, &authsvcv1.CreateClientRequest{
+		AppId:       appID,
+		Name:        "reports",
+		Permissions: []string{"reports:read"},
+		PublicKey:   string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+	})
+	require.NoError(t, err)
+	clientID := respCreate.GetClient().GetClientId()
+
+	assertion := clientAssertion(t, priv, jwt.MapClaims{
+		"iss": clientID,
+		"sub": clientID,
+		"aud": issuer,
+		"jti": gofakeit.UUID(),
+		"exp": time.Now().Add(time.Minute).Unix(),
+	})
+
+	respToken, err := s.ClientsClient.IssueClientToken(ctx, &authsvcv1.IssueClientTokenRequest{
+		ClientAssertion: assertion,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, clientID, tokenClaims(t, respToken.GetToken())["sub"])
+	assert.Equal(t, "reports:read", tokenClaims(t, respToken.GetToken())["scope"])
+
+	// assertions are single-use
+	_, err = s.ClientsClient.IssueClientToken(ctx, &authsvcv1.IssueClientTokenRequest{
+		ClientAssertion: assertion,
+	})
+	assert.EqualError(t, err, "rpc error: code = Unauthenticated desc = invalid client credentials")
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+	}{
+		{
+			name: "Other audience",
+			claims: jwt.MapClaims{
+				"iss": clientID, "sub": clientID, "aud": "other", "jti": gofakeit.UUID(),
+				"exp": time.Now().Add(time.Minute).Unix(),
+			},
+		},
+		{
+			name: "Expires too late",
+			claims: jwt.MapClaims{
+				"iss": clientID, "sub": clientID, "aud": issuer, "jti": gofakeit.UUID(),
+				"exp": time.Now().Add(time.Hour).Unix(),
+			},
+		},
+		{
+			name: "No id",
+			claims: jwt.MapClaims{
+				"iss": clientID, "sub": clientID, "aud": issuer,
+				"exp": time.Now().Add(time.Minute).Unix(),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.ClientsClient.IssueClientToken(ctx, &authsvcv1.IssueClientTokenRequest{
+				ClientAssertion: clientAssertion(t, priv, tt.claims),
+			})
+			assert.EqualError(t, err, "rpc error: code = Unauthenticated desc = invalid client credentials")
+		})
+	}
+}
+
+func TestClients_CreateFails(t *testing.T) {
+	ctx, s := suite.New(t)
+	adminCtx := withToken(ctx, adminToken(t))
+
+	_, _, token := registerAndLogin(ctx, t, s)
+
+	_, err := s.ClientsClient.CreateClient(withToken(ctx, token), &authsvcv1.CreateClientRequest{
+		AppId: appID,
+		Name:  "billing",
+	})
+	assert.EqualError(t, err, "rpc error: code = PermissionDenied desc = admin permission required")
+
+	tests := []struct {
+		name        string
+		req         *authsvcv1.CreateClientRequest
+		expectedErr string
+	}{
+		{
+			name:        "Empty name",
+			req:         &authsvcv1.CreateClientRequest{AppId: appID},
+			expectedErr: "name must be 1 to 64 characters",
+		},
+		{
+			name:        "Invalid permission",
+			req:         &authsvcv1.CreateClientRequest{AppId: appID, Name: "billing", Permissions: []string{"Read All"}},
+			expectedErr: "permission is not valid",
+		},
+		{
+			name:        "Invalid public key",
+			req:         &authsvcv1.CreateClientRequest{AppId: appID, Name: "billing", PublicKey: "not a key"},
+			expectedErr: "public key is not valid",
+		},
+		{
+			name:        "Unknown app",
+			req:         &authsvcv1.CreateClientRequest{AppId: 99999, Name: "billing"},
+			expectedErr: "app id is not valid",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.ClientsClient.CreateClient(adminCtx, tt.req)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expectedErr)
+		})
+	}
+}
+
+func clientAssertion(t *testing.T, key ed25519.PrivateKey, claims jwt.MapClaims) string {
+	t.Helper()
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims).SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func clientIDs(clients []*authsvcv1.Client) []string {
+	ids := make([]string, 0, len(clients))
+	for _, client := range clients {
+		ids = append(ids, client.GetClientId())
+	}
+	return ids
+}
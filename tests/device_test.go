@@ -0,0 +1,243 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	authsvcv1 "github.com/Len4i/auth-service/gen/go/authsvc"
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/lib/secret"
+	"github.com/Len4i/auth-service/internal/lib/totp"
+	"github.com/Len4i/auth-service/internal/services/oauth"
+	"github.com/Len4i/auth-service/internal/storage/sqlite"
+	"github.com/Len4i/auth-service/tests/suite"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+const deviceCodeGrant = "urn:ietf:params:oauth:grant-type:device_code"
+
+func TestDevice_Approve(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	email, password, _ := registerAndLogin(ctx, t, s)
+
+	config := &oauth2.Config{
+		ClientID: "999",
+		Endpoint: oauth2.Endpoint{
+			DeviceAuthURL: s.HTTPURL + "/oauth/device_authorization",
+			TokenURL:      s.HTTPURL + "/oauth/token",
+		},
+		Scopes: []string{"openid", "email"},
+	}
+	device, err := config.DeviceAuth(ctx)
+	require.NoError(t, err)
+	assert.Regexp(t, `^[BCDFGHJKLMNPQRSTVWXZ]{4}-[BCDFGHJKLMNPQRSTVWXZ]{4}$`, device.UserCode)
+	assert.Equal(t, s.HTTPURL+"/oauth/device", device.VerificationURI)
+	assert.Equal(t, device.VerificationURI+"?user_code="+device.UserCode, device.VerificationURIComplete)
+	assert.Equal(t, int64(5), device.Interval)
+	assert.InDelta(t, 10*time.Minute, time.Until(device.Expiry), float64(time.Minute))
+
+	status, body := pollDevice(t, s, device.DeviceCode)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "authorization_pending", body["error"])
+
+	// polling faster than the interval slows the device down
+	status, body = pollDevice(t, s, device.DeviceCode)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "slow_down", body["error"])
+
+	resp := httpGet(t, s, strings.TrimPrefix(device.VerificationURIComplete, s.HTTPURL))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, readBody(t, resp), "test-app")
+
+	// users may type the code in lower case and without the dash
+	form := url.Values{
+		"user_code": {strings.ToLower(strings.ReplaceAll(device.UserCode, "-", ""))},
+		"email":     {email},
+		"password":  {password},
+		"decision":  {"approve"},
+	}
+	resp = httpPostForm(t, s, "/oauth/device", form)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, readBody(t, resp), "Device connected")
+
+	status, body = pollDevice(t, s, device.DeviceCode)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "Bearer", body["token_type"])
+	assert.Equal(t, "openid email", body["scope"])
+	assert.NotEmpty(t, body["id_token"])
+
+	claims := tokenClaims(t, body["access_token"].(string))
+	assert.Equal(t, email, claims["email"])
+	assert.Equal(t, float64(appID), claims["app_id"])
+	assert.Equal(t, []any{"pwd"}, claims["amr"])
+
+	// the device code is redeemed once
+	status, body = pollDevice(t, s, device.DeviceCode)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalid_grant", body["error"])
+
+	// and the user code is used up
+	resp = httpPostForm(t, s, "/oauth/device", form)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestDevice_Deny(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	email, password, _ := registerAndLogin(ctx, t, s)
+	deviceCode, userCode := startDevice(t, s)
+
+	form := url.Values{
+		"user_code": {userCode},
+		"email":     {email},
+		"password":  {"wrong-password"},
+		"decision":  {"deny"},
+	}
+	resp := httpPostForm(t, s, "/oauth/device", form)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Contains(t, readBody(t, resp), "Invalid email or password.")
+
+	form.Set("password", password)
+	resp = httpPostForm(t, s, "/oauth/device", form)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, readBody(t, resp), "Device denied")
+
+	status, body := pollDevice(t, s, deviceCode)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "access_denied", body["error"])
+}
+
+func TestDevice_MFA(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	email, password, token := registerAndLogin(ctx, t, s)
+	userCtx := withToken(ctx, token)
+
+	respEnroll, err := s.MFAClient.EnrollTOTP(userCtx, &authsvcv1.EnrollTOTPRequest{})
+	require.NoError(t, err)
+	now := time.Now()
+	_, err = s.MFAClient.ConfirmTOTP(userCtx, &authsvcv1.ConfirmTOTPRequest{
+		Code: totpCode(t, respEnroll.GetSecret(), now),
+	})
+	require.NoError(t, err)
+
+	deviceCode, userCode := startDevice(t, s)
+
+	resp := httpPostForm(t, s, "/oauth/device", url.Values{
+		"user_code": {userCode},
+		"email":     {email},
+		"password":  {password},
+		"decision":  {"approve"},
+	})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	match := regexp.MustCompile(`name="mfa_challenge" value="([^"]+)"`).FindStringSubmatch(readBody(t, resp))
+	require.Len(t, match, 2)
+
+	resp = httpPostForm(t, s, "/oauth/device", url.Values{
+		"user_code":     {userCode},
+		"decision":      {"approve"},
+		"mfa_challenge": {match[1]},
+		"code":          {totpCode(t, respEnroll.GetSecret(), now.Add(totp.Period))},
+	})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, readBody(t, resp), "Device connected")
+
+	status, body := pollDevice(t, s, deviceCode)
+	require.Equal(t, http.StatusOK, status)
+	claims := tokenClaims(t, body["access_token"].(string))
+	assert.Equal(t, []any{"pwd", "otp", "mfa"}, claims["amr"])
+	assert.Equal(t, "aal2", claims["acr"])
+}
+
+func TestDevice_InvalidRequest(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	status, body := httpPostDeviceAuthorization(t, s, url.Values{"client_id": {"12345"}})
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, "invalid_client", body["error"])
+
+	status, body = httpPostDeviceAuthorization(t, s, url.Values{"client_id": {"999"}, "scope": {"bad\"scope"}})
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalid_scope", body["error"])
+
+	status, body = pollDevice(t, s, "not-a-device-code")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalid_grant", body["error"])
+
+	deviceCode, userCode := expiredDevice(ctx, t, s)
+	status, body = pollDevice(t, s, deviceCode)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "expired_token", body["error"])
+
+	resp := httpGet(t, s, "/oauth/device?user_code="+userCode)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(t, readBody(t, resp), "The code is not valid or has expired.")
+
+	resp = httpGet(t, s, "/oauth/device?user_code=XXXX-XXXX")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = httpGet(t, s, "/oauth/device")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, readBody(t, resp), `name="user_code"`)
+}
+
+// startDevice starts device authorization of the test app
+func startDevice(t *testing.T, s *suite.Suite) (deviceCode string, userCode string) {
+	t.Helper()
+
+	status, body := httpPostDeviceAuthorization(t, s, url.Values{"client_id": {"999"}})
+	require.Equal(t, http.StatusOK, status)
+	return body["device_code"].(string), body["user_code"].(string)
+}
+
+// expiredDevice stores device authorization of the test app which expired
+// a second ago, the server keeps expired ones for a while
+func expiredDevice(ctx context.Context, t *testing.T, s *suite.Suite) (deviceCode string, userCode string) {
+	t.Helper()
+
+	// config paths are relative to the repository root
+	storage, err := sqlite.New(filepath.Join("..", s.Cfg.StoragePath))
+	require.NoError(t, err)
+
+	deviceCode = gofakeit.LetterN(32)
+	userCode = strings.ToUpper(gofakeit.LetterN(4) + "-" + gofakeit.LetterN(4))
+	now := time.Now()
+	_, err = storage.SaveDeviceCode(ctx, models.DeviceCode{
+		AppID:     appID,
+		Interval:  5 * time.Second,
+		ExpiresAt: now.Add(-time.Second),
+	}, secret.Hash(deviceCode), secret.Hash(oauth.NormalizeUserCode(userCode)), now.Add(-time.Hour))
+	require.NoError(t, err)
+
+	return deviceCode, userCode
+}
+
+func pollDevice(t *testing.T, s *suite.Suite, deviceCode string) (int, map[string]any) {
+	t.Helper()
+
+	return httpPostToken(t, s, url.Values{
+		"grant_type":  {deviceCodeGrant},
+		"client_id":   {"999"},
+		"device_code": {deviceCode},
+	}, nil)
+}
+
+func httpPostDeviceAuthorization(t *testing.T, s *suite.Suite, form url.Values) (int, map[string]any) {
+	t.Helper()
+
+	resp := httpPostForm(t, s, "/oauth/device_authorization", form)
+	var body map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	return resp.StatusCode, body
+}
@@ -0,0 +1,211 @@
+package tests
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	authsvcv1 "github.com/Len4i/auth-service/gen/go/authsvc"
+	authjwt "github.com/Len4i/auth-service/internal/lib/jwt"
+	"github.com/Len4i/auth-service/tests/suite"
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestExtAuthz_Check(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	editorID, editorEmail, editor := editorUser(ctx, t, s)
+	admin := adminToken(t)
+	reader := adminTokenWithScope(t, authjwt.Scope{Scopes: []string{"reports:read"}})
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		route     string
+		token     string
+		wantCode  codes.Code
+		wantRoles string
+	}{
+		{
+			name:     "no route",
+			method:   "GET",
+			path:     "/anything",
+			token:    admin,
+			wantCode: codes.PermissionDenied,
+		},
+		{
+			name:     "no route without token",
+			method:   "GET",
+			path:     "/anything",
+			wantCode: codes.PermissionDenied,
+		},
+		{
+			name:     "invalid token",
+			method:   "GET",
+			path:     "/reports/weekly",
+			token:    "invalid",
+			wantCode: codes.Unauthenticated,
+		},
+		{
+			name:     "token needed",
+			method:   "GET",
+			path:     "/reports/weekly",
+			wantCode: codes.Unauthenticated,
+		},
+		{
+			name:   "public",
+			method: "GET",
+			path:   "/public/index.html",
+		},
+		{
+			name:      "role",
+			method:    "POST",
+			path:      "/posts/1?draft=true",
+			token:     editor,
+			wantRoles: "editor",
+		},
+		{
+			name:     "role missing",
+			method:   "POST",
+			path:     "/posts/1",
+			token:    admin,
+			wantCode: codes.PermissionDenied,
+		},
+		{
+			name:     "role of the token is not trusted",
+			method:   "POST",
+			path:     "/posts/1",
+			token:    adminTokenWithScope(t, authjwt.Scope{Roles: []string{"editor"}}),
+			wantCode: codes.PermissionDenied,
+		},
+		{
+			name:     "other method",
+			method:   "GET",
+			path:     "/posts/1",
+			token:    editor,
+			wantCode: codes.PermissionDenied,
+		},
+		{
+			name:      "named route",
+			method:    "DELETE",
+			path:      "/posts/1",
+			route:     "admin",
+			token:     admin,
+			wantRoles: "admin",
+		},
+		{
+			name:     "named route denied",
+			method:   "DELETE",
+			path:     "/posts/1",
+			route:    "admin",
+			token:    editor,
+			wantCode: codes.PermissionDenied,
+		},
+		{
+			name:     "unknown route",
+			method:   "GET",
+			path:     "/anything",
+			route:    "unknown",
+			token:    admin,
+			wantCode: codes.PermissionDenied,
+		},
+		{
+			name:      "scope",
+			method:    "GET",
+			path:      "/reports/weekly",
+			token:     reader,
+			wantRoles: "admin",
+		},
+		{
+			name:     "scope missing",
+			method:   "GET",
+			path:     "/reports/weekly",
+			token:    adminTokenWithScope(t, authjwt.Scope{Scopes: []string{"posts:read"}}),
+			wantCode: codes.PermissionDenied,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := map[string]string{"x-auth-user-id": "1"}
+			if tt.token != "" {
+				headers["authorization"] = "Bearer " + tt.token
+			}
+			req := &authv3.CheckRequest{Attributes: &authv3.AttributeContext{
+				Request: &authv3.AttributeContext_Request{Http: &authv3.AttributeContext_HttpRequest{
+					Method:  tt.method,
+					Path:    tt.path,
+					Headers: headers,
+				}},
+			}}
+			if tt.route != "" {
+				req.Attributes.ContextExtensions = map[string]string{"route": tt.route}
+			}
+
+			resp, err := s.ExtAuthzClient.Check(ctx, req)
+			require.NoError(t, err)
+			require.Equal(t, int32(tt.wantCode), resp.GetStatus().GetCode())
+
+			switch tt.wantCode {
+			case codes.OK:
+				ok := resp.GetOkResponse()
+				require.NotNil(t, ok)
+				if tt.token == "" {
+					// identity headers of the client are removed
+					assert.Contains(t, ok.GetHeadersToRemove(), "x-auth-user-id")
+					assert.Empty(t, ok.GetHeaders())
+					return
+				}
+				got := okHeaders(ok.GetHeaders())
+				if tt.token == editor {
+					assert.Equal(t, strconv.FormatInt(editorID, 10), got["x-auth-user-id"])
+					assert.Equal(t, editorEmail, got["x-auth-email"])
+				} else {
+					assert.Equal(t, strconv.Itoa(adminUserID), got["x-auth-user-id"])
+					assert.Equal(t, adminEmail, got["x-auth-email"])
+				}
+				assert.Equal(t, strconv.Itoa(appID), got["x-auth-app-id"])
+				assert.Equal(t, tt.wantRoles, got["x-auth-roles"])
+				assert.Contains(t, ok.GetHeadersToRemove(), "x-auth-org-id")
+			case codes.Unauthenticated:
+				assert.Equal(t, typev3.StatusCode_Unauthorized, resp.GetDeniedResponse().GetStatus().GetCode())
+				assert.Equal(t, "Bearer", okHeaders(resp.GetDeniedResponse().GetHeaders())["www-authenticate"])
+			default:
+				assert.Equal(t, typev3.StatusCode_Forbidden, resp.GetDeniedResponse().GetStatus().GetCode())
+			}
+		})
+	}
+}
+
+func okHeaders(options []*corev3.HeaderValueOption) map[string]string {
+	headers := make(map[string]string, len(options))
+	for _, option := range options {
+		headers[option.GetHeader().GetKey()] = option.GetHeader().GetValue()
+	}
+	return headers
+}
+
+// editorUser registers user with the editor role of the app the route
+// policies of the test config require
+func editorUser(ctx context.Context, t *testing.T, s *suite.Suite) (userID int64, email string, token string) {
+	t.Helper()
+
+	adminCtx := withToken(ctx, adminToken(t))
+	_, err := s.RBACClient.CreateRole(adminCtx, &authsvcv1.CreateRoleRequest{AppId: appID, Name: "editor"})
+	if status.Code(err) != codes.AlreadyExists {
+		require.NoError(t, err)
+	}
+
+	email, _, token = registerAndLogin(ctx, t, s)
+	userID = tokenUserID(t, token)
+	_, err = s.RBACClient.AssignRole(adminCtx, &authsvcv1.AssignRoleRequest{UserId: userID, AppId: appID, Role: "editor"})
+	require.NoError(t, err)
+
+	return userID, email, token
+}
@@ -0,0 +1,237 @@
+package tests
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Len4i/auth-service/tests/mockoidc"
+	"github.com/Len4i/auth-service/tests/suite"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	upstreamOnce sync.Once
+	upstream     *mockoidc.Provider
+	upstreamErr  error
+)
+
+// upstreamProvider starts the mock provider of test connectors once for all tests
+func upstreamProvider(t *testing.T, s *suite.Suite) *mockoidc.Provider {
+	t.Helper()
+
+	upstreamOnce.Do(func() {
+		connector := s.Cfg.Federation.Connectors[0]
+		upstream, upstreamErr = mockoidc.New(connector.Issuer, map[string]string{
+			s.Cfg.Federation.Connectors[0].ClientID: s.Cfg.Federation.Connectors[0].ClientSecret,
+			s.Cfg.Federation.Connectors[1].ClientID: s.Cfg.Federation.Connectors[1].ClientSecret,
+		})
+		if upstreamErr != nil {
+			return
+		}
+
+		issuer, err := url.Parse(connector.Issuer)
+		if err != nil {
+			upstreamErr = err
+			return
+		}
+		l, err := net.Listen("tcp", issuer.Host)
+		if err != nil {
+			upstreamErr = err
+			return
+		}
+		go func() { _ = http.Serve(l, upstream) }()
+	})
+	require.NoError(t, upstreamErr)
+
+	return upstream
+}
+
+func TestFederation_Provisioning(t *testing.T) {
+	_, s := suite.New(t)
+	provider := upstreamProvider(t, s)
+
+	user := mockoidc.User{
+		Subject:       gofakeit.UUID(),
+		Email:         gofakeit.Email(),
+		EmailVerified: true,
+		Name:          "Grace Hopper",
+	}
+	provider.AddUser(user)
+
+	claims := federatedToken(t, s, "mock", user.Subject)
+	assert.Equal(t, user.Email, claims["email"])
+	assert.Equal(t, []any{"fed"}, claims["amr"])
+	assert.Equal(t, "aal1", claims["acr"])
+
+	// the identity is linked to the provisioned user
+	assert.Equal(t, claims["user_id"], federatedToken(t, s, "mock", user.Subject)["user_id"])
+
+	// without a verified email nobody is provisioned
+	unverified := mockoidc.User{Subject: gofakeit.UUID(), Email: gofakeit.Email()}
+	provider.AddUser(unverified)
+	resp := federatedLogin(t, s, "mock", unverified.Subject)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Contains(t, readBody(t, resp), "There is no account for this sign in.")
+
+	// and the strict connector doesn't provision at all
+	stranger := mockoidc.User{Subject: gofakeit.UUID(), Email: gofakeit.Email(), EmailVerified: true}
+	provider.AddUser(stranger)
+	resp = federatedLogin(t, s, "strict", stranger.Subject)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestFederation_LinkByEmail(t *testing.T) {
+	ctx, s := suite.New(t)
+	provider := upstreamProvider(t, s)
+
+	email, _, token := registerAndLogin(ctx, t, s)
+	userID := tokenClaims(t, token)["user_id"]
+
+	subject := gofakeit.UUID()
+	provider.AddUser(mockoidc.User{Subject: subject, Email: email, EmailVerified: true})
+
+	claims := federatedToken(t, s, "mock", subject)
+	assert.Equal(t, userID, claims["user_id"])
+	assert.Equal(t, email, claims["email"])
+}
+
+func TestFederation_ExplicitLink(t *testing.T) {
+	ctx, s := suite.New(t)
+	provider := upstreamProvider(t, s)
+
+	email, password, token := registerAndLogin(ctx, t, s)
+	userID := tokenClaims(t, token)["user_id"]
+
+	// the strict connector doesn't trust emails, even verified ones
+	subject := gofakeit.UUID()
+	provider.AddUser(mockoidc.User{Subject: subject, Email: email, EmailVerified: true})
+
+	verifier, challenge := pkcePair(t)
+	resp := federatedLoginWithChallenge(t, s, "strict", subject, challenge)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body := readBody(t, resp)
+	assert.Contains(t, body, email)
+	match := linkTokenRe.FindStringSubmatch(body)
+	require.Len(t, match, 2)
+
+	form := url.Values{"link_token": {match[1]}, "password": {"wrong-password"}}
+	resp = httpPostForm(t, s, "/oauth/callback", form)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Contains(t, readBody(t, resp), "Invalid password.")
+
+	form.Set("password", password)
+	code := redirectQuery(t, httpPostForm(t, s, "/oauth/callback", form)).Get("code")
+	require.NotEmpty(t, code)
+
+	status, tokens := exchangeCode(t, s, code, verifier)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, userID, tokenClaims(t, tokens["access_token"].(string))["user_id"])
+
+	// the link is used up and the identity logs in directly from now on
+	resp = httpPostForm(t, s, "/oauth/callback", form)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, userID, federatedToken(t, s, "strict", subject)["user_id"])
+}
+
+func TestFederation_UnverifiedEmail(t *testing.T) {
+	ctx, s := suite.New(t)
+	provider := upstreamProvider(t, s)
+
+	email, _, _ := registerAndLogin(ctx, t, s)
+
+	// unverified emails are never linked automatically
+	subject := gofakeit.UUID()
+	provider.AddUser(mockoidc.User{Subject: subject, Email: email})
+
+	resp := federatedLogin(t, s, "mock", subject)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Regexp(t, linkTokenRe, readBody(t, resp))
+}
+
+func TestFederation_InvalidCallback(t *testing.T) {
+	_, s := suite.New(t)
+	upstreamProvider(t, s)
+
+	// the provider denies unknown users, user is sent back to the app
+	query := redirectQuery(t, federatedLogin(t, s, "mock", "unknown-subject"))
+	assert.Equal(t, "access_denied", query.Get("error"))
+	assert.Equal(t, "federated-state", query.Get("state"))
+
+	resp := httpGet(t, s, "/oauth/callback?"+url.Values{"state": {"not-a-state"}, "code": {"code"}}.Encode())
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(t, readBody(t, resp), "The sign in has expired, please try again.")
+
+	_, challenge := pkcePair(t)
+	params := authorizeParams(challenge)
+	params.Set("connector", "unknown")
+	resp = httpPostForm(t, s, "/oauth/authorize", params)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+var linkTokenRe = regexp.MustCompile(`name="link_token" value="([^"]+)"`)
+
+// federatedToken signs in with the connector as the upstream user
+// and returns claims of the token the code is exchanged for
+func federatedToken(t *testing.T, s *suite.Suite, connector string, subject string) map[string]any {
+	t.Helper()
+
+	verifier, challenge := pkcePair(t)
+	code := redirectQuery(t, federatedLoginWithChallenge(t, s, connector, subject, challenge)).Get("code")
+	require.NotEmpty(t, code)
+
+	status, body := exchangeCode(t, s, code, verifier)
+	require.Equal(t, http.StatusOK, status)
+	return tokenClaims(t, body["access_token"].(string))
+}
+
+func federatedLogin(t *testing.T, s *suite.Suite, connector string, subject string) *http.Response {
+	t.Helper()
+
+	_, challenge := pkcePair(t)
+	return federatedLoginWithChallenge(t, s, connector, subject, challenge)
+}
+
+// federatedLoginWithChallenge runs the login through the connector as a browser
+// would and returns the response of the callback
+func federatedLoginWithChallenge(
+	t *testing.T,
+	s *suite.Suite,
+	connector string,
+	subject string,
+	challenge string,
+) *http.Response {
+	t.Helper()
+
+	params := authorizeParams(challenge)
+	params.Set("state", "federated-state")
+	resp := httpGet(t, s, "/oauth/authorize?"+params.Encode())
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, readBody(t, resp), `value="`+connector+`"`)
+
+	params.Set("connector", connector)
+	resp = httpPostForm(t, s, "/oauth/authorize", params)
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	// the user picks the upstream account
+	upstreamURL, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	query := upstreamURL.Query()
+	query.Set("login_hint", subject)
+	upstreamURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, upstreamURL.String(), nil)
+	require.NoError(t, err)
+	resp = httpDo(t, req)
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	callback := resp.Header.Get("Location")
+	require.True(t, strings.HasPrefix(callback, s.HTTPURL+"/oauth/callback?"), callback)
+	return httpGet(t, s, strings.TrimPrefix(callback, s.HTTPURL))
+}
@@ -0,0 +1,197 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	authsvcv1 "github.com/Len4i/auth-service/gen/go/authsvc"
+	"github.com/Len4i/auth-service/tests/suite"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForwardAuth_Token(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	userID, email, token, roles := userWithRoles(ctx, t, s, "editor", "viewer")
+	editor, viewer := roles[0], roles[1]
+
+	tests := []struct {
+		name       string
+		header     string
+		query      string
+		wantStatus int
+	}{
+		{
+			name:       "valid",
+			header:     "Bearer " + token,
+			query:      "?app_id=999",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "role held",
+			header:     "Bearer " + token,
+			query:      "?app_id=999&role=admin&role=" + viewer,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "role missing",
+			header:     "Bearer " + token,
+			query:      "?app_id=999&role=admin",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "other app",
+			header:     "Bearer " + token,
+			query:      "?app_id=998",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "app missing",
+			header:     "Bearer " + token,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid app",
+			header:     "Bearer " + token,
+			query:      "?app_id=abc",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid token",
+			header:     "Bearer invalid",
+			query:      "?app_id=999",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "not bearer",
+			header:     "Basic dXNlcjpwYXNz",
+			query:      "?app_id=999",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "no credentials",
+			query:      "?app_id=999",
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := forwardAuthWithToken(t, s, tt.header, tt.query)
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantStatus == http.StatusUnauthorized {
+				assert.Equal(t, "Bearer", resp.Header.Get("WWW-Authenticate"))
+			}
+			if tt.wantStatus != http.StatusOK {
+				assert.Empty(t, resp.Header.Get("X-Auth-User-Id"))
+				return
+			}
+			assert.Equal(t, strconv.FormatInt(userID, 10), resp.Header.Get("X-Auth-User-Id"))
+			assert.Equal(t, email, resp.Header.Get("X-Auth-Email"))
+			assert.Equal(t, editor+","+viewer, resp.Header.Get("X-Auth-Roles"))
+			assert.Equal(t, strconv.Itoa(appID), resp.Header.Get("X-Auth-App-Id"))
+		})
+	}
+}
+
+func TestForwardAuth_RevokedRole(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	userID, _, token, roles := userWithRoles(ctx, t, s, "editor")
+
+	resp := forwardAuthWithToken(t, s, "Bearer "+token, "?app_id=999&role="+roles[0])
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	_, err := s.RBACClient.RevokeRole(withToken(ctx, adminToken(t)), &authsvcv1.RevokeRoleRequest{
+		UserId: userID,
+		AppId:  appID,
+		Role:   roles[0],
+	})
+	require.NoError(t, err)
+
+	// roles in the token don't outlive their revocation
+	resp = forwardAuthWithToken(t, s, "Bearer "+token, "?app_id=999&role="+roles[0])
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestForwardAuth_Session(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	email, password, _ := registerAndLogin(ctx, t, s)
+	csrfCookie, csrfToken := sessionCSRF(t, s)
+	resp, body := sessionCall(t, s, http.MethodPost, "/session/login", []*http.Cookie{csrfCookie}, csrfToken, map[string]any{
+		"email":    email,
+		"password": password,
+		"app_id":   appID,
+	})
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	cookie := responseCookie(t, resp, sessionCookieName)
+
+	forwardAuth := func(method string, cookie *http.Cookie) *http.Response {
+		req, err := http.NewRequest(method, s.HTTPURL+"/forward-auth?app_id=999", nil)
+		require.NoError(t, err)
+		req.Header.Set("X-Forwarded-Method", method)
+		req.Header.Set("X-Forwarded-Uri", "/internal/tool")
+		req.AddCookie(&http.Cookie{Name: cookie.Name, Value: cookie.Value})
+		return httpDo(t, req)
+	}
+
+	// proxies may forward the method of the original request
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		resp = forwardAuth(method, cookie)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, email, resp.Header.Get("X-Auth-Email"))
+		assert.NotEmpty(t, resp.Header.Get("X-Auth-User-Id"))
+		assert.Equal(t, "no-store", resp.Header.Get("Cache-Control"))
+	}
+
+	resp = forwardAuth(http.MethodGet, &http.Cookie{Name: sessionCookieName, Value: "invalid"})
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp, _ = sessionCall(t, s, http.MethodPost, "/session/logout", []*http.Cookie{cookie}, body["csrf_token"].(string), nil)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp = forwardAuth(http.MethodGet, cookie)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func forwardAuthWithToken(t *testing.T, s *suite.Suite, header string, query string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, s.HTTPURL+"/forward-auth"+query, nil)
+	require.NoError(t, err)
+	if header != "" {
+		req.Header.Set("Authorization", header)
+	}
+	return httpDo(t, req)
+}
+
+// userWithRoles registers user and assigns roles of the app created for the
+// test, names of the roles are those with a random suffix
+func userWithRoles(
+	ctx context.Context,
+	t *testing.T,
+	s *suite.Suite,
+	roles ...string,
+) (userID int64, email string, token string, names []string) {
+	t.Helper()
+
+	adminCtx := withToken(ctx, adminToken(t))
+	email, _, token = registerAndLogin(ctx, t, s)
+	userID = tokenUserID(t, token)
+
+	suffix := strings.ToLower(gofakeit.LetterN(8))
+	for _, role := range roles {
+		name := role + "-" + suffix
+		_, err := s.RBACClient.CreateRole(adminCtx, &authsvcv1.CreateRoleRequest{AppId: appID, Name: name})
+		require.NoError(t, err)
+		_, err = s.RBACClient.AssignRole(adminCtx, &authsvcv1.AssignRoleRequest{UserId: userID, AppId: appID, Role: name})
+		require.NoError(t, err)
+		names = append(names, name)
+	}
+
+	return userID, email, token, names
+}
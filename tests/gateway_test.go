@@ -0,0 +1,289 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	authsvcv1 "github.com/Len4i/auth-service/gen/go/authsvc"
+	"github.com/Len4i/auth-service/tests/suite"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const gatewayOrigin = "https://localhost:3000"
+
+// gatewayError is the error body of the gateway
+type gatewayError struct {
+	Error struct {
+		Code    int               `json:"code"`
+		Status  string            `json:"status"`
+		Message string            `json:"message"`
+		Details []json.RawMessage `json:"details"`
+	} `json:"error"`
+}
+
+func TestGateway_RegisterLogin(t *testing.T) {
+	_, s := suite.New(t)
+
+	email := gofakeit.Email()
+	password := randomFakePass(passDefaultLen)
+
+	var respReg struct {
+		UserID string `json:"user_id"`
+	}
+	resp := gatewayCall(t, s, "/v1/auth/register", nil, map[string]any{"email": email, "password": password}, &respReg)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	userID, err := strconv.ParseInt(respReg.UserID, 10, 64)
+	require.NoError(t, err)
+	assert.NotZero(t, userID)
+
+	var respLogin struct {
+		Token string `json:"token"`
+	}
+	resp = gatewayCall(t, s, "/v1/auth/login", nil, map[string]any{
+		"email":    email,
+		"password": password,
+		"app_id":   appID,
+	}, &respLogin)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.NotEmpty(t, respLogin.Token)
+
+	// unpopulated fields are sent too
+	var respAdmin map[string]any
+	resp = gatewayCall(t, s, "/v1/auth/is-admin", nil, map[string]any{"user_id": respReg.UserID}, &respAdmin)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, map[string]any{"is_admin": false}, respAdmin)
+
+	resp = gatewayCall(t, s, "/v1/auth/is-admin", nil, map[string]any{"user_id": adminUserID}, &respAdmin)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, map[string]any{"is_admin": true}, respAdmin)
+}
+
+func TestGateway_Errors(t *testing.T) {
+	_, s := suite.New(t)
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		header      http.Header
+		body        string
+		wantCode    int
+		wantStatus  string
+		wantMessage string
+	}{
+		{
+			name:        "invalid argument",
+			path:        "/v1/auth/login",
+			body:        `{"password": "secret", "app_id": 999}`,
+			wantCode:    http.StatusBadRequest,
+			wantStatus:  "INVALID_ARGUMENT",
+			wantMessage: "email is required",
+		},
+		{
+			name:        "unknown field",
+			path:        "/v1/auth/login",
+			body:        `{"login": "user@localhost.com"}`,
+			wantCode:    http.StatusBadRequest,
+			wantStatus:  "INVALID_ARGUMENT",
+			wantMessage: "request body is not valid",
+		},
+		{
+			name:        "not json",
+			path:        "/v1/auth/register",
+			body:        `email=user@localhost.com`,
+			wantCode:    http.StatusBadRequest,
+			wantStatus:  "INVALID_ARGUMENT",
+			wantMessage: "request body is not valid",
+		},
+		{
+			name:        "invalid token",
+			path:        "/v1/auth/is-admin",
+			header:      http.Header{"Authorization": {"Bearer invalid"}},
+			body:        `{"user_id": 999}`,
+			wantCode:    http.StatusUnauthorized,
+			wantStatus:  "UNAUTHENTICATED",
+			wantMessage: "invalid token",
+		},
+		{
+			name:        "metadata",
+			path:        "/v1/auth/register",
+			header:      http.Header{"X-App-Id": {"invalid"}},
+			body:        `{"email": "user@localhost.com", "password": "secret"}`,
+			wantCode:    http.StatusBadRequest,
+			wantStatus:  "INVALID_ARGUMENT",
+			wantMessage: "app id is not valid",
+		},
+		{
+			name:        "unknown method",
+			path:        "/v1/auth/logout",
+			body:        `{}`,
+			wantCode:    http.StatusNotFound,
+			wantStatus:  "NOT_FOUND",
+			wantMessage: "method not found",
+		},
+		{
+			name:        "http method",
+			method:      http.MethodGet,
+			path:        "/v1/auth/login",
+			wantCode:    http.StatusMethodNotAllowed,
+			wantStatus:  "UNIMPLEMENTED",
+			wantMessage: "method not allowed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			req, err := http.NewRequest(method, s.GatewayURL+tt.path, bytes.NewBufferString(tt.body))
+			require.NoError(t, err)
+			for name, values := range tt.header {
+				req.Header[name] = values
+			}
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			var body gatewayError
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+			assert.Equal(t, tt.wantCode, resp.StatusCode)
+			assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+			assert.Equal(t, tt.wantCode, body.Error.Code)
+			assert.Equal(t, tt.wantStatus, body.Error.Status)
+			assert.Equal(t, tt.wantMessage, body.Error.Message)
+		})
+	}
+}
+
+func TestGateway_MFARequired(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	email, password, token := registerAndLogin(ctx, t, s)
+	userCtx := withToken(ctx, token)
+	respEnroll, err := s.MFAClient.EnrollTOTP(userCtx, &authsvcv1.EnrollTOTPRequest{})
+	require.NoError(t, err)
+	_, err = s.MFAClient.ConfirmTOTP(userCtx, &authsvcv1.ConfirmTOTPRequest{
+		Code: totpCode(t, respEnroll.GetSecret(), time.Now()),
+	})
+	require.NoError(t, err)
+
+	var body gatewayError
+	resp := gatewayCall(t, s, "/v1/auth/login", nil, map[string]any{
+		"email":    email,
+		"password": password,
+		"app_id":   appID,
+	}, &body)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "FAILED_PRECONDITION", body.Error.Status)
+	require.Len(t, body.Error.Details, 1)
+
+	var info struct {
+		Type     string            `json:"@type"`
+		Reason   string            `json:"reason"`
+		Metadata map[string]string `json:"metadata"`
+	}
+	require.NoError(t, json.Unmarshal(body.Error.Details[0], &info))
+	assert.Equal(t, "type.googleapis.com/google.rpc.ErrorInfo", info.Type)
+	assert.Equal(t, "MFA_REQUIRED", info.Reason)
+	assert.NotEmpty(t, info.Metadata["challenge"])
+	assert.Equal(t, "totp", info.Metadata["methods"])
+}
+
+func TestGateway_CORS(t *testing.T) {
+	_, s := suite.New(t)
+
+	preflight := func(origin string) *http.Response {
+		req, err := http.NewRequest(http.MethodOptions, s.GatewayURL+"/v1/auth/login", nil)
+		require.NoError(t, err)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		req.Header.Set("Access-Control-Request-Headers", "content-type, x-org-id")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	resp := preflight(gatewayOrigin)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, gatewayOrigin, resp.Header.Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, resp.Header.Get("Access-Control-Allow-Methods"), http.MethodPost)
+	assert.Contains(t, resp.Header.Get("Access-Control-Allow-Headers"), "X-Org-Id")
+	assert.Equal(t, "600", resp.Header.Get("Access-Control-Max-Age"))
+
+	resp = preflight("https://evil.example.com")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("Access-Control-Allow-Origin"))
+
+	resp = gatewayCall(t, s, "/v1/auth/is-admin", http.Header{"Origin": {gatewayOrigin}}, map[string]any{
+		"user_id": adminUserID,
+	}, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, gatewayOrigin, resp.Header.Get("Access-Control-Allow-Origin"))
+}
+
+func TestGateway_OpenAPI(t *testing.T) {
+	_, s := suite.New(t)
+
+	resp, err := http.Get(s.GatewayURL + "/openapi.json")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var doc struct {
+		OpenAPI    string                                `json:"openapi"`
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]struct {
+					Type   string `json:"type"`
+					Format string `json:"format"`
+				} `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&doc))
+	assert.Equal(t, "3.0.3", doc.OpenAPI)
+	for _, path := range []string{"/v1/auth/register", "/v1/auth/login", "/v1/auth/is-admin"} {
+		require.Contains(t, doc.Paths, path)
+		assert.Contains(t, doc.Paths[path], "post")
+	}
+
+	login := doc.Components.Schemas["auth.LoginRequest"]
+	assert.Equal(t, "string", login.Properties["email"].Type)
+	assert.Equal(t, "integer", login.Properties["app_id"].Type)
+	userID := doc.Components.Schemas["auth.RegisterResponse"].Properties["user_id"]
+	assert.Equal(t, "string", userID.Type)
+	assert.Equal(t, "int64", userID.Format)
+	assert.Contains(t, doc.Components.Schemas, "Error")
+}
+
+// gatewayCall posts body to the gateway method at path and decodes
+// response into out if it is set
+func gatewayCall(t *testing.T, s *suite.Suite, path string, header http.Header, body any, out any) *http.Response {
+	t.Helper()
+
+	reqBody, err := json.Marshal(body)
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPost, s.GatewayURL+path, bytes.NewReader(reqBody))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	if out != nil {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(out))
+	}
+	return resp
+}
@@ -0,0 +1,260 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	aaav1 "github.com/Len4i/aaa/gen/go/aaa"
+	authsvcv1 "github.com/Len4i/auth-service/gen/go/authsvc"
+	"github.com/Len4i/auth-service/tests/suite"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/proto"
+)
+
+const webOrigin = "https://localhost:3000"
+
+func TestGRPCWeb_Protocols(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	email := gofakeit.Email()
+	password := randomFakePass(passDefaultLen)
+
+	register := webClient[aaav1.RegisterRequest, aaav1.RegisterResponse](s, "Register", connect.WithGRPCWeb())
+	respReg, err := register.CallUnary(ctx, connect.NewRequest(&aaav1.RegisterRequest{
+		Email:    email,
+		Password: password,
+	}))
+	require.NoError(t, err)
+	assert.NotEmpty(t, respReg.Msg.GetUserId())
+
+	protocols := map[string][]connect.ClientOption{
+		"grpc-web":      {connect.WithGRPCWeb()},
+		"grpc-web json": {connect.WithGRPCWeb(), connect.WithProtoJSON()},
+		"connect":       nil,
+		"connect json":  {connect.WithProtoJSON()},
+	}
+	for name, opts := range protocols {
+		t.Run(name, func(t *testing.T) {
+			login := webClient[aaav1.LoginRequest, aaav1.LoginResponse](s, "Login", opts...)
+			respLogin, err := login.CallUnary(ctx, connect.NewRequest(&aaav1.LoginRequest{
+				Email:    email,
+				Password: password,
+				AppId:    appID,
+			}))
+			require.NoError(t, err)
+			assert.NotEmpty(t, respLogin.Msg.GetToken())
+
+			_, err = login.CallUnary(ctx, connect.NewRequest(&aaav1.LoginRequest{Password: password, AppId: appID}))
+			require.Error(t, err)
+			assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+			assert.Equal(t, "email is required", connectMessage(err))
+
+			// the token authenticates calls as on native gRPC
+			enroll := webClient[authsvcv1.EnrollTOTPRequest, authsvcv1.EnrollTOTPResponse](s, "/authsvc.MFA/EnrollTOTP", opts...)
+			_, err = enroll.CallUnary(ctx, connect.NewRequest(&authsvcv1.EnrollTOTPRequest{}))
+			require.Error(t, err)
+			assert.Equal(t, connect.CodeUnauthenticated, connect.CodeOf(err))
+
+			req := connect.NewRequest(&authsvcv1.EnrollTOTPRequest{})
+			req.Header().Set("Authorization", "Bearer "+respLogin.Msg.GetToken())
+			respEnroll, err := enroll.CallUnary(ctx, req)
+			require.NoError(t, err)
+			assert.NotEmpty(t, respEnroll.Msg.GetSecret())
+		})
+	}
+}
+
+func TestGRPCWeb_ErrorDetails(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	email, password, token := registerAndLogin(ctx, t, s)
+	userCtx := withToken(ctx, token)
+	respEnroll, err := s.MFAClient.EnrollTOTP(userCtx, &authsvcv1.EnrollTOTPRequest{})
+	require.NoError(t, err)
+	_, err = s.MFAClient.ConfirmTOTP(userCtx, &authsvcv1.ConfirmTOTPRequest{
+		Code: totpCode(t, respEnroll.GetSecret(), time.Now()),
+	})
+	require.NoError(t, err)
+
+	for name, opts := range map[string][]connect.ClientOption{
+		"grpc-web": {connect.WithGRPCWeb()},
+		"connect":  nil,
+	} {
+		t.Run(name, func(t *testing.T) {
+			login := webClient[aaav1.LoginRequest, aaav1.LoginResponse](s, "Login", opts...)
+			_, err := login.CallUnary(ctx, connect.NewRequest(&aaav1.LoginRequest{
+				Email:    email,
+				Password: password,
+				AppId:    appID,
+			}))
+			require.Error(t, err)
+			assert.Equal(t, connect.CodeFailedPrecondition, connect.CodeOf(err))
+
+			var connectErr *connect.Error
+			require.ErrorAs(t, err, &connectErr)
+			require.Len(t, connectErr.Details(), 1)
+			detail, err := connectErr.Details()[0].Value()
+			require.NoError(t, err)
+			info, ok := detail.(*errdetails.ErrorInfo)
+			require.True(t, ok)
+			assert.Equal(t, "MFA_REQUIRED", info.GetReason())
+			assert.NotEmpty(t, info.GetMetadata()["challenge"])
+		})
+	}
+}
+
+func TestGRPCWeb_Origins(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	tests := []struct {
+		name     string
+		origin   string
+		appID    int32
+		wantCode connect.Code
+	}{
+		{
+			name:   "allowed",
+			origin: webOrigin,
+			appID:  appID,
+		},
+		{
+			name:     "not allowed",
+			origin:   "https://evil.example.com",
+			appID:    appID,
+			wantCode: connect.CodePermissionDenied,
+		},
+		{
+			name:     "not allowed by app",
+			origin:   webOrigin,
+			appID:    notExistAppID,
+			wantCode: connect.CodePermissionDenied,
+		},
+		{
+			name:     "no app",
+			origin:   webOrigin,
+			wantCode: connect.CodePermissionDenied,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			login := webClient[aaav1.LoginRequest, aaav1.LoginResponse](s, "Login", connect.WithGRPCWeb())
+			req := connect.NewRequest(&aaav1.LoginRequest{
+				Email:    profileUserEmail,
+				Password: profileUserPass,
+				AppId:    tt.appID,
+			})
+			req.Header().Set("Origin", tt.origin)
+			_, err := login.CallUnary(ctx, req)
+			if tt.wantCode == 0 {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Equal(t, tt.wantCode, connect.CodeOf(err))
+		})
+	}
+
+	// the app of metadata applies to requests without it
+	register := webClient[aaav1.RegisterRequest, aaav1.RegisterResponse](s, "Register")
+	req := connect.NewRequest(&aaav1.RegisterRequest{Email: gofakeit.Email(), Password: randomFakePass(passDefaultLen)})
+	req.Header().Set("Origin", webOrigin)
+	req.Header().Set("X-App-Id", "999")
+	_, err := register.CallUnary(ctx, req)
+	require.NoError(t, err)
+}
+
+func TestGRPCWeb_CORS(t *testing.T) {
+	_, s := suite.New(t)
+
+	preflight := func(origin string) *http.Response {
+		req, err := http.NewRequest(http.MethodOptions, s.GRPCWebURL+"/auth.Auth/Login", nil)
+		require.NoError(t, err)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		req.Header.Set("Access-Control-Request-Headers", "content-type, x-grpc-web")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	resp := preflight(webOrigin)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, webOrigin, resp.Header.Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, resp.Header.Get("Access-Control-Allow-Headers"), "X-Grpc-Web")
+	assert.Contains(t, resp.Header.Get("Access-Control-Allow-Headers"), "Connect-Protocol-Version")
+
+	resp = preflight("https://evil.example.com")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("Access-Control-Allow-Origin"))
+}
+
+func TestGRPCWeb_Text(t *testing.T) {
+	_, s := suite.New(t)
+
+	msg, err := proto.Marshal(&aaav1.IsAdminRequest{UserId: adminUserID})
+	require.NoError(t, err)
+	body := base64.StdEncoding.EncodeToString(webEnvelope(0, msg))
+
+	req, err := http.NewRequest(http.MethodPost, s.GRPCWebURL+"/auth.Auth/IsAdmin", strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/grpc-web-text")
+	req.Header.Set("Origin", webOrigin)
+	req.Header.Set("X-App-Id", "999")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/grpc-web-text+proto", resp.Header.Get("Content-Type"))
+	assert.Equal(t, webOrigin, resp.Header.Get("Access-Control-Allow-Origin"))
+
+	respBody, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, resp.Body))
+	require.NoError(t, err)
+
+	size := binary.BigEndian.Uint32(respBody[1:5])
+	var respMsg aaav1.IsAdminResponse
+	require.NoError(t, proto.Unmarshal(respBody[5:5+size], &respMsg))
+	assert.True(t, respMsg.GetIsAdmin())
+
+	trailer := respBody[5+size:]
+	require.Equal(t, byte(0x80), trailer[0])
+	assert.Equal(t, "grpc-status: 0\r\n", string(trailer[5:]))
+}
+
+// webClient returns client of the Auth method, or of the procedure
+// if method is a full path
+func webClient[Req, Res any](s *suite.Suite, method string, opts ...connect.ClientOption) *connect.Client[Req, Res] {
+	procedure := method
+	if !strings.HasPrefix(method, "/") {
+		procedure = "/auth.Auth/" + method
+	}
+	return connect.NewClient[Req, Res](http.DefaultClient, s.GRPCWebURL+procedure, opts...)
+}
+
+// connectMessage returns message of the error without code prefix
+func connectMessage(err error) string {
+	var connectErr *connect.Error
+	if !errors.As(err, &connectErr) {
+		return err.Error()
+	}
+	return connectErr.Message()
+}
+
+func webEnvelope(flags byte, msg []byte) []byte {
+	var b bytes.Buffer
+	b.WriteByte(flags)
+	_ = binary.Write(&b, binary.BigEndian, uint32(len(msg)))
+	b.Write(msg)
+	return b.Bytes()
+}
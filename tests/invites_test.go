@@ -0,0 +1,171 @@
+package tests
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	aaav1 "github.com/Len4i/aaa/gen/go/aaa"
+	authsvcv1 "github.com/Len4i/auth-service/gen/go/authsvc"
+	"github.com/Len4i/auth-service/internal/domain/models"
+	authjwt "github.com/Len4i/auth-service/internal/lib/jwt"
+	"github.com/Len4i/auth-service/tests/suite"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	inviteOnlyAppID = 997
+	adminEmail      = "admin-user@localhost.com"
+)
+
+func TestInvites_InviteOnlyRegistration(t *testing.T) {
+	ctx, s := suite.New(t)
+	adminCtx := withToken(ctx, adminToken(t))
+
+	respInvite, err := s.InvitesClient.CreateInvite(adminCtx, &authsvcv1.CreateInviteRequest{
+		AppId: inviteOnlyAppID,
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, respInvite.GetCode())
+	assert.EqualValues(t, 1, respInvite.GetInvite().GetMaxUses())
+
+	appCtx := metadata.AppendToOutgoingContext(ctx, "x-app-id", strconv.Itoa(inviteOnlyAppID))
+
+	_, err = s.AuthClient.Register(appCtx, &aaav1.RegisterRequest{
+		Email:    gofakeit.Email(),
+		Password: randomFakePass(passDefaultLen),
+	})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = PermissionDenied desc = invite code is required")
+
+	inviteCtx := metadata.AppendToOutgoingContext(appCtx, "x-invite-code", respInvite.GetCode())
+
+	respReg, err := s.AuthClient.Register(inviteCtx, &aaav1.RegisterRequest{
+		Email:    gofakeit.Email(),
+		Password: randomFakePass(passDefaultLen),
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, respReg.GetUserId())
+
+	// single-use invite is used up
+	_, err = s.AuthClient.Register(inviteCtx, &aaav1.RegisterRequest{
+		Email:    gofakeit.Email(),
+		Password: randomFakePass(passDefaultLen),
+	})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = PermissionDenied desc = invite code is not valid")
+
+	respList, err := s.InvitesClient.ListInvites(adminCtx, &authsvcv1.ListInvitesRequest{
+		AppId:           inviteOnlyAppID,
+		IncludeInactive: true,
+	})
+	require.NoError(t, err)
+	assert.Contains(t, inviteIDs(respList.GetInvites()), respInvite.GetInvite().GetId())
+
+	respList, err = s.InvitesClient.ListInvites(adminCtx, &authsvcv1.ListInvitesRequest{
+		AppId: inviteOnlyAppID,
+	})
+	require.NoError(t, err)
+	assert.NotContains(t, inviteIDs(respList.GetInvites()), respInvite.GetInvite().GetId())
+}
+
+func TestInvites_RevokedInvite(t *testing.T) {
+	ctx, s := suite.New(t)
+	adminCtx := withToken(ctx, adminToken(t))
+
+	respInvite, err := s.InvitesClient.CreateInvite(adminCtx, &authsvcv1.CreateInviteRequest{
+		AppId:   inviteOnlyAppID,
+		MaxUses: 5,
+	})
+	require.NoError(t, err)
+
+	_, err = s.InvitesClient.RevokeInvite(adminCtx, &authsvcv1.RevokeInviteRequest{
+		Id: respInvite.GetInvite().GetId(),
+	})
+	require.NoError(t, err)
+
+	inviteCtx := metadata.AppendToOutgoingContext(ctx,
+		"x-app-id", strconv.Itoa(inviteOnlyAppID),
+		"x-invite-code", respInvite.GetCode(),
+	)
+	_, err = s.AuthClient.Register(inviteCtx, &aaav1.RegisterRequest{
+		Email:    gofakeit.Email(),
+		Password: randomFakePass(passDefaultLen),
+	})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = PermissionDenied desc = invite code is not valid")
+}
+
+func TestInvites_RequireAdmin(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	_, err := s.InvitesClient.CreateInvite(ctx, &authsvcv1.CreateInviteRequest{})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = Unauthenticated desc = authentication required")
+
+	email := gofakeit.Email()
+	password := randomFakePass(passDefaultLen)
+	_, err = s.AuthClient.Register(ctx, &aaav1.RegisterRequest{
+		Email:    email,
+		Password: password,
+	})
+	require.NoError(t, err)
+
+	respLogin, err := s.AuthClient.Login(ctx, &aaav1.LoginRequest{
+		Email:    email,
+		Password: password,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+
+	_, err = s.InvitesClient.CreateInvite(withToken(ctx, respLogin.GetToken()), &authsvcv1.CreateInviteRequest{})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = PermissionDenied desc = admin permission required")
+
+	_, err = s.InvitesClient.CreateInvite(withToken(ctx, "not-a-token"), &authsvcv1.CreateInviteRequest{})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = Unauthenticated desc = invalid token")
+
+	// apps hold their secret, but tokens they sign don't authenticate to the service
+	minted, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": adminUserID,
+		"email":   adminEmail,
+		"app_id":  appID,
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte(appSecret))
+	require.NoError(t, err)
+	_, err = s.InvitesClient.CreateInvite(withToken(ctx, minted), &authsvcv1.CreateInviteRequest{})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = Unauthenticated desc = invalid token")
+}
+
+// adminToken issues a token for the seeded admin user, it has no password to log in with
+func adminToken(t *testing.T) string {
+	t.Helper()
+
+	return adminTokenWithScope(t, authjwt.Scope{})
+}
+
+// adminTokenWithScope issues a token for the seeded admin user with scope
+func adminTokenWithScope(t *testing.T, scope authjwt.Scope) string {
+	t.Helper()
+
+	return suite.IssueToken(t, models.User{ID: adminUserID, Email: adminEmail}, models.App{ID: appID, Secret: appSecret}, scope)
+}
+
+func withToken(ctx context.Context, token string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+}
+
+func inviteIDs(invites []*authsvcv1.Invite) []int64 {
+	ids := make([]int64, 0, len(invites))
+	for _, invite := range invites {
+		ids = append(ids, invite.GetId())
+	}
+	return ids
+}
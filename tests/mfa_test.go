@@ -0,0 +1,298 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	aaav1 "github.com/Len4i/aaa/gen/go/aaa"
+	authsvcv1 "github.com/Len4i/auth-service/gen/go/authsvc"
+	"github.com/Len4i/auth-service/internal/lib/totp"
+	"github.com/Len4i/auth-service/tests/suite"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestMFA_TOTPLogin(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	email, password, token := registerAndLogin(ctx, t, s)
+	userCtx := withToken(ctx, token)
+
+	respEnroll, err := s.MFAClient.EnrollTOTP(userCtx, &authsvcv1.EnrollTOTPRequest{})
+	require.NoError(t, err)
+	require.NotEmpty(t, respEnroll.GetSecret())
+	assert.Contains(t, respEnroll.GetUri(), "otpauth://totp/")
+
+	// unconfirmed factor doesn't affect login
+	_, err = s.AuthClient.Login(ctx, &aaav1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+
+	_, err = s.MFAClient.ConfirmTOTP(userCtx, &authsvcv1.ConfirmTOTPRequest{Code: "000000"})
+	require.Error(t, err)
+
+	now := time.Now()
+	_, err = s.MFAClient.ConfirmTOTP(userCtx, &authsvcv1.ConfirmTOTPRequest{
+		Code: totpCode(t, respEnroll.GetSecret(), now),
+	})
+	require.NoError(t, err)
+
+	challenge := loginMFAChallenge(ctx, t, s, email, password)
+
+	// code used for confirmation can't be replayed
+	_, err = s.MFAClient.VerifyMFA(ctx, &authsvcv1.VerifyMFARequest{
+		Challenge: challenge,
+		Code:      totpCode(t, respEnroll.GetSecret(), now),
+	})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = Unauthenticated desc = code is not valid")
+
+	respVerify, err := s.MFAClient.VerifyMFA(ctx, &authsvcv1.VerifyMFARequest{
+		Challenge: challenge,
+		Code:      totpCode(t, respEnroll.GetSecret(), now.Add(totp.Period)),
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, respVerify.GetToken())
+
+	// challenge is single use
+	_, err = s.MFAClient.VerifyMFA(ctx, &authsvcv1.VerifyMFARequest{
+		Challenge: challenge,
+		Code:      totpCode(t, respEnroll.GetSecret(), now.Add(totp.Period)),
+	})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = Unauthenticated desc = challenge is not valid")
+}
+
+func TestMFA_RecoveryCodes(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	email, password, token := registerAndLogin(ctx, t, s)
+	userCtx := withToken(ctx, token)
+
+	respEnroll, err := s.MFAClient.EnrollTOTP(userCtx, &authsvcv1.EnrollTOTPRequest{})
+	require.NoError(t, err)
+
+	respConfirm, err := s.MFAClient.ConfirmTOTP(userCtx, &authsvcv1.ConfirmTOTPRequest{
+		Code: totpCode(t, respEnroll.GetSecret(), time.Now()),
+	})
+	require.NoError(t, err)
+	recoveryCodes := respConfirm.GetRecoveryCodes()
+	require.Len(t, recoveryCodes, 10)
+
+	// codes are accepted regardless of case and separators
+	typed := strings.ToUpper(strings.ReplaceAll(recoveryCodes[0], "-", ""))
+	respVerify, err := s.MFAClient.VerifyMFA(ctx, &authsvcv1.VerifyMFARequest{
+		Challenge: loginMFAChallenge(ctx, t, s, email, password),
+		Code:      typed,
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, respVerify.GetToken())
+
+	respStatus, err := s.MFAClient.GetMFAStatus(userCtx, &authsvcv1.GetMFAStatusRequest{})
+	require.NoError(t, err)
+	assert.True(t, respStatus.GetTotpEnabled())
+	assert.EqualValues(t, 9, respStatus.GetRecoveryCodesRemaining())
+
+	// recovery code is single use
+	_, err = s.MFAClient.VerifyMFA(ctx, &authsvcv1.VerifyMFARequest{
+		Challenge: loginMFAChallenge(ctx, t, s, email, password),
+		Code:      recoveryCodes[0],
+	})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = Unauthenticated desc = code is not valid")
+
+	respRegen, err := s.MFAClient.RegenerateRecoveryCodes(userCtx, &authsvcv1.RegenerateRecoveryCodesRequest{
+		Code: recoveryCodes[1],
+	})
+	require.NoError(t, err)
+	require.Len(t, respRegen.GetRecoveryCodes(), 10)
+
+	// old set is invalidated
+	_, err = s.MFAClient.VerifyMFA(ctx, &authsvcv1.VerifyMFARequest{
+		Challenge: loginMFAChallenge(ctx, t, s, email, password),
+		Code:      recoveryCodes[2],
+	})
+	require.Error(t, err)
+
+	respStatus, err = s.MFAClient.GetMFAStatus(userCtx, &authsvcv1.GetMFAStatusRequest{})
+	require.NoError(t, err)
+	assert.EqualValues(t, 10, respStatus.GetRecoveryCodesRemaining())
+}
+
+func TestMFA_RegenerateRecoveryCodesAttempts(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	_, _, token := registerAndLogin(ctx, t, s)
+	userCtx := withToken(ctx, token)
+
+	respEnroll, err := s.MFAClient.EnrollTOTP(userCtx, &authsvcv1.EnrollTOTPRequest{})
+	require.NoError(t, err)
+	respConfirm, err := s.MFAClient.ConfirmTOTP(userCtx, &authsvcv1.ConfirmTOTPRequest{
+		Code: totpCode(t, respEnroll.GetSecret(), time.Now()),
+	})
+	require.NoError(t, err)
+
+	// recovery codes can't be guessed through regeneration either
+	for i := 0; i < 10; i++ {
+		_, err := s.MFAClient.RegenerateRecoveryCodes(userCtx, &authsvcv1.RegenerateRecoveryCodesRequest{
+			Code: "AAAA-AAAA-AAAA-AAAA",
+		})
+		require.EqualError(t, err, "rpc error: code = InvalidArgument desc = code is not valid")
+	}
+
+	_, err = s.MFAClient.RegenerateRecoveryCodes(userCtx, &authsvcv1.RegenerateRecoveryCodesRequest{
+		Code: respConfirm.GetRecoveryCodes()[0],
+	})
+	assert.EqualError(t, err, "rpc error: code = ResourceExhausted desc = too many attempts, try again later")
+
+	respStatus, err := s.MFAClient.GetMFAStatus(userCtx, &authsvcv1.GetMFAStatusRequest{})
+	require.NoError(t, err)
+	assert.EqualValues(t, 10, respStatus.GetRecoveryCodesRemaining())
+}
+
+func TestMFA_ChallengeAttempts(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	email, password, token := registerAndLogin(ctx, t, s)
+	userCtx := withToken(ctx, token)
+
+	respEnroll, err := s.MFAClient.EnrollTOTP(userCtx, &authsvcv1.EnrollTOTPRequest{})
+	require.NoError(t, err)
+	now := time.Now()
+	_, err = s.MFAClient.ConfirmTOTP(userCtx, &authsvcv1.ConfirmTOTPRequest{Code: totpCode(t, respEnroll.GetSecret(), now)})
+	require.NoError(t, err)
+
+	challenge := loginMFAChallenge(ctx, t, s, email, password)
+
+	// concurrent attempts can't exceed the limit
+	const attempts = 10
+	errs := make(chan error, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.MFAClient.VerifyMFA(ctx, &authsvcv1.VerifyMFARequest{Challenge: challenge, Code: "not-a-code"})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	invalidCode := 0
+	for err := range errs {
+		require.Error(t, err)
+		if status.Convert(err).Message() == "code is not valid" {
+			invalidCode++
+			continue
+		}
+		assert.EqualError(t, err, "rpc error: code = Unauthenticated desc = challenge is not valid")
+	}
+	assert.Equal(t, 5, invalidCode)
+
+	// valid code doesn't help once attempts are exhausted
+	_, err = s.MFAClient.VerifyMFA(ctx, &authsvcv1.VerifyMFARequest{
+		Challenge: challenge,
+		Code:      totpCode(t, respEnroll.GetSecret(), now.Add(totp.Period)),
+	})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = Unauthenticated desc = challenge is not valid")
+}
+
+func TestMFA_UserAttempts(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	email, password, token := registerAndLogin(ctx, t, s)
+	userCtx := withToken(ctx, token)
+
+	respEnroll, err := s.MFAClient.EnrollTOTP(userCtx, &authsvcv1.EnrollTOTPRequest{})
+	require.NoError(t, err)
+	now := time.Now()
+	_, err = s.MFAClient.ConfirmTOTP(userCtx, &authsvcv1.ConfirmTOTPRequest{Code: totpCode(t, respEnroll.GetSecret(), now)})
+	require.NoError(t, err)
+
+	// each login brings a new challenge, but not new attempts of the user
+	for i := 0; i < 2; i++ {
+		challenge := loginMFAChallenge(ctx, t, s, email, password)
+		for j := 0; j < 5; j++ {
+			_, err := s.MFAClient.VerifyMFA(ctx, &authsvcv1.VerifyMFARequest{Challenge: challenge, Code: "not-a-code"})
+			require.EqualError(t, err, "rpc error: code = Unauthenticated desc = code is not valid")
+		}
+	}
+
+	code := totpCode(t, respEnroll.GetSecret(), now.Add(totp.Period))
+	challenge := loginMFAChallenge(ctx, t, s, email, password)
+	_, err = s.MFAClient.VerifyMFA(ctx, &authsvcv1.VerifyMFARequest{Challenge: challenge, Code: code})
+	assert.EqualError(t, err, "rpc error: code = Unauthenticated desc = code is not valid")
+
+	_, err = s.MFAClient.DisableTOTP(userCtx, &authsvcv1.DisableTOTPRequest{Code: code})
+	assert.EqualError(t, err, "rpc error: code = ResourceExhausted desc = too many attempts, try again later")
+}
+
+func TestMFA_RequireUser(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	_, err := s.MFAClient.EnrollTOTP(ctx, &authsvcv1.EnrollTOTPRequest{})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = Unauthenticated desc = authentication required")
+
+	_, err = s.MFAClient.VerifyMFA(ctx, &authsvcv1.VerifyMFARequest{Challenge: "unknown", Code: "123456"})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = Unauthenticated desc = challenge is not valid")
+}
+
+func registerAndLogin(ctx context.Context, t *testing.T, s *suite.Suite) (email string, password string, token string) {
+	t.Helper()
+
+	email = gofakeit.Email()
+	password = randomFakePass(passDefaultLen)
+
+	_, err := s.AuthClient.Register(ctx, &aaav1.RegisterRequest{Email: email, Password: password})
+	require.NoError(t, err)
+
+	respLogin, err := s.AuthClient.Login(ctx, &aaav1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+
+	return email, password, respLogin.GetToken()
+}
+
+// loginMFAChallenge logs in user with MFA enabled and returns the challenge handle
+func loginMFAChallenge(ctx context.Context, t *testing.T, s *suite.Suite, email string, password string) string {
+	t.Helper()
+
+	_, err := s.AuthClient.Login(ctx, &aaav1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.Error(t, err)
+
+	return mfaRequiredChallenge(t, err)
+}
+
+// mfaRequiredChallenge returns challenge handle from MFA_REQUIRED error
+func mfaRequiredChallenge(t *testing.T, err error) string {
+	t.Helper()
+
+	st := status.Convert(err)
+	require.Equal(t, codes.FailedPrecondition, st.Code())
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.GetReason() == "MFA_REQUIRED" {
+			require.NotEmpty(t, info.GetMetadata()["challenge"])
+			return info.GetMetadata()["challenge"]
+		}
+	}
+
+	t.Fatal("error has no MFA_REQUIRED error info")
+	return ""
+}
+
+func totpCode(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+
+	code, err := totp.Code(secret, at)
+	require.NoError(t, err)
+	return code
+}
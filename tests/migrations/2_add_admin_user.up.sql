@@ -1,8 +1,12 @@
 INSERT INTO
-    users (id, email, pass_hash, is_admin)
+    users (id, email, pass_hash)
 VALUES (
         999,
         'admin-user@localhost.com',
-        'some-hash',
-        1
-    ) ON CONFLICT DO NOTHING;
\ No newline at end of file
+        'some-hash'
+    ) ON CONFLICT DO NOTHING;
+
+INSERT INTO
+    user_roles (user_id, role_id, created_at)
+SELECT 999, id, 0 FROM roles WHERE app_id = 0 AND name = 'admin'
+ON CONFLICT DO NOTHING;
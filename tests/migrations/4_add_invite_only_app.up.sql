@@ -0,0 +1,8 @@
+INSERT INTO
+    apps (id, name, secret, registration_mode)
+VALUES (
+        997,
+        'invite-only-test-app',
+        'invite-only-test-secret',
+        'invite_only'
+    ) ON CONFLICT DO NOTHING;
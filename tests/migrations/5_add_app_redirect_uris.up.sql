@@ -0,0 +1,5 @@
+UPDATE apps
+SET
+    redirect_uris = 'http://localhost:3000/callback https://localhost:3000/callback'
+WHERE
+    id = 999;
@@ -0,0 +1,19 @@
+INSERT INTO
+    users (
+        id,
+        email,
+        pass_hash,
+        name,
+        given_name,
+        family_name,
+        locale
+    )
+VALUES (
+        996,
+        'profile-user@localhost.com',
+        '$2a$10$SUvhM.g/FFgBzNs3cF5nNukSWwM5DYE5JMji4ajRmZ.fTA8TIAYQ2',
+        'Ada Lovelace',
+        'Ada',
+        'Lovelace',
+        'en-GB'
+    ) ON CONFLICT DO NOTHING;
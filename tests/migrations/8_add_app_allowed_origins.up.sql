@@ -0,0 +1,5 @@
+UPDATE apps
+SET
+    allowed_origins = 'https://localhost:3000'
+WHERE
+    id = 999;
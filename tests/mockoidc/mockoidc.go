@@ -0,0 +1,211 @@
+// Package mockoidc is an in-process OpenID Connect provider for tests
+//
+// It serves discovery, keys, the authorization and the token endpoint.
+// The authorization endpoint signs in the user picked by the login_hint
+// parameter without asking, like a browser with an active session would,
+// and denies the login if there is no such user.
+package mockoidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Len4i/auth-service/internal/lib/jwt"
+)
+
+const tokenTTL = 10 * time.Minute
+
+// User is an account at the provider, Subject is its login hint
+type User struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	GivenName     string
+	FamilyName    string
+}
+
+// Provider holds users and clients, clients maps client id to its secret
+type Provider struct {
+	Issuer  string
+	clients map[string]string
+	key     *jwt.SigningKey
+
+	mu    sync.Mutex
+	users map[string]User
+	codes map[string]grant
+}
+
+type grant struct {
+	ClientID      string
+	RedirectURI   string
+	Nonce         string
+	CodeChallenge string
+	User          User
+}
+
+func New(issuer string, clients map[string]string) (*Provider, error) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	key, err := jwt.ParseSigningKey(pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(rsaKey),
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Provider{
+		Issuer:  strings.TrimSuffix(issuer, "/"),
+		clients: clients,
+		key:     key,
+		users:   map[string]User{},
+		codes:   map[string]grant{},
+	}, nil
+}
+
+// AddUser adds or replaces user
+func (p *Provider) AddUser(user User) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.users[user.Subject] = user
+}
+
+func (p *Provider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		writeJSON(w, http.StatusOK, map[string]any{
+			"issuer":                                p.Issuer,
+			"authorization_endpoint":                p.Issuer + "/authorize",
+			"token_endpoint":                        p.Issuer + "/token",
+			"jwks_uri":                              p.Issuer + "/keys",
+			"response_types_supported":              []string{"code"},
+			"subject_types_supported":               []string{"public"},
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	case "/keys":
+		writeJSON(w, http.StatusOK, map[string]any{"keys": []jwt.JWK{p.key.JWK()}})
+	case "/authorize":
+		p.authorize(w, r)
+	case "/token":
+		p.token(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (p *Provider) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	redirectURI, err := url.Parse(query.Get("redirect_uri"))
+	if _, ok := p.clients[query.Get("client_id")]; !ok || err != nil || query.Get("response_type") != "code" {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	params := url.Values{"state": {query.Get("state")}}
+
+	p.mu.Lock()
+	user, ok := p.users[query.Get("login_hint")]
+	if ok {
+		code := randomString()
+		p.codes[code] = grant{
+			ClientID:      query.Get("client_id"),
+			RedirectURI:   redirectURI.String(),
+			Nonce:         query.Get("nonce"),
+			CodeChallenge: query.Get("code_challenge"),
+			User:          user,
+		}
+		params.Set("code", code)
+	} else {
+		params.Set("error", "access_denied")
+	}
+	p.mu.Unlock()
+
+	redirectURI.RawQuery = params.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if secret, ok := p.clients[clientID]; !ok || secret != clientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	p.mu.Lock()
+	code, ok := p.codes[r.PostForm.Get("code")]
+	delete(p.codes, r.PostForm.Get("code"))
+	p.mu.Unlock()
+
+	if !ok || code.ClientID != clientID || code.RedirectURI != r.PostForm.Get("redirect_uri") ||
+		(code.CodeChallenge != "" && code.CodeChallenge != s256(r.PostForm.Get("code_verifier"))) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	idToken, err := jwt.NewIDToken(p.key, jwt.IDToken{
+		Issuer:   p.Issuer,
+		Subject:  code.User.Subject,
+		Audience: clientID,
+		Nonce:    code.Nonce,
+		Claims: map[string]any{
+			"email":          code.User.Email,
+			"email_verified": code.User.EmailVerified,
+			"name":           code.User.Name,
+			"given_name":     code.User.GivenName,
+			"family_name":    code.User.FamilyName,
+		},
+	}, tokenTTL)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   int64(tokenTTL / time.Second),
+		"id_token":     idToken,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func s256(verifier string) string {
+	digest := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(digest[:])
+}
+
+func randomString() string {
+	b := make([]byte, 24)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,367 @@
+package tests
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	authsvcv1 "github.com/Len4i/auth-service/gen/go/authsvc"
+	"github.com/Len4i/auth-service/internal/lib/totp"
+	"github.com/Len4i/auth-service/tests/suite"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// redirectURI is registered for the test app
+const redirectURI = "http://localhost:3000/callback"
+
+func TestOAuth_AuthorizationCode(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	email, password, _ := registerAndLogin(ctx, t, s)
+	verifier, challenge := pkcePair(t)
+	params := authorizeParams(challenge)
+	params.Set("scope", "profile")
+
+	resp := httpGet(t, s, "/oauth/authorize?"+params.Encode())
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, readBody(t, resp), `name="password"`)
+	assert.Equal(t, "DENY", resp.Header.Get("X-Frame-Options"))
+
+	params.Set("email", email)
+	params.Set("password", password)
+	params.Set("state", "xyz")
+	resp = httpPostForm(t, s, "/oauth/authorize", params)
+	query := redirectQuery(t, resp)
+	assert.Equal(t, "xyz", query.Get("state"))
+	code := query.Get("code")
+	require.NotEmpty(t, code)
+
+	status, body := exchangeCode(t, s, code, verifier)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "Bearer", body["token_type"])
+	assert.Equal(t, float64(time.Hour/time.Second), body["expires_in"])
+	assert.Equal(t, "profile", body["scope"])
+
+	claims := tokenClaims(t, body["access_token"].(string))
+	assert.Equal(t, email, claims["email"])
+	assert.Equal(t, float64(appID), claims["app_id"])
+	assert.Equal(t, []any{"pwd"}, claims["amr"])
+
+	// codes are single-use
+	status, body = exchangeCode(t, s, code, verifier)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalid_grant", body["error"])
+}
+
+func TestOAuth_PKCE(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	email, password, _ := registerAndLogin(ctx, t, s)
+	_, challenge := pkcePair(t)
+	otherVerifier, _ := pkcePair(t)
+
+	code := oauthLogin(t, s, email, password, challenge)
+	status, body := exchangeCode(t, s, code, otherVerifier)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalid_grant", body["error"])
+
+	tests := []struct {
+		name   string
+		params func(url.Values)
+	}{
+		{
+			name:   "No challenge",
+			params: func(p url.Values) { p.Del("code_challenge") },
+		},
+		{
+			name:   "Plain method",
+			params: func(p url.Values) { p.Set("code_challenge_method", "plain") },
+		},
+		{
+			name:   "No method",
+			params: func(p url.Values) { p.Del("code_challenge_method") },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := authorizeParams(challenge)
+			params.Set("state", "xyz")
+			tt.params(params)
+
+			query := redirectQuery(t, httpGet(t, s, "/oauth/authorize?"+params.Encode()))
+			assert.Equal(t, "invalid_request", query.Get("error"))
+			assert.Equal(t, "xyz", query.Get("state"))
+			assert.Empty(t, query.Get("code"))
+		})
+	}
+}
+
+func TestOAuth_InvalidRequest(t *testing.T) {
+	_, s := suite.New(t)
+
+	_, challenge := pkcePair(t)
+
+	tests := []struct {
+		name   string
+		params func(url.Values)
+	}{
+		{
+			name:   "Unregistered redirect uri",
+			params: func(p url.Values) { p.Set("redirect_uri", "https://evil.example.com/callback") },
+		},
+		{
+			name:   "No redirect uri",
+			params: func(p url.Values) { p.Del("redirect_uri") },
+		},
+		{
+			name:   "Unknown client",
+			params: func(p url.Values) { p.Set("client_id", strconv.Itoa(notExistAppID)) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := authorizeParams(challenge)
+			tt.params(params)
+
+			// the error is never sent to an untrusted redirect uri
+			resp := httpGet(t, s, "/oauth/authorize?"+params.Encode())
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+			assert.Empty(t, resp.Header.Get("Location"))
+		})
+	}
+
+	params := authorizeParams(challenge)
+	params.Set("response_type", "token")
+	query := redirectQuery(t, httpGet(t, s, "/oauth/authorize?"+params.Encode()))
+	assert.Equal(t, "unsupported_response_type", query.Get("error"))
+
+	status, body := httpPostToken(t, s, url.Values{"grant_type": {"password"}}, nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "unsupported_grant_type", body["error"])
+
+	status, body = httpPostToken(t, s, url.Values{"grant_type": {"authorization_code"}}, nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalid_request", body["error"])
+}
+
+func TestOAuth_InvalidCredentials(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	email, _, _ := registerAndLogin(ctx, t, s)
+	_, challenge := pkcePair(t)
+
+	params := authorizeParams(challenge)
+	params.Set("email", email)
+	params.Set("password", randomFakePass(passDefaultLen))
+	resp := httpPostForm(t, s, "/oauth/authorize", params)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Contains(t, readBody(t, resp), "Invalid email or password.")
+}
+
+func TestOAuth_MFA(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	email, password, token := registerAndLogin(ctx, t, s)
+	userCtx := withToken(ctx, token)
+
+	respEnroll, err := s.MFAClient.EnrollTOTP(userCtx, &authsvcv1.EnrollTOTPRequest{})
+	require.NoError(t, err)
+	now := time.Now()
+	_, err = s.MFAClient.ConfirmTOTP(userCtx, &authsvcv1.ConfirmTOTPRequest{
+		Code: totpCode(t, respEnroll.GetSecret(), now),
+	})
+	require.NoError(t, err)
+
+	verifier, challenge := pkcePair(t)
+	params := authorizeParams(challenge)
+	params.Set("email", email)
+	params.Set("password", password)
+	resp := httpPostForm(t, s, "/oauth/authorize", params)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	match := regexp.MustCompile(`name="mfa_challenge" value="([^"]+)"`).FindStringSubmatch(readBody(t, resp))
+	require.Len(t, match, 2)
+
+	params = authorizeParams(challenge)
+	params.Set("mfa_challenge", match[1])
+	params.Set("code", "000000")
+	resp = httpPostForm(t, s, "/oauth/authorize", params)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Contains(t, readBody(t, resp), "The code is not valid.")
+
+	params.Set("code", totpCode(t, respEnroll.GetSecret(), now.Add(totp.Period)))
+	code := redirectQuery(t, httpPostForm(t, s, "/oauth/authorize", params)).Get("code")
+	require.NotEmpty(t, code)
+
+	status, body := exchangeCode(t, s, code, verifier)
+	require.Equal(t, http.StatusOK, status)
+	claims := tokenClaims(t, body["access_token"].(string))
+	assert.Equal(t, []any{"pwd", "otp", "mfa"}, claims["amr"])
+	assert.Equal(t, "aal2", claims["acr"])
+}
+
+func TestOAuth_ClientCredentials(t *testing.T) {
+	ctx, s := suite.New(t)
+	adminCtx := withToken(ctx, adminToken(t))
+
+	respCreate, err := s.ClientsClient.CreateClient(adminCtx, &authsvcv1.CreateClientRequest{
+		AppId:       appID,
+		Name:        "exporter",
+		Permissions: []string{"metrics:read", "metrics:write"},
+	})
+	require.NoError(t, err)
+	clientID := respCreate.GetClient().GetClientId()
+
+	status, body := httpPostToken(t, s, url.Values{
+		"grant_type": {"client_credentials"},
+		"scope":      {"metrics:read"},
+	}, []string{clientID, respCreate.GetClientSecret()})
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "metrics:read", body["scope"])
+	assert.Equal(t, clientID, tokenClaims(t, body["access_token"].(string))["client_id"])
+
+	status, body = httpPostToken(t, s, url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {clientID},
+		"client_secret": {respCreate.GetClientSecret()},
+	}, nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "metrics:read metrics:write", body["scope"])
+
+	status, body = httpPostToken(t, s, url.Values{
+		"grant_type": {"client_credentials"},
+		"scope":      {"metrics:delete"},
+	}, []string{clientID, respCreate.GetClientSecret()})
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalid_scope", body["error"])
+
+	status, body = httpPostToken(t, s, url.Values{
+		"grant_type": {"client_credentials"},
+	}, []string{clientID, gofakeit.Password(true, true, true, false, false, 32)})
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, "invalid_client", body["error"])
+}
+
+// pkcePair returns a random code verifier and its S256 challenge
+func pkcePair(t *testing.T) (verifier string, challenge string) {
+	t.Helper()
+
+	verifier = base64.RawURLEncoding.EncodeToString([]byte(gofakeit.LetterN(32)))
+	digest := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(digest[:])
+}
+
+func authorizeParams(challenge string) url.Values {
+	return url.Values{
+		"client_id":             {strconv.Itoa(appID)},
+		"redirect_uri":          {redirectURI},
+		"response_type":         {"code"},
+		"code_challenge":        {challenge},
+		"code_challenge_method": {"S256"},
+	}
+}
+
+// oauthLogin logs in user on the login page and returns the authorization code
+func oauthLogin(t *testing.T, s *suite.Suite, email string, password string, challenge string) string {
+	t.Helper()
+
+	params := authorizeParams(challenge)
+	params.Set("email", email)
+	params.Set("password", password)
+	code := redirectQuery(t, httpPostForm(t, s, "/oauth/authorize", params)).Get("code")
+	require.NotEmpty(t, code)
+	return code
+}
+
+func exchangeCode(t *testing.T, s *suite.Suite, code string, verifier string) (int, map[string]any) {
+	t.Helper()
+
+	return httpPostToken(t, s, url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {strconv.Itoa(appID)},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {verifier},
+	}, nil)
+}
+
+// httpPostToken posts form to the token endpoint, with basic auth
+// if it's not nil, and returns status and decoded body
+func httpPostToken(t *testing.T, s *suite.Suite, form url.Values, basicAuth []string) (int, map[string]any) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, s.HTTPURL+"/oauth/token", strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if basicAuth != nil {
+		req.SetBasicAuth(url.QueryEscape(basicAuth[0]), url.QueryEscape(basicAuth[1]))
+	}
+
+	resp := httpDo(t, req)
+	defer resp.Body.Close()
+	assert.Equal(t, "no-store", resp.Header.Get("Cache-Control"))
+
+	var body map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	return resp.StatusCode, body
+}
+
+func httpGet(t *testing.T, s *suite.Suite, path string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, s.HTTPURL+path, nil)
+	require.NoError(t, err)
+	return httpDo(t, req)
+}
+
+func httpPostForm(t *testing.T, s *suite.Suite, path string, form url.Values) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, s.HTTPURL+path, strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return httpDo(t, req)
+}
+
+// httpDo sends request without following redirects
+func httpDo(t *testing.T, req *http.Request) *http.Response {
+	t.Helper()
+
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// redirectQuery checks response redirects to the redirect uri and returns its query
+func redirectQuery(t *testing.T, resp *http.Response) url.Values {
+	t.Helper()
+
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	location, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	require.Equal(t, redirectURI, location.Scheme+"://"+location.Host+location.Path)
+	return location.Query()
+}
+
+func readBody(t *testing.T, resp *http.Response) string {
+	t.Helper()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(body)
+}
@@ -0,0 +1,192 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	aaav1 "github.com/Len4i/aaa/gen/go/aaa"
+	"github.com/Len4i/auth-service/tests/suite"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+const (
+	profileUserEmail = "profile-user@localhost.com"
+	profileUserPass  = "profile-secret"
+)
+
+// profileClaims are claims of the email and profile scopes
+type profileClaims struct {
+	Email      string `json:"email"`
+	Name       string `json:"name"`
+	GivenName  string `json:"given_name"`
+	FamilyName string `json:"family_name"`
+	Locale     string `json:"locale"`
+}
+
+func TestOIDC_Discovery(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	// the provider checks the issuer matches the discovery url
+	provider, err := oidc.NewProvider(ctx, s.HTTPURL)
+	require.NoError(t, err)
+	assert.Equal(t, s.HTTPURL+"/oauth/authorize", provider.Endpoint().AuthURL)
+	assert.Equal(t, s.HTTPURL+"/oauth/token", provider.Endpoint().TokenURL)
+
+	var metadata struct {
+		UserInfoEndpoint              string   `json:"userinfo_endpoint"`
+		ScopesSupported               []string `json:"scopes_supported"`
+		IDTokenSigningAlgValues       []string `json:"id_token_signing_alg_values_supported"`
+		CodeChallengeMethodsSupported []string `json:"code_challenge_methods_supported"`
+	}
+	require.NoError(t, provider.Claims(&metadata))
+	assert.Equal(t, s.HTTPURL+"/oauth/userinfo", metadata.UserInfoEndpoint)
+	assert.Subset(t, metadata.ScopesSupported, []string{"openid", "profile", "email"})
+	assert.Equal(t, []string{"RS256"}, metadata.IDTokenSigningAlgValues)
+	assert.Equal(t, []string{"S256"}, metadata.CodeChallengeMethodsSupported)
+}
+
+func TestOIDC_AuthorizationCode(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	provider, err := oidc.NewProvider(ctx, s.HTTPURL)
+	require.NoError(t, err)
+	config := oidcConfig(provider, oidc.ScopeOpenID, "profile", "email")
+
+	nonce := gofakeit.UUID()
+	token := oidcLogin(ctx, t, s, config, profileUserEmail, profileUserPass, oidc.Nonce(nonce))
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	require.True(t, ok)
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: config.ClientID}).Verify(ctx, rawIDToken)
+	require.NoError(t, err)
+	assert.Equal(t, nonce, idToken.Nonce)
+	assert.Equal(t, "996", idToken.Subject)
+
+	var claims profileClaims
+	require.NoError(t, idToken.Claims(&claims))
+	assert.Equal(t, profileClaims{
+		Email:      profileUserEmail,
+		Name:       "Ada Lovelace",
+		GivenName:  "Ada",
+		FamilyName: "Lovelace",
+		Locale:     "en-GB",
+	}, claims)
+
+	userInfo, err := provider.UserInfo(ctx, oauth2.StaticTokenSource(token))
+	require.NoError(t, err)
+	assert.Equal(t, "996", userInfo.Subject)
+	assert.Equal(t, profileUserEmail, userInfo.Email)
+
+	var infoClaims profileClaims
+	require.NoError(t, userInfo.Claims(&infoClaims))
+	assert.Equal(t, claims, infoClaims)
+}
+
+func TestOIDC_Scopes(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	email, password, loginToken := registerAndLogin(ctx, t, s)
+
+	provider, err := oidc.NewProvider(ctx, s.HTTPURL)
+	require.NoError(t, err)
+
+	// userinfo needs the openid scope granted to the app
+	_, err = provider.UserInfo(ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: loginToken}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "403")
+
+	token := oidcLogin(ctx, t, s, oidcConfig(provider, oidc.ScopeOpenID), email, password)
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: "999"}).Verify(ctx, token.Extra("id_token").(string))
+	require.NoError(t, err)
+	assert.Empty(t, idToken.Nonce)
+
+	var claims map[string]any
+	require.NoError(t, idToken.Claims(&claims))
+	assert.NotContains(t, claims, "email")
+
+	token = oidcLogin(ctx, t, s, oidcConfig(provider, oidc.ScopeOpenID, "email"), email, password)
+	userInfo, err := provider.UserInfo(ctx, oauth2.StaticTokenSource(token))
+	require.NoError(t, err)
+	assert.Equal(t, email, userInfo.Email)
+
+	var infoClaims map[string]any
+	require.NoError(t, userInfo.Claims(&infoClaims))
+	assert.NotContains(t, infoClaims, "name")
+
+	// tokens without openid don't get an ID token
+	token = oidcLogin(ctx, t, s, oidcConfig(provider, "profile"), email, password)
+	assert.Nil(t, token.Extra("id_token"))
+
+	_, err = provider.UserInfo(ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "not-a-token"}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "401")
+}
+
+func TestOIDC_LoginToken(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	provider, err := oidc.NewProvider(ctx, s.HTTPURL)
+	require.NoError(t, err)
+
+	email, password, _ := registerAndLogin(ctx, t, s)
+	oidcLogin(ctx, t, s, oidcConfig(provider, oidc.ScopeOpenID, "email"), email, password)
+
+	// once granted, userinfo accepts any user token of the app
+	respLogin, err := s.AuthClient.Login(ctx, &aaav1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+	userInfo, err := provider.UserInfo(ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: respLogin.GetToken()}))
+	require.NoError(t, err)
+	assert.Equal(t, email, userInfo.Email)
+}
+
+func oidcConfig(provider *oidc.Provider, scopes ...string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:    "999",
+		Endpoint:    provider.Endpoint(),
+		RedirectURL: redirectURI,
+		Scopes:      scopes,
+	}
+}
+
+// oidcLogin runs the authorization code flow as a browser and a client would
+func oidcLogin(
+	ctx context.Context,
+	t *testing.T,
+	s *suite.Suite,
+	config *oauth2.Config,
+	email string,
+	password string,
+	opts ...oauth2.AuthCodeOption,
+) *oauth2.Token {
+	t.Helper()
+
+	verifier, challenge := pkcePair(t)
+	state := gofakeit.UUID()
+	opts = append(opts,
+		oauth2.SetAuthURLParam("code_challenge", challenge),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	)
+	authURL, err := url.Parse(config.AuthCodeURL(state, opts...))
+	require.NoError(t, err)
+
+	resp := httpGet(t, s, strings.TrimPrefix(authURL.String(), s.HTTPURL))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// the login page posts the request back along with credentials
+	form := authURL.Query()
+	form.Set("email", email)
+	form.Set("password", password)
+	query := redirectQuery(t, httpPostForm(t, s, "/oauth/authorize", form))
+	require.Equal(t, state, query.Get("state"))
+
+	token, err := config.Exchange(ctx, query.Get("code"), oauth2.SetAuthURLParam("code_verifier", verifier))
+	require.NoError(t, err)
+	return token
+}
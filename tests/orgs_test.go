@@ -0,0 +1,390 @@
+package tests
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	aaav1 "github.com/Len4i/aaa/gen/go/aaa"
+	authsvcv1 "github.com/Len4i/auth-service/gen/go/authsvc"
+	"github.com/Len4i/auth-service/internal/lib/totp"
+	"github.com/Len4i/auth-service/tests/suite"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestOrgs_Login(t *testing.T) {
+	ctx, s := suite.New(t)
+	adminCtx := withToken(ctx, adminToken(t))
+
+	email, password, token := registerAndLogin(ctx, t, s)
+	userID := tokenUserID(t, token)
+	orgID := createOrg(ctx, t, s, &authsvcv1.CreateOrgRequest{OwnerUserId: userID})
+
+	respLogin, err := s.AuthClient.Login(withOrg(ctx, orgID), &aaav1.LoginRequest{
+		Email:    email,
+		Password: password,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+	claims := tokenClaims(t, respLogin.GetToken())
+	assert.Equal(t, float64(orgID), claims["org_id"])
+	assert.Equal(t, "owner", claims["org_role"])
+
+	// unscoped login has no org claims
+	_, ok := tokenClaims(t, token)["org_id"]
+	assert.False(t, ok)
+
+	otherEmail, otherPassword, _ := registerAndLogin(ctx, t, s)
+	_, err = s.AuthClient.Login(withOrg(ctx, orgID), &aaav1.LoginRequest{
+		Email:    otherEmail,
+		Password: otherPassword,
+		AppId:    appID,
+	})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = PermissionDenied desc = not a member of the org")
+
+	_, err = s.OrgsClient.UpdateOrg(adminCtx, &authsvcv1.UpdateOrgRequest{
+		OrgId:       orgID,
+		Name:        "restricted",
+		AllowedApps: []int32{inviteOnlyAppID},
+	})
+	require.NoError(t, err)
+
+	_, err = s.AuthClient.Login(withOrg(ctx, orgID), &aaav1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = PermissionDenied desc = app is not allowed in the org")
+
+	_, err = s.OrgsClient.UpdateOrg(adminCtx, &authsvcv1.UpdateOrgRequest{
+		OrgId:       orgID,
+		Name:        "restricted",
+		LoginPolicy: &authsvcv1.OrgLoginPolicy{AllowedMethods: []string{"hwk"}},
+	})
+	require.NoError(t, err)
+
+	_, err = s.AuthClient.Login(withOrg(ctx, orgID), &aaav1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = PermissionDenied desc = authentication method is not allowed in the org")
+
+	_, err = s.AuthClient.Login(
+		metadata.AppendToOutgoingContext(ctx, "x-org-id", "abc"),
+		&aaav1.LoginRequest{Email: email, Password: password, AppId: appID},
+	)
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = InvalidArgument desc = org id is not valid")
+}
+
+func TestOrgs_RequireMFA(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	email, password, token := registerAndLogin(ctx, t, s)
+	userCtx := withToken(ctx, token)
+	orgID := createOrg(ctx, t, s, &authsvcv1.CreateOrgRequest{
+		OwnerUserId: tokenUserID(t, token),
+		LoginPolicy: &authsvcv1.OrgLoginPolicy{RequireMfa: true},
+	})
+
+	_, err := s.AuthClient.Login(withOrg(ctx, orgID), &aaav1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = FailedPrecondition desc = org requires mfa")
+
+	respEnroll, err := s.MFAClient.EnrollTOTP(userCtx, &authsvcv1.EnrollTOTPRequest{})
+	require.NoError(t, err)
+	now := time.Now()
+	_, err = s.MFAClient.ConfirmTOTP(userCtx, &authsvcv1.ConfirmTOTPRequest{
+		Code: totpCode(t, respEnroll.GetSecret(), now),
+	})
+	require.NoError(t, err)
+
+	_, err = s.AuthClient.Login(withOrg(ctx, orgID), &aaav1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.Error(t, err)
+	challenge := mfaRequiredChallenge(t, err)
+
+	respVerify, err := s.MFAClient.VerifyMFA(ctx, &authsvcv1.VerifyMFARequest{
+		Challenge: challenge,
+		Code:      totpCode(t, respEnroll.GetSecret(), now.Add(totp.Period)),
+	})
+	require.NoError(t, err)
+	claims := tokenClaims(t, respVerify.GetToken())
+	assert.Equal(t, float64(orgID), claims["org_id"])
+	assert.Equal(t, "aal2", claims["acr"])
+
+	// single-factor token can't be switched to the org
+	_, err = s.OrgsClient.SwitchOrg(userCtx, &authsvcv1.SwitchOrgRequest{OrgId: orgID})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = FailedPrecondition desc = org requires mfa")
+}
+
+func TestOrgs_Invites(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	_, _, ownerToken := registerAndLogin(ctx, t, s)
+	ownerCtx := withToken(ctx, ownerToken)
+	orgID := createOrg(ctx, t, s, &authsvcv1.CreateOrgRequest{OwnerUserId: tokenUserID(t, ownerToken)})
+
+	email, _, token := registerAndLogin(ctx, t, s)
+	userID := tokenUserID(t, token)
+	userCtx := withToken(ctx, token)
+
+	_, err := s.OrgsClient.ListMembers(userCtx, &authsvcv1.ListMembersRequest{OrgId: orgID})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = PermissionDenied desc = not a member of the org")
+
+	respInvite, err := s.OrgsClient.CreateOrgInvite(ownerCtx, &authsvcv1.CreateOrgInviteRequest{
+		OrgId: orgID,
+		Email: gofakeit.Email(),
+	})
+	require.NoError(t, err)
+
+	// invite bound to another email
+	_, err = s.OrgsClient.AcceptOrgInvite(userCtx, &authsvcv1.AcceptOrgInviteRequest{Code: respInvite.GetCode()})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = PermissionDenied desc = invite code is not valid")
+
+	respInvite, err = s.OrgsClient.CreateOrgInvite(ownerCtx, &authsvcv1.CreateOrgInviteRequest{
+		OrgId: orgID,
+		Email: strings.ToUpper(email),
+		Role:  "admin",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "admin", respInvite.GetInvite().GetRole())
+
+	respAccept, err := s.OrgsClient.AcceptOrgInvite(userCtx, &authsvcv1.AcceptOrgInviteRequest{Code: respInvite.GetCode()})
+	require.NoError(t, err)
+	assert.Equal(t, orgID, respAccept.GetOrgId())
+	assert.Equal(t, "admin", respAccept.GetRole())
+
+	_, err = s.OrgsClient.AcceptOrgInvite(userCtx, &authsvcv1.AcceptOrgInviteRequest{Code: respInvite.GetCode()})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = PermissionDenied desc = invite code is not valid")
+
+	respMembers, err := s.OrgsClient.ListMembers(userCtx, &authsvcv1.ListMembersRequest{OrgId: orgID})
+	require.NoError(t, err)
+	assert.Len(t, respMembers.GetMembers(), 2)
+
+	// org admins can't make owners
+	_, err = s.OrgsClient.CreateOrgInvite(userCtx, &authsvcv1.CreateOrgInviteRequest{OrgId: orgID, Role: "owner"})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = PermissionDenied desc = org owner permission required")
+
+	respInvite, err = s.OrgsClient.CreateOrgInvite(userCtx, &authsvcv1.CreateOrgInviteRequest{OrgId: orgID})
+	require.NoError(t, err)
+	assert.Equal(t, "member", respInvite.GetInvite().GetRole())
+
+	_, err = s.OrgsClient.RevokeOrgInvite(userCtx, &authsvcv1.RevokeOrgInviteRequest{
+		OrgId: orgID,
+		Id:    respInvite.GetInvite().GetId(),
+	})
+	require.NoError(t, err)
+
+	// only the invite for another email is still active
+	respInvites, err := s.OrgsClient.ListOrgInvites(ownerCtx, &authsvcv1.ListOrgInvitesRequest{OrgId: orgID})
+	require.NoError(t, err)
+	assert.Len(t, respInvites.GetInvites(), 1)
+
+	respInvites, err = s.OrgsClient.ListOrgInvites(ownerCtx, &authsvcv1.ListOrgInvitesRequest{
+		OrgId:           orgID,
+		IncludeInactive: true,
+	})
+	require.NoError(t, err)
+	assert.Len(t, respInvites.GetInvites(), 3)
+
+	respOrgs, err := s.OrgsClient.ListOrgs(userCtx, &authsvcv1.ListOrgsRequest{})
+	require.NoError(t, err)
+	require.Len(t, respOrgs.GetOrgs(), 1)
+	assert.Equal(t, orgID, respOrgs.GetOrgs()[0].GetId())
+
+	_, err = s.OrgsClient.RemoveMember(userCtx, &authsvcv1.RemoveMemberRequest{OrgId: orgID, UserId: userID})
+	require.NoError(t, err)
+
+	_, err = s.OrgsClient.RemoveMember(ownerCtx, &authsvcv1.RemoveMemberRequest{OrgId: orgID, UserId: tokenUserID(t, ownerToken)})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = FailedPrecondition desc = last owner can't be removed")
+}
+
+func TestOrgs_SwitchOrg(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	_, _, token := registerAndLogin(ctx, t, s)
+	userID := tokenUserID(t, token)
+	userCtx := withToken(ctx, token)
+	orgID := createOrg(ctx, t, s, &authsvcv1.CreateOrgRequest{})
+
+	_, err := s.OrgsClient.SwitchOrg(userCtx, &authsvcv1.SwitchOrgRequest{OrgId: orgID})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = PermissionDenied desc = not a member of the org")
+
+	_, err = s.OrgsClient.AddMember(withToken(ctx, adminToken(t)), &authsvcv1.AddMemberRequest{
+		OrgId:  orgID,
+		UserId: userID,
+		Role:   "member",
+	})
+	require.NoError(t, err)
+
+	respSwitch, err := s.OrgsClient.SwitchOrg(userCtx, &authsvcv1.SwitchOrgRequest{OrgId: orgID})
+	require.NoError(t, err)
+	claims := tokenClaims(t, respSwitch.GetToken())
+	assert.Equal(t, float64(orgID), claims["org_id"])
+	assert.Equal(t, "member", claims["org_role"])
+	// switching doesn't extend the token
+	assert.Equal(t, tokenClaims(t, token)["exp"], claims["exp"])
+	assert.Equal(t, tokenClaims(t, token)["auth_time"], claims["auth_time"])
+
+	respSwitch, err = s.OrgsClient.SwitchOrg(withToken(ctx, respSwitch.GetToken()), &authsvcv1.SwitchOrgRequest{})
+	require.NoError(t, err)
+	_, ok := tokenClaims(t, respSwitch.GetToken())["org_id"]
+	assert.False(t, ok)
+}
+
+func TestOrgs_RequireAdmin(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	_, _, token := registerAndLogin(ctx, t, s)
+	userCtx := withToken(ctx, token)
+	orgID := createOrg(ctx, t, s, &authsvcv1.CreateOrgRequest{})
+
+	_, err := s.OrgsClient.CreateOrg(userCtx, &authsvcv1.CreateOrgRequest{Slug: "acme", Name: "Acme"})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = PermissionDenied desc = admin permission required")
+
+	_, err = s.OrgsClient.DeleteOrg(userCtx, &authsvcv1.DeleteOrgRequest{OrgId: orgID})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = PermissionDenied desc = admin permission required")
+
+	_, err = s.OrgsClient.ListOrgs(userCtx, &authsvcv1.ListOrgsRequest{All: true})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = PermissionDenied desc = admin permission required")
+
+	_, err = s.OrgsClient.UpdateOrg(userCtx, &authsvcv1.UpdateOrgRequest{OrgId: orgID, Name: "mine"})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = PermissionDenied desc = not a member of the org")
+
+	_, err = s.OrgsClient.ListOrgs(ctx, &authsvcv1.ListOrgsRequest{})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = Unauthenticated desc = authentication required")
+}
+
+func TestOrgs_OwnerMembership(t *testing.T) {
+	ctx, s := suite.New(t)
+	adminCtx := withToken(ctx, adminToken(t))
+
+	_, _, ownerToken := registerAndLogin(ctx, t, s)
+	ownerID := tokenUserID(t, ownerToken)
+	orgID := createOrg(ctx, t, s, &authsvcv1.CreateOrgRequest{OwnerUserId: ownerID})
+
+	_, _, orgAdminToken := registerAndLogin(ctx, t, s)
+	orgAdminCtx := withToken(ctx, orgAdminToken)
+	_, err := s.OrgsClient.AddMember(adminCtx, &authsvcv1.AddMemberRequest{
+		OrgId:  orgID,
+		UserId: tokenUserID(t, orgAdminToken),
+		Role:   "admin",
+	})
+	require.NoError(t, err)
+
+	// org admins can neither demote nor remove owners
+	_, err = s.OrgsClient.AddMember(orgAdminCtx, &authsvcv1.AddMemberRequest{OrgId: orgID, UserId: ownerID, Role: "member"})
+	assert.EqualError(t, err, "rpc error: code = PermissionDenied desc = org owner permission required")
+	_, err = s.OrgsClient.RemoveMember(orgAdminCtx, &authsvcv1.RemoveMemberRequest{OrgId: orgID, UserId: ownerID})
+	assert.EqualError(t, err, "rpc error: code = PermissionDenied desc = org owner permission required")
+
+	respMembers, err := s.OrgsClient.ListMembers(withToken(ctx, ownerToken), &authsvcv1.ListMembersRequest{OrgId: orgID})
+	require.NoError(t, err)
+	for _, member := range respMembers.GetMembers() {
+		if member.GetUserId() == ownerID {
+			assert.Equal(t, "owner", member.GetRole())
+		}
+	}
+
+	// but manage other members
+	_, _, memberToken := registerAndLogin(ctx, t, s)
+	memberID := tokenUserID(t, memberToken)
+	_, err = s.OrgsClient.AddMember(orgAdminCtx, &authsvcv1.AddMemberRequest{OrgId: orgID, UserId: memberID, Role: "member"})
+	require.NoError(t, err)
+	_, err = s.OrgsClient.RemoveMember(orgAdminCtx, &authsvcv1.RemoveMemberRequest{OrgId: orgID, UserId: memberID})
+	require.NoError(t, err)
+}
+
+func TestOrgs_InvalidInput(t *testing.T) {
+	ctx, s := suite.New(t)
+	adminCtx := withToken(ctx, adminToken(t))
+
+	slug := orgSlug()
+	_, err := s.OrgsClient.CreateOrg(adminCtx, &authsvcv1.CreateOrgRequest{Slug: slug, Name: "Acme"})
+	require.NoError(t, err)
+
+	_, err = s.OrgsClient.CreateOrg(adminCtx, &authsvcv1.CreateOrgRequest{Slug: slug, Name: "Acme"})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = AlreadyExists desc = org already exists")
+
+	_, err = s.OrgsClient.CreateOrg(adminCtx, &authsvcv1.CreateOrgRequest{Slug: "Bad Slug", Name: "Acme"})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = InvalidArgument desc = slug is not valid")
+
+	_, err = s.OrgsClient.CreateOrg(adminCtx, &authsvcv1.CreateOrgRequest{Slug: orgSlug()})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = InvalidArgument desc = name is not valid")
+
+	_, err = s.OrgsClient.CreateOrg(adminCtx, &authsvcv1.CreateOrgRequest{Slug: orgSlug(), Name: "Acme", AllowedApps: []int32{12345}})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = InvalidArgument desc = app id is not valid")
+
+	_, err = s.OrgsClient.CreateOrg(adminCtx, &authsvcv1.CreateOrgRequest{Slug: orgSlug(), Name: "Acme", OwnerUserId: notExistUserID})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = NotFound desc = user not found")
+
+	orgID := createOrg(ctx, t, s, &authsvcv1.CreateOrgRequest{})
+	_, err = s.OrgsClient.AddMember(adminCtx, &authsvcv1.AddMemberRequest{OrgId: orgID, UserId: adminUserID, Role: "guest"})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = InvalidArgument desc = role is not valid")
+
+	_, err = s.OrgsClient.GetOrg(adminCtx, &authsvcv1.GetOrgRequest{OrgId: 99999})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = NotFound desc = org not found")
+}
+
+// createOrg creates org with a random slug as admin and returns its ID
+func TestOrgs_APIKeys(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	_, _, token := registerAndLogin(ctx, t, s)
+	orgID := createOrg(ctx, t, s, &authsvcv1.CreateOrgRequest{OwnerUserId: tokenUserID(t, token)})
+	respKey, err := s.APIKeysClient.CreateAPIKey(withToken(ctx, token), &authsvcv1.CreateAPIKeyRequest{
+		Name:   "ci",
+		Scopes: []string{"*"},
+	})
+	require.NoError(t, err)
+	keyCtx := withToken(ctx, respKey.GetKey())
+
+	// orgs are managed by login only, even keys of owners can't act as members
+	_, err = s.OrgsClient.ListOrgs(keyCtx, &authsvcv1.ListOrgsRequest{})
+	assert.EqualError(t, err, "rpc error: code = PermissionDenied desc = api keys are not allowed")
+	_, err = s.OrgsClient.AddMember(keyCtx, &authsvcv1.AddMemberRequest{OrgId: orgID, UserId: adminUserID, Role: "owner"})
+	assert.EqualError(t, err, "rpc error: code = PermissionDenied desc = api keys are not allowed")
+	_, err = s.OrgsClient.AcceptOrgInvite(keyCtx, &authsvcv1.AcceptOrgInviteRequest{Code: "code"})
+	assert.EqualError(t, err, "rpc error: code = PermissionDenied desc = api keys are not allowed")
+}
+
+func createOrg(ctx context.Context, t *testing.T, s *suite.Suite, req *authsvcv1.CreateOrgRequest) int64 {
+	t.Helper()
+
+	req.Slug = orgSlug()
+	if req.Name == "" {
+		req.Name = gofakeit.Company()
+	}
+	resp, err := s.OrgsClient.CreateOrg(withToken(ctx, adminToken(t)), req)
+	require.NoError(t, err)
+
+	return resp.GetOrg().GetId()
+}
+
+func orgSlug() string {
+	return "org-" + strings.ToLower(gofakeit.LetterN(12))
+}
+
+func withOrg(ctx context.Context, orgID int64) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "x-org-id", strconv.FormatInt(orgID, 10))
+}
@@ -0,0 +1,188 @@
+package tests
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"testing"
+
+	authsvcv1 "github.com/Len4i/auth-service/gen/go/authsvc"
+	"github.com/Len4i/auth-service/internal/lib/mailer"
+	"github.com/Len4i/auth-service/tests/softauthn"
+	"github.com/Len4i/auth-service/tests/suite"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	loginCodeRe = regexp.MustCompile(`login code is (\d{6})`)
+	loginLinkRe = regexp.MustCompile(`https://\S+`)
+)
+
+func TestPasswordless_LoginCode(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	email, _, _ := registerAndLogin(ctx, t, s)
+
+	_, err := s.PasswordlessClient.RequestLoginCode(ctx, &authsvcv1.RequestLoginCodeRequest{Email: email, AppId: appID})
+	require.NoError(t, err)
+
+	code, _ := loginCodeMail(t, s, email)
+
+	resp, err := s.PasswordlessClient.RedeemLoginCode(ctx, &authsvcv1.RedeemLoginCodeRequest{Email: email, Code: code})
+	require.NoError(t, err)
+	assertTokenUser(t, resp.GetToken(), email)
+	assert.Equal(t, []interface{}{"email"}, tokenClaims(t, resp.GetToken())["amr"])
+
+	// code is single use
+	_, err = s.PasswordlessClient.RedeemLoginCode(ctx, &authsvcv1.RedeemLoginCodeRequest{Email: email, Code: code})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = Unauthenticated desc = code is not valid")
+}
+
+func TestPasswordless_MagicLink(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	email, _, _ := registerAndLogin(ctx, t, s)
+
+	_, err := s.PasswordlessClient.RequestLoginCode(ctx, &authsvcv1.RequestLoginCodeRequest{Email: email, AppId: appID})
+	require.NoError(t, err)
+
+	_, link := loginCodeMail(t, s, email)
+	u, err := url.Parse(link)
+	require.NoError(t, err)
+	linkToken := u.Query().Get("token")
+	require.NotEmpty(t, linkToken)
+
+	_, err = s.PasswordlessClient.RedeemLoginCode(ctx, &authsvcv1.RedeemLoginCodeRequest{LinkToken: linkToken + "x"})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = Unauthenticated desc = code is not valid")
+
+	resp, err := s.PasswordlessClient.RedeemLoginCode(ctx, &authsvcv1.RedeemLoginCodeRequest{LinkToken: linkToken})
+	require.NoError(t, err)
+	assertTokenUser(t, resp.GetToken(), email)
+
+	_, err = s.PasswordlessClient.RedeemLoginCode(ctx, &authsvcv1.RedeemLoginCodeRequest{LinkToken: linkToken})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = Unauthenticated desc = code is not valid")
+}
+
+func TestPasswordless_AttemptLimit(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	email, _, _ := registerAndLogin(ctx, t, s)
+
+	_, err := s.PasswordlessClient.RequestLoginCode(ctx, &authsvcv1.RequestLoginCodeRequest{Email: email, AppId: appID})
+	require.NoError(t, err)
+	code, _ := loginCodeMail(t, s, email)
+
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+	// concurrent guesses can't exceed the limit
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.PasswordlessClient.RedeemLoginCode(ctx, &authsvcv1.RedeemLoginCodeRequest{Email: email, Code: wrong})
+			assert.Error(t, err)
+		}()
+	}
+	wg.Wait()
+
+	_, err = s.PasswordlessClient.RedeemLoginCode(ctx, &authsvcv1.RedeemLoginCodeRequest{Email: email, Code: code})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = Unauthenticated desc = code is not valid")
+}
+
+func TestPasswordless_RequestLoginCode(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	email, _, _ := registerAndLogin(ctx, t, s)
+
+	// unknown users are not revealed
+	unknown := gofakeit.Email()
+	_, err := s.PasswordlessClient.RequestLoginCode(ctx, &authsvcv1.RequestLoginCodeRequest{Email: unknown, AppId: appID})
+	require.NoError(t, err)
+	assert.Empty(t, outboxMails(t, s, unknown))
+
+	_, err = s.PasswordlessClient.RequestLoginCode(ctx, &authsvcv1.RequestLoginCodeRequest{Email: email, AppId: appID})
+	require.NoError(t, err)
+
+	// repeated request is ignored
+	_, err = s.PasswordlessClient.RequestLoginCode(ctx, &authsvcv1.RequestLoginCodeRequest{Email: email, AppId: appID})
+	require.NoError(t, err)
+	assert.Len(t, outboxMails(t, s, email), 1)
+
+	_, err = s.PasswordlessClient.RequestLoginCode(ctx, &authsvcv1.RequestLoginCodeRequest{Email: email, AppId: 12345})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = InvalidArgument desc = app id is not valid")
+
+	_, err = s.PasswordlessClient.RequestLoginCode(ctx, &authsvcv1.RequestLoginCodeRequest{Email: email})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = InvalidArgument desc = app_id is required")
+}
+
+func TestPasswordless_MFARequired(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	email, _, token := registerAndLogin(ctx, t, s)
+	registerPasskey(ctx, t, s, softauthn.New(s.Cfg.WebAuthn.RPOrigins[0]), token)
+
+	_, err := s.PasswordlessClient.RequestLoginCode(ctx, &authsvcv1.RequestLoginCodeRequest{Email: email, AppId: appID})
+	require.NoError(t, err)
+	code, _ := loginCodeMail(t, s, email)
+
+	_, err = s.PasswordlessClient.RedeemLoginCode(ctx, &authsvcv1.RedeemLoginCodeRequest{Email: email, Code: code})
+	require.Error(t, err)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
+
+// loginCodeMail returns code and magic link from the last mail sent to email
+func loginCodeMail(t *testing.T, s *suite.Suite, email string) (code string, link string) {
+	t.Helper()
+
+	mails := outboxMails(t, s, email)
+	require.NotEmpty(t, mails)
+	body := mails[len(mails)-1].Body
+
+	m := loginCodeRe.FindStringSubmatch(body)
+	require.Len(t, m, 2, "no code in %q", body)
+
+	return m[1], loginLinkRe.FindString(body)
+}
+
+// outboxMails returns mails sent to email by the outbox mailer of the test server
+func outboxMails(t *testing.T, s *suite.Suite, email string) []mailer.Message {
+	t.Helper()
+
+	// config paths are relative to the repository root
+	f, err := os.Open(filepath.Join("..", s.Cfg.Mailer.OutboxPath))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	require.NoError(t, err)
+	defer f.Close()
+
+	var mails []mailer.Message
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var msg mailer.Message
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &msg))
+		if msg.To == email {
+			mails = append(mails, msg)
+		}
+	}
+	require.NoError(t, scanner.Err())
+
+	return mails
+}
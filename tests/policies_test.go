@@ -0,0 +1,258 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	authsvcv1 "github.com/Len4i/auth-service/gen/go/authsvc"
+	"github.com/Len4i/auth-service/tests/suite"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestPolicies_CheckAccess(t *testing.T) {
+	ctx, s := suite.New(t)
+	adminCtx := withToken(ctx, adminToken(t))
+
+	_, _, token := registerAndLogin(ctx, t, s)
+	userID := tokenUserID(t, token)
+	userCtx := withToken(ctx, token)
+
+	// actions are unique so that policies of parallel tests don't interfere
+	action := "edit-" + policySuffix()
+	allowName := "owner-" + policySuffix()
+	denyName := "locked-" + policySuffix()
+
+	_, err := s.PoliciesClient.CreatePolicy(adminCtx, &authsvcv1.CreatePolicyRequest{
+		AppId:      appID,
+		Name:       allowName,
+		Effect:     "allow",
+		Expression: `action == "` + action + `" && subject.id == resource.owner_id`,
+	})
+	require.NoError(t, err)
+
+	_, err = s.PoliciesClient.CreatePolicy(adminCtx, &authsvcv1.CreatePolicyRequest{
+		AppId:      appID,
+		Name:       denyName,
+		Effect:     "deny",
+		Expression: `action == "` + action + `" && has(resource.locked) && resource.locked`,
+	})
+	require.NoError(t, err)
+
+	respCheck, err := s.PoliciesClient.CheckAccess(userCtx, &authsvcv1.CheckAccessRequest{
+		Action:   action,
+		Resource: structValue(t, map[string]any{"owner_id": userID}),
+	})
+	require.NoError(t, err)
+	assert.True(t, respCheck.GetAllowed())
+	assert.Equal(t, allowName, respCheck.GetPolicy())
+
+	// api keys are allowed only actions within their scopes
+	respKey, err := s.APIKeysClient.CreateAPIKey(userCtx, &authsvcv1.CreateAPIKeyRequest{
+		Name:   "ci",
+		Scopes: []string{"view-" + policySuffix()},
+	})
+	require.NoError(t, err)
+	respCheck, err = s.PoliciesClient.CheckAccess(withToken(ctx, respKey.GetKey()), &authsvcv1.CheckAccessRequest{
+		Action:   action,
+		Resource: structValue(t, map[string]any{"owner_id": userID}),
+	})
+	require.NoError(t, err)
+	assert.False(t, respCheck.GetAllowed())
+
+	respCheck, err = s.PoliciesClient.CheckAccess(userCtx, &authsvcv1.CheckAccessRequest{
+		Action:   action,
+		Resource: structValue(t, map[string]any{"owner_id": userID, "locked": true}),
+	})
+	require.NoError(t, err)
+	assert.False(t, respCheck.GetAllowed())
+	assert.Equal(t, denyName, respCheck.GetPolicy())
+
+	// denied by default
+	respCheck, err = s.PoliciesClient.CheckAccess(userCtx, &authsvcv1.CheckAccessRequest{
+		Action:   action,
+		Resource: structValue(t, map[string]any{"owner_id": adminUserID}),
+	})
+	require.NoError(t, err)
+	assert.False(t, respCheck.GetAllowed())
+	assert.Empty(t, respCheck.GetPolicy())
+
+	_, err = s.PoliciesClient.UpdatePolicy(adminCtx, &authsvcv1.UpdatePolicyRequest{
+		AppId:      appID,
+		Name:       allowName,
+		Effect:     "allow",
+		Expression: `action == "` + action + `" && "admin" in subject.roles`,
+	})
+	require.NoError(t, err)
+
+	respCheck, err = s.PoliciesClient.CheckAccess(userCtx, &authsvcv1.CheckAccessRequest{
+		Action:   action,
+		Resource: structValue(t, map[string]any{"owner_id": userID}),
+	})
+	require.NoError(t, err)
+	assert.False(t, respCheck.GetAllowed())
+
+	_, err = s.PoliciesClient.DeletePolicy(adminCtx, &authsvcv1.DeletePolicyRequest{AppId: appID, Name: allowName})
+	require.NoError(t, err)
+
+	_, err = s.PoliciesClient.DeletePolicy(adminCtx, &authsvcv1.DeletePolicyRequest{AppId: appID, Name: allowName})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = NotFound desc = policy not found")
+}
+
+func TestPolicies_DryRun(t *testing.T) {
+	ctx, s := suite.New(t)
+	adminCtx := withToken(ctx, adminToken(t))
+
+	expression := `(subject.id == resource.owner_id || subject.org_role == "admin") && ` +
+		`now.getHours("UTC") >= 9 && now.getHours("UTC") < 17`
+	subject := structValue(t, map[string]any{"id": 7, "org_role": "member"})
+	resource := structValue(t, map[string]any{"owner_id": 7})
+	workday := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	resp, err := s.PoliciesClient.EvaluatePolicies(adminCtx, &authsvcv1.EvaluatePoliciesRequest{
+		AppId:      appID,
+		Expression: expression,
+		Subject:    subject,
+		Action:     "edit",
+		Resource:   resource,
+		Now:        workday.Unix(),
+	})
+	require.NoError(t, err)
+	assert.True(t, resp.GetAllowed())
+	require.Len(t, resp.GetResults(), 1)
+	assert.True(t, resp.GetResults()[0].GetMatched())
+
+	resp, err = s.PoliciesClient.EvaluatePolicies(adminCtx, &authsvcv1.EvaluatePoliciesRequest{
+		AppId:      appID,
+		Expression: expression,
+		Subject:    subject,
+		Action:     "edit",
+		Resource:   resource,
+		Now:        workday.Add(10 * time.Hour).Unix(),
+	})
+	require.NoError(t, err)
+	assert.False(t, resp.GetAllowed())
+
+	// missing attribute is reported, not matched
+	resp, err = s.PoliciesClient.EvaluatePolicies(adminCtx, &authsvcv1.EvaluatePoliciesRequest{
+		AppId:      appID,
+		Expression: expression,
+		Subject:    subject,
+		Action:     "edit",
+		Now:        workday.Unix(),
+	})
+	require.NoError(t, err)
+	assert.False(t, resp.GetAllowed())
+	require.Len(t, resp.GetResults(), 1)
+	assert.NotEmpty(t, resp.GetResults()[0].GetError())
+
+	_, err = s.PoliciesClient.EvaluatePolicies(adminCtx, &authsvcv1.EvaluatePoliciesRequest{
+		AppId:      appID,
+		Expression: `subject.id ==`,
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "rpc error: code = InvalidArgument desc = expression is not valid: ")
+}
+
+func TestPolicies_RequireAdmin(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	_, _, token := registerAndLogin(ctx, t, s)
+	userCtx := withToken(ctx, token)
+
+	_, err := s.PoliciesClient.CreatePolicy(userCtx, &authsvcv1.CreatePolicyRequest{
+		AppId:      appID,
+		Name:       "anyone",
+		Effect:     "allow",
+		Expression: "true",
+	})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = PermissionDenied desc = admin permission required")
+
+	_, err = s.PoliciesClient.EvaluatePolicies(userCtx, &authsvcv1.EvaluatePoliciesRequest{AppId: appID, Expression: "true"})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = PermissionDenied desc = admin permission required")
+
+	_, err = s.PoliciesClient.CheckAccess(ctx, &authsvcv1.CheckAccessRequest{Action: "read"})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = Unauthenticated desc = authentication required")
+
+	_, err = s.PoliciesClient.CheckAccess(userCtx, &authsvcv1.CheckAccessRequest{})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = InvalidArgument desc = action is required")
+}
+
+func TestPolicies_InvalidInput(t *testing.T) {
+	ctx, s := suite.New(t)
+	adminCtx := withToken(ctx, adminToken(t))
+
+	name := "policy-" + policySuffix()
+	_, err := s.PoliciesClient.CreatePolicy(adminCtx, &authsvcv1.CreatePolicyRequest{
+		AppId:      appID,
+		Name:       name,
+		Effect:     "allow",
+		Expression: "false",
+	})
+	require.NoError(t, err)
+
+	_, err = s.PoliciesClient.CreatePolicy(adminCtx, &authsvcv1.CreatePolicyRequest{
+		AppId:      appID,
+		Name:       name,
+		Effect:     "allow",
+		Expression: "false",
+	})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = AlreadyExists desc = policy already exists")
+
+	_, err = s.PoliciesClient.CreatePolicy(adminCtx, &authsvcv1.CreatePolicyRequest{
+		AppId:      appID,
+		Name:       "Bad Name",
+		Effect:     "allow",
+		Expression: "false",
+	})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = InvalidArgument desc = policy name is not valid")
+
+	_, err = s.PoliciesClient.CreatePolicy(adminCtx, &authsvcv1.CreatePolicyRequest{
+		AppId:      appID,
+		Name:       "policy-" + policySuffix(),
+		Effect:     "maybe",
+		Expression: "false",
+	})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = InvalidArgument desc = effect must be allow or deny")
+
+	_, err = s.PoliciesClient.CreatePolicy(adminCtx, &authsvcv1.CreatePolicyRequest{
+		AppId:      appID,
+		Name:       "policy-" + policySuffix(),
+		Effect:     "allow",
+		Expression: `action + "x"`,
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "rpc error: code = InvalidArgument desc = expression is not valid: ")
+
+	_, err = s.PoliciesClient.CreatePolicy(adminCtx, &authsvcv1.CreatePolicyRequest{
+		AppId:      12345,
+		Name:       "policy-" + policySuffix(),
+		Effect:     "allow",
+		Expression: "false",
+	})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = InvalidArgument desc = app id is not valid")
+}
+
+func policySuffix() string {
+	return strings.ToLower(gofakeit.LetterN(10))
+}
+
+func structValue(t *testing.T, m map[string]any) *structpb.Struct {
+	t.Helper()
+
+	value, err := structpb.NewStruct(m)
+	require.NoError(t, err)
+	return value
+}
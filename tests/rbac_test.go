@@ -0,0 +1,184 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	aaav1 "github.com/Len4i/aaa/gen/go/aaa"
+	authsvcv1 "github.com/Len4i/auth-service/gen/go/authsvc"
+	"github.com/Len4i/auth-service/tests/suite"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRBAC_AppRoles(t *testing.T) {
+	ctx, s := suite.New(t)
+	adminCtx := withToken(ctx, adminToken(t))
+
+	email, password, token := registerAndLogin(ctx, t, s)
+	userID := tokenUserID(t, token)
+	userCtx := withToken(ctx, token)
+	roleName := "editor-" + strings.ToLower(gofakeit.LetterN(8))
+
+	respCreate, err := s.RBACClient.CreateRole(adminCtx, &authsvcv1.CreateRoleRequest{
+		AppId:       appID,
+		Name:        roleName,
+		Description: "edits posts",
+		Permissions: []string{"posts:write", "posts:read"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"posts:read", "posts:write"}, respCreate.GetRole().GetPermissions())
+	assert.False(t, respCreate.GetRole().GetBuiltin())
+
+	_, err = s.RBACClient.CreateRole(adminCtx, &authsvcv1.CreateRoleRequest{AppId: appID, Name: roleName})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = AlreadyExists desc = role already exists")
+
+	respList, err := s.RBACClient.ListRoles(userCtx, &authsvcv1.ListRolesRequest{AppId: appID})
+	require.NoError(t, err)
+	assert.Contains(t, roleNames(respList.GetRoles()), roleName)
+	assert.Contains(t, roleNames(respList.GetRoles()), "admin")
+
+	_, err = s.RBACClient.AssignRole(adminCtx, &authsvcv1.AssignRoleRequest{UserId: userID, AppId: appID, Role: roleName})
+	require.NoError(t, err)
+
+	respHas, err := s.RBACClient.HasPermission(userCtx, &authsvcv1.HasPermissionRequest{Permission: "posts:write"})
+	require.NoError(t, err)
+	assert.True(t, respHas.GetAllowed())
+
+	respHas, err = s.RBACClient.HasPermission(userCtx, &authsvcv1.HasPermissionRequest{Permission: "posts:delete"})
+	require.NoError(t, err)
+	assert.False(t, respHas.GetAllowed())
+
+	// app roles are not granted in other apps
+	respHas, err = s.RBACClient.HasPermission(userCtx, &authsvcv1.HasPermissionRequest{
+		AppId:      inviteOnlyAppID,
+		Permission: "posts:write",
+	})
+	require.NoError(t, err)
+	assert.False(t, respHas.GetAllowed())
+
+	respUserRoles, err := s.RBACClient.ListRoles(userCtx, &authsvcv1.ListRolesRequest{AppId: appID, UserId: userID})
+	require.NoError(t, err)
+	assert.Equal(t, []string{roleName}, roleNames(respUserRoles.GetRoles()))
+
+	respLogin, err := s.AuthClient.Login(ctx, &aaav1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{roleName}, tokenClaims(t, respLogin.GetToken())["roles"])
+
+	_, err = s.RBACClient.RevokeRole(adminCtx, &authsvcv1.RevokeRoleRequest{UserId: userID, AppId: appID, Role: roleName})
+	require.NoError(t, err)
+
+	_, err = s.RBACClient.RevokeRole(adminCtx, &authsvcv1.RevokeRoleRequest{UserId: userID, AppId: appID, Role: roleName})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = NotFound desc = role is not assigned")
+
+	respHas, err = s.RBACClient.HasPermission(userCtx, &authsvcv1.HasPermissionRequest{Permission: "posts:write"})
+	require.NoError(t, err)
+	assert.False(t, respHas.GetAllowed())
+
+	_, err = s.RBACClient.DeleteRole(adminCtx, &authsvcv1.DeleteRoleRequest{AppId: appID, Name: roleName})
+	require.NoError(t, err)
+
+	_, err = s.RBACClient.AssignRole(adminCtx, &authsvcv1.AssignRoleRequest{UserId: userID, AppId: appID, Role: roleName})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = NotFound desc = role not found")
+}
+
+func TestRBAC_AdminRole(t *testing.T) {
+	ctx, s := suite.New(t)
+	adminCtx := withToken(ctx, adminToken(t))
+
+	_, _, token := registerAndLogin(ctx, t, s)
+	userID := tokenUserID(t, token)
+
+	// admin role grants every permission
+	respHas, err := s.RBACClient.HasPermission(adminCtx, &authsvcv1.HasPermissionRequest{Permission: "anything:at-all"})
+	require.NoError(t, err)
+	assert.True(t, respHas.GetAllowed())
+
+	_, err = s.RBACClient.AssignRole(adminCtx, &authsvcv1.AssignRoleRequest{UserId: userID, Role: "admin"})
+	require.NoError(t, err)
+
+	respIsAdmin, err := s.AuthClient.IsAdmin(ctx, &aaav1.IsAdminRequest{UserId: userID})
+	require.NoError(t, err)
+	assert.True(t, respIsAdmin.GetIsAdmin())
+
+	_, err = s.RBACClient.RevokeRole(adminCtx, &authsvcv1.RevokeRoleRequest{UserId: userID, Role: "admin"})
+	require.NoError(t, err)
+
+	respIsAdmin, err = s.AuthClient.IsAdmin(ctx, &aaav1.IsAdminRequest{UserId: userID})
+	require.NoError(t, err)
+	assert.False(t, respIsAdmin.GetIsAdmin())
+
+	_, err = s.RBACClient.DeleteRole(adminCtx, &authsvcv1.DeleteRoleRequest{Name: "admin"})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = FailedPrecondition desc = built-in role can't be deleted")
+}
+
+func TestRBAC_RequireAdmin(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	_, _, token := registerAndLogin(ctx, t, s)
+	userID := tokenUserID(t, token)
+	userCtx := withToken(ctx, token)
+
+	_, err := s.RBACClient.AssignRole(userCtx, &authsvcv1.AssignRoleRequest{UserId: userID, Role: "admin"})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = PermissionDenied desc = admin permission required")
+
+	_, err = s.RBACClient.CreateRole(userCtx, &authsvcv1.CreateRoleRequest{AppId: appID, Name: "viewer"})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = PermissionDenied desc = admin permission required")
+
+	// other users can be checked only by admins
+	_, err = s.RBACClient.HasPermission(userCtx, &authsvcv1.HasPermissionRequest{UserId: adminUserID, Permission: "posts:write"})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = PermissionDenied desc = admin permission required")
+
+	_, err = s.RBACClient.ListRoles(ctx, &authsvcv1.ListRolesRequest{AppId: appID})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = Unauthenticated desc = authentication required")
+}
+
+func TestRBAC_InvalidInput(t *testing.T) {
+	ctx, s := suite.New(t)
+	adminCtx := withToken(ctx, adminToken(t))
+
+	_, err := s.RBACClient.CreateRole(adminCtx, &authsvcv1.CreateRoleRequest{AppId: appID, Name: "Bad Name"})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = InvalidArgument desc = role name is not valid")
+
+	_, err = s.RBACClient.CreateRole(adminCtx, &authsvcv1.CreateRoleRequest{
+		AppId:       appID,
+		Name:        "viewer",
+		Permissions: []string{"posts read"},
+	})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = InvalidArgument desc = permission is not valid")
+
+	_, err = s.RBACClient.CreateRole(adminCtx, &authsvcv1.CreateRoleRequest{AppId: 12345, Name: "viewer"})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = InvalidArgument desc = app id is not valid")
+
+	_, err = s.RBACClient.AssignRole(adminCtx, &authsvcv1.AssignRoleRequest{UserId: notExistUserID, Role: "admin"})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = NotFound desc = user not found")
+}
+
+func tokenUserID(t *testing.T, token string) int64 {
+	t.Helper()
+
+	userID, ok := tokenClaims(t, token)["user_id"].(float64)
+	require.True(t, ok)
+	return int64(userID)
+}
+
+func roleNames(roles []*authsvcv1.Role) []string {
+	names := make([]string, 0, len(roles))
+	for _, role := range roles {
+		names = append(names, role.GetName())
+	}
+	return names
+}
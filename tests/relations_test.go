@@ -0,0 +1,310 @@
+package tests
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"strconv"
+	"testing"
+
+	authsvcv1 "github.com/Len4i/auth-service/gen/go/authsvc"
+	"github.com/Len4i/auth-service/tests/suite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelations_Check(t *testing.T) {
+	ctx, s := suite.New(t)
+	adminCtx := withToken(ctx, adminToken(t))
+
+	_, _, token := registerAndLogin(ctx, t, s)
+	userID := strconv.FormatInt(tokenUserID(t, token), 10)
+	userCtx := withToken(ctx, token)
+
+	group, folder, document := writeDocumentSchema(adminCtx, t, s)
+
+	// user is a member of the group, the group may view the folder
+	// and the document is in the folder
+	respWrite, err := s.RelationsClient.WriteTuples(adminCtx, &authsvcv1.WriteTuplesRequest{
+		Tuples: []*authsvcv1.RelationTuple{
+			relationTuple(group, "eng", "member", userSubject(userID)),
+			relationTuple(folder, "docs", "viewer", subjectSet(group, "eng", "member")),
+			relationTuple(document, "readme", "parent", subjectSet(folder, "docs", "")),
+		},
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, respWrite.GetConsistencyToken())
+
+	respCheck, err := s.RelationsClient.Check(userCtx, &authsvcv1.CheckRequest{
+		Namespace:        document,
+		ObjectId:         "readme",
+		Relation:         "viewer",
+		ConsistencyToken: respWrite.GetConsistencyToken(),
+	})
+	require.NoError(t, err)
+	assert.True(t, respCheck.GetAllowed())
+	assert.NotEmpty(t, respCheck.GetConsistencyToken())
+
+	respCheck, err = s.RelationsClient.Check(userCtx, &authsvcv1.CheckRequest{
+		Namespace:        document,
+		ObjectId:         "readme",
+		Relation:         "editor",
+		ConsistencyToken: respWrite.GetConsistencyToken(),
+	})
+	require.NoError(t, err)
+	assert.False(t, respCheck.GetAllowed())
+
+	// editors are viewers
+	respWrite, err = s.RelationsClient.WriteTuples(adminCtx, &authsvcv1.WriteTuplesRequest{
+		Tuples: []*authsvcv1.RelationTuple{
+			relationTuple(document, "design", "editor", userSubject(userID)),
+		},
+	})
+	require.NoError(t, err)
+
+	for _, relation := range []string{"editor", "viewer"} {
+		respCheck, err = s.RelationsClient.Check(userCtx, &authsvcv1.CheckRequest{
+			Namespace:        document,
+			ObjectId:         "design",
+			Relation:         relation,
+			ConsistencyToken: respWrite.GetConsistencyToken(),
+		})
+		require.NoError(t, err)
+		assert.True(t, respCheck.GetAllowed(), relation)
+	}
+
+	// read after delete
+	respDelete, err := s.RelationsClient.DeleteTuples(adminCtx, &authsvcv1.DeleteTuplesRequest{
+		Tuples: []*authsvcv1.RelationTuple{
+			relationTuple(group, "eng", "member", userSubject(userID)),
+		},
+	})
+	require.NoError(t, err)
+
+	respCheck, err = s.RelationsClient.Check(userCtx, &authsvcv1.CheckRequest{
+		Namespace:        document,
+		ObjectId:         "readme",
+		Relation:         "viewer",
+		ConsistencyToken: respDelete.GetConsistencyToken(),
+	})
+	require.NoError(t, err)
+	assert.False(t, respCheck.GetAllowed())
+
+	// admin checks other users
+	respCheck, err = s.RelationsClient.Check(adminCtx, &authsvcv1.CheckRequest{
+		Namespace: document,
+		ObjectId:  "design",
+		Relation:  "viewer",
+		UserId:    userID,
+	})
+	require.NoError(t, err)
+	assert.True(t, respCheck.GetAllowed())
+}
+
+func TestRelations_CheckFails(t *testing.T) {
+	ctx, s := suite.New(t)
+	adminCtx := withToken(ctx, adminToken(t))
+
+	_, _, token := registerAndLogin(ctx, t, s)
+	userCtx := withToken(ctx, token)
+
+	_, _, document := writeDocumentSchema(adminCtx, t, s)
+
+	_, err := s.RelationsClient.Check(userCtx, &authsvcv1.CheckRequest{
+		Namespace: document,
+		ObjectId:  "readme",
+		Relation:  "viewer",
+		UserId:    strconv.Itoa(adminUserID),
+	})
+	assert.EqualError(t, err, "rpc error: code = PermissionDenied desc = admin permission required")
+
+	_, err = s.RelationsClient.Check(userCtx, &authsvcv1.CheckRequest{
+		Namespace: document,
+		ObjectId:  "readme",
+		Relation:  "owner",
+	})
+	assert.EqualError(t, err, "rpc error: code = InvalidArgument desc = relation is not defined")
+
+	_, err = s.RelationsClient.Check(userCtx, &authsvcv1.CheckRequest{
+		Namespace: "missing_" + policySuffix(),
+		ObjectId:  "readme",
+		Relation:  "viewer",
+	})
+	assert.EqualError(t, err, "rpc error: code = InvalidArgument desc = namespace is not defined")
+
+	_, err = s.RelationsClient.Check(userCtx, &authsvcv1.CheckRequest{
+		Namespace:        document,
+		ObjectId:         "readme",
+		Relation:         "viewer",
+		ConsistencyToken: "not a token",
+	})
+	assert.EqualError(t, err, "rpc error: code = InvalidArgument desc = consistency token is not valid")
+
+	ahead := base64.RawURLEncoding.EncodeToString(binary.BigEndian.AppendUint64(nil, 1<<40))
+	_, err = s.RelationsClient.Check(userCtx, &authsvcv1.CheckRequest{
+		Namespace:        document,
+		ObjectId:         "readme",
+		Relation:         "viewer",
+		ConsistencyToken: ahead,
+	})
+	assert.EqualError(t, err, "rpc error: code = FailedPrecondition desc = consistency token is ahead of the store")
+}
+
+func TestRelations_Expand(t *testing.T) {
+	ctx, s := suite.New(t)
+	adminCtx := withToken(ctx, adminToken(t))
+
+	group, folder, document := writeDocumentSchema(adminCtx, t, s)
+
+	respWrite, err := s.RelationsClient.WriteTuples(adminCtx, &authsvcv1.WriteTuplesRequest{
+		Tuples: []*authsvcv1.RelationTuple{
+			relationTuple(group, "eng", "member", userSubject("1")),
+			relationTuple(folder, "docs", "viewer", subjectSet(group, "eng", "member")),
+			relationTuple(document, "readme", "parent", subjectSet(folder, "docs", "")),
+			relationTuple(document, "readme", "editor", userSubject("2")),
+		},
+	})
+	require.NoError(t, err)
+
+	respExpand, err := s.RelationsClient.Expand(adminCtx, &authsvcv1.ExpandRequest{
+		Namespace:        document,
+		ObjectId:         "readme",
+		Relation:         "viewer",
+		ConsistencyToken: respWrite.GetConsistencyToken(),
+	})
+	require.NoError(t, err)
+
+	tree := respExpand.GetTree()
+	assert.Equal(t, document, tree.GetNamespace())
+	assert.Equal(t, "viewer", tree.GetRelation())
+	assert.Empty(t, tree.GetUsers())
+	require.Len(t, tree.GetChildren(), 2)
+
+	editors := tree.GetChildren()[0]
+	assert.Equal(t, "editor", editors.GetRelation())
+	assert.Equal(t, []string{"2"}, editors.GetUsers())
+
+	folderViewers := tree.GetChildren()[1]
+	assert.Equal(t, folder, folderViewers.GetNamespace())
+	assert.Equal(t, "docs", folderViewers.GetObjectId())
+	require.Len(t, folderViewers.GetChildren(), 1)
+	assert.Equal(t, []string{"1"}, folderViewers.GetChildren()[0].GetUsers())
+
+	respRead, err := s.RelationsClient.ReadTuples(adminCtx, &authsvcv1.ReadTuplesRequest{
+		Namespace: document,
+		ObjectId:  "readme",
+	})
+	require.NoError(t, err)
+	assert.Len(t, respRead.GetTuples(), 2)
+}
+
+func TestRelations_WriteFails(t *testing.T) {
+	ctx, s := suite.New(t)
+	adminCtx := withToken(ctx, adminToken(t))
+
+	_, _, token := registerAndLogin(ctx, t, s)
+	userCtx := withToken(ctx, token)
+
+	_, _, document := writeDocumentSchema(adminCtx, t, s)
+
+	_, err := s.RelationsClient.WriteTuples(userCtx, &authsvcv1.WriteTuplesRequest{
+		Tuples: []*authsvcv1.RelationTuple{
+			relationTuple(document, "readme", "viewer", userSubject("1")),
+		},
+	})
+	assert.EqualError(t, err, "rpc error: code = PermissionDenied desc = admin permission required")
+
+	_, err = s.RelationsClient.WriteTuples(adminCtx, &authsvcv1.WriteTuplesRequest{
+		Tuples: []*authsvcv1.RelationTuple{
+			relationTuple(document, "readme", "owner", userSubject("1")),
+		},
+	})
+	assert.EqualError(t, err, "rpc error: code = InvalidArgument desc = relation is not defined")
+
+	_, err = s.RelationsClient.WriteTuples(adminCtx, &authsvcv1.WriteTuplesRequest{
+		Tuples: []*authsvcv1.RelationTuple{
+			relationTuple(document, "readme", "viewer", nil),
+		},
+	})
+	assert.EqualError(t, err, "rpc error: code = InvalidArgument desc = tuple subject is required")
+
+	_, err = s.RelationsClient.WriteSchema(adminCtx, &authsvcv1.WriteSchemaRequest{
+		Namespaces: []*authsvcv1.Namespace{{
+			Name: "broken_" + policySuffix(),
+			Relations: []*authsvcv1.Relation{{
+				Name:    "viewer",
+				Rewrite: []*authsvcv1.Userset{computedUserset("editor")},
+			}},
+		}},
+	})
+	assert.EqualError(t, err, "rpc error: code = InvalidArgument desc = namespace is not valid")
+}
+
+// writeDocumentSchema writes namespaces unique to the test where viewers
+// of a document are its editors and viewers of its parent folder
+func writeDocumentSchema(ctx context.Context, t *testing.T, s *suite.Suite) (group, folder, document string) {
+	t.Helper()
+
+	suffix := policySuffix()
+	group, folder, document = "group_"+suffix, "folder_"+suffix, "document_"+suffix
+
+	this := &authsvcv1.Userset{Userset: &authsvcv1.Userset_This{This: true}}
+	_, err := s.RelationsClient.WriteSchema(ctx, &authsvcv1.WriteSchemaRequest{
+		Namespaces: []*authsvcv1.Namespace{
+			{
+				Name:      group,
+				Relations: []*authsvcv1.Relation{{Name: "member"}},
+			},
+			{
+				Name:      folder,
+				Relations: []*authsvcv1.Relation{{Name: "viewer"}},
+			},
+			{
+				Name: document,
+				Relations: []*authsvcv1.Relation{
+					{Name: "parent"},
+					{Name: "editor"},
+					{
+						Name: "viewer",
+						Rewrite: []*authsvcv1.Userset{
+							this,
+							computedUserset("editor"),
+							{Userset: &authsvcv1.Userset_TupleToUserset{TupleToUserset: &authsvcv1.TupleToUserset{
+								Tupleset:        "parent",
+								ComputedUserset: "viewer",
+							}}},
+						},
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	return group, folder, document
+}
+
+func computedUserset(relation string) *authsvcv1.Userset {
+	return &authsvcv1.Userset{Userset: &authsvcv1.Userset_ComputedUserset{ComputedUserset: relation}}
+}
+
+func relationTuple(namespace, objectID, relation string, subject *authsvcv1.Subject) *authsvcv1.RelationTuple {
+	return &authsvcv1.RelationTuple{
+		Namespace: namespace,
+		ObjectId:  objectID,
+		Relation:  relation,
+		Subject:   subject,
+	}
+}
+
+func userSubject(userID string) *authsvcv1.Subject {
+	return &authsvcv1.Subject{Subject: &authsvcv1.Subject_UserId{UserId: userID}}
+}
+
+func subjectSet(namespace, objectID, relation string) *authsvcv1.Subject {
+	return &authsvcv1.Subject{Subject: &authsvcv1.Subject_SubjectSet{SubjectSet: &authsvcv1.SubjectSet{
+		Namespace: namespace,
+		ObjectId:  objectID,
+		Relation:  relation,
+	}}}
+}
@@ -0,0 +1,495 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	aaav1 "github.com/Len4i/aaa/gen/go/aaa"
+	authsvcv1 "github.com/Len4i/auth-service/gen/go/authsvc"
+	"github.com/Len4i/auth-service/tests/suite"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const scimErrorSchema = "urn:ietf:params:scim:api:messages:2.0:Error"
+
+func TestSCIM_Tokens(t *testing.T) {
+	ctx, s := suite.New(t)
+	adminCtx := withToken(ctx, adminToken(t))
+
+	orgID := createOrg(ctx, t, s, &authsvcv1.CreateOrgRequest{})
+
+	respCreate, err := s.OrgsClient.CreateSCIMToken(adminCtx, &authsvcv1.CreateSCIMTokenRequest{OrgId: orgID, Name: "okta"})
+	require.NoError(t, err)
+	token := respCreate.GetToken()
+	assert.True(t, strings.HasPrefix(token, "scim_"))
+	assert.Equal(t, orgID, respCreate.GetScimToken().GetOrgId())
+	assert.Equal(t, "okta", respCreate.GetScimToken().GetName())
+	assert.Equal(t, int64(adminUserID), respCreate.GetScimToken().GetCreatedBy())
+	assert.Zero(t, respCreate.GetScimToken().GetLastUsedAt())
+
+	status, body := scimDo(t, s, token, http.MethodGet, "/Users", nil)
+	require.Equal(t, http.StatusOK, status, body)
+	assert.Equal(t, []any{"urn:ietf:params:scim:api:messages:2.0:ListResponse"}, body["schemas"])
+	assert.Equal(t, float64(0), body["totalResults"])
+	assert.Equal(t, []any{}, body["Resources"])
+
+	respList, err := s.OrgsClient.ListSCIMTokens(adminCtx, &authsvcv1.ListSCIMTokensRequest{OrgId: orgID})
+	require.NoError(t, err)
+	require.Len(t, respList.GetScimTokens(), 1)
+	assert.NotZero(t, respList.GetScimTokens()[0].GetLastUsedAt())
+
+	_, err = s.OrgsClient.RevokeSCIMToken(adminCtx, &authsvcv1.RevokeSCIMTokenRequest{
+		OrgId: orgID,
+		Id:    respCreate.GetScimToken().GetId(),
+	})
+	require.NoError(t, err)
+
+	status, body = scimDo(t, s, token, http.MethodGet, "/Users", nil)
+	require.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, []any{scimErrorSchema}, body["schemas"])
+	assert.Equal(t, "401", body["status"])
+
+	respList, err = s.OrgsClient.ListSCIMTokens(adminCtx, &authsvcv1.ListSCIMTokensRequest{OrgId: orgID})
+	require.NoError(t, err)
+	assert.Empty(t, respList.GetScimTokens())
+
+	respList, err = s.OrgsClient.ListSCIMTokens(adminCtx, &authsvcv1.ListSCIMTokensRequest{OrgId: orgID, IncludeRevoked: true})
+	require.NoError(t, err)
+	require.Len(t, respList.GetScimTokens(), 1)
+	assert.True(t, respList.GetScimTokens()[0].GetRevoked())
+}
+
+func TestSCIM_TokensErrors(t *testing.T) {
+	ctx, s := suite.New(t)
+	adminCtx := withToken(ctx, adminToken(t))
+
+	_, _, token := registerAndLogin(ctx, t, s)
+	orgID := createOrg(ctx, t, s, &authsvcv1.CreateOrgRequest{})
+
+	_, err := s.OrgsClient.CreateSCIMToken(withToken(ctx, token), &authsvcv1.CreateSCIMTokenRequest{OrgId: orgID, Name: "okta"})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = PermissionDenied desc = not a member of the org")
+
+	_, err = s.OrgsClient.CreateSCIMToken(adminCtx, &authsvcv1.CreateSCIMTokenRequest{OrgId: orgID})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = InvalidArgument desc = name is not valid")
+
+	_, err = s.OrgsClient.CreateSCIMToken(adminCtx, &authsvcv1.CreateSCIMTokenRequest{OrgId: 99999, Name: "okta"})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = NotFound desc = org not found")
+
+	_, err = s.OrgsClient.RevokeSCIMToken(adminCtx, &authsvcv1.RevokeSCIMTokenRequest{OrgId: orgID, Id: 99999})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = NotFound desc = scim token not found")
+
+	for _, header := range []string{"", "Bearer invalid", "Bearer scim_invalid", "Bearer " + token} {
+		req, err := http.NewRequest(http.MethodGet, s.HTTPURL+"/scim/v2/Users", nil)
+		require.NoError(t, err)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		resp := httpDo(t, req)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, header)
+		assert.Contains(t, resp.Header.Get("WWW-Authenticate"), "Bearer")
+	}
+
+	// the service provider config is public
+	resp := httpGet(t, s, "/scim/v2/ServiceProviderConfig")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, readBody(t, resp), `"patch":{"supported":true}`)
+}
+
+func TestSCIM_Users(t *testing.T) {
+	ctx, s := suite.New(t)
+	adminCtx := withToken(ctx, adminToken(t))
+
+	orgID, token := scimOrg(ctx, t, s)
+	email := gofakeit.Email()
+
+	status, user := scimDo(t, s, token, http.MethodPost, "/Users", map[string]any{
+		"schemas":    []string{"urn:ietf:params:scim:schemas:core:2.0:User"},
+		"userName":   email,
+		"externalId": "00u1",
+		"name":       map[string]any{"givenName": "Barbara", "familyName": "Jensen"},
+		"emails":     []any{map[string]any{"value": email, "primary": true}},
+	})
+	require.Equal(t, http.StatusCreated, status, user)
+	id := user["id"].(string)
+	assert.Equal(t, email, user["userName"])
+	assert.Equal(t, "00u1", user["externalId"])
+	assert.Equal(t, true, user["active"])
+	assert.Equal(t, map[string]any{"givenName": "Barbara", "familyName": "Jensen"}, user["name"])
+	assert.Equal(t, "User", user["meta"].(map[string]any)["resourceType"])
+	assert.True(t, strings.HasSuffix(user["meta"].(map[string]any)["location"].(string), "/scim/v2/Users/"+id))
+
+	// active users are members of the org
+	respMembers, err := s.OrgsClient.ListMembers(adminCtx, &authsvcv1.ListMembersRequest{OrgId: orgID})
+	require.NoError(t, err)
+	require.Len(t, respMembers.GetMembers(), 1)
+	assert.Equal(t, email, respMembers.GetMembers()[0].GetEmail())
+	assert.Equal(t, "member", respMembers.GetMembers()[0].GetRole())
+
+	status, body := scimDo(t, s, token, http.MethodPost, "/Users", map[string]any{"userName": email})
+	require.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, "uniqueness", body["scimType"])
+
+	status, body = scimDo(t, s, token, http.MethodGet, "/Users/"+id, nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, email, body["userName"])
+
+	status, body = scimDo(t, s, token, http.MethodGet, `/Users?filter=userName+eq+"`+strings.ToUpper(email)+`"`, nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, float64(1), body["totalResults"])
+	assert.Equal(t, id, body["Resources"].([]any)[0].(map[string]any)["id"])
+
+	status, body = scimDo(t, s, token, http.MethodGet, `/Users?filter=externalId+eq+"other"`, nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, float64(0), body["totalResults"])
+
+	status, body = scimDo(t, s, token, http.MethodGet, `/Users?filter=userName+like+"a"`, nil)
+	require.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalidFilter", body["scimType"])
+	assert.Equal(t, "400", body["status"])
+
+	// some identity providers send booleans as strings
+	status, body = scimDo(t, s, token, http.MethodPatch, "/Users/"+id, map[string]any{
+		"schemas": []string{"urn:ietf:params:scim:api:messages:2.0:PatchOp"},
+		"Operations": []any{
+			map[string]any{"op": "Replace", "path": "active", "value": "False"},
+			map[string]any{"op": "replace", "path": "name.givenName", "value": "Babs"},
+			map[string]any{"op": "add", "value": map[string]any{"displayName": "Babs Jensen"}},
+		},
+	})
+	require.Equal(t, http.StatusOK, status, body)
+	assert.Equal(t, false, body["active"])
+	assert.Equal(t, "Babs Jensen", body["displayName"])
+	assert.Equal(t, "Babs", body["name"].(map[string]any)["givenName"])
+
+	respMembers, err = s.OrgsClient.ListMembers(adminCtx, &authsvcv1.ListMembersRequest{OrgId: orgID})
+	require.NoError(t, err)
+	assert.Empty(t, respMembers.GetMembers())
+
+	status, body = scimDo(t, s, token, http.MethodPatch, "/Users/"+id, map[string]any{
+		"Operations": []any{map[string]any{"op": "remove", "path": "userName"}},
+	})
+	require.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalidPath", body["scimType"])
+
+	newEmail := gofakeit.Email()
+	status, body = scimDo(t, s, token, http.MethodPut, "/Users/"+id, map[string]any{
+		"userName": newEmail,
+		"active":   true,
+	})
+	require.Equal(t, http.StatusOK, status, body)
+	assert.Equal(t, newEmail, body["userName"])
+	assert.Equal(t, true, body["active"])
+	assert.Nil(t, body["externalId"])
+
+	respMembers, err = s.OrgsClient.ListMembers(adminCtx, &authsvcv1.ListMembersRequest{OrgId: orgID})
+	require.NoError(t, err)
+	require.Len(t, respMembers.GetMembers(), 1)
+	assert.Equal(t, newEmail, respMembers.GetMembers()[0].GetEmail())
+
+	// resources are scoped to the org of the token
+	_, otherToken := scimOrg(ctx, t, s)
+	status, body = scimDo(t, s, otherToken, http.MethodGet, "/Users/"+id, nil)
+	require.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, "404", body["status"])
+
+	status, _ = scimDo(t, s, token, http.MethodDelete, "/Users/"+id, nil)
+	require.Equal(t, http.StatusNoContent, status)
+
+	status, _ = scimDo(t, s, token, http.MethodGet, "/Users/"+id, nil)
+	require.Equal(t, http.StatusNotFound, status)
+
+	respMembers, err = s.OrgsClient.ListMembers(adminCtx, &authsvcv1.ListMembersRequest{OrgId: orgID})
+	require.NoError(t, err)
+	assert.Empty(t, respMembers.GetMembers())
+}
+
+func TestSCIM_ExistingUser(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	email, password, userToken := registerAndLogin(ctx, t, s)
+	orgID, token := scimOrg(ctx, t, s)
+
+	// accounts of others can't be taken over
+	status, user := scimDo(t, s, token, http.MethodPost, "/Users", map[string]any{
+		"userName":    email,
+		"displayName": "Someone Else",
+	})
+	require.Equal(t, http.StatusConflict, status, user)
+	assert.Equal(t, "uniqueness", user["scimType"])
+
+	_, err := s.OrgsClient.AddMember(withToken(ctx, adminToken(t)), &authsvcv1.AddMemberRequest{
+		OrgId:  orgID,
+		UserId: tokenUserID(t, userToken),
+		Role:   "member",
+	})
+	require.NoError(t, err)
+
+	status, user = scimDo(t, s, token, http.MethodPost, "/Users", map[string]any{
+		"userName":    email,
+		"displayName": "Someone Else",
+	})
+	require.Equal(t, http.StatusCreated, status, user)
+	id := user["id"].(string)
+	// the user is linked as is
+	assert.Nil(t, user["displayName"])
+
+	status, body := scimDo(t, s, token, http.MethodPatch, "/Users/"+id, map[string]any{
+		"Operations": []any{map[string]any{"op": "replace", "path": "userName", "value": gofakeit.Email()}},
+	})
+	require.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "mutability", body["scimType"])
+
+	// the user keeps logging in with the password
+	_, err = s.AuthClient.Login(ctx, &aaav1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+
+	status, _ = scimDo(t, s, token, http.MethodDelete, "/Users/"+id, nil)
+	require.Equal(t, http.StatusNoContent, status)
+
+	_, err = s.AuthClient.Login(ctx, &aaav1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+}
+
+func TestSCIM_Owner(t *testing.T) {
+	ctx, s := suite.New(t)
+	adminCtx := withToken(ctx, adminToken(t))
+
+	email, _, userToken := registerAndLogin(ctx, t, s)
+	orgID, token := scimOrg(ctx, t, s)
+	_, err := s.OrgsClient.AddMember(adminCtx, &authsvcv1.AddMemberRequest{
+		OrgId:  orgID,
+		UserId: tokenUserID(t, userToken),
+		Role:   "owner",
+	})
+	require.NoError(t, err)
+
+	status, user := scimDo(t, s, token, http.MethodPost, "/Users", map[string]any{"userName": email})
+	require.Equal(t, http.StatusCreated, status, user)
+	id := user["id"].(string)
+
+	// the identity provider can't remove owners of the org
+	status, body := scimDo(t, s, token, http.MethodPatch, "/Users/"+id, map[string]any{
+		"Operations": []any{map[string]any{"op": "replace", "path": "active", "value": false}},
+	})
+	require.Equal(t, http.StatusOK, status, body)
+	status, _ = scimDo(t, s, token, http.MethodDelete, "/Users/"+id, nil)
+	require.Equal(t, http.StatusNoContent, status)
+
+	respMembers, err := s.OrgsClient.ListMembers(adminCtx, &authsvcv1.ListMembersRequest{OrgId: orgID})
+	require.NoError(t, err)
+	require.Len(t, respMembers.GetMembers(), 1)
+	assert.Equal(t, email, respMembers.GetMembers()[0].GetEmail())
+	assert.Equal(t, "owner", respMembers.GetMembers()[0].GetRole())
+}
+
+func TestSCIM_UsersPagination(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	_, token := scimOrg(ctx, t, s)
+
+	var ids []any
+	for i := 0; i < 3; i++ {
+		status, user := scimDo(t, s, token, http.MethodPost, "/Users", map[string]any{"userName": gofakeit.Email()})
+		require.Equal(t, http.StatusCreated, status, user)
+		ids = append(ids, user["id"])
+	}
+
+	tests := []struct {
+		query        string
+		wantIDs      []any
+		wantStartIdx float64
+		wantPerPage  float64
+		wantStatus   int
+		wantSCIMType string
+	}{
+		{query: "", wantIDs: ids, wantStartIdx: 1, wantPerPage: 3, wantStatus: http.StatusOK},
+		{query: "?startIndex=2&count=1", wantIDs: ids[1:2], wantStartIdx: 2, wantPerPage: 1, wantStatus: http.StatusOK},
+		{query: "?startIndex=3&count=10", wantIDs: ids[2:], wantStartIdx: 3, wantPerPage: 1, wantStatus: http.StatusOK},
+		{query: "?startIndex=0&count=2", wantIDs: ids[:2], wantStartIdx: 1, wantPerPage: 2, wantStatus: http.StatusOK},
+		{query: "?count=0", wantIDs: []any{}, wantStartIdx: 1, wantPerPage: 0, wantStatus: http.StatusOK},
+		{query: "?startIndex=10", wantIDs: []any{}, wantStartIdx: 10, wantPerPage: 0, wantStatus: http.StatusOK},
+		{query: "?count=abc", wantStatus: http.StatusBadRequest, wantSCIMType: "invalidValue"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			status, body := scimDo(t, s, token, http.MethodGet, "/Users"+tt.query, nil)
+			require.Equal(t, tt.wantStatus, status, body)
+			if tt.wantSCIMType != "" {
+				assert.Equal(t, tt.wantSCIMType, body["scimType"])
+				return
+			}
+
+			assert.Equal(t, float64(3), body["totalResults"])
+			assert.Equal(t, tt.wantStartIdx, body["startIndex"])
+			assert.Equal(t, tt.wantPerPage, body["itemsPerPage"])
+			gotIDs := []any{}
+			for _, resource := range body["Resources"].([]any) {
+				gotIDs = append(gotIDs, resource.(map[string]any)["id"])
+			}
+			assert.Equal(t, tt.wantIDs, gotIDs)
+		})
+	}
+}
+
+func TestSCIM_Groups(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	_, token := scimOrg(ctx, t, s)
+
+	var userIDs []string
+	for i := 0; i < 3; i++ {
+		status, user := scimDo(t, s, token, http.MethodPost, "/Users", map[string]any{"userName": gofakeit.Email()})
+		require.Equal(t, http.StatusCreated, status, user)
+		userIDs = append(userIDs, user["id"].(string))
+	}
+
+	status, group := scimDo(t, s, token, http.MethodPost, "/Groups", map[string]any{
+		"schemas":     []string{"urn:ietf:params:scim:schemas:core:2.0:Group"},
+		"displayName": "Engineering",
+		"members":     []any{map[string]any{"value": userIDs[0]}},
+	})
+	require.Equal(t, http.StatusCreated, status, group)
+	id := group["id"].(string)
+	assert.Equal(t, "Engineering", group["displayName"])
+	assert.Equal(t, []string{userIDs[0]}, scimMemberIDs(group))
+
+	status, user := scimDo(t, s, token, http.MethodGet, "/Users/"+userIDs[0], nil)
+	require.Equal(t, http.StatusOK, status)
+	require.Len(t, user["groups"], 1)
+	assert.Equal(t, id, user["groups"].([]any)[0].(map[string]any)["value"])
+	assert.Equal(t, "Engineering", user["groups"].([]any)[0].(map[string]any)["display"])
+
+	status, body := scimDo(t, s, token, http.MethodPost, "/Groups", map[string]any{"displayName": "Engineering"})
+	require.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, "uniqueness", body["scimType"])
+
+	status, body = scimDo(t, s, token, http.MethodPost, "/Groups", map[string]any{"displayName": ""})
+	require.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalidValue", body["scimType"])
+
+	status, body = scimDo(t, s, token, http.MethodPatch, "/Groups/"+id, map[string]any{
+		"Operations": []any{
+			map[string]any{"op": "add", "path": "members", "value": []any{
+				map[string]any{"value": userIDs[1]},
+				map[string]any{"value": userIDs[2]},
+			}},
+			map[string]any{"op": "remove", "path": `members[value eq "` + userIDs[0] + `"]`},
+			map[string]any{"op": "replace", "path": "displayName", "value": "Platform"},
+		},
+	})
+	require.Equal(t, http.StatusOK, status, body)
+	assert.Equal(t, "Platform", body["displayName"])
+	assert.Equal(t, userIDs[1:], scimMemberIDs(body))
+
+	// members listed in the value are removed
+	status, body = scimDo(t, s, token, http.MethodPatch, "/Groups/"+id, map[string]any{
+		"Operations": []any{
+			map[string]any{"op": "remove", "path": "members", "value": []any{map[string]any{"value": userIDs[1]}}},
+		},
+	})
+	require.Equal(t, http.StatusOK, status, body)
+	assert.Equal(t, userIDs[2:], scimMemberIDs(body))
+
+	_, otherToken := scimOrg(ctx, t, s)
+	status, otherUser := scimDo(t, s, otherToken, http.MethodPost, "/Users", map[string]any{"userName": gofakeit.Email()})
+	require.Equal(t, http.StatusCreated, status)
+
+	// only users of the org may be members
+	status, body = scimDo(t, s, token, http.MethodPatch, "/Groups/"+id, map[string]any{
+		"Operations": []any{
+			map[string]any{"op": "add", "path": "members", "value": []any{map[string]any{"value": otherUser["id"]}}},
+		},
+	})
+	require.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalidValue", body["scimType"])
+
+	status, body = scimDo(t, s, token, http.MethodGet, `/Groups?filter=displayName+eq+"platform"`, nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, float64(1), body["totalResults"])
+
+	status, body = scimDo(t, s, otherToken, http.MethodGet, `/Groups?filter=displayName+eq+"platform"`, nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, float64(0), body["totalResults"])
+
+	status, body = scimDo(t, s, token, http.MethodPut, "/Groups/"+id, map[string]any{
+		"displayName": "Platform",
+		"externalId":  "g1",
+		"members":     []any{map[string]any{"value": userIDs[0]}},
+	})
+	require.Equal(t, http.StatusOK, status, body)
+	assert.Equal(t, "g1", body["externalId"])
+	assert.Equal(t, []string{userIDs[0]}, scimMemberIDs(body))
+
+	// deprovisioned users leave groups of the org
+	status, _ = scimDo(t, s, token, http.MethodDelete, "/Users/"+userIDs[0], nil)
+	require.Equal(t, http.StatusNoContent, status)
+
+	status, body = scimDo(t, s, token, http.MethodGet, "/Groups/"+id, nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.Empty(t, scimMemberIDs(body))
+
+	status, _ = scimDo(t, s, token, http.MethodDelete, "/Groups/"+id, nil)
+	require.Equal(t, http.StatusNoContent, status)
+
+	status, body = scimDo(t, s, token, http.MethodGet, "/Groups/"+id, nil)
+	require.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, []any{scimErrorSchema}, body["schemas"])
+}
+
+// scimOrg creates org with a scim token and returns them
+func scimOrg(ctx context.Context, t *testing.T, s *suite.Suite) (orgID int64, token string) {
+	t.Helper()
+
+	orgID = createOrg(ctx, t, s, &authsvcv1.CreateOrgRequest{})
+	resp, err := s.OrgsClient.CreateSCIMToken(withToken(ctx, adminToken(t)), &authsvcv1.CreateSCIMTokenRequest{
+		OrgId: orgID,
+		Name:  "idp",
+	})
+	require.NoError(t, err)
+
+	return orgID, resp.GetToken()
+}
+
+// scimDo sends SCIM request with the token and returns status and decoded body of the response
+func scimDo(t *testing.T, s *suite.Suite, token string, method string, path string, body any) (int, map[string]any) {
+	t.Helper()
+
+	var reqBody bytes.Buffer
+	if body != nil {
+		require.NoError(t, json.NewEncoder(&reqBody).Encode(body))
+	}
+	req, err := http.NewRequest(method, s.HTTPURL+"/scim/v2"+path, &reqBody)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/scim+json")
+	}
+
+	resp := httpDo(t, req)
+	var out map[string]any
+	if resp.StatusCode != http.StatusNoContent {
+		assert.Equal(t, "application/scim+json", resp.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+	}
+
+	return resp.StatusCode, out
+}
+
+func scimMemberIDs(group map[string]any) []string {
+	ids := []string{}
+	members, _ := group["members"].([]any)
+	for _, member := range members {
+		ids = append(ids, member.(map[string]any)["value"].(string))
+	}
+	return ids
+}
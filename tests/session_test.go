@@ -0,0 +1,333 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	authsvcv1 "github.com/Len4i/auth-service/gen/go/authsvc"
+	"github.com/Len4i/auth-service/internal/lib/totp"
+	"github.com/Len4i/auth-service/tests/suite"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	sessionCookieName = "auth_session"
+	csrfCookieName    = "auth_csrf"
+)
+
+func TestSession_LoginCheckLogout(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	email, password, _ := registerAndLogin(ctx, t, s)
+
+	csrfCookie, csrfToken := sessionCSRF(t, s)
+	resp, body := sessionCall(t, s, http.MethodPost, "/session/login", []*http.Cookie{csrfCookie}, csrfToken, map[string]any{
+		"email":    email,
+		"password": password,
+		"app_id":   appID,
+	})
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	assert.Equal(t, email, body["email"])
+	assert.EqualValues(t, appID, body["app_id"])
+	assert.Equal(t, []any{"pwd"}, body["amr"])
+	sessionCSRFToken, _ := body["csrf_token"].(string)
+	require.NotEmpty(t, sessionCSRFToken)
+	assert.NotEqual(t, csrfToken, sessionCSRFToken)
+
+	cookie := responseCookie(t, resp, sessionCookieName)
+	assert.True(t, cookie.HttpOnly)
+	assert.True(t, cookie.Secure)
+	assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+	assert.Equal(t, "/", cookie.Path)
+	assert.InDelta(t, (24 * time.Hour).Seconds(), cookie.MaxAge, 5)
+	// the CSRF cookie of the login is cleared
+	assert.Equal(t, -1, responseCookie(t, resp, csrfCookieName).MaxAge)
+
+	resp, body = sessionCall(t, s, http.MethodGet, "/session", []*http.Cookie{cookie}, "", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, email, body["email"])
+	assert.Equal(t, sessionCSRFToken, body["csrf_token"])
+
+	// logout requires the CSRF token of the session
+	resp, body = sessionCall(t, s, http.MethodPost, "/session/logout", []*http.Cookie{cookie}, "", nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, "invalid_csrf_token", body["error"])
+	resp, _ = sessionCall(t, s, http.MethodPost, "/session/logout", []*http.Cookie{cookie}, csrfToken, nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp, _ = sessionCall(t, s, http.MethodPost, "/session/logout", []*http.Cookie{cookie}, sessionCSRFToken, nil)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, -1, responseCookie(t, resp, sessionCookieName).MaxAge)
+
+	resp, body = sessionCall(t, s, http.MethodGet, "/session", []*http.Cookie{cookie}, "", nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, "invalid_session", body["error"])
+}
+
+func TestSession_LoginErrors(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	email, password, _ := registerAndLogin(ctx, t, s)
+	csrfCookie, csrfToken := sessionCSRF(t, s)
+	otherCookie, _ := sessionCSRF(t, s)
+	login := map[string]any{"email": email, "password": password, "app_id": appID}
+
+	tests := []struct {
+		name       string
+		cookies    []*http.Cookie
+		csrfToken  string
+		body       map[string]any
+		wantStatus int
+		wantError  string
+	}{
+		{
+			name:       "no csrf token",
+			cookies:    []*http.Cookie{csrfCookie},
+			body:       login,
+			wantStatus: http.StatusForbidden,
+			wantError:  "invalid_csrf_token",
+		},
+		{
+			name:       "no csrf cookie",
+			csrfToken:  csrfToken,
+			body:       login,
+			wantStatus: http.StatusForbidden,
+			wantError:  "invalid_csrf_token",
+		},
+		{
+			name:       "csrf token of another cookie",
+			cookies:    []*http.Cookie{otherCookie},
+			csrfToken:  csrfToken,
+			body:       login,
+			wantStatus: http.StatusForbidden,
+			wantError:  "invalid_csrf_token",
+		},
+		{
+			name:       "wrong password",
+			cookies:    []*http.Cookie{csrfCookie},
+			csrfToken:  csrfToken,
+			body:       map[string]any{"email": email, "password": "wrong", "app_id": appID},
+			wantStatus: http.StatusUnauthorized,
+			wantError:  "invalid_credentials",
+		},
+		{
+			name:       "no app",
+			cookies:    []*http.Cookie{csrfCookie},
+			csrfToken:  csrfToken,
+			body:       map[string]any{"email": email, "password": password},
+			wantStatus: http.StatusBadRequest,
+			wantError:  "invalid_request",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := sessionCall(t, s, http.MethodPost, "/session/login", tt.cookies, tt.csrfToken, tt.body)
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			assert.Equal(t, tt.wantError, body["error"])
+			for _, cookie := range resp.Cookies() {
+				assert.NotEqual(t, sessionCookieName, cookie.Name)
+			}
+		})
+	}
+}
+
+func TestSession_MFA(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	email, password, token := registerAndLogin(ctx, t, s)
+	userCtx := withToken(ctx, token)
+	now := time.Now()
+	respEnroll, err := s.MFAClient.EnrollTOTP(userCtx, &authsvcv1.EnrollTOTPRequest{})
+	require.NoError(t, err)
+	_, err = s.MFAClient.ConfirmTOTP(userCtx, &authsvcv1.ConfirmTOTPRequest{
+		Code: totpCode(t, respEnroll.GetSecret(), now),
+	})
+	require.NoError(t, err)
+
+	csrfCookie, csrfToken := sessionCSRF(t, s)
+	resp, body := sessionCall(t, s, http.MethodPost, "/session/login", []*http.Cookie{csrfCookie}, csrfToken, map[string]any{
+		"email":    email,
+		"password": password,
+		"app_id":   appID,
+	})
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, "mfa_required", body["error"])
+	challenge, _ := body["mfa_challenge"].(string)
+	require.NotEmpty(t, challenge)
+
+	resp, body = sessionCall(t, s, http.MethodPost, "/session/mfa", []*http.Cookie{csrfCookie}, csrfToken, map[string]any{
+		"mfa_challenge": challenge,
+		"code":          "000000",
+	})
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, "invalid_mfa_code", body["error"])
+
+	resp, body = sessionCall(t, s, http.MethodPost, "/session/mfa", []*http.Cookie{csrfCookie}, csrfToken, map[string]any{
+		"mfa_challenge": challenge,
+		"code":          totpCode(t, respEnroll.GetSecret(), now.Add(totp.Period)),
+	})
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	assert.ElementsMatch(t, []any{"pwd", "otp", "mfa"}, body["amr"])
+
+	resp, body = sessionCall(t, s, http.MethodGet, "/session", []*http.Cookie{responseCookie(t, resp, sessionCookieName)}, "", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, email, body["email"])
+}
+
+func TestSession_CurrentRoles(t *testing.T) {
+	ctx, s := suite.New(t)
+	adminCtx := withToken(ctx, adminToken(t))
+
+	_, _, ownerToken := registerAndLogin(ctx, t, s)
+	orgID := createOrg(ctx, t, s, &authsvcv1.CreateOrgRequest{OwnerUserId: tokenUserID(t, ownerToken)})
+
+	email, password, token := registerAndLogin(ctx, t, s)
+	userID := tokenUserID(t, token)
+	_, err := s.OrgsClient.AddMember(adminCtx, &authsvcv1.AddMemberRequest{OrgId: orgID, UserId: userID, Role: "admin"})
+	require.NoError(t, err)
+	role := "editor-" + strings.ToLower(gofakeit.LetterN(8))
+	_, err = s.RBACClient.CreateRole(adminCtx, &authsvcv1.CreateRoleRequest{AppId: appID, Name: role})
+	require.NoError(t, err)
+	_, err = s.RBACClient.AssignRole(adminCtx, &authsvcv1.AssignRoleRequest{UserId: userID, AppId: appID, Role: role})
+	require.NoError(t, err)
+
+	csrfCookie, csrfToken := sessionCSRF(t, s)
+	resp, body := sessionCall(t, s, http.MethodPost, "/session/login", []*http.Cookie{csrfCookie}, csrfToken, map[string]any{
+		"email":    email,
+		"password": password,
+		"app_id":   appID,
+		"org_id":   orgID,
+	})
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	cookie := responseCookie(t, resp, sessionCookieName)
+	assert.Equal(t, []any{role}, body["roles"])
+	assert.Equal(t, "admin", body["org_role"])
+
+	_, err = s.RBACClient.RevokeRole(adminCtx, &authsvcv1.RevokeRoleRequest{UserId: userID, AppId: appID, Role: role})
+	require.NoError(t, err)
+	_, err = s.OrgsClient.AddMember(adminCtx, &authsvcv1.AddMemberRequest{OrgId: orgID, UserId: userID, Role: "member"})
+	require.NoError(t, err)
+
+	// changes of roles apply to the running session
+	resp, body = sessionCall(t, s, http.MethodGet, "/session", []*http.Cookie{cookie}, "", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Nil(t, body["roles"])
+	assert.Equal(t, "member", body["org_role"])
+
+	// and the session ends with the membership in its org
+	_, err = s.OrgsClient.RemoveMember(adminCtx, &authsvcv1.RemoveMemberRequest{OrgId: orgID, UserId: userID})
+	require.NoError(t, err)
+	resp, body = sessionCall(t, s, http.MethodGet, "/session", []*http.Cookie{cookie}, "", nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, "invalid_session", body["error"])
+}
+
+func TestSession_Origins(t *testing.T) {
+	_, s := suite.New(t)
+
+	csrfCookie, csrfToken := sessionCSRF(t, s)
+	login := func(origin string, app int) int {
+		reqBody, err := json.Marshal(map[string]any{
+			"email":    profileUserEmail,
+			"password": profileUserPass,
+			"app_id":   app,
+		})
+		require.NoError(t, err)
+		req, err := http.NewRequest(http.MethodPost, s.HTTPURL+"/session/login", bytes.NewReader(reqBody))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", csrfToken)
+		req.Header.Set("Origin", origin)
+		req.AddCookie(csrfCookie)
+		resp := httpDo(t, req)
+		if origin == webOrigin && resp.StatusCode == http.StatusOK {
+			assert.Equal(t, webOrigin, resp.Header.Get("Access-Control-Allow-Origin"))
+			assert.Equal(t, "true", resp.Header.Get("Access-Control-Allow-Credentials"))
+		}
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusOK, login(webOrigin, appID))
+	assert.Equal(t, http.StatusForbidden, login("https://evil.example.com", appID))
+	assert.Equal(t, http.StatusForbidden, login(webOrigin, notExistAppID))
+
+	req, err := http.NewRequest(http.MethodOptions, s.HTTPURL+"/session/login", nil)
+	require.NoError(t, err)
+	req.Header.Set("Origin", webOrigin)
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	resp := httpDo(t, req)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, "true", resp.Header.Get("Access-Control-Allow-Credentials"))
+	assert.Contains(t, resp.Header.Get("Access-Control-Allow-Headers"), "X-CSRF-Token")
+}
+
+// sessionCSRF returns CSRF cookie and token of a login
+func sessionCSRF(t *testing.T, s *suite.Suite) (*http.Cookie, string) {
+	t.Helper()
+
+	resp, body := sessionCall(t, s, http.MethodGet, "/session/csrf", nil, "", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	token, _ := body["csrf_token"].(string)
+	require.NotEmpty(t, token)
+
+	cookie := responseCookie(t, resp, csrfCookieName)
+	assert.True(t, cookie.HttpOnly)
+	assert.True(t, cookie.Secure)
+	assert.Equal(t, token, cookie.Value)
+	return cookie, token
+}
+
+// sessionCall sends request to session endpoint at path with cookies,
+// since they are Secure, and decodes JSON response if there is one
+func sessionCall(
+	t *testing.T,
+	s *suite.Suite,
+	method string,
+	path string,
+	cookies []*http.Cookie,
+	csrfToken string,
+	body any,
+) (*http.Response, map[string]any) {
+	t.Helper()
+
+	var reqBody bytes.Buffer
+	if body != nil {
+		require.NoError(t, json.NewEncoder(&reqBody).Encode(body))
+	}
+	req, err := http.NewRequest(method, s.HTTPURL+path, &reqBody)
+	require.NoError(t, err)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if csrfToken != "" {
+		req.Header.Set("X-CSRF-Token", csrfToken)
+	}
+	for _, cookie := range cookies {
+		req.AddCookie(&http.Cookie{Name: cookie.Name, Value: cookie.Value})
+	}
+
+	resp := httpDo(t, req)
+	var respBody map[string]any
+	if resp.StatusCode != http.StatusNoContent {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&respBody))
+	}
+	return resp, respBody
+}
+
+func responseCookie(t *testing.T, resp *http.Response, name string) *http.Cookie {
+	t.Helper()
+
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+	t.Fatalf("response has no %s cookie", name)
+	return nil
+}
@@ -0,0 +1,272 @@
+// Package softauthn is a software WebAuthn authenticator for tests
+//
+// It creates ES256 passkeys with "none" attestation and answers
+// options JSON returned by the WebAuthn service the way a browser would.
+package softauthn
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+const (
+	flagUserPresent    = 0x01
+	flagUserVerified   = 0x04
+	flagBackupEligible = 0x08
+	flagBackupState    = 0x10
+	flagAttestedData   = 0x40
+)
+
+var ErrNoCredential = errors.New("no matching credential")
+
+// Authenticator holds credentials created for any relying party
+//
+// SignCount is shared by all credentials and incremented before every assertion.
+type Authenticator struct {
+	Origin       string
+	UserVerified bool
+	SignCount    uint32
+	credentials  []*Credential
+}
+
+type Credential struct {
+	ID         []byte
+	RPID       string
+	UserHandle []byte
+	key        *ecdsa.PrivateKey
+}
+
+func New(origin string) *Authenticator {
+	return &Authenticator{
+		Origin:       origin,
+		UserVerified: true,
+	}
+}
+
+type descriptor struct {
+	ID string `json:"id"`
+}
+
+type creationOptions struct {
+	PublicKey struct {
+		Challenge string `json:"challenge"`
+		RP        struct {
+			ID string `json:"id"`
+		} `json:"rp"`
+		User struct {
+			ID string `json:"id"`
+		} `json:"user"`
+		ExcludeCredentials []descriptor `json:"excludeCredentials"`
+	} `json:"publicKey"`
+}
+
+type requestOptions struct {
+	PublicKey struct {
+		Challenge        string       `json:"challenge"`
+		RPID             string       `json:"rpId"`
+		AllowCredentials []descriptor `json:"allowCredentials"`
+	} `json:"publicKey"`
+}
+
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+type coseKey struct {
+	Kty int    `cbor:"1,keyasint"`
+	Alg int    `cbor:"3,keyasint"`
+	Crv int    `cbor:"-1,keyasint"`
+	X   []byte `cbor:"-2,keyasint"`
+	Y   []byte `cbor:"-3,keyasint"`
+}
+
+type attestationObject struct {
+	Fmt      string         `cbor:"fmt"`
+	AttStmt  map[string]any `cbor:"attStmt"`
+	AuthData []byte         `cbor:"authData"`
+}
+
+// Create makes a new credential for PublicKeyCredentialCreationOptions JSON
+// and returns PublicKeyCredential JSON
+func (a *Authenticator) Create(options []byte) ([]byte, *Credential, error) {
+	var opts creationOptions
+	if err := json.Unmarshal(options, &opts); err != nil {
+		return nil, nil, err
+	}
+
+	for _, excluded := range opts.PublicKey.ExcludeCredentials {
+		for _, cred := range a.credentials {
+			if excluded.ID == encode(cred.ID) {
+				return nil, nil, fmt.Errorf("credential %s is excluded", excluded.ID)
+			}
+		}
+	}
+
+	userHandle, err := base64.RawURLEncoding.DecodeString(opts.PublicKey.User.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cred := &Credential{
+		ID:         make([]byte, 16),
+		RPID:       opts.PublicKey.RP.ID,
+		UserHandle: userHandle,
+		key:        key,
+	}
+	if _, err := rand.Read(cred.ID); err != nil {
+		return nil, nil, err
+	}
+
+	publicKey, err := cbor.Marshal(coseKey{
+		Kty: 2,  // EC2
+		Alg: -7, // ES256
+		Crv: 1,  // P-256
+		X:   key.PublicKey.X.FillBytes(make([]byte, 32)),
+		Y:   key.PublicKey.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	authData := a.authData(cred.RPID, flagAttestedData, 0)
+	authData = append(authData, make([]byte, 16)...) // AAGUID
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(cred.ID)))
+	authData = append(authData, cred.ID...)
+	authData = append(authData, publicKey...)
+
+	attestation, err := cbor.Marshal(attestationObject{
+		Fmt:      "none",
+		AttStmt:  map[string]any{},
+		AuthData: authData,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	clientDataJSON, err := a.clientData("webauthn.create", opts.PublicKey.Challenge)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	a.credentials = append(a.credentials, cred)
+
+	response, err := json.Marshal(map[string]any{
+		"id":                      encode(cred.ID),
+		"rawId":                   encode(cred.ID),
+		"type":                    "public-key",
+		"authenticatorAttachment": "platform",
+		"response": map[string]any{
+			"clientDataJSON":    encode(clientDataJSON),
+			"attestationObject": encode(attestation),
+			"transports":        []string{"internal", "hybrid"},
+		},
+		"clientExtensionResults": map[string]any{},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return response, cred, nil
+}
+
+// Get signs PublicKeyCredentialRequestOptions JSON with a matching credential
+// and returns PublicKeyCredential JSON
+//
+// Any credential of the relying party matches empty allow list, like a
+// discoverable credential picked by user.
+func (a *Authenticator) Get(options []byte) ([]byte, error) {
+	var opts requestOptions
+	if err := json.Unmarshal(options, &opts); err != nil {
+		return nil, err
+	}
+
+	cred := a.find(opts.PublicKey.RPID, opts.PublicKey.AllowCredentials)
+	if cred == nil {
+		return nil, ErrNoCredential
+	}
+
+	a.SignCount++
+	authData := a.authData(cred.RPID, 0, a.SignCount)
+
+	clientDataJSON, err := a.clientData("webauthn.get", opts.PublicKey.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(authData, clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, cred.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(map[string]any{
+		"id":    encode(cred.ID),
+		"rawId": encode(cred.ID),
+		"type":  "public-key",
+		"response": map[string]any{
+			"clientDataJSON":    encode(clientDataJSON),
+			"authenticatorData": encode(authData),
+			"signature":         encode(signature),
+			"userHandle":        encode(cred.UserHandle),
+		},
+		"clientExtensionResults": map[string]any{},
+	})
+}
+
+func (a *Authenticator) find(rpID string, allowed []descriptor) *Credential {
+	for _, cred := range a.credentials {
+		if cred.RPID != rpID {
+			continue
+		}
+		if len(allowed) == 0 {
+			return cred
+		}
+		for _, d := range allowed {
+			if d.ID == encode(cred.ID) {
+				return cred
+			}
+		}
+	}
+	return nil
+}
+
+func (a *Authenticator) authData(rpID string, flags byte, signCount uint32) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+
+	flags |= flagUserPresent | flagBackupEligible | flagBackupState
+	if a.UserVerified {
+		flags |= flagUserVerified
+	}
+
+	authData := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(authData, signCount)
+}
+
+func (a *Authenticator) clientData(typ string, challenge string) ([]byte, error) {
+	return json.Marshal(clientData{
+		Type:      typ,
+		Challenge: challenge,
+		Origin:    a.Origin,
+	})
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
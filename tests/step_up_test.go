@@ -0,0 +1,135 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	authsvcv1 "github.com/Len4i/auth-service/gen/go/authsvc"
+	"github.com/Len4i/auth-service/internal/lib/totp"
+	"github.com/Len4i/auth-service/tests/softauthn"
+	"github.com/Len4i/auth-service/tests/suite"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStepUp_TOTP(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	_, _, token := registerAndLogin(ctx, t, s)
+	userCtx := withToken(ctx, token)
+
+	claims := tokenClaims(t, token)
+	assert.Equal(t, []interface{}{"pwd"}, claims["amr"])
+	assert.Equal(t, "aal1", claims["acr"])
+	authTime := int64(claims["auth_time"].(float64))
+	assert.InDelta(t, time.Now().Unix(), authTime, 60)
+
+	_, err := s.MFAClient.StepUp(userCtx, &authsvcv1.StepUpRequest{Code: "000000"})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = FailedPrecondition desc = second factor is not enabled")
+
+	respEnroll, err := s.MFAClient.EnrollTOTP(userCtx, &authsvcv1.EnrollTOTPRequest{})
+	require.NoError(t, err)
+	now := time.Now()
+	_, err = s.MFAClient.ConfirmTOTP(userCtx, &authsvcv1.ConfirmTOTPRequest{
+		Code: totpCode(t, respEnroll.GetSecret(), now),
+	})
+	require.NoError(t, err)
+
+	_, err = s.MFAClient.StepUp(userCtx, &authsvcv1.StepUpRequest{Code: totpCode(t, respEnroll.GetSecret(), now)})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = Unauthenticated desc = code is not valid")
+
+	respStepUp, err := s.MFAClient.StepUp(userCtx, &authsvcv1.StepUpRequest{
+		Code: totpCode(t, respEnroll.GetSecret(), now.Add(totp.Period)),
+	})
+	require.NoError(t, err)
+
+	claims = tokenClaims(t, respStepUp.GetToken())
+	assert.Equal(t, []interface{}{"pwd", "otp", "mfa"}, claims["amr"])
+	assert.Equal(t, "aal2", claims["acr"])
+	assert.GreaterOrEqual(t, int64(claims["auth_time"].(float64)), authTime)
+
+	// step-up token can be used as a regular one
+	_, err = s.MFAClient.GetMFAStatus(withToken(ctx, respStepUp.GetToken()), &authsvcv1.GetMFAStatusRequest{})
+	require.NoError(t, err)
+}
+
+func TestStepUp_Attempts(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	_, _, token := registerAndLogin(ctx, t, s)
+	userCtx := withToken(ctx, token)
+
+	respEnroll, err := s.MFAClient.EnrollTOTP(userCtx, &authsvcv1.EnrollTOTPRequest{})
+	require.NoError(t, err)
+	now := time.Now()
+	_, err = s.MFAClient.ConfirmTOTP(userCtx, &authsvcv1.ConfirmTOTPRequest{
+		Code: totpCode(t, respEnroll.GetSecret(), now),
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		_, err := s.MFAClient.StepUp(userCtx, &authsvcv1.StepUpRequest{Code: "not-a-code"})
+		require.EqualError(t, err, "rpc error: code = Unauthenticated desc = code is not valid")
+	}
+
+	_, err = s.MFAClient.StepUp(userCtx, &authsvcv1.StepUpRequest{
+		Code: totpCode(t, respEnroll.GetSecret(), now.Add(totp.Period)),
+	})
+	assert.EqualError(t, err, "rpc error: code = ResourceExhausted desc = too many attempts, try again later")
+}
+
+func TestStepUp_Passkey(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	email, _, token := registerAndLogin(ctx, t, s)
+	authenticator := softauthn.New(s.Cfg.WebAuthn.RPOrigins[0])
+	registerPasskey(ctx, t, s, authenticator, token)
+
+	_, err := s.MFAClient.StepUp(withToken(ctx, token), &authsvcv1.StepUpRequest{})
+	require.Error(t, err)
+	challenge := mfaRequiredChallenge(t, err)
+
+	respBegin, err := s.WebAuthnClient.BeginLogin(ctx, &authsvcv1.BeginLoginRequest{MfaChallenge: challenge})
+	require.NoError(t, err)
+	assertion, err := authenticator.Get(respBegin.GetOptions())
+	require.NoError(t, err)
+	respFinish, err := s.WebAuthnClient.FinishLogin(ctx, &authsvcv1.FinishLoginRequest{
+		Session:    respBegin.GetSession(),
+		Credential: assertion,
+	})
+	require.NoError(t, err)
+	assertTokenUser(t, respFinish.GetToken(), email)
+
+	claims := tokenClaims(t, respFinish.GetToken())
+	assert.Equal(t, []interface{}{"pwd", "hwk", "mfa"}, claims["amr"])
+	assert.Equal(t, "aal2", claims["acr"])
+
+	// user verification makes passkey login multi-factor
+	claims = tokenClaims(t, passkeyLogin(ctx, t, s, authenticator, email))
+	assert.Equal(t, []interface{}{"hwk", "mfa"}, claims["amr"])
+	assert.Equal(t, "aal2", claims["acr"])
+}
+
+func TestStepUp_RequireUser(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	_, err := s.MFAClient.StepUp(ctx, &authsvcv1.StepUpRequest{Code: "000000"})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = Unauthenticated desc = authentication required")
+}
+
+func tokenClaims(t *testing.T, token string) jwt.MapClaims {
+	t.Helper()
+
+	parsed, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
+		return []byte(appSecret), nil
+	})
+	require.NoError(t, err)
+
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	require.True(t, ok)
+	return claims
+}
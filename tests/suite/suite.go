@@ -2,31 +2,52 @@ package suite
 
 import (
 	"context"
+	"encoding/base64"
 	"net"
 	"strconv"
 	"testing"
+	"time"
 
 	aaav1 "github.com/Len4i/aaa/gen/go/aaa"
+	authsvcv1 "github.com/Len4i/auth-service/gen/go/authsvc"
 	"github.com/Len4i/auth-service/internal/config"
+	"github.com/Len4i/auth-service/internal/domain/models"
+	"github.com/Len4i/auth-service/internal/lib/jwt"
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 )
 
 const (
-	localHost = "localhost"
+	localHost  = "localhost"
+	configPath = "../configs/local_tests.yaml"
 )
 
 type Suite struct {
 	*testing.T
-	AuthClient aaav1.AuthClient
-	Cfg        *config.Config
+	AuthClient         aaav1.AuthClient
+	InvitesClient      authsvcv1.InvitesClient
+	MFAClient          authsvcv1.MFAClient
+	WebAuthnClient     authsvcv1.WebAuthnClient
+	PasswordlessClient authsvcv1.PasswordlessClient
+	RBACClient         authsvcv1.RBACClient
+	OrgsClient         authsvcv1.OrgsClient
+	PoliciesClient     authsvcv1.PoliciesClient
+	RelationsClient    authsvcv1.RelationsClient
+	APIKeysClient      authsvcv1.APIKeysClient
+	ClientsClient      authsvcv1.ClientsClient
+	ExtAuthzClient     authv3.AuthorizationClient
+	HTTPURL            string
+	GatewayURL         string
+	GRPCWebURL         string
+	Cfg                *config.Config
 }
 
 func New(t *testing.T) (context.Context, *Suite) {
 	t.Helper()
 	t.Parallel()
 
-	cfg := config.MustLoadByPath("../configs/local_tests.yaml")
+	cfg := config.MustLoadByPath(configPath)
 	ctx, cancelCtx := context.WithTimeout(context.Background(), cfg.GRPC.Timeout)
 
 	t.Cleanup(func() {
@@ -40,12 +61,56 @@ func New(t *testing.T) (context.Context, *Suite) {
 	}
 
 	return ctx, &Suite{
-		T:          t,
-		AuthClient: aaav1.NewAuthClient(cc),
-		Cfg:        cfg,
+		T:                  t,
+		AuthClient:         aaav1.NewAuthClient(cc),
+		InvitesClient:      authsvcv1.NewInvitesClient(cc),
+		MFAClient:          authsvcv1.NewMFAClient(cc),
+		WebAuthnClient:     authsvcv1.NewWebAuthnClient(cc),
+		PasswordlessClient: authsvcv1.NewPasswordlessClient(cc),
+		RBACClient:         authsvcv1.NewRBACClient(cc),
+		OrgsClient:         authsvcv1.NewOrgsClient(cc),
+		PoliciesClient:     authsvcv1.NewPoliciesClient(cc),
+		RelationsClient:    authsvcv1.NewRelationsClient(cc),
+		APIKeysClient:      authsvcv1.NewAPIKeysClient(cc),
+		ClientsClient:      authsvcv1.NewClientsClient(cc),
+		ExtAuthzClient:     authv3.NewAuthorizationClient(cc),
+		HTTPURL:            httpURL(cfg),
+		GatewayURL:         gatewayURL(cfg),
+		GRPCWebURL:         grpcWebURL(cfg),
+		Cfg:                cfg,
 	}
 }
 
+// IssueToken issues token of user in app with the token key of the server,
+// e.g. for the seeded admin user who has no password to log in with
+func IssueToken(t *testing.T, user models.User, app models.App, scope jwt.Scope) string {
+	t.Helper()
+
+	cfg := config.MustLoadByPath(configPath)
+	key, err := base64.StdEncoding.DecodeString(cfg.TokenKey)
+	if err != nil || len(key) == 0 {
+		t.Fatalf("token_key of the test config is not valid: %v", err)
+	}
+
+	token, err := jwt.NewToken(user, app, scope, jwt.Authentication{}, time.Hour, key)
+	if err != nil {
+		t.Fatalf("failed to issue token: %v", err)
+	}
+	return token
+}
+
 func grpcAddress(cfg *config.Config) string {
 	return net.JoinHostPort(localHost, strconv.Itoa(cfg.GRPC.Port))
 }
+
+func httpURL(cfg *config.Config) string {
+	return "http://" + net.JoinHostPort(localHost, strconv.Itoa(cfg.HTTP.Port))
+}
+
+func gatewayURL(cfg *config.Config) string {
+	return "http://" + net.JoinHostPort(localHost, strconv.Itoa(cfg.Gateway.Port))
+}
+
+func grpcWebURL(cfg *config.Config) string {
+	return "http://" + net.JoinHostPort(localHost, strconv.Itoa(cfg.GRPC.Web.Port))
+}
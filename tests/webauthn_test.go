@@ -0,0 +1,245 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	aaav1 "github.com/Len4i/aaa/gen/go/aaa"
+	authsvcv1 "github.com/Len4i/auth-service/gen/go/authsvc"
+	"github.com/Len4i/auth-service/tests/softauthn"
+	"github.com/Len4i/auth-service/tests/suite"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/status"
+)
+
+func TestWebAuthn_SecondFactor(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	email, password, token := registerAndLogin(ctx, t, s)
+	authenticator := softauthn.New(s.Cfg.WebAuthn.RPOrigins[0])
+	registerPasskey(ctx, t, s, authenticator, token)
+
+	respList, err := s.WebAuthnClient.ListCredentials(withToken(ctx, token), &authsvcv1.ListCredentialsRequest{})
+	require.NoError(t, err)
+	require.Len(t, respList.GetCredentials(), 1)
+	cred := respList.GetCredentials()[0]
+	assert.Equal(t, "test key", cred.GetName())
+	assert.ElementsMatch(t, []string{"internal", "hybrid"}, cred.GetTransports())
+	assert.True(t, cred.GetBackupEligible())
+	assert.Zero(t, cred.GetLastUsedAt())
+
+	_, err = s.AuthClient.Login(ctx, &aaav1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.Error(t, err)
+	var methods string
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			methods = info.GetMetadata()["methods"]
+		}
+	}
+	assert.Equal(t, "webauthn", methods)
+
+	challenge := loginMFAChallenge(ctx, t, s, email, password)
+	respBegin, err := s.WebAuthnClient.BeginLogin(ctx, &authsvcv1.BeginLoginRequest{MfaChallenge: challenge})
+	require.NoError(t, err)
+
+	assertion, err := authenticator.Get(respBegin.GetOptions())
+	require.NoError(t, err)
+
+	respFinish, err := s.WebAuthnClient.FinishLogin(ctx, &authsvcv1.FinishLoginRequest{
+		Session:    respBegin.GetSession(),
+		Credential: assertion,
+	})
+	require.NoError(t, err)
+	assertTokenUser(t, respFinish.GetToken(), email)
+
+	// session is single use
+	_, err = s.WebAuthnClient.FinishLogin(ctx, &authsvcv1.FinishLoginRequest{
+		Session:    respBegin.GetSession(),
+		Credential: assertion,
+	})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = Unauthenticated desc = session is not valid")
+
+	// challenge is completed
+	_, err = s.WebAuthnClient.BeginLogin(ctx, &authsvcv1.BeginLoginRequest{MfaChallenge: challenge})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = Unauthenticated desc = challenge is not valid")
+
+	respList, err = s.WebAuthnClient.ListCredentials(withToken(ctx, token), &authsvcv1.ListCredentialsRequest{})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, respList.GetCredentials()[0].GetSignCount())
+	assert.NotZero(t, respList.GetCredentials()[0].GetLastUsedAt())
+}
+
+func TestWebAuthn_Passwordless(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	email, _, token := registerAndLogin(ctx, t, s)
+	authenticator := softauthn.New(s.Cfg.WebAuthn.RPOrigins[0])
+	registerPasskey(ctx, t, s, authenticator, token)
+
+	// discoverable credential
+	assertTokenUser(t, passkeyLogin(ctx, t, s, authenticator, ""), email)
+	// credentials of the user
+	assertTokenUser(t, passkeyLogin(ctx, t, s, authenticator, email), email)
+
+	_, err := s.WebAuthnClient.BeginLogin(ctx, &authsvcv1.BeginLoginRequest{AppId: appID, Email: "unknown@localhost.com"})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = Unauthenticated desc = invalid credentials")
+
+	_, err = s.WebAuthnClient.BeginLogin(ctx, &authsvcv1.BeginLoginRequest{Email: email})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = InvalidArgument desc = app_id is required")
+
+	tests := []struct {
+		name          string
+		mutate        func(a *softauthn.Authenticator)
+		expectedError string
+	}{
+		{
+			name:          "user not verified",
+			mutate:        func(a *softauthn.Authenticator) { a.UserVerified = false },
+			expectedError: "credential is not valid",
+		},
+		{
+			name:          "cloned authenticator",
+			mutate:        func(a *softauthn.Authenticator) { a.SignCount = 0 },
+			expectedError: "credential is not valid",
+		},
+		{
+			name:          "wrong origin",
+			mutate:        func(a *softauthn.Authenticator) { a.Origin = "https://phishing.example.com" },
+			expectedError: "credential is not valid",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clone := *authenticator
+			tt.mutate(&clone)
+
+			respBegin, err := s.WebAuthnClient.BeginLogin(ctx, &authsvcv1.BeginLoginRequest{AppId: appID})
+			require.NoError(t, err)
+
+			assertion, err := clone.Get(respBegin.GetOptions())
+			require.NoError(t, err)
+
+			_, err = s.WebAuthnClient.FinishLogin(ctx, &authsvcv1.FinishLoginRequest{
+				Session:    respBegin.GetSession(),
+				Credential: assertion,
+			})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expectedError)
+		})
+	}
+}
+
+func TestWebAuthn_DeleteCredential(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	email, password, token := registerAndLogin(ctx, t, s)
+	authenticator := softauthn.New(s.Cfg.WebAuthn.RPOrigins[0])
+	id := registerPasskey(ctx, t, s, authenticator, token)
+
+	// credential can't be registered twice from the same authenticator
+	respBegin, err := s.WebAuthnClient.BeginRegistration(withToken(ctx, token), &authsvcv1.BeginRegistrationRequest{})
+	require.NoError(t, err)
+	_, _, err = authenticator.Create(respBegin.GetOptions())
+	require.Error(t, err)
+
+	_, _, otherToken := registerAndLogin(ctx, t, s)
+	_, err = s.WebAuthnClient.DeleteCredential(withToken(ctx, otherToken), &authsvcv1.DeleteCredentialRequest{Id: id})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = NotFound desc = credential not found")
+
+	_, err = s.WebAuthnClient.DeleteCredential(withToken(ctx, token), &authsvcv1.DeleteCredentialRequest{Id: id})
+	require.NoError(t, err)
+
+	respLogin, err := s.AuthClient.Login(ctx, &aaav1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+	assert.NotEmpty(t, respLogin.GetToken())
+}
+
+func TestWebAuthn_RequireUser(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	_, err := s.WebAuthnClient.BeginRegistration(ctx, &authsvcv1.BeginRegistrationRequest{})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = Unauthenticated desc = authentication required")
+
+	_, err = s.WebAuthnClient.FinishLogin(ctx, &authsvcv1.FinishLoginRequest{Session: "unknown", Credential: []byte("{}")})
+	require.Error(t, err)
+	assert.EqualError(t, err, "rpc error: code = Unauthenticated desc = session is not valid")
+}
+
+// registerPasskey registers new credential of authenticator and returns its ID
+func registerPasskey(
+	ctx context.Context,
+	t *testing.T,
+	s *suite.Suite,
+	authenticator *softauthn.Authenticator,
+	token string,
+) int64 {
+	t.Helper()
+
+	userCtx := withToken(ctx, token)
+
+	respBegin, err := s.WebAuthnClient.BeginRegistration(userCtx, &authsvcv1.BeginRegistrationRequest{})
+	require.NoError(t, err)
+	require.NotEmpty(t, respBegin.GetSession())
+
+	credential, _, err := authenticator.Create(respBegin.GetOptions())
+	require.NoError(t, err)
+
+	respFinish, err := s.WebAuthnClient.FinishRegistration(userCtx, &authsvcv1.FinishRegistrationRequest{
+		Session:    respBegin.GetSession(),
+		Credential: credential,
+		Name:       "test key",
+	})
+	require.NoError(t, err)
+	require.NotZero(t, respFinish.GetCredential().GetId())
+
+	return respFinish.GetCredential().GetId()
+}
+
+// passkeyLogin logs in to the test app without password and returns token
+func passkeyLogin(
+	ctx context.Context,
+	t *testing.T,
+	s *suite.Suite,
+	authenticator *softauthn.Authenticator,
+	email string,
+) string {
+	t.Helper()
+
+	respBegin, err := s.WebAuthnClient.BeginLogin(ctx, &authsvcv1.BeginLoginRequest{AppId: appID, Email: email})
+	require.NoError(t, err)
+
+	assertion, err := authenticator.Get(respBegin.GetOptions())
+	require.NoError(t, err)
+
+	respFinish, err := s.WebAuthnClient.FinishLogin(ctx, &authsvcv1.FinishLoginRequest{
+		Session:    respBegin.GetSession(),
+		Credential: assertion,
+	})
+	require.NoError(t, err)
+
+	return respFinish.GetToken()
+}
+
+func assertTokenUser(t *testing.T, token string, email string) {
+	t.Helper()
+
+	parsed, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
+		return []byte(appSecret), nil
+	})
+	require.NoError(t, err)
+
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	require.True(t, ok)
+	assert.Equal(t, email, claims["email"].(string))
+	assert.Equal(t, appID, int(claims["app_id"].(float64)))
+}